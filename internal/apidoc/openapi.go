@@ -0,0 +1,378 @@
+// Package apidoc 根据 Go 结构体生成 OpenAPI 3.0 接口文档
+// 接口的请求与响应直接引用处理函数实际使用的结构体，字段由 json / binding 标签推导，文档与实现不会脱节
+package apidoc
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version 生成文档使用的 OpenAPI 版本
+const Version = "3.0.3"
+
+// Spec OpenAPI 文档根对象
+type Spec struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Info 文档基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Components 可复用的 Schema 与鉴权方式
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 鉴权方式
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Schema OpenAPI Schema 对象 (仅包含生成时用到的字段)
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// Operation 单个接口
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+// Parameter 路径或查询参数
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType 请求体或响应体的内容
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Param 描述 Route 的路径或查询参数，In 为 "path" 或 "query"，Type 为 OpenAPI 基本类型 (默认 string)
+type Param struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// FormField 描述 multipart/form-data 请求中的字段
+type FormField struct {
+	Name        string
+	Description string
+	File        bool // 文件字段
+	Multiple    bool // 同名字段可出现多次 (如批量上传)
+	Required    bool
+}
+
+// Route 描述一个接口，路径使用 gin 的 :param 写法
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	Auth    bool // 需要携带登录 Token
+	Params  []Param
+	// Body JSON 请求体，传入对应结构体的零值 (如 LoginRequest{})
+	Body interface{}
+	// Form multipart/form-data 请求字段，与 Body 二选一
+	Form []FormField
+	// Response 成功 (200) 时的响应体，传入对应结构体的零值；nil 表示无响应体
+	Response interface{}
+}
+
+// Builder 收集接口并生成文档，结构体 Schema 注册到 components 中复用
+type Builder struct {
+	info      Info
+	spec      *Spec
+	overrides map[reflect.Type]*Schema
+	// errorRef 所有接口共用的错误响应 Schema
+	errorRef *Schema
+}
+
+// bearerAuth 登录 Token 鉴权方式的名称
+const bearerAuth = "bearerAuth"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// NewBuilder 创建文档生成器，errorBody 为接口失败时统一返回的响应体结构 (可为 nil)
+func NewBuilder(info Info, errorBody interface{}) *Builder {
+	b := &Builder{
+		info: info,
+		spec: &Spec{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   map[string]map[string]*Operation{},
+			Components: Components{
+				Schemas: map[string]*Schema{},
+				SecuritySchemes: map[string]*SecurityScheme{
+					bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+		overrides: map[reflect.Type]*Schema{},
+	}
+	if errorBody != nil {
+		b.errorRef = b.schemaFor(reflect.TypeOf(errorBody), false)
+	}
+	return b
+}
+
+// Override 为无法通过反射推导的类型 (如自定义 JSON 序列化的类型) 指定 Schema
+func (b *Builder) Override(value interface{}, schema *Schema) {
+	b.overrides[reflect.TypeOf(value)] = schema
+}
+
+var ginParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// Add 登记接口
+func (b *Builder) Add(r Route) {
+	path := ginParamPattern.ReplaceAllString(r.Path, "{$1}")
+	method := strings.ToLower(r.Method)
+	op := &Operation{
+		Summary:     r.Summary,
+		OperationID: operationID(method, r.Path),
+		Responses:   map[string]*Response{},
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Auth {
+		op.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+
+	for _, p := range r.Params {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required || p.In == "path",
+			Schema:      &Schema{Type: typ},
+		})
+	}
+
+	switch {
+	case r.Body != nil:
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: b.schemaFor(reflect.TypeOf(r.Body), true)}},
+		}
+	case len(r.Form) > 0:
+		form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, f := range r.Form {
+			field := &Schema{Type: "string", Description: f.Description}
+			if f.File {
+				field.Format = "binary"
+			}
+			if f.Multiple {
+				field = &Schema{Type: "array", Items: field, Description: f.Description}
+				field.Items.Description = ""
+			}
+			form.Properties[f.Name] = field
+			if f.Required {
+				form.Required = append(form.Required, f.Name)
+			}
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"multipart/form-data": {Schema: form}},
+		}
+	}
+
+	ok := &Response{Description: "成功"}
+	if r.Response != nil {
+		ok.Content = map[string]*MediaType{"application/json": {Schema: b.schemaFor(reflect.TypeOf(r.Response), false)}}
+	}
+	op.Responses["200"] = ok
+	if b.errorRef != nil {
+		op.Responses["default"] = &Response{
+			Description: "失败",
+			Content:     map[string]*MediaType{"application/json": {Schema: b.errorRef}},
+		}
+	}
+
+	if b.spec.Paths[path] == nil {
+		b.spec.Paths[path] = map[string]*Operation{}
+	}
+	b.spec.Paths[path][method] = op
+}
+
+// Spec 返回生成的文档
+func (b *Builder) Spec() *Spec {
+	return b.spec
+}
+
+// operationID 由请求方法与路径生成唯一的 operationId (如 get_api_user_images_id)
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == ':' || r == '-' || r == '.' }) {
+		sb.WriteByte('_')
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
+// schemaFor 返回类型 t 的 Schema，具名结构体注册到 components 并返回引用
+// request 为 true 时按 binding:"required" 推导必填字段，否则没有 omitempty 的字段均视为必定返回
+func (b *Builder) schemaFor(t reflect.Type, request bool) *Schema {
+	if s, ok := b.overrides[t]; ok {
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner := b.schemaFor(t.Elem(), request)
+		if inner.Ref != "" {
+			return &Schema{AllOf: []*Schema{inner}, Nullable: true}
+		}
+		nullable := *inner
+		nullable.Nullable = true
+		return &nullable
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaFor(t.Elem(), request)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaFor(t.Elem(), request)}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return b.structSchema(t, request)
+		}
+		name := componentName(t)
+		if _, ok := b.spec.Components.Schemas[name]; !ok {
+			// 先占位，防止自引用的结构体无限递归
+			b.spec.Components.Schemas[name] = &Schema{}
+			*b.spec.Components.Schemas[name] = *b.structSchema(t, request)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		// interface{} 等任意类型
+		return &Schema{}
+	}
+}
+
+// structSchema 展开结构体字段，匿名嵌入的结构体字段提升到外层
+func (b *Builder) structSchema(t reflect.Type, request bool) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	b.collectFields(s, t, request)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (b *Builder) collectFields(s *Schema, t reflect.Type, request bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.collectFields(s, ft, request)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = b.schemaFor(f.Type, request)
+		omitempty := strings.Contains(","+opts+",", ",omitempty,")
+		required := !omitempty && f.Type.Kind() != reflect.Ptr
+		if request {
+			required = strings.Contains(f.Tag.Get("binding"), "required")
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// componentName 以 "包名.类型名" 作为 components 中的名称，避免不同包的同名类型冲突
+func componentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
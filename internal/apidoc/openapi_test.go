@@ -0,0 +1,116 @@
+package apidoc
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type testItem struct {
+	testBase
+	Name     string            `json:"name" binding:"required"`
+	Note     *string           `json:"note"`
+	Tags     []string          `json:"tags,omitempty"`
+	Extra    map[string]int    `json:"extra,omitempty"`
+	Secret   string            `json:"-"`
+	Children []testItem        `json:"children,omitempty"`
+	Owner    *testBase         `json:"owner,omitempty"`
+	Labels   map[string]string `json:"labels" binding:"omitempty"`
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+	b := NewBuilder(Info{Title: "test", Version: "1"}, nil)
+	b.Add(Route{Method: http.MethodPost, Path: "/items", Body: testItem{}, Response: testItem{}})
+
+	s := b.Spec().Components.Schemas["apidoc.testItem"]
+	if s == nil {
+		t.Fatalf("component not registered: %v", b.Spec().Components.Schemas)
+	}
+	for _, name := range []string{"id", "created_at", "name", "note", "tags", "extra", "children", "owner", "labels"} {
+		if s.Properties[name] == nil {
+			t.Errorf("property %q missing", name)
+		}
+	}
+	if _, ok := s.Properties["Secret"]; ok || len(s.Properties) != 9 {
+		t.Errorf("unexpected properties: %v", s.Properties)
+	}
+	if got := s.Properties["created_at"]; got.Type != "string" || got.Format != "date-time" {
+		t.Errorf("time.Time schema = %+v", got)
+	}
+	if got := s.Properties["id"]; got.Type != "integer" || got.Minimum == nil || *got.Minimum != 0 {
+		t.Errorf("uint schema = %+v", got)
+	}
+	if got := s.Properties["note"]; got.Type != "string" || !got.Nullable {
+		t.Errorf("pointer schema = %+v", got)
+	}
+	if got := s.Properties["owner"]; len(got.AllOf) != 1 || got.AllOf[0].Ref != "#/components/schemas/apidoc.testBase" || !got.Nullable {
+		t.Errorf("pointer to struct schema = %+v", got)
+	}
+	if got := s.Properties["children"]; got.Type != "array" || got.Items.Ref != "#/components/schemas/apidoc.testItem" {
+		t.Errorf("self-referencing slice schema = %+v", got)
+	}
+	if got := s.Properties["extra"]; got.Type != "object" || got.AdditionalProperties.Type != "integer" {
+		t.Errorf("map schema = %+v", got)
+	}
+
+	// 请求体按 binding:"required" 推导必填字段
+	if want := []string{"name"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+}
+
+func TestResponseRequiredFields(t *testing.T) {
+	b := NewBuilder(Info{Title: "test", Version: "1"}, nil)
+	b.Add(Route{Method: http.MethodGet, Path: "/items/:id", Response: testItem{}})
+
+	s := b.Spec().Components.Schemas["apidoc.testItem"]
+	// 响应体中没有 omitempty 的非指针字段均视为必定返回
+	if want := []string{"created_at", "id", "labels", "name"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+}
+
+func TestAddRoute(t *testing.T) {
+	type errorBody struct {
+		Error string `json:"error"`
+	}
+	b := NewBuilder(Info{Title: "test", Version: "1"}, errorBody{})
+	b.Add(Route{
+		Method: http.MethodPost, Path: "/api/items/:id/upload", Tag: "items", Auth: true,
+		Params: []Param{{Name: "id", In: "path", Type: "integer"}, {Name: "format", In: "query"}},
+		Form:   []FormField{{Name: "file", File: true, Multiple: true, Required: true}, {Name: "note"}},
+	})
+
+	op := b.Spec().Paths["/api/items/{id}/upload"]["post"]
+	if op == nil {
+		t.Fatalf("operation not registered: %v", b.Spec().Paths)
+	}
+	if op.OperationID != "post_api_items_id_upload" || len(op.Tags) != 1 || len(op.Security) != 1 {
+		t.Errorf("unexpected operation: %+v", op)
+	}
+	if p := op.Parameters[0]; !p.Required || p.Schema.Type != "integer" {
+		t.Errorf("path parameter = %+v", p)
+	}
+	if p := op.Parameters[1]; p.Required || p.Schema.Type != "string" {
+		t.Errorf("query parameter = %+v", p)
+	}
+	form := op.RequestBody.Content["multipart/form-data"].Schema
+	if file := form.Properties["file"]; file.Type != "array" || file.Items.Format != "binary" {
+		t.Errorf("file field = %+v", file)
+	}
+	if !reflect.DeepEqual(form.Required, []string{"file"}) {
+		t.Errorf("form required = %v", form.Required)
+	}
+	if op.Responses["200"].Content != nil {
+		t.Error("route without response type should have no 200 content")
+	}
+	if op.Responses["default"].Content["application/json"].Schema.Ref != "#/components/schemas/apidoc.errorBody" {
+		t.Errorf("default response = %+v", op.Responses["default"])
+	}
+}
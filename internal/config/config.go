@@ -24,11 +24,14 @@ type Config struct {
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	Upload   UploadConfig   `mapstructure:"upload"`
 	SMTP     SMTPConfig     `mapstructure:"smtp"`
+	Cluster  ClusterConfig  `mapstructure:"cluster"`
 }
 
 type ServerConfig struct {
 	Port string `mapstructure:"port"`
 	Mode string `mapstructure:"mode"`
+	// ShutdownDrainSeconds 收到停止信号后、关闭监听前等待的秒数，期间探针返回 503，供负载均衡摘除本实例
+	ShutdownDrainSeconds int `mapstructure:"shutdown_drain_seconds"`
 }
 
 type DatabaseConfig struct {
@@ -40,11 +43,32 @@ type DatabaseConfig struct {
 	Password string `mapstructure:"password"`
 	Name     string `mapstructure:"name"` // database name
 	SSL      bool   `mapstructure:"ssl"`  // enable TLS/SSL
+	DSN      string `mapstructure:"dsn"`  // full connection string, overrides the fields above
 }
 
 type JWTConfig struct {
 	Secret          string `mapstructure:"secret"`
 	ExpirationHours int    `mapstructure:"expiration_hours"`
+	// Algorithm 签名算法：HS256 (使用 Secret，默认) 或 RS256 (使用 PrivateKeyFile)
+	Algorithm string `mapstructure:"algorithm"`
+	// KeyID 当前签名密钥的标识，写入 Token 头部的 kid；留空时不写入 kid
+	KeyID string `mapstructure:"key_id"`
+	// PrivateKeyFile RS256 签名使用的 RSA 私钥 (PEM) 路径
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	// PreviousKeys 轮换前的旧密钥，仅用于验证其签发且尚未过期的 Token
+	PreviousKeys []JWTKeyConfig `mapstructure:"previous_keys"`
+}
+
+// JWTKeyConfig 仅用于验证的旧 JWT 密钥，Secret (HS256) 与 PublicKeyFile (RS256) 二选一
+type JWTKeyConfig struct {
+	ID            string `mapstructure:"id"` // 与签发时的 kid 一致，旧 Token 没有 kid 时留空
+	Secret        string `mapstructure:"secret"`
+	PublicKeyFile string `mapstructure:"public_key_file"`
+}
+
+// usesSecret 当前签名密钥是否为 HMAC 密钥 (需要检查 Secret 是否安全)
+func (c JWTConfig) usesSecret() bool {
+	return !strings.EqualFold(c.Algorithm, "RS256")
 }
 
 type UploadConfig struct {
@@ -63,6 +87,13 @@ type SMTPConfig struct {
 	SSL      bool   `mapstructure:"ssl"`
 }
 
+// ClusterConfig 多节点部署相关配置
+type ClusterConfig struct {
+	// SettingsSyncInterval 轮询数据库中配置版本号的间隔 (秒)，用于多节点间同步配置缓存失效
+	// 0 表示仅单节点部署，只清理本地缓存
+	SettingsSyncInterval int `mapstructure:"settings_sync_interval"`
+}
+
 // Get 获取当前配置的快照（高性能无锁）
 func Get() Config {
 	val := appConfig.Load()
@@ -92,6 +123,7 @@ func InitConfig() {
 	v.SetDefault("upload.avatar_url_prefix", "/avatars/")
 	v.SetDefault("server.port", "8080")
 	v.SetDefault("server.mode", "debug")
+	v.SetDefault("server.shutdown_drain_seconds", 5)
 	v.SetDefault("database.type", "sqlite")
 	v.SetDefault("database.filename", "config/perfect_pic.db")
 	v.SetDefault("database.host", "127.0.0.1")
@@ -100,14 +132,19 @@ func InitConfig() {
 	v.SetDefault("database.password", "root")
 	v.SetDefault("database.name", "perfect_pic")
 	v.SetDefault("database.ssl", false)
+	v.SetDefault("database.dsn", "")
 	v.SetDefault("jwt.secret", "")
 	v.SetDefault("jwt.expiration_hours", 24)
+	v.SetDefault("jwt.algorithm", "HS256")
+	v.SetDefault("jwt.key_id", "")
+	v.SetDefault("jwt.private_key_file", "")
 	v.SetDefault("smtp.host", "")
 	v.SetDefault("smtp.port", 587)
 	v.SetDefault("smtp.username", "")
 	v.SetDefault("smtp.password", "")
 	v.SetDefault("smtp.from", "")
 	v.SetDefault("smtp.ssl", false)
+	v.SetDefault("cluster.settings_sync_interval", 0)
 
 	// 读取配置文件
 	if err := v.ReadInConfig(); err != nil {
@@ -135,7 +172,7 @@ func InitConfig() {
 
 	// 首次启动安全检查：如果是 release 模式，拦截不安全的 JWT Secret
 	curr := Get()
-	if curr.Server.Mode == "release" {
+	if curr.Server.Mode == "release" && curr.JWT.usesSecret() {
 		if curr.JWT.Secret == "" || curr.JWT.Secret == "perfect_pic_secret" {
 			log.Fatal("❌ [安全严重错误] 生产模式(release)下必须设置安全的 JWT Secret！\n请设置环境变量 PERFECT_PIC_JWT_SECRET 或在配置文件中指定 jwt.secret")
 		}
@@ -165,7 +202,7 @@ func loadAndStore(v *viper.Viper) {
 
 	// 安全检查
 	if tempConfig.Server.Mode == "release" {
-		if tempConfig.JWT.Secret == "" || tempConfig.JWT.Secret == "perfect_pic_secret" {
+		if tempConfig.JWT.usesSecret() && (tempConfig.JWT.Secret == "" || tempConfig.JWT.Secret == "perfect_pic_secret") {
 			log.Println("❌ [安全严重错误] 生产模式(release)下必须设置安全的 JWT Secret！")
 		}
 	} else {
@@ -17,30 +17,82 @@ const (
 	// ConfigBaseURL 网站基础URL (例如 http://localhost:8080)
 	ConfigBaseURL = "base_url"
 
+	// ConfigPublicBaseURL 图片访问地址的公开域名 (例如 https://cdn.example.com)，留空时返回相对地址
+	ConfigPublicBaseURL = "public_base_url"
+
 	// ConfigAllowInit 是否允许初始化管理员账号 (true/false)
 	ConfigAllowInit = "allow_init"
 
 	// ConfigAllowRegister 是否开放注册 (true/false)
 	ConfigAllowRegister = "allow_register"
 
+	// ConfigRegistrationMode 注册模式 (open: 开放注册, closed: 关闭注册, invite: 仅邀请码注册)
+	ConfigRegistrationMode = "registration_mode"
+
 	// ConfigEnableSMTP 是否启用SMTP发送邮件 (true/false)
 	ConfigEnableSMTP = "enable_smtp"
 
 	// ConfigBlockUnverifiedUsers 是否阻止未验证邮箱用户登录 (true/false)
 	ConfigBlockUnverifiedUsers = "block_unverified_users"
 
+	// ConfigAllowEmailLogin 是否允许使用邮箱代替用户名登录 (true/false)
+	ConfigAllowEmailLogin = "allow_email_login"
+
+	// ConfigNotifyNewDevice 从未登录过的设备登录时是否向用户发送邮件提醒 (true/false)
+	ConfigNotifyNewDevice = "notify_new_device"
+
+	// ConfigAccountDeletionGraceDays 用户申请注销账号后保留的天数，期满后删除账号及其文件，期间可撤销
+	ConfigAccountDeletionGraceDays = "account_deletion_grace_days"
+
 	// ConfigRequireEmailVerification 注册是否强制要求验证邮箱 (true/false)
 	ConfigRequireEmailVerification = "require_email_verification"
 
+	// ConfigUnverifiedRetentionDays 未验证邮箱的账号保留天数，超过后自动删除 (0 表示不清理)
+	ConfigUnverifiedRetentionDays = "unverified_retention_days"
+
 	// ConfigMaxUploadSize 图片最大上传限制 (MB)
 	ConfigMaxUploadSize = "max_upload_size"
 
+	// ConfigMaxUploadSizeBytes 单个文件最大上传限制 (Bytes，大于 0 时优先于 ConfigMaxUploadSize)
+	ConfigMaxUploadSizeBytes = "max_upload_size_bytes"
+
 	// ConfigAllowFileExtensions 允许上传的文件扩展名 (逗号分隔)
 	ConfigAllowFileExtensions = "allow_file_extensions"
 
+	// ConfigAllowedImageTypes 允许上传的图片类型 (逗号分隔，如 png,webp,jpg；留空沿用 ConfigAllowFileExtensions)
+	ConfigAllowedImageTypes = "allowed_image_types"
+
+	// ConfigMaxPixels 单张图片允许的最大像素面积 (宽*高，GIF 按总帧面积计算，0 表示不限制)
+	ConfigMaxPixels = "max_pixels"
+
+	// ConfigMaxGifFrames GIF 图片允许的最大帧数 (0 表示不限制)
+	ConfigMaxGifFrames = "max_gif_frames"
+
+	// ConfigMaxImageWidth / ConfigMaxImageHeight 图片允许的最大宽度与高度 (像素，0 表示不限制)
+	ConfigMaxImageWidth  = "max_image_width"
+	ConfigMaxImageHeight = "max_image_height"
+
+	// ConfigOversizePolicy 宽高超出限制时的处理策略 (reject: 拒绝上传, downscale: 等比缩小后保存)
+	ConfigOversizePolicy = "oversize_policy"
+
+	// ConfigExtensionMismatchPolicy 文件内容与扩展名不一致时的处理策略
+	// (reject: 拒绝上传, trust-content: 按真实类型保存, trust-extension: 保留声明的扩展名)
+	ConfigExtensionMismatchPolicy = "extension_mismatch_policy"
+
+	// ConfigAllowSVG 是否允许上传 SVG (true/false)，SVG 会在保存前清理脚本与外部引用
+	ConfigAllowSVG = "allow_svg"
+
+	// ConfigIntegrityScanSampleRate 图片完整性巡检每分钟最多校验的图片数量 (0 表示关闭巡检)
+	ConfigIntegrityScanSampleRate = "integrity_scan_sample_rate"
+
 	// ConfigDefaultStorageQuota 默认存储配额 (字节)
 	ConfigDefaultStorageQuota = "default_storage_quota"
 
+	// ConfigDailyUploadCountLimit 每个用户每天最多上传的图片数量 (0 表示不限制，管理员不受限)
+	ConfigDailyUploadCountLimit = "daily_upload_count_limit"
+	// ConfigDailyUploadBytesLimit 每个用户每天最多上传的字节数 (0 表示不限制，管理员不受限)
+	ConfigDailyUploadBytesLimit = "daily_upload_bytes_limit"
+
 	// ConfigRateLimitEnabled 是否开启限流
 	ConfigRateLimitEnabled = "rate_limit_enabled"
 
@@ -67,4 +119,225 @@ const (
 
 	// ConfigTrustedProxies 可信代理列表 (逗号分隔，留空表示不信任代理头)
 	ConfigTrustedProxies = "trusted_proxies"
+
+	// ConfigAdminIPAllowlist 允许访问管理后台的 IP 或 CIDR (逗号分隔，留空表示不限制)
+	ConfigAdminIPAllowlist = "admin_ip_allowlist"
+
+	// ConfigCaptchaBypassIPs 免验证码的 IP 或 CIDR (逗号分隔，留空表示不放行，供内部测试工具使用；访客上传始终需要验证码)
+	ConfigCaptchaBypassIPs = "captcha_bypass_ips"
+
+	// ConfigCaptchaRequiredActions 需要验证码的操作 (逗号分隔，可选 login、register、password_reset；留空表示均不需要)
+	ConfigCaptchaRequiredActions = "captcha_required_actions"
+
+	// ConfigCaptchaProvider 验证码类型 (image: 内置图片验证码, cap: 自托管 Cap 验证码)
+	ConfigCaptchaProvider = "captcha_provider"
+
+	// ConfigCaptchaCapInstanceURL 自托管 Cap 服务地址 (如 https://cap.example.com)
+	ConfigCaptchaCapInstanceURL = "captcha_cap_instance_url"
+
+	// ConfigCaptchaCapSiteKey Cap 站点 Key (公开，前端组件使用)
+	ConfigCaptchaCapSiteKey = "captcha_cap_site_key"
+
+	// ConfigCaptchaCapSecret Cap 站点密钥 (仅服务端校验时使用)
+	ConfigCaptchaCapSecret = "captcha_cap_secret"
+
+	// ConfigCaptchaCapVerifyURL Cap 服务端校验地址 (留空使用 {实例地址}/{站点 Key}/siteverify)
+	ConfigCaptchaCapVerifyURL = "captcha_cap_verify_url"
+
+	// ConfigCORSAllowedOrigins 允许跨域访问 API 的来源 (逗号分隔，* 表示任意来源，留空表示不允许跨域)
+	ConfigCORSAllowedOrigins = "cors_allowed_origins"
+
+	// ConfigLogLevel 日志级别 (debug / info / warn / error)
+	ConfigLogLevel = "log_level"
+
+	// ConfigLogFormat 日志格式 (text / json)
+	ConfigLogFormat = "log_format"
+
+	// ConfigEmailWorkerCount 邮件发送队列工作协程数量 (修改后需重启服务生效)
+	ConfigEmailWorkerCount = "email_worker_count"
+
+	// ConfigEmailMaxAttempts 邮件发送失败时的最大尝试次数
+	ConfigEmailMaxAttempts = "email_max_attempts"
+
+	// ConfigDefaultLocale 默认语言 (用于邮件等，zh-CN / en)
+	ConfigDefaultLocale = "default_locale"
+
+	// ConfigAvatarMaxSize 头像最大边长 (像素)，超出时自动缩放
+	ConfigAvatarMaxSize = "avatar_max_size"
+
+	// ConfigAutoOrient 上传 JPEG 时是否按 EXIF 方向自动旋转图片 (保留其余 EXIF 信息)
+	ConfigAutoOrient = "auto_orient"
+
+	// ConfigOptimizeOnUpload 上传时是否压缩图片 (JPEG 按 ConfigJpegQuality 重新编码，PNG 无损重新压缩)，结果更大时保留原图
+	ConfigOptimizeOnUpload = "optimize_on_upload"
+
+	// ConfigGenerateAltFormats 上传位图时是否额外生成 WebP 备选文件 (true/false)，按请求的 Accept 头返回
+	ConfigGenerateAltFormats = "generate_alt_formats"
+
+	// ConfigUploadResponseStyle 上传成功的响应格式 (default / sharex / picgo)，请求可通过 format 参数覆盖
+	ConfigUploadResponseStyle = "upload_response_style"
+
+	// ConfigUploadDeleteToken 上传时是否生成一次性删除 Token，并在响应中附带免登录的删除链接 (true/false)
+	ConfigUploadDeleteToken = "upload_delete_token"
+
+	// ConfigAllowAnonymousUpload 是否允许未登录的访客上传图片 (true/false)，访客上传必须通过验证码
+	ConfigAllowAnonymousUpload = "allow_anonymous_upload"
+	// ConfigAnonymousImageTTLDays 访客上传的图片保留天数，上传时据此设置过期时间，到期后由后台任务删除 (0 表示永久保留，修改后只影响之后上传的图片)
+	ConfigAnonymousImageTTLDays = "anonymous_image_ttl_days"
+	// ConfigAnonymousUploadCountPerIP 同一 IP (IPv6 按 /64 网段) 最多保留的访客图片数量 (0 表示不限制)
+	ConfigAnonymousUploadCountPerIP = "anonymous_upload_count_per_ip"
+	// ConfigAnonymousUploadBytesPerIP 同一 IP (IPv6 按 /64 网段) 上传的访客图片最多占用的字节数 (0 表示不限制)
+	ConfigAnonymousUploadBytesPerIP = "anonymous_upload_bytes_per_ip"
+
+	// ConfigExpiryCheckIntervalMinutes 清理已过期图片的检查间隔 (分钟)
+	ConfigExpiryCheckIntervalMinutes = "expiry_check_interval_minutes"
+
+	// ConfigMaxConcurrentImageOps 同时进行的图片解码/缩放/编码任务数上限 (0 表示不限制)
+	ConfigMaxConcurrentImageOps = "max_concurrent_image_ops"
+	// ConfigImageOpQueueTimeoutSeconds 图片处理排队等待的最长时间 (秒)，超时返回服务器繁忙
+	ConfigImageOpQueueTimeoutSeconds = "image_op_queue_timeout_seconds"
+
+	// ConfigJpegQuality 上传压缩时 JPEG 重新编码的质量 (1-100)
+	ConfigJpegQuality = "jpeg_quality"
+
+	// ConfigMaxBatchUploadFiles 批量上传单次请求最多包含的文件数量
+	ConfigMaxBatchUploadFiles = "max_batch_upload_files"
+
+	// ConfigAvatarCropSquare 是否将头像居中裁剪为正方形
+	ConfigAvatarCropSquare = "avatar_crop_square"
+
+	// ConfigLoginMaxAttempts 同一 IP 登录同一账号的连续失败次数上限 (达到后临时锁定该 IP 对该账号的登录，0 表示不锁定)
+	ConfigLoginMaxAttempts = "login_max_attempts"
+
+	// ConfigLoginLockoutMinutes 登录失败锁定时长 (分钟)
+	ConfigLoginLockoutMinutes = "login_lockout_minutes"
+
+	// ConfigAccountLockThreshold 累计连续登录失败多少次后锁定账号 (由管理员或邮件链接解锁，0 表示不锁定)
+	ConfigAccountLockThreshold = "account_lock_threshold"
+
+	// ConfigLoginRevealAttempts 登录失败时是否返回剩余尝试次数与锁定截止时间
+	ConfigLoginRevealAttempts = "login_reveal_attempts"
+
+	// ConfigPasswordMinLength 密码最小长度
+	ConfigPasswordMinLength = "password_min_length"
+
+	// ConfigPasswordRequireDigit 密码是否必须包含数字 (true/false)
+	ConfigPasswordRequireDigit = "password_require_digit"
+
+	// ConfigPasswordRequireUpper 密码是否必须包含大写字母 (true/false)
+	ConfigPasswordRequireUpper = "password_require_upper"
+
+	// ConfigPasswordRequireLower 密码是否必须包含小写字母 (true/false)
+	ConfigPasswordRequireLower = "password_require_lower"
+
+	// ConfigPasswordRequireSymbol 密码是否必须包含符号 (true/false)
+	ConfigPasswordRequireSymbol = "password_require_symbol"
+
+	// ConfigPasswordDenyCommon 是否禁止使用内置的常见弱密码 (true/false)
+	ConfigPasswordDenyCommon = "password_deny_common"
+
+	// ConfigPasswordDenylist 自定义禁用密码列表 (逗号分隔，忽略大小写)
+	ConfigPasswordDenylist = "password_denylist"
+
+	// ConfigBcryptCost 密码哈希的 bcrypt 计算成本 (4-31)，登录时会将低于该成本的旧哈希自动升级
+	ConfigBcryptCost = "bcrypt_cost"
+
+	// ConfigPasswordHashScheme 新密码使用的哈希方案 (bcrypt/argon2id)，已有哈希按其自身方案校验
+	ConfigPasswordHashScheme = "password_hash_scheme"
+
+	// ConfigPasswordMigrateOnLogin 登录成功时是否将其他方案的旧哈希迁移为当前方案 (true/false)
+	ConfigPasswordMigrateOnLogin = "password_migrate_on_login"
+
+	// ConfigArgon2Memory Argon2id 内存开销 (KiB)
+	ConfigArgon2Memory = "argon2_memory"
+
+	// ConfigArgon2Iterations Argon2id 迭代次数
+	ConfigArgon2Iterations = "argon2_iterations"
+
+	// ConfigArgon2Parallelism Argon2id 并行度
+	ConfigArgon2Parallelism = "argon2_parallelism"
+
+	// ConfigReservedUsernames 保留用户名列表 (逗号分隔，忽略大小写)，不允许注册或修改为这些用户名
+	ConfigReservedUsernames = "reserved_usernames"
+
+	// ConfigVerificationResendCooldown 重发验证邮件的冷却时间 (秒)
+	ConfigVerificationResendCooldown = "verification_resend_cooldown"
+
+	// ConfigPasswordResetCooldown 同一邮箱重复请求重置密码邮件的冷却时间 (秒)
+	ConfigPasswordResetCooldown = "password_reset_cooldown"
+
+	// ConfigPasswordResetTTLMinutes 重置密码链接的有效期 (分钟)
+	ConfigPasswordResetTTLMinutes = "password_reset_ttl_minutes"
+
+	// ConfigEmailVerifyTTLHours 邮箱验证与修改邮箱确认链接的有效期 (小时，可为小数)
+	ConfigEmailVerifyTTLHours = "email_verify_ttl_hours"
+
+	// ConfigUserTokenHours 普通用户登录 Token 有效期 (小时，0 表示使用配置文件中的 jwt.expiration_hours)
+	ConfigUserTokenHours = "user_token_hours"
+
+	// ConfigAdminTokenHours 管理员登录 Token 有效期 (小时，0 表示使用配置文件中的 jwt.expiration_hours)
+	ConfigAdminTokenHours = "admin_token_hours"
+
+	// ConfigRememberMeHours 登录时勾选记住我的 Token 有效期 (小时，0 表示不支持记住我)
+	ConfigRememberMeHours = "remember_me_hours"
+
+	// ConfigStorageBackend 图片存储后端 (local: 本地磁盘, webdav: WebDAV, oss: 阿里云 OSS)
+	ConfigStorageBackend = "storage_backend"
+
+	// ConfigWebDAVURL WebDAV 存储根目录地址 (如 https://nas.example.com/dav/images/)
+	ConfigWebDAVURL = "webdav_url"
+
+	// ConfigWebDAVUsername WebDAV 用户名
+	ConfigWebDAVUsername = "webdav_username"
+
+	// ConfigWebDAVPassword WebDAV 密码
+	ConfigWebDAVPassword = "webdav_password"
+
+	// ConfigOSSEndpoint 阿里云 OSS Endpoint (如 oss-cn-hangzhou.aliyuncs.com)
+	ConfigOSSEndpoint = "oss_endpoint"
+
+	// ConfigOSSBucket 阿里云 OSS Bucket 名称
+	ConfigOSSBucket = "oss_bucket"
+
+	// ConfigOSSAccessKeyID 阿里云 OSS AccessKey ID
+	ConfigOSSAccessKeyID = "oss_access_key_id"
+
+	// ConfigOSSAccessKeySecret 阿里云 OSS AccessKey Secret
+	ConfigOSSAccessKeySecret = "oss_access_key_secret"
+
+	// ConfigOSSCustomDomain OSS 自定义域名或 CDN 地址 (用于生成图片公开链接，留空使用 Bucket 默认域名)
+	ConfigOSSCustomDomain = "oss_custom_domain"
+
+	// ConfigPresignTTLSeconds 对象存储限时访问地址的有效期 (秒，0 表示不使用限时地址，由本服务转发图片)
+	ConfigPresignTTLSeconds = "presign_ttl_seconds"
+
+	// ConfigStorageLayout 新上传图片的存储路径布局 (date: 按日期分目录, hash: 按内容哈希前缀分片)
+	ConfigStorageLayout = "storage_layout"
+
+	// ConfigTrackImageViews 是否统计图片访问次数与最近访问时间
+	ConfigTrackImageViews = "track_image_views"
+
+	// ConfigImageViewsExcludeBots 统计访问次数时是否忽略 HEAD 请求与爬虫
+	ConfigImageViewsExcludeBots = "image_views_exclude_bots"
+
+	// ConfigHotlinkProtection 是否开启图片防盗链 (按 Referer 来源过滤)
+	ConfigHotlinkProtection = "hotlink_protection"
+
+	// ConfigHotlinkAllowedReferers 防盗链允许的来源域名 (逗号分隔，支持 *.example.com，本站域名始终允许)
+	ConfigHotlinkAllowedReferers = "hotlink_allowed_referers"
+
+	// ConfigHotlinkAllowEmptyReferer 防盗链是否允许无 Referer 的请求 (直接访问)
+	ConfigHotlinkAllowEmptyReferer = "hotlink_allow_empty_referer"
+
+	// ConfigHotlinkRedirectURL 被防盗链拦截时重定向到的占位图地址 (留空返回 403)
+	ConfigHotlinkRedirectURL = "hotlink_redirect_url"
+
+	// ConfigImageCacheMaxAge 图片响应的 Cache-Control max-age (秒，0 表示 no-cache，每次使用前重新验证)
+	ConfigImageCacheMaxAge = "image_cache_max_age"
+
+	// ConfigInternalRedirectMode 本地存储的图片交由前端服务器发送 (off: 由本服务发送, nginx: X-Accel-Redirect, apache: X-Sendfile)
+	ConfigInternalRedirectMode = "internal_redirect_mode"
+
+	// ConfigInternalRedirectPrefix nginx 模式下 X-Accel-Redirect 使用的 internal location 前缀
+	ConfigInternalRedirectPrefix = "internal_redirect_prefix"
 )
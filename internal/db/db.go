@@ -6,7 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"perfect-pic-server/internal/config"
-	"perfect-pic-server/internal/model"
 	"time"
 
 	"github.com/glebarez/sqlite"
@@ -20,47 +19,10 @@ var DB *gorm.DB
 func InitDB() {
 	var err error
 	cfg := config.Get()
-	var dialector gorm.Dialector
 
-	switch cfg.Database.Type {
-	case "mysql":
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.Database.User,
-			cfg.Database.Password,
-			cfg.Database.Host,
-			cfg.Database.Port,
-			cfg.Database.Name,
-		)
-		if cfg.Database.SSL {
-			dsn += "&tls=true"
-		}
-		dialector = mysql.Open(dsn)
-	case "postgres":
-		sslMode := "disable"
-		if cfg.Database.SSL {
-			sslMode = "require"
-		}
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Shanghai",
-			cfg.Database.Host,
-			cfg.Database.User,
-			cfg.Database.Password,
-			cfg.Database.Name,
-			cfg.Database.Port,
-			sslMode,
-		)
-		dialector = postgres.Open(dsn)
-	case "sqlite":
-		fallthrough
-	default:
-		// 自动创建数据库目录
-		dbDir := filepath.Dir(cfg.Database.Filename)
-		if err := os.MkdirAll(dbDir, 0755); err != nil {
-			log.Fatalf("❌ 无法创建数据库目录 '%s': %v", dbDir, err)
-		}
-
-		// 启用 WAL 模式和繁忙等待，提升 SQLite 并发性能
-		dsn := cfg.Database.Filename + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"
-		dialector = sqlite.Open(dsn)
+	dialector, err := openDialector(cfg.Database)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
 	}
 
 	DB, err = gorm.Open(dialector, &gorm.Config{})
@@ -75,7 +37,7 @@ func InitDB() {
 	}
 
 	// 配置连接池
-	if cfg.Database.Type == "sqlite" {
+	if DB.Dialector.Name() == "sqlite" {
 		// SQLite 建议单连接写
 		sqlDB.SetMaxOpenConns(1)
 		sqlDB.SetMaxIdleConns(1)
@@ -86,15 +48,62 @@ func InitDB() {
 	}
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	err = DB.AutoMigrate(
-		&model.User{},
-		&model.Setting{},
-		&model.Image{},
-	)
-
-	if err != nil {
+	if err := Migrate(DB); err != nil {
 		log.Fatal("❌ 数据库迁移失败: ", err)
 	}
 
-	log.Printf("✅ 数据库(%s)连接成功，表结构已同步", cfg.Database.Type)
+	log.Printf("✅ 数据库(%s)连接成功，表结构已同步", DB.Dialector.Name())
+}
+
+// openDialector 按配置的数据库类型选择 GORM 驱动
+// 设置了 DSN 时直接使用，否则由各连接参数拼接
+func openDialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Type {
+	case "mysql":
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+				cfg.User,
+				cfg.Password,
+				cfg.Host,
+				cfg.Port,
+				cfg.Name,
+			)
+			if cfg.SSL {
+				dsn += "&tls=true"
+			}
+		}
+		return mysql.Open(dsn), nil
+	case "postgres":
+		dsn := cfg.DSN
+		if dsn == "" {
+			sslMode := "disable"
+			if cfg.SSL {
+				sslMode = "require"
+			}
+			dsn = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Shanghai",
+				cfg.Host,
+				cfg.User,
+				cfg.Password,
+				cfg.Name,
+				cfg.Port,
+				sslMode,
+			)
+		}
+		return postgres.Open(dsn), nil
+	case "sqlite", "":
+		if cfg.DSN != "" {
+			return sqlite.Open(cfg.DSN), nil
+		}
+		// 自动创建数据库目录
+		dbDir := filepath.Dir(cfg.Filename)
+		if err := os.MkdirAll(dbDir, 0755); err != nil {
+			return nil, fmt.Errorf("无法创建数据库目录 '%s': %w", dbDir, err)
+		}
+
+		// 启用 WAL 模式和繁忙等待，提升 SQLite 并发性能
+		return sqlite.Open(cfg.Filename + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型 %q (可选 sqlite, mysql, postgres)", cfg.Type)
+	}
 }
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"path/filepath"
+	"perfect-pic-server/internal/config"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+)
+
+func TestOpenDialector(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data", "test.db")
+
+	tests := []struct {
+		cfg     config.DatabaseConfig
+		name    string
+		wantDSN string
+	}{
+		{cfg: config.DatabaseConfig{Filename: filename}, name: "sqlite", wantDSN: filename + "?"},
+		{cfg: config.DatabaseConfig{Type: "sqlite", DSN: "file::memory:"}, name: "sqlite", wantDSN: "file::memory:"},
+		{cfg: config.DatabaseConfig{Type: "mysql", User: "u", Password: "p", Host: "db", Port: "3306", Name: "pic", SSL: true}, name: "mysql", wantDSN: "u:p@tcp(db:3306)/pic?charset=utf8mb4&parseTime=True&loc=Local&tls=true"},
+		{cfg: config.DatabaseConfig{Type: "mysql", DSN: "u:p@tcp(other:3306)/x", Host: "db"}, name: "mysql", wantDSN: "u:p@tcp(other:3306)/x"},
+		{cfg: config.DatabaseConfig{Type: "postgres", User: "u", Password: "p", Host: "db", Port: "5432", Name: "pic"}, name: "postgres", wantDSN: "host=db user=u password=p dbname=pic port=5432 sslmode=disable"},
+		{cfg: config.DatabaseConfig{Type: "postgres", DSN: "postgres://u:p@other/x", Host: "db"}, name: "postgres", wantDSN: "postgres://u:p@other/x"},
+	}
+	for _, tt := range tests {
+		d, err := openDialector(tt.cfg)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.cfg, err)
+		}
+		if d.Name() != tt.name {
+			t.Errorf("%+v: dialector = %s, want %s", tt.cfg, d.Name(), tt.name)
+		}
+		var dsn string
+		switch v := d.(type) {
+		case *sqlite.Dialector:
+			dsn = v.DSN
+		case *mysql.Dialector:
+			dsn = v.DSN
+		case *postgres.Dialector:
+			dsn = v.DSN
+		}
+		if !strings.HasPrefix(dsn, tt.wantDSN) {
+			t.Errorf("%+v: DSN = %q, want prefix %q", tt.cfg, dsn, tt.wantDSN)
+		}
+	}
+
+	if _, err := openDialector(config.DatabaseConfig{Type: "oracle"}); err == nil {
+		t.Fatal("unknown database type should be rejected")
+	}
+}
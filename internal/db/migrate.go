@@ -0,0 +1,139 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"perfect-pic-server/internal/model"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration 一次带版本号的数据迁移 (回填数据、调整索引等 AutoMigrate 无法完成的变更)
+// 已发布的迁移不可修改或删除，新的变更追加到 migrations 末尾并使用更大的版本号
+type migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// schemaMigration 记录已执行的迁移
+type schemaMigration struct {
+	Version   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:128;not null"`
+	AppliedAt int64  `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// models 由 AutoMigrate 同步表结构的模型
+var models = []any{
+	&model.User{},
+	&model.Setting{},
+	&model.Image{},
+	&model.ImageIntegrityIssue{},
+	&model.SettingsVersion{},
+	&model.SettingsChange{},
+	&model.Session{},
+	&model.KnownDevice{},
+	&model.DailyUploadUsage{},
+	&model.InviteCode{},
+	&model.AuditLog{},
+	&model.Album{},
+	&model.AlbumImage{},
+	&model.Tag{},
+	&model.ImageTag{},
+}
+
+// migrations 按版本号升序执行，每个迁移只执行一次
+// 迁移只能使用 GORM 的链式 API 或三种数据库 (SQLite/MySQL/PostgreSQL) 通用的 SQL
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "backfill_user_display_name",
+		// 为新增 DisplayName 字段之前创建的用户回填展示名
+		Up: func(tx *gorm.DB) error {
+			return tx.Model(&model.User{}).Where("display_name = ? OR display_name IS NULL", "").
+				UpdateColumn("display_name", gorm.Expr("username")).Error
+		},
+	},
+}
+
+// Migrate 同步表结构并执行尚未执行的版本迁移
+// 先由 AutoMigrate 创建或补齐表与列 (只增不删)，再在各自的事务中依次执行 migrations，
+// 执行结果记录在 schema_migrations 表中。多个实例同时启动时，同一迁移只会由其中一个实例执行
+func Migrate(d *gorm.DB) error {
+	if err := d.AutoMigrate(models...); err != nil {
+		return err
+	}
+	if err := d.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	pending, err := pendingMigrations(d)
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		if err := runMigration(d, m); err != nil {
+			return fmt.Errorf("迁移 %d (%s) 执行失败: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// pendingMigrations 返回尚未执行的迁移，并检查迁移列表的版本号是否有效
+func pendingMigrations(d *gorm.DB) ([]migration, error) {
+	var applied []schemaMigration
+	if err := d.Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[uint]bool, len(applied))
+	var latestApplied uint
+	for _, a := range applied {
+		done[a.Version] = true
+		latestApplied = max(latestApplied, a.Version)
+	}
+
+	sorted := make([]migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	var pending []migration
+	for i, m := range sorted {
+		if m.Version == 0 || (i > 0 && sorted[i-1].Version == m.Version) {
+			return nil, fmt.Errorf("迁移版本号无效或重复: %d", m.Version)
+		}
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	if n := len(sorted); n > 0 && latestApplied > sorted[n-1].Version {
+		log.Printf("⚠️ 数据库已执行版本 %d 的迁移，高于当前程序支持的版本 %d，可能正在运行旧版本程序", latestApplied, sorted[n-1].Version)
+	}
+	return pending, nil
+}
+
+// runMigration 在事务中执行单个迁移并记录版本
+// 先写入版本记录，并发执行同一迁移的其他实例会因主键冲突而回滚，之后视为已由其他实例完成
+func runMigration(d *gorm.DB, m migration) error {
+	err := d.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().Unix()}).Error; err != nil {
+			return err
+		}
+		return m.Up(tx)
+	})
+	if err == nil {
+		log.Printf("✅ 已执行数据库迁移 %d (%s)", m.Version, m.Name)
+		return nil
+	}
+
+	var count int64
+	if d.Model(&schemaMigration{}).Where("version = ?", m.Version).Count(&count).Error == nil && count > 0 {
+		return nil
+	}
+	return err
+}
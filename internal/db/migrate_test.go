@@ -0,0 +1,149 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB 为当前测试打开独立的内存 SQLite 数据库 (未迁移)
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	d, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := d.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return d
+}
+
+// useMigrations 在当前测试中替换迁移列表
+func useMigrations(t *testing.T, list []migration) {
+	t.Helper()
+	prev := migrations
+	migrations = list
+	t.Cleanup(func() { migrations = prev })
+}
+
+func appliedVersions(t *testing.T, d *gorm.DB) []uint {
+	t.Helper()
+	var versions []uint
+	if err := d.Model(&schemaMigration{}).Order("version").Pluck("version", &versions).Error; err != nil {
+		t.Fatalf("list applied migrations: %v", err)
+	}
+	return versions
+}
+
+func TestMigrateRunsEachMigrationOnce(t *testing.T) {
+	d := openTestDB(t)
+	calls := 0
+	useMigrations(t, []migration{
+		migrations[0],
+		{Version: 2, Name: "count_calls", Up: func(tx *gorm.DB) error { calls++; return nil }},
+	})
+
+	if err := d.AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	if err := d.Create(&model.User{Username: "alice", Password: "x", Email: "alice@example.com"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(d); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("migration 2 ran %d times, want 1", calls)
+	}
+	if got := appliedVersions(t, d); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("applied versions = %v, want [1 2]", got)
+	}
+
+	var user model.User
+	d.Where("username = ?", "alice").First(&user)
+	if user.DisplayName != "alice" {
+		t.Fatalf("display_name = %q, want backfilled username", user.DisplayName)
+	}
+}
+
+func TestMigrateRollsBackFailedMigration(t *testing.T) {
+	d := openTestDB(t)
+	useMigrations(t, []migration{
+		{Version: 1, Name: "fails", Up: func(tx *gorm.DB) error {
+			if err := tx.Create(&model.Setting{Key: "partial", Value: "1"}).Error; err != nil {
+				return err
+			}
+			return errors.New("boom")
+		}},
+	})
+
+	err := Migrate(d)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Migrate() error = %v, want the migration failure", err)
+	}
+	if got := appliedVersions(t, d); len(got) != 0 {
+		t.Fatalf("applied versions = %v, want none", got)
+	}
+	var count int64
+	d.Model(&model.Setting{}).Where("key = ?", "partial").Count(&count)
+	if count != 0 {
+		t.Fatal("changes of a failed migration should be rolled back")
+	}
+}
+
+func TestMigrateSkipsMigrationAppliedByAnotherInstance(t *testing.T) {
+	d := openTestDB(t)
+	if err := d.AutoMigrate(&schemaMigration{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	// 模拟其他实例已写入版本记录
+	if err := d.Create(&schemaMigration{Version: 1, Name: "other", AppliedAt: 1}).Error; err != nil {
+		t.Fatalf("create version row: %v", err)
+	}
+	m := migration{Version: 1, Name: "other", Up: func(tx *gorm.DB) error {
+		t.Fatal("migration should not run again")
+		return nil
+	}}
+	if err := runMigration(d, m); err != nil {
+		t.Fatalf("runMigration() = %v, want nil when the version is already recorded", err)
+	}
+}
+
+func TestMigrateRejectsInvalidVersions(t *testing.T) {
+	noop := func(tx *gorm.DB) error { return nil }
+	tests := [][]migration{
+		{{Version: 0, Name: "zero", Up: noop}},
+		{{Version: 1, Name: "a", Up: noop}, {Version: 1, Name: "b", Up: noop}},
+	}
+	for i, list := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			d := openTestDB(t)
+			useMigrations(t, list)
+			if err := Migrate(d); err == nil {
+				t.Error("Migrate() should reject invalid versions")
+			}
+		})
+	}
+}
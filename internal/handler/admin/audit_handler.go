@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLogList 获取审计日志列表，支持按操作者 (actor_id)、操作类型 (action) 与时间范围 (since, until) 过滤
+func GetAuditLogList(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	} else if pageSize > 100 {
+		pageSize = 100
+	}
+	actorID, _ := strconv.ParseUint(c.Query("actor_id"), 10, 64)
+	since, _ := strconv.ParseInt(c.Query("since"), 10, 64)
+	until, _ := strconv.ParseInt(c.Query("until"), 10, 64)
+
+	opts := service.AuditLogListOptions{
+		Page:     page,
+		PageSize: pageSize,
+		ActorID:  uint(actorID),
+		Action:   c.Query("action"),
+		Since:    since,
+		Until:    until,
+	}
+	logs, total, err := service.ListAuditLogs(opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取审计日志失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      logs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
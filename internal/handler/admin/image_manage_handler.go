@@ -38,7 +38,7 @@ func GetImageList(c *gin.Context) {
 
 	if username != "" {
 		// 联表查询
-		query = query.Joins("JOIN users ON users.id = images.user_id").Where("users.username LIKE ?", "%"+username+"%")
+		query = query.Joins("JOIN users ON users.id = images.user_id").Where("LOWER(users.username) LIKE ?", "%"+service.UsernameKey(username)+"%")
 	}
 
 	if id != "" {
@@ -82,7 +82,7 @@ func DeleteImage(c *gin.Context) {
 		return
 	}
 
-	if err := service.DeleteImage(&image); err != nil {
+	if err := service.DeleteImage(c.Request.Context(), &image); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
@@ -122,7 +122,7 @@ func BatchDeleteImages(c *gin.Context) {
 		return
 	}
 
-	if err := service.BatchDeleteImages(images); err != nil {
+	if err := service.BatchDeleteImages(c.Request.Context(), images); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetIntegrityIssues 获取图片完整性巡检发现的问题列表
+func GetIntegrityIssues(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	pageSizeStr := c.DefaultQuery("page_size", "10")
+	showResolved := c.DefaultQuery("show_resolved", "false")
+
+	page, _ := strconv.Atoi(pageStr)
+	pageSize, _ := strconv.Atoi(pageSizeStr)
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	var total int64
+	var issues []model.ImageIntegrityIssue
+
+	query := db.DB.Model(&model.ImageIntegrityIssue{})
+	if showResolved != "true" {
+		query = query.Where("resolved = ?", false)
+	}
+
+	query.Count(&total)
+
+	result := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&issues)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取巡检记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"list":      issues,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ResolveIntegrityIssue 将巡检问题标记为已处理
+func ResolveIntegrityIssue(c *gin.Context) {
+	id := c.Param("id")
+
+	result := db.DB.Model(&model.ImageIntegrityIssue{}).Where("id = ?", id).Update("resolved", true)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已标记为已处理"})
+}
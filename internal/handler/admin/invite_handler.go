@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"perfect-pic-server/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateInviteCodeRequest 创建邀请码请求结构体
+type CreateInviteCodeRequest struct {
+	MaxUses   int   `json:"max_uses"`
+	ExpiresAt int64 `json:"expires_at"` // Unix 秒，0 表示永不过期
+}
+
+// CreateInviteCode 创建邀请码
+func CreateInviteCode(c *gin.Context) {
+	var req CreateInviteCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
+		return
+	}
+
+	adminID, _ := c.Get("id")
+	aid, _ := adminID.(uint)
+
+	invite, err := service.CreateInviteCode(aid, req.MaxUses, req.ExpiresAt)
+	if err != nil {
+		if _, ok := service.AsAuthError(err); !ok {
+			log.Printf("Create invite code error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建邀请码失败"})
+			return
+		}
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invite})
+}
+
+// GetInviteCodeList 获取邀请码列表
+func GetInviteCodeList(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	codes, total, err := service.ListInviteCodes(page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取邀请码列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      codes,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// DeleteInviteCode 删除邀请码
+func DeleteInviteCode(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的邀请码ID"})
+		return
+	}
+
+	if err := service.DeleteInviteCode(uint(id)); err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"perfect-pic-server/internal/middleware"
+	"perfect-pic-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecomputeStorage 按图片记录重新统计所有用户的已用空间，修正统计漂移
+func RecomputeStorage(c *gin.Context) {
+	fixed, err := service.RecomputeAllStorage()
+	if err != nil {
+		log.Printf("重新统计已用空间失败 (已修正 %d 个用户): %v", fixed, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重新统计已用空间失败", "fixed": fixed})
+		return
+	}
+
+	service.RecordAudit(adminActorID(c), service.AuditActionStorageRecompute, "storage", middleware.ClientIP(c),
+		map[string]interface{}{"fixed": fixed})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已用空间统计完成",
+		"fixed":   fixed,
+	})
+}
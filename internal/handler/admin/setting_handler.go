@@ -5,12 +5,12 @@ import (
 	"log"
 	"net/http"
 	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/middleware"
 	"perfect-pic-server/internal/model"
 	"perfect-pic-server/internal/service"
 	"perfect-pic-server/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type UpdateSettingRequest struct {
@@ -35,43 +35,33 @@ func UpdateSettings(c *gin.Context) {
 		return
 	}
 
-	// 开启事务 (Transaction)
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
-		for _, item := range reqs {
-			setting := model.Setting{
-				Key:   item.Key,
-				Value: item.Value,
-			}
-
-			// 尝试更新 Value 字段
-			// 使用 Model(&setting) 会利用主键 Key 进行条件匹配
-			result := tx.Model(&setting).Select("Value").Updates(setting)
-			if result.Error != nil {
-				return result.Error
-			}
-
-			// 如果没有记录被更新（说明不存在），则创建新记录
-			if result.RowsAffected == 0 {
-				if err := tx.Create(&setting).Error; err != nil {
-					return err
-				}
-			}
+	values := make(map[string]string, len(reqs))
+	keys := make([]string, 0, len(reqs))
+	for _, item := range reqs {
+		if _, ok := values[item.Key]; !ok {
+			keys = append(keys, item.Key)
 		}
-		return nil
-	})
+		values[item.Key] = item.Value
+	}
 
-	if err != nil {
+	// 校验并保存，仅清理受影响配置项的缓存 (多节点部署时同时通知其他节点)
+	if err := service.UpdateSettings(values); err != nil {
+		if validationErr, ok := service.AsSettingValidationError(err); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Message, "key": validationErr.Key})
+			return
+		}
 		log.Printf("配置更新失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
 		return
 	}
 
-	// 清空内存缓存
-	service.ClearCache()
+	// 审计日志只记录修改了哪些配置项，不记录配置值 (可能包含密钥)
+	service.RecordAudit(adminActorID(c), service.AuditActionSettingsUpdate, "settings", middleware.ClientIP(c),
+		map[string]interface{}{"keys": keys})
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "配置更新成功",
-		"count":   len(reqs),
+		"count":   len(keys),
 	})
 }
 
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"perfect-pic-server/internal/db"
 	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
 	"runtime"
 
 	"github.com/gin-gonic/gin"
@@ -28,8 +29,8 @@ func GetServerStats(c *gin.Context) {
 		return
 	}
 
-	// 统计用户数量
-	if err := db.DB.Model(&model.User{}).Count(&userCount).Error; err != nil {
+	// 统计用户数量 (不含访客上传使用的匿名系统用户)
+	if err := db.DB.Model(&model.User{}).Where("LOWER(username) <> ?", service.UsernameKey(service.AnonymousUsername)).Count(&userCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计用户数据失败"})
 		return
 	}
@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestMain 在临时目录中运行测试，未找到配置文件时使用默认配置
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "perfect-pic-admin-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	log.SetOutput(io.Discard)
+	gin.SetMode(gin.TestMode)
+	config.InitConfig()
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupTestDB 为当前测试创建独立的内存 SQLite 数据库，执行迁移并写入默认设置
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:admin_%s?mode=memory&cache=shared&_pragma=busy_timeout(5000)", name)
+	d, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.Migrate(d); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	prev := db.DB
+	db.DB = d
+	service.ClearCache()
+	service.InitializeSettings()
+	t.Cleanup(func() {
+		if sqlDB, err := d.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		db.DB = prev
+		service.ClearCache()
+	})
+}
+
+// createTestUser 创建一个状态正常的用户，mutate 可在写入前修改字段
+func createTestUser(t *testing.T, username string, mutate ...func(*model.User)) model.User {
+	t.Helper()
+	user := model.User{
+		Username:    username,
+		DisplayName: username,
+		Password:    "x",
+		Status:      1,
+		Email:       username + "@example.com",
+	}
+	for _, m := range mutate {
+		m(&user)
+	}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// withUser 模拟鉴权中间件，将管理员 ID 写入上下文
+func withUser(id uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("id", id)
+		c.Set("admin", true)
+		c.Next()
+	}
+}
+
+// doJSON 向 h 发送 JSON 请求并返回响应
+func doJSON(t *testing.T, h http.Handler, method, path string, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("marshal payload: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
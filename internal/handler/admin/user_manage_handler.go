@@ -1,7 +1,7 @@
 package admin
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"perfect-pic-server/internal/db"
@@ -10,51 +10,42 @@ import (
 	"perfect-pic-server/internal/service"
 	"perfect-pic-server/internal/utils"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 // GetUserList 获取用户列表
+// 支持按用户名/邮箱搜索 (keyword)、按状态 (status) 与邮箱验证状态 (email_verified) 过滤，
+// 以及按 id / created_at / storage_used 排序 (sort_by, order)
 func GetUserList(c *gin.Context) {
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("page_size", "10")
-	keyword := c.Query("keyword")
-	showDeleted := c.DefaultQuery("show_deleted", "false")
-	order := c.Query("order")
-
-	page, _ := strconv.Atoi(pageStr)
-	pageSize, _ := strconv.Atoi(pageSizeStr)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 	if page < 1 {
 		page = 1
 	}
 	if pageSize < 1 {
 		pageSize = 10
+	} else if pageSize > 100 {
+		pageSize = 100
 	}
 
-	var users []model.User
-	var total int64
-
-	query := db.DB.Model(&model.User{})
-	if showDeleted == "true" {
-		query = query.Unscoped()
+	opts := service.UserListOptions{
+		Page:        page,
+		PageSize:    pageSize,
+		Keyword:     c.Query("keyword"),
+		Status:      c.Query("status"),
+		SortBy:      c.Query("sort_by"),
+		Desc:        c.Query("order") != "asc",
+		ShowDeleted: c.DefaultQuery("show_deleted", "false") == "true",
 	}
-	if keyword != "" {
-		query = query.Where("username LIKE ?", "%"+keyword+"%")
+	if verified := c.Query("email_verified"); verified != "" {
+		v := verified == "true"
+		opts.EmailVerified = &v
 	}
 
-	query.Count(&total)
-
-	sortOrder := "id desc"
-	if order == "asc" {
-		sortOrder = "id asc"
-	}
-
-	result := query.Offset((page - 1) * pageSize).Limit(pageSize).Order(sortOrder).Find(&users)
-
-	if result.Error != nil {
+	users, total, err := service.ListUsers(opts)
+	if err != nil {
+		log.Printf("List users error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户列表失败"})
 		return
 	}
@@ -99,33 +90,37 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
-	if ok, msg := utils.ValidatePassword(req.Password); !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	if err := service.ValidatePasswordPolicy(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if ok, msg := utils.ValidateUsername(req.Username); !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	req.Username = service.NormalizeUsername(req.Username)
+	if err := service.ValidateUsernamePolicy(req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var count int64
-	db.DB.Model(&model.User{}).Where("username = ?", req.Username).Count(&count)
-	if count > 0 {
+	if taken, err := service.IsUsernameTaken(req.Username, 0); err != nil || taken {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := service.HashPassword(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
 		return
 	}
 
 	user := model.User{
-		Username: req.Username,
-		Password: string(hashedPassword),
-		Admin:    false,
+		Username:    service.UsernameKey(req.Username),
+		DisplayName: req.Username,
+		Password:    hashedPassword,
+		Admin:       false,
 	}
 
 	if err := db.DB.Create(&user).Error; err != nil {
@@ -144,6 +139,9 @@ type UpdateUserRequest struct {
 	EmailVerified *bool   `json:"email_verified"`
 	StorageQuota  *int64  `json:"storage_quota"`
 	Status        *int    `json:"status"`
+	// 每日上传次数/字节数上限，-1 表示恢复使用站点默认值，0 表示不限制
+	DailyUploadCountLimit *int   `json:"daily_upload_count_limit"`
+	DailyUploadBytesLimit *int64 `json:"daily_upload_bytes_limit"`
 }
 
 // UpdateUser 修改用户信息
@@ -168,11 +166,24 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	// 额外检查是否被其他用户占用
+	if val, ok := updates["username"]; ok {
+		if newUsername, ok := val.(string); ok {
+			taken, err := service.IsUsernameTaken(newUsername, uint(id))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
+				return
+			}
+			if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": "用户名已被其他用户占用"})
+				return
+			}
+		}
+	}
 	if val, ok := updates["email"]; ok {
 		if newEmail, ok := val.(string); ok {
 			var count int64
-			// 检查是否有其他用户使用了该邮箱
-			db.DB.Model(&model.User{}).Where("email = ? AND id != ?", newEmail, id).Count(&count)
+			// 检查是否有其他用户使用了该邮箱 (忽略大小写差异)
+			db.DB.Model(&model.User{}).Where("LOWER(email) = ? AND id != ?", utils.NormalizeEmail(newEmail), id).Count(&count)
 			if count > 0 {
 				c.JSON(http.StatusConflict, gin.H{"error": "该邮箱已被其他用户占用"})
 				return
@@ -190,10 +201,18 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	if len(updates) > 0 {
+		oldStatus, oldQuota := user.Status, user.StorageQuota
 		if err := db.DB.Model(&user).Updates(updates).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
 			return
 		}
+		if status, ok := updates["status"].(int); ok && status != oldStatus {
+			recordUserStatusAudit(c, user.ID, status)
+		}
+		if quota, ok := updates["storage_quota"]; ok {
+			service.RecordAudit(adminActorID(c), service.AuditActionUserQuota, service.AuditTargetUser(user.ID),
+				middleware.ClientIP(c), map[string]interface{}{"old": oldQuota, "new": quota})
+		}
 		// 清除用户状态缓存
 		middleware.ClearUserStatusCache(user.ID)
 	}
@@ -222,27 +241,32 @@ func prepareUserUpdates(req UpdateUserRequest) (map[string]interface{}, string)
 	if err := validateAndUpdateStatus(req, updates); err != "" {
 		return nil, err
 	}
+	if err := validateAndUpdateDailyUploadLimits(req, updates); err != "" {
+		return nil, err
+	}
 
 	return updates, ""
 }
 
 func validateAndUpdateUsername(req UpdateUserRequest, updates map[string]interface{}) string {
 	if req.Username != nil && *req.Username != "" {
-		if ok, msg := utils.ValidateUsername(*req.Username); !ok {
-			return msg
+		username := service.NormalizeUsername(*req.Username)
+		if err := service.ValidateUsernamePolicy(username); err != nil {
+			return err.Error()
 		}
-		updates["username"] = *req.Username
+		updates["username"] = service.UsernameKey(username)
+		updates["display_name"] = username
 	}
 	return ""
 }
 
 func validateAndUpdatePassword(req UpdateUserRequest, updates map[string]interface{}) string {
 	if req.Password != nil && *req.Password != "" {
-		if ok, msg := utils.ValidatePassword(*req.Password); !ok {
-			return msg
+		if err := service.ValidatePasswordPolicy(*req.Password); err != nil {
+			return err.Error()
 		}
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
-		updates["password"] = string(hashedPassword)
+		hashedPassword, _ := service.HashPassword(*req.Password)
+		updates["password"] = hashedPassword
 	}
 	return ""
 }
@@ -277,6 +301,28 @@ func validateAndUpdateStorageQuota(req UpdateUserRequest, updates map[string]int
 	return ""
 }
 
+func validateAndUpdateDailyUploadLimits(req UpdateUserRequest, updates map[string]interface{}) string {
+	if req.DailyUploadCountLimit != nil {
+		if *req.DailyUploadCountLimit == -1 {
+			updates["daily_upload_count_limit"] = nil
+		} else if *req.DailyUploadCountLimit >= 0 {
+			updates["daily_upload_count_limit"] = *req.DailyUploadCountLimit
+		} else {
+			return "每日上传次数上限不能为负数（-1除外）"
+		}
+	}
+	if req.DailyUploadBytesLimit != nil {
+		if *req.DailyUploadBytesLimit == -1 {
+			updates["daily_upload_bytes_limit"] = nil
+		} else if *req.DailyUploadBytesLimit >= 0 {
+			updates["daily_upload_bytes_limit"] = *req.DailyUploadBytesLimit
+		} else {
+			return "每日上传字节数上限不能为负数（-1除外）"
+		}
+	}
+	return ""
+}
+
 func validateAndUpdateStatus(req UpdateUserRequest, updates map[string]interface{}) string {
 	if req.Status != nil {
 		if *req.Status == 1 || *req.Status == 2 {
@@ -308,7 +354,10 @@ func UpdateUserAvatar(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	_ = ext
+	if utils.ImageTypeForExt(ext) == "svg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "头像不支持 SVG 格式"})
+		return
+	}
 
 	var user model.User
 	if err := db.DB.First(&user, id).Error; err != nil {
@@ -316,7 +365,11 @@ func UpdateUserAvatar(c *gin.Context) {
 		return
 	}
 
-	newFilename, err := service.UpdateUserAvatar(&user, file)
+	newFilename, err := service.UpdateUserAvatar(c.Request.Context(), &user, file)
+	if errors.Is(err, service.ErrServerBusy) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Printf("Admin UpdateUserAvatar error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "头像更新失败"})
@@ -350,6 +403,87 @@ func RemoveUserAvatar(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "头像已移除"})
 }
 
+// UnlockUser 解锁因登录失败次数过多被锁定的用户
+func UnlockUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	adminID, _ := c.Get("id")
+	aid, _ := adminID.(uint)
+
+	if err := service.UnlockUser(aid, uint(id)); err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.ClearUserStatusCache(uint(id))
+	c.JSON(http.StatusOK, gin.H{"message": "解锁成功"})
+}
+
+// ForceLogoutUser 强制用户下线，使其已签发的全部 Token 立即失效
+func ForceLogoutUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var user model.User
+	if err := db.DB.Select("id").First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if err := service.BumpTokenVersion(user.ID); err != nil {
+		log.Printf("Force logout error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "强制下线失败"})
+		return
+	}
+	if err := service.RevokeAllSessions(user.ID); err != nil {
+		log.Printf("Revoke sessions error: %v", err)
+	}
+	middleware.ClearUserStatusCache(user.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "已强制该用户下线"})
+}
+
+// ImpersonateUser 模拟登录为指定用户，返回短期有效的 Token
+func ImpersonateUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	token, err := service.ImpersonateUser(adminActorID(c), uint(id), service.SessionMeta{
+		UserAgent: c.Request.UserAgent(),
+		IP:        middleware.ClientIP(c),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		case errors.Is(err, service.ErrImpersonationForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Impersonate user error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "模拟登录失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_in": int(service.ImpersonationTokenDuration.Seconds()),
+	})
+}
+
 // DeleteUser 删除用户
 func DeleteUser(c *gin.Context) {
 	idStr := c.Param("id")
@@ -359,56 +493,124 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	hardDelete := c.DefaultQuery("hard_delete", "false")
+	adminID, _ := c.Get("id")
+	aid, _ := adminID.(uint)
 
-	// 暂时没做禁止删除自身
-	if hardDelete == "true" {
-		// 先清理文件
-		if err := service.DeleteUserFiles(uint(id)); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "清理用户文件失败"})
-			return
+	hardDelete := c.DefaultQuery("hard_delete", "false") == "true"
+	if err := service.DeleteUser(c.Request.Context(), aid, uint(id), hardDelete); err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// 清除用户状态缓存
+	middleware.ClearUserStatusCache(uint(id))
+
+	service.RecordAudit(aid, service.AuditActionUserDelete, service.AuditTargetUser(uint(id)), middleware.ClientIP(c),
+		map[string]interface{}{"hard_delete": hardDelete})
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// BulkUserStatusRequest 批量修改用户状态请求结构体
+type BulkUserStatusRequest struct {
+	IDs    []uint `json:"ids" binding:"required"`
+	Status int    `json:"status" binding:"required"`
+}
+
+// BulkUpdateUserStatus 批量封禁/解封用户
+func BulkUpdateUserStatus(c *gin.Context) {
+	var req BulkUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
+		return
+	}
+
+	adminID, _ := c.Get("id")
+	aid, _ := adminID.(uint)
+
+	results, err := service.BulkUpdateUserStatus(aid, req.IDs, req.Status)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	clearBulkUserStatusCache(results)
+	for _, r := range results {
+		if r.Success {
+			recordUserStatusAudit(c, r.UserID, req.Status)
 		}
+	}
 
-		err = db.DB.Transaction(func(tx *gorm.DB) error {
-			var user model.User
-			tx.Unscoped().First(&user, id)
-			tx.Unscoped().Delete(&user)
-			return nil
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
-			return
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+// BulkDeleteUsersRequest 批量删除用户请求结构体
+type BulkDeleteUsersRequest struct {
+	IDs []uint `json:"ids" binding:"required"`
+}
+
+// BulkDeleteUsers 批量彻底删除用户及其文件
+func BulkDeleteUsers(c *gin.Context) {
+	var req BulkDeleteUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
+		return
+	}
+
+	adminID, _ := c.Get("id")
+	aid, _ := adminID.(uint)
+
+	results, err := service.BulkDeleteUsers(c.Request.Context(), aid, req.IDs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	clearBulkUserStatusCache(results)
+	for _, r := range results {
+		if r.Success {
+			service.RecordAudit(aid, service.AuditActionUserDelete, service.AuditTargetUser(r.UserID), middleware.ClientIP(c),
+				map[string]interface{}{"hard_delete": true})
 		}
-	} else {
-		err = db.DB.Transaction(func(tx *gorm.DB) error {
-			var user model.User
-			tx.First(&user, id)
-			// 修改名字和邮箱，释放唯一索引占用，并标记为状态3(停用)
-			// 邮箱格式: delete_<timestamp>_<original_email>
-			// 注意长度限制 255
-			timestamp := time.Now().Unix()
-			newUsername := fmt.Sprintf("%s_del_%d", user.Username, timestamp)
-			newEmail := fmt.Sprintf("del_%d_%s", timestamp, user.Email)
-			if len(newEmail) > 255 {
-				newEmail = newEmail[:255]
-			}
+	}
 
-			tx.Model(&user).Updates(map[string]interface{}{
-				"username": newUsername,
-				"email":    newEmail,
-				"status":   3,
-			})
-			tx.Delete(&user)
-			return nil
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
-			return
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+// clearBulkUserStatusCache 清除批量操作成功用户的状态缓存
+func clearBulkUserStatusCache(results []service.BulkUserResult) {
+	for _, r := range results {
+		if r.Success {
+			middleware.ClearUserStatusCache(r.UserID)
 		}
 	}
+}
 
-	// 清除用户状态缓存
-	middleware.ClearUserStatusCache(uint(id))
+// adminActorID 返回当前管理员的用户 ID
+func adminActorID(c *gin.Context) uint {
+	adminID, _ := c.Get("id")
+	aid, _ := adminID.(uint)
+	return aid
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+// recordUserStatusAudit 记录封禁/解封审计日志
+func recordUserStatusAudit(c *gin.Context, userID uint, status int) {
+	action := service.AuditActionUserUnban
+	if status == 2 {
+		action = service.AuditActionUserBan
+	}
+	service.RecordAudit(adminActorID(c), action, service.AuditTargetUser(userID), middleware.ClientIP(c), nil)
+}
+
+// adminErrorStatus 将服务层错误映射为 HTTP 状态码
+func adminErrorStatus(err error) int {
+	if authErr, ok := service.AsAuthError(err); ok {
+		switch authErr.Code {
+		case service.AuthErrorNotFound:
+			return http.StatusNotFound
+		case service.AuthErrorValidation:
+			return http.StatusBadRequest
+		case service.AuthErrorForbidden:
+			return http.StatusForbidden
+		}
+	}
+	return http.StatusInternalServerError
 }
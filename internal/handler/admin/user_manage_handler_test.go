@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/middleware"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditEntries 返回指定操作的全部审计日志
+func auditEntries(t *testing.T, action string) []model.AuditLog {
+	t.Helper()
+	var entries []model.AuditLog
+	if err := db.DB.Where("action = ?", action).Find(&entries).Error; err != nil {
+		t.Fatalf("load audit logs: %v", err)
+	}
+	return entries
+}
+
+func TestBanUserWritesOneAuditEntry(t *testing.T) {
+	setupTestDB(t)
+	adminUser := createTestUser(t, "admin", func(u *model.User) { u.Admin = true })
+	target := createTestUser(t, "target")
+
+	r := gin.New()
+	r.PATCH("/admin/users/:id", withUser(adminUser.ID), UpdateUser)
+	path := "/admin/users/" + strconv.Itoa(int(target.ID))
+
+	if w := doJSON(t, r, http.MethodPatch, path, map[string]any{"status": 2}); w.Code != http.StatusOK {
+		t.Fatalf("ban status = %d body = %s", w.Code, w.Body.String())
+	}
+	entries := auditEntries(t, service.AuditActionUserBan)
+	if len(entries) != 1 {
+		t.Fatalf("ban audit entries = %d, want 1", len(entries))
+	}
+	if entries[0].ActorID != adminUser.ID || entries[0].Target != service.AuditTargetUser(target.ID) {
+		t.Fatalf("unexpected audit entry: %+v", entries[0])
+	}
+
+	// 状态未变化时不重复记录
+	if w := doJSON(t, r, http.MethodPatch, path, map[string]any{"status": 2}); w.Code != http.StatusOK {
+		t.Fatalf("repeat ban status = %d body = %s", w.Code, w.Body.String())
+	}
+	if n := len(auditEntries(t, service.AuditActionUserBan)); n != 1 {
+		t.Fatalf("ban audit entries after repeat = %d, want 1", n)
+	}
+	if n := len(auditEntries(t, service.AuditActionUserUnban)); n != 0 {
+		t.Fatalf("unban audit entries = %d, want 0", n)
+	}
+}
+
+func TestBulkBanWritesOneAuditEntryPerUser(t *testing.T) {
+	setupTestDB(t)
+	adminUser := createTestUser(t, "admin", func(u *model.User) { u.Admin = true })
+	a := createTestUser(t, "usera")
+	b := createTestUser(t, "userb")
+
+	r := gin.New()
+	r.POST("/admin/users/batch/status", withUser(adminUser.ID), BulkUpdateUserStatus)
+
+	// 重复 ID 只处理一次
+	payload := map[string]any{"ids": []uint{a.ID, b.ID, a.ID}, "status": 2}
+	if w := doJSON(t, r, http.MethodPost, "/admin/users/batch/status", payload); w.Code != http.StatusOK {
+		t.Fatalf("bulk ban status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	perTarget := map[string]int{}
+	for _, e := range auditEntries(t, service.AuditActionUserBan) {
+		perTarget[e.Target]++
+	}
+	for _, u := range []model.User{a, b} {
+		if n := perTarget[service.AuditTargetUser(u.ID)]; n != 1 {
+			t.Errorf("audit entries for %s = %d, want 1", u.Username, n)
+		}
+	}
+	if len(perTarget) != 2 {
+		t.Errorf("audit targets = %v, want exactly the two banned users", perTarget)
+	}
+}
+
+func TestForceLogoutInvalidatesOldTokens(t *testing.T) {
+	setupTestDB(t)
+	hashed, err := service.HashPassword("Passw0rd!")
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	adminUser := createTestUser(t, "admin", func(u *model.User) { u.Admin = true })
+	target := createTestUser(t, "target", func(u *model.User) { u.Password = hashed })
+	t.Cleanup(func() { middleware.ClearUserStatusCache(target.ID) })
+
+	r := gin.New()
+	r.POST("/admin/users/:id/logout", withUser(adminUser.ID), ForceLogoutUser)
+	r.GET("/user/profile", middleware.JWTAuth(), middleware.UserStatusCheck(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	profile := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/user/profile", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	token, err := service.LoginUser("target", "Passw0rd!", service.SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if code := profile(token); code != http.StatusNoContent {
+		t.Fatalf("before logout: status = %d, want %d", code, http.StatusNoContent)
+	}
+
+	path := "/admin/users/" + strconv.Itoa(int(target.ID)) + "/logout"
+	if w := doJSON(t, r, http.MethodPost, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("force logout status = %d body = %s", w.Code, w.Body.String())
+	}
+	if code := profile(token); code != http.StatusUnauthorized {
+		t.Fatalf("old token after forced logout: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	var stored model.User
+	db.DB.First(&stored, target.ID)
+	if stored.TokenVersion != target.TokenVersion+1 {
+		t.Fatalf("token version = %d, want %d", stored.TokenVersion, target.TokenVersion+1)
+	}
+}
+
+func TestGetUserListOmitsPasswordHash(t *testing.T) {
+	setupTestDB(t)
+	adminUser := createTestUser(t, "admin", func(u *model.User) { u.Admin = true })
+	createTestUser(t, "alice", func(u *model.User) { u.Password = "$2a$10$secret-hash" })
+	createTestUser(t, "albert", func(u *model.User) { u.Status = 2 })
+
+	r := gin.New()
+	r.GET("/admin/users", withUser(adminUser.ID), GetUserList)
+
+	w := doJSON(t, r, http.MethodGet, "/admin/users?keyword=al&status=active", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list status = %d body = %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret-hash") {
+		t.Fatalf("response leaks the password hash: %s", w.Body.String())
+	}
+	var resp struct {
+		Data  []map[string]any `json:"data"`
+		Total int64            `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Total != 1 || len(resp.Data) != 1 || resp.Data[0]["username"] != "alice" {
+		t.Fatalf("unexpected list: %+v", resp)
+	}
+	if _, ok := resp.Data[0]["password"]; ok {
+		t.Fatalf("list item should not contain a password field: %v", resp.Data[0])
+	}
+}
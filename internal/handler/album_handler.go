@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"perfect-pic-server/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyAlbums 获取自己的相册列表
+func GetMyAlbums(c *gin.Context) {
+	albums, err := service.ListAlbums(c.GetUint("id"))
+	if err != nil {
+		log.Printf("List albums error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取相册列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"list": albums})
+}
+
+type albumRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// CreateMyAlbum 创建相册
+func CreateMyAlbum(c *gin.Context) {
+	var req albumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	album, err := service.CreateAlbum(c.GetUint("id"), req.Name, req.Description)
+	if err != nil {
+		writeAlbumError(c, err, "创建相册失败")
+		return
+	}
+	c.JSON(http.StatusOK, album)
+}
+
+// UpdateMyAlbum 修改相册名称与描述
+func UpdateMyAlbum(c *gin.Context) {
+	albumID, ok := parseAlbumID(c)
+	if !ok {
+		return
+	}
+	var req albumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	album, err := service.UpdateAlbum(c.GetUint("id"), albumID, req.Name, req.Description)
+	if err != nil {
+		writeAlbumError(c, err, "修改相册失败")
+		return
+	}
+	c.JSON(http.StatusOK, album)
+}
+
+// DeleteMyAlbum 删除相册 (不删除其中的图片)
+func DeleteMyAlbum(c *gin.Context) {
+	albumID, ok := parseAlbumID(c)
+	if !ok {
+		return
+	}
+	if err := service.DeleteAlbum(c.GetUint("id"), albumID); err != nil {
+		writeAlbumError(c, err, "删除相册失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetMyAlbumImages 分页获取相册中的图片
+func GetMyAlbumImages(c *gin.Context) {
+	albumID, ok := parseAlbumID(c)
+	if !ok {
+		return
+	}
+	opts, ok := parseImageListOptions(c)
+	if !ok {
+		return
+	}
+	opts.AlbumID = albumID
+	respondImageList(c, opts)
+}
+
+// AddMyAlbumImages 将图片加入相册，不属于自己的图片会被忽略
+func AddMyAlbumImages(c *gin.Context) {
+	albumID, ok := parseAlbumID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Ids []uint `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	added, err := service.AddImagesToAlbum(c.GetUint("id"), albumID, req.Ids)
+	if err != nil {
+		writeAlbumError(c, err, "添加图片失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "添加成功", "added": added})
+}
+
+// RemoveMyAlbumImages 将图片移出相册
+func RemoveMyAlbumImages(c *gin.Context) {
+	albumID, ok := parseAlbumID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Ids []uint `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	removed, err := service.RemoveImagesFromAlbum(c.GetUint("id"), albumID, req.Ids)
+	if err != nil {
+		writeAlbumError(c, err, "移除图片失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "移除成功", "removed": removed})
+}
+
+// GetMyTags 获取自己的标签列表
+func GetMyTags(c *gin.Context) {
+	tags, err := service.ListTags(c.GetUint("id"))
+	if err != nil {
+		log.Printf("List tags error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"list": tags})
+}
+
+// GetMyImageTags 获取图片的标签
+func GetMyImageTags(c *gin.Context) {
+	imageID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片ID"})
+		return
+	}
+	tags, err := service.GetImageTags(c.GetUint("id"), uint(imageID))
+	if err != nil {
+		writeAlbumError(c, err, "获取标签失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// SetMyImageTags 替换图片的全部标签
+func SetMyImageTags(c *gin.Context) {
+	imageID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片ID"})
+		return
+	}
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	tags, err := service.SetImageTags(c.GetUint("id"), uint(imageID), req.Tags)
+	if err != nil {
+		writeAlbumError(c, err, "设置标签失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+func parseAlbumID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的相册ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeAlbumError 将相册/标签相关错误转换为 HTTP 响应
+func writeAlbumError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrAlbumNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
+	case errors.Is(err, service.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在或无权访问"})
+	case errors.Is(err, service.ErrInvalidAlbum), errors.Is(err, service.ErrInvalidTag):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Album operation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
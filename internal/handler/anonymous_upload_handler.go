@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/middleware"
+	"perfect-pic-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadAnonymousImage 访客免登录上传 (需开启 ConfigAllowAnonymousUpload)
+// 表单字段 file 为图片，验证码字段 (captcha_id/captcha_answer 或 captcha_token) 随表单提交且必须通过校验
+func UploadAnonymousImage(c *gin.Context) {
+	if !service.GetBool(consts.ConfigAllowAnonymousUpload) {
+		c.JSON(http.StatusForbidden, gin.H{"error": service.ErrAnonymousUploadDisabled.Error()})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择文件"})
+		return
+	}
+
+	if !verifyCaptchaChallenge(c, service.CaptchaActionAnonymousUpload, captchaFields{
+		CaptchaID:     c.PostForm("captcha_id"),
+		CaptchaAnswer: c.PostForm("captcha_answer"),
+		CaptchaToken:  c.PostForm("captcha_token"),
+	}) {
+		return
+	}
+
+	imageRecord, url, err := service.ProcessAnonymousUpload(c.Request.Context(), file, middleware.ClientIP(c))
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	writeUploadSuccess(c, imageRecord.UserID, imageRecord, url)
+}
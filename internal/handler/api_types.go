@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+)
+
+// 以下为公开接口的请求与响应结构体，处理函数与 OpenAPI 文档 (GetOpenAPISpec) 共用同一份定义
+
+// ErrorResponse 接口失败时的响应体，认证类错误附带错误码
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// MessageResponse 只包含提示信息的响应体
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// LoginRequest 登录请求
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"` // 用户名，开启 allow_email_login 时也可为邮箱
+	Password string `json:"password" binding:"required"`
+	// RememberMe 记住我，签发有效期更长的 Token (remember_me_hours)
+	RememberMe bool `json:"remember_me"`
+	captchaFields
+}
+
+// LoginResponse 登录成功的响应
+type LoginResponse struct {
+	Token   string `json:"token"`
+	Message string `json:"message"`
+}
+
+// RegisterRequest 注册请求
+type RegisterRequest struct {
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required"`
+	Locale     string `json:"locale"`
+	InviteCode string `json:"invite_code"`
+	captchaFields
+}
+
+// PasswordResetRequest 申请重置密码的请求
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	captchaFields
+}
+
+// UploadResponse 上传单张图片成功的响应
+type UploadResponse struct {
+	Msg string `json:"msg"`
+	URL string `json:"url"`
+	ID  uint   `json:"id"`
+	// PossibleDuplicates 图库中视觉相似的图片 ID，没有时省略
+	PossibleDuplicates []uint `json:"possible_duplicates,omitempty"`
+	// DeleteToken 一次性删除 Token (DELETE 图片地址?token=...)，DeletionURL 为对应的免登录删除链接
+	// 仅在开启 ConfigUploadDeleteToken 时返回
+	DeleteToken string `json:"delete_token,omitempty"`
+	DeletionURL string `json:"deletion_url,omitempty"`
+}
+
+// ShareXUploadResponse ShareX 自定义上传器格式 (URL: {json:url}，删除链接: {json:deletion_url})
+type ShareXUploadResponse struct {
+	URL         string `json:"url"`
+	DeletionURL string `json:"deletion_url,omitempty"`
+}
+
+// PicGoUploadResponse PicGo 自定义 Web 图床格式 (图片地址 JSON 路径: data.url)
+type PicGoUploadResponse struct {
+	Success bool              `json:"success"`
+	Data    PicGoUploadResult `json:"data"`
+}
+
+// PicGoUploadResult PicGo 响应中的图片信息
+type PicGoUploadResult struct {
+	ID          uint   `json:"id"`
+	URL         string `json:"url"`
+	DeletionURL string `json:"deletion_url,omitempty"`
+}
+
+// BatchUploadResponse 批量上传的响应，results 按上传顺序给出每个文件的结果
+type BatchUploadResponse struct {
+	Msg       string                 `json:"msg"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []service.UploadResult `json:"results"`
+}
+
+// ImageListResponse 图片分页列表
+type ImageListResponse struct {
+	List     []model.Image `json:"list"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// ReplaceImageResponse 替换图片成功的响应
+type ReplaceImageResponse struct {
+	Msg   string       `json:"msg"`
+	Image *model.Image `json:"image"`
+}
+
+// BatchDeleteRequest 批量删除图片的请求
+type BatchDeleteRequest struct {
+	Ids []uint `json:"ids" binding:"required"`
+}
+
+// BatchDeleteResponse 批量删除图片成功的响应
+type BatchDeleteResponse struct {
+	Message      string `json:"message"`
+	DeletedCount int    `json:"deleted_count"`
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"perfect-pic-server/internal/service"
+	"testing"
+	"time"
+)
+
+func TestAuthErrorHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code service.AuthErrorCode
+		want int
+	}{
+		{service.AuthErrorValidation, http.StatusBadRequest},
+		{service.AuthErrorReservedName, http.StatusBadRequest},
+		{service.AuthErrorUnauthorized, http.StatusUnauthorized},
+		{service.AuthErrorForbidden, http.StatusForbidden},
+		{service.AuthErrorLocked, http.StatusForbidden},
+		{service.AuthErrorConflict, http.StatusConflict},
+		{service.AuthErrorNotFound, http.StatusNotFound},
+		{service.AuthErrorTooManyAttempts, http.StatusTooManyRequests},
+		{service.AuthErrorCooldown, http.StatusTooManyRequests},
+		{service.AuthErrorInternal, http.StatusInternalServerError},
+		{service.AuthErrorCode("unknown"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		status, body := AuthErrorHTTPStatus(&service.AuthError{Code: tt.code, Message: "msg"})
+		if status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.code, status, tt.want)
+		}
+		if body["error"] != "msg" || body["code"] != tt.code {
+			t.Errorf("%s: unexpected body %v", tt.code, body)
+		}
+	}
+}
+
+func TestAuthErrorHTTPStatusOptionalFields(t *testing.T) {
+	// 包装后的 AuthError 同样识别，冷却时间向上取整到秒
+	err := fmt.Errorf("resend: %w", &service.AuthError{Code: service.AuthErrorCooldown, Message: "wait", RetryAfter: 1500 * time.Millisecond})
+	status, body := AuthErrorHTTPStatus(err)
+	if status != http.StatusTooManyRequests || body["retry_after"] != 2 {
+		t.Fatalf("cooldown: status = %d, body = %v", status, body)
+	}
+	if _, ok := body["remaining_attempts"]; ok {
+		t.Fatalf("attempt fields present without attempt status: %v", body)
+	}
+
+	status, body = AuthErrorHTTPStatus(errors.New("boom"))
+	if status != http.StatusInternalServerError || body["code"] != service.AuthErrorInternal || body["error"] == "boom" {
+		t.Fatalf("plain error: status = %d, body = %v", status, body)
+	}
+}
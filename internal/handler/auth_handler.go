@@ -1,170 +1,195 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
+	"log"
+	"math"
 	"net/http"
-	"perfect-pic-server/internal/config"
 	"perfect-pic-server/internal/consts"
 	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/middleware"
 	"perfect-pic-server/internal/model"
 	"perfect-pic-server/internal/service"
 	"perfect-pic-server/internal/utils"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func Login(c *gin.Context) {
-	cfg := config.Get()
-	var req struct {
-		Username      string `json:"username" binding:"required"`
-		Password      string `json:"password" binding:"required"`
-		CaptchaID     string `json:"captcha_id" binding:"required"`
-		CaptchaAnswer string `json:"captcha_answer" binding:"required"`
-	}
+	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
 
-	if !utils.VerifyCaptcha(req.CaptchaID, req.CaptchaAnswer) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误或已过期"})
+	if !verifyCaptchaChallenge(c, service.CaptchaActionLogin, req.captchaFields) {
 		return
 	}
 
-	var user model.User
-	result := db.DB.Where("username = ?", req.Username).First(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+	token, err := service.LoginUser(req.Username, req.Password, service.SessionMeta{
+		UserAgent:  c.Request.UserAgent(),
+		IP:         middleware.ClientIP(c),
+		RememberMe: req.RememberMe,
+	})
+	if err != nil {
+		writeAuthError(c, err)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
-		return
-	}
+	c.JSON(http.StatusOK, LoginResponse{Token: token, Message: "登录成功"})
+}
 
-	if user.Status == 2 {
-		c.JSON(http.StatusForbidden, gin.H{"error": "该账号已被封禁"})
-		return
+// authErrorStatuses AuthError 错误码对应的 HTTP 状态码，未登记的错误码按 500 处理
+var authErrorStatuses = map[service.AuthErrorCode]int{
+	service.AuthErrorValidation:      http.StatusBadRequest,
+	service.AuthErrorReservedName:    http.StatusBadRequest,
+	service.AuthErrorUnauthorized:    http.StatusUnauthorized,
+	service.AuthErrorForbidden:       http.StatusForbidden,
+	service.AuthErrorLocked:          http.StatusForbidden,
+	service.AuthErrorConflict:        http.StatusConflict,
+	service.AuthErrorNotFound:        http.StatusNotFound,
+	service.AuthErrorTooManyAttempts: http.StatusTooManyRequests,
+	service.AuthErrorCooldown:        http.StatusTooManyRequests,
+	service.AuthErrorInternal:        http.StatusInternalServerError,
+}
+
+// AuthErrorHTTPStatus 将认证业务错误转换为 HTTP 状态码与响应体，可直接用于 c.JSON(AuthErrorHTTPStatus(err))
+// 响应体固定包含 error (提示信息) 与 code (错误码)；非 AuthError 的错误记录日志后按内部错误返回
+func AuthErrorHTTPStatus(err error) (int, gin.H) {
+	authErr, ok := service.AsAuthError(err)
+	if !ok {
+		log.Printf("Auth error: %v", err)
+		return http.StatusInternalServerError, gin.H{"error": "系统错误，请稍后重试", "code": service.AuthErrorInternal}
 	}
-	if user.Status == 3 {
-		c.JSON(http.StatusForbidden, gin.H{"error": "该账号已停用"})
-		return
+
+	status, ok := authErrorStatuses[authErr.Code]
+	if !ok {
+		status = http.StatusInternalServerError
 	}
 
-	// 检查是否阻止未验证邮箱用户登录
-	if service.GetBool(consts.ConfigBlockUnverifiedUsers) {
-		if user.Email != "" && !user.EmailVerified {
-			c.JSON(http.StatusForbidden, gin.H{"error": "请先验证邮箱后再登录"})
-			return
-		}
+	body := gin.H{"error": authErr.Message}
+	if authErr.Attempt != nil {
+		body = loginFailureBody(authErr.Message, *authErr.Attempt)
+	}
+	if authErr.RetryAfter > 0 {
+		body["retry_after"] = int(math.Ceil(authErr.RetryAfter.Seconds()))
 	}
+	body["code"] = authErr.Code
+	return status, body
+}
 
-	// 签发 Token
-	token, _ := utils.GenerateLoginToken(user.ID, user.Username, user.Admin, time.Hour*time.Duration(cfg.JWT.ExpirationHours))
+// writeAuthError 将认证业务错误转换为 HTTP 响应，提示信息按请求语言渲染
+func writeAuthError(c *gin.Context, err error) {
+	status, body := AuthErrorHTTPStatus(err)
+	if _, ok := service.AsAuthError(err); ok {
+		body["error"] = service.LocalizeError(err, requestLocale(c))
+	}
+	c.JSON(status, body)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":   token,
-		"message": "登录成功",
-	})
+// loginFailureBody 构造登录失败的响应体
+// 开启锁定且允许透露时，附带剩余尝试次数与锁定截止时间 (Unix 秒)
+func loginFailureBody(msg string, status service.LoginAttemptStatus) gin.H {
+	body := gin.H{"error": msg}
+	if !status.Enabled || !service.GetBool(consts.ConfigLoginRevealAttempts) {
+		return body
+	}
+	body["remaining_attempts"] = status.Remaining
+	if status.Locked() {
+		body["locked_until"] = status.LockedUntil.Unix()
+	}
+	return body
 }
 
 func Register(c *gin.Context) {
-	var req struct {
-		Username      string `json:"username" binding:"required"`
-		Password      string `json:"password" binding:"required"`
-		Email         string `json:"email" binding:"required"`
-		CaptchaID     string `json:"captcha_id" binding:"required"`
-		CaptchaAnswer string `json:"captcha_answer" binding:"required"`
-	}
+	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数格式错误"})
 		return
 	}
 
-	if ok, msg := utils.ValidatePassword(req.Password); !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	if err := service.ValidatePasswordPolicy(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if ok, msg := utils.ValidateUsername(req.Username); !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	req.Username = service.NormalizeUsername(req.Username)
+	if err := service.ValidateUsernamePolicy(req.Username); err != nil {
+		writeAuthError(c, err)
 		return
 	}
 
+	req.Email = utils.NormalizeEmail(req.Email)
 	if ok, msg := utils.ValidateEmail(req.Email); !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
 
-	if !utils.VerifyCaptcha(req.CaptchaID, req.CaptchaAnswer) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误或已过期"})
+	if !verifyCaptchaChallenge(c, service.CaptchaActionRegister, req.captchaFields) {
 		return
 	}
 
-	allowRegister := service.GetBool(consts.ConfigAllowRegister)
-	if !allowRegister {
-		c.JSON(http.StatusForbidden, gin.H{"error": "注册功能已关闭"})
+	if err := service.CheckRegistrationAllowed(req.InviteCode); err != nil {
+		writeAuthError(c, err)
 		return
 	}
 
-	var existingUser model.User
-	result := db.DB.Where("username = ?", req.Username).First(&existingUser)
-	if result.RowsAffected > 0 {
+	taken, err := service.IsUsernameTaken(req.Username, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败，请稍后重试"})
+		return
+	}
+	if taken {
 		c.JSON(http.StatusConflict, gin.H{"error": "用户名已存在"})
 		return
 	}
 
+	// 按规范化形式比较，忽略大小写差异 (与修改邮箱、邮箱登录一致)
 	var existingEmailUser model.User
-	if db.DB.Where("email = ?", req.Email).First(&existingEmailUser).RowsAffected > 0 {
+	if db.DB.Where("LOWER(email) = ?", req.Email).First(&existingEmailUser).RowsAffected > 0 {
 		c.JSON(http.StatusConflict, gin.H{"error": "邮箱已被注册"})
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := service.HashPassword(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
 		return
 	}
 
 	newUser := model.User{
-		Username:      req.Username,
-		Password:      string(hashedPassword),
+		Username:      service.UsernameKey(req.Username),
+		DisplayName:   req.Username,
+		Password:      hashedPassword,
 		Email:         req.Email,
 		EmailVerified: false,
 		Admin:         false,
 		Avatar:        "", // 默认头像
+		Locale:        service.NormalizeLocale(req.Locale),
 	}
 
-	if err := db.DB.Create(&newUser).Error; err != nil {
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := service.ConsumeInviteCode(tx, req.InviteCode); err != nil {
+			return err
+		}
+		return tx.Create(&newUser).Error
+	})
+	if err != nil {
+		if _, ok := service.AsAuthError(err); ok {
+			writeAuthError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败，请稍后重试"})
 		return
 	}
 
-	// 生成验证 Token (有效期30分钟)
-	verifyToken, _ := utils.GenerateEmailToken(newUser.ID, newUser.Email, 30*time.Minute)
-
-	baseURL := service.GetString(consts.ConfigBaseURL)
-	if baseURL == "" {
-		baseURL = "http://localhost"
-	}
-	// 去除末尾斜杠
-	if len(baseURL) > 0 && baseURL[len(baseURL)-1] == '/' {
-		baseURL = baseURL[:len(baseURL)-1]
+	// 发送验证邮件 (进入异步队列)
+	if err := service.SendAccountVerificationEmail(&newUser); err != nil {
+		log.Printf("Send verification email error: %v", err)
 	}
 
-	// 生成前端验证页面的链接
-	// 用户点击邮件中的链接 -> 跳转到前端 /auth/email-verify 页面 -> 前端获取 token 并调用后端 /api/auth/email-verify 接口
-	verifyUrl := fmt.Sprintf("%s/auth/email-verify?token=%s", baseURL, verifyToken)
-
-	// 异步发送验证邮件
-	go func() {
-		_ = service.SendVerificationEmail(newUser.Email, newUser.Username, verifyUrl)
-	}()
-
-	c.JSON(http.StatusOK, gin.H{"message": "注册成功，请前往邮箱验证"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "注册成功，请前往邮箱验证"})
 }
 
 func EmailVerify(c *gin.Context) {
@@ -200,11 +225,21 @@ func EmailVerify(c *gin.Context) {
 		return
 	}
 
-	user.EmailVerified = true
-	if err := db.DB.Save(&user).Error; err != nil {
+	// 以未验证且未进入删除流程为条件更新，避免与清理未验证账号的后台任务互相覆盖
+	result := db.DB.Model(&model.User{}).Where("id = ? AND email_verified = ? AND status <> ?", user.ID, false, 3).
+		Update("email_verified", true)
+	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证失败，请稍后重试"})
 		return
 	}
+	if result.RowsAffected == 0 {
+		if err := db.DB.Select("email_verified").First(&user, user.ID).Error; err == nil && user.EmailVerified {
+			c.JSON(http.StatusOK, gin.H{"message": "邮箱已验证，无需重复验证"})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "账号已停用"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "邮箱验证成功，现在可以登录了"})
 }
@@ -242,79 +277,160 @@ func EmailChangeVerify(c *gin.Context) {
 		return
 	}
 
-	// 再次检查新邮箱是否被占用
-	var count int64
-	db.DB.Model(&model.User{}).Where("email = ? AND id != ?", claims.NewEmail, claims.ID).Count(&count)
-	if count > 0 {
+	// 只有最近一次发起且未被撤销的修改可以确认
+	newEmail := utils.NormalizeEmail(claims.NewEmail)
+	if utils.NormalizeEmail(user.PendingEmail) != newEmail {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该修改已被撤销或已有更新的修改请求，验证链接已失效"})
+		return
+	}
+
+	// 再次检查新邮箱是否被占用 (发起修改到确认之间可能已被其他用户注册)
+	if isEmailTakenByOther(newEmail, claims.ID) {
 		c.JSON(http.StatusConflict, gin.H{"error": "新邮箱已被其他用户占用，无法修改"})
 		return
 	}
 
-	user.Email = claims.NewEmail
+	user.Email = newEmail
+	user.PendingEmail = ""
 	user.EmailVerified = true // 修改成功即视为新邮箱已验证
 	if err := db.DB.Save(&user).Error; err != nil {
+		// 检查与保存之间存在竞争窗口，唯一索引冲突时同样按占用处理
+		if isEmailTakenByOther(newEmail, claims.ID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "新邮箱已被其他用户占用，无法修改"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "邮箱修改失败，请稍后重试"})
 		return
 	}
 
+	// 登录邮箱已变更，使此前签发的全部 Token 失效
+	if err := service.BumpTokenVersion(user.ID); err != nil {
+		log.Printf("Bump token version error: %v", err)
+	}
+	middleware.ClearUserStatusCache(user.ID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "邮箱修改成功"})
 }
 
-// RequestPasswordReset 请求重置密码
-func RequestPasswordReset(c *gin.Context) {
+// EmailChangeCancel 通过旧邮箱中的撤销链接取消 (或撤回已确认的) 邮箱修改，并使该用户的全部登录失效
+func EmailChangeCancel(c *gin.Context) {
 	var req struct {
-		Email         string `json:"email" binding:"required,email"`
-		CaptchaID     string `json:"captcha_id" binding:"required"`
-		CaptchaAnswer string `json:"captcha_answer" binding:"required"`
+		Token string `json:"token" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
 
-	if !utils.VerifyCaptcha(req.CaptchaID, req.CaptchaAnswer) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误或已过期"})
+	userID, err := service.CancelEmailChange(req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmailChangeCancelInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrEmailChangeRevertConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
+	middleware.ClearUserStatusCache(userID)
 
-	var user model.User
-	// 查找用户
-	if err := db.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		// 为了安全，即使用户不存在也提示发送成功，防止探测邮箱是否存在
-		c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，重置密码邮件将发送至您的邮箱"})
+	c.JSON(http.StatusOK, gin.H{"message": "已撤销邮箱修改，所有设备已退出登录，建议尽快修改密码"})
+}
+
+// AccountDeletionCancel 通过确认邮件中的撤销链接撤销注销申请，账号恢复正常登录
+func AccountDeletionCancel(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
 
-	// 检查用户状态
-	if user.Status == 2 || user.Status == 3 {
-		c.JSON(http.StatusForbidden, gin.H{"error": "该账号已被封禁或停用，无法重置密码"})
+	userID, err := service.CancelAccountDeletionByToken(req.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountDeletionCancelInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	middleware.ClearUserStatusCache(userID)
 
-	// 生成 Token (内存中)
-	token, err := service.GenerateForgetPasswordToken(user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "已撤销注销申请，现在可以重新登录"})
+}
+
+// AccountUnlock 通过解锁邮件中的链接自助解锁因登录失败被锁定的账号
+func AccountUnlock(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	userID, err := service.UnlockAccountByToken(req.Token)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成重置链接失败，请稍后重试"})
+		if errors.Is(err, service.ErrAccountUnlockInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	middleware.ClearUserStatusCache(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "账号已解锁，现在可以重新登录"})
+}
 
-	// 生成链接
-	baseURL := service.GetString(consts.ConfigBaseURL)
-	if baseURL == "" {
-		baseURL = "http://localhost"
+// RequestAccountUnlock 向被锁定账号的邮箱重新发送解锁邮件
+func RequestAccountUnlock(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	// 为了安全，邮箱不存在或账号未被锁定时也提示发送成功，防止探测邮箱是否存在
+	if err := service.RequestAccountUnlock(req.Email); err != nil {
+		log.Printf("Request account unlock error: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱对应的账号已被锁定，解锁邮件将发送至您的邮箱"})
+}
+
+// isEmailTakenByOther 检查邮箱 (规范化形式) 是否已被其他用户使用
+func isEmailTakenByOther(normalizedEmail string, userID uint) bool {
+	var count int64
+	db.DB.Model(&model.User{}).Where("LOWER(email) = ? AND id != ?", normalizedEmail, userID).Count(&count)
+	return count > 0
+}
+
+// RequestPasswordReset 请求重置密码
+func RequestPasswordReset(c *gin.Context) {
+	var req PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
 	}
-	if len(baseURL) > 0 && baseURL[len(baseURL)-1] == '/' {
-		baseURL = baseURL[:len(baseURL)-1]
+
+	if !verifyCaptchaChallenge(c, service.CaptchaActionPasswordReset, req.captchaFields) {
+		return
 	}
-	// 前端重置密码页面: /auth/reset-password?token=xxx
-	resetUrl := fmt.Sprintf("%s/auth/reset-password?token=%s", baseURL, token)
 
-	// 发送邮件
-	go func() {
-		_ = service.SendPasswordResetEmail(user.Email, user.Username, resetUrl)
-	}()
+	// 为了安全，用户不存在或处于冷却期时也提示发送成功，防止探测邮箱是否存在
+	if err := service.RequestPasswordReset(req.Email); err != nil {
+		writeAuthError(c, err)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，重置密码邮件将发送至您的邮箱"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "如果该邮箱已注册，重置密码邮件将发送至您的邮箱"})
 }
 
 // ResetPassword 执行重置密码
@@ -328,8 +444,8 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if ok, msg := utils.ValidatePassword(req.NewPassword); !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	if err := service.ValidatePasswordPolicy(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -353,13 +469,13 @@ func ResetPassword(c *gin.Context) {
 	}
 
 	// 更新密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hashedPassword, err := service.HashPassword(req.NewPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
 		return
 	}
 
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 	// 成功通过邮箱重置密码，视为邮箱有效
 	user.EmailVerified = true
 
@@ -368,12 +484,25 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
+	// 密码已重置，使该用户此前签发的全部 Token 与其他重置链接失效
+	service.RevokePasswordResetTokens(user.ID)
+	if err := service.BumpTokenVersion(user.ID); err != nil {
+		log.Printf("Bump token version error: %v", err)
+	}
+	if err := service.RevokeAllSessions(user.ID); err != nil {
+		log.Printf("Revoke sessions error: %v", err)
+	}
+	middleware.ClearUserStatusCache(user.ID)
+
+	service.RecordAudit(user.ID, service.AuditActionPasswordReset, service.AuditTargetUser(user.ID), middleware.ClientIP(c), nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "密码重置成功，请使用新密码登录"})
 }
 
 func GetRegisterState(c *gin.Context) {
-	allowRegister := service.GetBool(consts.ConfigAllowRegister)
+	mode := service.GetRegistrationMode()
 	c.JSON(http.StatusOK, gin.H{
-		"allow_register": allowRegister,
+		"allow_register":    mode != service.RegistrationModeClosed,
+		"registration_mode": mode,
 	})
 }
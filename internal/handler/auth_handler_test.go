@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"perfect-pic-server/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newLoginRouter() *gin.Engine {
+	r := gin.New()
+	r.POST("/login", Login)
+	return r
+}
+
+func TestLoginFailureRevealsRemainingAttempts(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigLoginMaxAttempts:    "3",
+		consts.ConfigLoginRevealAttempts: "true",
+	})
+	createTestUser(t, "alice")
+	r := newLoginRouter()
+
+	w, body := doJSON(t, r, http.MethodPost, "/login", gin.H{"username": "alice", "password": "wrong"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if got := body["remaining_attempts"]; got != float64(2) {
+		t.Fatalf("remaining_attempts = %v, want 2 (body %v)", got, body)
+	}
+	if _, ok := body["locked_until"]; ok {
+		t.Fatal("locked_until present before lockout")
+	}
+
+	doJSON(t, r, http.MethodPost, "/login", gin.H{"username": "alice", "password": "wrong"})
+	doJSON(t, r, http.MethodPost, "/login", gin.H{"username": "alice", "password": "wrong"})
+	w, body = doJSON(t, r, http.MethodPost, "/login", gin.H{"username": "alice", "password": testPassword})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status while locked = %d (body %v)", w.Code, body)
+	}
+	if _, ok := body["locked_until"]; !ok {
+		t.Fatalf("locked_until missing while locked: %v", body)
+	}
+}
+
+func TestLoginFailureHidesAttemptsWhenRevealOff(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigLoginMaxAttempts:    "3",
+		consts.ConfigLoginRevealAttempts: "false",
+	})
+	createTestUser(t, "bob")
+
+	w, body := doJSON(t, newLoginRouter(), http.MethodPost, "/login", gin.H{"username": "bob", "password": "wrong"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	for _, key := range []string{"remaining_attempts", "locked_until"} {
+		if _, ok := body[key]; ok {
+			t.Fatalf("%s revealed while login_reveal_attempts is off: %v", key, body)
+		}
+	}
+}
+
+func TestEmailVerify(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "carol", func(u *model.User) { u.EmailVerified = false })
+	token, err := utils.GenerateEmailToken(user.ID, user.Email, time.Hour)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	r := gin.New()
+	r.POST("/verify", EmailVerify)
+
+	if w, body := doJSON(t, r, http.MethodPost, "/verify", gin.H{"token": token}); w.Code != http.StatusOK {
+		t.Fatalf("status = %d (body %v)", w.Code, body)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if !stored.EmailVerified {
+		t.Fatal("email not marked verified")
+	}
+	if w, body := doJSON(t, r, http.MethodPost, "/verify", gin.H{"token": token}); w.Code != http.StatusOK {
+		t.Fatalf("repeated verify status = %d (body %v)", w.Code, body)
+	}
+}
+
+func TestEmailVerifyRejectsAccountBeingPurged(t *testing.T) {
+	setupTestDB(t)
+	// 清理任务已将账号标记为删除中 (状态 3)
+	user := createTestUser(t, "dave", func(u *model.User) { u.EmailVerified = false; u.Status = 3 })
+	token, err := utils.GenerateEmailToken(user.ID, user.Email, time.Hour)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	r := gin.New()
+	r.POST("/verify", EmailVerify)
+
+	if w, body := doJSON(t, r, http.MethodPost, "/verify", gin.H{"token": token}); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d (body %v), want 403", w.Code, body)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.EmailVerified {
+		t.Fatal("email verified on an account being purged")
+	}
+}
+
+func TestRegisterComparesEmailCaseInsensitively(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "foo")
+	r := gin.New()
+	r.POST("/register", Register)
+
+	w, body := doJSON(t, r, http.MethodPost, "/register", gin.H{"username": "foo2", "password": testPassword, "email": "FOO@Example.com"})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("register with a differently cased email: status = %d (body %v), want 409", w.Code, body)
+	}
+
+	w, body = doJSON(t, r, http.MethodPost, "/register", gin.H{"username": "barbar", "password": testPassword, "email": "  Bar@Example.COM "})
+	if w.Code != http.StatusOK {
+		t.Fatalf("register: status = %d (body %v)", w.Code, body)
+	}
+	var stored model.User
+	db.DB.Where("username = ?", "barbar").First(&stored)
+	if stored.Email != "bar@example.com" {
+		t.Fatalf("stored email = %q, want the normalized form", stored.Email)
+	}
+}
+
+// registerAs 以 username 注册新用户，inviteCode 为空时不携带邀请码
+func registerAs(t *testing.T, r http.Handler, username, inviteCode string) (int, map[string]any) {
+	t.Helper()
+	payload := gin.H{"username": username, "password": testPassword, "email": username + "@example.com"}
+	if inviteCode != "" {
+		payload["invite_code"] = inviteCode
+	}
+	w, body := doJSON(t, r, http.MethodPost, "/register", payload)
+	return w.Code, body
+}
+
+func TestRegisterModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     int
+	}{
+		{name: "open", settings: map[string]string{consts.ConfigRegistrationMode: "open"}, want: http.StatusOK},
+		{name: "closed", settings: map[string]string{consts.ConfigRegistrationMode: "closed"}, want: http.StatusForbidden},
+		{name: "allow register off overrides mode", settings: map[string]string{consts.ConfigAllowRegister: "false", consts.ConfigRegistrationMode: "open"}, want: http.StatusForbidden},
+		{name: "invite without code", settings: map[string]string{consts.ConfigRegistrationMode: "invite"}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			setTestSettings(t, tt.settings)
+			r := gin.New()
+			r.POST("/register", Register)
+
+			code, body := registerAs(t, r, "newbie", "")
+			if code != tt.want {
+				t.Fatalf("status = %d (body %v), want %d", code, body, tt.want)
+			}
+			var count int64
+			db.DB.Model(&model.User{}).Where("username = ?", "newbie").Count(&count)
+			if (count == 1) != (tt.want == http.StatusOK) {
+				t.Fatalf("user created = %v, want %v", count == 1, tt.want == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestRegisterWithInviteCode(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigRegistrationMode: "invite"})
+	admin := createTestUser(t, "admin", func(u *model.User) { u.Admin = true })
+	r := gin.New()
+	r.POST("/register", Register)
+
+	invite, err := service.CreateInviteCode(admin.ID, 1, 0)
+	if err != nil {
+		t.Fatalf("create invite code: %v", err)
+	}
+	if code, body := registerAs(t, r, "first", invite.Code); code != http.StatusOK {
+		t.Fatalf("register with invite code: status = %d (body %v)", code, body)
+	}
+	var stored model.InviteCode
+	db.DB.First(&stored, invite.ID)
+	if stored.UsedCount != 1 {
+		t.Fatalf("used count = %d, want 1", stored.UsedCount)
+	}
+
+	// 已用完的邀请码不能再次使用
+	if code, body := registerAs(t, r, "second", invite.Code); code != http.StatusBadRequest {
+		t.Fatalf("reused invite code: status = %d (body %v), want 400", code, body)
+	}
+
+	expired := model.InviteCode{Code: "EXPIRED", CreatedBy: admin.ID, MaxUses: 5,
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(), CreatedAt: time.Now().Add(-time.Hour).Unix()}
+	if err := db.DB.Create(&expired).Error; err != nil {
+		t.Fatalf("create expired invite code: %v", err)
+	}
+	if code, body := registerAs(t, r, "third", "EXPIRED"); code != http.StatusBadRequest {
+		t.Fatalf("expired invite code: status = %d (body %v), want 400", code, body)
+	}
+	if code, body := registerAs(t, r, "fourth", "NOSUCHCODE"); code != http.StatusBadRequest {
+		t.Fatalf("unknown invite code: status = %d (body %v), want 400", code, body)
+	}
+
+	var count int64
+	db.DB.Model(&model.User{}).Where("username IN ?", []string{"second", "third", "fourth"}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d users registered with an invalid invite code", count)
+	}
+}
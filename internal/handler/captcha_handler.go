@@ -1,14 +1,31 @@
 package handler
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"perfect-pic-server/internal/middleware"
+	"perfect-pic-server/internal/service"
 	"perfect-pic-server/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// captchaFields 需要验证码的请求中携带的验证码字段
+// 内置图片验证码使用 captcha_id 与 captcha_answer，Cap 使用组件返回的 captcha_token
+type captchaFields struct {
+	CaptchaID     string `json:"captcha_id"`
+	CaptchaAnswer string `json:"captcha_answer"`
+	CaptchaToken  string `json:"captcha_token"`
+}
+
 // GetCaptcha 获取验证码
 func GetCaptcha(c *gin.Context) {
+	if service.GetCaptchaProvider() != service.CaptchaProviderImage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前验证码类型无需获取图片验证码"})
+		return
+	}
+
 	id, b64s, _, err := utils.MakeCaptcha()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证码生成失败"})
@@ -20,3 +37,37 @@ func GetCaptcha(c *gin.Context) {
 		"captcha_image": b64s,
 	})
 }
+
+// GetCaptchaProviderInfo 获取当前验证码类型及前端组件所需的公开配置
+func GetCaptchaProviderInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, service.GetCaptchaProviderInfo())
+}
+
+// verifyCaptchaChallenge 校验 action 操作的验证码，操作不在 ConfigCaptchaRequiredActions 中
+// 或客户端 IP 位于免验证码列表 (ConfigCaptchaBypassIPs) 时直接通过；
+// 访客上传始终需要验证码，免验证码列表对其不生效。
+// 验证码字段因此不再是必填参数，需要校验的请求缺少验证码时校验失败
+// 校验失败时已写入错误响应，调用方直接返回即可
+func verifyCaptchaChallenge(c *gin.Context, action string, fields captchaFields) bool {
+	if !service.CaptchaRequired(action) {
+		return true
+	}
+	if action != service.CaptchaActionAnonymousUpload && middleware.CaptchaBypassed(c) {
+		return true
+	}
+	err := service.VerifyCaptchaChallenge(c.Request.Context(), service.CaptchaSubmission{
+		ID:     fields.CaptchaID,
+		Answer: fields.CaptchaAnswer,
+		Token:  fields.CaptchaToken,
+	})
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, service.ErrCaptchaInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrCaptchaInvalid.Error()})
+		return false
+	}
+	log.Printf("Captcha verify error: %v", err)
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrCaptchaUnavailable.Error()})
+	return false
+}
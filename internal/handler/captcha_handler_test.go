@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"perfect-pic-server/internal/utils"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withRemoteAddr 模拟来自 addr 的连接
+func withRemoteAddr(addr string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.RemoteAddr = addr
+		c.Next()
+	}
+}
+
+func TestCaptchaBypassIPs(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigCaptchaRequiredActions: "login",
+		consts.ConfigCaptchaBypassIPs:       "203.0.113.0/24, 2001:db8::1",
+	})
+	createTestUser(t, "tester")
+
+	tests := []struct {
+		name    string
+		addr    string
+		headers []string
+		want    int
+	}{
+		// 免验证码的请求直接进入密码校验
+		{name: "allowlisted ipv4", addr: "203.0.113.9:4000", want: http.StatusUnauthorized},
+		{name: "allowlisted ipv6", addr: "[2001:db8::1]:4000", want: http.StatusUnauthorized},
+		{name: "not allowlisted", addr: "198.51.100.1:4000", want: http.StatusBadRequest},
+		// 不可信的连接伪造 X-Forwarded-For 不能绕过验证码
+		{name: "spoofed forwarded for", addr: "198.51.100.1:4000", headers: []string{"X-Forwarded-For", "203.0.113.9"}, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.POST("/login", withRemoteAddr(tt.addr), Login)
+		w, body := doJSON(t, r, http.MethodPost, "/login", gin.H{"username": "tester", "password": "wrong"}, tt.headers...)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (body %v)", tt.name, w.Code, tt.want, body)
+		}
+	}
+}
+
+func TestCaptchaBypassListEmptyByDefault(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaRequiredActions: "login"})
+	createTestUser(t, "tester")
+
+	r := gin.New()
+	r.POST("/login", withRemoteAddr("127.0.0.1:4000"), Login)
+	if w, _ := doJSON(t, r, http.MethodPost, "/login", gin.H{"username": "tester", "password": testPassword}); w.Code != http.StatusBadRequest {
+		t.Fatalf("login without captcha: status = %d, want 400", w.Code)
+	}
+}
+
+func TestCaptchaRequiredActions(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "tester")
+	r := gin.New()
+	r.POST("/login", Login)
+	r.POST("/register", Register)
+	r.POST("/password/reset", RequestPasswordReset)
+
+	requests := map[string]struct {
+		path    string
+		payload gin.H
+	}{
+		service.CaptchaActionLogin:         {"/login", gin.H{"username": "tester", "password": testPassword}},
+		service.CaptchaActionRegister:      {"/register", gin.H{"username": "newcomer", "password": testPassword, "email": "newcomer@example.com"}},
+		service.CaptchaActionPasswordReset: {"/password/reset", gin.H{"email": "tester@example.com"}},
+	}
+	for _, setting := range []string{"login,register,password_reset", "Register", " login , password_reset", ""} {
+		setTestSettings(t, map[string]string{consts.ConfigCaptchaRequiredActions: setting})
+		for action, req := range requests {
+			_, body := doJSON(t, r, http.MethodPost, req.path, req.payload)
+			rejected := body["error"] == service.ErrCaptchaInvalid.Error()
+			if want := service.CaptchaRequired(action); rejected != want {
+				t.Errorf("setting %q, action %s: captcha rejected = %v, want %v (body %v)", setting, action, rejected, want, body)
+			}
+		}
+	}
+}
+
+func TestImageCaptchaCannotBeReplayed(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaRequiredActions: "login"})
+	createTestUser(t, "tester")
+	r := gin.New()
+	r.POST("/login", Login)
+
+	login := func(id, answer string) (int, map[string]any) {
+		w, body := doJSON(t, r, http.MethodPost, "/login", gin.H{
+			"username": "tester", "password": testPassword, "captcha_id": id, "captcha_answer": answer,
+		})
+		return w.Code, body
+	}
+
+	id, _, answer, err := utils.MakeCaptcha()
+	if err != nil {
+		t.Fatalf("make captcha: %v", err)
+	}
+	if code, body := login(id, answer); code != http.StatusOK {
+		t.Fatalf("first use: status = %d (body %v)", code, body)
+	}
+	if code, _ := login(id, answer); code != http.StatusBadRequest {
+		t.Fatalf("replayed captcha: status = %d, want 400", code)
+	}
+
+	// 答错后验证码同样作废，不能再用正确答案重试
+	id, _, answer, _ = utils.MakeCaptcha()
+	login(id, "wrong")
+	if code, _ := login(id, answer); code != http.StatusBadRequest {
+		t.Fatalf("captcha reused after a wrong answer: status = %d, want 400", code)
+	}
+}
+
+func TestCaptchaBypassDoesNotApplyToAnonymousUpload(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigAllowAnonymousUpload: "true",
+		consts.ConfigCaptchaBypassIPs:     "203.0.113.0/24",
+	})
+
+	r := gin.New()
+	r.POST("/anonymous/upload", withRemoteAddr("203.0.113.9:4000"), UploadAnonymousImage)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, uploadRequest(t, "/anonymous/upload", 8))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), service.ErrCaptchaInvalid.Error()) {
+		t.Fatalf("anonymous upload from a bypass IP without captcha: status = %d body = %s, want captcha error", w.Code, w.Body.String())
+	}
+	var count int64
+	db.DB.Model(&model.Image{}).Count(&count)
+	if count != 0 {
+		t.Fatal("anonymous upload should not be stored without a captcha")
+	}
+}
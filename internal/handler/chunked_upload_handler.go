@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"perfect-pic-server/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type initUploadRequest struct {
+	Filename  string `json:"filename" binding:"required"`
+	TotalSize int64  `json:"total_size" binding:"required"`
+}
+
+// InitChunkedUpload 创建分片上传会话
+func InitChunkedUpload(c *gin.Context) {
+	var req initUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	uploadID, err := service.InitUpload(c.GetUint("id"), req.TotalSize, req.Filename)
+	if err != nil {
+		writeChunkUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"upload_id": uploadID})
+}
+
+// GetChunkedUpload 查询分片上传进度 (已接收的分片序号)，用于断线后续传
+func GetChunkedUpload(c *gin.Context) {
+	status, err := service.GetUploadStatus(c.GetUint("id"), c.Param("upload_id"))
+	if err != nil {
+		writeChunkUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// UploadChunk 上传一个分片，请求体为分片的原始字节
+func UploadChunk(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分片序号"})
+		return
+	}
+
+	status, err := service.UploadChunk(c.GetUint("id"), c.Param("upload_id"), index, c.Request.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "分片过大"})
+			return
+		}
+		writeChunkUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// CompleteChunkedUpload 合并分片并保存图片，响应与普通上传一致
+func CompleteChunkedUpload(c *gin.Context) {
+	uid := c.GetUint("id")
+	imageRecord, url, err := service.CompleteUpload(c.Request.Context(), uid, c.Param("upload_id"))
+	if err != nil {
+		if errors.Is(err, service.ErrUploadNotFound) || errors.Is(err, service.ErrUploadIncomplete) {
+			writeChunkUploadError(c, err)
+			return
+		}
+		writeUploadError(c, err)
+		return
+	}
+	writeUploadSuccess(c, uid, imageRecord, url)
+}
+
+// AbortChunkedUpload 取消分片上传并删除已上传的分片
+func AbortChunkedUpload(c *gin.Context) {
+	if err := service.AbortUpload(c.GetUint("id"), c.Param("upload_id")); err != nil {
+		writeChunkUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "已取消上传"})
+}
+
+func writeChunkUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTooManyUploads):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidChunk), errors.Is(err, service.ErrUploadIncomplete):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		writeUploadError(c, err)
+	}
+}
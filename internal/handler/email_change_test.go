@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tokenParamPattern = regexp.MustCompile(`token=([A-Za-z0-9._-]+)`)
+
+func newEmailChangeRouter(userID uint) *gin.Engine {
+	r := gin.New()
+	r.POST("/user/email", withUser(userID), RequestUpdateEmail)
+	r.POST("/auth/email-change-verify", EmailChangeVerify)
+	r.POST("/auth/email-change-cancel", EmailChangeCancel)
+	return r
+}
+
+// requestEmailChange 发起修改邮箱，返回发送到新邮箱的确认 Token 与发送到旧邮箱的撤销 Token
+func requestEmailChange(t *testing.T, r http.Handler, mailer *testMailer, oldEmail, newEmail string) (confirm, cancel string) {
+	t.Helper()
+	before := len(mailer.Sent())
+	w, body := doJSON(t, r, http.MethodPost, "/user/email", gin.H{"password": testPassword, "new_email": newEmail})
+	if w.Code != http.StatusOK {
+		t.Fatalf("request email change: status = %d (body %v)", w.Code, body)
+	}
+	for _, m := range mailer.Sent()[before:] {
+		match := tokenParamPattern.FindStringSubmatch(m.Text)
+		if match == nil {
+			t.Fatalf("no token link in email to %s: %s", m.To, m.Text)
+		}
+		switch m.To {
+		case newEmail:
+			confirm = match[1]
+		case oldEmail:
+			cancel = match[1]
+		}
+	}
+	if confirm == "" || cancel == "" {
+		t.Fatalf("expected confirmation and notice emails, got %+v", mailer.Sent()[before:])
+	}
+	return confirm, cancel
+}
+
+func TestEmailChangeKeepsOldEmailUntilConfirmed(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "mover")
+	r := newEmailChangeRouter(user.ID)
+
+	confirm, _ := requestEmailChange(t, r, mailer, "mover@example.com", "moved@example.com")
+
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Email != "mover@example.com" || stored.PendingEmail != "moved@example.com" {
+		t.Fatalf("email should stay unchanged until confirmed: email=%q pending=%q", stored.Email, stored.PendingEmail)
+	}
+
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/email-change-verify", gin.H{"token": confirm}); w.Code != http.StatusOK {
+		t.Fatalf("confirm: status = %d (body %v)", w.Code, body)
+	}
+	db.DB.First(&stored, user.ID)
+	if stored.Email != "moved@example.com" || stored.PendingEmail != "" {
+		t.Fatalf("email not changed after confirmation: email=%q pending=%q", stored.Email, stored.PendingEmail)
+	}
+}
+
+func TestEmailChangeCancelBeforeConfirmation(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "victim")
+	r := newEmailChangeRouter(user.ID)
+
+	confirm, cancel := requestEmailChange(t, r, mailer, "victim@example.com", "attacker@example.com")
+
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/email-change-cancel", gin.H{"token": cancel}); w.Code != http.StatusOK {
+		t.Fatalf("cancel: status = %d (body %v)", w.Code, body)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Email != "victim@example.com" || stored.PendingEmail != "" || stored.TokenVersion == user.TokenVersion {
+		t.Fatalf("cancel should clear pending email and revoke tokens: %+v", stored)
+	}
+
+	if w, _ := doJSON(t, r, http.MethodPost, "/auth/email-change-verify", gin.H{"token": confirm}); w.Code != http.StatusBadRequest {
+		t.Fatalf("cancelled change should not be confirmable, status = %d", w.Code)
+	}
+}
+
+func TestEmailChangeCancelRevertsConfirmedChange(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "victim")
+	r := newEmailChangeRouter(user.ID)
+
+	confirm, cancel := requestEmailChange(t, r, mailer, "victim@example.com", "attacker@example.com")
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/email-change-verify", gin.H{"token": confirm}); w.Code != http.StatusOK {
+		t.Fatalf("confirm: status = %d (body %v)", w.Code, body)
+	}
+
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/email-change-cancel", gin.H{"token": cancel}); w.Code != http.StatusOK {
+		t.Fatalf("cancel: status = %d (body %v)", w.Code, body)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Email != "victim@example.com" {
+		t.Fatalf("confirmed change should be reverted, email = %q", stored.Email)
+	}
+
+	// 撤销链接只能使用一次
+	if w, _ := doJSON(t, r, http.MethodPost, "/auth/email-change-cancel", gin.H{"token": cancel}); w.Code != http.StatusBadRequest {
+		t.Fatalf("reused cancel link: status = %d, want 400", w.Code)
+	}
+}
+
+func TestEmailChangeCancelConflictsWhenOldEmailTaken(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "victim")
+	r := newEmailChangeRouter(user.ID)
+
+	confirm, cancel := requestEmailChange(t, r, mailer, "victim@example.com", "attacker@example.com")
+	doJSON(t, r, http.MethodPost, "/auth/email-change-verify", gin.H{"token": confirm})
+	createTestUser(t, "squatter", func(u *model.User) { u.Email = "victim@example.com" })
+
+	if w, _ := doJSON(t, r, http.MethodPost, "/auth/email-change-cancel", gin.H{"token": cancel}); w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", w.Code)
+	}
+}
+
+func TestEmailChangeRejectsEmailInUse(t *testing.T) {
+	setupTestDB(t)
+	useTestMailer(t)
+	user := createTestUser(t, "mover")
+	createTestUser(t, "taken")
+	r := newEmailChangeRouter(user.ID)
+
+	w, body := doJSON(t, r, http.MethodPost, "/user/email", gin.H{"password": testPassword, "new_email": "Taken@Example.com"})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("change to an email in use: status = %d (body %v), want 409", w.Code, body)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.PendingEmail != "" {
+		t.Fatalf("pending email = %q, want none after an early conflict", stored.PendingEmail)
+	}
+}
+
+func TestEmailChangeConfirmConflictsWhenEmailTakenMeanwhile(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "mover")
+	r := newEmailChangeRouter(user.ID)
+
+	confirm, _ := requestEmailChange(t, r, mailer, "mover@example.com", "wanted@example.com")
+	// 发起修改后、确认前，其他用户注册了该邮箱 (大小写不同)
+	createTestUser(t, "racer", func(u *model.User) { u.Email = "Wanted@Example.com" })
+
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/email-change-verify", gin.H{"token": confirm}); w.Code != http.StatusConflict {
+		t.Fatalf("confirm: status = %d (body %v), want 409", w.Code, body)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Email != "mover@example.com" {
+		t.Fatalf("email = %q, want unchanged after a conflict", stored.Email)
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz 存活探针，进程正常运行时始终返回 200，关闭过程中返回 503
+func Healthz(c *gin.Context) {
+	if service.IsShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz 就绪探针，检查数据库与存储目录，任一依赖不可用时返回 503 并列出失败项
+func Readyz(c *gin.Context) {
+	ready, checks := service.CheckReadiness(c.Request.Context())
+	if !ready {
+		failed := make([]string, 0)
+		for _, check := range checks {
+			if !check.OK {
+				failed = append(failed, check.Name)
+			}
+		}
+		body := gin.H{"status": "unavailable", "checks": checks, "failed": failed}
+		if service.IsShuttingDown() {
+			body["status"] = "shutting_down"
+		}
+		c.JSON(http.StatusServiceUnavailable, body)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+}
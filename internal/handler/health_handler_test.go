@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"perfect-pic-server/internal/db"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadyzReportsFailedDependency(t *testing.T) {
+	setupTestDB(t)
+	if err := os.MkdirAll("uploads/imgs", 0755); err != nil {
+		t.Fatalf("create upload root: %v", err)
+	}
+	r := gin.New()
+	r.GET("/readyz", Readyz)
+	r.GET("/healthz", Healthz)
+
+	if w, _ := doJSON(t, r, http.MethodGet, "/readyz", nil); w.Code != http.StatusOK {
+		t.Fatalf("readyz status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	_ = sqlDB.Close()
+
+	w, body := doJSON(t, r, http.MethodGet, "/readyz", nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz status = %d, want 503", w.Code)
+	}
+	failed, _ := body["failed"].([]any)
+	if len(failed) != 1 || failed[0] != "database" {
+		t.Fatalf("failed = %v, want [database]", body["failed"])
+	}
+	if strings.Contains(w.Body.String(), "closed") || strings.Contains(w.Body.String(), "sql:") {
+		t.Fatalf("readyz leaked error details: %s", w.Body.String())
+	}
+
+	// 存活探针不依赖数据库
+	if w, _ := doJSON(t, r, http.MethodGet, "/healthz", nil); w.Code != http.StatusOK {
+		t.Fatalf("healthz status = %d, want 200", w.Code)
+	}
+}
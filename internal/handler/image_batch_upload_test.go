@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/middleware"
+	"perfect-pic-server/internal/service"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// batchUploadRequest 构造包含 n 张 PNG 的批量上传请求
+func batchUploadRequest(t *testing.T, n int) *http.Request {
+	t.Helper()
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i := 0; i < n; i++ {
+		part, err := mw.CreateFormFile("files", "f"+strconv.Itoa(i)+".png")
+		if err != nil {
+			t.Fatalf("create form file: %v", err)
+		}
+		part.Write(img.Bytes())
+	}
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload/batch", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestBatchUploadChargesRateLimitPerFile(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigRateLimitEnabled:     "true",
+		consts.ConfigRateLimitUploadRPS:   "0.01",
+		consts.ConfigRateLimitUploadBurst: "3",
+	})
+	user := createTestUser(t, "batchy")
+	if got := service.GetMaxBatchUploadFiles(); got != 3 {
+		t.Fatalf("max batch files = %d, want the upload burst 3", got)
+	}
+
+	r := gin.New()
+	uploadLimiter := middleware.RateLimitMiddleware(consts.ConfigRateLimitUploadRPS, consts.ConfigRateLimitUploadBurst)
+	r.POST("/upload/batch", withUser(user.ID), middleware.BatchUploadBodyLimitMiddleware(),
+		middleware.BatchUploadRateCostMiddleware(), uploadLimiter, UploadImages)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, batchUploadRequest(t, 2))
+	if w.Code != http.StatusOK {
+		t.Fatalf("first batch status = %d body = %s", w.Code, w.Body.String())
+	}
+
+	// 两个文件已计入两次，只剩一次额度
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, batchUploadRequest(t, 2))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second batch status = %d, want 429", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, batchUploadRequest(t, 1))
+	if w.Code != http.StatusOK {
+		t.Fatalf("single file status = %d body = %s", w.Code, w.Body.String())
+	}
+}
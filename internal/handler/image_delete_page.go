@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageDeletePage 删除链接确认页与结果页的内容
+type imageDeletePage struct {
+	SiteName string
+	Filename string // 待删除图片的文件名，非空时展示确认按钮
+	Message  string // 结果或错误提示
+}
+
+// imageDeletePageTemplate 确认页提交的表单不指定 action，POST 回当前地址
+var imageDeletePageTemplate = template.Must(template.New("image_delete").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>删除图片 - {{.SiteName}}</title>
+<style>
+body{font-family:system-ui,sans-serif;max-width:28rem;margin:4rem auto;padding:0 1rem;color:#222}
+button{background:#d93025;color:#fff;border:0;border-radius:4px;padding:.6rem 1.2rem;font-size:1rem;cursor:pointer}
+</style>
+</head>
+<body>
+<h1>删除图片</h1>
+{{if .Filename}}<p>确认删除图片 <strong>{{.Filename}}</strong>？删除后无法恢复。</p>
+<form method="post"><button type="submit">确认删除</button></form>
+{{else}}<p>{{.Message}}</p>
+{{end}}</body>
+</html>
+`))
+
+// ConfirmImageDeleteByLink 在浏览器中打开删除链接时展示确认页，不删除图片
+// 链接预览、预取等自动发出的 GET 请求不会误删图片，确认后由 DeleteImageByLink 处理
+func ConfirmImageDeleteByLink(c *gin.Context) {
+	image, err := service.CheckImageDeleteToken(c.Param("token"))
+	if err != nil {
+		status, message := deleteByTokenStatus(err)
+		renderImageDeletePage(c, status, imageDeletePage{Message: message})
+		return
+	}
+	filename := image.OriginalFilename
+	if filename == "" {
+		filename = image.Filename
+	}
+	renderImageDeletePage(c, http.StatusOK, imageDeletePage{Filename: filename})
+}
+
+// renderImageDeletePage 渲染删除确认页或结果页
+// 地址中包含删除 Token，禁止缓存与通过 Referer 外泄，页面只允许提交回本站
+func renderImageDeletePage(c *gin.Context, status int, page imageDeletePage) {
+	page.SiteName = service.GetString(consts.ConfigSiteName)
+	var buf bytes.Buffer
+	if err := imageDeletePageTemplate.Execute(&buf, page); err != nil {
+		log.Printf("Render image delete page error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "页面渲染失败"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
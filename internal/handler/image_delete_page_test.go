@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createDeletableImage 写入一张带删除 Token 的图片，返回删除链接中的 Token
+func createDeletableImage(t *testing.T) (model.Image, string) {
+	t.Helper()
+	user := createTestUser(t, "alice")
+	img := createServedImage(t, user.ID, "2026/del.png", []byte("png-bytes"), nil)
+	const secret = "s3cret"
+	sum := sha256.Sum256([]byte(secret))
+	db.DB.Model(&img).Update("delete_token_hash", hex.EncodeToString(sum[:]))
+	return img, fmt.Sprintf("%d.%s", img.ID, secret)
+}
+
+func newImageDeleteRouter() *gin.Engine {
+	r := gin.New()
+	r.GET("/image_delete/:token", ConfirmImageDeleteByLink)
+	r.POST("/image_delete/:token", DeleteImageByLink)
+	r.DELETE("/image_delete/:token", DeleteImageByLink)
+	return r
+}
+
+func imageExists(id uint) bool {
+	var count int64
+	db.DB.Model(&model.Image{}).Where("id = ?", id).Count(&count)
+	return count > 0
+}
+
+func TestImageDeleteLinkGetOnlyConfirms(t *testing.T) {
+	setupTestDB(t)
+	img, token := createDeletableImage(t)
+	r := newImageDeleteRouter()
+
+	w, _ := doJSON(t, r, http.MethodGet, "/image_delete/"+token, nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status = %d content-type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `<form method="post">`) {
+		t.Fatalf("confirmation form missing: %s", w.Body.String())
+	}
+	if !imageExists(img.ID) {
+		t.Fatal("GET deleted the image")
+	}
+
+	// 从确认页提交表单后删除并返回 HTML 结果页
+	w, _ = doJSON(t, r, http.MethodPost, "/image_delete/"+token, nil, "Accept", "text/html,application/xhtml+xml")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "图片已删除") {
+		t.Fatalf("form submit status = %d body = %s", w.Code, w.Body.String())
+	}
+	if imageExists(img.ID) {
+		t.Fatal("image not deleted after confirmation")
+	}
+}
+
+func TestImageDeleteLinkDeleteMethod(t *testing.T) {
+	setupTestDB(t)
+	img, token := createDeletableImage(t)
+	r := newImageDeleteRouter()
+
+	if w, body := doJSON(t, r, http.MethodDelete, "/image_delete/"+token, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %v", w.Code, body)
+	}
+	if imageExists(img.ID) {
+		t.Fatal("image not deleted")
+	}
+	if w, _ := doJSON(t, r, http.MethodDelete, "/image_delete/"+token, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("second delete status = %d, want 404", w.Code)
+	}
+}
+
+func TestImageDeleteLinkInvalidToken(t *testing.T) {
+	setupTestDB(t)
+	img, _ := createDeletableImage(t)
+	r := newImageDeleteRouter()
+	bad := fmt.Sprintf("%d.wrong", img.ID)
+
+	if w, _ := doJSON(t, r, http.MethodGet, "/image_delete/"+bad, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("GET status = %d, want 403", w.Code)
+	}
+	if w, _ := doJSON(t, r, http.MethodPost, "/image_delete/"+bad, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("POST status = %d, want 403", w.Code)
+	}
+	if !imageExists(img.ID) {
+		t.Fatal("image deleted with an invalid token")
+	}
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"perfect-pic-server/internal/db"
 	"perfect-pic-server/internal/model"
 	"perfect-pic-server/internal/service"
+	"perfect-pic-server/internal/storage"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,38 +36,157 @@ func UploadImage(c *gin.Context) {
 		return
 	}
 
-	imageRecord, url, err := service.ProcessImageUpload(file, uid)
+	expiresAt, ok := parseImageExpiry(c)
+	if !ok {
+		return
+	}
+
+	imageRecord, url, err := service.ProcessImageUpload(c.Request.Context(), file, uid, expiresAt)
 	if err != nil {
-		errStr := err.Error()
-		if strings.Contains(errStr, "存储空间不足") {
-			c.JSON(http.StatusForbidden, gin.H{"error": errStr})
-		} else if strings.Contains(errStr, "不支持的文件类型") || strings.Contains(errStr, "文件大小") {
-			c.JSON(http.StatusBadRequest, gin.H{"error": errStr})
-		} else {
-			// 对于其他错误（包括系统错误），记录日志并返回通用错误信息
-			log.Printf("Upload failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败，请稍后重试"})
+		writeUploadError(c, err)
+		return
+	}
+	writeUploadSuccess(c, uid, imageRecord, url)
+}
+
+// parseImageExpiry 读取表单字段 expires_in (有效期，单位秒)，换算为过期时间 (Unix 秒)
+// 未填写时返回 nil (永久保留)；取值无效时已写入错误响应，ok 为 false
+func parseImageExpiry(c *gin.Context) (expiresAt *int64, ok bool) {
+	raw := strings.TrimSpace(c.PostForm("expires_in"))
+	if raw == "" {
+		return nil, true
+	}
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || seconds <= 0 || seconds > int64(service.MaxImageTTL/time.Second) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("有效期必须为 1 到 %d 之间的秒数", int64(service.MaxImageTTL/time.Second))})
+		return nil, false
+	}
+	at := time.Now().Unix() + seconds
+	return &at, true
+}
+
+// statusClientClosedRequest 客户端在处理完成前断开连接 (沿用 nginx 的 499)
+const statusClientClosedRequest = 499
+
+// writeUploadError 按上传错误类型返回对应的状态码
+func writeUploadError(c *gin.Context, err error) {
+	errStr := err.Error()
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// 客户端已断开，上传已中止且未保存，无需记录为服务端错误
+		c.JSON(statusClientClosedRequest, gin.H{"error": "请求已取消"})
+	} else if errors.Is(err, service.ErrFileTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": service.LocalizeError(err, requestLocale(c))})
+	} else if errors.Is(err, service.ErrStorageQuotaExceeded) {
+		c.JSON(http.StatusForbidden, gin.H{"error": service.LocalizeError(err, requestLocale(c))})
+	} else if errors.Is(err, service.ErrDailyUploadLimit) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": service.LocalizeError(err, requestLocale(c))})
+	} else if errors.Is(err, service.ErrServerBusy) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errStr})
+	} else if errors.Is(err, service.ErrAnonymousUploadLimit) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": errStr})
+	} else if errors.Is(err, service.ErrAnonymousUploadDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errStr})
+	} else if strings.Contains(errStr, "不支持的文件类型") || strings.Contains(errStr, "文件大小") ||
+		strings.Contains(errStr, "像素") || strings.Contains(errStr, "GIF") || strings.Contains(errStr, "文件真实类型") ||
+		strings.Contains(errStr, "图片尺寸") || strings.Contains(errStr, "无法识别文件类型") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errStr})
+	} else {
+		// 对于其他错误（包括系统错误），记录日志并返回通用错误信息
+		log.Printf("Upload failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败，请稍后重试"})
+	}
+}
+
+// writeUploadSuccess 按响应格式 (查询参数 format 或 ConfigUploadResponseStyle) 返回上传成功的响应
+// ShareX 与 PicGo 格式直接作为外链使用，相对地址会补全为完整 URL
+func writeUploadSuccess(c *gin.Context, uid uint, imageRecord *model.Image, url string) {
+	deletionURL := service.ImageDeletionURL(imageRecord)
+	switch service.UploadResponseStyle(c.Query("format")) {
+	case service.UploadResponseStyleShareX:
+		c.JSON(http.StatusOK, ShareXUploadResponse{URL: service.AbsoluteImageURL(url), DeletionURL: deletionURL})
+	case service.UploadResponseStylePicGo:
+		c.JSON(http.StatusOK, PicGoUploadResponse{
+			Success: true,
+			Data:    PicGoUploadResult{ID: imageRecord.ID, URL: service.AbsoluteImageURL(url), DeletionURL: deletionURL},
+		})
+	default:
+		resp := uploadSuccessResponse(uid, imageRecord, url)
+		resp.DeleteToken = imageRecord.DeleteToken
+		resp.DeletionURL = deletionURL
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// uploadSuccessResponse 构造上传成功的响应
+func uploadSuccessResponse(uid uint, imageRecord *model.Image, url string) UploadResponse {
+	resp := UploadResponse{Msg: "上传成功", URL: url, ID: imageRecord.ID}
+	// 图库中存在视觉相似的图片时附带提示，查询失败不影响上传结果
+	// 访客上传的图片共用匿名用户的图库，不提示以免泄露其他访客的图片
+	if imageRecord.UploaderIP != "" {
+		return resp
+	}
+	if similar, err := service.FindSimilarImages(uid, imageRecord.ID, service.DefaultSimilarImageDistance); err != nil {
+		log.Printf("Find similar images error: %v", err)
+	} else if len(similar) > 0 {
+		ids := make([]uint, 0, len(similar))
+		for _, img := range similar {
+			ids = append(ids, img.ID)
 		}
+		resp.PossibleDuplicates = ids
+	}
+	return resp
+}
+
+// UploadImages 批量上传图片 (表单字段 files)，逐个返回处理结果，允许部分成功
+func UploadImages(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择文件"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"msg": "上传成功",
-		"url": url,
-		"id":  imageRecord.ID,
+	userID, exists := c.Get("id")
+	uid, ok := userID.(uint)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未获取到用户信息"})
+		return
+	}
+
+	results, err := service.ProcessImageUploads(c.Request.Context(), form.File["files"], uid)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	succeeded := 0
+	for _, r := range results {
+		if r.Success {
+			succeeded++
+		}
+	}
+
+	c.JSON(http.StatusOK, BatchUploadResponse{
+		Msg:       "批量上传完成",
+		Succeeded: succeeded,
+		Failed:    len(results) - succeeded,
+		Results:   results,
 	})
 }
 
+// GetMyImages 分页获取自己的图片，可按文件名、ID、相册 (album_id)、标签 (tag) 过滤，
+// 或按关键词 (q) 搜索原始文件名与标签
 func GetMyImages(c *gin.Context) {
-	userID, _ := c.Get("id")
-
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("page_size", "10")
-	filename := c.Query("filename")
-	id := c.Query("id")
+	opts, ok := parseImageListOptions(c)
+	if !ok {
+		return
+	}
+	respondImageList(c, opts)
+}
 
-	page, _ := strconv.Atoi(pageStr)
-	pageSize, _ := strconv.Atoi(pageSizeStr)
+// parseImageListOptions 解析图片列表的分页与过滤参数，参数无效时直接返回 400
+func parseImageListOptions(c *gin.Context) (service.ImageListOptions, bool) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 	if page < 1 {
 		page = 1
 	}
@@ -70,33 +194,132 @@ func GetMyImages(c *gin.Context) {
 		pageSize = 10
 	}
 
-	var total int64
-	var images []model.Image
+	opts := service.ImageListOptions{
+		Page:     page,
+		PageSize: pageSize,
+		Filename: c.Query("filename"),
+		Tag:      c.Query("tag"),
+		Query:    c.Query("q"),
+	}
+	if id := c.Query("id"); id != "" {
+		parsed, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片ID"})
+			return opts, false
+		}
+		opts.ID = uint(parsed)
+	}
+	if albumID := c.Query("album_id"); albumID != "" {
+		parsed, err := strconv.ParseUint(albumID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的相册ID"})
+			return opts, false
+		}
+		opts.AlbumID = uint(parsed)
+	}
+	return opts, true
+}
 
-	query := db.DB.Model(&model.Image{}).Where("user_id = ?", userID)
+func respondImageList(c *gin.Context, opts service.ImageListOptions) {
+	images, total, err := service.ListUserImages(c.GetUint("id"), opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取图片列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ImageListResponse{
+		List:     images,
+		Total:    total,
+		Page:     opts.Page,
+		PageSize: opts.PageSize,
+	})
+}
 
-	if filename != "" {
-		query = query.Where("filename LIKE ?", "%"+filename+"%")
+// GetMyImageInfo 获取自己某张图片的元信息 (宽高、大小、类型、访问地址)
+func GetMyImageInfo(c *gin.Context) {
+	userID := c.GetUint("id")
+	imageID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片ID"})
+		return
 	}
-	if id != "" {
-		query = query.Where("id = ?", id)
+
+	info, err := service.GetImageInfo(userID, uint(imageID))
+	if err != nil {
+		if errors.Is(err, service.ErrImageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在或无权访问"})
+			return
+		}
+		log.Printf("Get image info error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取图片信息失败"})
+		return
 	}
 
-	query.Count(&total)
+	c.JSON(http.StatusOK, info)
+}
 
-	result := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&images)
+// GetMyImageExif 获取自己某张图片的 EXIF 拍摄参数，查询参数 gps=true 时包含位置信息
+func GetMyImageExif(c *gin.Context) {
+	userID := c.GetUint("id")
+	imageID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片ID"})
+		return
+	}
+	includeGPS, _ := strconv.ParseBool(c.Query("gps"))
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取图片列表失败"})
+	fields, err := service.GetImageExif(userID, uint(imageID), includeGPS)
+	if err != nil {
+		if errors.Is(err, service.ErrImageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在或无权访问"})
+			return
+		}
+		log.Printf("Get image exif error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取图片 EXIF 失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"list":      images,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	c.JSON(http.StatusOK, gin.H{"exif": fields})
+}
+
+// ReplaceMyImage 用新文件替换自己的图片 (表单字段 file)，访问地址保持不变
+func ReplaceMyImage(c *gin.Context) {
+	userID := c.GetUint("id")
+	imageID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片ID"})
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择文件"})
+		return
+	}
+
+	image, err := service.ReplaceImage(c.Request.Context(), userID, uint(imageID), file)
+	if err != nil {
+		errStr := err.Error()
+		switch {
+		case errors.Is(err, service.ErrImageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在或无权修改"})
+		case errors.Is(err, service.ErrServerBusy):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errStr})
+		case errors.Is(err, service.ErrFileTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": service.LocalizeError(err, requestLocale(c))})
+		case errors.Is(err, service.ErrStorageQuotaExceeded):
+			c.JSON(http.StatusForbidden, gin.H{"error": service.LocalizeError(err, requestLocale(c))})
+		case errors.Is(err, service.ErrImageTypeMismatch), strings.Contains(errStr, "不支持的文件类型"),
+			strings.Contains(errStr, "文件大小"), strings.Contains(errStr, "像素"), strings.Contains(errStr, "GIF"),
+			strings.Contains(errStr, "文件真实类型"), strings.Contains(errStr, "图片尺寸"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": errStr})
+		default:
+			log.Printf("Replace image failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "替换失败，请稍后重试"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ReplaceImageResponse{Msg: "替换成功", Image: image})
 }
 
 // DeleteMyImage 用户删除自己的图片
@@ -111,21 +334,19 @@ func DeleteMyImage(c *gin.Context) {
 		return
 	}
 
-	if err := service.DeleteImage(&image); err != nil {
+	if err := service.DeleteImage(c.Request.Context(), &image); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "删除成功"})
 }
 
 // BatchDeleteMyImages 批量删除用户自己的图片
 func BatchDeleteMyImages(c *gin.Context) {
 	userID, _ := c.Get("id")
 
-	var req struct {
-		Ids []uint `json:"ids" binding:"required"`
-	}
+	var req BatchDeleteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
@@ -153,10 +374,60 @@ func BatchDeleteMyImages(c *gin.Context) {
 		return
 	}
 
-	if err := service.BatchDeleteImages(images); err != nil {
+	if err := service.BatchDeleteImages(c.Request.Context(), images); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "删除成功", "deleted_count": len(images)})
+	c.JSON(http.StatusOK, BatchDeleteResponse{Message: "删除成功", DeletedCount: len(images)})
+}
+
+// DeleteImageByLink 通过上传响应中的删除链接删除图片 (POST/DELETE)，无需登录 (链接本身即凭证)
+// 在浏览器中打开链接 (GET) 只展示确认页，见 ConfirmImageDeleteByLink；从确认页提交时返回 HTML 结果页
+func DeleteImageByLink(c *gin.Context) {
+	_, err := service.DeleteImageByToken(c.Param("token"))
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		status, message := deleteByTokenStatus(err)
+		if err == nil {
+			message = "图片已删除"
+		}
+		renderImageDeletePage(c, status, imageDeletePage{Message: message})
+		return
+	}
+	writeDeleteByTokenResult(c, err)
+}
+
+// DeleteImageByPathToken 处理 DELETE {图片地址}?token=...，Token 必须是为该图片签发的删除 Token
+func DeleteImageByPathToken(c *gin.Context) {
+	key, err := storage.CleanKey(strings.TrimPrefix(c.Param("filepath"), "/"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在或已被删除"})
+		return
+	}
+	_, err = service.DeleteImageByPathToken(key, c.Query("token"))
+	writeDeleteByTokenResult(c, err)
+}
+
+func writeDeleteByTokenResult(c *gin.Context, err error) {
+	status, message := deleteByTokenStatus(err)
+	if err == nil {
+		c.JSON(status, MessageResponse{Message: message})
+		return
+	}
+	c.JSON(status, gin.H{"error": message})
+}
+
+// deleteByTokenStatus 返回按删除 Token 操作的结果对应的状态码与提示
+func deleteByTokenStatus(err error) (int, string) {
+	switch {
+	case err == nil:
+		return http.StatusOK, "删除成功"
+	case errors.Is(err, service.ErrInvalidDeleteToken):
+		return http.StatusForbidden, "删除链接无效"
+	case errors.Is(err, service.ErrImageNotFound):
+		return http.StatusNotFound, "图片不存在或已被删除"
+	default:
+		log.Printf("Delete image by token error: %v", err)
+		return http.StatusInternalServerError, "删除失败"
+	}
 }
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/middleware"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newHotlinkRouter 与 main.go 一致，在图片路由上挂载防盗链中间件
+func newHotlinkRouter() *gin.Engine {
+	r := gin.New()
+	r.GET("/imgs/*filepath", middleware.HotlinkProtection(), ServeImage)
+	return r
+}
+
+func TestHotlinkProtectionOnImageRoute(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	createServedImage(t, user.ID, "2026/a.png", []byte("png-bytes"), nil)
+	createServedImage(t, user.ID, "placeholder.png", []byte("placeholder-bytes"), nil)
+	r := newHotlinkRouter()
+
+	cases := []struct {
+		name         string
+		settings     map[string]string
+		path         string
+		referer      string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:     "own site",
+			path:     "/imgs/2026/a.png",
+			referer:  "http://example.com/gallery",
+			wantCode: http.StatusOK,
+			wantBody: "png-bytes",
+		},
+		{
+			name:     "allowed referer",
+			settings: map[string]string{consts.ConfigHotlinkAllowedReferers: "*.friend.org"},
+			path:     "/imgs/2026/a.png",
+			referer:  "https://blog.friend.org/post",
+			wantCode: http.StatusOK,
+			wantBody: "png-bytes",
+		},
+		{
+			name:     "off-site referer rejected",
+			path:     "/imgs/2026/a.png",
+			referer:  "https://evil.test/page",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:         "off-site referer redirected",
+			settings:     map[string]string{consts.ConfigHotlinkRedirectURL: "https://cdn.example.net/hotlink.png"},
+			path:         "/imgs/2026/a.png",
+			referer:      "https://evil.test/page",
+			wantCode:     http.StatusFound,
+			wantLocation: "https://cdn.example.net/hotlink.png",
+		},
+		{
+			// 占位图本身位于图片路径下时直接返回，不会重定向到自身
+			name:     "placeholder served instead of looping",
+			settings: map[string]string{consts.ConfigHotlinkRedirectURL: "http://example.com/imgs/placeholder.png"},
+			path:     "/imgs/placeholder.png",
+			referer:  "https://evil.test/page",
+			wantCode: http.StatusOK,
+			wantBody: "placeholder-bytes",
+		},
+		{
+			name:     "empty referer allowed by default",
+			path:     "/imgs/2026/a.png",
+			wantCode: http.StatusOK,
+			wantBody: "png-bytes",
+		},
+		{
+			name:     "empty referer rejected when disabled",
+			settings: map[string]string{consts.ConfigHotlinkAllowEmptyReferer: "false"},
+			path:     "/imgs/2026/a.png",
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setTestSettings(t, map[string]string{
+				consts.ConfigHotlinkProtection:        "true",
+				consts.ConfigHotlinkAllowedReferers:   "",
+				consts.ConfigHotlinkAllowEmptyReferer: "true",
+				consts.ConfigHotlinkRedirectURL:       "",
+			})
+			setTestSettings(t, tc.settings)
+
+			var headers []string
+			if tc.referer != "" {
+				headers = []string{"Referer", tc.referer}
+			}
+			w, _ := doJSON(t, r, http.MethodGet, tc.path, nil, headers...)
+			if w.Code != tc.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tc.wantCode, w.Body.String())
+			}
+			if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tc.wantBody)
+			}
+			if got := w.Header().Get("Location"); got != tc.wantLocation {
+				t.Fatalf("location = %q, want %q", got, tc.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/service"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetMyImageInfo(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner")
+	other := createTestUser(t, "other")
+	img := createServedImage(t, owner.ID, "2026/info.png", []byte("png-bytes"), nil)
+	db.DB.Model(&img).Updates(map[string]any{"width": 640, "height": 480, "dominant_color": "#112233"})
+
+	route := func(uid uint) *gin.Engine {
+		r := gin.New()
+		r.GET("/images/:id", withUser(uid), GetMyImageInfo)
+		return r
+	}
+	path := "/images/" + strconv.Itoa(int(img.ID))
+
+	w, body := doJSON(t, route(owner.ID), http.MethodGet, path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("owner status = %d body = %v", w.Code, body)
+	}
+	if body["width"] != float64(640) || body["height"] != float64(480) || body["size"] != float64(len("png-bytes")) ||
+		body["dominant_color"] != "#112233" || body["url"] != service.ImageURLPrefix()+img.Path {
+		t.Fatalf("info = %v", body)
+	}
+
+	// 其他用户的图片与不存在的图片一样返回 404
+	if w, _ := doJSON(t, route(other.ID), http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("other user status = %d, want 404", w.Code)
+	}
+	if w, _ := doJSON(t, route(owner.ID), http.MethodGet, "/images/999999", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("missing image status = %d, want 404", w.Code)
+	}
+	if w, _ := doJSON(t, route(owner.ID), http.MethodGet, "/images/abc", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid id status = %d, want 400", w.Code)
+	}
+}
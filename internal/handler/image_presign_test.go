@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"perfect-pic-server/internal/consts"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useObjectStore 启动一个按路径保存对象的简易对象存储，并将图片存储切换到 OSS 后端
+// 返回值统计对象存储收到的 GET 请求数
+func useObjectStore(t *testing.T) *atomic.Int64 {
+	t.Helper()
+	var gets atomic.Int64
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet, http.MethodHead:
+			if r.Method == http.MethodGet {
+				gets.Add(1)
+			}
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	setTestSettings(t, map[string]string{
+		consts.ConfigStorageBackend:     "oss",
+		consts.ConfigOSSEndpoint:        srv.URL,
+		consts.ConfigOSSBucket:          "bucket",
+		consts.ConfigOSSAccessKeyID:     "ak",
+		consts.ConfigOSSAccessKeySecret: "sk",
+	})
+	return &gets
+}
+
+func TestServeImageRedirectsToPresignedURL(t *testing.T) {
+	setupTestDB(t)
+	useObjectStore(t)
+	setTestSettings(t, map[string]string{consts.ConfigPresignTTLSeconds: "300"})
+	user := createTestUser(t, "alice")
+	createServedImage(t, user.ID, "2026/01/a.png", []byte("png-bytes"), nil)
+	r := newServeRouter()
+
+	before := time.Now().Unix()
+	w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/01/a.png", nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	signed, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse location: %v", err)
+	}
+	q := signed.Query()
+	if signed.Path != "/bucket/2026/01/a.png" || q.Get("Signature") == "" {
+		t.Fatalf("unexpected signed url %q", signed)
+	}
+	expires, err := strconv.ParseInt(q.Get("Expires"), 10, 64)
+	if err != nil || expires < before+300 || expires > time.Now().Unix()+300 {
+		t.Fatalf("Expires = %q, want about 300s from now", q.Get("Expires"))
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "private, max-age=150" {
+		t.Fatalf("Cache-Control = %q, want the redirect cached for half the ttl", cc)
+	}
+
+	// 下载附件需要原始文件名，仍由本服务转发
+	w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/01/a.png?download=1", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "png-bytes" {
+		t.Fatalf("download: status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	// 关闭限时地址后同样转发
+	setTestSettings(t, map[string]string{consts.ConfigPresignTTLSeconds: "0"})
+	w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/01/a.png", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "png-bytes" {
+		t.Fatalf("presign off: status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServeImageLocalStorageStreamsWithPresignEnabled(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigPresignTTLSeconds: "300"})
+	user := createTestUser(t, "alice")
+	createServedImage(t, user.ID, "2026/01/a.png", []byte("png-bytes"), nil)
+
+	w, _ := doJSON(t, newServeRouter(), http.MethodGet, "/imgs/2026/01/a.png", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "png-bytes" {
+		t.Fatalf("status = %d, body = %q; local storage should stream the file", w.Code, w.Body.String())
+	}
+	if loc := w.Header().Get("Location"); loc != "" || strings.Contains(w.Header().Get("Cache-Control"), "max-age=150") {
+		t.Fatalf("local storage should not redirect: %v", w.Header())
+	}
+}
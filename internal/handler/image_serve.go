@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeImage 提供图片文件访问
+// 携带 ?download=1 时以附件形式返回，并使用上传时的原始文件名；
+// 否则请求的 Accept 头包含 image/webp 且存在 WebP 备选文件时返回备选文件
+// 已过期 (尚未被后台任务删除) 的图片返回 404
+func ServeImage(c *gin.Context) {
+	key, err := storage.CleanKey(strings.TrimPrefix(c.Param("filepath"), "/"))
+	if err != nil || service.IsImageExpired(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+
+	store, err := service.ImageStorage()
+	if err != nil {
+		log.Printf("Image storage error: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "存储后端不可用"})
+		return
+	}
+
+	servedKey := negotiateImageKey(c, key)
+
+	// 可随机读取的存储 (如本地磁盘) 直接交给 ServeContent，支持 Range 与条件请求
+	if seeker, ok := store.(storage.SeekableGetter); ok {
+		serveSeekableImage(c, store, seeker, key, servedKey)
+		return
+	}
+
+	// 支持限时地址的对象存储直接重定向，节省本服务带宽 (下载附件需设置文件名，仍由本服务转发)
+	// SVG 需附带 CSP 响应头，始终由本服务转发
+	if c.Query("download") != "1" && !isSVGKey(key) {
+		signedURL, ttl, ok, err := service.PresignImageURL(store, servedKey)
+		if err != nil {
+			log.Printf("Presign image url error: %v", err)
+		} else if ok {
+			// 重定向本身只能在签名有效期内缓存，覆盖静态资源的长缓存设置
+			c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(ttl.Seconds())/2))
+			service.RecordImageView(key, c.Request.Method, c.Request.UserAgent())
+			c.Redirect(http.StatusFound, signedURL)
+			return
+		}
+	}
+
+	// 对象存储使用记录中的内容哈希作为 ETag，命中条件请求时无需再从后端读取文件
+	etag, modTime := storedImageValidators(key)
+	if etag != "" && servedKey != key {
+		etag = strings.TrimSuffix(etag, `"`) + `-webp"`
+	}
+	setImageCacheHeaders(c, etag, modTime)
+	if imageNotModified(c.Request, etag, modTime) {
+		service.RecordImageView(key, c.Request.Method, c.Request.UserAgent())
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	r, err := store.Get(c.Request.Context(), servedKey)
+	if errors.Is(err, storage.ErrNotFound) && servedKey != key {
+		// 备选文件缺失时退回原图
+		servedKey = key
+		c.Header("ETag", "")
+		r, err = store.Get(c.Request.Context(), key)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+			return
+		}
+		log.Printf("Get stored image error: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "读取图片失败"})
+		return
+	}
+	defer func() { _ = r.Close() }()
+	service.RecordImageView(key, c.Request.Method, c.Request.UserAgent())
+
+	setImageDownloadHeader(c, key, path.Base(key))
+	contentType := mime.TypeByExtension(path.Ext(servedKey))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if isSVGKey(key) {
+		contentType = svgContentType
+		setSVGSecurityHeaders(c)
+	}
+	c.DataFromReader(http.StatusOK, -1, contentType, r, nil)
+}
+
+// serveSeekableImage 发送可随机读取的图片；开启 ConfigInternalRedirectMode 时只设置响应头，
+// 文件内容 (包括 Range 与条件请求) 交由前端服务器处理
+func serveSeekableImage(c *gin.Context, store storage.Storage, seeker storage.SeekableGetter, key, servedKey string) {
+	f, info, err := seeker.GetSeeker(c.Request.Context(), servedKey)
+	if errors.Is(err, storage.ErrNotFound) && servedKey != key {
+		// 备选文件缺失时退回原图
+		servedKey = key
+		f, info, err = seeker.GetSeeker(c.Request.Context(), key)
+	}
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Get stored image error: %v", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	// 只支持单个区间，多区间请求忽略 Range 返回完整文件 (RFC 7233 允许服务端忽略 Range)
+	if strings.Contains(c.Request.Header.Get("Range"), ",") {
+		c.Request.Header.Del("Range")
+	}
+
+	service.RecordImageView(key, c.Request.Method, c.Request.UserAgent())
+	setImageDownloadHeader(c, key, path.Base(key))
+	if isSVGKey(key) {
+		c.Header("Content-Type", svgContentType)
+		setSVGSecurityHeaders(c)
+	}
+
+	name, value, ok, err := service.InternalRedirectHeader(store, servedKey)
+	if err != nil {
+		log.Printf("Internal redirect error: %v", err)
+	} else if ok {
+		if c.Writer.Header().Get("Content-Type") == "" {
+			if contentType := mime.TypeByExtension(path.Ext(servedKey)); contentType != "" {
+				c.Header("Content-Type", contentType)
+			}
+		}
+		setImageCacheHeaders(c, "", time.Time{})
+		c.Header(name, value)
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	// ServeContent 会根据 ETag 与修改时间处理 If-None-Match / If-Modified-Since 并返回 304，
+	// 对 Range 请求返回 206 与 Content-Range
+	setImageCacheHeaders(c, fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size), time.Time{})
+	http.ServeContent(c.Writer, c.Request, path.Base(servedKey), info.ModTime, f)
+}
+
+// negotiateImageKey 根据 Accept 头选择返回原图还是 WebP 备选文件，返回实际读取的存储键。
+// 可能存在备选文件的类型 (JPEG/PNG/BMP) 总是附带 Vary: Accept，避免共享缓存把 WebP 返回给不支持的客户端
+func negotiateImageKey(c *gin.Context, key string) string {
+	switch utils.ImageTypeForExt(strings.ToLower(path.Ext(key))) {
+	case "jpg", "png", "bmp":
+	default:
+		return key
+	}
+	c.Writer.Header().Add("Vary", "Accept")
+	if c.Query("download") == "1" || !acceptsWebP(c.GetHeader("Accept")) {
+		return key
+	}
+	var image model.Image
+	if err := db.DB.Select("webp_path").Where("path = ?", key).First(&image).Error; err != nil || image.WebpPath == "" {
+		return key
+	}
+	altKey, err := storage.CleanKey(image.WebpPath)
+	if err != nil {
+		return key
+	}
+	return altKey
+}
+
+// acceptsWebP 判断 Accept 头是否接受 image/webp (忽略 q=0 的条目，不处理 image/* 等通配)
+func acceptsWebP(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != "image/webp" {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+const (
+	svgContentType = "image/svg+xml"
+	// svgContentSecurityPolicy 直接打开 SVG 时禁止脚本与一切外部资源，只允许内联样式与 data: 位图
+	svgContentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; img-src data:; sandbox"
+)
+
+// isSVGKey 判断存储键是否为 SVG 图片
+func isSVGKey(key string) bool {
+	return strings.EqualFold(path.Ext(key), ".svg")
+}
+
+// setSVGSecurityHeaders 为 SVG 响应设置严格的 CSP 并禁止 MIME 嗅探。
+// 上传时已清理过脚本与外部引用，这里作为纵深防御
+func setSVGSecurityHeaders(c *gin.Context) {
+	c.Header("Content-Security-Policy", svgContentSecurityPolicy)
+	c.Header("X-Content-Type-Options", "nosniff")
+}
+
+// setImageDownloadHeader 携带 ?download=1 时设置附件下载头，优先使用上传时的原始文件名
+func setImageDownloadHeader(c *gin.Context, key, fallbackName string) {
+	if c.Query("download") != "1" {
+		return
+	}
+	var image model.Image
+	name := fallbackName
+	if err := db.DB.Select("filename", "original_filename").
+		Where("path = ?", key).First(&image).Error; err == nil && image.OriginalFilename != "" {
+		name = image.OriginalFilename
+	}
+	c.Header("Content-Disposition", utils.ContentDisposition("attachment", name))
+}
+
+// storedImageValidators 根据图片记录生成 ETag (内容哈希) 与最后修改时间，记录不存在时均为空值
+func storedImageValidators(key string) (string, time.Time) {
+	var image model.Image
+	if err := db.DB.Select("hash", "uploaded_at", "updated_at").
+		Where("path = ?", key).First(&image).Error; err != nil {
+		return "", time.Time{}
+	}
+	var etag string
+	if image.Hash != "" {
+		etag = `"` + image.Hash + `"`
+	}
+	modified := image.UpdatedAt
+	if modified == 0 {
+		modified = image.UploadedAt
+	}
+	var modTime time.Time
+	if modified > 0 {
+		modTime = time.Unix(modified, 0)
+	}
+	return etag, modTime
+}
+
+// setImageCacheHeaders 设置 ETag、Last-Modified 以及 Cache-Control。
+// 替换图片后地址不变，因此默认 (ConfigImageCacheMaxAge 为 0) 要求客户端每次使用前按 ETag 重新验证；
+// 大于 0 时允许缓存 max-age 秒，过期后同样需要重新验证
+func setImageCacheHeaders(c *gin.Context, etag string, modTime time.Time) {
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !modTime.IsZero() {
+		c.Header("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if maxAge := service.GetInt(consts.ConfigImageCacheMaxAge); maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d, must-revalidate", maxAge))
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+}
+
+// imageNotModified 判断条件请求是否命中缓存。
+// 与 RFC 7232 一致，携带 If-None-Match 时忽略 If-Modified-Since
+func imageNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if modTime.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(since)
+}
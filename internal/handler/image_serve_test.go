@@ -0,0 +1,404 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// putStoredFile 在当前图片存储中写入文件
+func putStoredFile(t *testing.T, key string, content []byte) {
+	t.Helper()
+	store, err := service.ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	if err := store.Put(context.Background(), key, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("put %s: %v", key, err)
+	}
+}
+
+// createServedImage 写入一张图片记录与文件，webp 非 nil 时同时写入 WebP 备选文件
+func createServedImage(t *testing.T, userID uint, key string, content, webp []byte) model.Image {
+	t.Helper()
+	img := model.Image{Filename: key, Path: key, Size: int64(len(content)), MimeType: ".png", UploadedAt: time.Now().Unix(), UserID: userID}
+	putStoredFile(t, key, content)
+	if webp != nil {
+		img.WebpPath = service.WebPAltKey(key)
+		putStoredFile(t, img.WebpPath, webp)
+	}
+	if err := db.DB.Create(&img).Error; err != nil {
+		t.Fatalf("create image: %v", err)
+	}
+	return img
+}
+
+func newServeRouter() *gin.Engine {
+	r := gin.New()
+	r.GET("/imgs/*filepath", ServeImage)
+	r.HEAD("/imgs/*filepath", ServeImage)
+	return r
+}
+
+func TestServeImageNegotiatesWebP(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	createServedImage(t, user.ID, "2026/a.png", []byte("png-bytes"), []byte("webp-bytes"))
+	r := newServeRouter()
+
+	cases := []struct {
+		name, path, accept, want string
+	}{
+		{"webp accepted", "/imgs/2026/a.png", "image/avif,image/webp,*/*;q=0.8", "webp-bytes"},
+		{"no accept", "/imgs/2026/a.png", "", "png-bytes"},
+		{"wildcard only", "/imgs/2026/a.png", "image/*", "png-bytes"},
+		{"webp refused", "/imgs/2026/a.png", "image/webp;q=0", "png-bytes"},
+		{"download", "/imgs/2026/a.png?download=1", "image/webp", "png-bytes"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, _ := doJSON(t, r, http.MethodGet, tc.path, nil, "Accept", tc.accept)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			if got := w.Body.String(); got != tc.want {
+				t.Fatalf("body = %q, want %q", got, tc.want)
+			}
+			if vary := w.Header().Values("Vary"); len(vary) == 0 {
+				t.Fatal("Vary: Accept missing")
+			}
+		})
+	}
+}
+
+func TestServeImageFallsBackWhenWebPMissing(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	img := createServedImage(t, user.ID, "2026/b.png", []byte("png-bytes"), nil)
+	// 记录指向的备选文件不存在
+	db.DB.Model(&img).Update("webp_path", service.WebPAltKey(img.Path))
+
+	w, _ := doJSON(t, newServeRouter(), http.MethodGet, "/imgs/2026/b.png", nil, "Accept", "image/webp")
+	if w.Code != http.StatusOK || w.Body.String() != "png-bytes" {
+		t.Fatalf("status = %d body = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServeImageRequiresRevalidation(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "carol")
+	createServedImage(t, user.ID, "2026/c.png", []byte("png-bytes"), nil)
+	r := newServeRouter()
+
+	w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("Cache-Control = %q, want no-cache", cc)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag missing")
+	}
+
+	// 替换文件后旧 ETag 不再命中，客户端重新验证时拿到新内容
+	time.Sleep(10 * time.Millisecond)
+	putStoredFile(t, "2026/c.png", []byte("replaced-bytes"))
+	w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil, "If-None-Match", etag)
+	if w.Code != http.StatusOK || w.Body.String() != "replaced-bytes" {
+		t.Fatalf("revalidate status = %d body = %q", w.Code, w.Body.String())
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigImageCacheMaxAge: "60"})
+	w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil)
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=60, must-revalidate" {
+		t.Fatalf("Cache-Control = %q", cc)
+	}
+}
+
+func TestServeImageNotModified(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "carol")
+	createServedImage(t, user.ID, "2026/c.png", []byte("png-bytes"), nil)
+	r := newServeRouter()
+
+	w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("missing validators: %v", w.Header())
+	}
+
+	for _, header := range [][]string{
+		{"If-None-Match", etag},
+		{"If-None-Match", `"other", ` + etag},
+		{"If-Modified-Since", lastModified},
+	} {
+		w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil, header...)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Fatalf("%s: status = %d, body = %q; want empty 304", header[0], w.Code, w.Body.String())
+		}
+	}
+
+	w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil, "If-None-Match", `"stale"`)
+	if w.Code != http.StatusOK || w.Body.String() != "png-bytes" {
+		t.Fatalf("stale etag: status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServeRemoteImageNotModifiedSkipsFetch(t *testing.T) {
+	setupTestDB(t)
+	gets := useObjectStore(t)
+	user := createTestUser(t, "carol")
+	img := createServedImage(t, user.ID, "2026/c.png", []byte("png-bytes"), nil)
+	db.DB.Model(&img).Update("hash", "abc123")
+	r := newServeRouter()
+
+	w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"abc123"` {
+		t.Fatalf("status = %d, ETag = %q", w.Code, w.Header().Get("ETag"))
+	}
+	fetched := gets.Load()
+
+	w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil, "If-None-Match", `"abc123"`)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("status = %d, body = %q; want empty 304", w.Code, w.Body.String())
+	}
+	if gets.Load() != fetched {
+		t.Fatal("a conditional hit should not fetch the object from storage")
+	}
+}
+
+func TestServeImageRange(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "carol")
+	content := []byte("0123456789abcdefghij")
+	createServedImage(t, user.ID, "2026/c.png", content, nil)
+	r := newServeRouter()
+
+	w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil, "Range", "bytes=0-9")
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", w.Code)
+	}
+	if w.Body.String() != "0123456789" {
+		t.Fatalf("body = %q, want the first 10 bytes", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 0-9/20" {
+		t.Fatalf("Content-Range = %q", got)
+	}
+	if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Fatalf("Accept-Ranges = %q", got)
+	}
+
+	w, _ = doJSON(t, r, http.MethodGet, "/imgs/2026/c.png", nil, "Range", "bytes=0-1,5-6")
+	if w.Code != http.StatusOK || w.Body.String() != string(content) {
+		t.Fatalf("multi-range: status = %d, body = %q; want 200 with the full file", w.Code, w.Body.String())
+	}
+}
+
+func TestServeSVGSetsSecurityHeaders(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "artist")
+	createServedImage(t, user.ID, "2026/logo.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), nil)
+
+	w, _ := doJSON(t, newServeRouter(), http.MethodGet, "/imgs/2026/logo.svg", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != svgContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, svgContentType)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != svgContentSecurityPolicy {
+		t.Fatalf("Content-Security-Policy = %q", got)
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Fatalf("X-Content-Type-Options = %q", got)
+	}
+}
+
+func TestServeImageInternalRedirect(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	createServedImage(t, user.ID, "2026/01/a b.png", []byte("png-bytes"), nil)
+	r := newServeRouter()
+
+	cases := []struct {
+		mode, prefix, header, want string
+	}{
+		{mode: service.InternalRedirectNginx, prefix: "/_protected_imgs/", header: "X-Accel-Redirect", want: "/_protected_imgs/2026/01/a%20b.png"},
+		{mode: service.InternalRedirectNginx, prefix: "internal", header: "X-Accel-Redirect", want: "/internal/2026/01/a%20b.png"},
+		{mode: service.InternalRedirectApache, header: "X-Sendfile", want: "2026/01/a b.png"},
+	}
+	for _, tc := range cases {
+		setTestSettings(t, map[string]string{
+			consts.ConfigInternalRedirectMode:   tc.mode,
+			consts.ConfigInternalRedirectPrefix: tc.prefix,
+		})
+		w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/01/a%20b.png", nil)
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Fatalf("%s: status = %d, body = %q; want empty 200", tc.mode, w.Code, w.Body.String())
+		}
+		got := w.Header().Get(tc.header)
+		if tc.mode == service.InternalRedirectApache {
+			if !filepath.IsAbs(got) || !strings.HasSuffix(filepath.ToSlash(got), tc.want) {
+				t.Fatalf("X-Sendfile = %q, want an absolute path ending in %q", got, tc.want)
+			}
+		} else if got != tc.want {
+			t.Fatalf("%s = %q, want %q", tc.header, got, tc.want)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+			t.Fatalf("Content-Type = %q, want image/png", ct)
+		}
+	}
+
+	// 文件不存在时仍由本服务返回 404
+	if w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/01/missing.png", nil); w.Code != http.StatusNotFound || w.Header().Get("X-Sendfile") != "" {
+		t.Fatalf("missing file: status = %d, headers = %v", w.Code, w.Header())
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigInternalRedirectMode: service.InternalRedirectOff})
+	if w, _ := doJSON(t, r, http.MethodGet, "/imgs/2026/01/a%20b.png", nil); w.Body.String() != "png-bytes" {
+		t.Fatalf("off mode body = %q", w.Body.String())
+	}
+}
+
+func TestServeImageDownloadUsesOriginalFilename(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	ascii := createServedImage(t, user.ID, "2026/a1b2c3.png", []byte("png-bytes"), nil)
+	unicode := createServedImage(t, user.ID, "2026/d4e5f6.png", []byte("png-bytes"), nil)
+	createServedImage(t, user.ID, "2026/g7h8i9.png", []byte("png-bytes"), nil)
+	db.DB.Model(&ascii).Update("original_filename", "holiday.png")
+	db.DB.Model(&unicode).Update("original_filename", "假期.png")
+	r := newServeRouter()
+
+	cases := []struct {
+		name, path, want string
+	}{
+		{"ascii", "/imgs/2026/a1b2c3.png?download=1", `attachment; filename="holiday.png"`},
+		{"non-ascii", "/imgs/2026/d4e5f6.png?download=1", `attachment; filename="__.png"; filename*=UTF-8''%E5%81%87%E6%9C%9F.png`},
+		{"no original name", "/imgs/2026/g7h8i9.png?download=1", `attachment; filename="g7h8i9.png"`},
+		{"inline", "/imgs/2026/a1b2c3.png", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, _ := doJSON(t, r, http.MethodGet, tc.path, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Disposition"); got != tc.want {
+				t.Fatalf("Content-Disposition = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUploadedFilenameIsSanitizedForDownload(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	r := newServeRouter()
+	r.POST("/upload", withUser(user.ID), UploadImage)
+
+	cases := []struct {
+		filename, want string
+	}{
+		{`..\..\etc\passwd.png`, "passwd.png"},
+		{"CON.png", "_CON.png"},
+		{`a<b>:"c|d?.png`, "a_b___c_d_.png"},
+	}
+	for i, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, uploadNamedRequest(t, "/upload", tc.filename, 8+i))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: upload status = %d, body = %s", tc.filename, w.Code, w.Body.String())
+		}
+		var img model.Image
+		if err := db.DB.Where("user_id = ?", user.ID).Order("id desc").First(&img).Error; err != nil {
+			t.Fatalf("load image: %v", err)
+		}
+		if img.OriginalFilename != tc.want {
+			t.Fatalf("%q: stored original name = %q, want %q", tc.filename, img.OriginalFilename, tc.want)
+		}
+
+		w, _ = doJSON(t, r, http.MethodGet, "/imgs/"+img.Path+"?download=1", nil)
+		want := `attachment; filename="` + tc.want + `"`
+		if got := w.Header().Get("Content-Disposition"); w.Code != http.StatusOK || got != want {
+			t.Fatalf("%q: status = %d, Content-Disposition = %s, want %s", tc.filename, w.Code, got, want)
+		}
+	}
+}
+
+// serveN 以指定方法与 User-Agent 请求图片 n 次
+func serveN(t *testing.T, r http.Handler, method, path, userAgent string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK && w.Code != http.StatusNotFound {
+			t.Fatalf("%s %s: status = %d", method, path, w.Code)
+		}
+	}
+}
+
+// flushedViews 写回缓冲的访问计数并返回图片当前的访问次数
+func flushedViews(t *testing.T, img model.Image) model.Image {
+	t.Helper()
+	if err := service.FlushImageViews(); err != nil {
+		t.Fatalf("flush image views: %v", err)
+	}
+	var stored model.Image
+	db.DB.First(&stored, img.ID)
+	return stored
+}
+
+func TestServeImageCountsViewsAfterFlush(t *testing.T) {
+	setupTestDB(t)
+	_ = service.FlushImageViews()
+	user := createTestUser(t, "alice")
+	img := createServedImage(t, user.ID, "2026/01/a.png", []byte("png-bytes"), nil)
+	r := newServeRouter()
+	browser := "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"
+
+	serveN(t, r, http.MethodGet, "/imgs/2026/01/a.png", browser, 7)
+	// 不存在的图片、HEAD 请求与爬虫不计入
+	serveN(t, r, http.MethodGet, "/imgs/2026/01/missing.png", browser, 3)
+	serveN(t, r, http.MethodHead, "/imgs/2026/01/a.png", browser, 2)
+	serveN(t, r, http.MethodGet, "/imgs/2026/01/a.png", "Googlebot/2.1", 2)
+
+	stored := flushedViews(t, img)
+	if stored.ViewCount != 7 || stored.LastAccessedAt == 0 {
+		t.Fatalf("view count = %d, last accessed = %d; want 7 views", stored.ViewCount, stored.LastAccessedAt)
+	}
+
+	// 计数为原子累加，多次写回后继续增长
+	serveN(t, r, http.MethodGet, "/imgs/2026/01/a.png", browser, 5)
+	if stored = flushedViews(t, img); stored.ViewCount != 12 {
+		t.Fatalf("view count after second flush = %d, want 12", stored.ViewCount)
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigImageViewsExcludeBots: "false"})
+	serveN(t, r, http.MethodHead, "/imgs/2026/01/a.png", browser, 1)
+	serveN(t, r, http.MethodGet, "/imgs/2026/01/a.png", "Googlebot/2.1", 1)
+	if stored = flushedViews(t, img); stored.ViewCount != 14 {
+		t.Fatalf("view count with bots included = %d, want 14", stored.ViewCount)
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigTrackImageViews: "false"})
+	serveN(t, r, http.MethodGet, "/imgs/2026/01/a.png", browser, 4)
+	if stored = flushedViews(t, img); stored.ViewCount != 14 {
+		t.Fatalf("view count with tracking off = %d, want 14", stored.ViewCount)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"sync"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -48,7 +47,16 @@ func Init(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "已初始化，无法重复初始化"})
 		return
 	}
-	passwordHashed, err := bcrypt.GenerateFromPassword([]byte(initInfo.Password), bcrypt.DefaultCost)
+	initInfo.Username = service.NormalizeUsername(initInfo.Username)
+	if err := service.ValidateUsernamePolicy(initInfo.Username); err != nil {
+		writeAuthError(c, err)
+		return
+	}
+	if err := service.ValidatePasswordPolicy(initInfo.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	passwordHashed, err := service.HashPassword(initInfo.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "初始化失败",
@@ -72,10 +80,11 @@ func Init(c *gin.Context) {
 
 		// 创建管理员用户
 		newUser := model.User{
-			Username: initInfo.Username,
-			Password: string(passwordHashed),
-			Avatar:   "",
-			Admin:    true,
+			Username:    service.UsernameKey(initInfo.Username),
+			DisplayName: initInfo.Username,
+			Password:    passwordHashed,
+			Avatar:      "",
+			Admin:       true,
 		}
 		if err := tx.Create(&newUser).Error; err != nil {
 			return err
@@ -88,7 +97,7 @@ func Init(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "初始化失败"})
 		return
 	}
-	service.ClearCache()
+	service.InvalidateSettings()
 	c.JSON(http.StatusOK, gin.H{
 		"message": "初始化成功",
 	})
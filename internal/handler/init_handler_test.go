@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInitAppliesUsernamePolicy(t *testing.T) {
+	setupTestDB(t)
+	r := gin.New()
+	r.POST("/init", Init)
+
+	payload := func(username string) map[string]string {
+		return map[string]string{"username": username, "password": testPassword, "site_name": "Pics", "site_description": "d"}
+	}
+	for _, name := range []string{"Admin", "bad name!", "12345"} {
+		if w, _ := doJSON(t, r, http.MethodPost, "/init", payload(name)); w.Code != http.StatusBadRequest {
+			t.Fatalf("init with %q: status = %d, want 400", name, w.Code)
+		}
+	}
+
+	if w, body := doJSON(t, r, http.MethodPost, "/init", payload("  SiteOwner ")); w.Code != http.StatusOK {
+		t.Fatalf("init status = %d body = %v", w.Code, body)
+	}
+	var user model.User
+	if err := db.DB.Where("admin = ?", true).First(&user).Error; err != nil {
+		t.Fatalf("find admin: %v", err)
+	}
+	if user.Username != "siteowner" || user.DisplayName != "SiteOwner" {
+		t.Fatalf("admin username = %q display = %q", user.Username, user.DisplayName)
+	}
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// requestLocale 确定提示信息使用的语言：登录用户保存的偏好语言 -> Accept-Language 请求头 -> 站点默认语言 -> 中文
+// 用户明确保存的语言优先于浏览器默认发送的请求头
+func requestLocale(c *gin.Context) string {
+	if uid := c.GetUint("id"); uid != 0 {
+		var userLocale string
+		db.DB.Model(&model.User{}).Select("locale").Where("id = ?", uid).Scan(&userLocale)
+		if locale := service.NormalizeLocale(userLocale); locale != "" {
+			return locale
+		}
+	}
+
+	if tags, _, err := language.ParseAcceptLanguage(c.GetHeader("Accept-Language")); err == nil {
+		for _, tag := range tags {
+			if locale := service.NormalizeLocale(tag.String()); locale != "" {
+				return locale
+			}
+		}
+	}
+	return service.ResolveLocale("")
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestLocalePrefersSavedLocale(t *testing.T) {
+	setupTestDB(t)
+	english := createTestUser(t, "english_locale", func(u *model.User) { u.Locale = service.LocaleEn })
+	unset := createTestUser(t, "unset_locale")
+
+	newRouter := func(uid uint) *gin.Engine {
+		r := gin.New()
+		r.GET("/locale", withUser(uid), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"locale": requestLocale(c)})
+		})
+		return r
+	}
+
+	tests := []struct {
+		name           string
+		uid            uint
+		acceptLanguage string
+		want           string
+	}{
+		{"saved locale wins over header", english.ID, "zh-CN,zh;q=0.9", service.LocaleEn},
+		{"header used without saved locale", unset.ID, "en-US,en;q=0.9", service.LocaleEn},
+		{"header used for guests", 0, "en-GB", service.LocaleEn},
+		{"default without header", unset.ID, "", service.LocaleZhCN},
+	}
+	for _, tt := range tests {
+		var headers []string
+		if tt.acceptLanguage != "" {
+			headers = []string{"Accept-Language", tt.acceptLanguage}
+		}
+		_, body := doJSON(t, newRouter(tt.uid), http.MethodGet, "/locale", nil, headers...)
+		if body["locale"] != tt.want {
+			t.Errorf("%s: locale = %v, want %s", tt.name, body["locale"], tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/apidoc"
+	"perfect-pic-server/internal/service"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	openAPIOnce sync.Once
+	openAPISpec *apidoc.Spec
+)
+
+var (
+	// imageIDParam 图片 ID 路径参数
+	imageIDParam = apidoc.Param{Name: "id", In: "path", Type: "integer", Description: "图片 ID"}
+	// uploadFormatParam 上传响应格式参数，sharex 与 picgo 格式分别对应 ShareXUploadResponse 与 PicGoUploadResponse
+	uploadFormatParam = apidoc.Param{Name: "format", In: "query",
+		Description: "响应格式: default (默认，即下方结构)、sharex ({url, deletion_url})、picgo ({success, data: {id, url, deletion_url}})"}
+)
+
+// GetOpenAPISpec 返回认证、上传与图片接口的 OpenAPI 3.0 文档
+// 文档中的请求与响应结构直接取自处理函数使用的结构体，首次请求时生成后缓存
+func GetOpenAPISpec(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPISpec = BuildOpenAPISpec()
+	})
+	c.JSON(http.StatusOK, openAPISpec)
+}
+
+// BuildOpenAPISpec 生成接口文档
+func BuildOpenAPISpec() *apidoc.Spec {
+	b := apidoc.NewBuilder(apidoc.Info{
+		Title:       "PerfectPic API",
+		Description: "PerfectPic 图床服务接口。需要登录的接口在 Authorization 头中携带 Bearer Token。",
+		Version:     "1.0.0",
+	}, ErrorResponse{})
+
+	// 认证
+	b.Add(apidoc.Route{Method: http.MethodPost, Path: "/api/login", Tag: "认证", Summary: "登录",
+		Body: LoginRequest{}, Response: LoginResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodPost, Path: "/api/register", Tag: "认证", Summary: "注册",
+		Body: RegisterRequest{}, Response: MessageResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodPost, Path: "/api/auth/password/reset/request", Tag: "认证", Summary: "申请重置密码",
+		Body: PasswordResetRequest{}, Response: MessageResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodGet, Path: "/api/captcha/provider", Tag: "认证", Summary: "获取验证码类型与公开配置",
+		Response: service.CaptchaProviderInfo{}})
+
+	// 上传
+	b.Add(apidoc.Route{Method: http.MethodPost, Path: "/api/user/upload", Tag: "上传", Summary: "上传图片", Auth: true,
+		Params: []apidoc.Param{uploadFormatParam},
+		Form: []apidoc.FormField{
+			{Name: "file", File: true, Required: true, Description: "图片文件"},
+			{Name: "expires_in", Description: "有效期 (秒)，到期后图片不再提供访问并被删除，不填表示永久保留"},
+		},
+		Response: UploadResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodPost, Path: "/api/anonymous/upload", Tag: "上传", Summary: "免登录上传图片 (需开启访客上传)",
+		Params: []apidoc.Param{uploadFormatParam},
+		Form: []apidoc.FormField{
+			{Name: "file", File: true, Required: true, Description: "图片文件"},
+			{Name: "captcha_id", Description: "图片验证码 ID"},
+			{Name: "captcha_answer", Description: "图片验证码答案"},
+			{Name: "captcha_token", Description: "Cap 验证码 Token"},
+		},
+		Response: UploadResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodPost, Path: "/api/user/upload/batch", Tag: "上传", Summary: "批量上传图片", Auth: true,
+		Form:     []apidoc.FormField{{Name: "files", File: true, Multiple: true, Required: true, Description: "图片文件"}},
+		Response: BatchUploadResponse{}})
+
+	// 图片
+	b.Add(apidoc.Route{Method: http.MethodGet, Path: "/api/user/images", Tag: "图片", Summary: "分页获取自己的图片", Auth: true,
+		Params: []apidoc.Param{
+			{Name: "page", In: "query", Type: "integer", Description: "页码 (从 1 开始)"},
+			{Name: "page_size", In: "query", Type: "integer", Description: "每页数量"},
+			{Name: "filename", In: "query", Description: "按存储文件名模糊过滤"},
+			{Name: "id", In: "query", Type: "integer", Description: "按图片 ID 过滤"},
+			{Name: "album_id", In: "query", Type: "integer", Description: "只返回该相册中的图片"},
+			{Name: "tag", In: "query", Description: "只返回带有该标签的图片"},
+			{Name: "q", In: "query", Description: "按原始文件名与标签搜索"},
+		},
+		Response: ImageListResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodGet, Path: "/api/user/images/:id", Tag: "图片", Summary: "获取图片元信息", Auth: true,
+		Params: []apidoc.Param{imageIDParam}, Response: service.ImageInfo{}})
+	b.Add(apidoc.Route{Method: http.MethodPut, Path: "/api/user/images/:id", Tag: "图片", Summary: "替换图片文件 (访问地址不变)", Auth: true,
+		Params:   []apidoc.Param{imageIDParam},
+		Form:     []apidoc.FormField{{Name: "file", File: true, Required: true, Description: "新的图片文件，类型需与原图一致"}},
+		Response: ReplaceImageResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodDelete, Path: "/api/user/images/:id", Tag: "图片", Summary: "删除图片", Auth: true,
+		Params: []apidoc.Param{imageIDParam}, Response: MessageResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodDelete, Path: "/api/user/images/batch", Tag: "图片", Summary: "批量删除图片", Auth: true,
+		Body: BatchDeleteRequest{}, Response: BatchDeleteResponse{}})
+	deleteTokenParam := apidoc.Param{Name: "token", In: "path", Description: "删除链接中的 Token"}
+	b.Add(apidoc.Route{Method: http.MethodGet, Path: "/api/image_delete/:token", Tag: "图片", Summary: "删除链接的确认页 (HTML，不删除图片)",
+		Params: []apidoc.Param{deleteTokenParam}})
+	b.Add(apidoc.Route{Method: http.MethodPost, Path: "/api/image_delete/:token", Tag: "图片", Summary: "通过上传响应中的删除链接删除图片",
+		Params: []apidoc.Param{deleteTokenParam}, Response: MessageResponse{}})
+	b.Add(apidoc.Route{Method: http.MethodDelete, Path: "/api/image_delete/:token", Tag: "图片", Summary: "通过上传响应中的删除链接删除图片",
+		Params: []apidoc.Param{deleteTokenParam}, Response: MessageResponse{}})
+
+	return b.Spec()
+}
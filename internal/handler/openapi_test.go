@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// collectRefs 收集 JSON 文档中所有的 $ref
+func collectRefs(v any, refs map[string]bool) {
+	switch node := v.(type) {
+	case map[string]any:
+		for key, child := range node {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				refs[ref] = true
+				continue
+			}
+			collectRefs(child, refs)
+		}
+	case []any:
+		for _, child := range node {
+			collectRefs(child, refs)
+		}
+	}
+}
+
+func TestOpenAPISpecIsConsistent(t *testing.T) {
+	r := gin.New()
+	r.GET("/openapi.json", GetOpenAPISpec)
+	w, doc := doJSON(t, r, http.MethodGet, "/openapi.json", nil)
+	if w.Code != http.StatusOK || doc["openapi"] != "3.0.3" {
+		t.Fatalf("status = %d, openapi = %v", w.Code, doc["openapi"])
+	}
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	refs := map[string]bool{}
+	collectRefs(doc, refs)
+	for ref := range refs {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("unresolved $ref %s", ref)
+		}
+	}
+
+	// 路径中的每个 {param} 都需要声明对应的路径参数
+	placeholder := regexp.MustCompile(`\{([^}]+)\}`)
+	for path, item := range doc["paths"].(map[string]any) {
+		for method, raw := range item.(map[string]any) {
+			declared := map[string]bool{}
+			if params, ok := raw.(map[string]any)["parameters"].([]any); ok {
+				for _, p := range params {
+					param := p.(map[string]any)
+					if param["in"] == "path" {
+						declared[param["name"].(string)] = true
+					}
+				}
+			}
+			for _, m := range placeholder.FindAllStringSubmatch(path, -1) {
+				if !declared[m[1]] {
+					t.Errorf("%s %s: path parameter %q not declared", method, path, m[1])
+				}
+			}
+		}
+	}
+}
+
+func TestOpenAPISchemaMatchesWireFormat(t *testing.T) {
+	spec := BuildOpenAPISpec()
+
+	raw, _ := json.Marshal(UploadResponse{PossibleDuplicates: []uint{1}, DeleteToken: "t", DeletionURL: "u"})
+	var wire map[string]any
+	_ = json.Unmarshal(raw, &wire)
+	schema := spec.Components.Schemas["handler.UploadResponse"]
+	if schema == nil {
+		t.Fatal("UploadResponse not documented")
+	}
+	var documented, marshalled []string
+	for name := range schema.Properties {
+		documented = append(documented, name)
+	}
+	for name := range wire {
+		marshalled = append(marshalled, name)
+	}
+	sort.Strings(documented)
+	sort.Strings(marshalled)
+	if strings.Join(documented, ",") != strings.Join(marshalled, ",") {
+		t.Fatalf("documented properties %v, marshalled %v", documented, marshalled)
+	}
+
+	// 嵌入的验证码字段展开到登录请求中，binding:"required" 字段为必填
+	login := spec.Components.Schemas["handler.LoginRequest"]
+	if login.Properties["captcha_id"] == nil || strings.Join(login.Required, ",") != "password,username" {
+		t.Fatalf("LoginRequest schema = %+v", login)
+	}
+}
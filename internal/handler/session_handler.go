@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"perfect-pic-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMySessions 列出当前用户的登录会话
+func GetMySessions(c *gin.Context) {
+	userID, exists := c.Get("id")
+	uid, ok := userID.(uint)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
+		return
+	}
+
+	sessions, err := service.ListSessions(uid)
+	if err != nil {
+		log.Printf("List sessions error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取会话列表失败"})
+		return
+	}
+
+	currentJTI := c.GetString("jti")
+	list := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, gin.H{
+			"jti":        s.JTI,
+			"user_agent": s.UserAgent,
+			"ip":         s.IP,
+			"created_at": s.CreatedAt,
+			"expires_at": s.ExpiresAt,
+			"current":    s.JTI == currentJTI,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// RevokeMySession 注销指定会话
+func RevokeMySession(c *gin.Context) {
+	userID, exists := c.Get("id")
+	uid, ok := userID.(uint)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
+		return
+	}
+
+	if err := service.RevokeSession(uid, c.Param("jti")); err != nil {
+		if authErr, ok := service.AsAuthError(err); ok && authErr.Code == service.AuthErrorNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": authErr.Message})
+			return
+		}
+		log.Printf("Revoke session error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "会话已注销"})
+}
+
+// RevokeAllMySessions 退出所有设备
+func RevokeAllMySessions(c *gin.Context) {
+	userID, exists := c.Get("id")
+	uid, ok := userID.(uint)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
+		return
+	}
+
+	if err := service.RevokeAllSessions(uid); err != nil {
+		log.Printf("Revoke all sessions error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出所有设备"})
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testPassword 测试用户的默认密码 (符合默认密码策略)
+const testPassword = "Passw0rd!"
+
+// TestMain 在临时目录中运行测试，未找到配置文件时使用默认配置
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "perfect-pic-handler-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	log.SetOutput(io.Discard)
+	gin.SetMode(gin.TestMode)
+	config.InitConfig()
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupTestDB 为当前测试创建独立的内存 SQLite 数据库，执行迁移并写入默认设置
+// 默认关闭验证码，需要验证码的测试自行开启
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:handler_%s?mode=memory&cache=shared&_pragma=busy_timeout(5000)", name)
+	d, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.Migrate(d); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	prev := db.DB
+	db.DB = d
+	service.ClearCache()
+	service.InitializeSettings()
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaRequiredActions: ""})
+	t.Cleanup(func() {
+		if sqlDB, err := d.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		db.DB = prev
+		service.ClearCache()
+	})
+}
+
+// setTestSettings 修改系统设置，失败时终止测试
+func setTestSettings(t *testing.T, values map[string]string) {
+	t.Helper()
+	if err := service.UpdateSettings(values); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+}
+
+// createTestUser 创建一个状态正常、密码为 testPassword 的用户，mutate 可在写入前修改字段
+func createTestUser(t *testing.T, username string, mutate ...func(*model.User)) model.User {
+	t.Helper()
+	hashed, err := service.HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := model.User{
+		Username:    strings.ToLower(username),
+		DisplayName: username,
+		Password:    hashed,
+		Status:      1,
+		Email:       strings.ToLower(username) + "@example.com",
+	}
+	for _, m := range mutate {
+		m(&user)
+	}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// withUser 模拟鉴权中间件，将用户 ID 写入上下文
+func withUser(id uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("id", id)
+		c.Next()
+	}
+}
+
+// doJSON 向 h 发送 JSON 请求并解析 JSON 响应体 (响应体为空或不是 JSON 时 body 为 nil)
+func doJSON(t *testing.T, h http.Handler, method, path string, payload any, headers ...string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var reader io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("marshal payload: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var body map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+// sentEmail 测试邮件发送实现记录的一封邮件
+type sentEmail struct {
+	To, Subject, HTML, Text string
+}
+
+// testMailer 记录所有发送的邮件，不实际发送
+type testMailer struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (m *testMailer) Send(to, subject, htmlBody, textBody string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentEmail{To: to, Subject: subject, HTML: htmlBody, Text: textBody})
+	return nil
+}
+
+// Sent 返回已发送邮件的副本
+func (m *testMailer) Sent() []sentEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]sentEmail(nil), m.sent...)
+}
+
+// useTestMailer 开启 SMTP 并将邮件发送替换为 testMailer；邮件队列未启动，邮件同步发送
+func useTestMailer(t *testing.T) *testMailer {
+	t.Helper()
+	m := &testMailer{}
+	service.SetMailer(m)
+	t.Cleanup(func() { service.SetMailer(nil) })
+	setTestSettings(t, map[string]string{consts.ConfigEnableSMTP: "true"})
+	return m
+}
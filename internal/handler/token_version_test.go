@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/middleware"
+	"perfect-pic-server/internal/service"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mountProfile 在 r 上挂载经过完整鉴权的测试接口
+func mountProfile(r *gin.Engine) {
+	r.GET("/user/profile", middleware.JWTAuth(), middleware.UserStatusCheck(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// profileStatus 携带 token 访问受保护接口并返回状态码
+func profileStatus(t *testing.T, r http.Handler, token string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/user/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// loginAs 通过正常登录流程签发 Token，并在测试结束时清除该用户的状态缓存
+func loginAs(t *testing.T, userID uint, username, password string) string {
+	t.Helper()
+	t.Cleanup(func() { middleware.ClearUserStatusCache(userID) })
+	token, err := service.LoginUser(username, password, service.SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("login %q: %v", username, err)
+	}
+	return token
+}
+
+func TestResetPasswordInvalidatesOldTokens(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "resetter")
+
+	r := gin.New()
+	mountProfile(r)
+	r.POST("/auth/password-reset", RequestPasswordReset)
+	r.POST("/auth/reset-password", ResetPassword)
+
+	oldToken := loginAs(t, user.ID, "resetter", testPassword)
+	if code := profileStatus(t, r, oldToken); code != http.StatusNoContent {
+		t.Fatalf("before reset: status = %d, want %d", code, http.StatusNoContent)
+	}
+
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/password-reset", gin.H{"email": user.Email}); w.Code != http.StatusOK {
+		t.Fatalf("request reset: status = %d (body %v)", w.Code, body)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected one reset email, got %d", len(sent))
+	}
+	match := tokenParamPattern.FindStringSubmatch(sent[0].Text)
+	if match == nil {
+		t.Fatalf("no token link in reset email: %s", sent[0].Text)
+	}
+	payload := gin.H{"token": match[1], "new_password": "N3wPassw0rd!"}
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/reset-password", payload); w.Code != http.StatusOK {
+		t.Fatalf("reset: status = %d (body %v)", w.Code, body)
+	}
+
+	if code := profileStatus(t, r, oldToken); code != http.StatusUnauthorized {
+		t.Fatalf("old token after reset: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := profileStatus(t, r, loginAs(t, user.ID, "resetter", "N3wPassw0rd!")); code != http.StatusNoContent {
+		t.Fatalf("new token after reset: status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestEmailChangeVerifyInvalidatesOldTokens(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "mover")
+	r := newEmailChangeRouter(user.ID)
+	mountProfile(r)
+
+	oldToken := loginAs(t, user.ID, "mover", testPassword)
+	if code := profileStatus(t, r, oldToken); code != http.StatusNoContent {
+		t.Fatalf("before change: status = %d, want %d", code, http.StatusNoContent)
+	}
+
+	confirm, _ := requestEmailChange(t, r, mailer, "mover@example.com", "moved@example.com")
+	if w, body := doJSON(t, r, http.MethodPost, "/auth/email-change-verify", gin.H{"token": confirm}); w.Code != http.StatusOK {
+		t.Fatalf("confirm: status = %d (body %v)", w.Code, body)
+	}
+
+	if code := profileStatus(t, r, oldToken); code != http.StatusUnauthorized {
+		t.Fatalf("old token after email change: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := profileStatus(t, r, loginAs(t, user.ID, "mover", testPassword)); code != http.StatusNoContent {
+		t.Fatalf("new token after email change: status = %d, want %d", code, http.StatusNoContent)
+	}
+}
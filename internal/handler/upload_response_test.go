@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadRequest 构造上传一张 size×size PNG 的请求 (不同尺寸得到不同的文件)
+func uploadRequest(t *testing.T, path string, size int) *http.Request {
+	t.Helper()
+	return uploadNamedRequest(t, path, "a.png", size)
+}
+
+// uploadNamedRequest 同 uploadRequest，但使用指定的原始文件名
+func uploadNamedRequest(t *testing.T, path, filename string, size int) *http.Request {
+	t.Helper()
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(img.Bytes())
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadResponseStyles(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader")
+	r := gin.New()
+	r.POST("/upload", withUser(user.ID), UploadImage)
+
+	upload := func(path string, size int) map[string]any {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, uploadRequest(t, path, size))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d body = %s", path, w.Code, w.Body.String())
+		}
+		var body map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode body: %v", path, err)
+		}
+		return body
+	}
+	isAbsolute := func(v any, prefix string) bool {
+		s, _ := v.(string)
+		return strings.HasPrefix(s, "http://localhost"+prefix)
+	}
+
+	body := upload("/upload", 8)
+	if url, _ := body["url"].(string); !strings.HasPrefix(url, "/imgs/") || body["msg"] == nil || body["delete_token"] == nil {
+		t.Fatalf("default response = %v", body)
+	}
+	if !isAbsolute(body["deletion_url"], "/api/image_delete/") {
+		t.Fatalf("default deletion_url = %v", body["deletion_url"])
+	}
+
+	body = upload("/upload?format=sharex", 9)
+	if len(body) != 2 || !isAbsolute(body["url"], "/imgs/") || !isAbsolute(body["deletion_url"], "/api/image_delete/") {
+		t.Fatalf("sharex response = %v", body)
+	}
+
+	// 站点默认格式为 PicGo，请求参数可覆盖，无效的参数沿用站点配置
+	setTestSettings(t, map[string]string{consts.ConfigUploadResponseStyle: "picgo"})
+	for i, path := range []string{"/upload", "/upload?format=xml"} {
+		body = upload(path, 10+i)
+		data, _ := body["data"].(map[string]any)
+		if body["success"] != true || data["id"] == nil || !isAbsolute(data["url"], "/imgs/") || !isAbsolute(data["deletion_url"], "/api/image_delete/") {
+			t.Fatalf("%s: picgo response = %v", path, body)
+		}
+	}
+	body = upload("/upload?format=default", 12)
+	if body["msg"] == nil || body["success"] != nil {
+		t.Fatalf("format=default response = %v", body)
+	}
+}
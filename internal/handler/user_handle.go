@@ -1,19 +1,21 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"perfect-pic-server/internal/config"
 	"perfect-pic-server/internal/consts"
 	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/middleware"
 	"perfect-pic-server/internal/model"
 	"perfect-pic-server/internal/service"
 	"perfect-pic-server/internal/utils"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // GetSelfInfo 获取当前用户信息
@@ -30,15 +32,23 @@ func GetSelfInfo(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"id":            user.ID,
 		"username":      user.Username,
+		"display_name":  user.DisplayName,
 		"email":         user.Email,
 		"avatar":        user.Avatar,
 		"admin":         user.Admin,
 		"storage_quota": user.StorageQuota,
 		"storage_used":  user.StorageUsed,
-	})
+		"locale":        user.Locale,
+		"pending_email": user.PendingEmail,
+	}
+	// 模拟登录时返回发起模拟的管理员 ID，便于前端提示
+	if impersonator := middleware.ImpersonatedBy(c); impersonator != 0 {
+		resp["impersonated_by"] = impersonator
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // UpdateSelfUsername 修改自己的用户名
@@ -57,26 +67,38 @@ func UpdateSelfUsername(c *gin.Context) {
 		return
 	}
 
-	if ok, msg := utils.ValidateUsername(req.Username); !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	uid, ok := userId.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "用户ID类型错误"})
+		return
+	}
+
+	req.Username = service.NormalizeUsername(req.Username)
+	if err := service.ValidateUsernamePolicy(req.Username); err != nil {
+		writeAuthError(c, err)
 		return
 	}
 
 	// 检查用户名是否已存在
-	var count int64
-	db.DB.Model(&model.User{}).Where("username = ? AND id != ?", req.Username, userId).Count(&count)
-	if count > 0 {
+	taken, err := service.IsUsernameTaken(req.Username, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+	if taken {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
 		return
 	}
 
-	if err := db.DB.Model(&model.User{}).Where("id = ?", userId).Update("username", req.Username).Error; err != nil {
+	username := service.UsernameKey(req.Username)
+	if err := db.DB.Model(&model.User{}).Where("id = ?", uid).Updates(map[string]interface{}{
+		"username":     username,
+		"display_name": req.Username,
+	}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
 		return
 	}
 
-	// 获取配置
-	cfg := config.Get()
 	// 获取管理员权限状态
 	adminVal, _ := c.Get("admin")
 	isAdmin := false
@@ -84,29 +106,60 @@ func UpdateSelfUsername(c *gin.Context) {
 		isAdmin = val
 	}
 
-	uid, ok := userId.(uint)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "用户ID类型错误"})
+	// 为当前会话签发新 Token
+	jti := c.GetString("jti")
+	token, err := service.RefreshSessionToken(jti, uid, username, isAdmin)
+	if err != nil {
+		log.Printf("Refresh session token error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "签发新 Token 失败，请重新登录"})
 		return
 	}
 
-	// 签发新 Token
-	token, _ := utils.GenerateLoginToken(uid, req.Username, isAdmin, time.Hour*time.Duration(cfg.JWT.ExpirationHours))
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "用户名更新成功",
 		"token":   token,
 	})
 }
 
-// UpdateSelfPassword 修改自己的密码
-func UpdateSelfPassword(c *gin.Context) {
+// UpdateSelfLocale 更新语言偏好 (留空表示跟随站点默认语言)
+func UpdateSelfLocale(c *gin.Context) {
 	userId, exists := c.Get("id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
 		return
 	}
 
+	var req struct {
+		Locale string `json:"locale"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	locale := service.NormalizeLocale(req.Locale)
+	if req.Locale != "" && locale == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的语言，仅允许: " + strings.Join(service.SupportedLocales, ", ")})
+		return
+	}
+
+	if err := db.DB.Model(&model.User{}).Where("id = ?", userId).Update("locale", locale).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "语言偏好更新成功", "locale": locale})
+}
+
+// UpdateSelfPassword 修改自己的密码 (需验证当前密码)，成功后全部设备需要重新登录
+func UpdateSelfPassword(c *gin.Context) {
+	userID, exists := c.Get("id")
+	uid, ok := userID.(uint)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
+		return
+	}
+
 	var req struct {
 		OldPassword string `json:"old_password" binding:"required"`
 		NewPassword string `json:"new_password" binding:"required"`
@@ -116,8 +169,31 @@ func UpdateSelfPassword(c *gin.Context) {
 		return
 	}
 
-	if ok, msg := utils.ValidatePassword(req.NewPassword); !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	if err := service.ChangePassword(uid, req.OldPassword, req.NewPassword); err != nil {
+		writeAuthError(c, err)
+		return
+	}
+	middleware.ClearUserStatusCache(uid)
+
+	service.RecordAudit(uid, service.AuditActionPasswordChange, service.AuditTargetUser(uid), middleware.ClientIP(c), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码修改成功，请重新登录"})
+}
+
+// RequestSelfDeletion 验证密码后申请注销当前账号
+// 保留期内账号无法登录，可通过确认邮件中的链接撤销；期满后账号与全部图片会被删除
+func RequestSelfDeletion(c *gin.Context) {
+	userId, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
 
@@ -126,26 +202,25 @@ func UpdateSelfPassword(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 		return
 	}
-
-	// 验证旧密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "旧密码错误"})
+	if !service.VerifyPassword(user.Password, req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码错误"})
 		return
 	}
 
-	// 加密新密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	deleteAt, err := service.RequestAccountDeletion(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		writeAuthError(c, err)
 		return
 	}
+	middleware.ClearUserStatusCache(user.ID)
 
-	if err := db.DB.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
-		return
-	}
+	service.RecordAudit(user.ID, service.AuditActionAccountDeletion, service.AuditTargetUser(user.ID), middleware.ClientIP(c),
+		map[string]interface{}{"delete_at": deleteAt.Unix()})
 
-	c.JSON(http.StatusOK, gin.H{"message": "密码修改成功"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "已申请注销账号，所有设备已退出登录，保留期内可通过确认邮件中的链接撤销",
+		"delete_at": deleteAt.Unix(),
+	})
 }
 
 func RequestUpdateEmail(c *gin.Context) {
@@ -170,6 +245,7 @@ func RequestUpdateEmail(c *gin.Context) {
 		return
 	}
 
+	req.NewEmail = utils.NormalizeEmail(req.NewEmail)
 	if ok, msg := utils.ValidateEmail(req.NewEmail); !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
@@ -182,26 +258,26 @@ func RequestUpdateEmail(c *gin.Context) {
 	}
 
 	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if !service.VerifyPassword(user.Password, req.Password) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "密码错误"})
 		return
 	}
 
-	if user.Email == req.NewEmail {
+	if utils.NormalizeEmail(user.Email) == req.NewEmail {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "新邮箱不能与当前邮箱相同"})
 		return
 	}
 
-	// 检查新邮箱是否被占用
+	// 检查新邮箱是否被占用 (按规范化形式比较，忽略大小写差异)
 	var count int64
-	db.DB.Model(&model.User{}).Where("email = ?", req.NewEmail).Count(&count)
+	db.DB.Model(&model.User{}).Where("LOWER(email) = ? AND id != ?", req.NewEmail, user.ID).Count(&count)
 	if count > 0 {
 		c.JSON(http.StatusConflict, gin.H{"error": "该邮箱已被使用"})
 		return
 	}
 
 	// 生成修改邮箱验证 Token (有效期30分钟)
-	token, err := utils.GenerateEmailChangeToken(user.ID, user.Email, req.NewEmail, 30*time.Minute)
+	token, err := utils.GenerateEmailChangeToken(user.ID, user.Email, req.NewEmail, service.EmailVerifyTTL())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证链接失败"})
 		return
@@ -218,10 +294,30 @@ func RequestUpdateEmail(c *gin.Context) {
 	// 前端验证页面: /auth/email-change-verify?token=xxx
 	verifyUrl := fmt.Sprintf("%s/auth/email-change-verify?token=%s", baseURL, token)
 
-	// 发送邮件到新邮箱
-	go func() {
-		_ = service.SendEmailChangeVerification(req.NewEmail, user.Username, user.Email, req.NewEmail, verifyUrl)
-	}()
+	// 记录待确认的新邮箱，确认前仍使用旧邮箱；再次发起修改会使之前的确认链接失效
+	if err := db.DB.Model(&user).Update("pending_email", req.NewEmail).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发起修改邮箱失败"})
+		return
+	}
+
+	// 发送邮件到新邮箱 (进入异步队列)
+	if err := service.SendEmailChangeVerification(req.NewEmail, user.Username, user.Email, req.NewEmail, verifyUrl, user.Locale); err != nil {
+		log.Printf("Send email change verification error: %v", err)
+	}
+
+	// 通知旧邮箱并附带撤销链接，防止会话被盗用后邮箱被悄悄修改
+	if user.Email != "" {
+		cancelToken, err := utils.GenerateEmailChangeCancelToken(user.ID, user.Email, req.NewEmail, service.EmailChangeCancelTokenDuration)
+		if err != nil {
+			log.Printf("Generate email change cancel token error: %v", err)
+		} else {
+			// 前端撤销页面: /auth/email-change-cancel?token=xxx
+			cancelUrl := fmt.Sprintf("%s/auth/email-change-cancel?token=%s", baseURL, cancelToken)
+			if err := service.SendEmailChangeNotice(user.Email, user.Username, user.Email, req.NewEmail, cancelUrl, user.Locale); err != nil {
+				log.Printf("Send email change notice error: %v", err)
+			}
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "验证邮件已发送至新邮箱，请查收并确认"})
 }
@@ -244,14 +340,22 @@ func UpdateSelfAvatar(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	_ = ext
+	if utils.ImageTypeForExt(ext) == "svg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "头像不支持 SVG 格式"})
+		return
+	}
+
 	var user model.User
 	if err := db.DB.First(&user, userId).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 		return
 	}
 
-	newFilename, err := service.UpdateUserAvatar(&user, file)
+	newFilename, err := service.UpdateUserAvatar(c.Request.Context(), &user, file)
+	if errors.Is(err, service.ErrServerBusy) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Printf("UpdateUserAvatar error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "头像更新失败"})
@@ -278,3 +382,48 @@ func GetSelfImagesCount(c *gin.Context) {
 		"image_count": count,
 	})
 }
+
+// ResendVerificationEmail 重新发送邮箱验证邮件
+func ResendVerificationEmail(c *gin.Context) {
+	userID, exists := c.Get("id")
+	uid, ok := userID.(uint)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
+		return
+	}
+
+	if err := service.ResendVerificationEmail(uid); err != nil {
+		writeAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果邮箱尚未验证，验证邮件将很快送达"})
+}
+
+// ExportMyData 以 ZIP 格式导出当前用户的全部数据 (资料、图片元数据与图片文件)
+func ExportMyData(c *gin.Context) {
+	userID, exists := c.Get("id")
+	uid, ok := userID.(uint)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "获取用户ID失败"})
+		return
+	}
+
+	reader, err := service.ExportUserData(c.Request.Context(), uid)
+	if err != nil {
+		writeAuthError(c, err)
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	filename := fmt.Sprintf("perfect-pic-export-%d-%s.zip", uid, time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", utils.ContentDisposition("attachment", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，中途出错只能记录日志
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("Export user data error: %v", err)
+	}
+}
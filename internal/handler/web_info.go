@@ -16,6 +16,7 @@ func GetWebInfo(c *gin.Context) {
 		consts.ConfigSiteDescription,
 		consts.ConfigSiteLogo,
 		consts.ConfigSiteFavicon,
+		consts.ConfigCaptchaRequiredActions, // 前端据此决定哪些表单需要展示验证码
 	}
 
 	type WebInfoItem struct {
@@ -35,9 +36,8 @@ func GetWebInfo(c *gin.Context) {
 }
 
 func GetImagePrefix(c *gin.Context) {
-	cfg := config.Get()
 	c.JSON(http.StatusOK, gin.H{
-		"image_prefix": cfg.Upload.URLPrefix,
+		"image_prefix": service.ImageURLPrefix(),
 	})
 }
 
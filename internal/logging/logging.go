@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// stdlibLogLevel 标准库 log 包输出的日志级别
+const stdlibLogLevel = slog.LevelError
+
+type ctxKey struct{}
+
+var (
+	level         = new(slog.LevelVar)
+	mu            sync.Mutex
+	currentFormat string
+	output        io.Writer = os.Stderr
+)
+
+// Configure 设置全局日志级别 (debug/info/warn/error) 与格式 (text/json)，可在运行时重复调用
+// 同时接管标准库 log 包的输出，使既有的 log.Printf 也以结构化格式输出。
+// 其中包含大量错误日志且无法区分级别，统一按 Error 级别输出，避免调高日志级别后被过滤
+func Configure(levelName, format string) {
+	level.Set(ParseLevel(levelName))
+
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != FormatJSON {
+		format = FormatText
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if format == currentFormat {
+		return
+	}
+	currentFormat = format
+	handler := newHandler(output, format)
+	slog.SetDefault(slog.New(handler))
+	// slog.SetDefault 会把标准库 log 以 Info 级别接入，这里改为 Error 级别
+	log.SetOutput(slog.NewLogLogger(handler, stdlibLogLevel).Writer())
+}
+
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// ParseLevel 解析日志级别名称，无法识别时返回 Info
+func ParseLevel(name string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewContext 返回携带 logger 的上下文
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext 取出上下文中的 logger (带有请求 ID 等字段)，不存在时返回全局默认 logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestStdlibLogSurvivesErrorLevel(t *testing.T) {
+	var buf bytes.Buffer
+	prevOutput, prevDefault := output, slog.Default()
+	output, currentFormat = &buf, ""
+	t.Cleanup(func() {
+		output, currentFormat = prevOutput, ""
+		slog.SetDefault(prevDefault)
+	})
+
+	Configure("error", FormatJSON)
+	log.Printf("Save image error: %v", "disk full")
+	slog.Info("filtered")
+
+	got := buf.String()
+	if !strings.Contains(got, `"level":"ERROR"`) || !strings.Contains(got, "disk full") {
+		t.Fatalf("stdlib log should be written at error level, got %q", got)
+	}
+	if strings.Contains(got, "filtered") {
+		t.Fatalf("info log should be filtered at error level, got %q", got)
+	}
+}
+
+// captureLogs 将日志输出重定向到缓冲区，测试结束后恢复
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prevOutput, prevDefault, prevLevel := output, slog.Default(), level.Level()
+	output, currentFormat = &buf, ""
+	t.Cleanup(func() {
+		output, currentFormat = prevOutput, ""
+		level.Set(prevLevel)
+		slog.SetDefault(prevDefault)
+	})
+	return &buf
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		" INFO ":  slog.LevelInfo,
+		"warn":    slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"Error":   slog.LevelError,
+		"":        slog.LevelInfo,
+		"verbose": slog.LevelInfo,
+	}
+	for name, want := range tests {
+		if got := ParseLevel(name); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestConfigureFiltersByLevel(t *testing.T) {
+	buf := captureLogs(t)
+
+	tests := []struct {
+		level string
+		want  []string
+	}{
+		{level: "debug", want: []string{"debug-msg", "info-msg", "warn-msg", "error-msg"}},
+		{level: "info", want: []string{"info-msg", "warn-msg", "error-msg"}},
+		{level: "warn", want: []string{"warn-msg", "error-msg"}},
+		{level: "error", want: []string{"error-msg"}},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		Configure(tt.level, FormatText)
+		slog.Debug("debug-msg")
+		slog.Info("info-msg")
+		slog.Warn("warn-msg")
+		slog.Error("error-msg")
+
+		got := buf.String()
+		for _, msg := range []string{"debug-msg", "info-msg", "warn-msg", "error-msg"} {
+			wanted := false
+			for _, w := range tt.want {
+				wanted = wanted || w == msg
+			}
+			if strings.Contains(got, msg) != wanted {
+				t.Errorf("level %s: %s logged = %v, want %v", tt.level, msg, !wanted, wanted)
+			}
+		}
+	}
+}
+
+func TestConfigureFormat(t *testing.T) {
+	buf := captureLogs(t)
+
+	Configure("info", FormatJSON)
+	slog.Info("upload failed", "user_id", 7)
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &entry); err != nil {
+		t.Fatalf("json format output is not JSON: %q", buf.String())
+	}
+	if entry["msg"] != "upload failed" || entry["user_id"] != float64(7) {
+		t.Fatalf("unexpected json entry: %v", entry)
+	}
+
+	// 无法识别的格式回退为 text
+	buf.Reset()
+	Configure("info", "xml")
+	slog.Info("upload failed", "user_id", 7)
+	if got := buf.String(); !strings.Contains(got, "msg=\"upload failed\"") || !strings.Contains(got, "user_id=7") {
+		t.Fatalf("unexpected text output: %q", got)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Fatal("context without logger should return the default logger")
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if FromContext(NewContext(context.Background(), logger)) != logger {
+		t.Fatal("logger stored in context not returned")
+	}
+}
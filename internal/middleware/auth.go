@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"perfect-pic-server/internal/db"
 	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
 	"perfect-pic-server/internal/utils"
 	"strings"
 	"sync"
@@ -21,8 +22,9 @@ var (
 const statusCacheTTL = 1 * time.Minute
 
 type cachedStatus struct {
-	Status    int
-	ExpiresAt time.Time
+	Status       int
+	TokenVersion int
+	ExpiresAt    time.Time
 }
 
 // ClearUserStatusCache 清除指定用户的状态缓存
@@ -56,9 +58,38 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		// 校验会话是否已被吊销
+		if !service.IsSessionActive(claims.ID, claims.RegisteredClaims.ID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
 		c.Set("id", claims.ID)
 		c.Set("username", claims.Username)
 		c.Set("admin", claims.Admin)
+		c.Set("jti", claims.RegisteredClaims.ID)
+		c.Set("token_version", claims.Version)
+		if claims.ImpersonatedBy != 0 {
+			c.Set("impersonated_by", claims.ImpersonatedBy)
+		}
+		c.Next()
+	}
+}
+
+// ImpersonatedBy 返回当前请求模拟登录的管理员 ID，非模拟登录时返回 0
+func ImpersonatedBy(c *gin.Context) uint {
+	return c.GetUint("impersonated_by")
+}
+
+// BlockImpersonation 拒绝模拟登录状态下的敏感操作 (修改密码、邮箱、删除数据等)
+func BlockImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ImpersonatedBy(c) != 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "模拟登录状态下不允许此操作"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -81,7 +112,7 @@ func UserStatusCheck() gin.HandlerFunc {
 			return
 		}
 
-		var currentStatus int
+		var currentStatus, currentVersion int
 
 		// 尝试从缓存获取
 		if val, ok := statusCache.Load(uid); ok {
@@ -89,6 +120,7 @@ func UserStatusCheck() gin.HandlerFunc {
 			if typeOk {
 				if time.Now().Before(cached.ExpiresAt) {
 					currentStatus = cached.Status
+					currentVersion = cached.TokenVersion
 				}
 			}
 		}
@@ -96,20 +128,29 @@ func UserStatusCheck() gin.HandlerFunc {
 		// 如果缓存未命中或过期，查询数据库
 		if currentStatus == 0 {
 			var user model.User
-			if err := db.DB.Select("status").First(&user, uid).Error; err != nil {
+			if err := db.DB.Select("status", "token_version").First(&user, uid).Error; err != nil {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
 				c.Abort()
 				return
 			}
 			currentStatus = user.Status
+			currentVersion = user.TokenVersion
 
 			// 写入缓存
 			statusCache.Store(uid, cachedStatus{
-				Status:    currentStatus,
-				ExpiresAt: time.Now().Add(statusCacheTTL),
+				Status:       currentStatus,
+				TokenVersion: currentVersion,
+				ExpiresAt:    time.Now().Add(statusCacheTTL),
 			})
 		}
 
+		// Token 版本落后说明用户已重置密码或被强制下线
+		if c.GetInt("token_version") != currentVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
 		if currentStatus == 2 {
 			c.JSON(http.StatusForbidden, gin.H{"error": "账号已被封禁"})
 			c.Abort()
@@ -120,6 +161,11 @@ func UserStatusCheck() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if currentStatus == 4 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "账号已被锁定"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/service"
+	"perfect-pic-server/internal/utils"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBlockImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		impersonatedBy uint
+		want           int
+	}{
+		{impersonatedBy: 0, want: http.StatusNoContent},
+		{impersonatedBy: 1, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.PATCH("/user/password", func(c *gin.Context) {
+			if tt.impersonatedBy != 0 {
+				c.Set("impersonated_by", tt.impersonatedBy)
+			}
+			c.Next()
+		}, BlockImpersonation(), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/user/password", nil))
+		if w.Code != tt.want {
+			t.Errorf("impersonated_by=%d: status = %d, want %d", tt.impersonatedBy, w.Code, tt.want)
+		}
+	}
+}
+
+// newAuthRouter 返回挂载 JWTAuth 与 UserStatusCheck 的测试路由
+func newAuthRouter() *gin.Engine {
+	r := gin.New()
+	r.GET("/user/profile", JWTAuth(), UserStatusCheck(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+// authRequest 携带 token 访问受保护接口并返回状态码
+func authRequest(t *testing.T, r http.Handler, token string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/user/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// loginToken 通过正常登录流程签发 Token
+func loginToken(t *testing.T, username string) string {
+	t.Helper()
+	token, err := service.LoginUser(username, testPassword, service.SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("login %q: %v", username, err)
+	}
+	return token
+}
+
+func TestRevokedSessionIsRejected(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	r := newAuthRouter()
+
+	revoked := loginToken(t, "alice")
+	other := loginToken(t, "alice")
+	for _, token := range []string{revoked, other} {
+		if code := authRequest(t, r, token); code != http.StatusNoContent {
+			t.Fatalf("before revoke: status = %d, want %d", code, http.StatusNoContent)
+		}
+	}
+
+	claims, err := utils.ParseLoginToken(revoked)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if err := service.RevokeSession(user.ID, claims.RegisteredClaims.ID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+
+	if code := authRequest(t, r, revoked); code != http.StatusUnauthorized {
+		t.Fatalf("revoked token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := authRequest(t, r, other); code != http.StatusNoContent {
+		t.Fatalf("other session: status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestStaleTokenVersionIsRejected(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	r := newAuthRouter()
+
+	token := loginToken(t, "alice")
+	if code := authRequest(t, r, token); code != http.StatusNoContent {
+		t.Fatalf("before bump: status = %d, want %d", code, http.StatusNoContent)
+	}
+
+	// 缓存未刷新前仍按旧版本放行，刷新状态缓存后旧 Token 立即失效
+	if err := service.BumpTokenVersion(user.ID); err != nil {
+		t.Fatalf("bump token version: %v", err)
+	}
+	ClearUserStatusCache(user.ID)
+	if code := authRequest(t, r, token); code != http.StatusUnauthorized {
+		t.Fatalf("stale token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	// 版本更新后重新登录签发的 Token 可以正常使用
+	if code := authRequest(t, r, loginToken(t, "alice")); code != http.StatusNoContent {
+		t.Fatalf("new token: status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestBannedUserIsForbidden(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	r := newAuthRouter()
+
+	token := loginToken(t, "alice")
+	if err := db.DB.Model(&user).Update("status", 2).Error; err != nil {
+		t.Fatalf("ban user: %v", err)
+	}
+	ClearUserStatusCache(user.ID)
+	if code := authRequest(t, r, token); code != http.StatusForbidden {
+		t.Fatalf("banned user: status = %d, want %d", code, http.StatusForbidden)
+	}
+}
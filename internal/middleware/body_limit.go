@@ -13,10 +13,10 @@ import (
 // BodyLimitMiddleware 限制请求体大小
 func BodyLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 跳过上传相关的路由
+		// 跳过上传相关的路由 (由路由上的上传大小限制中间件单独限制)
 		// 这里简单通过路径判断
 		path := c.Request.URL.Path
-		if strings.HasSuffix(path, "/upload") || strings.HasSuffix(path, "/avatar") {
+		if strings.HasSuffix(path, "/upload") || strings.HasSuffix(path, "/avatar") || isUploadRoute(c) {
 			c.Next()
 			return
 		}
@@ -40,14 +40,26 @@ func BodyLimitMiddleware() gin.HandlerFunc {
 // UploadBodyLimitMiddleware 限制上传/头像接口的请求体大小
 func UploadBodyLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		maxSizeMB := service.GetInt(consts.ConfigMaxUploadSize)
-		if maxSizeMB <= 0 {
-			maxSizeMB = 10
+		maxBytes := service.GetMaxUploadSizeBytes()
+
+		if c.Request.ContentLength > maxBytes && c.Request.ContentLength != -1 {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件大小不能超过 %s", service.FormatUploadSizeLimit(maxBytes))})
+			c.Abort()
+			return
 		}
-		maxBytes := int64(maxSizeMB) * 1024 * 1024
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// BatchUploadBodyLimitMiddleware 限制批量上传接口的请求体大小 (单文件上限 * 单次最多文件数)
+func BatchUploadBodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxBytes := service.GetMaxUploadSizeBytes() * int64(service.GetMaxBatchUploadFiles())
 
 		if c.Request.ContentLength > maxBytes && c.Request.ContentLength != -1 {
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件大小不能超过 %dMB", maxSizeMB)})
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("请求总大小不能超过 %s", service.FormatUploadSizeLimit(maxBytes))})
 			c.Abort()
 			return
 		}
@@ -56,3 +68,19 @@ func UploadBodyLimitMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// uploadRouteSuffixes 自带请求体大小限制的其他上传路由 (路由模板后缀)
+var uploadRouteSuffixes = []string{"/upload/batch", "/images/:id", "/uploads/:upload_id/chunks/:index"}
+
+func isUploadRoute(c *gin.Context) bool {
+	if c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPut {
+		return false
+	}
+	fullPath := c.FullPath()
+	for _, suffix := range uploadRouteSuffixes {
+		if strings.HasSuffix(fullPath, suffix) {
+			return true
+		}
+	}
+	return false
+}
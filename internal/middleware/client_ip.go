@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/service"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ipNetList 缓存最近一次解析的 IP/CIDR 列表，配置未变化时不重复解析
+type ipNetList struct {
+	name   string // 配置名称，仅用于日志
+	mu     sync.Mutex
+	parsed bool
+	raw    string
+	nets   []*net.IPNet
+}
+
+var trustedProxyNets = &ipNetList{name: "可信代理"}
+
+// get 解析逗号或空白分隔的 IP/CIDR 列表，无效的条目会被忽略
+func (l *ipNetList) get(raw string) []*net.IPNet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.parsed && l.raw == raw {
+		return l.nets
+	}
+
+	nets := make([]*net.IPNet, 0)
+	items := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	for _, item := range items {
+		if ipNet := parseIPOrCIDR(item); ipNet != nil {
+			nets = append(nets, ipNet)
+			continue
+		}
+		log.Printf("⚠️ %s列表中存在无效条目: %q", l.name, item)
+	}
+
+	l.parsed = true
+	l.raw = raw
+	l.nets = nets
+	return nets
+}
+
+// parseIPOrCIDR 解析单个 IP (视为 /32 或 /128) 或 CIDR
+func parseIPOrCIDR(item string) *net.IPNet {
+	if strings.Contains(item, "/") {
+		if _, ipNet, err := net.ParseCIDR(item); err == nil {
+			return ipNet
+		}
+		return nil
+	}
+	ip := net.ParseIP(item)
+	if ip == nil {
+		return nil
+	}
+	bits := 128
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 解析请求的真实客户端 IP
+// 仅当直连对端位于 ConfigTrustedProxies 中时才采信 X-Forwarded-For / X-Real-IP：
+// 从 X-Forwarded-For 右侧开始跳过可信代理，第一个不可信的合法 IP 即为客户端；
+// 否则直接使用连接的 RemoteAddr，防止客户端伪造请求头。配置修改后立即生效。
+func ClientIP(c *gin.Context) string {
+	peer := c.Request.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil {
+		return peer
+	}
+
+	trusted := trustedProxyNets.get(strings.TrimSpace(service.GetString(consts.ConfigTrustedProxies)))
+	if len(trusted) == 0 || !ipInNets(peerIP, trusted) {
+		return peerIP.String()
+	}
+
+	if xff := c.GetHeader("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				// 格式错误的条目之前的内容都不可信，不再继续向左查找
+				break
+			}
+			if !ipInNets(ip, trusted) {
+				return ip.String()
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(c.GetHeader("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return peerIP.String()
+}
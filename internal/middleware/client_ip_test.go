@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigTrustedProxies: "10.0.0.0/8, 192.0.2.1"})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		realIP     string
+		want       string
+	}{
+		{name: "untrusted peer ignores headers", remoteAddr: "198.51.100.7:1234", xff: "203.0.113.9", realIP: "203.0.113.10", want: "198.51.100.7"},
+		{name: "trusted peer uses forwarded client", remoteAddr: "10.1.2.3:1234", xff: "203.0.113.9", want: "203.0.113.9"},
+		{name: "single trusted IP entry", remoteAddr: "192.0.2.1:1234", xff: "203.0.113.9", want: "203.0.113.9"},
+		{name: "skips trusted hops from the right", remoteAddr: "10.1.2.3:1234", xff: "203.0.113.9, 10.9.9.9, 10.8.8.8", want: "203.0.113.9"},
+		{name: "spoofed leftmost entry is ignored", remoteAddr: "10.1.2.3:1234", xff: "1.1.1.1, 203.0.113.9", want: "203.0.113.9"},
+		{name: "malformed entry stops the walk", remoteAddr: "10.1.2.3:1234", xff: "203.0.113.9, not-an-ip, 10.8.8.8", realIP: "203.0.113.10", want: "203.0.113.10"},
+		{name: "malformed header falls back to peer", remoteAddr: "10.1.2.3:1234", xff: "garbage", want: "10.1.2.3"},
+		{name: "only trusted hops falls back to X-Real-IP", remoteAddr: "10.1.2.3:1234", xff: "10.8.8.8", realIP: "203.0.113.10", want: "203.0.113.10"},
+		{name: "malformed X-Real-IP is ignored", remoteAddr: "10.1.2.3:1234", realIP: "203.0.113.10; drop", want: "10.1.2.3"},
+		{name: "IPv6 peer", remoteAddr: "[2001:db8::1]:1234", xff: "203.0.113.9", want: "2001:db8::1"},
+		{name: "peer without port", remoteAddr: "198.51.100.7", want: "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/api/ping", nil)
+			c.Request.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				c.Request.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				c.Request.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(c); got != tt.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	setupTestDB(t)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/ping", nil)
+	c.Request.RemoteAddr = "10.1.2.3:1234"
+	c.Request.Header.Set("X-Forwarded-For", "203.0.113.9")
+	c.Request.Header.Set("X-Real-IP", "203.0.113.10")
+	if got := ClientIP(c); got != "10.1.2.3" {
+		t.Fatalf("ClientIP = %q, forwarded headers must be ignored when no proxy is trusted", got)
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type, X-API-Key, X-Request-ID"
+	corsExposeHeaders = "X-Request-ID, Content-Disposition, Retry-After"
+	corsMaxAge        = "600"
+)
+
+// CORS 跨域中间件，允许的来源由 ConfigCORSAllowedOrigins 控制 (逗号分隔，留空表示不允许跨域)
+// 配置为 * 时允许任意来源但不允许携带凭证；配置为具体来源列表时回显匹配的来源并允许携带凭证
+// 仅作用于 /api 路径，预检请求 (OPTIONS) 直接返回 204
+func CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+
+		allowAll, allowed := matchCORSOrigin(origin, service.GetString(consts.ConfigCORSAllowedOrigins))
+		// 响应内容随 Origin 变化，提示缓存区分
+		c.Writer.Header().Add("Vary", "Origin")
+		if !allowed {
+			if isPreflight(c) {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
+
+		if isPreflight(c) {
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			c.Header("Access-Control-Max-Age", corsMaxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func isPreflight(c *gin.Context) bool {
+	return c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+}
+
+// matchCORSOrigin 判断来源是否在允许列表中，allowAll 表示命中了通配符 *
+func matchCORSOrigin(origin, allowedList string) (allowAll bool, allowed bool) {
+	for _, item := range strings.Split(allowedList, ",") {
+		item = strings.TrimRight(strings.TrimSpace(item), "/")
+		if item == "" {
+			continue
+		}
+		if item == "*" {
+			allowAll = true
+			continue
+		}
+		if strings.EqualFold(item, origin) {
+			return false, true
+		}
+	}
+	return allowAll, allowAll
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCORSRouter 返回与正式路由相同顺序挂载 CORS 与 SecurityHeaders 的测试路由
+func newCORSRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(CORS(), SecurityHeaders())
+	r.POST("/api/upload", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return r
+}
+
+func corsRequest(r http.Handler, method, origin string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/upload", nil)
+	req.Header.Set("Origin", origin)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigCORSAllowedOrigins: "https://app.example.com, https://admin.example.com/"})
+	r := newCORSRouter()
+
+	w := corsRequest(r, http.MethodPost, "https://admin.example.com")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	h := w.Header()
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+	if h.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal("credentials should be allowed for an explicitly listed origin")
+	}
+	if h.Get("Access-Control-Expose-Headers") == "" || h.Get("Vary") != "Origin" {
+		t.Fatalf("missing expose/vary headers: %v", h)
+	}
+	if h.Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("security headers should still be set")
+	}
+}
+
+func TestCORSWildcardNeverAllowsCredentials(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigCORSAllowedOrigins: "*"})
+
+	w := corsRequest(newCORSRouter(), http.MethodPost, "https://anywhere.example")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatal("credentials must not be allowed with a wildcard origin")
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigCORSAllowedOrigins: "https://app.example.com"})
+	r := newCORSRouter()
+
+	w := corsRequest(r, http.MethodPost, "https://evil.example")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, the request itself should not be blocked", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want none", got)
+	}
+
+	w = corsRequest(r, http.MethodOptions, "https://evil.example", "Access-Control-Request-Method", "POST")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("preflight from a disallowed origin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigCORSAllowedOrigins: "https://app.example.com"})
+
+	w := corsRequest(newCORSRouter(), http.MethodOptions, "https://app.example.com",
+		"Access-Control-Request-Method", "POST",
+		"Access-Control-Request-Headers", "X-API-Key")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	h := w.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		h.Get("Access-Control-Allow-Methods") == "" ||
+		h.Get("Access-Control-Max-Age") == "" {
+		t.Fatalf("unexpected preflight headers: %v", h)
+	}
+	if got := h.Get("Access-Control-Allow-Headers"); got != corsAllowHeaders {
+		t.Fatalf("Access-Control-Allow-Headers = %q, want %q", got, corsAllowHeaders)
+	}
+}
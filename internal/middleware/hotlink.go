@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HotlinkProtection 图片防盗链中间件，开启 ConfigHotlinkProtection 后按 Referer 来源过滤图片请求
+// 本站域名 (请求 Host 与 ConfigBaseURL) 始终允许，其他域名需在 ConfigHotlinkAllowedReferers 中
+// (逗号分隔，支持 *.example.com 匹配子域名)；无 Referer 的请求 (直接访问) 由 ConfigHotlinkAllowEmptyReferer 决定。
+// 被拒绝的请求返回 403，配置了 ConfigHotlinkRedirectURL 时重定向到该占位图
+func HotlinkProtection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !service.GetBool(consts.ConfigHotlinkProtection) {
+			c.Next()
+			return
+		}
+		// 响应内容随 Referer 变化，提示缓存区分
+		c.Writer.Header().Add("Vary", "Referer")
+
+		if refererAllowed(c) {
+			c.Next()
+			return
+		}
+
+		redirect := strings.TrimSpace(service.GetString(consts.ConfigHotlinkRedirectURL))
+		switch {
+		case redirect == "":
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "禁止外链访问"})
+		case isSelfRedirect(c, redirect):
+			// 占位图本身位于图片路径下时放行，避免重定向循环
+			c.Next()
+		default:
+			c.Header("Cache-Control", "no-store")
+			c.Redirect(http.StatusFound, redirect)
+			c.Abort()
+		}
+	}
+}
+
+// isSelfRedirect 判断占位图地址是否指向当前请求本身 (相对路径，或主机为本站且路径相同)
+func isSelfRedirect(c *gin.Context, redirect string) bool {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return false
+	}
+	if host := strings.ToLower(u.Hostname()); host != "" {
+		if host != strings.ToLower(hostWithoutPort(c.Request.Host)) {
+			return false
+		}
+		if port := portOf(c.Request.Host); u.Port() != "" && port != "" && u.Port() != port {
+			return false
+		}
+	}
+	return path.Clean("/"+u.Path) == path.Clean("/"+c.Request.URL.Path)
+}
+
+// portOf 返回 host:port 中的端口，未指定时返回空字符串
+func portOf(hostport string) string {
+	if u, err := url.Parse("//" + hostport); err == nil {
+		return u.Port()
+	}
+	return ""
+}
+
+func refererAllowed(c *gin.Context) bool {
+	referer := c.GetHeader("Referer")
+	if referer == "" {
+		return service.GetBool(consts.ConfigHotlinkAllowEmptyReferer)
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+
+	if host == strings.ToLower(hostWithoutPort(c.Request.Host)) {
+		return true
+	}
+	if base, err := url.Parse(service.GetString(consts.ConfigBaseURL)); err == nil && host == strings.ToLower(base.Hostname()) {
+		return true
+	}
+	return matchRefererHost(host, service.GetString(consts.ConfigHotlinkAllowedReferers))
+}
+
+// matchRefererHost 判断域名是否在允许列表中，列表项可以是完整 URL、域名或 *.example.com 形式
+func matchRefererHost(host, allowedList string) bool {
+	for _, item := range strings.Split(allowedList, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "://") {
+			if u, err := url.Parse(item); err == nil {
+				item = u.Hostname()
+			}
+		}
+		if suffix, ok := strings.CutPrefix(item, "*."); ok {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == hostWithoutPort(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func hostWithoutPort(hostport string) string {
+	if u, err := url.Parse("//" + hostport); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return hostport
+}
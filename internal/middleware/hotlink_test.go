@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIsSelfRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		redirect string
+		want     bool
+	}{
+		{"/imgs/placeholder.png", true},
+		{"/imgs//placeholder.png", true},
+		{"https://pic.example.com/imgs/placeholder.png", true},
+		{"https://PIC.example.com:8080/imgs/placeholder.png", true},
+		{"https://pic.example.com:9090/imgs/placeholder.png", false},
+		{"https://cdn.example.com/imgs/placeholder.png", false},
+		{"/imgs/other.png", false},
+		{"https://cdn.example.com/imgs/placeholder.png?x=1", false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "http://pic.example.com:8080/imgs/placeholder.png", nil)
+		if got := isSelfRedirect(c, tt.redirect); got != tt.want {
+			t.Errorf("isSelfRedirect(%q) = %v, want %v", tt.redirect, got, tt.want)
+		}
+	}
+}
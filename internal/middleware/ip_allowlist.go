@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	adminAllowlistNets = &ipNetList{name: "管理后台 IP 白名单"}
+	captchaBypassNets  = &ipNetList{name: "免验证码 IP"}
+)
+
+// AdminIPAllowlist 管理后台 IP 白名单中间件，白名单由 ConfigAdminIPAllowlist 配置 (逗号分隔的 IP 或 CIDR)
+// 白名单为空时不做限制。客户端 IP 由 ClientIP 解析，伪造的 X-Forwarded-For 无法绕过限制。
+func AdminIPAllowlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(service.GetString(consts.ConfigAdminIPAllowlist))
+		if raw == "" {
+			c.Next()
+			return
+		}
+
+		ip := net.ParseIP(ClientIP(c))
+		if ip == nil || !ipInNets(ip, adminAllowlistNets.get(raw)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "当前 IP 不允许访问管理后台"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CaptchaBypassed 判断请求的客户端 IP 是否位于 ConfigCaptchaBypassIPs 中，位于其中时无需校验验证码
+// 列表默认为空，即所有请求都需要验证码。客户端 IP 同样由 ClientIP 解析
+func CaptchaBypassed(c *gin.Context) bool {
+	raw := strings.TrimSpace(service.GetString(consts.ConfigCaptchaBypassIPs))
+	if raw == "" {
+		return false
+	}
+	ip := net.ParseIP(ClientIP(c))
+	return ip != nil && ipInNets(ip, captchaBypassNets.get(raw))
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminIPAllowlist(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowlist  string
+		trusted    string
+		remoteAddr string
+		xff        string
+		want       int
+	}{
+		{name: "empty allowlist is a no-op", remoteAddr: "198.51.100.7:1234", want: http.StatusNoContent},
+		{name: "allowed CIDR", allowlist: "203.0.113.0/24", remoteAddr: "203.0.113.42:1234", want: http.StatusNoContent},
+		{name: "allowed single IP", allowlist: "10.0.0.1, 203.0.113.42", remoteAddr: "203.0.113.42:1234", want: http.StatusNoContent},
+		{name: "blocked IP", allowlist: "203.0.113.0/24", remoteAddr: "198.51.100.7:1234", want: http.StatusForbidden},
+		{name: "spoofed header without trusted proxy", allowlist: "203.0.113.0/24",
+			remoteAddr: "198.51.100.7:1234", xff: "203.0.113.42", want: http.StatusForbidden},
+		{name: "forwarded client behind trusted proxy", allowlist: "203.0.113.0/24", trusted: "10.0.0.0/8",
+			remoteAddr: "10.0.0.5:1234", xff: "203.0.113.42", want: http.StatusNoContent},
+		{name: "spoofed hop behind trusted proxy", allowlist: "203.0.113.0/24", trusted: "10.0.0.0/8",
+			remoteAddr: "10.0.0.5:1234", xff: "203.0.113.42, 198.51.100.7", want: http.StatusForbidden},
+		{name: "proxy itself is not allowlisted", allowlist: "203.0.113.0/24", trusted: "10.0.0.0/8",
+			remoteAddr: "10.0.0.5:1234", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			setTestSettings(t, map[string]string{
+				consts.ConfigAdminIPAllowlist: tt.allowlist,
+				consts.ConfigTrustedProxies:   tt.trusted,
+			})
+
+			r := gin.New()
+			r.GET("/api/admin/users", AdminIPAllowlist(), func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -5,84 +5,27 @@ import (
 	"net/http"
 	"perfect-pic-server/internal/consts"
 	"perfect-pic-server/internal/service"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
-type IPRateLimiter struct {
-	ips sync.Map
-	mu  sync.Mutex
-	r   rate.Limit
-	b   int
-}
-
-type client struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
-}
-
-func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
-	i := &IPRateLimiter{
-		r: r,
-		b: b,
+// burstWindow 将 RPS/Burst 配置换算为滑动窗口：窗口内最多 burst 次，窗口长度为 burst 次请求按 rps 匀速恢复所需的时间
+func burstWindow(rps float64, burst int) (int, time.Duration) {
+	if rps <= 0 || burst <= 0 {
+		return 0, 0
 	}
-
-	go i.cleanupLoop()
-
-	return i
+	return burst, time.Duration(float64(burst) / rps * float64(time.Second))
 }
 
-func (i *IPRateLimiter) getLimiter(ip string) *rate.Limiter {
-	if v, ok := i.ips.Load(ip); ok {
-		if c, ok := v.(*client); ok {
-			c.lastSeen = time.Now()
-			return c.limiter
-		}
-	}
-
-	i.mu.Lock()
-	defer i.mu.Unlock()
-
-	// Double check
-	if v, ok := i.ips.Load(ip); ok {
-		if c, ok := v.(*client); ok {
-			c.lastSeen = time.Now()
-			return c.limiter
-		}
-	}
-
-	limiter := rate.NewLimiter(i.r, i.b)
-	i.ips.Store(ip, &client{limiter: limiter, lastSeen: time.Now()})
-
-	return limiter
-}
-
-func (i *IPRateLimiter) cleanupLoop() {
-	for {
-		time.Sleep(1 * time.Minute)
-		i.ips.Range(func(key, value interface{}) bool {
-			client, ok := value.(*client)
-			if !ok {
-				i.ips.Delete(key)
-				return true
-			}
-			if time.Since(client.lastSeen) > 3*time.Minute {
-				i.ips.Delete(key)
-			}
-			return true
-		})
-	}
-}
+// rateLimitCostKey 上下文中记录本次请求计入限流次数的键，未设置时计为 1 次
+const rateLimitCostKey = "rate_limit_cost"
 
-// RateLimitMiddleware 创建一个动态限流中间件
+// RateLimitMiddleware 创建一个动态限流中间件，按客户端 IP 计数，每次请求读取最新的 RPS/Burst 配置 (rps 为 0 时不限制)
+// 同一次调用返回的中间件共用一个 service.RateLimiter 实例 (如 auth/upload 各一个)；
+// 之前的中间件可通过 rateLimitCostKey 让一次请求计入多次 (如批量上传按文件计数)
 func RateLimitMiddleware(rpsKey string, burstKey string) gin.HandlerFunc {
-	// 内部建立一个 map 缓存 limiter，避免每次请求都创建 IPRateLimiter 对象
-	// 这里其实是每个 group（auth/upload）共用一个 IPRateLimiter 实例
-	var limiter *IPRateLimiter
-	var once sync.Once
+	limiter := service.NewRateLimiter()
 
 	return func(c *gin.Context) {
 		// 检查总开关
@@ -91,28 +34,12 @@ func RateLimitMiddleware(rpsKey string, burstKey string) gin.HandlerFunc {
 			return
 		}
 
-		// 获取当前配置
-		currentRPS := service.GetFloat64(rpsKey)
-		currentBurst := service.GetInt(burstKey)
-
-		// 初始化 Limiter
-		once.Do(func() {
-			limiter = NewIPRateLimiter(rate.Limit(currentRPS), currentBurst)
-		})
-
-		// 获取 IP 对应的 limiter
-		ip := c.ClientIP()
-		l := limiter.getLimiter(ip)
-
-		// 动态更新 limit 和 burst (如果配置发生变更)
-		if l.Limit() != rate.Limit(currentRPS) {
-			l.SetLimit(rate.Limit(currentRPS))
-		}
-		if l.Burst() != currentBurst {
-			l.SetBurst(currentBurst)
+		cost := c.GetInt(rateLimitCostKey)
+		if cost < 1 {
+			cost = 1
 		}
-
-		if !l.Allow() {
+		limit, window := burstWindow(service.GetFloat64(rpsKey), service.GetInt(burstKey))
+		if allowed, _ := limiter.AllowN(ClientIP(c), cost, limit, window); !allowed {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
 			c.Abort()
 			return
@@ -121,27 +48,21 @@ func RateLimitMiddleware(rpsKey string, burstKey string) gin.HandlerFunc {
 	}
 }
 
+// BatchUploadRateCostMiddleware 让批量上传按文件数计入上传限流，需放在 RateLimitMiddleware 之前
+// 表单在此处解析，处理函数读取时直接使用已解析的结果；超过单次上限的文件不会被处理，也不计数
+func BatchUploadRateCostMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if form, err := c.MultipartForm(); err == nil {
+			c.Set(rateLimitCostKey, min(len(form.File["files"]), service.GetMaxBatchUploadFiles()))
+		}
+		c.Next()
+	}
+}
+
 // IntervalRateMiddleware 限制调用间隔的中间件
 func IntervalRateMiddleware(interval time.Duration) gin.HandlerFunc {
-	// 内部建立一个 map 缓存 IP 最后访问时间
-	var requestTimes sync.Map
-
-	// 启动清理协程
-	go func() {
-		for {
-			time.Sleep(5 * time.Minute)
-			now := time.Now()
-			requestTimes.Range(func(key, value interface{}) bool {
-				if t, ok := value.(time.Time); ok {
-					// 清理超过2倍间隔时间的记录
-					if now.Sub(t) > interval*2 && now.Sub(t) > 5*time.Minute {
-						requestTimes.Delete(key)
-					}
-				}
-				return true
-			})
-		}
-	}()
+	// 每个 IP 在 interval 内只允许调用一次
+	limiter := service.NewRateLimiter()
 
 	return func(c *gin.Context) {
 		// 检查是否开启敏感操作限流
@@ -150,20 +71,12 @@ func IntervalRateMiddleware(interval time.Duration) gin.HandlerFunc {
 			return
 		}
 
-		ip := c.ClientIP()
-
-		val, ok := requestTimes.Load(ip)
-		if ok {
-			if t, ok := val.(time.Time); ok {
-				if time.Since(t) < interval {
-					c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("操作过于频繁，请等待 %v 后再试", interval)})
-					c.Abort()
-					return
-				}
-			}
+		if allowed, _ := limiter.Allow(ClientIP(c), 1, interval); !allowed {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("操作过于频繁，请等待 %v 后再试", interval)})
+			c.Abort()
+			return
 		}
 
-		requestTimes.Store(ip, time.Now())
 		c.Next()
 	}
 }
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBurstWindow(t *testing.T) {
+	tests := []struct {
+		rps       float64
+		burst     int
+		wantLimit int
+		wantWin   time.Duration
+	}{
+		{rps: 0.5, burst: 2, wantLimit: 2, wantWin: 4 * time.Second},
+		{rps: 1, burst: 5, wantLimit: 5, wantWin: 5 * time.Second},
+		{rps: 10, burst: 1, wantLimit: 1, wantWin: 100 * time.Millisecond},
+		{rps: 0, burst: 5, wantLimit: 0, wantWin: 0},
+		{rps: 1, burst: 0, wantLimit: 0, wantWin: 0},
+	}
+	for _, tt := range tests {
+		limit, window := burstWindow(tt.rps, tt.burst)
+		if limit != tt.wantLimit || window != tt.wantWin {
+			t.Errorf("burstWindow(%v, %d) = %d, %v; want %d, %v", tt.rps, tt.burst, limit, window, tt.wantLimit, tt.wantWin)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/logging"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captureRequestLogs 将默认 logger 替换为写入缓冲区的 JSON logger
+func captureRequestLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		log.SetOutput(io.Discard)
+	})
+	return &buf
+}
+
+// logEntries 解析缓冲区中的 JSON 日志行
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRequestLoggerFields(t *testing.T) {
+	setupTestDB(t)
+	buf := captureRequestLogs(t)
+
+	r := gin.New()
+	r.Use(RequestLogger())
+	r.GET("/api/ping", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("handler")
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/ping?x=1", nil))
+	requestID := w.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Fatal("response has no request ID header")
+	}
+
+	entries := logEntries(t, buf)
+	if len(entries) != 2 {
+		t.Fatalf("log entries = %d, want 2: %s", len(entries), buf.String())
+	}
+	// 业务代码通过上下文取得的 logger 带有同一个请求 ID
+	if entries[0]["msg"] != "handler" || entries[0]["request_id"] != requestID {
+		t.Fatalf("handler log = %v, want request_id %s", entries[0], requestID)
+	}
+
+	access := entries[1]
+	want := map[string]any{
+		"msg":        "request",
+		"level":      "INFO",
+		"request_id": requestID,
+		"method":     http.MethodGet,
+		"path":       "/api/ping",
+		"status":     float64(http.StatusNoContent),
+	}
+	for k, v := range want {
+		if access[k] != v {
+			t.Errorf("access log %s = %v, want %v", k, access[k], v)
+		}
+	}
+	for _, k := range []string{"latency_ms", "ip"} {
+		if _, ok := access[k]; !ok {
+			t.Errorf("access log missing %s: %v", k, access)
+		}
+	}
+}
+
+func TestRequestLoggerLevelByStatus(t *testing.T) {
+	setupTestDB(t)
+	buf := captureRequestLogs(t)
+
+	tests := []struct {
+		status int
+		level  string
+	}{
+		{status: http.StatusOK, level: "INFO"},
+		{status: http.StatusNotFound, level: "WARN"},
+		{status: http.StatusInternalServerError, level: "ERROR"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		r := gin.New()
+		r.Use(RequestLogger())
+		r.GET("/x", func(c *gin.Context) { c.Status(tt.status) })
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+
+		entries := logEntries(t, buf)
+		if len(entries) != 1 || entries[0]["level"] != tt.level {
+			t.Errorf("status %d: entries = %v, want one %s entry", tt.status, entries, tt.level)
+		}
+	}
+}
+
+func TestRequestLoggerForwardedRequestID(t *testing.T) {
+	setupTestDB(t)
+	captureRequestLogs(t)
+
+	r := gin.New()
+	r.Use(RequestLogger())
+	r.GET("/x", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		header   string
+		wantSame bool
+	}{
+		{header: "abc-123.DEF_4", wantSame: true},
+		{header: "bad id\nlevel=ERROR", wantSame: false},
+		{header: strings.Repeat("a", 65), wantSame: false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/x", nil)
+		req.Header.Set(RequestIDHeader, tt.header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		got := w.Header().Get(RequestIDHeader)
+		if got == "" || (got == tt.header) != tt.wantSame {
+			t.Errorf("forwarded %q: response request ID = %q, want same = %v", tt.header, got, tt.wantSame)
+		}
+	}
+}
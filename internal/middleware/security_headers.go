@@ -1,6 +1,13 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"perfect-pic-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultContentSecurityPolicy 未启用第三方验证码且未设置图片公开域名时使用的 CSP
+const defaultContentSecurityPolicy = "default-src 'self'; img-src 'self' data: blob:; style-src 'self' 'unsafe-inline'; script-src 'self';"
 
 // SecurityHeaders 添加安全相关的 HTTP 响应头
 func SecurityHeaders() gin.HandlerFunc {
@@ -17,8 +24,28 @@ func SecurityHeaders() gin.HandlerFunc {
 		// img-src 'self' data: blob:: 允许加载同源图片以及 data: 和 blob: 协议的图片
 		// style-src 'self' 'unsafe-inline': 允许同源样式和内联样式 (很多前端框架需要)
 		// script-src 'self': 只允许同源脚本
-		c.Header("Content-Security-Policy", "default-src 'self'; img-src 'self' data: blob:; style-src 'self' 'unsafe-inline'; script-src 'self';")
+		c.Header("Content-Security-Policy", contentSecurityPolicy())
 
 		c.Next()
 	}
 }
+
+// contentSecurityPolicy 按当前配置生成 CSP
+// 设置了图片公开域名 (ConfigPublicBaseURL) 时允许从该源加载图片；
+// 启用 Cap 验证码时额外允许加载/连接其实例源，并允许组件使用 blob: Worker 与 WebAssembly 计算工作量证明
+func contentSecurityPolicy() string {
+	imageOrigin := service.PublicImageOrigin()
+	origin := service.CaptchaOrigin()
+	if imageOrigin == "" && origin == "" {
+		return defaultContentSecurityPolicy
+	}
+	imgSrc := "img-src 'self' data: blob:"
+	if imageOrigin != "" {
+		imgSrc += " " + imageOrigin
+	}
+	if origin == "" {
+		return "default-src 'self'; " + imgSrc + "; style-src 'self' 'unsafe-inline'; script-src 'self';"
+	}
+	return "default-src 'self'; " + imgSrc + "; style-src 'self' 'unsafe-inline'; " +
+		"script-src 'self' 'wasm-unsafe-eval' " + origin + "; connect-src 'self' " + origin + "; worker-src 'self' blob: " + origin + ";"
+}
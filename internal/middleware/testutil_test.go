@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/service"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testPassword 测试用户的默认密码 (符合默认密码策略)
+const testPassword = "Passw0rd!"
+
+// TestMain 在临时目录中运行测试，未找到配置文件时使用默认配置
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "perfect-pic-middleware-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	log.SetOutput(io.Discard)
+	gin.SetMode(gin.TestMode)
+	config.InitConfig()
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupTestDB 为当前测试创建独立的内存 SQLite 数据库，执行迁移并写入默认设置
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:middleware_%s?mode=memory&cache=shared&_pragma=busy_timeout(5000)", name)
+	d, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.Migrate(d); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	prev := db.DB
+	db.DB = d
+	service.ClearCache()
+	service.InitializeSettings()
+	t.Cleanup(func() {
+		if sqlDB, err := d.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		db.DB = prev
+		service.ClearCache()
+	})
+}
+
+// setTestSettings 写入设置并刷新缓存
+func setTestSettings(t *testing.T, values map[string]string) {
+	t.Helper()
+	if err := service.UpdateSettings(values); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+}
+
+// createTestUser 创建一个状态正常、密码为 testPassword 的用户
+func createTestUser(t *testing.T, username string) model.User {
+	t.Helper()
+	hashed, err := service.HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := model.User{
+		Username:    username,
+		DisplayName: username,
+		Password:    hashed,
+		Status:      1,
+		Email:       username + "@example.com",
+	}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() { ClearUserStatusCache(user.ID) })
+	return user
+}
@@ -0,0 +1,21 @@
+package model
+
+// Album 用户相册，一张图片可以属于多个相册
+type Album struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UserID      uint   `json:"user_id" gorm:"not null;index"`
+	Name        string `json:"name" gorm:"size:100;not null"`
+	Description string `json:"description" gorm:"size:500"`
+	CreatedAt   int64  `json:"created_at" gorm:"not null"`
+	UpdatedAt   int64  `json:"updated_at" gorm:"not null"`
+	User        User   `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// AlbumImage 相册与图片的关联
+type AlbumImage struct {
+	AlbumID uint  `json:"album_id" gorm:"primaryKey"`
+	ImageID uint  `json:"image_id" gorm:"primaryKey;index"`
+	AddedAt int64 `json:"added_at" gorm:"not null"`
+	Album   Album `gorm:"foreignKey:AlbumID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Image   Image `gorm:"foreignKey:ImageID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+}
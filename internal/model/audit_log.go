@@ -0,0 +1,12 @@
+package model
+
+// AuditLog 敏感操作审计日志
+type AuditLog struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	ActorID   uint   `json:"actor_id" gorm:"not null;index"`       // 操作者用户 ID
+	Action    string `json:"action" gorm:"size:64;not null;index"` // 操作类型，见 service.AuditAction* 常量
+	Target    string `json:"target" gorm:"size:255"`               // 操作对象 (如 user:12、setting)
+	Metadata  string `json:"metadata" gorm:"type:text"`            // 附加信息 (JSON，敏感字段已脱敏)
+	IP        string `json:"ip" gorm:"size:64"`
+	CreatedAt int64  `json:"created_at" gorm:"not null;index"`
+}
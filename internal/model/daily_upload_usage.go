@@ -0,0 +1,11 @@
+package model
+
+// DailyUploadUsage 用户某一天 (服务器本地时间) 的上传用量，用于每日上传上限
+// 保存在数据库中，服务重启或多实例部署时用量保持一致；每个用户只保留当天的记录
+type DailyUploadUsage struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_daily_upload_user_day"`
+	Day    string `json:"day" gorm:"size:10;not null;uniqueIndex:idx_daily_upload_user_day"` // 2006-01-02
+	Count  int    `json:"count" gorm:"not null;default:0"`
+	Bytes  int64  `json:"bytes" gorm:"not null;default:0"`
+}
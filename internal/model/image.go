@@ -1,14 +1,42 @@
 package model
 
 type Image struct {
-	ID         uint   `json:"id" gorm:"primaryKey"`
-	Filename   string `json:"filename" gorm:"not null;unique"`
-	Path       string `json:"path" gorm:"not null;unique"`
-	Size       int64  `json:"size" gorm:"not null"`
-	Width      int    `json:"width" gorm:"not null"`
-	Height     int    `json:"height" gorm:"not null"`
-	MimeType   string `json:"mime_type" gorm:"not null"`
-	UploadedAt int64  `json:"uploaded_at" gorm:"not null;index"`
-	UserID     uint   `json:"user_id" gorm:"not null;index"`
-	User       User   `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Filename string `json:"filename" gorm:"not null;unique"`
+	Path     string `json:"path" gorm:"not null;unique"`
+	// OriginalFilename 用户上传时的原始文件名，用于下载时的文件名
+	OriginalFilename string `json:"original_filename" gorm:"size:255"`
+	Size             int64  `json:"size" gorm:"not null"`
+	Width            int    `json:"width" gorm:"not null"`
+	Height           int    `json:"height" gorm:"not null"`
+	MimeType         string `json:"mime_type" gorm:"not null"`
+	Hash             string `json:"hash" gorm:"size:64;index"` // 文件内容 SHA-256 (Hex)
+	// PHash 64 位感知哈希 (dHash，按位存为有符号整数)，用于查找视觉相似的图片，计算失败时为空
+	PHash *int64 `json:"-" gorm:"index"`
+	// DominantColor 主色调 (#rrggbb)，供前端在图片加载前渲染占位色块，计算失败时为空
+	DominantColor string `json:"dominant_color" gorm:"size:7"`
+	// WebpPath 自动生成的 WebP 备选文件的存储路径，浏览器支持 WebP 时优先返回，未生成时为空
+	WebpPath string `json:"webp_path" gorm:"size:255"`
+	// AltSkippedHash 补生成备选文件时因类型不适用或结果不小于原图而跳过时的内容哈希，与 Hash 相同时不再重复处理
+	AltSkippedHash string `json:"-" gorm:"size:64;not null;default:''"`
+	// DeleteTokenHash 一次性删除 Token 的 SHA-256 摘要 (Hex)，未生成或已使用时为空
+	DeleteTokenHash string `json:"-" gorm:"size:64"`
+	// DeleteToken 删除 Token 明文，仅在上传成功时填充并返回给上传者，不入库
+	DeleteToken string `json:"-" gorm:"-"`
+	// ExpiresAt 过期时间 (Unix 秒)，到期后不再提供访问并由后台任务删除，nil 表示永久保留
+	ExpiresAt *int64 `json:"expires_at" gorm:"index"`
+	// UploaderIP 访客 (未登录) 上传时的客户端 IP，用于按 IP 限额，登录用户上传的图片为空
+	UploaderIP string `json:"-" gorm:"size:45;index"`
+	// UploaderNetwork 访客上传时按 IP 限额所用的地址 (IPv4 为完整地址，IPv6 为所在 /64 网段)，登录用户上传的图片为空
+	UploaderNetwork string `json:"-" gorm:"size:45;index"`
+	UploadedAt      int64  `json:"uploaded_at" gorm:"not null;index"`
+	// UpdatedAt 文件内容最近一次变更的时间 (Unix 秒，替换图片时更新)，用于 Last-Modified
+	UpdatedAt int64 `json:"updated_at" gorm:"not null;default:0"`
+	// ViewCount 图片被访问的次数，由后台任务批量写回，存在数秒延迟
+	ViewCount int64 `json:"view_count" gorm:"not null;default:0"`
+	// LastAccessedAt 最近一次被访问的时间 (Unix 秒，0 表示从未被访问)
+	LastAccessedAt int64 `json:"last_accessed_at" gorm:"not null;default:0"`
+	// UserID 上传者；访客上传的图片归属于内置的匿名系统用户 (见 service.AnonymousUsername)
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
 }
@@ -0,0 +1,13 @@
+package model
+
+// ImageIntegrityIssue 图片完整性巡检发现的问题记录，供管理员审查
+type ImageIntegrityIssue struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	ImageID      uint   `json:"image_id" gorm:"not null;index"`
+	Path         string `json:"path" gorm:"not null"`
+	ExpectedHash string `json:"expected_hash" gorm:"size:64"`
+	ActualHash   string `json:"actual_hash" gorm:"size:64"`
+	Reason       string `json:"reason" gorm:"not null"` // mismatch: 内容不一致, missing: 文件丢失
+	DetectedAt   int64  `json:"detected_at" gorm:"not null;index"`
+	Resolved     bool   `json:"resolved" gorm:"default:false;index"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// InviteCode 邀请码，邀请注册模式下注册时必须提供
+type InviteCode struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	Code      string `json:"code" gorm:"size:32;not null;uniqueIndex"`
+	CreatedBy uint   `json:"created_by" gorm:"not null;index"`
+	MaxUses   int    `json:"max_uses" gorm:"not null;default:1"`
+	UsedCount int    `json:"used_count" gorm:"not null;default:0"`
+	ExpiresAt int64  `json:"expires_at" gorm:"not null;default:0"` // 过期时间 (Unix 秒，0 表示永不过期)
+	CreatedAt int64  `json:"created_at" gorm:"not null"`
+}
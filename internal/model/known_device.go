@@ -0,0 +1,13 @@
+package model
+
+// KnownDevice 用户登录过的设备，按浏览器类型、操作系统与 IP 网段的哈希识别
+// 从未记录过的设备登录时可发送新设备登录提醒
+type KnownDevice struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UserID      uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_known_device_user_fp"`
+	Fingerprint string `json:"-" gorm:"size:64;not null;uniqueIndex:idx_known_device_user_fp"`
+	UserAgent   string `json:"user_agent" gorm:"size:512"` // 最近一次登录的 User-Agent
+	IP          string `json:"ip" gorm:"size:64"`          // 最近一次登录的 IP
+	FirstSeenAt int64  `json:"first_seen_at" gorm:"not null"`
+	LastSeenAt  int64  `json:"last_seen_at" gorm:"not null"`
+}
@@ -0,0 +1,15 @@
+package model
+
+// Session 登录会话，每个登录 Token 通过 jti 对应一条记录
+// 删除记录即吊销对应 Token
+type Session struct {
+	ID        uint   `json:"-" gorm:"primaryKey"`
+	JTI       string `json:"jti" gorm:"size:64;not null;uniqueIndex"`
+	UserID    uint   `json:"user_id" gorm:"not null;index"`
+	UserAgent string `json:"user_agent" gorm:"size:512"`
+	IP        string `json:"ip" gorm:"size:64"`
+	CreatedAt int64  `json:"created_at" gorm:"not null"`
+	ExpiresAt int64  `json:"expires_at" gorm:"not null;index"`
+	// RememberMe 登录时勾选了记住我，刷新 Token 时沿用较长的有效期
+	RememberMe bool `json:"remember_me" gorm:"not null;default:false"`
+}
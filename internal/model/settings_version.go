@@ -0,0 +1,17 @@
+package model
+
+// SettingsVersion 全局配置版本号 (单行记录)
+// 任一节点修改配置后递增版本号，其余节点轮询发现变化后清理本地缓存
+type SettingsVersion struct {
+	ID        uint  `gorm:"primaryKey"`
+	Version   int64 `gorm:"not null;default:0"`
+	UpdatedAt int64 `gorm:"autoUpdateTime"`
+}
+
+// SettingsChange 每次配置版本递增时修改的配置项
+// 其余节点据此只清理受影响配置项的缓存；Keys 为空表示需要整体清理
+type SettingsChange struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Keys      string `gorm:"type:text"` // 逗号分隔的配置键
+	CreatedAt int64  `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,18 @@
+package model
+
+// Tag 用户自定义的图片标签，同一用户下标签名唯一
+type Tag struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	UserID    uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_tags_user_name"`
+	Name      string `json:"name" gorm:"size:50;not null;uniqueIndex:idx_tags_user_name"`
+	CreatedAt int64  `json:"created_at" gorm:"not null"`
+	User      User   `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// ImageTag 图片与标签的关联
+type ImageTag struct {
+	ImageID uint  `json:"image_id" gorm:"primaryKey"`
+	TagID   uint  `json:"tag_id" gorm:"primaryKey;index"`
+	Image   Image `gorm:"foreignKey:ImageID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Tag     Tag   `gorm:"foreignKey:TagID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+}
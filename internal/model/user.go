@@ -7,18 +7,32 @@ import (
 )
 
 type User struct {
-	ID            uint `json:"id" gorm:"primaryKey"`
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	DeletedAt     gorm.DeletedAt `gorm:"index"`
-	Username      string         `json:"username" gorm:"unique;not null"`
-	Password      string         `json:"-" gorm:"not null"`
-	Admin         bool           `json:"admin" gorm:"not null"`
-	Status        int            `json:"status" gorm:"default:1"` // 1: 正常, 2: 封禁, 3: 软删除(停用)
-	Avatar        string         `json:"avatar"`
-	Email         string         `json:"email" gorm:"unique;index;size:255"`
-	EmailVerified bool           `json:"email_verified" gorm:"default:false"`
-	StorageQuota  *int64         `json:"storage_quota"`
-	StorageUsed   int64          `json:"storage_used" gorm:"default:0"` // 已用存储空间 (Bytes)
-	Photos        []Image        `json:"-"`
+	ID               uint `json:"id" gorm:"primaryKey"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	Username         string         `json:"username" gorm:"unique;not null"` // 规范化 (小写) 后的用户名，用于登录与唯一性比较
+	DisplayName      string         `json:"display_name"`                    // 展示用用户名，保留注册时的大小写
+	Password         string         `json:"-" gorm:"not null"`
+	Admin            bool           `json:"admin" gorm:"not null"`
+	Status           int            `json:"status" gorm:"default:1;index"` // 1: 正常, 2: 封禁, 3: 软删除(停用), 4: 锁定(登录失败次数过多)
+	Avatar           string         `json:"avatar"`
+	Email            string         `json:"email" gorm:"unique;index;size:255"`
+	EmailVerified    bool           `json:"email_verified" gorm:"default:false"`
+	PendingEmail     string         `json:"pending_email" gorm:"size:255"` // 待确认的新邮箱，确认前仍使用 Email 登录与接收邮件
+	StorageQuota     *int64         `json:"storage_quota"`
+	StorageUsed      int64          `json:"storage_used" gorm:"default:0"`       // 已用存储空间 (Bytes)
+	FailedLoginCount int            `json:"failed_login_count" gorm:"default:0"` // 累计连续登录失败次数，登录成功后清零
+	LockReason       string         `json:"lock_reason"`
+	LockedAt         *int64         `json:"locked_at"`             // 因登录失败被锁定的时间 (Unix 秒)，未锁定时为 nil
+	TokenVersion     int            `json:"-" gorm:"default:0"`    // 登录 Token 版本号，递增后旧 Token 全部失效
+	Locale           string         `json:"locale" gorm:"size:16"` // 语言偏好 (如 zh-CN、en)，留空使用站点默认语言
+	Photos           []Image        `json:"-"`
+
+	// 单独设置的每日上传次数/字节数上限，nil 时使用站点默认值，0 表示不限制
+	DailyUploadCountLimit *int   `json:"daily_upload_count_limit"`
+	DailyUploadBytesLimit *int64 `json:"daily_upload_bytes_limit"`
+
+	// DeletionScheduledAt 用户申请注销账号后计划删除的时间 (Unix 秒)，nil 表示未申请；期间无法登录
+	DeletionScheduledAt *int64 `json:"deletion_scheduled_at" gorm:"index"`
 }
@@ -11,9 +11,22 @@ import (
 )
 
 func InitRouter(r *gin.Engine) {
+	// 分配请求 ID 并记录结构化请求日志
+	r.Use(middleware.RequestLogger())
+
+	// 跨域处理 (需在路由匹配前处理预检请求，因此注册为全局中间件)
+	r.Use(middleware.CORS())
+
 	// 注册全局安全标头中间件
 	r.Use(middleware.SecurityHeaders())
 
+	// 存活与就绪探针 (供 k8s 等编排系统使用)
+	r.GET("/healthz", handler.Healthz)
+	r.GET("/readyz", handler.Readyz)
+
+	// 接口文档 (OpenAPI 3.0)
+	r.GET("/openapi.json", handler.GetOpenAPISpec)
+
 	api := r.Group("/api")
 	{
 		// 应用请求体大小限制中间件
@@ -34,31 +47,67 @@ func InitRouter(r *gin.Engine) {
 
 		api.POST("/auth/email-verify", handler.EmailVerify)
 		api.POST("/auth/email-change-verify", handler.EmailChangeVerify)
+		api.POST("/auth/email-change-cancel", handler.EmailChangeCancel)
+		api.POST("/auth/account-deletion-cancel", handler.AccountDeletionCancel)
 
 		// 限制重置密码请求频率为每2分钟1次
 		resetLimiter := middleware.IntervalRateMiddleware(2 * time.Minute)
 		api.POST("/auth/password/reset/request", resetLimiter, handler.RequestPasswordReset)
 		api.POST("/auth/password/reset", handler.ResetPassword)
 
+		// 因登录失败被锁定的账号通过邮件自助解锁
+		api.POST("/auth/account-unlock", handler.AccountUnlock)
+		api.POST("/auth/account-unlock/request", authLimiter, handler.RequestAccountUnlock)
+
 		api.GET("/register", handler.GetRegisterState)
 		api.GET("/captcha", authLimiter, handler.GetCaptcha)
+		api.GET("/captcha/provider", handler.GetCaptchaProviderInfo)
 		api.GET("/webinfo", handler.GetWebInfo)
 		api.GET("/image_prefix", handler.GetImagePrefix)
 		api.GET("/avatar_prefix", handler.GetAvatarPrefix)
 		api.GET("/default_storage_quota", handler.GetDefaultStorageQuota)
 
+		// 访客免登录上传 (需开启 ConfigAllowAnonymousUpload，且必须通过验证码)
+		api.POST("/anonymous/upload", middleware.UploadBodyLimitMiddleware(),
+			middleware.RateLimitMiddleware(consts.ConfigRateLimitUploadRPS, consts.ConfigRateLimitUploadBurst), handler.UploadAnonymousImage)
+
+		// 上传响应中附带的免登录删除链接
+		// GET 只展示确认页，避免链接预览、预取等请求误删图片
+		api.GET("/image_delete/:token", authLimiter, handler.ConfirmImageDeleteByLink)
+		api.POST("/image_delete/:token", authLimiter, handler.DeleteImageByLink)
+		api.DELETE("/image_delete/:token", authLimiter, handler.DeleteImageByLink)
+
 		// 权限路由
 		userGroup := api.Group("/user")
 		userGroup.Use(middleware.JWTAuth())         // 挂载鉴权中间件
 		userGroup.Use(middleware.UserStatusCheck()) // 挂载状态检查中间件
 		{
+			// 模拟登录 (管理员以用户身份登录) 时禁止的敏感操作
+			noImpersonation := middleware.BlockImpersonation()
+
 			userGroup.GET("/profile", handler.GetSelfInfo)
-			userGroup.PATCH("/username", handler.UpdateSelfUsername)
-			userGroup.PATCH("/password", handler.UpdateSelfPassword)
+			userGroup.PATCH("/username", noImpersonation, handler.UpdateSelfUsername)
+			userGroup.PATCH("/password", noImpersonation, handler.UpdateSelfPassword)
+			userGroup.PATCH("/locale", handler.UpdateSelfLocale)
+
+			// 登录会话管理
+			userGroup.GET("/sessions", handler.GetMySessions)
+			userGroup.DELETE("/sessions", noImpersonation, handler.RevokeAllMySessions)
+			userGroup.DELETE("/sessions/:jti", handler.RevokeMySession)
 
 			// 限制修改邮箱请求频率为每2分钟1次
 			emailLimiter := middleware.IntervalRateMiddleware(2 * time.Minute)
-			userGroup.POST("/email", emailLimiter, handler.RequestUpdateEmail)
+			userGroup.POST("/email", noImpersonation, emailLimiter, handler.RequestUpdateEmail)
+
+			// 导出个人数据，限制每10分钟1次
+			exportLimiter := middleware.IntervalRateMiddleware(10 * time.Minute)
+			userGroup.GET("/export", noImpersonation, exportLimiter, handler.ExportMyData)
+
+			// 申请注销账号 (保留期由 ConfigAccountDeletionGraceDays 控制)
+			userGroup.POST("/deletion", noImpersonation, handler.RequestSelfDeletion)
+
+			// 重发验证邮件 (冷却时间由 ConfigVerificationResendCooldown 控制)
+			userGroup.POST("/email/verify/resend", handler.ResendVerificationEmail)
 
 			// 上传限流：读取配置
 			uploadLimiter := middleware.RateLimitMiddleware(consts.ConfigRateLimitUploadRPS, consts.ConfigRateLimitUploadBurst)
@@ -67,10 +116,32 @@ func InitRouter(r *gin.Engine) {
 
 			// Image Upload
 			userGroup.POST("/upload", uploadBodyLimit, uploadLimiter, handler.UploadImage)
+			userGroup.POST("/upload/batch", middleware.BatchUploadBodyLimitMiddleware(), middleware.BatchUploadRateCostMiddleware(), uploadLimiter, handler.UploadImages)
+			// 分片上传 (每个分片的大小受单文件上传限制约束)
+			userGroup.POST("/uploads", handler.InitChunkedUpload)
+			userGroup.GET("/uploads/:upload_id", handler.GetChunkedUpload)
+			userGroup.PUT("/uploads/:upload_id/chunks/:index", uploadBodyLimit, uploadLimiter, handler.UploadChunk)
+			userGroup.POST("/uploads/:upload_id/complete", uploadLimiter, handler.CompleteChunkedUpload)
+			userGroup.DELETE("/uploads/:upload_id", handler.AbortChunkedUpload)
 			userGroup.GET("/images", handler.GetMyImages)
-			userGroup.DELETE("/images/batch", handler.BatchDeleteMyImages)
-			userGroup.DELETE("/images/:id", handler.DeleteMyImage)
+			userGroup.DELETE("/images/batch", noImpersonation, handler.BatchDeleteMyImages)
+			userGroup.GET("/images/:id", handler.GetMyImageInfo)
+			userGroup.GET("/images/:id/exif", handler.GetMyImageExif)
+			userGroup.PUT("/images/:id", noImpersonation, uploadBodyLimit, uploadLimiter, handler.ReplaceMyImage)
+			userGroup.DELETE("/images/:id", noImpersonation, handler.DeleteMyImage)
 			userGroup.GET("/images/count", handler.GetSelfImagesCount)
+			userGroup.GET("/images/:id/tags", handler.GetMyImageTags)
+			userGroup.PUT("/images/:id/tags", handler.SetMyImageTags)
+
+			// Albums & Tags
+			userGroup.GET("/albums", handler.GetMyAlbums)
+			userGroup.POST("/albums", handler.CreateMyAlbum)
+			userGroup.PATCH("/albums/:id", handler.UpdateMyAlbum)
+			userGroup.DELETE("/albums/:id", noImpersonation, handler.DeleteMyAlbum)
+			userGroup.GET("/albums/:id/images", handler.GetMyAlbumImages)
+			userGroup.POST("/albums/:id/images", handler.AddMyAlbumImages)
+			userGroup.DELETE("/albums/:id/images", handler.RemoveMyAlbumImages)
+			userGroup.GET("/tags", handler.GetMyTags)
 
 			userGroup.GET("/ping", func(c *gin.Context) {
 				c.JSON(200, gin.H{"message": "pong with auth"})
@@ -79,6 +150,7 @@ func InitRouter(r *gin.Engine) {
 
 		// Admin 路由
 		adminGroup := api.Group("/admin")
+		adminGroup.Use(middleware.AdminIPAllowlist()) // 管理后台 IP 白名单
 		adminGroup.Use(middleware.JWTAuth())
 		adminGroup.Use(middleware.UserStatusCheck()) // 挂载状态检查中间件
 		adminGroup.Use(middleware.AdminCheck())
@@ -96,12 +168,30 @@ func InitRouter(r *gin.Engine) {
 			adminGroup.PATCH("/users/:id", admin.UpdateUser)
 			adminGroup.POST("/users/:id/avatar", admin.UpdateUserAvatar)
 			adminGroup.DELETE("/users/:id/avatar", admin.RemoveUserAvatar)
+			adminGroup.POST("/users/:id/unlock", admin.UnlockUser)
+			adminGroup.POST("/users/:id/logout", admin.ForceLogoutUser)
+			adminGroup.POST("/users/:id/impersonate", admin.ImpersonateUser)
 			adminGroup.DELETE("/users/:id", admin.DeleteUser)
+			adminGroup.POST("/users/batch/status", admin.BulkUpdateUserStatus)
+			adminGroup.DELETE("/users/batch", admin.BulkDeleteUsers)
+
+			adminGroup.GET("/audit-logs", admin.GetAuditLogList)
+
+			adminGroup.GET("/invite-codes", admin.GetInviteCodeList)
+			adminGroup.POST("/invite-codes", admin.CreateInviteCode)
+			adminGroup.DELETE("/invite-codes/:id", admin.DeleteInviteCode)
 
 			// 图片管理
 			adminGroup.GET("/images", admin.GetImageList)
 			adminGroup.DELETE("/images/batch", admin.BatchDeleteImages)
 			adminGroup.DELETE("/images/:id", admin.DeleteImage)
+
+			// 图片完整性巡检
+			adminGroup.GET("/integrity-issues", admin.GetIntegrityIssues)
+			adminGroup.PATCH("/integrity-issues/:id/resolve", admin.ResolveIntegrityIssue)
+
+			// 维护任务
+			adminGroup.POST("/maintenance/recompute-storage", admin.RecomputeStorage)
 		}
 	}
 }
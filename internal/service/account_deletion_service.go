@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// accountDeletionInterval 删除到期注销账号的执行间隔
+const accountDeletionInterval = time.Hour
+
+var (
+	// ErrAccountDeletionNotScheduled 账号没有待处理的注销申请
+	ErrAccountDeletionNotScheduled = errors.New("账号没有待处理的注销申请")
+	// ErrAccountDeletionCancelInvalid 撤销链接无效、已过期或对应的申请已不存在
+	ErrAccountDeletionCancelInvalid = errors.New("撤销链接已失效")
+	// errAccountDeletionCancelled 删除过程中注销申请已被撤销，放弃删除
+	errAccountDeletionCancelled = errors.New("注销申请已撤销")
+)
+
+var (
+	accountDeletionOnce   sync.Once
+	accountDeletionStopCh chan struct{}
+	accountDeletionDoneCh chan struct{}
+)
+
+// AccountDeletionGrace 返回申请注销后到实际删除的保留时间 (ConfigAccountDeletionGraceDays，配置无效时为 14 天)
+func AccountDeletionGrace() time.Duration {
+	days := GetInt(consts.ConfigAccountDeletionGraceDays)
+	if days <= 0 {
+		days = 14
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RequestAccountDeletion 用户申请注销账号：记录计划删除时间，退出全部会话并发送附带撤销链接的确认邮件
+// 保留期内账号无法登录，期满后由后台任务删除账号及其文件；已申请时返回原计划删除时间。
+// 管理员账号与未绑定邮箱 (无法接收撤销链接) 的账号不能自行注销
+func RequestAccountDeletion(userID uint) (time.Time, error) {
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, newAuthError(AuthErrorNotFound, "用户不存在")
+		}
+		return time.Time{}, newAuthError(AuthErrorInternal, "查询用户失败")
+	}
+	if user.Admin {
+		return time.Time{}, newAuthError(AuthErrorForbidden, "管理员账号不能自行注销")
+	}
+	if user.Email == "" {
+		return time.Time{}, newAuthError(AuthErrorValidation, "请先绑定邮箱后再申请注销")
+	}
+	if user.DeletionScheduledAt != nil {
+		return time.Unix(*user.DeletionScheduledAt, 0), nil
+	}
+
+	deleteAt := time.Now().Add(AccountDeletionGrace()).Truncate(time.Second)
+	result := db.DB.Model(&model.User{}).Where("id = ? AND deletion_scheduled_at IS NULL", user.ID).
+		Update("deletion_scheduled_at", deleteAt.Unix())
+	if result.Error != nil {
+		log.Printf("Schedule account deletion error: %v\n", result.Error)
+		return time.Time{}, newAuthError(AuthErrorInternal, "申请注销失败")
+	}
+	if result.RowsAffected == 0 {
+		// 并发请求已先一步申请
+		if err := db.DB.Select("deletion_scheduled_at").First(&user, user.ID).Error; err != nil || user.DeletionScheduledAt == nil {
+			return time.Time{}, newAuthError(AuthErrorInternal, "申请注销失败")
+		}
+		return time.Unix(*user.DeletionScheduledAt, 0), nil
+	}
+
+	if err := RevokeAllSessions(user.ID); err != nil {
+		log.Printf("Revoke sessions error: %v\n", err)
+	}
+
+	cancelToken, err := utils.GenerateAccountDeletionCancelToken(user.ID, deleteAt)
+	if err != nil {
+		log.Printf("Generate account deletion cancel token error: %v\n", err)
+		return deleteAt, nil
+	}
+	// 前端撤销页面: /auth/account-deletion-cancel?token=xxx
+	cancelUrl := fmt.Sprintf("%s/auth/account-deletion-cancel?token=%s", getBaseURL(), cancelToken)
+	if err := SendAccountDeletionEmail(user.Email, user.Username, deleteAt, cancelUrl, user.Locale); err != nil {
+		log.Printf("Send account deletion email error: %v\n", err)
+	}
+	return deleteAt, nil
+}
+
+// CancelAccountDeletion 撤销用户的注销申请，账号恢复正常登录
+// 后台任务已开始删除 (状态 3) 的账号不能再撤销
+func CancelAccountDeletion(userID uint) error {
+	result := db.DB.Model(&model.User{}).Where("id = ? AND deletion_scheduled_at IS NOT NULL AND status <> ?", userID, 3).
+		Update("deletion_scheduled_at", nil)
+	if result.Error != nil {
+		log.Printf("Cancel account deletion error: %v\n", result.Error)
+		return errors.New("撤销注销申请失败")
+	}
+	if result.RowsAffected == 0 {
+		return ErrAccountDeletionNotScheduled
+	}
+	return nil
+}
+
+// CancelAccountDeletionByToken 通过确认邮件中的撤销链接撤销注销申请，返回被操作的用户 ID
+// 链接只对签发时的那次申请有效，撤销后重新申请会使旧链接失效
+func CancelAccountDeletionByToken(token string) (uint, error) {
+	claims, err := utils.ParseAccountDeletionCancelToken(token)
+	if err != nil {
+		return 0, ErrAccountDeletionCancelInvalid
+	}
+
+	var user model.User
+	if err := db.DB.Select("id", "status", "deletion_scheduled_at").First(&user, claims.ID).Error; err != nil {
+		return 0, ErrAccountDeletionCancelInvalid
+	}
+	if user.DeletionScheduledAt == nil || *user.DeletionScheduledAt != claims.ScheduledAt || user.Status == 3 {
+		return 0, ErrAccountDeletionCancelInvalid
+	}
+
+	if err := CancelAccountDeletion(user.ID); err != nil {
+		if errors.Is(err, ErrAccountDeletionNotScheduled) {
+			return 0, ErrAccountDeletionCancelInvalid
+		}
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// StartAccountDeletionJanitor 启动定期删除保留期已满的注销账号的后台任务 (仅启动一次)
+func StartAccountDeletionJanitor() {
+	accountDeletionOnce.Do(func() {
+		accountDeletionStopCh = make(chan struct{})
+		accountDeletionDoneCh = make(chan struct{})
+		go func() {
+			defer close(accountDeletionDoneCh)
+			ticker := time.NewTicker(accountDeletionInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if deleted, err := PurgeScheduledAccountDeletions(time.Now()); err != nil {
+						log.Printf("Purge scheduled account deletions error: %v\n", err)
+					} else if deleted > 0 {
+						log.Printf("🧹 已删除 %d 个注销保留期已满的账号\n", deleted)
+					}
+				case <-accountDeletionStopCh:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// StopAccountDeletionJanitor 停止删除任务
+func StopAccountDeletionJanitor() {
+	if accountDeletionStopCh == nil {
+		return
+	}
+	close(accountDeletionStopCh)
+	<-accountDeletionDoneCh
+	accountDeletionStopCh = nil
+}
+
+// PurgeScheduledAccountDeletions 删除计划删除时间不晚于 now 的账号，同时清理其头像与图片文件，返回删除的数量
+// 每个账号先以注销申请为条件标记为删除中，期间撤销的账号会被跳过
+func PurgeScheduledAccountDeletions(now time.Time) (deleted int, err error) {
+	ctx := context.Background()
+
+	var ids []uint
+	if err := db.DB.Model(&model.User{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now.Unix()).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := claimScheduledAccount(id, now); err != nil {
+			if !errors.Is(err, errAccountDeletionCancelled) {
+				log.Printf("Claim account %d for deletion error: %v\n", id, err)
+			}
+			continue
+		}
+		if err := purgeClaimedUser(ctx, id); err != nil {
+			log.Printf("Purge account %d error: %v\n", id, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// claimScheduledAccount 确认注销申请仍有效后将账号标记为删除中 (状态 3)，标记后不能再撤销申请
+// 上一轮已标记但未删除完成的账号直接返回 nil 以便重试
+func claimScheduledAccount(userID uint, now time.Time) error {
+	result := db.DB.Model(&model.User{}).
+		Where("id = ? AND status <> ? AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", userID, 3, now.Unix()).
+		Update("status", 3)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := db.DB.Model(&model.User{}).
+		Where("id = ? AND status = ? AND deletion_scheduled_at IS NOT NULL", userID, 3).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errAccountDeletionCancelled
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+	"testing"
+	"time"
+)
+
+// createStoredImage 为用户写入一张图片记录并在当前存储中写入文件
+func createStoredImage(t *testing.T, userID uint, key string) model.Image {
+	t.Helper()
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	putObject(t, store, key, []byte("image"))
+	img := model.Image{Filename: key, Path: key, Size: 5, MimeType: "image/png", UploadedAt: time.Now().Unix(), UserID: userID}
+	if err := db.DB.Create(&img).Error; err != nil {
+		t.Fatalf("create image: %v", err)
+	}
+	return img
+}
+
+func TestRequestAccountDeletionSendsCancelLink(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "alice")
+
+	deleteAt, err := RequestAccountDeletion(user.ID)
+	if err != nil {
+		t.Fatalf("request deletion: %v", err)
+	}
+	if len(mailer.Sent()) != 1 {
+		t.Fatalf("emails sent = %d", len(mailer.Sent()))
+	}
+	again, err := RequestAccountDeletion(user.ID)
+	if err != nil || !again.Equal(deleteAt) {
+		t.Fatalf("repeated request = %v, %v; want %v", again, err, deleteAt)
+	}
+
+	token, err := utils.GenerateAccountDeletionCancelToken(user.ID, deleteAt)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	if id, err := CancelAccountDeletionByToken(token); err != nil || id != user.ID {
+		t.Fatalf("cancel = %d, %v", id, err)
+	}
+	if _, err := CancelAccountDeletionByToken(token); !errors.Is(err, ErrAccountDeletionCancelInvalid) {
+		t.Fatalf("reused cancel token error = %v", err)
+	}
+}
+
+func TestPurgeScheduledAccountDeletions(t *testing.T) {
+	setupTestDB(t)
+	past := time.Now().Add(-time.Hour).Unix()
+	doomed := createTestUser(t, "alice", func(u *model.User) { u.DeletionScheduledAt = &past })
+	future := time.Now().Add(time.Hour).Unix()
+	pending := createTestUser(t, "bob", func(u *model.User) { u.DeletionScheduledAt = &future })
+	img := createStoredImage(t, doomed.ID, "alice.png")
+	createStoredImage(t, pending.ID, "bob.png")
+
+	deleted, err := PurgeScheduledAccountDeletions(time.Now())
+	if err != nil || deleted != 1 {
+		t.Fatalf("purge = %d, %v", deleted, err)
+	}
+	var count int64
+	db.DB.Unscoped().Model(&model.User{}).Where("id = ?", doomed.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("scheduled account not deleted")
+	}
+	db.DB.Unscoped().Model(&model.Image{}).Where("user_id = ?", doomed.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("images of the deleted account remain")
+	}
+	store, _ := ImageStorage()
+	if _, err := readObject(t, store, img.Path); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("image file not deleted: %v", err)
+	}
+	if _, err := readObject(t, store, "bob.png"); err != nil {
+		t.Fatalf("file of a pending account deleted: %v", err)
+	}
+}
+
+func TestClaimedAccountCannotCancelDeletion(t *testing.T) {
+	setupTestDB(t)
+	past := time.Now().Add(-time.Hour).Unix()
+	user := createTestUser(t, "alice", func(u *model.User) { u.DeletionScheduledAt = &past })
+
+	if err := claimScheduledAccount(user.ID, time.Now()); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := CancelAccountDeletion(user.ID); !errors.Is(err, ErrAccountDeletionNotScheduled) {
+		t.Fatalf("cancel after claim error = %v", err)
+	}
+	// 上一轮已标记但未删除完成的账号可以再次认领
+	if err := claimScheduledAccount(user.ID, time.Now()); err != nil {
+		t.Fatalf("reclaim: %v", err)
+	}
+	if err := purgeClaimedUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+}
+
+func TestClaimSkipsCancelledDeletion(t *testing.T) {
+	setupTestDB(t)
+	past := time.Now().Add(-time.Hour).Unix()
+	user := createTestUser(t, "alice", func(u *model.User) { u.DeletionScheduledAt = &past })
+
+	if err := CancelAccountDeletion(user.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if err := claimScheduledAccount(user.ID, time.Now()); !errors.Is(err, errAccountDeletionCancelled) {
+		t.Fatalf("claim after cancel error = %v", err)
+	}
+	if deleted, err := PurgeScheduledAccountDeletions(time.Now()); err != nil || deleted != 0 {
+		t.Fatalf("purge = %d, %v", deleted, err)
+	}
+}
+
+func TestAccountDeletionJanitorStop(t *testing.T) {
+	StartAccountDeletionJanitor()
+	done := make(chan struct{})
+	go func() {
+		StopAccountDeletionJanitor()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopAccountDeletionJanitor did not return")
+	}
+	// 重复停止不阻塞
+	StopAccountDeletionJanitor()
+}
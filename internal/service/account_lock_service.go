@@ -0,0 +1,208 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// AccountUnlockTokenDuration 解锁邮件中链接的有效期
+	AccountUnlockTokenDuration = 24 * time.Hour
+	// accountUnlockResendCooldown 同一邮箱重新申请解锁邮件的冷却时间
+	accountUnlockResendCooldown = 5 * time.Minute
+)
+
+// ErrAccountUnlockInvalid 解锁链接无效、已过期，或账号已解锁、再次被锁定
+var ErrAccountUnlockInvalid = errors.New("解锁链接已失效")
+
+// accountUnlockLimiter 按规范化后的邮箱限制解锁邮件的重新发送频率
+var accountUnlockLimiter = NewRateLimiter()
+
+// registerLoginFailure 记录一次登录失败，是登录失败计数的唯一入口
+// 按账号与 IP 累加临时锁定计数 (ConfigLoginMaxAttempts)；user 不为 nil 时同时累加持久化的失败次数，
+// 达到 ConfigAccountLockThreshold 时锁定账号并发送解锁邮件。返回合并后的剩余次数，以及账号是否因此被锁定
+func registerLoginFailure(user *model.User, account, ip string) (LoginAttemptStatus, bool) {
+	status := RecordLoginFailure(account, ip)
+	if user == nil {
+		return status, false
+	}
+
+	if err := db.DB.Model(&model.User{}).Where("id = ?", user.ID).
+		UpdateColumn("failed_login_count", gorm.Expr("failed_login_count + 1")).Error; err != nil {
+		log.Printf("Increase failed login count error: %v\n", err)
+		return status, false
+	}
+
+	threshold := GetInt(consts.ConfigAccountLockThreshold)
+	if threshold <= 0 || user.Status != 1 {
+		return status, false
+	}
+
+	var current model.User
+	if err := db.DB.Select("id", "failed_login_count").First(&current, user.ID).Error; err != nil {
+		return status, false
+	}
+	if current.FailedLoginCount >= threshold {
+		locked, err := LockUser(user.ID, "连续登录失败次数过多")
+		if err != nil {
+			log.Printf("Lock user error: %v\n", err)
+		}
+		return status, locked
+	}
+
+	// 同时显示两种计数中更接近上限的剩余次数
+	remaining := threshold - current.FailedLoginCount
+	if !status.Enabled || remaining < status.Remaining {
+		status.Remaining = remaining
+	}
+	status.Enabled = true
+	return status, false
+}
+
+// clearLoginFailures 登录成功后清除该 IP 的临时锁定计数与账号的持久化失败次数
+func clearLoginFailures(user *model.User, account, ip string) {
+	ResetLoginFailures(account, ip)
+	if user.FailedLoginCount > 0 {
+		if err := db.DB.Model(&model.User{}).Where("id = ?", user.ID).
+			UpdateColumn("failed_login_count", 0).Error; err != nil {
+			log.Printf("Reset failed login count error: %v\n", err)
+		}
+	}
+}
+
+// LockUser 锁定账号 (状态置为 4) 并向账号邮箱发送解锁邮件，返回本次是否锁定了账号
+// 锁定后需管理员解锁，或由用户通过邮件中的链接自助解锁；账号不处于正常状态时不做任何修改
+func LockUser(userID uint, reason string) (bool, error) {
+	lockedAt := time.Now().Unix()
+	result := db.DB.Model(&model.User{}).Where("id = ? AND status = ?", userID, 1).
+		Updates(map[string]interface{}{"status": 4, "lock_reason": reason, "locked_at": lockedAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	log.Printf("🔒 用户 %d 已被锁定: %s", userID, reason)
+
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return true, nil
+	}
+	if err := sendAccountUnlockEmail(&user); err != nil {
+		log.Printf("Send account unlock email error: %v\n", err)
+	}
+	return true, nil
+}
+
+// UnlockUser 管理员解锁账号，同时清零登录失败次数
+func UnlockUser(adminID, userID uint) error {
+	var user model.User
+	if err := db.DB.Select("id", "username", "status").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newAuthError(AuthErrorNotFound, "用户不存在")
+		}
+		return newAuthError(AuthErrorInternal, "查询用户失败")
+	}
+	if user.Status != 4 {
+		return newAuthError(AuthErrorValidation, "该账号未处于锁定状态")
+	}
+
+	if err := unlockAccount(&user); err != nil {
+		log.Printf("Unlock user error: %v\n", err)
+		return newAuthError(AuthErrorInternal, "解锁失败")
+	}
+	log.Printf("🔓 管理员 %d 解锁了用户 %d", adminID, userID)
+	return nil
+}
+
+// UnlockAccountByToken 通过解锁邮件中的链接自助解锁账号，返回被解锁的用户 ID
+// 链接只对签发时的那次锁定有效
+func UnlockAccountByToken(token string) (uint, error) {
+	claims, err := utils.ParseAccountUnlockToken(token)
+	if err != nil {
+		return 0, ErrAccountUnlockInvalid
+	}
+
+	var user model.User
+	if err := db.DB.Select("id", "username", "status", "locked_at").First(&user, claims.ID).Error; err != nil {
+		return 0, ErrAccountUnlockInvalid
+	}
+	if user.Status != 4 || lockedAtOf(&user) != claims.LockedAt {
+		return 0, ErrAccountUnlockInvalid
+	}
+
+	if err := unlockAccount(&user); err != nil {
+		log.Printf("Unlock user error: %v\n", err)
+		return 0, errors.New("解锁失败")
+	}
+	log.Printf("🔓 用户 %d 通过邮件解锁了账号", user.ID)
+	return user.ID, nil
+}
+
+// RequestAccountUnlock 向被锁定账号的邮箱重新发送解锁邮件
+// 为防止探测邮箱是否已注册，邮箱不存在、账号未被锁定或处于冷却期内时同样返回 nil
+func RequestAccountUnlock(email string) error {
+	normalized := utils.NormalizeEmail(email)
+	var user model.User
+	if err := db.DB.Where("LOWER(email) = ?", normalized).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Status != 4 {
+		return nil
+	}
+	if allowed, _ := accountUnlockLimiter.Allow(normalized, 1, accountUnlockResendCooldown); !allowed {
+		return nil
+	}
+
+	if err := sendAccountUnlockEmail(&user); err != nil {
+		log.Printf("Send account unlock email error: %v\n", err)
+	}
+	return nil
+}
+
+// unlockAccount 恢复账号为正常状态，清零失败次数并清除所有 IP 上的临时锁定
+func unlockAccount(user *model.User) error {
+	if err := db.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"status":             1,
+		"failed_login_count": 0,
+		"lock_reason":        "",
+		"locked_at":          nil,
+	}).Error; err != nil {
+		return err
+	}
+	ResetAllLoginFailures(user.Username)
+	return nil
+}
+
+// sendAccountUnlockEmail 为被锁定的账号签发解锁链接并发送邮件，未绑定邮箱时跳过
+func sendAccountUnlockEmail(user *model.User) error {
+	if user.Email == "" {
+		return nil
+	}
+	token, err := utils.GenerateAccountUnlockToken(user.ID, lockedAtOf(user), AccountUnlockTokenDuration)
+	if err != nil {
+		return err
+	}
+	// 前端解锁页面: /auth/account-unlock?token=xxx
+	unlockUrl := fmt.Sprintf("%s/auth/account-unlock?token=%s", getBaseURL(), token)
+	return SendAccountUnlockEmail(user.Email, user.Username, unlockUrl, user.Locale)
+}
+
+// lockedAtOf 返回账号的锁定时间，此前锁定的账号没有记录时为 0
+func lockedAtOf(user *model.User) int64 {
+	if user.LockedAt == nil {
+		return 0
+	}
+	return *user.LockedAt
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"testing"
+)
+
+// unlockTokenFrom 从解锁邮件的纯文本正文中提取解锁链接的 token
+func unlockTokenFrom(t *testing.T, body string) string {
+	t.Helper()
+	for _, field := range strings.Fields(body) {
+		if !strings.Contains(field, "/auth/account-unlock?token=") {
+			continue
+		}
+		u, err := url.Parse(field)
+		if err != nil {
+			t.Fatalf("parse unlock url: %v", err)
+		}
+		return u.Query().Get("token")
+	}
+	t.Fatalf("unlock url not found in email: %q", body)
+	return ""
+}
+
+// assertLoginLocked 断言登录返回账号已锁定
+func assertLoginLocked(t *testing.T, password string) {
+	t.Helper()
+	_, err := LoginUser("carol", password, SessionMeta{IP: "203.0.113.7"})
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Code != AuthErrorLocked {
+		t.Fatalf("login error = %v, want AuthErrorLocked", err)
+	}
+}
+
+func TestAccountLockAfterThreshold(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	setTestSettings(t, map[string]string{consts.ConfigLoginMaxAttempts: "0", consts.ConfigAccountLockThreshold: "3"})
+	user := createTestUser(t, "carol")
+
+	for i := 0; i < 2; i++ {
+		_, err := LoginUser("carol", "wrong-password", SessionMeta{IP: "203.0.113.7"})
+		var authErr *AuthError
+		if !errors.As(err, &authErr) || authErr.Code != AuthErrorUnauthorized {
+			t.Fatalf("failure %d error = %v", i+1, err)
+		}
+		if want := 2 - i; authErr.Attempt == nil || authErr.Attempt.Remaining != want {
+			t.Fatalf("failure %d attempt = %+v, want remaining %d", i+1, authErr.Attempt, want)
+		}
+	}
+	assertLoginLocked(t, "wrong-password")
+
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Status != 4 || stored.LockedAt == nil {
+		t.Fatalf("user not locked: status=%d locked_at=%v", stored.Status, stored.LockedAt)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != user.Email {
+		t.Fatalf("unlock emails = %+v", sent)
+	}
+
+	// 锁定状态在校验密码之前返回，正确密码同样无法登录
+	assertLoginLocked(t, testPassword)
+}
+
+func TestAccountLockCounterResetOnSuccess(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigLoginMaxAttempts: "0", consts.ConfigAccountLockThreshold: "3"})
+	user := createTestUser(t, "carol")
+
+	for i := 0; i < 2; i++ {
+		_, _ = LoginUser("carol", "wrong-password", SessionMeta{IP: "203.0.113.7"})
+	}
+	if _, err := LoginUser("carol", testPassword, SessionMeta{IP: "203.0.113.7"}); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.FailedLoginCount != 0 {
+		t.Fatalf("failed_login_count = %d after successful login", stored.FailedLoginCount)
+	}
+}
+
+func TestUnlockAccountByToken(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "carol")
+
+	if locked, err := LockUser(user.ID, "test"); err != nil || !locked {
+		t.Fatalf("LockUser = %v, %v", locked, err)
+	}
+	token := unlockTokenFrom(t, mailer.Sent()[0].Text)
+
+	id, err := UnlockAccountByToken(token)
+	if err != nil || id != user.ID {
+		t.Fatalf("UnlockAccountByToken = %d, %v", id, err)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Status != 1 || stored.LockedAt != nil || stored.LockReason != "" {
+		t.Fatalf("user not unlocked: %+v", stored)
+	}
+	if _, err := UnlockAccountByToken(token); !errors.Is(err, ErrAccountUnlockInvalid) {
+		t.Fatalf("reused token error = %v", err)
+	}
+}
+
+func TestUnlockTokenBoundToLock(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "carol")
+
+	if _, err := LockUser(user.ID, "test"); err != nil {
+		t.Fatalf("LockUser: %v", err)
+	}
+	oldToken := unlockTokenFrom(t, mailer.Sent()[0].Text)
+	if err := UnlockUser(1, user.ID); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+
+	// 再次锁定后，上一次锁定签发的链接失效
+	db.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{"status": 4, "locked_at": int64(1)})
+	if _, err := UnlockAccountByToken(oldToken); !errors.Is(err, ErrAccountUnlockInvalid) {
+		t.Fatalf("old token error = %v", err)
+	}
+}
+
+func TestUnlockUserRequiresLockedAccount(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "carol")
+
+	err := UnlockUser(1, user.ID)
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Code != AuthErrorValidation {
+		t.Fatalf("UnlockUser on active account error = %v", err)
+	}
+}
+
+func TestRequestAccountUnlock(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	active := createTestUser(t, "dave")
+	locked := createTestUser(t, "carol", func(u *model.User) { u.Status = 4 })
+
+	if err := RequestAccountUnlock(active.Email); err != nil || len(mailer.Sent()) != 0 {
+		t.Fatalf("active account: err=%v sent=%d", err, len(mailer.Sent()))
+	}
+	if err := RequestAccountUnlock("nobody@example.com"); err != nil || len(mailer.Sent()) != 0 {
+		t.Fatalf("unknown email: err=%v sent=%d", err, len(mailer.Sent()))
+	}
+	if err := RequestAccountUnlock(strings.ToUpper(locked.Email)); err != nil || len(mailer.Sent()) != 1 {
+		t.Fatalf("locked account: err=%v sent=%d", err, len(mailer.Sent()))
+	}
+	// 冷却期内不重复发送
+	if err := RequestAccountUnlock(locked.Email); err != nil || len(mailer.Sent()) != 1 {
+		t.Fatalf("cooldown: err=%v sent=%d", err, len(mailer.Sent()))
+	}
+}
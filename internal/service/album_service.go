@@ -0,0 +1,425 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxAlbumNameLength        = 100
+	maxAlbumDescriptionLength = 500
+	maxTagNameLength          = 50
+	maxTagsPerImage           = 20
+)
+
+var (
+	// ErrAlbumNotFound 相册不存在或不属于当前用户
+	ErrAlbumNotFound = errors.New("相册不存在")
+	// ErrInvalidAlbum 相册名称或描述不合法
+	ErrInvalidAlbum = errors.New("相册信息不合法")
+	// ErrInvalidTag 标签名称或数量不合法
+	ErrInvalidTag = errors.New("标签不合法")
+)
+
+// ImageListOptions 用户图片列表查询参数
+type ImageListOptions struct {
+	Page     int
+	PageSize int
+	// Filename 按存储文件名模糊搜索
+	Filename string
+	// ID 按图片 ID 精确查询，0 表示不过滤
+	ID uint
+	// AlbumID 仅列出指定相册中的图片，0 表示不过滤
+	AlbumID uint
+	// Tag 仅列出带有该标签的图片，留空表示不过滤
+	Tag string
+	// Query 搜索关键词，按空白分词，每个词都需匹配原始文件名或标签名 (忽略大小写)
+	Query string
+}
+
+// ListUserImages 分页查询用户自己的图片，按 ID 倒序
+// 相册与标签过滤通过子查询实现，且子查询同样限定为该用户的相册/标签
+func ListUserImages(userID uint, opts ImageListOptions) ([]model.Image, int64, error) {
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PageSize < 1 {
+		opts.PageSize = 10
+	}
+
+	query := db.DB.Model(&model.Image{}).Where("images.user_id = ?", userID)
+	if opts.Filename != "" {
+		query = query.Where("images.filename LIKE ?", "%"+opts.Filename+"%")
+	}
+	if opts.ID != 0 {
+		query = query.Where("images.id = ?", opts.ID)
+	}
+	if opts.AlbumID != 0 {
+		query = query.Where("images.id IN (?)", db.DB.Model(&model.AlbumImage{}).
+			Select("album_images.image_id").
+			Joins("JOIN albums ON albums.id = album_images.album_id").
+			Where("albums.id = ? AND albums.user_id = ?", opts.AlbumID, userID))
+	}
+	if tag := strings.TrimSpace(opts.Tag); tag != "" {
+		query = query.Where("images.id IN (?)", db.DB.Model(&model.ImageTag{}).
+			Select("image_tags.image_id").
+			Joins("JOIN tags ON tags.id = image_tags.tag_id").
+			Where("tags.user_id = ? AND tags.name = ?", userID, tag))
+	}
+
+	for _, term := range searchTerms(opts.Query) {
+		like := "%" + escapeLike(strings.ToLower(term)) + "%"
+		query = query.Where("LOWER(images.original_filename) LIKE ? ESCAPE '!' OR images.id IN (?)", like,
+			db.DB.Model(&model.ImageTag{}).
+				Select("image_tags.image_id").
+				Joins("JOIN tags ON tags.id = image_tags.tag_id").
+				Where("tags.user_id = ? AND LOWER(tags.name) LIKE ? ESCAPE '!'", userID, like))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var images []model.Image
+	err := query.Order("images.id desc").Offset((opts.Page - 1) * opts.PageSize).Limit(opts.PageSize).Find(&images).Error
+	return images, total, err
+}
+
+// maxSearchTerms 搜索关键词最多参与匹配的词数
+const maxSearchTerms = 10
+
+// SearchImages 在用户自己的图片中按原始文件名与标签名搜索，多个词之间为 AND 关系
+// 使用 LIKE 匹配以兼容 SQLite/MySQL/PostgreSQL，opts 中的其他过滤条件同样生效
+func SearchImages(userID uint, query string, opts ImageListOptions) ([]model.Image, int64, error) {
+	opts.Query = query
+	return ListUserImages(userID, opts)
+}
+
+// searchTerms 按空白切分搜索词并去重，超出 maxSearchTerms 的部分被忽略
+func searchTerms(query string) []string {
+	seen := make(map[string]struct{})
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		seen[term] = struct{}{}
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// escapeLike 转义 LIKE 通配符，配合 ESCAPE '!' 使用 (反斜杠在各数据库中的转义规则不一致)
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// AlbumItem 相册列表项，附带图片数量
+type AlbumItem struct {
+	model.Album
+	ImageCount int64 `json:"image_count"`
+}
+
+// ListAlbums 列出用户的全部相册
+func ListAlbums(userID uint) ([]AlbumItem, error) {
+	var albums []AlbumItem
+	err := db.DB.Model(&model.Album{}).
+		Select("albums.*, (SELECT COUNT(*) FROM album_images WHERE album_images.album_id = albums.id) AS image_count").
+		Where("albums.user_id = ?", userID).
+		Order("albums.id desc").
+		Scan(&albums).Error
+	return albums, err
+}
+
+// CreateAlbum 为用户创建相册
+func CreateAlbum(userID uint, name, description string) (*model.Album, error) {
+	name, description, err := normalizeAlbumFields(name, description)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	album := model.Album{
+		UserID:      userID,
+		Name:        name,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := db.DB.Create(&album).Error; err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// UpdateAlbum 修改相册名称与描述
+func UpdateAlbum(userID, albumID uint, name, description string) (*model.Album, error) {
+	name, description, err := normalizeAlbumFields(name, description)
+	if err != nil {
+		return nil, err
+	}
+	album, err := findUserAlbum(db.DB, userID, albumID)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.DB.Model(album).Updates(map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"updated_at":  time.Now().Unix(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// DeleteAlbum 删除相册及其图片关联，图片本身不受影响
+func DeleteAlbum(userID, albumID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		album, err := findUserAlbum(tx, userID, albumID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("album_id = ?", album.ID).Delete(&model.AlbumImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(album).Error
+	})
+}
+
+// AddImagesToAlbum 将图片加入相册，返回实际新增的数量
+// 只有属于该用户的图片会被加入，已在相册中的图片会被忽略
+func AddImagesToAlbum(userID, albumID uint, imageIDs []uint) (int64, error) {
+	var added int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		album, err := findUserAlbum(tx, userID, albumID)
+		if err != nil {
+			return err
+		}
+		ownedIDs, err := ownedImageIDs(tx, userID, imageIDs)
+		if err != nil || len(ownedIDs) == 0 {
+			return err
+		}
+
+		now := time.Now().Unix()
+		rows := make([]model.AlbumImage, 0, len(ownedIDs))
+		for _, id := range ownedIDs {
+			rows = append(rows, model.AlbumImage{AlbumID: album.ID, ImageID: id, AddedAt: now})
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+		if result.Error != nil {
+			return result.Error
+		}
+		added = result.RowsAffected
+		return tx.Model(album).UpdateColumn("updated_at", now).Error
+	})
+	return added, err
+}
+
+// RemoveImagesFromAlbum 将图片移出相册，返回实际移除的数量
+func RemoveImagesFromAlbum(userID, albumID uint, imageIDs []uint) (int64, error) {
+	if len(imageIDs) == 0 {
+		return 0, nil
+	}
+	var removed int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		album, err := findUserAlbum(tx, userID, albumID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("album_id = ? AND image_id IN ?", album.ID, imageIDs).Delete(&model.AlbumImage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		return tx.Model(album).UpdateColumn("updated_at", time.Now().Unix()).Error
+	})
+	return removed, err
+}
+
+// TagItem 标签列表项，附带使用该标签的图片数量
+type TagItem struct {
+	model.Tag
+	ImageCount int64 `json:"image_count"`
+}
+
+// ListTags 列出用户的全部标签，按名称排序
+func ListTags(userID uint) ([]TagItem, error) {
+	var tags []TagItem
+	err := db.DB.Model(&model.Tag{}).
+		Select("tags.*, (SELECT COUNT(*) FROM image_tags WHERE image_tags.tag_id = tags.id) AS image_count").
+		Where("tags.user_id = ?", userID).
+		Order("tags.name asc").
+		Scan(&tags).Error
+	return tags, err
+}
+
+// GetImageTags 返回图片的全部标签名
+func GetImageTags(userID, imageID uint) ([]string, error) {
+	ids, err := ownedImageIDs(db.DB, userID, []uint{imageID})
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrImageNotFound
+	}
+	var names []string
+	err = db.DB.Model(&model.Tag{}).
+		Joins("JOIN image_tags ON image_tags.tag_id = tags.id").
+		Where("image_tags.image_id = ? AND tags.user_id = ?", imageID, userID).
+		Order("tags.name asc").
+		Pluck("tags.name", &names).Error
+	return names, err
+}
+
+// SetImageTags 用给定的标签列表替换图片的全部标签，不存在的标签自动创建
+// 不再被任何图片使用的标签会被删除
+func SetImageTags(userID, imageID uint, names []string) ([]string, error) {
+	names, err := normalizeTagNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		ids, err := ownedImageIDs(tx, userID, []uint{imageID})
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return ErrImageNotFound
+		}
+
+		if err := tx.Where("image_id = ?", imageID).Delete(&model.ImageTag{}).Error; err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			now := time.Now().Unix()
+			tags := make([]model.Tag, 0, len(names))
+			for _, name := range names {
+				tags = append(tags, model.Tag{UserID: userID, Name: name, CreatedAt: now})
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+				return err
+			}
+
+			var tagIDs []uint
+			if err := tx.Model(&model.Tag{}).Where("user_id = ? AND name IN ?", userID, names).
+				Pluck("id", &tagIDs).Error; err != nil {
+				return err
+			}
+			rows := make([]model.ImageTag, 0, len(tagIDs))
+			for _, id := range tagIDs {
+				rows = append(rows, model.ImageTag{ImageID: imageID, TagID: id})
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return deleteUnusedTags(tx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// deleteImageRelations 删除图片的相册与标签关联，需在删除图片记录的事务中调用
+// 不依赖数据库外键级联 (SQLite 默认未开启外键约束)
+func deleteImageRelations(tx *gorm.DB, imageIDs []uint) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+	if err := tx.Where("image_id IN ?", imageIDs).Delete(&model.AlbumImage{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("image_id IN ?", imageIDs).Delete(&model.ImageTag{}).Error
+}
+
+// deleteUserAlbumsAndTags 删除用户的全部相册、标签及其关联，需在删除用户的事务中调用
+func deleteUserAlbumsAndTags(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("album_id IN (?)", tx.Model(&model.Album{}).Select("id").Where("user_id = ?", userID)).
+		Delete(&model.AlbumImage{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("tag_id IN (?)", tx.Model(&model.Tag{}).Select("id").Where("user_id = ?", userID)).
+		Delete(&model.ImageTag{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&model.Album{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&model.Tag{}).Error
+}
+
+// deleteUnusedTags 删除用户不再被任何图片使用的标签
+func deleteUnusedTags(tx *gorm.DB, userID uint) error {
+	return tx.Where("user_id = ? AND id NOT IN (?)", userID, tx.Model(&model.ImageTag{}).Select("tag_id")).
+		Delete(&model.Tag{}).Error
+}
+
+func findUserAlbum(tx *gorm.DB, userID, albumID uint) (*model.Album, error) {
+	var album model.Album
+	if err := tx.Where("id = ? AND user_id = ?", albumID, userID).First(&album).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlbumNotFound
+		}
+		return nil, err
+	}
+	return &album, nil
+}
+
+// ownedImageIDs 过滤出属于该用户的图片 ID
+func ownedImageIDs(tx *gorm.DB, userID uint, imageIDs []uint) ([]uint, error) {
+	if len(imageIDs) == 0 {
+		return nil, nil
+	}
+	var ids []uint
+	err := tx.Model(&model.Image{}).Where("user_id = ? AND id IN ?", userID, imageIDs).Pluck("id", &ids).Error
+	return ids, err
+}
+
+func normalizeAlbumFields(name, description string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	description = strings.TrimSpace(description)
+	if name == "" || utf8.RuneCountInString(name) > maxAlbumNameLength {
+		return "", "", fmt.Errorf("%w: 名称不能为空且不超过 %d 个字符", ErrInvalidAlbum, maxAlbumNameLength)
+	}
+	if utf8.RuneCountInString(description) > maxAlbumDescriptionLength {
+		return "", "", fmt.Errorf("%w: 描述不能超过 %d 个字符", ErrInvalidAlbum, maxAlbumDescriptionLength)
+	}
+	return name, description, nil
+}
+
+// normalizeTagNames 去除空白与重复的标签名，并校验长度与数量
+func normalizeTagNames(names []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if utf8.RuneCountInString(name) > maxTagNameLength || strings.Contains(name, ",") {
+			return nil, fmt.Errorf("%w: 标签不能超过 %d 个字符且不能包含逗号", ErrInvalidTag, maxTagNameLength)
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		result = append(result, name)
+	}
+	if len(result) > maxTagsPerImage {
+		return nil, fmt.Errorf("%w: 每张图片最多 %d 个标签", ErrInvalidTag, maxTagsPerImage)
+	}
+	return result, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"reflect"
+	"testing"
+)
+
+// imageIDs 返回图片列表的 ID (保持顺序)
+func imageIDs(images []model.Image) []uint {
+	ids := make([]uint, 0, len(images))
+	for _, img := range images {
+		ids = append(ids, img.ID)
+	}
+	return ids
+}
+
+func TestAddImagesToAlbumAndList(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	a1 := createStoredImage(t, alice.ID, "2026/02/13/a1.png")
+	a2 := createStoredImage(t, alice.ID, "2026/02/13/a2.png")
+	createStoredImage(t, alice.ID, "2026/02/13/a3.png")
+	b1 := createStoredImage(t, bob.ID, "2026/02/13/b1.png")
+
+	album, err := CreateAlbum(alice.ID, "Trip", "")
+	if err != nil {
+		t.Fatalf("create album: %v", err)
+	}
+	// 其他用户的图片不会被加入
+	added, err := AddImagesToAlbum(alice.ID, album.ID, []uint{a1.ID, a2.ID, b1.ID})
+	if err != nil || added != 2 {
+		t.Fatalf("add images: added %d, %v; want 2", added, err)
+	}
+	if added, err := AddImagesToAlbum(alice.ID, album.ID, []uint{a1.ID}); err != nil || added != 0 {
+		t.Fatalf("re-adding an image: added %d, %v; want 0", added, err)
+	}
+
+	images, total, err := ListUserImages(alice.ID, ImageListOptions{AlbumID: album.ID})
+	if err != nil {
+		t.Fatalf("list album: %v", err)
+	}
+	if ids := imageIDs(images); total != 2 || len(ids) != 2 || ids[0] != a2.ID || ids[1] != a1.ID {
+		t.Fatalf("album images = %v (total %d), want [%d %d]", ids, total, a2.ID, a1.ID)
+	}
+	page2, total, err := ListUserImages(alice.ID, ImageListOptions{AlbumID: album.ID, Page: 2, PageSize: 1})
+	if err != nil || total != 2 || len(page2) != 1 || page2[0].ID != a1.ID {
+		t.Fatalf("second page = %v (total %d, %v)", imageIDs(page2), total, err)
+	}
+
+	albums, err := ListAlbums(alice.ID)
+	if err != nil || len(albums) != 1 || albums[0].ImageCount != 2 {
+		t.Fatalf("list albums = %+v, %v", albums, err)
+	}
+
+	// 相册属于创建者，其他用户既不能写入也不能通过它列出图片
+	if _, err := AddImagesToAlbum(bob.ID, album.ID, []uint{b1.ID}); !errors.Is(err, ErrAlbumNotFound) {
+		t.Fatalf("other user adding to the album: got %v, want ErrAlbumNotFound", err)
+	}
+	if images, total, _ := ListUserImages(bob.ID, ImageListOptions{AlbumID: album.ID}); total != 0 || len(images) != 0 {
+		t.Fatalf("other user listing the album saw %d images", total)
+	}
+
+	if removed, err := RemoveImagesFromAlbum(alice.ID, album.ID, []uint{a1.ID}); err != nil || removed != 1 {
+		t.Fatalf("remove image: removed %d, %v", removed, err)
+	}
+	if _, total, _ := ListUserImages(alice.ID, ImageListOptions{AlbumID: album.ID}); total != 1 {
+		t.Fatalf("album has %d images after removal, want 1", total)
+	}
+}
+
+func TestDeleteImageCleansUpAlbumAndTagRows(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	img := createStoredImage(t, user.ID, "2026/02/13/a.png")
+	other := createStoredImage(t, user.ID, "2026/02/13/b.png")
+	third := createStoredImage(t, user.ID, "2026/02/13/c.png")
+
+	album, err := CreateAlbum(user.ID, "Trip", "")
+	if err != nil {
+		t.Fatalf("create album: %v", err)
+	}
+	if _, err := AddImagesToAlbum(user.ID, album.ID, []uint{img.ID, other.ID, third.ID}); err != nil {
+		t.Fatalf("add images: %v", err)
+	}
+	for _, im := range []model.Image{img, other, third} {
+		if _, err := SetImageTags(user.ID, im.ID, []string{"beach", "2026"}); err != nil {
+			t.Fatalf("set tags: %v", err)
+		}
+	}
+
+	if err := DeleteImage(context.Background(), &img); err != nil {
+		t.Fatalf("delete image: %v", err)
+	}
+	if err := BatchDeleteImages(context.Background(), []model.Image{other}); err != nil {
+		t.Fatalf("batch delete: %v", err)
+	}
+
+	for _, id := range []uint{img.ID, other.ID} {
+		var albumRows, tagRows int64
+		db.DB.Model(&model.AlbumImage{}).Where("image_id = ?", id).Count(&albumRows)
+		db.DB.Model(&model.ImageTag{}).Where("image_id = ?", id).Count(&tagRows)
+		if albumRows != 0 || tagRows != 0 {
+			t.Fatalf("image %d left %d album rows and %d tag rows", id, albumRows, tagRows)
+		}
+	}
+
+	// 仍存在的图片保留其相册与标签
+	images, total, err := ListUserImages(user.ID, ImageListOptions{AlbumID: album.ID})
+	if err != nil || total != 1 || images[0].ID != third.ID {
+		t.Fatalf("album images after delete = %v (total %d, %v)", imageIDs(images), total, err)
+	}
+	tags, err := ListTags(user.ID)
+	if err != nil || len(tags) != 2 || tags[0].ImageCount != 1 {
+		t.Fatalf("tags after delete = %+v, %v", tags, err)
+	}
+}
+
+func TestSearchImages(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+
+	named := func(owner uint, key, original string, tags ...string) model.Image {
+		t.Helper()
+		img := createStoredImage(t, owner, key)
+		if err := db.DB.Model(&img).Update("original_filename", original).Error; err != nil {
+			t.Fatalf("set original filename: %v", err)
+		}
+		if len(tags) > 0 {
+			if _, err := SetImageTags(owner, img.ID, tags); err != nil {
+				t.Fatalf("set tags: %v", err)
+			}
+		}
+		return img
+	}
+	sunset := named(alice.ID, "2026/02/13/a.png", "Sunset_Beach.JPG", "holiday")
+	sunrise := named(alice.ID, "2026/02/13/b.png", "sunrise.png", "holiday", "mountain")
+	report := named(alice.ID, "2026/02/13/c.png", "report 100%.png")
+	named(bob.ID, "2026/02/13/d.png", "sunset.png", "holiday")
+
+	tests := []struct {
+		query string
+		want  []uint
+	}{
+		{query: "sunset", want: []uint{sunset.ID}},
+		{query: "BEACH", want: []uint{sunset.ID}},
+		{query: "sun", want: []uint{sunrise.ID, sunset.ID}},
+		{query: "mountain", want: []uint{sunrise.ID}},
+		{query: "holi", want: []uint{sunrise.ID, sunset.ID}},
+		{query: "sun mountain", want: []uint{sunrise.ID}},
+		{query: "sunset mountain", want: []uint{}},
+		{query: "100%", want: []uint{report.ID}},
+		{query: "t_b", want: []uint{sunset.ID}},
+		{query: "%", want: []uint{report.ID}},
+	}
+	for _, tt := range tests {
+		images, total, err := SearchImages(alice.ID, tt.query, ImageListOptions{})
+		if err != nil {
+			t.Fatalf("search %q: %v", tt.query, err)
+		}
+		if got := imageIDs(images); !reflect.DeepEqual(got, tt.want) || total != int64(len(tt.want)) {
+			t.Errorf("search %q = %v (total %d), want %v", tt.query, got, total, tt.want)
+		}
+	}
+
+	// 其他用户的同名图片与标签不会出现在结果中
+	images, _, err := SearchImages(bob.ID, "sunset holiday", ImageListOptions{})
+	if err != nil || len(images) != 1 || images[0].UserID != bob.ID {
+		t.Fatalf("bob's search = %+v, %v", images, err)
+	}
+}
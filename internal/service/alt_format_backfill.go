@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+)
+
+// AltFormatBackfillResult 补生成 WebP 备选文件的结果统计
+type AltFormatBackfillResult struct {
+	Total     int `json:"total"`     // 没有备选文件且未因相同内容跳过过的图片记录数
+	Generated int `json:"generated"` // 本次生成的备选文件数
+	Skipped   int `json:"skipped"`   // 类型不适用、结果不小于原图或处理期间图片已变更的数量
+	Missing   int `json:"missing"`   // 存储中原文件不存在的数量
+	Failed    int `json:"failed"`    // 读取或写入失败的数量
+}
+
+// altFormatPendingCondition 尚未生成备选文件、且当前内容未被跳过过的图片 (参数为空字符串)
+const altFormatPendingCondition = "webp_path = ? AND (alt_skipped_hash = '' OR alt_skipped_hash <> hash)"
+
+// BackfillAltFormats 为开启 ConfigGenerateAltFormats 之前上传的图片补生成 WebP 备选文件
+// 按图片 ID 分批遍历没有备选文件的记录，生成方式与上传时一致；原文件缺失的图片记录日志后跳过。
+// 只在记录未变化 (备选文件仍为空且内容哈希不变) 时写入备选路径，不持有锁，可在服务运行时执行；
+// 类型不适用或结果不小于原图而跳过的图片记录当时的内容哈希，内容未变化时之后不再处理；
+// 中断后重复执行即可继续 (已生成或已跳过的图片不再处理)。progress 可为 nil
+func BackfillAltFormats(batchSize int, progress func(done, total int)) (*AltFormatBackfillResult, error) {
+	if !GetBool(consts.ConfigGenerateAltFormats) {
+		return nil, errors.New("未开启 generate_alt_formats，无需生成备选文件")
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	store, err := ImageStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := db.DB.Model(&model.Image{}).Where(altFormatPendingCondition, "").Count(&total).Error; err != nil {
+		return nil, err
+	}
+	result := &AltFormatBackfillResult{Total: int(total)}
+
+	ctx := context.Background()
+	done := 0
+	var lastID uint
+	for {
+		var images []model.Image
+		if err := db.DB.Select("id", "path", "size", "mime_type", "hash").
+			Where("id > ?", lastID).Where(altFormatPendingCondition, "").
+			Order("id asc").Limit(batchSize).Find(&images).Error; err != nil {
+			return result, err
+		}
+		if len(images) == 0 {
+			break
+		}
+
+		for _, img := range images {
+			backfillAltFormat(ctx, store, img, result)
+			done++
+			if progress != nil {
+				progress(done, result.Total)
+			}
+		}
+		lastID = images[len(images)-1].ID
+	}
+
+	return result, nil
+}
+
+// backfillAltFormat 为单张图片生成 WebP 备选文件并更新统计
+func backfillAltFormat(ctx context.Context, store storage.Storage, img model.Image, result *AltFormatBackfillResult) {
+	if !webpAltSourceTypes[utils.ImageTypeForExt(img.MimeType)] {
+		markAltFormatSkipped(img)
+		result.Skipped++
+		return
+	}
+	key, err := storage.CleanKey(img.Path)
+	if err != nil {
+		log.Printf("[AltFormat] 跳过非法图片路径 %q (id=%d)", img.Path, img.ID)
+		result.Failed++
+		return
+	}
+
+	tempDir := os.TempDir()
+	if committer, ok := store.(storage.FileCommitter); ok {
+		if tempDir, err = committer.TempDir(key); err != nil {
+			log.Printf("[AltFormat] 创建临时目录失败 %s: %v", key, err)
+			result.Failed++
+			return
+		}
+	}
+
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("[AltFormat] 原文件不存在，跳过 %s (id=%d)", key, img.ID)
+			result.Missing++
+			return
+		}
+		log.Printf("[AltFormat] 读取原文件失败 %s: %v", key, err)
+		result.Failed++
+		return
+	}
+	stored, err := streamToTempFile(r, tempDir, img.Size)
+	_ = r.Close()
+	if err != nil {
+		log.Printf("[AltFormat] 读取原文件失败 %s: %v", key, err)
+		result.Failed++
+		return
+	}
+	defer removeTempFile(stored)
+
+	releaseOp, err := acquireImageOp(ctx)
+	if err != nil {
+		result.Failed++
+		return
+	}
+	alt := generateWebPAlternative(stored, img.MimeType, tempDir)
+	releaseOp()
+	if alt == nil {
+		markAltFormatSkipped(img)
+		result.Skipped++
+		return
+	}
+	altKey := storeWebPAlternative(ctx, store, key, alt)
+	if altKey == "" {
+		result.Failed++
+		return
+	}
+
+	// 处理期间图片被替换 (哈希变化)、删除或已由其他途径生成备选文件时放弃本次结果
+	update := db.DB.Model(&model.Image{}).Where("id = ? AND webp_path = ? AND hash = ?", img.ID, "", img.Hash).
+		Update("webp_path", altKey)
+	if update.Error == nil && update.RowsAffected > 0 {
+		result.Generated++
+		return
+	}
+	if update.Error != nil {
+		log.Printf("[AltFormat] 更新图片记录失败 (id=%d): %v", img.ID, update.Error)
+	}
+	// 替换图片时生成的备选文件使用相同的键，记录已指向该键时不能删除
+	var current model.Image
+	if err := db.DB.Select("webp_path").Where("id = ?", img.ID).First(&current).Error; err != nil || current.WebpPath != altKey {
+		deleteStoredImage(store, altKey)
+	}
+	result.Skipped++
+}
+
+// markAltFormatSkipped 记录跳过时的内容哈希，图片内容未变化 (未被替换) 时不再重复处理
+func markAltFormatSkipped(img model.Image) {
+	if img.Hash == "" {
+		return
+	}
+	if err := db.DB.Model(&model.Image{}).Where("id = ? AND hash = ?", img.ID, img.Hash).
+		UpdateColumn("alt_skipped_hash", img.Hash).Error; err != nil {
+		log.Printf("[AltFormat] 记录跳过状态失败 (id=%d): %v", img.ID, err)
+	}
+}
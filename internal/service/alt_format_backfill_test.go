@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image/gif"
+	"image/jpeg"
+	"path"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+// createBackfillImage 写入一条没有备选文件的图片记录 (上传时未开启 ConfigGenerateAltFormats)
+func createBackfillImage(t *testing.T, userID uint, key, mimeType string, data []byte) model.Image {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	img := model.Image{Filename: path.Base(key), Path: key, Size: int64(len(data)), MimeType: mimeType,
+		Hash: hex.EncodeToString(sum[:]), UploadedAt: time.Now().Unix(), UserID: userID}
+	if err := db.DB.Create(&img).Error; err != nil {
+		t.Fatalf("create image: %v", err)
+	}
+	return img
+}
+
+func TestBackfillAltFormatsGeneratesMissingAlternatives(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "backfill")
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, gradientImage(256, 256), &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	putObject(t, store, "2026/photo.jpg", buf.Bytes())
+	photo := createBackfillImage(t, user.ID, "2026/photo.jpg", ".jpg", buf.Bytes())
+	createBackfillImage(t, user.ID, "2026/missing.jpg", ".jpg", []byte("gone"))
+
+	if _, err := BackfillAltFormats(10, nil); err == nil {
+		t.Fatal("backfill should fail while generate_alt_formats is off")
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigGenerateAltFormats: "true"})
+	var lastDone, lastTotal int
+	result, err := BackfillAltFormats(1, func(done, total int) { lastDone, lastTotal = done, total })
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if result.Total != 2 || result.Generated != 1 || result.Missing != 1 || result.Failed != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	if lastDone != 2 || lastTotal != 2 {
+		t.Fatalf("last progress = %d/%d, want 2/2", lastDone, lastTotal)
+	}
+
+	var stored model.Image
+	db.DB.First(&stored, photo.ID)
+	if stored.WebpPath == "" {
+		t.Fatal("webp_path should be set")
+	}
+	alt, err := readObject(t, store, stored.WebpPath)
+	if err != nil {
+		t.Fatalf("read alternative: %v", err)
+	}
+	if len(alt) < 12 || string(alt[8:12]) != "WEBP" || len(alt) >= buf.Len() {
+		t.Fatalf("alternative is not a smaller WebP (%d bytes)", len(alt))
+	}
+
+	// 已生成的图片不再处理，原文件缺失的图片仍待处理
+	result, err = BackfillAltFormats(10, nil)
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if result.Total != 1 || result.Generated != 0 || result.Missing != 1 {
+		t.Fatalf("second run = %+v", result)
+	}
+}
+
+func TestBackfillAltFormatsRemembersSkippedImages(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigGenerateAltFormats: "true"})
+	user := createTestUser(t, "backfill")
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, gradientImage(8, 8), nil); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	putObject(t, store, "2026/anim.gif", buf.Bytes())
+	img := createBackfillImage(t, user.ID, "2026/anim.gif", ".gif", buf.Bytes())
+
+	result, err := BackfillAltFormats(10, nil)
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if result.Total != 1 || result.Skipped != 1 {
+		t.Fatalf("first run = %+v", result)
+	}
+
+	result, err = BackfillAltFormats(10, nil)
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if result.Total != 0 || result.Skipped != 0 {
+		t.Fatalf("skipped image should not be processed again, got %+v", result)
+	}
+
+	// 内容变化 (如被替换) 后重新处理
+	db.DB.Model(&img).UpdateColumn("hash", "changed")
+	result, err = BackfillAltFormats(10, nil)
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if result.Total != 1 {
+		t.Fatalf("changed image should be processed again, got %+v", result)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnonymousUsername 访客上传所归属的系统用户的用户名
+// 包含注册时不允许的字符 @，不会与普通用户冲突
+const AnonymousUsername = "@anonymous"
+
+// anonymousStorageQuota 匿名系统用户的存储配额，实际限额按 IP 计算 (ConfigAnonymousUploadBytesPerIP)
+const anonymousStorageQuota int64 = 1 << 62
+
+var (
+	// ErrAnonymousUploadDisabled 站点未开启访客上传
+	ErrAnonymousUploadDisabled = errors.New("当前站点未开启免登录上传")
+	// ErrAnonymousUploadLimit 同一 IP 的访客图片数量或占用空间已达上限
+	ErrAnonymousUploadLimit = errors.New("免登录上传已达上限")
+)
+
+// anonymousPending 某个 IP 正在进行中 (尚未写入数据库) 的访客上传
+type anonymousPending struct {
+	count int
+	bytes int64
+}
+
+var (
+	anonymousUserMu sync.Mutex
+	anonymousUserID uint
+
+	// anonymousUploadMu 保护 anonymousPendings，并串行化按 IP 限额的检查，避免并发上传同时通过检查
+	anonymousUploadMu sync.Mutex
+	anonymousPendings = make(map[string]*anonymousPending)
+)
+
+// AnonymousUserID 返回匿名系统用户的 ID，不存在时创建
+// 该用户处于停用状态且密码无效，无法登录或重置密码，仅作为访客图片的归属
+func AnonymousUserID() (uint, error) {
+	anonymousUserMu.Lock()
+	defer anonymousUserMu.Unlock()
+	if anonymousUserID != 0 {
+		return anonymousUserID, nil
+	}
+
+	var user model.User
+	// 与普通用户一样按规范化后的形式比较；该用户名故意不符合用户名策略，不经过 ValidateUsernamePolicy
+	err := db.DB.Unscoped().Where("LOWER(username) = ?", UsernameKey(AnonymousUsername)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		quota := anonymousStorageQuota
+		user = model.User{
+			Username:      UsernameKey(AnonymousUsername),
+			DisplayName:   "anonymous",
+			Password:      "!",
+			Status:        3,
+			Email:         "anonymous@perfect-pic.invalid",
+			EmailVerified: true,
+			StorageQuota:  &quota,
+		}
+		err = db.DB.Create(&user).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+	anonymousUserID = user.ID
+	return user.ID, nil
+}
+
+// ProcessAnonymousUpload 处理访客 (未登录) 上传，图片归属于匿名系统用户并记录上传者 IP
+// 同一 IP (IPv6 按 /64 网段) 保留的访客图片数量与占用空间分别受 ConfigAnonymousUploadCountPerIP 与 ConfigAnonymousUploadBytesPerIP 限制，
+// 图片删除 (包括到期清理) 后额度随之释放；ConfigAnonymousImageTTLDays 大于 0 时按其设置图片的过期时间，
+// 到期后与其他过期图片一同由 PurgeExpiredImages 删除。验证码由调用方校验
+func ProcessAnonymousUpload(ctx context.Context, fh *multipart.FileHeader, ip string) (*model.Image, string, error) {
+	if !GetBool(consts.ConfigAllowAnonymousUpload) {
+		return nil, "", ErrAnonymousUploadDisabled
+	}
+
+	file := formUploadSource(fh)
+	file.UploaderIP = ip
+	file.UploaderNetwork = anonymousQuotaKey(ip)
+	if days := GetInt(consts.ConfigAnonymousImageTTLDays); days > 0 {
+		expiresAt := time.Now().Add(time.Duration(days) * 24 * time.Hour).Unix()
+		file.ExpiresAt = &expiresAt
+	}
+	valid, ext, err := validateUploadSource(file)
+	if !valid {
+		return nil, "", err
+	}
+
+	uid, err := AnonymousUserID()
+	if err != nil {
+		log.Printf("Get anonymous user error: %v\n", err)
+		return nil, "", errors.New("系统错误: 无法创建匿名用户")
+	}
+
+	release, err := reserveAnonymousUpload(uid, ip, file.UploaderNetwork, file.Size)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
+	return saveImageUpload(ctx, file, uid, ext, 0, anonymousStorageQuota)
+}
+
+// anonymousQuotaKey 返回按 IP 限额所用的地址：IPv4 为完整地址，IPv6 为所在 /64 网段
+// 同一用户通常可以任意使用整个 /64 网段内的地址，按单个 IPv6 地址限额形同虚设
+func anonymousQuotaKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.String()
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// reserveAnonymousUpload 检查 network (见 anonymousQuotaKey) 再上传 size 字节是否超出限额，通过时预占额度直到返回的 release 被调用
+// 已保存的访客图片以数据库为准 (此前未记录网段的图片按 IP 匹配)，进行中的上传计入内存中的预占额度
+func reserveAnonymousUpload(uid uint, ip, network string, size int64) (release func(), err error) {
+	countLimit := GetInt(consts.ConfigAnonymousUploadCountPerIP)
+	bytesLimit := GetInt64(consts.ConfigAnonymousUploadBytesPerIP)
+
+	anonymousUploadMu.Lock()
+	defer anonymousUploadMu.Unlock()
+
+	pending := anonymousPendings[network]
+	if pending == nil {
+		pending = &anonymousPending{}
+	}
+	if countLimit > 0 || bytesLimit > 0 {
+		var usage struct {
+			Count int64
+			Bytes int64
+		}
+		if err := db.DB.Model(&model.Image{}).
+			Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS bytes").
+			Where("user_id = ? AND (uploader_network = ? OR (uploader_network = '' AND uploader_ip = ?))", uid, network, ip).
+			Scan(&usage).Error; err != nil {
+			log.Printf("Count anonymous uploads error: %v\n", err)
+			return nil, errors.New("查询上传记录失败")
+		}
+		if countLimit > 0 && int(usage.Count)+pending.count+1 > countLimit {
+			return nil, fmt.Errorf("%w: 同一 IP 最多保留 %d 张免登录上传的图片", ErrAnonymousUploadLimit, countLimit)
+		}
+		if bytesLimit > 0 && usage.Bytes+pending.bytes+size > bytesLimit {
+			return nil, fmt.Errorf("%w: 同一 IP 免登录上传的图片最多占用 %s", ErrAnonymousUploadLimit, FormatUploadSizeLimit(bytesLimit))
+		}
+	}
+
+	pending.count++
+	pending.bytes += size
+	anonymousPendings[network] = pending
+	return func() {
+		anonymousUploadMu.Lock()
+		defer anonymousUploadMu.Unlock()
+		pending.count--
+		pending.bytes -= size
+		if pending.count <= 0 {
+			delete(anonymousPendings, network)
+		}
+	}, nil
+}
+
+// ScheduleAnonymousImageExpiry 为尚未设置过期时间的访客图片 (改为按过期时间清理之前上传的) 按上传时间补充过期时间，
+// 之后由 PurgeExpiredImages 统一删除；ConfigAnonymousImageTTLDays 为 0 时不做处理。返回更新的数量
+func ScheduleAnonymousImageExpiry() (int64, error) {
+	days := GetInt(consts.ConfigAnonymousImageTTLDays)
+	if days <= 0 {
+		return 0, nil
+	}
+	uid, err := AnonymousUserID()
+	if err != nil {
+		return 0, err
+	}
+	ttl := int64(days) * 24 * 60 * 60
+	result := db.DB.Model(&model.Image{}).Where("user_id = ? AND expires_at IS NULL", uid).
+		UpdateColumn("expires_at", gorm.Expr("uploaded_at + ?", ttl))
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+// pngFileHeader 构造一个包含 PNG 图片的表单文件
+func pngFileHeader(t *testing.T, name string) *multipart.FileHeader {
+	t.Helper()
+	var img bytes.Buffer
+	if err := png.Encode(&img, gradientImage(16, 16)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", name)
+	_, _ = part.Write(img.Bytes())
+	_ = w.Close()
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("parse multipart: %v", err)
+	}
+	t.Cleanup(func() { _ = req.MultipartForm.RemoveAll() })
+	return req.MultipartForm.File["file"][0]
+}
+
+func TestAnonymousQuotaKey(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.7":          "203.0.113.7",
+		"::ffff:203.0.113.7":   "203.0.113.7",
+		"2001:db8:1:2:3:4:5:6": "2001:db8:1:2::/64",
+		"2001:db8:1:2:ffff::1": "2001:db8:1:2::/64",
+		"2001:db8:1:3::1":      "2001:db8:1:3::/64",
+		"not-an-ip":            "not-an-ip",
+	}
+	for ip, want := range tests {
+		if got := anonymousQuotaKey(ip); got != want {
+			t.Errorf("anonymousQuotaKey(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestAnonymousUploadLimitSharedAcrossIPv6Prefix(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigAllowAnonymousUpload:      "true",
+		consts.ConfigAnonymousUploadCountPerIP: "1",
+	})
+
+	if _, _, err := ProcessAnonymousUpload(context.Background(), pngFileHeader(t, "a.png"), "2001:db8:1:2::1"); err != nil {
+		t.Fatalf("first upload: %v", err)
+	}
+	_, _, err := ProcessAnonymousUpload(context.Background(), pngFileHeader(t, "b.png"), "2001:db8:1:2::99")
+	if !errors.Is(err, ErrAnonymousUploadLimit) {
+		t.Fatalf("same /64 should share the quota, got %v", err)
+	}
+	if _, _, err := ProcessAnonymousUpload(context.Background(), pngFileHeader(t, "c.png"), "2001:db8:1:3::1"); err != nil {
+		t.Fatalf("another /64 should have its own quota: %v", err)
+	}
+}
+
+func TestAnonymousUploadSetsExpiry(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigAllowAnonymousUpload:  "true",
+		consts.ConfigAnonymousImageTTLDays: "3",
+	})
+
+	img, _, err := ProcessAnonymousUpload(context.Background(), pngFileHeader(t, "a.png"), "203.0.113.7")
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	var stored model.Image
+	db.DB.First(&stored, img.ID)
+	want := time.Now().Add(3 * 24 * time.Hour).Unix()
+	if stored.ExpiresAt == nil || *stored.ExpiresAt < want-5 || *stored.ExpiresAt > want+5 {
+		t.Fatalf("expires_at = %v, want about %d", stored.ExpiresAt, want)
+	}
+	if stored.UploaderIP != "203.0.113.7" || stored.UploaderNetwork != "203.0.113.7" {
+		t.Fatalf("uploader = %q / %q", stored.UploaderIP, stored.UploaderNetwork)
+	}
+
+	// 到期后与其他过期图片一同被清理
+	deleted, err := PurgeExpiredImages(time.Now().Add(4 * 24 * time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("purge = %d, %v", deleted, err)
+	}
+}
+
+func TestScheduleAnonymousImageExpiry(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigAnonymousImageTTLDays: "7"})
+	uid, err := AnonymousUserID()
+	if err != nil {
+		t.Fatalf("anonymous user: %v", err)
+	}
+	legacy := createStoredImage(t, uid, "legacy.png")
+	kept := int64(123)
+	withExpiry := createStoredImage(t, uid, "expiring.png")
+	db.DB.Model(&withExpiry).Update("expires_at", kept)
+	other := createTestUser(t, "member")
+	owned := createStoredImage(t, other.ID, "owned.png")
+
+	scheduled, err := ScheduleAnonymousImageExpiry()
+	if err != nil || scheduled != 1 {
+		t.Fatalf("scheduled = %d, %v", scheduled, err)
+	}
+
+	var got model.Image
+	db.DB.First(&got, legacy.ID)
+	if got.ExpiresAt == nil || *got.ExpiresAt != legacy.UploadedAt+7*24*60*60 {
+		t.Fatalf("legacy expires_at = %v", got.ExpiresAt)
+	}
+	got = model.Image{}
+	db.DB.First(&got, withExpiry.ID)
+	if got.ExpiresAt == nil || *got.ExpiresAt != kept {
+		t.Fatalf("existing expiry should be kept, got %v", *got.ExpiresAt)
+	}
+	got = model.Image{}
+	db.DB.First(&got, owned.ID)
+	if got.ExpiresAt != nil {
+		t.Fatalf("member images should not expire, got %v", got.ExpiresAt)
+	}
+}
+
+func TestAnonymousUserIsReusedAndHiddenFromUserList(t *testing.T) {
+	setupTestDB(t)
+	uid, err := AnonymousUserID()
+	if err != nil {
+		t.Fatalf("anonymous user: %v", err)
+	}
+	createTestUser(t, "Visible")
+
+	// 进程内缓存清空后仍按规范化后的用户名找到同一个用户
+	resetTestState()
+	again, err := AnonymousUserID()
+	if err != nil || again != uid {
+		t.Fatalf("anonymous user = %d, %v; want %d", again, err, uid)
+	}
+
+	users, total, err := ListUsers(UserListOptions{Keyword: "VISIBLE"})
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if total != 1 || len(users) != 1 || users[0].ID == uid {
+		t.Fatalf("users = %+v (total %d), want only the visible user", users, total)
+	}
+	if _, total, _ := ListUsers(UserListOptions{Keyword: "anonymous"}); total != 0 {
+		t.Fatalf("anonymous user listed, total = %d", total)
+	}
+}
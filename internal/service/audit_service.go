@@ -0,0 +1,198 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计操作类型
+const (
+	AuditActionLogin            = "user.login"              // 登录成功
+	AuditActionPasswordChange   = "user.password_change"    // 修改密码
+	AuditActionPasswordReset    = "user.password_reset"     // 通过邮件重置密码
+	AuditActionAccountDeletion  = "user.account_deletion"   // 申请注销账号
+	AuditActionUserBan          = "admin.user_ban"          // 封禁用户
+	AuditActionUserUnban        = "admin.user_unban"        // 解封用户
+	AuditActionUserDelete       = "admin.user_delete"       // 删除用户
+	AuditActionUserQuota        = "admin.user_quota"        // 修改用户存储配额
+	AuditActionSettingsUpdate   = "admin.settings_update"   // 修改系统设置
+	AuditActionImpersonate      = "admin.impersonate"       // 模拟登录为其他用户
+	AuditActionStorageRecompute = "admin.storage_recompute" // 重新统计所有用户的已用空间
+)
+
+// auditRedacted 敏感字段脱敏后的占位值
+const auditRedacted = "[REDACTED]"
+
+// auditSensitiveKeys 元数据中键名 (不区分大小写) 与之完全相同的值会被脱敏
+// 按完整键名匹配，避免误伤 keys、user_agent 等普通字段
+var auditSensitiveKeys = map[string]bool{
+	"password":         true,
+	"old_password":     true,
+	"new_password":     true,
+	"current_password": true,
+	"token":            true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"delete_token":     true,
+	"secret":           true,
+	"client_secret":    true,
+	"captcha":          true,
+	"captcha_answer":   true,
+	"api_key":          true,
+	"access_key":       true,
+	"secret_key":       true,
+	"private_key":      true,
+}
+
+// auditQueueSize 审计日志写入队列的容量，队列已满时新的记录被丢弃
+const auditQueueSize = 1024
+
+var (
+	auditQueue   chan model.AuditLog
+	auditQueueMu sync.RWMutex
+	auditDoneCh  chan struct{}
+)
+
+// AuditTargetUser 返回以用户为操作对象时的 Target 字符串
+func AuditTargetUser(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// StartAuditWriter 启动后台写入审计日志的协程 (重复调用无效)
+func StartAuditWriter() {
+	auditQueueMu.Lock()
+	defer auditQueueMu.Unlock()
+	if auditQueue != nil {
+		return
+	}
+	auditQueue = make(chan model.AuditLog, auditQueueSize)
+	auditDoneCh = make(chan struct{})
+	go func(queue <-chan model.AuditLog, done chan<- struct{}) {
+		defer close(done)
+		for entry := range queue {
+			writeAuditLog(entry)
+		}
+	}(auditQueue, auditDoneCh)
+}
+
+// StopAuditWriter 停止接收新的审计日志，并在超时前尽量写完队列中剩余的记录
+func StopAuditWriter(timeout time.Duration) {
+	auditQueueMu.Lock()
+	queue, done := auditQueue, auditDoneCh
+	auditQueue = nil
+	auditQueueMu.Unlock()
+	if queue == nil {
+		return
+	}
+	close(queue)
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("⚠️ 审计日志未能在 %v 内写完，剩余记录将被丢弃", timeout)
+	}
+}
+
+// RecordAudit 记录一条审计日志
+// 尽力而为且不阻塞主流程：后台写入协程已启动时放入队列异步写入 (队列已满时丢弃并记录日志)，
+// 未启动时同步写入；写入失败只记录日志。metadata 中的敏感字段会被脱敏后再序列化
+func RecordAudit(actorID uint, action, target, ip string, metadata map[string]interface{}) {
+	entry := model.AuditLog{
+		ActorID:   actorID,
+		Action:    action,
+		Target:    target,
+		IP:        ip,
+		CreatedAt: time.Now().Unix(),
+	}
+	if len(metadata) > 0 {
+		if data, err := json.Marshal(redactAuditMetadata(metadata)); err == nil {
+			entry.Metadata = string(data)
+		}
+	}
+
+	auditQueueMu.RLock()
+	defer auditQueueMu.RUnlock()
+	if auditQueue == nil {
+		writeAuditLog(entry)
+		return
+	}
+	select {
+	case auditQueue <- entry:
+	default:
+		log.Printf("Audit log queue full, dropped (action=%s)\n", action)
+	}
+}
+
+// writeAuditLog 写入一条审计日志，失败只记录日志
+func writeAuditLog(entry model.AuditLog) {
+	if err := db.DB.Create(&entry).Error; err != nil {
+		log.Printf("Record audit log error: %v (action=%s)\n", err, entry.Action)
+	}
+}
+
+func redactAuditMetadata(metadata map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		if auditSensitiveKeys[strings.ToLower(k)] {
+			out[k] = auditRedacted
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = redactAuditMetadata(nested)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// AuditLogListOptions 审计日志查询参数
+type AuditLogListOptions struct {
+	Page     int
+	PageSize int
+	ActorID  uint   // 0 表示不过滤
+	Action   string // 留空表示不过滤
+	Since    int64  // 起始时间 (Unix 秒，含)，0 表示不限制
+	Until    int64  // 结束时间 (Unix 秒，含)，0 表示不限制
+}
+
+// ListAuditLogs 分页查询审计日志，按时间倒序
+func ListAuditLogs(opts AuditLogListOptions) ([]model.AuditLog, int64, error) {
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PageSize < 1 {
+		opts.PageSize = 20
+	}
+	if opts.PageSize > 100 {
+		opts.PageSize = 100
+	}
+
+	query := db.DB.Model(&model.AuditLog{})
+	if opts.ActorID != 0 {
+		query = query.Where("actor_id = ?", opts.ActorID)
+	}
+	if opts.Action != "" {
+		query = query.Where("action = ?", opts.Action)
+	}
+	if opts.Since > 0 {
+		query = query.Where("created_at >= ?", opts.Since)
+	}
+	if opts.Until > 0 {
+		query = query.Where("created_at <= ?", opts.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	logs := make([]model.AuditLog, 0)
+	err := query.Order("id desc").Offset((opts.Page - 1) * opts.PageSize).Limit(opts.PageSize).Find(&logs).Error
+	return logs, total, err
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+func TestRedactAuditMetadataMatchesExactFieldNames(t *testing.T) {
+	got := redactAuditMetadata(map[string]interface{}{
+		"keys":       []string{"site_name", "jwt_secret"},
+		"user_agent": "curl/8.0",
+		"password":   "hunter2",
+		"Token":      "abc",
+		"nested":     map[string]interface{}{"secret": "s", "monkey": "m"},
+	})
+
+	if _, ok := got["keys"].([]string); !ok {
+		t.Fatalf("keys should be kept, got %v", got["keys"])
+	}
+	if got["user_agent"] != "curl/8.0" {
+		t.Fatalf("user_agent should be kept, got %v", got["user_agent"])
+	}
+	if got["password"] != auditRedacted || got["Token"] != auditRedacted {
+		t.Fatalf("password/token should be redacted, got %v", got)
+	}
+	nested := got["nested"].(map[string]interface{})
+	if nested["secret"] != auditRedacted || nested["monkey"] != "m" {
+		t.Fatalf("unexpected nested metadata: %v", nested)
+	}
+}
+
+func TestRecordAuditWritesSynchronouslyWithoutWriter(t *testing.T) {
+	setupTestDB(t)
+
+	RecordAudit(1, AuditActionSettingsUpdate, "setting", "127.0.0.1", map[string]interface{}{
+		"keys": []string{"site_name"},
+	})
+
+	var entry model.AuditLog
+	if err := db.DB.First(&entry).Error; err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(entry.Metadata), &metadata); err != nil {
+		t.Fatalf("unmarshal metadata: %v", err)
+	}
+	if keys, ok := metadata["keys"].([]interface{}); !ok || len(keys) != 1 || keys[0] != "site_name" {
+		t.Fatalf("keys not recorded: %s", entry.Metadata)
+	}
+}
+
+func TestStopAuditWriterFlushesQueue(t *testing.T) {
+	setupTestDB(t)
+
+	StartAuditWriter()
+	for i := 0; i < 10; i++ {
+		RecordAudit(1, AuditActionSettingsUpdate, "setting", "127.0.0.1", nil)
+	}
+	StopAuditWriter(5 * time.Second)
+
+	var count int64
+	db.DB.Model(&model.AuditLog{}).Count(&count)
+	if count != 10 {
+		t.Fatalf("expected 10 audit logs after stop, got %d", count)
+	}
+}
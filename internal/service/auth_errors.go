@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// AuthErrorCode 认证相关业务错误码
+type AuthErrorCode string
+
+const (
+	AuthErrorValidation      AuthErrorCode = "validation"        // 参数不合法
+	AuthErrorUnauthorized    AuthErrorCode = "unauthorized"      // 凭证错误
+	AuthErrorForbidden       AuthErrorCode = "forbidden"         // 账号状态不允许操作 (封禁、停用、未验证等)
+	AuthErrorConflict        AuthErrorCode = "conflict"          // 资源冲突 (用户名/邮箱已存在)
+	AuthErrorReservedName    AuthErrorCode = "reserved_name"     // 用户名为保留用户名
+	AuthErrorNotFound        AuthErrorCode = "not_found"         // 资源不存在
+	AuthErrorLocked          AuthErrorCode = "locked"            // 账号已被锁定，需要管理员或通过邮件解锁
+	AuthErrorTooManyAttempts AuthErrorCode = "too_many_attempts" // 短时间内失败次数过多，临时锁定
+	AuthErrorCooldown        AuthErrorCode = "cooldown"          // 操作处于冷却期，需等待 RetryAfter 后重试
+	AuthErrorInternal        AuthErrorCode = "internal"          // 系统内部错误
+)
+
+// AuthError 认证业务错误，Message 可直接返回给用户 (中文)
+// 设置了 MessageID 时可通过 Localize 按请求语言渲染，Message 作为缺省文本
+type AuthError struct {
+	Code    AuthErrorCode
+	Message string
+	// MessageID/Params 可本地化的消息标识与参数 (可选)
+	MessageID MessageID
+	Params    []interface{}
+	// Attempt 登录失败计数状态 (仅登录相关错误携带)
+	Attempt *LoginAttemptStatus
+	// RetryAfter 冷却剩余时间 (仅 AuthErrorCooldown 携带)
+	RetryAfter time.Duration
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// Localize 按语言渲染错误信息，未设置 MessageID 时返回 Message
+func (e *AuthError) Localize(locale string) string {
+	if e.MessageID == "" {
+		return e.Message
+	}
+	return LocalizeMessage(locale, e.MessageID, e.Params...)
+}
+
+func newAuthError(code AuthErrorCode, msg string) *AuthError {
+	return &AuthError{Code: code, Message: msg}
+}
+
+// newLocalizedAuthError 创建可本地化的 AuthError，Message 为对应的中文文本
+func newLocalizedAuthError(code AuthErrorCode, id MessageID, params ...interface{}) *AuthError {
+	return &AuthError{Code: code, Message: LocalizeMessage(LocaleZhCN, id, params...), MessageID: id, Params: params}
+}
+
+// AsAuthError 从错误链中提取 AuthError
+func AsAuthError(err error) (*AuthError, bool) {
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return authErr, true
+	}
+	return nil, false
+}
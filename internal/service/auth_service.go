@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// findLoginUser 按登录标识查找用户，返回用户与登录失败计数使用的键
+// 开启 ConfigAllowEmailLogin 且标识形如邮箱时先按规范化后的邮箱查找，未找到再按用户名查找
+func findLoginUser(identifier string) (model.User, string, error) {
+	var user model.User
+	if GetBool(consts.ConfigAllowEmailLogin) && strings.Contains(identifier, "@") {
+		email := utils.NormalizeEmail(identifier)
+		err := db.DB.Where("LOWER(email) = ?", email).Order("id").First(&user).Error
+		if err == nil {
+			return user, UsernameKey(user.Username), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, email, err
+		}
+	}
+
+	username := UsernameKey(identifier)
+	err := db.DB.Where("LOWER(username) = ?", username).First(&user).Error
+	return user, username, err
+}
+
+// LoginUser 校验登录标识 (用户名，开启 ConfigAllowEmailLogin 时也可为邮箱) 与密码，创建会话并签发登录 Token
+// 失败时返回 *AuthError，登录失败相关的错误会携带失败计数状态；用户不存在与密码错误统一返回 AuthErrorUnauthorized
+func LoginUser(identifier, password string, meta SessionMeta) (string, error) {
+	user, attemptKey, err := findLoginUser(identifier)
+
+	// 检查是否因短时间内连续登录失败被临时锁定 (按账号与 IP 计数，用户名与邮箱登录共用)
+	if status := GetLoginAttemptStatus(attemptKey, meta.IP); status.Locked() {
+		authErr := newLocalizedAuthError(AuthErrorTooManyAttempts, MsgLoginTooManyAttempts)
+		authErr.Attempt = &status
+		return "", authErr
+	}
+
+	if err != nil {
+		status, _ := registerLoginFailure(nil, attemptKey, meta.IP)
+		authErr := newLocalizedAuthError(AuthErrorUnauthorized, MsgLoginInvalid)
+		authErr.Attempt = &status
+		return "", authErr
+	}
+
+	// 账号已被锁定时不再校验密码，也不再累加失败次数，需通过解锁邮件或管理员解锁
+	if user.Status == 4 {
+		return "", newLocalizedAuthError(AuthErrorLocked, MsgAccountLocked)
+	}
+
+	if !VerifyPassword(user.Password, password) {
+		status, locked := registerLoginFailure(&user, attemptKey, meta.IP)
+		if locked {
+			return "", newLocalizedAuthError(AuthErrorLocked, MsgAccountLocked)
+		}
+		authErr := newLocalizedAuthError(AuthErrorUnauthorized, MsgLoginInvalid)
+		authErr.Attempt = &status
+		return "", authErr
+	}
+
+	switch user.Status {
+	case 2:
+		return "", newLocalizedAuthError(AuthErrorForbidden, MsgAccountBanned)
+	case 3:
+		return "", newLocalizedAuthError(AuthErrorForbidden, MsgAccountDisabled)
+	}
+
+	// 注销保留期内禁止登录，需通过确认邮件中的链接撤销申请
+	if user.DeletionScheduledAt != nil {
+		deleteAt := time.Unix(*user.DeletionScheduledAt, 0).Format("2006-01-02 15:04")
+		return "", newLocalizedAuthError(AuthErrorForbidden, MsgAccountDeletionPending, deleteAt)
+	}
+
+	// 检查是否阻止未验证邮箱用户登录
+	if GetBool(consts.ConfigBlockUnverifiedUsers) && user.Email != "" && !user.EmailVerified {
+		return "", newLocalizedAuthError(AuthErrorForbidden, MsgEmailNotVerified)
+	}
+
+	clearLoginFailures(&user, attemptKey, meta.IP)
+	rehashPasswordIfNeeded(&user, password)
+
+	// 创建会话并签发 Token
+	token, err := issueSessionToken(&user, meta)
+	if err != nil {
+		log.Printf("Generate login token error: %v\n", err)
+		return "", newLocalizedAuthError(AuthErrorInternal, MsgLoginFailed)
+	}
+
+	RecordAudit(user.ID, AuditActionLogin, AuditTargetUser(user.ID), meta.IP, map[string]interface{}{
+		"user_agent": meta.UserAgent,
+	})
+	notifyLoginDevice(&user, meta)
+	return token, nil
+}
+
+// ChangePassword 已登录用户修改密码，需验证当前密码 (区别于通过邮件重置密码)
+// 新密码需符合密码策略，并按当前配置的哈希方案与参数重新计算哈希；
+// 修改成功后递增 TokenVersion 并吊销全部会话，此前签发的 Token 立即失效，调用方需清除用户状态缓存
+func ChangePassword(userID uint, currentPassword, newPassword string) error {
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newAuthError(AuthErrorNotFound, "用户不存在")
+		}
+		return newAuthError(AuthErrorInternal, "查询用户失败")
+	}
+
+	if !VerifyPassword(user.Password, currentPassword) {
+		// 返回 400 而非 401，避免前端将其当作登录失效而清除登录状态
+		return newLocalizedAuthError(AuthErrorValidation, MsgCurrentPasswordWrong)
+	}
+	if err := ValidatePasswordPolicy(newPassword); err != nil {
+		return newAuthError(AuthErrorValidation, err.Error())
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return newAuthError(AuthErrorInternal, "密码加密失败")
+	}
+	if err := db.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"password":      hashedPassword,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error; err != nil {
+		log.Printf("Change password error: %v\n", err)
+		return newAuthError(AuthErrorInternal, "密码修改失败")
+	}
+
+	if err := RevokeAllSessions(user.ID); err != nil {
+		log.Printf("Revoke sessions error: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"testing"
+)
+
+func TestChangePasswordRejectsWrongCurrentPassword(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "changer")
+
+	err := ChangePassword(user.ID, "wrong-password", "N3wPassw0rd!")
+	authErr, ok := AsAuthError(err)
+	if !ok || authErr.Code != AuthErrorValidation {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if got := LocalizeError(err, LocaleEn); got != "The current password is incorrect" {
+		t.Fatalf("unexpected localized message %q", got)
+	}
+
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Password != user.Password {
+		t.Fatal("password should not change")
+	}
+}
+
+func TestChangePasswordBumpsTokenVersion(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "changer")
+
+	if err := ChangePassword(user.ID, testPassword, "N3wPassw0rd!"); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if !VerifyPassword(stored.Password, "N3wPassw0rd!") {
+		t.Fatal("new password should verify")
+	}
+	if stored.TokenVersion != user.TokenVersion+1 {
+		t.Fatalf("token version = %d, want %d", stored.TokenVersion, user.TokenVersion+1)
+	}
+}
+
+// loginUserID 登录并返回 Token 对应的用户 ID
+func loginUserID(t *testing.T, identifier string) uint {
+	t.Helper()
+	token, err := LoginUser(identifier, testPassword, SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("login %q: %v", identifier, err)
+	}
+	claims, err := utils.ParseLoginToken(token)
+	if err != nil {
+		t.Fatalf("parse login token: %v", err)
+	}
+	return claims.ID
+}
+
+func TestEmailLoginDisabledByDefault(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "erin")
+
+	if id := loginUserID(t, "erin"); id != user.ID {
+		t.Fatalf("logged in as %d, want %d", id, user.ID)
+	}
+	_, err := LoginUser("erin@example.com", testPassword, SessionMeta{IP: "127.0.0.1"})
+	if authErr, ok := AsAuthError(err); !ok || authErr.Code != AuthErrorUnauthorized {
+		t.Fatalf("email login with the setting off: got %v, want unauthorized", err)
+	}
+}
+
+func TestEmailLogin(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigAllowEmailLogin: "true"})
+	user := createTestUser(t, "erin")
+
+	for _, identifier := range []string{"erin", "erin@example.com", "  Erin@Example.COM "} {
+		if id := loginUserID(t, identifier); id != user.ID {
+			t.Fatalf("%q logged in as %d, want %d", identifier, id, user.ID)
+		}
+	}
+
+	// 邮箱不存在与密码错误返回相同的错误
+	_, unknownErr := LoginUser("nobody@example.com", testPassword, SessionMeta{IP: "127.0.0.1"})
+	_, wrongErr := LoginUser("erin@example.com", "wrong-password", SessionMeta{IP: "127.0.0.1"})
+	for _, err := range []error{unknownErr, wrongErr} {
+		if authErr, ok := AsAuthError(err); !ok || authErr.Code != AuthErrorUnauthorized {
+			t.Fatalf("got %v, want unauthorized", err)
+		}
+	}
+	if LocalizeError(unknownErr, LocaleEn) != LocalizeError(wrongErr, LocaleEn) {
+		t.Fatal("unknown email and wrong password should return the same message")
+	}
+}
+
+func TestEmailLoginPrefersEmailMatch(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigAllowEmailLogin: "true"})
+	owner := createTestUser(t, "owner", func(u *model.User) { u.Email = "shared@example.com" })
+	createTestUser(t, "shared@example.com", func(u *model.User) { u.Email = "other@example.com" })
+
+	if id := loginUserID(t, "shared@example.com"); id != owner.ID {
+		t.Fatalf("logged in as %d, want the account with that email (%d)", id, owner.ID)
+	}
+}
+
+func TestEmailLoginSharesFailureCounter(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigAllowEmailLogin:  "true",
+		consts.ConfigLoginMaxAttempts: "4",
+	})
+	createTestUser(t, "erin")
+
+	// 交替使用用户名与邮箱不能绕过临时锁定
+	for _, identifier := range []string{"erin", "erin@example.com", "ERIN", "Erin@example.com"} {
+		_, _ = LoginUser(identifier, "wrong-password", SessionMeta{IP: "203.0.113.9"})
+	}
+	_, err := LoginUser("erin@example.com", testPassword, SessionMeta{IP: "203.0.113.9"})
+	if authErr, ok := AsAuthError(err); !ok || authErr.Code != AuthErrorTooManyAttempts {
+		t.Fatalf("got %v, want too many attempts", err)
+	}
+}
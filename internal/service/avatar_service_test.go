@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"sync"
+	"testing"
+)
+
+func TestUpdateUserAvatarConcurrentKeepsOneFile(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "avatar")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u := user
+			_, errs[i] = UpdateUserAvatar(context.Background(), &u, pngFileHeader(t, "avatar.png"))
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("update %d: %v", i, err)
+		}
+	}
+
+	var stored model.User
+	if err := db.DB.First(&stored, user.ID).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join("uploads", "avatars", fmt.Sprint(user.ID)))
+	if err != nil {
+		t.Fatalf("read avatar dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one avatar file, got %d", len(entries))
+	}
+	if entries[0].Name() != stored.Avatar {
+		t.Fatalf("db points at %q, but the surviving file is %q", stored.Avatar, entries[0].Name())
+	}
+}
+
+func TestUpdateUserAvatarStoresSquareWithinMaxSize(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "avatar")
+
+	var src bytes.Buffer
+	if err := png.Encode(&src, gradientImage(2000, 1000)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	name, err := UpdateUserAvatar(context.Background(), &user, uploadFileHeader(t, "big.png", src.Bytes()))
+	if err != nil {
+		t.Fatalf("update avatar: %v", err)
+	}
+	if filepath.Ext(name) != ".png" || user.Avatar != name {
+		t.Fatalf("unexpected avatar name %q (user.Avatar %q)", name, user.Avatar)
+	}
+
+	f, err := os.Open(filepath.Join("uploads", "avatars", fmt.Sprint(user.ID), name))
+	if err != nil {
+		t.Fatalf("open stored avatar: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("decode stored avatar: %v", err)
+	}
+	maxSize := GetInt(consts.ConfigAvatarMaxSize)
+	if cfg.Width != cfg.Height || cfg.Width > maxSize {
+		t.Fatalf("stored avatar is %dx%d, want a square within %d", cfg.Width, cfg.Height, maxSize)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/utils"
+	"strings"
+	"time"
+)
+
+// 可在 ConfigCaptchaRequiredActions 中配置的操作
+const (
+	CaptchaActionLogin         = "login"
+	CaptchaActionRegister      = "register"
+	CaptchaActionPasswordReset = "password_reset"
+)
+
+// CaptchaActionAnonymousUpload 访客上传，始终需要验证码，不受 ConfigCaptchaRequiredActions 影响
+const CaptchaActionAnonymousUpload = "anonymous_upload"
+
+// 可选的验证码类型 (ConfigCaptchaProvider)
+const (
+	CaptchaProviderImage = "image" // 内置图片验证码
+	CaptchaProviderCap   = "cap"   // 自托管 Cap (https://capjs.js.org) 工作量证明验证码
+)
+
+// captchaVerifyTimeout 远程校验验证码的超时时间
+const captchaVerifyTimeout = 10 * time.Second
+
+var (
+	// ErrCaptchaInvalid 验证码错误、已过期或已被使用
+	ErrCaptchaInvalid = errors.New("验证码错误或已过期")
+	// ErrCaptchaUnavailable 验证码服务未配置或无法访问
+	ErrCaptchaUnavailable = errors.New("验证码服务暂不可用")
+)
+
+var captchaHTTPClient = &http.Client{Timeout: captchaVerifyTimeout}
+
+// CaptchaSubmission 客户端提交的验证码
+// 图片验证码使用 ID 与 Answer，Cap 使用组件返回的 Token
+type CaptchaSubmission struct {
+	ID     string
+	Answer string
+	Token  string
+}
+
+// CaptchaProviderInfo 前端渲染验证码所需的公开配置
+type CaptchaProviderInfo struct {
+	Provider string `json:"provider"`
+	// Cap 实例地址与站点 Key，前端组件的 API 地址为 {instance_url}/{site_key}/
+	CapInstanceURL string `json:"cap_instance_url,omitempty"`
+	CapSiteKey     string `json:"cap_site_key,omitempty"`
+}
+
+// CaptchaRequired 判断 action 操作是否需要校验验证码 (是否出现在 ConfigCaptchaRequiredActions 中，不区分大小写)
+func CaptchaRequired(action string) bool {
+	if action == CaptchaActionAnonymousUpload {
+		return true
+	}
+	for _, item := range strings.Split(GetString(consts.ConfigCaptchaRequiredActions), ",") {
+		if strings.EqualFold(strings.TrimSpace(item), action) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCaptchaProvider 返回当前使用的验证码类型，未知的取值按内置图片验证码处理
+func GetCaptchaProvider() string {
+	if GetString(consts.ConfigCaptchaProvider) == CaptchaProviderCap {
+		return CaptchaProviderCap
+	}
+	return CaptchaProviderImage
+}
+
+// GetCaptchaProviderInfo 返回前端需要的验证码配置，不包含站点密钥
+func GetCaptchaProviderInfo() CaptchaProviderInfo {
+	info := CaptchaProviderInfo{Provider: GetCaptchaProvider()}
+	if info.Provider == CaptchaProviderCap {
+		info.CapInstanceURL = strings.TrimRight(strings.TrimSpace(GetString(consts.ConfigCaptchaCapInstanceURL)), "/")
+		info.CapSiteKey = strings.TrimSpace(GetString(consts.ConfigCaptchaCapSiteKey))
+	}
+	return info
+}
+
+// CaptchaOrigin 返回前端需要加载脚本或连接的验证码服务源 (scheme://host)，内置图片验证码返回空字符串
+func CaptchaOrigin() string {
+	if GetCaptchaProvider() != CaptchaProviderCap {
+		return ""
+	}
+	u, err := url.Parse(strings.TrimSpace(GetString(consts.ConfigCaptchaCapInstanceURL)))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// VerifyCaptchaChallenge 按当前验证码类型校验客户端提交的验证码
+// 校验未通过时返回 ErrCaptchaInvalid，验证码服务异常时返回包装了 ErrCaptchaUnavailable 的错误
+func VerifyCaptchaChallenge(ctx context.Context, sub CaptchaSubmission) error {
+	switch GetCaptchaProvider() {
+	case CaptchaProviderCap:
+		return verifyCapToken(ctx, sub.Token)
+	default:
+		if sub.ID == "" || sub.Answer == "" || !utils.VerifyCaptcha(sub.ID, sub.Answer) {
+			return ErrCaptchaInvalid
+		}
+		return nil
+	}
+}
+
+// capVerifyURL 返回 Cap 的服务端校验地址，未单独配置时由实例地址与站点 Key 拼接
+func capVerifyURL() string {
+	if verifyURL := strings.TrimSpace(GetString(consts.ConfigCaptchaCapVerifyURL)); verifyURL != "" {
+		return verifyURL
+	}
+	instance := strings.TrimRight(strings.TrimSpace(GetString(consts.ConfigCaptchaCapInstanceURL)), "/")
+	siteKey := strings.TrimSpace(GetString(consts.ConfigCaptchaCapSiteKey))
+	if instance == "" || siteKey == "" {
+		return ""
+	}
+	return instance + "/" + url.PathEscape(siteKey) + "/siteverify"
+}
+
+// verifyCapToken 将 Cap 组件返回的 Token 提交到自托管实例校验，Token 由 Cap 服务端保证只能使用一次
+func verifyCapToken(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrCaptchaInvalid
+	}
+	verifyURL := capVerifyURL()
+	secret := GetString(consts.ConfigCaptchaCapSecret)
+	if verifyURL == "" || secret == "" {
+		return fmt.Errorf("%w: Cap 校验地址或站点密钥未配置", ErrCaptchaUnavailable)
+	}
+
+	body, err := json.Marshal(map[string]string{"secret": secret, "response": token})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCaptchaUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := captchaHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCaptchaUnavailable, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: Cap 校验返回 %s", ErrCaptchaUnavailable, resp.Status)
+	}
+
+	// Cap 对无效 Token 也可能返回 4xx，只要响应体可解析就以 success 字段为准
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return fmt.Errorf("%w: Cap 校验响应无效 (%s)", ErrCaptchaUnavailable, resp.Status)
+	}
+	if !result.Success {
+		return ErrCaptchaInvalid
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"perfect-pic-server/internal/consts"
+	"testing"
+)
+
+func TestCaptchaRequired(t *testing.T) {
+	setupTestDB(t)
+
+	// 默认三种操作均需要验证码
+	for _, action := range []string{CaptchaActionLogin, CaptchaActionRegister, CaptchaActionPasswordReset} {
+		if !CaptchaRequired(action) {
+			t.Errorf("%s should require a captcha by default", action)
+		}
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaRequiredActions: " Register "})
+	if CaptchaRequired(CaptchaActionLogin) || CaptchaRequired(CaptchaActionPasswordReset) || !CaptchaRequired(CaptchaActionRegister) {
+		t.Fatal("only register should require a captcha")
+	}
+
+	// 访客上传始终需要验证码
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaRequiredActions: ""})
+	if CaptchaRequired(CaptchaActionLogin) || !CaptchaRequired(CaptchaActionAnonymousUpload) {
+		t.Fatal("empty list should only keep the anonymous upload captcha")
+	}
+}
+
+// newCapServer 启动模拟的 Cap 校验服务，只接受 secret 为 "s3cret"、response 为 "good" 的请求
+func newCapServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var req struct {
+			Secret   string `json:"secret"`
+			Response string `json:"response"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case req.Response == "down":
+			w.WriteHeader(http.StatusBadGateway)
+		case req.Secret != "s3cret" || req.Response != "good":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"success":false}`))
+		default:
+			_, _ = w.Write([]byte(`{"success":true}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &paths
+}
+
+func TestVerifyCapToken(t *testing.T) {
+	setupTestDB(t)
+	srv, paths := newCapServer(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigCaptchaProvider:       CaptchaProviderCap,
+		consts.ConfigCaptchaCapInstanceURL: srv.URL + "/",
+		consts.ConfigCaptchaCapSiteKey:     "site key",
+		consts.ConfigCaptchaCapSecret:      "s3cret",
+	})
+	ctx := context.Background()
+
+	if err := VerifyCaptchaChallenge(ctx, CaptchaSubmission{Token: "good"}); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+	if got := (*paths)[0]; got != "/site key/siteverify" {
+		t.Fatalf("verify path = %q", got)
+	}
+	for _, token := range []string{"bad", ""} {
+		if err := VerifyCaptchaChallenge(ctx, CaptchaSubmission{Token: token}); !errors.Is(err, ErrCaptchaInvalid) {
+			t.Errorf("token %q: err = %v, want ErrCaptchaInvalid", token, err)
+		}
+	}
+	if err := VerifyCaptchaChallenge(ctx, CaptchaSubmission{Token: "down"}); !errors.Is(err, ErrCaptchaUnavailable) {
+		t.Fatalf("upstream error: err = %v, want ErrCaptchaUnavailable", err)
+	}
+
+	// 单独配置的校验地址优先
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaCapVerifyURL: srv.URL + "/custom"})
+	if err := VerifyCaptchaChallenge(ctx, CaptchaSubmission{Token: "good"}); err != nil {
+		t.Fatalf("custom verify url: %v", err)
+	}
+	if got := (*paths)[len(*paths)-1]; got != "/custom" {
+		t.Fatalf("verify path = %q, want /custom", got)
+	}
+}
+
+func TestVerifyCapTokenRequiresConfiguration(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaProvider: CaptchaProviderCap})
+	if err := VerifyCaptchaChallenge(context.Background(), CaptchaSubmission{Token: "good"}); !errors.Is(err, ErrCaptchaUnavailable) {
+		t.Fatalf("unconfigured Cap: err = %v, want ErrCaptchaUnavailable", err)
+	}
+}
+
+func TestCaptchaProviderInfoOmitsSecret(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigCaptchaProvider:       CaptchaProviderCap,
+		consts.ConfigCaptchaCapInstanceURL: "https://cap.example.com/",
+		consts.ConfigCaptchaCapSiteKey:     "key",
+		consts.ConfigCaptchaCapSecret:      "s3cret",
+	})
+
+	info := GetCaptchaProviderInfo()
+	if info.Provider != CaptchaProviderCap || info.CapInstanceURL != "https://cap.example.com" || info.CapSiteKey != "key" {
+		t.Fatalf("unexpected provider info: %+v", info)
+	}
+	raw, _ := json.Marshal(info)
+	if string(raw) != `{"provider":"cap","cap_instance_url":"https://cap.example.com","cap_site_key":"key"}` {
+		t.Fatalf("provider info JSON = %s", raw)
+	}
+	if got := CaptchaOrigin(); got != "https://cap.example.com" {
+		t.Fatalf("CaptchaOrigin = %q", got)
+	}
+}
+
+func TestCaptchaProviderInfoCachesMisconfiguredProvider(t *testing.T) {
+	d := setupTestDB(t)
+	// 选择了 Cap 但缺少实例地址与站点 Key
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaProvider: CaptchaProviderCap})
+	_ = GetCaptchaProviderInfo()
+
+	queries := countQueries(t, d)
+	for i := 0; i < 10; i++ {
+		if info := GetCaptchaProviderInfo(); info.Provider != CaptchaProviderCap || info.CapInstanceURL != "" {
+			t.Fatalf("unexpected provider info: %+v", info)
+		}
+	}
+	if got := queries.Load(); got != 0 {
+		t.Fatalf("repeated lookups ran %d queries, want 0", got)
+	}
+
+	// 修改设置后立即生效
+	setTestSettings(t, map[string]string{consts.ConfigCaptchaProvider: CaptchaProviderImage})
+	if info := GetCaptchaProviderInfo(); info.Provider != CaptchaProviderImage {
+		t.Fatalf("provider = %q after switching to image", info.Provider)
+	}
+	setTestSettings(t, map[string]string{
+		consts.ConfigCaptchaProvider:       CaptchaProviderCap,
+		consts.ConfigCaptchaCapInstanceURL: "https://cap.example.com",
+		consts.ConfigCaptchaCapSiteKey:     "key",
+	})
+	if info := GetCaptchaProviderInfo(); info.Provider != CaptchaProviderCap || info.CapSiteKey != "key" {
+		t.Fatalf("unexpected provider info after configuring Cap: %+v", info)
+	}
+}
+
+// BenchmarkGetCaptchaProviderInfo 对比配置缓存命中与每次清空缓存后的查询次数 (Cap 未配置完整)
+func BenchmarkGetCaptchaProviderInfo(b *testing.B) {
+	d := setupTestDB(b)
+	if err := UpdateSettings(map[string]string{consts.ConfigCaptchaProvider: CaptchaProviderCap}); err != nil {
+		b.Fatalf("update settings: %v", err)
+	}
+	queries := countQueries(b, d)
+
+	b.Run("cached", func(b *testing.B) {
+		_ = GetCaptchaProviderInfo()
+		before := queries.Load()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = GetCaptchaProviderInfo()
+		}
+		b.ReportMetric(float64(queries.Load()-before)/float64(b.N), "queries/op")
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		before := queries.Load()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ClearCache()
+			_ = GetCaptchaProviderInfo()
+		}
+		b.ReportMetric(float64(queries.Load()-before)/float64(b.N), "queries/op")
+	})
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// chunkUploadTTL 分片上传会话自最后一次写入起的有效期，过期未完成的会话由后台任务清理
+	chunkUploadTTL = 24 * time.Hour
+	// chunkUploadCleanupInterval 清理过期会话的间隔
+	chunkUploadCleanupInterval = 10 * time.Minute
+	// maxUploadChunks 单个会话允许的最大分片数
+	maxUploadChunks = 10000
+	// maxActiveChunkUploads 每个用户同时进行中的分片上传会话上限，防止占满临时目录
+	maxActiveChunkUploads = 5
+)
+
+var (
+	// ErrUploadNotFound 分片上传会话不存在、已过期或不属于当前用户
+	ErrUploadNotFound = errors.New("上传会话不存在或已过期")
+	// ErrInvalidChunk 分片序号或内容无效
+	ErrInvalidChunk = errors.New("分片无效")
+	// ErrUploadIncomplete 完成上传时仍有分片缺失
+	ErrUploadIncomplete = errors.New("分片尚未上传完整")
+	// ErrTooManyUploads 进行中的分片上传会话过多
+	ErrTooManyUploads = errors.New("进行中的上传任务过多，请先完成或取消已有任务")
+)
+
+// chunkUpload 一个分片上传会话，分片以 <序号>.part 保存在会话目录中
+type chunkUpload struct {
+	mu        sync.Mutex
+	ID        string
+	UserID    uint
+	Filename  string
+	TotalSize int64
+	Dir       string
+	Chunks    map[int]int64
+	Received  int64
+	ExpiresAt time.Time
+	// completing 为 true 时正在合并，不再接受新的分片
+	completing bool
+}
+
+// ChunkUploadStatus 分片上传会话的进度，客户端断线后据此续传缺失的分片
+type ChunkUploadStatus struct {
+	UploadID       string `json:"upload_id"`
+	Filename       string `json:"filename"`
+	TotalSize      int64  `json:"total_size"`
+	ReceivedBytes  int64  `json:"received_bytes"`
+	ReceivedChunks []int  `json:"received_chunks"`
+	ExpiresAt      int64  `json:"expires_at"`
+}
+
+var (
+	chunkUploadsMu sync.Mutex
+	chunkUploads   = make(map[string]*chunkUpload)
+
+	chunkUploadOnce   sync.Once
+	chunkUploadStopCh chan struct{}
+	chunkUploadDoneCh chan struct{}
+)
+
+// chunkUploadRoot 分片临时文件的根目录
+func chunkUploadRoot() string {
+	return filepath.Join(os.TempDir(), "perfect-pic-chunks")
+}
+
+// InitUpload 创建分片上传会话，返回会话 ID
+// 文件类型按文件名扩展名预检，totalSize 需不超过单文件上传限制与用户剩余配额
+func InitUpload(userID uint, totalSize int64, filename string) (string, error) {
+	filename = utils.SanitizeFilename(filename)
+	if _, err := validateImageExt(filename, GetAllowedImageTypes()); err != nil {
+		return "", err
+	}
+	if totalSize <= 0 {
+		return "", fmt.Errorf("%w: 文件大小必须大于 0", ErrInvalidChunk)
+	}
+	if maxSize := GetMaxUploadSizeBytes(); totalSize > maxSize {
+		return "", fileTooLargeError(maxSize)
+	}
+
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		log.Printf("Get user error: %v\n", err)
+		return "", errors.New("查询用户信息失败")
+	}
+	if quota := userStorageQuota(&user); user.StorageUsed+totalSize > quota {
+		return "", quotaExceededError(user.StorageUsed, quota)
+	}
+	// 提前拒绝无法完成的上传；额度在 CompleteUpload 时才真正预占
+	if err := checkDailyUpload(&user, totalSize); err != nil {
+		return "", err
+	}
+
+	upload := &chunkUpload{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Filename:  filename,
+		TotalSize: totalSize,
+		Chunks:    make(map[int]int64),
+		ExpiresAt: time.Now().Add(chunkUploadTTL),
+	}
+	upload.Dir = filepath.Join(chunkUploadRoot(), upload.ID)
+
+	chunkUploadsMu.Lock()
+	defer chunkUploadsMu.Unlock()
+	active := 0
+	for _, u := range chunkUploads {
+		if u.UserID == userID {
+			active++
+		}
+	}
+	if active >= maxActiveChunkUploads {
+		return "", ErrTooManyUploads
+	}
+	if err := os.MkdirAll(upload.Dir, 0700); err != nil {
+		log.Printf("MkdirAll error: %v\n", err)
+		return "", errors.New("系统错误: 无法创建临时目录")
+	}
+	chunkUploads[upload.ID] = upload
+	return upload.ID, nil
+}
+
+// UploadChunk 写入第 index 个分片 (从 0 开始)，重复上传同一序号时覆盖
+// 所有分片的总大小不能超过创建会话时声明的 totalSize
+func UploadChunk(userID uint, uploadID string, index int, data io.Reader) (*ChunkUploadStatus, error) {
+	if index < 0 || index >= maxUploadChunks {
+		return nil, fmt.Errorf("%w: 分片序号需在 0-%d 之间", ErrInvalidChunk, maxUploadChunks-1)
+	}
+	upload, err := getChunkUpload(userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	upload.mu.Lock()
+	if upload.completing {
+		upload.mu.Unlock()
+		return nil, ErrUploadNotFound
+	}
+	limit := upload.TotalSize - upload.Received + upload.Chunks[index]
+	upload.mu.Unlock()
+
+	// 写入临时文件时不持有锁，允许并行上传多个分片
+	tmp, err := os.CreateTemp(upload.Dir, ".chunk-*.tmp")
+	if err != nil {
+		log.Printf("Create chunk error: %v\n", err)
+		return nil, errors.New("分片保存失败")
+	}
+	n, err := io.Copy(tmp, io.LimitReader(data, limit+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// 保留原始错误，便于调用方识别请求体超出限制 (*http.MaxBytesError)
+		_ = os.Remove(tmp.Name())
+		return nil, fmt.Errorf("分片保存失败: %w", err)
+	}
+	if n == 0 || n > limit {
+		_ = os.Remove(tmp.Name())
+		if n == 0 {
+			return nil, fmt.Errorf("%w: 分片内容为空", ErrInvalidChunk)
+		}
+		return nil, fmt.Errorf("%w: 分片总大小超过声明的文件大小", ErrInvalidChunk)
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	// 等待期间可能有同序号分片写入或会话已开始合并，以当前状态重新校验
+	received := upload.Received - upload.Chunks[index] + n
+	if upload.completing || received > upload.TotalSize {
+		_ = os.Remove(tmp.Name())
+		if upload.completing {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("%w: 分片总大小超过声明的文件大小", ErrInvalidChunk)
+	}
+	if err := os.Rename(tmp.Name(), upload.partPath(index)); err != nil {
+		_ = os.Remove(tmp.Name())
+		log.Printf("Rename chunk error: %v\n", err)
+		return nil, errors.New("分片保存失败")
+	}
+	upload.Chunks[index] = n
+	upload.Received = received
+	upload.ExpiresAt = time.Now().Add(chunkUploadTTL)
+	return upload.status(), nil
+}
+
+// GetUploadStatus 查询分片上传会话的进度
+func GetUploadStatus(userID uint, uploadID string) (*ChunkUploadStatus, error) {
+	upload, err := getChunkUpload(userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	return upload.status(), nil
+}
+
+// CompleteUpload 按序号合并分片并走普通上传的校验与保存流程，返回图片记录与访问地址
+// 分片缺失时会话保留以便续传；其余情况 (成功或校验失败) 会话均被删除
+func CompleteUpload(ctx context.Context, userID uint, uploadID string) (*model.Image, string, error) {
+	upload, err := getChunkUpload(userID, uploadID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	upload.mu.Lock()
+	if upload.completing {
+		upload.mu.Unlock()
+		return nil, "", ErrUploadNotFound
+	}
+	for i := 0; i < len(upload.Chunks); i++ {
+		if _, ok := upload.Chunks[i]; !ok {
+			upload.mu.Unlock()
+			return nil, "", fmt.Errorf("%w: 缺少第 %d 个分片", ErrUploadIncomplete, i)
+		}
+	}
+	if upload.Received != upload.TotalSize {
+		upload.mu.Unlock()
+		return nil, "", fmt.Errorf("%w: 已接收 %d B，共 %d B", ErrUploadIncomplete, upload.Received, upload.TotalSize)
+	}
+	upload.completing = true
+	chunkCount := len(upload.Chunks)
+	upload.mu.Unlock()
+	defer removeChunkUpload(upload)
+
+	assembled := filepath.Join(upload.Dir, "assembled")
+	if err := upload.assemble(assembled, chunkCount); err != nil {
+		log.Printf("Assemble chunks error: %v\n", err)
+		return nil, "", errors.New("文件保存失败")
+	}
+
+	return processUploadSource(ctx, uploadSource{
+		Filename: upload.Filename,
+		Size:     upload.TotalSize,
+		Open:     func() (multipart.File, error) { return os.Open(assembled) },
+	}, userID)
+}
+
+// AbortUpload 取消分片上传会话并删除已上传的分片
+func AbortUpload(userID uint, uploadID string) error {
+	upload, err := getChunkUpload(userID, uploadID)
+	if err != nil {
+		return err
+	}
+	upload.mu.Lock()
+	completing := upload.completing
+	upload.mu.Unlock()
+	if completing {
+		return ErrUploadNotFound
+	}
+	removeChunkUpload(upload)
+	return nil
+}
+
+// CleanupExpiredUploads 删除已过期的分片上传会话，返回删除的数量
+func CleanupExpiredUploads() int {
+	now := time.Now()
+	var expired []*chunkUpload
+	chunkUploadsMu.Lock()
+	for _, upload := range chunkUploads {
+		upload.mu.Lock()
+		if !upload.completing && now.After(upload.ExpiresAt) {
+			expired = append(expired, upload)
+		}
+		upload.mu.Unlock()
+	}
+	chunkUploadsMu.Unlock()
+
+	for _, upload := range expired {
+		removeChunkUpload(upload)
+	}
+	return len(expired)
+}
+
+// StartChunkUploadJanitor 启动定期清理过期分片上传会话的后台任务
+// 会话状态只保存在内存中，启动时先清空上次运行遗留的分片目录
+func StartChunkUploadJanitor() {
+	chunkUploadOnce.Do(func() {
+		if err := os.RemoveAll(chunkUploadRoot()); err != nil {
+			log.Printf("Remove stale chunk uploads error: %v\n", err)
+		}
+		chunkUploadStopCh = make(chan struct{})
+		chunkUploadDoneCh = make(chan struct{})
+		go func() {
+			defer close(chunkUploadDoneCh)
+			ticker := time.NewTicker(chunkUploadCleanupInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if n := CleanupExpiredUploads(); n > 0 {
+						log.Printf("Removed %d expired chunk uploads\n", n)
+					}
+				case <-chunkUploadStopCh:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// StopChunkUploadJanitor 停止清理任务
+func StopChunkUploadJanitor() {
+	if chunkUploadStopCh == nil {
+		return
+	}
+	close(chunkUploadStopCh)
+	<-chunkUploadDoneCh
+	chunkUploadStopCh = nil
+}
+
+func getChunkUpload(userID uint, uploadID string) (*chunkUpload, error) {
+	chunkUploadsMu.Lock()
+	upload, ok := chunkUploads[uploadID]
+	chunkUploadsMu.Unlock()
+	if !ok || upload.UserID != userID {
+		return nil, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+func removeChunkUpload(upload *chunkUpload) {
+	chunkUploadsMu.Lock()
+	if chunkUploads[upload.ID] == upload {
+		delete(chunkUploads, upload.ID)
+	}
+	chunkUploadsMu.Unlock()
+	if err := os.RemoveAll(upload.Dir); err != nil {
+		log.Printf("Remove chunk upload error: %v\n", err)
+	}
+}
+
+func (u *chunkUpload) partPath(index int) string {
+	return filepath.Join(u.Dir, strconv.Itoa(index)+".part")
+}
+
+// status 返回会话进度，调用方需持有 u.mu
+func (u *chunkUpload) status() *ChunkUploadStatus {
+	indices := make([]int, 0, len(u.Chunks))
+	for i := range u.Chunks {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return &ChunkUploadStatus{
+		UploadID:       u.ID,
+		Filename:       u.Filename,
+		TotalSize:      u.TotalSize,
+		ReceivedBytes:  u.Received,
+		ReceivedChunks: indices,
+		ExpiresAt:      u.ExpiresAt.Unix(),
+	}
+}
+
+// assemble 将 0..count-1 号分片依次写入 dst
+func (u *chunkUpload) assemble(dst string, count int) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		if err = appendFile(out, u.partPath(i)); err != nil {
+			break
+		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func appendFile(dst io.Writer, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(dst, f)
+	return err
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+)
+
+// testPNG 返回一张测试 PNG 的内容
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradientImage(w, h)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestChunkedUploadOutOfOrder(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "chunky")
+	data := testPNG(t, 32, 32)
+	third := len(data) / 3
+	chunks := [][]byte{data[:third], data[third : 2*third], data[2*third:]}
+
+	id, err := InitUpload(user.ID, int64(len(data)), "big.png")
+	if err != nil {
+		t.Fatalf("init upload: %v", err)
+	}
+	for _, i := range []int{2, 0} {
+		if _, err := UploadChunk(user.ID, id, i, bytes.NewReader(chunks[i])); err != nil {
+			t.Fatalf("upload chunk %d: %v", i, err)
+		}
+	}
+
+	// 缺少分片时不能完成，会话保留以便续传
+	if _, _, err := CompleteUpload(context.Background(), user.ID, id); !errors.Is(err, ErrUploadIncomplete) {
+		t.Fatalf("complete with missing chunk error = %v, want ErrUploadIncomplete", err)
+	}
+	status, err := GetUploadStatus(user.ID, id)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.ReceivedBytes != int64(len(chunks[0])+len(chunks[2])) || len(status.ReceivedChunks) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	if _, err := UploadChunk(user.ID, id, 1, bytes.NewReader(chunks[1])); err != nil {
+		t.Fatalf("upload chunk 1: %v", err)
+	}
+	img, _, err := CompleteUpload(context.Background(), user.ID, id)
+	if err != nil {
+		t.Fatalf("complete upload: %v", err)
+	}
+	var stored model.Image
+	if err := db.DB.First(&stored, img.ID).Error; err != nil {
+		t.Fatalf("load image: %v", err)
+	}
+	if stored.Size != int64(len(data)) || stored.OriginalFilename != "big.png" {
+		t.Fatalf("stored image = %+v", stored)
+	}
+	// 完成后会话被删除
+	if _, err := GetUploadStatus(user.ID, id); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("status after completion error = %v, want ErrUploadNotFound", err)
+	}
+}
+
+func TestChunkedUploadRejectsOversizedChunks(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "chunky")
+
+	id, err := InitUpload(user.ID, 10, "a.png")
+	if err != nil {
+		t.Fatalf("init upload: %v", err)
+	}
+	if _, err := UploadChunk(user.ID, id, 0, bytes.NewReader(make([]byte, 6))); err != nil {
+		t.Fatalf("upload chunk 0: %v", err)
+	}
+	if _, err := UploadChunk(user.ID, id, 1, bytes.NewReader(make([]byte, 5))); !errors.Is(err, ErrInvalidChunk) {
+		t.Fatalf("oversized chunk error = %v, want ErrInvalidChunk", err)
+	}
+	// 重传同一序号时按替换计算总大小
+	if _, err := UploadChunk(user.ID, id, 0, bytes.NewReader(make([]byte, 10))); err != nil {
+		t.Fatalf("re-upload chunk 0: %v", err)
+	}
+	if _, err := UploadChunk(user.ID, id, 1, bytes.NewReader(nil)); !errors.Is(err, ErrInvalidChunk) {
+		t.Fatalf("empty chunk error = %v, want ErrInvalidChunk", err)
+	}
+}
+
+func TestChunkedUploadSessionsArePerUser(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner")
+	other := createTestUser(t, "other")
+
+	id, err := InitUpload(owner.ID, 10, "a.png")
+	if err != nil {
+		t.Fatalf("init upload: %v", err)
+	}
+	if _, err := UploadChunk(other.ID, id, 0, bytes.NewReader([]byte("x"))); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("other user chunk error = %v, want ErrUploadNotFound", err)
+	}
+	if err := AbortUpload(other.ID, id); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("other user abort error = %v, want ErrUploadNotFound", err)
+	}
+	if err := AbortUpload(owner.ID, id); err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+
+	for i := 0; i < maxActiveChunkUploads; i++ {
+		if _, err := InitUpload(owner.ID, 10, "a.png"); err != nil {
+			t.Fatalf("init upload %d: %v", i, err)
+		}
+	}
+	if _, err := InitUpload(owner.ID, 10, "a.png"); !errors.Is(err, ErrTooManyUploads) {
+		t.Fatalf("init beyond limit error = %v, want ErrTooManyUploads", err)
+	}
+	if _, err := InitUpload(owner.ID, 10, "a.exe"); err == nil {
+		t.Fatal("expected error for a disallowed extension")
+	}
+}
+
+func TestCleanupExpiredUploads(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "chunky")
+	id, err := InitUpload(user.ID, 10, "a.png")
+	if err != nil {
+		t.Fatalf("init upload: %v", err)
+	}
+	if n := CleanupExpiredUploads(); n != 0 {
+		t.Fatalf("cleaned %d active uploads", n)
+	}
+
+	upload, _ := getChunkUpload(user.ID, id)
+	upload.mu.Lock()
+	upload.ExpiresAt = upload.ExpiresAt.Add(-2 * chunkUploadTTL)
+	upload.mu.Unlock()
+	if n := CleanupExpiredUploads(); n != 1 {
+		t.Fatalf("cleaned = %d, want 1", n)
+	}
+	if _, err := GetUploadStatus(user.ID, id); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("status after cleanup error = %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDailyUploadLimit 用户当日上传次数或字节数已达上限
+var ErrDailyUploadLimit = errors.New("今日上传已达上限")
+
+// dailyUploadReservation 一次上传预占的当日额度，上传结束后需调用 settle
+type dailyUploadReservation struct {
+	userID uint
+	day    string
+	size   int64
+}
+
+// dailyUploadLimits 返回用户的每日上传次数与字节数上限 (0 表示不限制)
+// 用户单独设置的上限优先于站点默认值，管理员不受限制
+func dailyUploadLimits(user *model.User) (int, int64) {
+	if user.Admin {
+		return 0, 0
+	}
+	countLimit := GetInt(consts.ConfigDailyUploadCountLimit)
+	if user.DailyUploadCountLimit != nil {
+		countLimit = *user.DailyUploadCountLimit
+	}
+	bytesLimit := GetInt64(consts.ConfigDailyUploadBytesLimit)
+	if user.DailyUploadBytesLimit != nil {
+		bytesLimit = *user.DailyUploadBytesLimit
+	}
+	return countLimit, bytesLimit
+}
+
+// checkDailyUpload 检查再上传一个 size 字节的文件是否会超出当日上限，不预占额度
+func checkDailyUpload(user *model.User, size int64) error {
+	countLimit, bytesLimit := dailyUploadLimits(user)
+	if countLimit <= 0 && bytesLimit <= 0 {
+		return nil
+	}
+	now := time.Now()
+	usage, err := dailyUploadUsageOf(user.ID, dailyUploadDay(now))
+	if err != nil {
+		log.Printf("Query daily upload usage error: %v\n", err)
+		return errors.New("查询今日上传用量失败")
+	}
+	return dailyUploadLimitError(usage, size, countLimit, bytesLimit, now)
+}
+
+// reserveDailyUpload 为一次上传预占当日额度 (次数 +1，字节数 +size)，超出上限时返回 ErrDailyUploadLimit
+// 用量按用户、日期保存在数据库中，并以条件更新累加，多个请求或实例并发上传时不会超出上限
+func reserveDailyUpload(user *model.User, size int64) (*dailyUploadReservation, error) {
+	now := time.Now()
+	day := dailyUploadDay(now)
+	countLimit, bytesLimit := dailyUploadLimits(user)
+
+	created := db.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.DailyUploadUsage{UserID: user.ID, Day: day})
+	if created.Error != nil {
+		log.Printf("Create daily upload usage error: %v\n", created.Error)
+		return nil, errors.New("记录今日上传用量失败")
+	}
+	if created.RowsAffected > 0 {
+		// 当天第一次上传时清理该用户之前的记录
+		if err := db.DB.Where("user_id = ? AND day <> ?", user.ID, day).Delete(&model.DailyUploadUsage{}).Error; err != nil {
+			log.Printf("Delete stale daily upload usage error: %v\n", err)
+		}
+	}
+
+	query := db.DB.Model(&model.DailyUploadUsage{}).Where("user_id = ? AND day = ?", user.ID, day)
+	if countLimit > 0 {
+		query = query.Where("count + 1 <= ?", countLimit)
+	}
+	if bytesLimit > 0 {
+		query = query.Where("bytes + ? <= ?", size, bytesLimit)
+	}
+	result := query.UpdateColumns(map[string]interface{}{
+		"count": gorm.Expr("count + 1"),
+		"bytes": gorm.Expr("bytes + ?", size),
+	})
+	if result.Error != nil {
+		log.Printf("Reserve daily upload usage error: %v\n", result.Error)
+		return nil, errors.New("记录今日上传用量失败")
+	}
+	if result.RowsAffected == 0 {
+		usage, err := dailyUploadUsageOf(user.ID, day)
+		if err != nil {
+			log.Printf("Query daily upload usage error: %v\n", err)
+			return nil, errors.New("查询今日上传用量失败")
+		}
+		if err := dailyUploadLimitError(usage, size, countLimit, bytesLimit, now); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("记录今日上传用量失败")
+	}
+	return &dailyUploadReservation{userID: user.ID, day: day, size: size}, nil
+}
+
+// settle 按实际写入大小修正预占的额度，上传失败时 (ok 为 false) 归还额度
+func (r *dailyUploadReservation) settle(actual int64, ok bool) {
+	updates := map[string]interface{}{"bytes": gorm.Expr("bytes + ?", actual-r.size)}
+	if !ok {
+		updates = map[string]interface{}{
+			"count": gorm.Expr("count - 1"),
+			"bytes": gorm.Expr("bytes - ?", r.size),
+		}
+	} else if actual == r.size {
+		return
+	}
+	if err := db.DB.Model(&model.DailyUploadUsage{}).Where("user_id = ? AND day = ?", r.userID, r.day).
+		UpdateColumns(updates).Error; err != nil {
+		log.Printf("Settle daily upload usage error: %v\n", err)
+	}
+}
+
+// dailyUploadUsageOf 返回用户某天的用量，没有记录时返回零值
+func dailyUploadUsageOf(userID uint, day string) (model.DailyUploadUsage, error) {
+	var usage model.DailyUploadUsage
+	err := db.DB.Where("user_id = ? AND day = ?", userID, day).Limit(1).Find(&usage).Error
+	return usage, err
+}
+
+// dailyUploadLimitError 用量加上本次上传 (次数 +1，字节数 +size) 后超出上限时返回错误
+func dailyUploadLimitError(usage model.DailyUploadUsage, size int64, countLimit int, bytesLimit int64, now time.Time) error {
+	if countLimit > 0 && usage.Count+1 > countLimit {
+		return newLocalizedError(ErrDailyUploadLimit, MsgDailyUploadCountLimit, countLimit, dailyUploadReset(now))
+	}
+	if bytesLimit > 0 && usage.Bytes+size > bytesLimit {
+		return newLocalizedError(ErrDailyUploadLimit, MsgDailyUploadBytesLimit,
+			FormatUploadSizeLimit(bytesLimit), usage.Bytes, dailyUploadReset(now))
+	}
+	return nil
+}
+
+// dailyUploadDay 返回 now 所在的日期 (服务器本地时间)，作为用量记录的键
+func dailyUploadDay(now time.Time) string {
+	return now.Format("2006-01-02")
+}
+
+// dailyUploadReset 返回当日用量清零的时间 (次日零点)
+func dailyUploadReset(now time.Time) string {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+func TestReserveDailyUploadEnforcesCountLimit(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigDailyUploadCountLimit: "2"})
+	user := createTestUser(t, "daily_count")
+
+	for i := 0; i < 2; i++ {
+		if _, err := reserveDailyUpload(&user, 10); err != nil {
+			t.Fatalf("reserve %d: %v", i, err)
+		}
+	}
+	if _, err := reserveDailyUpload(&user, 10); !errors.Is(err, ErrDailyUploadLimit) {
+		t.Fatalf("third reserve error = %v, want ErrDailyUploadLimit", err)
+	}
+	if err := checkDailyUpload(&user, 10); !errors.Is(err, ErrDailyUploadLimit) {
+		t.Fatalf("check error = %v, want ErrDailyUploadLimit", err)
+	}
+}
+
+func TestReserveDailyUploadEnforcesBytesLimit(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigDailyUploadBytesLimit: "100"})
+	user := createTestUser(t, "daily_bytes")
+
+	r, err := reserveDailyUpload(&user, 60)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	r.settle(50, true)
+	if _, err := reserveDailyUpload(&user, 51); !errors.Is(err, ErrDailyUploadLimit) {
+		t.Fatalf("reserve over limit error = %v, want ErrDailyUploadLimit", err)
+	}
+	if _, err := reserveDailyUpload(&user, 50); err != nil {
+		t.Fatalf("reserve within limit: %v", err)
+	}
+}
+
+func TestDailyUploadSettleRefundsFailedUpload(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigDailyUploadCountLimit: "1"})
+	user := createTestUser(t, "daily_refund")
+
+	r, err := reserveDailyUpload(&user, 10)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	r.settle(0, false)
+
+	usage, err := dailyUploadUsageOf(user.ID, dailyUploadDay(time.Now()))
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	if usage.Count != 0 || usage.Bytes != 0 {
+		t.Fatalf("usage after refund = %d/%d, want 0/0", usage.Count, usage.Bytes)
+	}
+	if _, err := reserveDailyUpload(&user, 10); err != nil {
+		t.Fatalf("reserve after refund: %v", err)
+	}
+}
+
+func TestDailyUploadUsageIsStoredPerDay(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigDailyUploadCountLimit: "1"})
+	user := createTestUser(t, "daily_day")
+
+	// 昨天的用量不计入今天，当天首次上传时被清理
+	yesterday := dailyUploadDay(time.Now().AddDate(0, 0, -1))
+	if err := db.DB.Create(&model.DailyUploadUsage{UserID: user.ID, Day: yesterday, Count: 5, Bytes: 500}).Error; err != nil {
+		t.Fatalf("create usage: %v", err)
+	}
+	if _, err := reserveDailyUpload(&user, 10); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	var rows []model.DailyUploadUsage
+	if err := db.DB.Where("user_id = ?", user.ID).Find(&rows).Error; err != nil {
+		t.Fatalf("list usage: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Day != dailyUploadDay(time.Now()) || rows[0].Count != 1 || rows[0].Bytes != 10 {
+		t.Fatalf("usage rows = %+v, want only today's row with 1/10", rows)
+	}
+}
+
+func TestDailyUploadLimitSkipsAdmins(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigDailyUploadCountLimit: "1"})
+	admin := createTestUser(t, "daily_admin", func(u *model.User) { u.Admin = true })
+
+	for i := 0; i < 3; i++ {
+		if _, err := reserveDailyUpload(&admin, 10); err != nil {
+			t.Fatalf("admin reserve %d: %v", i, err)
+		}
+	}
+}
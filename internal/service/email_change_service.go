@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"time"
+)
+
+// EmailChangeCancelTokenDuration 发送到旧邮箱的撤销链接有效期
+// 长于确认链接，新邮箱确认后旧邮箱仍可在此期间将修改撤回
+const EmailChangeCancelTokenDuration = 7 * 24 * time.Hour
+
+var (
+	// ErrEmailChangeCancelInvalid 撤销链接无效、已过期或对应的修改已不存在
+	ErrEmailChangeCancelInvalid = errors.New("撤销链接已失效")
+	// ErrEmailChangeRevertConflict 旧邮箱已被其他用户占用，无法恢复
+	ErrEmailChangeRevertConflict = errors.New("原邮箱已被其他用户占用，无法恢复")
+)
+
+// CancelEmailChange 通过旧邮箱收到的撤销链接取消修改邮箱：
+// 修改尚未确认时清除待确认的新邮箱；已确认时将邮箱恢复为旧邮箱。
+// 两种情况下都会使该用户已签发的全部 Token 失效 (撤销意味着会话可能已被盗用)，返回被操作的用户 ID
+func CancelEmailChange(token string) (uint, error) {
+	claims, err := utils.ParseEmailChangeCancelToken(token)
+	if err != nil {
+		return 0, ErrEmailChangeCancelInvalid
+	}
+
+	var user model.User
+	if err := db.DB.First(&user, claims.ID).Error; err != nil {
+		return 0, ErrEmailChangeCancelInvalid
+	}
+
+	oldEmail := claims.OldEmail
+	newEmail := utils.NormalizeEmail(claims.NewEmail)
+	updates := map[string]interface{}{"pending_email": ""}
+	switch {
+	case user.Email == oldEmail && utils.NormalizeEmail(user.PendingEmail) == newEmail:
+		// 修改尚未确认
+	case utils.NormalizeEmail(user.Email) == newEmail:
+		// 修改已确认，恢复旧邮箱
+		var count int64
+		db.DB.Model(&model.User{}).Where("LOWER(email) = ? AND id != ?", utils.NormalizeEmail(oldEmail), user.ID).Count(&count)
+		if count > 0 {
+			return 0, ErrEmailChangeRevertConflict
+		}
+		updates["email"] = oldEmail
+		updates["email_verified"] = true
+	default:
+		return 0, ErrEmailChangeCancelInvalid
+	}
+
+	if err := db.DB.Model(&user).Updates(updates).Error; err != nil {
+		log.Printf("Cancel email change error: %v\n", err)
+		return 0, errors.New("撤销修改邮箱失败")
+	}
+	if err := BumpTokenVersion(user.ID); err != nil {
+		log.Printf("Bump token version error: %v\n", err)
+	}
+	if err := RevokeAllSessions(user.ID); err != nil {
+		log.Printf("Revoke sessions error: %v\n", err)
+	}
+	return user.ID, nil
+}
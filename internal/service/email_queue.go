@@ -0,0 +1,142 @@
+package service
+
+import (
+	"log"
+	"perfect-pic-server/internal/consts"
+	"sync"
+	"time"
+)
+
+// emailQueueSize 邮件队列缓冲长度
+const emailQueueSize = 256
+
+// emailJob 待发送的邮件
+type emailJob struct {
+	To       string
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+var (
+	emailQueue     chan emailJob
+	emailQueueMu   sync.RWMutex
+	emailWorkersWg sync.WaitGroup
+	emailStopCh    chan struct{}
+)
+
+// StartEmailQueue 启动邮件发送队列与工作协程，工作协程数量由 ConfigEmailWorkerCount 决定
+func StartEmailQueue() {
+	emailQueueMu.Lock()
+	defer emailQueueMu.Unlock()
+	if emailQueue != nil {
+		return
+	}
+
+	workers := GetInt(consts.ConfigEmailWorkerCount)
+	if workers <= 0 {
+		workers = 2
+	}
+
+	emailQueue = make(chan emailJob, emailQueueSize)
+	emailStopCh = make(chan struct{})
+	for i := 0; i < workers; i++ {
+		emailWorkersWg.Add(1)
+		go emailWorker(emailQueue, emailStopCh)
+	}
+}
+
+// StopEmailQueue 停止接收新邮件，并在超时前尽量发送完队列中剩余的邮件
+func StopEmailQueue(timeout time.Duration) {
+	emailQueueMu.Lock()
+	queue, stopCh := emailQueue, emailStopCh
+	emailQueue = nil
+	emailQueueMu.Unlock()
+	if queue == nil {
+		return
+	}
+	close(queue)
+
+	done := make(chan struct{})
+	go func() {
+		emailWorkersWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("✅ 邮件队列已清空")
+	case <-time.After(timeout):
+		// 通知工作协程放弃重试等待
+		close(stopCh)
+		log.Printf("⚠️ 邮件队列未能在 %v 内清空，剩余邮件将被丢弃", timeout)
+	}
+}
+
+// enqueueEmail 将邮件放入发送队列
+// 队列未启动或已满时退化为同步发送，保证邮件不会被静默丢弃；
+// 同步发送前释放读锁，避免缓慢的 SMTP 连接阻塞 StopEmailQueue
+func enqueueEmail(job emailJob) error {
+	if tryEnqueueEmail(job) {
+		return nil
+	}
+	return GetMailer().Send(job.To, job.Subject, job.HTMLBody, job.TextBody)
+}
+
+// tryEnqueueEmail 尝试将邮件放入队列，队列未启动或已满时返回 false
+func tryEnqueueEmail(job emailJob) bool {
+	emailQueueMu.RLock()
+	defer emailQueueMu.RUnlock()
+
+	if emailQueue == nil {
+		return false
+	}
+	select {
+	case emailQueue <- job:
+		return true
+	default:
+		log.Println("[Email] 邮件队列已满，改为同步发送")
+		return false
+	}
+}
+
+func emailWorker(queue <-chan emailJob, stopCh <-chan struct{}) {
+	defer emailWorkersWg.Done()
+	for job := range queue {
+		deliverWithRetry(job, stopCh)
+	}
+}
+
+// deliverWithRetry 发送邮件，失败时按指数退避重试，达到 ConfigEmailMaxAttempts 后记入死信日志
+func deliverWithRetry(job emailJob, stopCh <-chan struct{}) {
+	maxAttempts := GetInt(consts.ConfigEmailMaxAttempts)
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	backoff := time.Second
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = GetMailer().Send(job.To, job.Subject, job.HTMLBody, job.TextBody); err == nil {
+			return
+		}
+		if attempt == maxAttempts {
+			break
+		}
+
+		log.Printf("[Email] 第 %d 次发送失败，%v 后重试: %v", attempt, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-stopCh:
+			log.Printf("[Email] [DeadLetter] 服务关闭，放弃重试，主题: %s，错误: %v", job.Subject, err)
+			return
+		}
+		backoff *= 2
+		if backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+
+	// 死信：不记录收件地址，防止日志泄露敏感信息
+	log.Printf("[Email] [DeadLetter] 邮件发送失败已放弃，主题: %s，错误: %v", job.Subject, err)
+}
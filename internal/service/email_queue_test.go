@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// blockingMailer 发送时通知 entered 并阻塞到 release 关闭
+type blockingMailer struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(to, subject, htmlBody, textBody string) error {
+	m.entered <- struct{}{}
+	<-m.release
+	return nil
+}
+
+func TestEnqueueEmailSyncFallbackDoesNotHoldLock(t *testing.T) {
+	setupTestDB(t)
+	m := &blockingMailer{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	SetMailer(m)
+	t.Cleanup(func() { SetMailer(nil) })
+
+	// 队列未启动，邮件同步发送并阻塞在 SMTP 上
+	sent := make(chan error, 1)
+	go func() { sent <- enqueueEmail(emailJob{To: "a@example.com", Subject: "hi"}) }()
+	<-m.entered
+
+	started := make(chan struct{})
+	go func() {
+		StartEmailQueue()
+		StopEmailQueue(time.Second)
+		close(started)
+	}()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		close(m.release)
+		t.Fatal("starting the email queue blocked on a synchronous send")
+	}
+
+	close(m.release)
+	if err := <-sent; err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
@@ -1,15 +1,9 @@
 package service
 
 import (
-	"bytes"
-	"crypto/tls"
 	"fmt"
-	"html/template"
-	"log"
 	"mime"
 	"net/mail"
-	"net/smtp"
-	"os"
 	"perfect-pic-server/internal/config"
 	"perfect-pic-server/internal/consts"
 	"regexp"
@@ -36,84 +30,84 @@ type EmailChangeData struct {
 	VerifyUrl string
 }
 
+type EmailChangeNoticeData struct {
+	SiteName  string
+	Username  string
+	OldEmail  string
+	NewEmail  string
+	CancelUrl string
+}
+
+type NewDeviceLoginData struct {
+	SiteName  string
+	Username  string
+	Time      string
+	IP        string
+	UserAgent string
+}
+
+type AccountDeletionData struct {
+	SiteName  string
+	Username  string
+	DeleteAt  string
+	CancelUrl string
+}
+
+type AccountUnlockData struct {
+	SiteName   string
+	Username   string
+	UnlockUrl  string
+	ValidHours int // 解锁链接的有效期 (小时)
+}
+
 type PasswordResetData struct {
-	SiteName string
-	Username string
-	ResetUrl string
+	SiteName     string
+	Username     string
+	ResetUrl     string
+	ValidMinutes int // 重置链接的有效期 (分钟)
 }
 
 var strictEmailRegex = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z0-9]+`)
 
-// SendVerificationEmail 发送验证邮件
-func SendVerificationEmail(toEmail, username, verifyUrl string) error {
+// sendTransactionalEmail 发送业务邮件 (未开启 SMTP 时直接跳过)
+func sendTransactionalEmail(toEmail, subject, htmlBody, textBody string) error {
 	// 检查是否开启 SMTP
 	if !GetBool(consts.ConfigEnableSMTP) {
 		return nil
 	}
+	// 放入异步队列发送，失败时由队列负责重试
+	return enqueueEmail(emailJob{To: toEmail, Subject: subject, HTMLBody: htmlBody, TextBody: textBody})
+}
 
-	cfg := config.Get()
-	if cfg.SMTP.Host == "" {
-		return nil
-	}
-
-	auth := smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
-
+func getSiteName() string {
 	siteName := GetString(consts.ConfigSiteName)
 	if siteName == "" {
 		siteName = "Perfect Pic"
 	}
+	return siteName
+}
 
-	// 邮件主题
-	subject := fmt.Sprintf("欢迎注册 %s - 请验证您的邮箱", siteName)
-
-	// 读取模板文件
-	templatePath := "config/verification-mail.html"
-	contentBytes, err := os.ReadFile(templatePath)
-	var bodyTpl string
-	if err != nil {
-		// 如果模板读取失败，使用默认简单模板
-		bodyTpl = `
-			<h1>欢迎加入 {{.SiteName}}</h1>
-			<p>请点击链接验证邮箱: <a href="{{.VerifyUrl}}">{{.VerifyUrl}}</a></p>
-		`
-	} else {
-		bodyTpl = string(contentBytes)
-	}
-
+// SendVerificationEmail 发送验证邮件
+func SendVerificationEmail(toEmail, username, verifyUrl, locale string) error {
 	data := VerificationEmailData{
-		SiteName:  siteName,
+		SiteName:  getSiteName(),
 		Username:  username,
 		VerifyUrl: verifyUrl,
 	}
+	return sendTemplatedEmail(toEmail, EmailTemplateVerifyEmail, locale, data)
+}
 
-	body, err := renderTemplate(bodyTpl, data)
-	if err != nil {
-		return err
-	}
-
-	fromHeader, fromAddr, err := formatAddressHeader(cfg.SMTP.From)
-	if err != nil {
-		return err
-	}
-	toHeader, toAddr, err := formatAddressHeader(toEmail)
-	if err != nil {
-		return err
+// sendTemplatedEmail 按语言渲染模板后发送业务邮件
+func sendTemplatedEmail(toEmail, name, locale string, data interface{}) error {
+	// 未开启 SMTP 时无需渲染
+	if !GetBool(consts.ConfigEnableSMTP) {
+		return nil
 	}
-
-	msg, err := buildEmailMessage(fromHeader, toHeader, subject, body)
+	subject, htmlBody, textBody, err := RenderEmail(name, locale, data)
 	if err != nil {
 		return err
 	}
-
-	addr := fmt.Sprintf("%s:%d", cfg.SMTP.Host, cfg.SMTP.Port)
-
-	// 如果配置了 SSL (通常是端口 465)，需要使用 tls 连接
-	if cfg.SMTP.SSL {
-		return sendMailWithSSL(addr, auth, fromAddr, []string{toAddr}, msg)
-	}
-
-	// 默认使用 STARTTLS (通常是端口 587 或 25)
-	return smtp.SendMail(addr, auth, fromAddr, []string{toAddr}, msg)
+	return sendTransactionalEmail(toEmail, subject, htmlBody, textBody)
 }
 
 // SendTestEmail 发送测试邮件
@@ -123,12 +117,7 @@ func SendTestEmail(toEmail string) error {
 		return fmt.Errorf("SMTP Host 未配置")
 	}
 
-	auth := smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
-
-	siteName := GetString(consts.ConfigSiteName)
-	if siteName == "" {
-		siteName = "Perfect Pic"
-	}
+	siteName := getSiteName()
 
 	subject := fmt.Sprintf("%s SMTP 测试邮件", siteName)
 	bodyTpl := `
@@ -152,236 +141,76 @@ func SendTestEmail(toEmail string) error {
 		return err
 	}
 
-	fromHeader, fromAddr, err := formatAddressHeader(cfg.SMTP.From)
-	if err != nil {
-		return err
-	}
-	toHeader, toAddr, err := formatAddressHeader(toEmail)
-	if err != nil {
-		return err
-	}
-
-	msg, err := buildEmailMessage(fromHeader, toHeader, subject, body)
-	if err != nil {
-		return err
-	}
-
-	addr := fmt.Sprintf("%s:%d", cfg.SMTP.Host, cfg.SMTP.Port)
-
-	if cfg.SMTP.SSL {
-		return sendMailWithSSL(addr, auth, fromAddr, []string{toAddr}, msg)
-	}
-
-	return smtp.SendMail(addr, auth, fromAddr, []string{toAddr}, msg)
+	return GetMailer().Send(toEmail, subject, body, "")
 }
 
 // SendEmailChangeVerification 发送修改邮箱验证邮件
-func SendEmailChangeVerification(toEmail, username, oldEmail, newEmail, verifyUrl string) error {
-	// 检查是否开启 SMTP
-	if !GetBool(consts.ConfigEnableSMTP) {
-		return nil
-	}
-
-	cfg := config.Get()
-	if cfg.SMTP.Host == "" {
-		return nil
-	}
-
-	auth := smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
-
-	siteName := GetString(consts.ConfigSiteName)
-	if siteName == "" {
-		siteName = "Perfect Pic"
-	}
-
-	// 邮件主题
-	subject := fmt.Sprintf("%s - 请确认修改邮箱", siteName)
-
-	// 读取模板文件
-	templatePath := "config/email-change-mail.html"
-	contentBytes, err := os.ReadFile(templatePath)
-	var bodyTpl string
-	if err != nil {
-		bodyTpl = `
-			<h1>修改邮箱确认 - {{.SiteName}}</h1>
-			<p>您请求将邮箱从 {{.OldEmail}} 修改为 {{.NewEmail}}。</p>
-			<p>请点击链接确认: <a href="{{.VerifyUrl}}">{{.VerifyUrl}}</a></p>
-		`
-	} else {
-		bodyTpl = string(contentBytes)
-	}
-
+func SendEmailChangeVerification(toEmail, username, oldEmail, newEmail, verifyUrl, locale string) error {
 	data := EmailChangeData{
-		SiteName:  siteName,
+		SiteName:  getSiteName(),
 		Username:  username,
 		OldEmail:  oldEmail,
 		NewEmail:  newEmail,
 		VerifyUrl: verifyUrl,
 	}
+	return sendTemplatedEmail(toEmail, EmailTemplateEmailChange, locale, data)
+}
 
-	body, err := renderTemplate(bodyTpl, data)
-	if err != nil {
-		return err
+// SendEmailChangeNotice 向旧邮箱发送修改邮箱通知，附带撤销链接
+func SendEmailChangeNotice(toEmail, username, oldEmail, newEmail, cancelUrl, locale string) error {
+	data := EmailChangeNoticeData{
+		SiteName:  getSiteName(),
+		Username:  username,
+		OldEmail:  oldEmail,
+		NewEmail:  newEmail,
+		CancelUrl: cancelUrl,
 	}
+	return sendTemplatedEmail(toEmail, EmailTemplateEmailChangeNotice, locale, data)
+}
 
-	fromHeader, fromAddr, err := formatAddressHeader(cfg.SMTP.From)
-	if err != nil {
-		return err
-	}
-	toHeader, toAddr, err := formatAddressHeader(toEmail)
-	if err != nil {
-		return err
+// SendNewDeviceLoginEmail 发送新设备登录提醒
+func SendNewDeviceLoginEmail(toEmail, username, ip, userAgent string, loginAt time.Time, locale string) error {
+	data := NewDeviceLoginData{
+		SiteName:  getSiteName(),
+		Username:  username,
+		Time:      loginAt.Format("2006-01-02 15:04:05"),
+		IP:        ip,
+		UserAgent: userAgent,
 	}
+	return sendTemplatedEmail(toEmail, EmailTemplateNewDeviceLogin, locale, data)
+}
 
-	msg, err := buildEmailMessage(fromHeader, toHeader, subject, body)
-	if err != nil {
-		return err
+// SendAccountDeletionEmail 发送注销账号申请确认，附带撤销链接
+func SendAccountDeletionEmail(toEmail, username string, deleteAt time.Time, cancelUrl, locale string) error {
+	data := AccountDeletionData{
+		SiteName:  getSiteName(),
+		Username:  username,
+		DeleteAt:  deleteAt.Format("2006-01-02 15:04"),
+		CancelUrl: cancelUrl,
 	}
+	return sendTemplatedEmail(toEmail, EmailTemplateAccountDeletion, locale, data)
+}
 
-	addr := fmt.Sprintf("%s:%d", cfg.SMTP.Host, cfg.SMTP.Port)
-
-	if cfg.SMTP.SSL {
-		return sendMailWithSSL(addr, auth, fromAddr, []string{toAddr}, msg)
+// SendAccountUnlockEmail 发送账号被锁定的通知，附带自助解锁链接
+func SendAccountUnlockEmail(toEmail, username, unlockUrl, locale string) error {
+	data := AccountUnlockData{
+		SiteName:   getSiteName(),
+		Username:   username,
+		UnlockUrl:  unlockUrl,
+		ValidHours: int(AccountUnlockTokenDuration / time.Hour),
 	}
-
-	return smtp.SendMail(addr, auth, fromAddr, []string{toAddr}, msg)
+	return sendTemplatedEmail(toEmail, EmailTemplateAccountUnlock, locale, data)
 }
 
 // SendPasswordResetEmail 发送重置密码邮件
-func SendPasswordResetEmail(toEmail, username, resetUrl string) error {
-	// 检查是否开启 SMTP
-	if !GetBool(consts.ConfigEnableSMTP) {
-		return nil
-	}
-
-	cfg := config.Get()
-	if cfg.SMTP.Host == "" {
-		return nil
-	}
-
-	auth := smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
-
-	siteName := GetString(consts.ConfigSiteName)
-	if siteName == "" {
-		siteName = "Perfect Pic"
-	}
-
-	// 邮件主题
-	subject := fmt.Sprintf("%s - 重置密码请求", siteName)
-
-	// 读取模板文件
-	templatePath := "config/reset-password-mail.html"
-	contentBytes, err := os.ReadFile(templatePath)
-	var bodyTpl string
-	if err != nil {
-		bodyTpl = `
-			<h1>重置密码 - {{.SiteName}}</h1>
-			<p>请点击链接重置密码: <a href="{{.ResetUrl}}">{{.ResetUrl}}</a></p>
-			<p>有效期15分钟。</p>
-		`
-	} else {
-		bodyTpl = string(contentBytes)
-	}
-
+func SendPasswordResetEmail(toEmail, username, resetUrl, locale string) error {
 	data := PasswordResetData{
-		SiteName: siteName,
-		Username: username,
-		ResetUrl: resetUrl,
-	}
-
-	body, err := renderTemplate(bodyTpl, data)
-	if err != nil {
-		return err
-	}
-
-	fromHeader, fromAddr, err := formatAddressHeader(cfg.SMTP.From)
-	if err != nil {
-		return err
+		SiteName:     getSiteName(),
+		Username:     username,
+		ResetUrl:     resetUrl,
+		ValidMinutes: int(PasswordResetTTL() / time.Minute),
 	}
-	toHeader, toAddr, err := formatAddressHeader(toEmail)
-	if err != nil {
-		return err
-	}
-
-	msg, err := buildEmailMessage(fromHeader, toHeader, subject, body)
-	if err != nil {
-		return err
-	}
-
-	addr := fmt.Sprintf("%s:%d", cfg.SMTP.Host, cfg.SMTP.Port)
-
-	if cfg.SMTP.SSL {
-		return sendMailWithSSL(addr, auth, fromAddr, []string{toAddr}, msg)
-	}
-
-	return smtp.SendMail(addr, auth, fromAddr, []string{toAddr}, msg)
-}
-
-func sendMailWithSSL(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
-	cfg := config.Get()
-	// log.Printf("[Email] 正在使用 SSL 连接至 %s 发送邮件", addr)
-
-	// 建立 TLS 连接
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: false,
-		ServerName:         cfg.SMTP.Host,
-	}
-
-	// 增加超时控制
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
-	if err != nil {
-		log.Printf("[Email] TLS 连接失败: %v", err)
-		return err
-	}
-	defer conn.Close()
-
-	client, err := smtp.NewClient(conn, cfg.SMTP.Host)
-	if err != nil {
-		log.Printf("[Email] 创建 SMTP 客户端失败: %v", err)
-		return err
-	}
-	defer client.Close()
-
-	// 认证
-	if auth != nil {
-		if ok, _ := client.Extension("AUTH"); ok {
-			if err = client.Auth(auth); err != nil {
-				log.Printf("[Email] SMTP认证失败: %v", err)
-				return err
-			}
-		}
-	}
-
-	// 发送流程
-	if err = client.Mail(from); err != nil {
-		log.Printf("[Email] MAIL FROM 命令失败: %v", err)
-		return err
-	}
-	for _, addr := range to {
-		if err = client.Rcpt(addr); err != nil {
-			// 不记录具体邮箱地址，防止日志泄露敏感信息
-			log.Printf("[Email] RCPT TO 命令失败: %v", err)
-			return err
-		}
-	}
-	w, err := client.Data()
-	if err != nil {
-		log.Printf("[Email] DATA 命令失败: %v", err)
-		return err
-	}
-	_, err = w.Write(msg)
-	if err != nil {
-		log.Printf("[Email] 写入邮件内容失败: %v", err)
-		return err
-	}
-	err = w.Close()
-	if err != nil {
-		log.Printf("[Email] 关闭 DATA 失败: %v", err)
-		return err
-	}
-
-	// log.Printf("[Email] 邮件投递成功")
-	return client.Quit()
+	return sendTemplatedEmail(toEmail, EmailTemplateResetPassword, locale, data)
 }
 
 //func parseAddressForHeader(input string) (string, string, error) {
@@ -398,17 +227,6 @@ func sendMailWithSSL(addr string, auth smtp.Auth, from string, to []string, msg
 //	return cleanHeaderValue, addr.Address, nil
 //}
 
-func buildEmailMessage(fromHeader, toHeader, subject, body string) ([]byte, error) {
-	// 对 Subject 进行 MIME 编码，防止中文乱码或被拒收
-	encodedSubject := mime.BEncoding.Encode("UTF-8", subject)
-	// 添加 Date 头
-	dateStr := time.Now().Format(time.RFC1123Z)
-
-	header := fmt.Sprintf("Date: %s\r\nFrom: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n",
-		dateStr, fromHeader, toHeader, encodedSubject)
-	return []byte(header + body), nil
-}
-
 //func rejectCRLF(value string, field string) error {
 //	if strings.ContainsAny(value, "\r\n") {
 //		return fmt.Errorf("invalid %s header: CRLF not allowed", field)
@@ -425,18 +243,6 @@ func buildEmailMessage(fromHeader, toHeader, subject, body string) ([]byte, erro
 //	}, input)
 //}
 
-func renderTemplate(tpl string, data interface{}) (string, error) {
-	t, err := template.New("email").Parse(tpl)
-	if err != nil {
-		return "", err
-	}
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return "", err
-	}
-	return buf.String(), nil
-}
-
 func formatAddressHeader(input string) (string, string, error) {
 	// 解析地址 (如果格式不对，这里直接报错，起到 Validation 作用)
 	addr, err := mail.ParseAddress(input)
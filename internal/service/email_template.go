@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/consts"
+	"strings"
+	texttemplate "text/template"
+)
+
+// 邮件模板名称
+const (
+	EmailTemplateVerifyEmail   = "verify_email"
+	EmailTemplateResetPassword = "reset_password"
+	EmailTemplateEmailChange   = "email_change"
+	// EmailTemplateEmailChangeNotice 发送到旧邮箱的修改通知，附带撤销链接
+	EmailTemplateEmailChangeNotice = "email_change_notice"
+	// EmailTemplateNewDeviceLogin 从新设备登录的提醒
+	EmailTemplateNewDeviceLogin = "new_device_login"
+	// EmailTemplateAccountDeletion 申请注销账号的确认，附带撤销链接
+	EmailTemplateAccountDeletion = "account_deletion"
+	// EmailTemplateAccountUnlock 账号因登录失败被锁定的通知，附带解锁链接
+	EmailTemplateAccountUnlock = "account_unlock"
+)
+
+// 支持的语言
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEn   = "en"
+)
+
+// SupportedLocales 支持的语言列表
+var SupportedLocales = []string{LocaleZhCN, LocaleEn}
+
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+// emailSubjects 各语言的邮件主题 (%s 为站点名称)
+var emailSubjects = map[string]map[string]string{
+	LocaleZhCN: {
+		EmailTemplateVerifyEmail:       "欢迎注册 %s - 请验证您的邮箱",
+		EmailTemplateResetPassword:     "%s - 重置密码请求",
+		EmailTemplateEmailChange:       "%s - 请确认修改邮箱",
+		EmailTemplateEmailChangeNotice: "%s - 您的账户邮箱正在被修改",
+		EmailTemplateNewDeviceLogin:    "%s - 您的账户在新设备上登录",
+		EmailTemplateAccountDeletion:   "%s - 您的账号已申请注销",
+		EmailTemplateAccountUnlock:     "%s - 您的账号已被锁定",
+	},
+	LocaleEn: {
+		EmailTemplateVerifyEmail:       "Welcome to %s - Please verify your email",
+		EmailTemplateResetPassword:     "%s - Password reset request",
+		EmailTemplateEmailChange:       "%s - Please confirm your email change",
+		EmailTemplateEmailChangeNotice: "%s - Your account email is being changed",
+		EmailTemplateNewDeviceLogin:    "%s - New sign-in to your account",
+		EmailTemplateAccountDeletion:   "%s - Your account is scheduled for deletion",
+		EmailTemplateAccountUnlock:     "%s - Your account has been locked",
+	},
+}
+
+// legacyTemplateFiles 旧版自定义模板路径，作为中文模板的覆盖文件继续生效
+var legacyTemplateFiles = map[string]string{
+	EmailTemplateVerifyEmail:   "config/verification-mail.html",
+	EmailTemplateResetPassword: "config/reset-password-mail.html",
+	EmailTemplateEmailChange:   "config/email-change-mail.html",
+}
+
+// NormalizeLocale 将语言标识规范化为支持的语言，无法识别时返回空字符串
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(locale, "_", "-")))
+	switch {
+	case locale == "":
+		return ""
+	case locale == "zh" || strings.HasPrefix(locale, "zh-"):
+		return LocaleZhCN
+	case locale == "en" || strings.HasPrefix(locale, "en-"):
+		return LocaleEn
+	}
+	return ""
+}
+
+// ResolveLocale 按 用户偏好 -> 站点默认语言 -> 中文 的顺序确定邮件语言
+func ResolveLocale(userLocale string) string {
+	if locale := NormalizeLocale(userLocale); locale != "" {
+		return locale
+	}
+	if locale := NormalizeLocale(GetString(consts.ConfigDefaultLocale)); locale != "" {
+		return locale
+	}
+	return LocaleZhCN
+}
+
+// RenderEmail 渲染指定语言的邮件，返回主题、HTML 正文与纯文本正文
+// HTML 模板优先读取 config/mail/<locale>/<name>.html，其次为旧版中文模板文件，最后使用内置模板
+func RenderEmail(name, locale string, data interface{}) (string, string, string, error) {
+	locale = ResolveLocale(locale)
+
+	subjectFormat, ok := emailSubjects[locale][name]
+	if !ok {
+		return "", "", "", fmt.Errorf("unknown email template: %s", name)
+	}
+	subject := fmt.Sprintf(subjectFormat, getSiteName())
+
+	htmlTpl, err := loadEmailTemplateSource(name, locale, "html")
+	if err != nil {
+		return "", "", "", err
+	}
+	htmlBody, err := renderTemplate(htmlTpl, data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	textTpl, err := loadEmailTemplateSource(name, locale, "txt")
+	if err != nil {
+		return "", "", "", err
+	}
+	textBody, err := renderTextTemplate(textTpl, data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return subject, htmlBody, textBody, nil
+}
+
+func loadEmailTemplateSource(name, locale, ext string) (string, error) {
+	custom := filepath.Join("config", "mail", locale, name+"."+ext)
+	if content, err := os.ReadFile(custom); err == nil {
+		return string(content), nil
+	}
+	if ext == "html" && locale == LocaleZhCN {
+		if content, err := os.ReadFile(legacyTemplateFiles[name]); err == nil {
+			return string(content), nil
+		}
+	}
+
+	content, err := emailTemplateFS.ReadFile("templates/email/" + locale + "/" + name + "." + ext)
+	if err != nil {
+		return "", fmt.Errorf("email template not found: %s/%s.%s", locale, name, ext)
+	}
+	return string(content), nil
+}
+
+func renderTemplate(tpl string, data interface{}) (string, error) {
+	t, err := htmltemplate.New("email").Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func renderTextTemplate(tpl string, data interface{}) (string, error) {
+	t, err := texttemplate.New("email").Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
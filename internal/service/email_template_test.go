@@ -0,0 +1,89 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"strings"
+	"testing"
+)
+
+func TestRenderEmailLocales(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigSiteName: "Pic Site"})
+
+	verify := VerificationEmailData{SiteName: "Pic Site", Username: "alice", VerifyUrl: "https://pic.example/verify?token=abc"}
+	reset := PasswordResetData{SiteName: "Pic Site", Username: "alice", ResetUrl: "https://pic.example/reset?token=abc", ValidMinutes: 30}
+	change := EmailChangeData{SiteName: "Pic Site", Username: "alice", OldEmail: "old@example.com", NewEmail: "new@example.com", VerifyUrl: "https://pic.example/email?token=abc"}
+
+	tests := []struct {
+		name        string
+		template    string
+		locale      string
+		data        interface{}
+		wantSubject string
+		wantBody    []string
+	}{
+		{name: "verify zh", template: EmailTemplateVerifyEmail, locale: LocaleZhCN, data: verify,
+			wantSubject: "欢迎注册 Pic Site - 请验证您的邮箱", wantBody: []string{"亲爱的 alice", verify.VerifyUrl}},
+		{name: "verify en", template: EmailTemplateVerifyEmail, locale: LocaleEn, data: verify,
+			wantSubject: "Welcome to Pic Site - Please verify your email", wantBody: []string{"Hi alice", verify.VerifyUrl}},
+		{name: "reset zh", template: EmailTemplateResetPassword, locale: LocaleZhCN, data: reset,
+			wantSubject: "Pic Site - 重置密码请求", wantBody: []string{"亲爱的 alice", reset.ResetUrl, "30分钟内有效"}},
+		{name: "reset en", template: EmailTemplateResetPassword, locale: LocaleEn, data: reset,
+			wantSubject: "Pic Site - Password reset request", wantBody: []string{"Hi alice", reset.ResetUrl, "30 minutes"}},
+		{name: "email change zh", template: EmailTemplateEmailChange, locale: LocaleZhCN, data: change,
+			wantSubject: "Pic Site - 请确认修改邮箱", wantBody: []string{"亲爱的 alice", "new@example.com", change.VerifyUrl}},
+		{name: "email change en", template: EmailTemplateEmailChange, locale: LocaleEn, data: change,
+			wantSubject: "Pic Site - Please confirm your email change", wantBody: []string{"Hi alice", "new@example.com", change.VerifyUrl}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, htmlBody, textBody, err := RenderEmail(tt.template, tt.locale, tt.data)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if subject != tt.wantSubject {
+				t.Fatalf("subject = %q, want %q", subject, tt.wantSubject)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(textBody, want) {
+					t.Fatalf("text body missing %q:\n%s", want, textBody)
+				}
+			}
+			if !strings.Contains(htmlBody, "alice") {
+				t.Fatalf("html body missing username:\n%s", htmlBody)
+			}
+		})
+	}
+}
+
+func TestRenderEmailEscapesHTML(t *testing.T) {
+	setupTestDB(t)
+
+	data := VerificationEmailData{SiteName: "Pic Site", Username: "<script>x</script>", VerifyUrl: "https://pic.example/verify"}
+	_, htmlBody, _, err := RenderEmail(EmailTemplateVerifyEmail, LocaleEn, data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if strings.Contains(htmlBody, "<script>") {
+		t.Fatalf("username was not escaped in html body:\n%s", htmlBody)
+	}
+}
+
+func TestResolveLocale(t *testing.T) {
+	setupTestDB(t)
+
+	if got := ResolveLocale("en_US"); got != LocaleEn {
+		t.Fatalf("user preference: got %q, want %q", got, LocaleEn)
+	}
+	if got := ResolveLocale(""); got != LocaleZhCN {
+		t.Fatalf("fallback: got %q, want %q", got, LocaleZhCN)
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigDefaultLocale: "en"})
+	if got := ResolveLocale("fr"); got != LocaleEn {
+		t.Fatalf("site default: got %q, want %q", got, LocaleEn)
+	}
+	if got := ResolveLocale("zh-TW"); got != LocaleZhCN {
+		t.Fatalf("user preference over site default: got %q, want %q", got, LocaleZhCN)
+	}
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// exportImageBatchSize 导出时每批查询的图片记录数量
+const exportImageBatchSize = 200
+
+// UserExportProfile 导出数据中的用户资料 (不含密码等敏感字段)
+type UserExportProfile struct {
+	ID            uint      `json:"id"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Admin         bool      `json:"admin"`
+	Status        int       `json:"status"`
+	Avatar        string    `json:"avatar"`
+	Locale        string    `json:"locale"`
+	StorageQuota  *int64    `json:"storage_quota"`
+	StorageUsed   int64     `json:"storage_used"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// userExportManifest 导出压缩包中的 manifest.json
+type userExportManifest struct {
+	ExportedAt int64             `json:"exported_at"`
+	Profile    UserExportProfile `json:"profile"`
+	ImageCount int64             `json:"image_count"`
+	AlbumCount int               `json:"album_count"`
+}
+
+// userExportAlbum 导出压缩包 albums.json 中的相册及其包含的图片 ID
+type userExportAlbum struct {
+	model.Album
+	ImageIDs []uint `json:"image_ids"`
+}
+
+// ExportUserData 导出用户的全部数据，返回 ZIP 数据流
+// 压缩包内包含 manifest.json (用户资料)、images.json (图片元数据)、albums.json (相册)、
+// images/ 下与存储布局一致的图片文件以及 avatars/ 下的头像。
+// 压缩在后台协程中边读边写，图片记录分批查询，内存占用与图库大小无关；
+// ctx 取消 (如客户端断开) 时停止写入，读取方得到 ctx.Err()。
+// 调用方读取完毕或中途放弃时都必须 Close 返回的 ReadCloser。
+func ExportUserData(ctx context.Context, userID uint) (io.ReadCloser, error) {
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newAuthError(AuthErrorNotFound, "用户不存在")
+		}
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeUserExport(ctx, pw, &user))
+	}()
+	return pr, nil
+}
+
+// writeUserExport 将导出内容以 ZIP 格式写入 w
+func writeUserExport(ctx context.Context, w io.Writer, user *model.User) error {
+	cfg := config.Get()
+	store, err := ImageStorage()
+	if err != nil {
+		return err
+	}
+	avatarRoot := cfg.Upload.AvatarPath
+	if avatarRoot == "" {
+		avatarRoot = "uploads/avatars"
+	}
+
+	var imageCount int64
+	if err := db.DB.Model(&model.Image{}).Where("user_id = ?", user.ID).Count(&imageCount).Error; err != nil {
+		return err
+	}
+	albums, err := loadExportAlbums(user.ID)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	manifest := userExportManifest{
+		ExportedAt: time.Now().Unix(),
+		Profile: UserExportProfile{
+			ID:            user.ID,
+			Username:      user.Username,
+			DisplayName:   user.DisplayName,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			Admin:         user.Admin,
+			Status:        user.Status,
+			Avatar:        user.Avatar,
+			Locale:        user.Locale,
+			StorageQuota:  user.StorageQuota,
+			StorageUsed:   user.StorageUsed,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+		},
+		ImageCount: imageCount,
+		AlbumCount: len(albums),
+	}
+	mw, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	// 第一遍：分批写入图片元数据 (JSON 数组)
+	iw, err := zw.Create("images.json")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(iw, "["); err != nil {
+		return err
+	}
+	first := true
+	err = forEachUserImageBatch(user.ID, func(images []model.Image) error {
+		for _, img := range images {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(iw, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			data, err := json.Marshal(img)
+			if err != nil {
+				return err
+			}
+			if _, err := iw.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(iw, "]\n"); err != nil {
+		return err
+	}
+
+	aw, err := zw.Create("albums.json")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(aw).Encode(albums); err != nil {
+		return err
+	}
+
+	// 第二遍：分批写入图片文件
+	err = forEachUserImageBatch(user.ID, func(images []model.Image) error {
+		for _, img := range images {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			key, err := storage.CleanKey(img.Path)
+			if err != nil {
+				log.Printf("[Export] 跳过非法图片路径 %q: %v", img.Path, err)
+				continue
+			}
+			if err := addStoredImageToZip(ctx, zw, store, path.Join("images", key), key, time.Unix(img.UploadedAt, 0)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if user.Avatar != "" {
+		rel := path.Join(fmt.Sprintf("%d", user.ID), user.Avatar)
+		if fullPath, err := utils.SecureJoin(avatarRoot, rel); err == nil {
+			if err := addFileToZip(zw, path.Join("avatars", rel), fullPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+// loadExportAlbums 读取用户的全部相册及其中的图片 ID (按加入时间排序)
+func loadExportAlbums(userID uint) ([]userExportAlbum, error) {
+	var albums []model.Album
+	if err := db.DB.Where("user_id = ?", userID).Order("id asc").Find(&albums).Error; err != nil {
+		return nil, err
+	}
+	result := make([]userExportAlbum, 0, len(albums))
+	for _, album := range albums {
+		ids := []uint{}
+		if err := db.DB.Model(&model.AlbumImage{}).Where("album_id = ?", album.ID).
+			Order("added_at asc, image_id asc").Pluck("image_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		result = append(result, userExportAlbum{Album: album, ImageIDs: ids})
+	}
+	return result, nil
+}
+
+// forEachUserImageBatch 按 ID 顺序分批遍历用户的图片记录
+func forEachUserImageBatch(userID uint, fn func(images []model.Image) error) error {
+	var lastID uint
+	for {
+		var images []model.Image
+		if err := db.DB.Where("user_id = ? AND id > ?", userID, lastID).
+			Order("id asc").Limit(exportImageBatchSize).Find(&images).Error; err != nil {
+			return err
+		}
+		if len(images) == 0 {
+			return nil
+		}
+		if err := fn(images); err != nil {
+			return err
+		}
+		lastID = images[len(images)-1].ID
+	}
+}
+
+// addFileToZip 将磁盘文件以流的方式写入压缩包，文件不存在时跳过
+func addFileToZip(zw *zip.Writer, name, fullPath string) error {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("[Export] 文件不存在，已跳过: %s", name)
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	// 图片本身已压缩，直接存储以节省 CPU
+	header.Method = zip.Store
+
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
+
+// addStoredImageToZip 将存储后端中的图片以流的方式写入压缩包，文件不存在时跳过
+func addStoredImageToZip(ctx context.Context, zw *zip.Writer, store storage.Storage, name, key string, modified time.Time) error {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("[Export] 文件不存在，已跳过: %s", name)
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	// 图片本身已压缩，直接存储以节省 CPU
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
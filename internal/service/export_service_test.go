@@ -0,0 +1,136 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readZipEntry 读取压缩包中的指定文件，不存在时终止测试
+func readZipEntry(t *testing.T, zr *zip.Reader, name string) []byte {
+	t.Helper()
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return data
+}
+
+func TestExportUserData(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "exporter")
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+
+	contents := map[string][]byte{
+		"2026/01/a.png": []byte("first image"),
+		"2026/02/b.png": []byte("second image"),
+	}
+	var images []model.Image
+	for key, data := range contents {
+		putObject(t, store, key, data)
+		img := model.Image{Filename: key, Path: key, Size: int64(len(data)), MimeType: ".png", UploadedAt: time.Now().Unix(), UserID: user.ID}
+		if err := db.DB.Create(&img).Error; err != nil {
+			t.Fatalf("create image: %v", err)
+		}
+		images = append(images, img)
+	}
+	album := model.Album{UserID: user.ID, Name: "holiday", CreatedAt: 1, UpdatedAt: 1}
+	if err := db.DB.Create(&album).Error; err != nil {
+		t.Fatalf("create album: %v", err)
+	}
+	if err := db.DB.Create(&model.AlbumImage{AlbumID: album.ID, ImageID: images[0].ID, AddedAt: 1}).Error; err != nil {
+		t.Fatalf("add album image: %v", err)
+	}
+	// 其他用户的数据不应导出
+	other := createTestUser(t, "other")
+	createStoredImage(t, other.ID, "2026/03/other.png")
+
+	reader, err := ExportUserData(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+
+	rawManifest := readZipEntry(t, zr, "manifest.json")
+	var manifest userExportManifest
+	if err := json.Unmarshal(rawManifest, &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if manifest.Profile.ID != user.ID || manifest.Profile.Username != "exporter" || manifest.ImageCount != 2 || manifest.AlbumCount != 1 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	if strings.Contains(string(rawManifest), "password") || strings.Contains(string(rawManifest), user.Password) {
+		t.Fatal("manifest should not contain the password hash")
+	}
+
+	var exported []model.Image
+	if err := json.Unmarshal(readZipEntry(t, zr, "images.json"), &exported); err != nil || len(exported) != 2 {
+		t.Fatalf("images.json = %v, err = %v", exported, err)
+	}
+	for key, want := range contents {
+		if got := readZipEntry(t, zr, "images/"+key); !bytes.Equal(got, want) {
+			t.Fatalf("images/%s = %q, want %q", key, got, want)
+		}
+	}
+	if _, err := zr.Open("images/2026/03/other.png"); err == nil {
+		t.Fatal("another user's image should not be exported")
+	}
+
+	var albums []userExportAlbum
+	if err := json.Unmarshal(readZipEntry(t, zr, "albums.json"), &albums); err != nil {
+		t.Fatalf("decode albums.json: %v", err)
+	}
+	if len(albums) != 1 || albums[0].Name != "holiday" || len(albums[0].ImageIDs) != 1 || albums[0].ImageIDs[0] != images[0].ID {
+		t.Fatalf("albums.json = %+v", albums)
+	}
+}
+
+func TestExportUserDataStopsWhenCancelled(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "exporter")
+	createStoredImage(t, user.ID, "2026/01/a.png")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, err := ExportUserData(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	defer reader.Close()
+	cancel()
+
+	if _, err := io.ReadAll(reader); !errors.Is(err, context.Canceled) {
+		t.Fatalf("read after cancel: got %v, want context.Canceled", err)
+	}
+}
+
+func TestExportUserDataUnknownUser(t *testing.T) {
+	setupTestDB(t)
+	_, err := ExportUserData(context.Background(), 999)
+	if authErr, ok := AsAuthError(err); !ok || authErr.Code != AuthErrorNotFound {
+		t.Fatalf("got %v, want not found", err)
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+)
+
+// errStreamLimitExceeded 流式写入时超过了允许写入的字节数
+var errStreamLimitExceeded = errors.New("stream limit exceeded")
+
+// streamedFile 流式写入临时文件后的结果
+type streamedFile struct {
+	TempPath string // 临时文件路径 (与目标文件位于同一目录，便于原子重命名)
+	Size     int64  // 实际写入的字节数
+	Hash     string // 内容 SHA-256 (Hex)
+}
+
+// streamToTempFile 将 src 以流的方式写入 dir 下的临时文件，写入过程中同时计算大小与哈希，
+// 内存占用与文件大小无关。写入超过 limit 字节时立即终止并返回 errStreamLimitExceeded。
+// 任何错误都会清理临时文件。
+func streamToTempFile(src io.Reader, dir string, limit int64) (*streamedFile, error) {
+	tmp, err := os.CreateTemp(dir, ".upload-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+
+	hasher := sha256.New()
+	// 多读 1 字节用于探测是否超限
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(src, limit+1))
+	if err == nil && written <= limit {
+		// 落盘后再重命名，避免异常断电后留下空文件或截断文件
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written > limit {
+		err = errStreamLimitExceeded
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+
+	return &streamedFile{
+		TempPath: tmpPath,
+		Size:     written,
+		Hash:     hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// decodeImageSize 重新打开文件，仅解析文件头获取图片宽高
+func decodeImageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// decodeImageFile 完整解码图片文件 (调用方需事先校验像素面积，防止解压炸弹)
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// commitTempFile 将临时文件原子地重命名为目标文件，并同步所在目录使重命名持久化。
+// 失败时清理临时文件或已重命名的目标文件
+func commitTempFile(tmpPath, dst string) error {
+	if err := os.Rename(tmpPath, dst); err != nil {
+		log.Printf("Rename temp file error: %v\n", err)
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := utils.SyncDir(filepath.Dir(dst)); err != nil {
+		log.Printf("Sync directory error: %v\n", err)
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// removeTempFile 清理临时文件 (用于后续校验失败时)
+func removeTempFile(f *streamedFile) {
+	if f != nil {
+		_ = os.Remove(f.TempPath)
+	}
+}
+
+// contextReader 在 ctx 取消后读取返回 ctx.Err()，用于中止客户端已断开的上传
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// storeTempFile 将临时文件写入存储后端后清理临时文件
+// 支持 FileCommitter 的存储 (本地磁盘) 直接重命名，避免再次拷贝；写入远程存储时 ctx 取消会中止上传
+func storeTempFile(ctx context.Context, store storage.Storage, key string, f *streamedFile) error {
+	if committer, ok := store.(storage.FileCommitter); ok {
+		return committer.Commit(key, f.TempPath)
+	}
+	defer removeTempFile(f)
+
+	src, err := os.Open(f.TempPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	return store.Put(ctx, key, src, f.Size)
+}
+
+// deleteStoredImage 删除存储中的图片文件，失败时只记录日志
+func deleteStoredImage(store storage.Storage, key string) {
+	if err := store.Delete(context.Background(), key); err != nil {
+		log.Printf("Delete file error: %v, key: %s\n", err, key)
+	}
+}
+
+// imageURL 返回图片的访问地址，存储后端提供公开地址时直接使用
+func imageURL(store storage.Storage, key string) string {
+	if provider, ok := store.(storage.PublicURLProvider); ok {
+		return provider.PublicURLPrefix() + key
+	}
+	return servedImageURLPrefix() + key
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"runtime"
+	"testing"
+)
+
+// patternReader 生成 n 字节的固定模式数据，自身不持有缓冲区
+type patternReader struct {
+	n, off int64
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.off >= r.n {
+		return 0, io.EOF
+	}
+	if remaining := r.n - r.off; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	for i := range p {
+		p[i] = byte((r.off + int64(i)) % 251)
+	}
+	r.off += int64(len(p))
+	return len(p), nil
+}
+
+// failingReader 读取 n 字节后返回错误
+type failingReader struct {
+	patternReader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.patternReader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+// tempFiles 返回 dir 下的文件数量
+func tempFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	return len(entries)
+}
+
+func TestStreamToTempFileKeepsMemoryBounded(t *testing.T) {
+	const size = 32 << 20
+	dir := t.TempDir()
+
+	want := sha256.New()
+	if _, err := io.Copy(want, &patternReader{n: size}); err != nil {
+		t.Fatalf("hash pattern: %v", err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	stored, err := streamToTempFile(&patternReader{n: size}, dir, size)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	defer removeTempFile(stored)
+
+	// 全程只使用固定大小的复制缓冲区，分配量远小于文件大小
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("streaming %d bytes allocated %d bytes", size, allocated)
+	}
+	if stored.Size != size || stored.Hash != hex.EncodeToString(want.Sum(nil)) {
+		t.Fatalf("stored size %d hash %s", stored.Size, stored.Hash)
+	}
+	if info, err := os.Stat(stored.TempPath); err != nil || info.Size() != size {
+		t.Fatalf("temp file: %v, %v", info, err)
+	}
+}
+
+func TestStreamToTempFileCleansUpOnError(t *testing.T) {
+	dir := t.TempDir()
+
+	// 超过上限时在写满上限后立即终止
+	if _, err := streamToTempFile(&patternReader{n: 1 << 20}, dir, 1<<19); !errors.Is(err, errStreamLimitExceeded) {
+		t.Fatalf("over limit: err = %v, want errStreamLimitExceeded", err)
+	}
+	if n := tempFiles(t, dir); n != 0 {
+		t.Fatalf("temp files after exceeding the limit = %d, want 0", n)
+	}
+
+	// 读取中途出错
+	if _, err := streamToTempFile(&failingReader{patternReader{n: 1 << 16}}, dir, 1<<20); err == nil {
+		t.Fatal("read error not returned")
+	}
+	if n := tempFiles(t, dir); n != 0 {
+		t.Fatalf("temp files after a read error = %d, want 0", n)
+	}
+
+	// 恰好等于上限时成功
+	stored, err := streamToTempFile(&patternReader{n: 1 << 19}, dir, 1<<19)
+	if err != nil || stored.Size != 1<<19 {
+		t.Fatalf("at limit: %+v, %v", stored, err)
+	}
+	removeTempFile(stored)
+}
+
+// failingUpload 读取到一半时出错的上传文件
+type failingUpload struct {
+	*bytes.Reader
+	failAfter int64
+}
+
+func (f *failingUpload) Read(p []byte) (int, error) {
+	pos := f.Size() - int64(f.Len())
+	if pos >= f.failAfter {
+		return 0, errors.New("disk write failed")
+	}
+	if remaining := f.failAfter - pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	return f.Reader.Read(p)
+}
+
+func (f *failingUpload) Close() error { return nil }
+
+// assertNoUploadLeftovers 检查存储中没有任何文件且没有图片记录
+func assertNoUploadLeftovers(t *testing.T) {
+	t.Helper()
+	if n := storedFileCount(t); n != 0 {
+		t.Fatalf("files left in storage = %d, want 0", n)
+	}
+	var count int64
+	db.DB.Model(&model.Image{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("image records = %d, want 0", count)
+	}
+	var user model.User
+	db.DB.Where("username = ?", "alice").First(&user)
+	if user.StorageUsed != 0 {
+		t.Fatalf("storage used = %d, want 0", user.StorageUsed)
+	}
+}
+
+func TestUploadWriteErrorLeavesNothingBehind(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	data := make([]byte, 64<<10)
+	if _, err := io.ReadFull(&patternReader{n: int64(len(data))}, data); err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+
+	src := uploadSource{
+		Filename: "a.png",
+		Size:     int64(len(data)),
+		Open: func() (multipart.File, error) {
+			return &failingUpload{Reader: bytes.NewReader(data), failAfter: int64(len(data)) / 2}, nil
+		},
+	}
+	if _, _, err := saveImageUpload(context.Background(), src, user.ID, ".png", 0, 1<<30); err == nil {
+		t.Fatal("upload with a failing write succeeded")
+	}
+	assertNoUploadLeftovers(t)
+}
+
+func TestUploadCommitErrorLeavesNothingBehind(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	setTestSettings(t, map[string]string{consts.ConfigStorageLayout: StorageLayoutHash})
+
+	// 先上传一次得到内容哈希对应的分片目录，再删除图片并用同名文件占住该目录，使提交时无法创建目录
+	img, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "a.png"), user.ID, nil)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := DeleteImage(context.Background(), img); err != nil {
+		t.Fatalf("delete image: %v", err)
+	}
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	blocked, err := store.(*storage.Local).Path(img.Path[:2])
+	if err != nil {
+		t.Fatalf("shard path: %v", err)
+	}
+	if err := os.RemoveAll(blocked); err != nil {
+		t.Fatalf("remove shard: %v", err)
+	}
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatalf("block shard: %v", err)
+	}
+
+	if _, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "a.png"), user.ID, nil); err == nil {
+		t.Fatal("upload succeeded although the file could not be committed")
+	}
+	if err := os.Remove(blocked); err != nil {
+		t.Fatalf("unblock shard: %v", err)
+	}
+	assertNoUploadLeftovers(t)
+}
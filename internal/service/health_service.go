@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/storage"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckTimeout 单项依赖检查的超时时间，防止依赖挂起导致探针无响应
+const healthCheckTimeout = 2 * time.Second
+
+var shuttingDown atomic.Bool
+
+// SetShuttingDown 标记服务正在关闭，之后存活与就绪检查均返回失败
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// IsShuttingDown 服务是否正在关闭
+func IsShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// DependencyCheck 单项依赖的检查结果
+type DependencyCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// CheckReadiness 依次检查数据库与上传目录，每项检查都带有超时
+// 全部通过时 ready 为 true
+func CheckReadiness(ctx context.Context) (ready bool, checks []DependencyCheck) {
+	checks = []DependencyCheck{
+		runDependencyCheck(ctx, "database", checkDatabase),
+		runDependencyCheck(ctx, "storage", checkStorage),
+	}
+	ready = !IsShuttingDown()
+	for _, c := range checks {
+		if !c.OK {
+			ready = false
+		}
+	}
+	return ready, checks
+}
+
+// runDependencyCheck 在超时上下文中执行检查，超时后立即返回失败而不等待检查结束
+func runDependencyCheck(parent context.Context, name string, check func(ctx context.Context) error) DependencyCheck {
+	ctx, cancel := context.WithTimeout(parent, healthCheckTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := DependencyCheck{Name: name, OK: err == nil}
+	if err != nil {
+		// 探针无需认证，详细错误 (可能包含连接地址等信息) 只记录日志
+		log.Printf("Readiness check %s failed: %v", name, err)
+		result.Error = "unavailable"
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timeout"
+		}
+	}
+	return result
+}
+
+func checkDatabase(ctx context.Context) error {
+	if db.DB == nil {
+		return errors.New("database not initialized")
+	}
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func checkStorage(ctx context.Context) error {
+	store, err := ImageStorage()
+	if err != nil {
+		return err
+	}
+	// 远程存储检查能否访问 (对象不存在不视为错误)
+	if _, ok := store.(*storage.Local); !ok {
+		_, err := store.Exists(ctx, ".healthz")
+		return err
+	}
+
+	info, err := os.Stat(imageUploadRoot())
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("upload path is not a directory")
+	}
+	return nil
+}
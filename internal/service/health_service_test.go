@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// checkByName 按名称查找检查结果
+func checkByName(t *testing.T, checks []DependencyCheck, name string) DependencyCheck {
+	t.Helper()
+	for _, c := range checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %s check in %+v", name, checks)
+	return DependencyCheck{}
+}
+
+func TestCheckReadiness(t *testing.T) {
+	d := setupTestDB(t)
+	if err := os.MkdirAll(imageUploadRoot(), 0755); err != nil {
+		t.Fatalf("create upload root: %v", err)
+	}
+
+	if ready, checks := CheckReadiness(context.Background()); !ready {
+		t.Fatalf("healthy dependencies reported not ready: %+v", checks)
+	}
+
+	// 模拟数据库故障：错误详情只写日志，不返回给调用方
+	sqlDB, err := d.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	_ = sqlDB.Close()
+	ready, checks := CheckReadiness(context.Background())
+	if ready {
+		t.Fatal("closed database should fail readiness")
+	}
+	if c := checkByName(t, checks, "database"); c.OK || c.Error != "unavailable" {
+		t.Fatalf("database check = %+v, want a generic failure", c)
+	}
+	if c := checkByName(t, checks, "storage"); !c.OK {
+		t.Fatalf("storage check = %+v", c)
+	}
+}
+
+func TestDependencyCheckTimesOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := runDependencyCheck(ctx, "hung", func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	if result.OK || result.Error != "timeout" {
+		t.Fatalf("result = %+v, want timeout", result)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("a hung check should not block the probe")
+	}
+}
+
+func TestShuttingDownFailsReadiness(t *testing.T) {
+	setupTestDB(t)
+	if err := os.MkdirAll(imageUploadRoot(), 0755); err != nil {
+		t.Fatalf("create upload root: %v", err)
+	}
+
+	SetShuttingDown()
+	if !IsShuttingDown() {
+		t.Fatal("IsShuttingDown should report true")
+	}
+	if ready, checks := CheckReadiness(context.Background()); ready {
+		t.Fatalf("ready while shutting down: %+v", checks)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+)
+
+// webpAltSourceTypes 会生成 WebP 备选文件的图片类型 (GIF 可能为动图，WebP/SVG 无需转换)
+var webpAltSourceTypes = map[string]bool{"jpg": true, "png": true, "bmp": true}
+
+// webpAltLosslessTypes 使用无损编码生成备选文件的图片类型，JPEG 本身有损，按 ConfigJpegQuality 有损编码
+var webpAltLosslessTypes = map[string]bool{"png": true, "bmp": true}
+
+// WebPAltKey 返回图片 WebP 备选文件的存储键 (原文件键加 .webp 后缀，避免与其他图片冲突)
+func WebPAltKey(key string) string {
+	return key + ".webp"
+}
+
+// generateWebPAlternative 按 ConfigGenerateAltFormats 为已写入临时文件的上传图片生成 WebP 备选文件
+// 未启用、类型不适用、编码失败或结果不小于原文件时返回 nil，不影响上传本身
+func generateWebPAlternative(stored *streamedFile, ext, dir string) *streamedFile {
+	imageType := utils.ImageTypeForExt(ext)
+	if !GetBool(consts.ConfigGenerateAltFormats) || !webpAltSourceTypes[imageType] {
+		return nil
+	}
+	img, err := decodeImageFile(stored.TempPath)
+	if err != nil {
+		log.Printf("WebP alternative decode error: %v\n", err)
+		return nil
+	}
+	quality := GetInt(consts.ConfigJpegQuality)
+	if quality < 1 || quality > 100 {
+		quality = 85
+	}
+	data, err := utils.EncodeWebP(img, quality, webpAltLosslessTypes[imageType])
+	if err != nil {
+		log.Printf("WebP alternative encode error: %v\n", err)
+		return nil
+	}
+	if int64(len(data)) >= stored.Size {
+		return nil
+	}
+	alt, err := streamToTempFile(bytes.NewReader(data), dir, stored.Size)
+	if err != nil {
+		log.Printf("WebP alternative save error: %v\n", err)
+		return nil
+	}
+	return alt
+}
+
+// storeWebPAlternative 将备选文件写入 WebPAltKey(key)，返回写入的键；alt 为 nil 或写入失败时返回空字符串
+func storeWebPAlternative(ctx context.Context, store storage.Storage, key string, alt *streamedFile) string {
+	if alt == nil {
+		return ""
+	}
+	altKey := WebPAltKey(key)
+	if err := storeTempFile(ctx, store, altKey, alt); err != nil {
+		log.Printf("Store webp alternative error: %v, key: %s\n", err, altKey)
+		removeTempFile(alt)
+		return ""
+	}
+	return altKey
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/utils"
+	"testing"
+)
+
+// gradientImage 生成一张带渐变与噪点的测试图片 (PNG 无法高效压缩)
+func gradientImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x*y) ^ uint8(x+y), A: 255})
+		}
+	}
+	return img
+}
+
+// tempStoredFile 将 data 写入临时目录，返回对应的 streamedFile
+func tempStoredFile(t *testing.T, data []byte) *streamedFile {
+	t.Helper()
+	stored, err := streamToTempFile(bytes.NewReader(data), t.TempDir(), int64(len(data)))
+	if err != nil {
+		t.Fatalf("temp file: %v", err)
+	}
+	t.Cleanup(func() { removeTempFile(stored) })
+	return stored
+}
+
+func readAlt(t *testing.T, alt *streamedFile) []byte {
+	t.Helper()
+	if alt == nil {
+		t.Fatal("no webp alternative generated")
+	}
+	t.Cleanup(func() { removeTempFile(alt) })
+	data, err := os.ReadFile(alt.TempPath)
+	if err != nil {
+		t.Fatalf("read alt: %v", err)
+	}
+	return data
+}
+
+func TestGenerateWebPAlternative(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigGenerateAltFormats: "true"})
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, gradientImage(256, 256), &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	if data := readAlt(t, generateWebPAlternative(tempStoredFile(t, jpg.Bytes()), ".jpg", t.TempDir())); utils.IsLosslessWebP(data) {
+		t.Fatal("JPEG alternative encoded losslessly")
+	}
+
+	var pngBuf bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&pngBuf, gradientImage(128, 128)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	if data := readAlt(t, generateWebPAlternative(tempStoredFile(t, pngBuf.Bytes()), ".png", t.TempDir())); !utils.IsLosslessWebP(data) {
+		t.Fatal("PNG alternative not encoded losslessly")
+	}
+
+	if alt := generateWebPAlternative(tempStoredFile(t, pngBuf.Bytes()), ".gif", t.TempDir()); alt != nil {
+		t.Fatal("alternative generated for a GIF")
+	}
+}
+
+func TestGenerateWebPAlternativeDisabled(t *testing.T) {
+	setupTestDB(t)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, gradientImage(64, 64), nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if alt := generateWebPAlternative(tempStoredFile(t, buf.Bytes()), ".jpg", t.TempDir()); alt != nil {
+		t.Fatal("alternative generated while generate_alt_formats is off")
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"sync"
+)
+
+// batchUploadWorkers 批量上传时同时处理的文件数量，避免大批量上传耗尽文件描述符
+const batchUploadWorkers = 4
+
+// UploadResult 批量上传中单个文件的处理结果
+type UploadResult struct {
+	Filename string `json:"filename"`
+	Success  bool   `json:"success"`
+	ID       uint   `json:"id,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// DeleteToken 一次性删除 Token，见 DeleteImageByToken
+	DeleteToken string `json:"delete_token,omitempty"`
+}
+
+// GetMaxBatchUploadFiles 获取批量上传单次最多文件数量
+// 开启上传限流时每个文件计为一次上传请求，数量不超过上传突发限制，否则整批请求总会被限流拒绝
+func GetMaxBatchUploadFiles() int {
+	n := GetInt(consts.ConfigMaxBatchUploadFiles)
+	if n <= 0 {
+		n = 20
+	}
+	if GetBool(consts.ConfigRateLimitEnabled) && GetFloat64(consts.ConfigRateLimitUploadRPS) > 0 {
+		if burst := GetInt(consts.ConfigRateLimitUploadBurst); burst > 0 && burst < n {
+			n = burst
+		}
+	}
+	return n
+}
+
+// batchQuota 批量上传期间的配额预占，保证并发处理时累计用量不超过配额
+type batchQuota struct {
+	mu       sync.Mutex
+	used     int64
+	quota    int64
+	exceeded bool // 一旦配额不足，后续文件不再处理
+}
+
+// reserve 为文件预占空间，返回预占前的已用空间；配额不足时返回错误
+func (q *batchQuota) reserve(size int64) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.exceeded {
+		return 0, newLocalizedError(ErrStorageQuotaExceeded, MsgStorageQuotaExceededBatch)
+	}
+	if q.used+size > q.quota {
+		q.exceeded = true
+		return 0, quotaExceededError(q.used, q.quota)
+	}
+	before := q.used
+	q.used += size
+	return before, nil
+}
+
+// settle 按实际写入大小修正预占 (失败时 actual 为 0，释放预占)
+func (q *batchQuota) settle(reserved, actual int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.used += actual - reserved
+}
+
+// ProcessImageUploads 批量处理图片上传，逐个校验并保存，返回与 files 顺序一致的结果
+// 配额按本批次累计检查，配额不足后剩余文件均以配额不足失败；每个成功的文件单独记账，部分失败不影响已成功文件的存储统计
+func ProcessImageUploads(ctx context.Context, files []*multipart.FileHeader, userID uint) ([]UploadResult, error) {
+	if len(files) == 0 {
+		return nil, errors.New("请选择文件")
+	}
+	if maxFiles := GetMaxBatchUploadFiles(); len(files) > maxFiles {
+		return nil, fmt.Errorf("单次最多上传 %d 个文件", maxFiles)
+	}
+
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		log.Printf("Get user error: %v\n", err)
+		return nil, errors.New("查询用户信息失败")
+	}
+	quota := &batchQuota{used: user.StorageUsed, quota: userStorageQuota(&user)}
+
+	results := make([]UploadResult, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := batchUploadWorkers
+	if len(files) < workers {
+		workers = len(files)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = processBatchUploadFile(ctx, files[i], &user, quota)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+func processBatchUploadFile(ctx context.Context, file *multipart.FileHeader, user *model.User, quota *batchQuota) UploadResult {
+	result := UploadResult{Filename: utils.SanitizeFilename(file.Filename)}
+
+	valid, ext, err := ValidateImageFile(file)
+	if !valid {
+		result.Error = err.Error()
+		return result
+	}
+
+	usedBefore, err := quota.reserve(file.Size)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	reservation, err := reserveDailyUpload(user, file.Size)
+	if err != nil {
+		quota.settle(file.Size, 0)
+		result.Error = err.Error()
+		return result
+	}
+
+	image, url, err := saveImageUpload(ctx, formUploadSource(file), user.ID, ext, usedBefore, quota.quota)
+	if err != nil {
+		quota.settle(file.Size, 0)
+		reservation.settle(0, false)
+		result.Error = err.Error()
+		return result
+	}
+	quota.settle(file.Size, image.Size)
+	reservation.settle(image.Size, true)
+
+	result.Success = true
+	result.ID = image.ID
+	result.URL = url
+	result.DeleteToken = image.DeleteToken
+	return result
+}
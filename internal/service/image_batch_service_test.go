@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strconv"
+	"testing"
+)
+
+// batchFileHeaders 构造一批 multipart 文件头，files 为文件名到内容的有序列表
+func batchFileHeaders(t *testing.T, files ...[2]string) []*multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, f := range files {
+		part, _ := w.CreateFormFile("files", f[0])
+		_, _ = part.Write([]byte(f[1]))
+	}
+	_ = w.Close()
+
+	req := httptest.NewRequest("POST", "/upload/batch", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("parse multipart: %v", err)
+	}
+	t.Cleanup(func() { _ = req.MultipartForm.RemoveAll() })
+	return req.MultipartForm.File["files"]
+}
+
+// batchPNG 返回内容互不相同的 PNG 文件 (size 不同，避免任何按内容去重的影响)
+func batchPNG(t *testing.T, i int) [2]string {
+	t.Helper()
+	var img bytes.Buffer
+	if err := png.Encode(&img, gradientImage(16+i, 16)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return [2]string{"f" + strconv.Itoa(i) + ".png", img.String()}
+}
+
+// assertStorageMatchesImages 检查用户的已用空间与实际保存的图片一致
+func assertStorageMatchesImages(t *testing.T, userID uint, wantImages int) {
+	t.Helper()
+	var images []model.Image
+	if err := db.DB.Where("user_id = ?", userID).Find(&images).Error; err != nil {
+		t.Fatalf("load images: %v", err)
+	}
+	if len(images) != wantImages {
+		t.Fatalf("stored images = %d, want %d", len(images), wantImages)
+	}
+	var total int64
+	for _, img := range images {
+		total += img.Size
+	}
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if user.StorageUsed != total {
+		t.Fatalf("storage used = %d, want %d", user.StorageUsed, total)
+	}
+}
+
+func TestProcessImageUploadsMixedBatch(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "batcher")
+
+	files := batchFileHeaders(t,
+		batchPNG(t, 0),
+		[2]string{"notes.txt", "plain text"},
+		batchPNG(t, 1),
+		[2]string{"fake.png", "not really a png"},
+	)
+	results, err := ProcessImageUploads(context.Background(), files, user.ID)
+	if err != nil {
+		t.Fatalf("ProcessImageUploads: %v", err)
+	}
+	if len(results) != len(files) {
+		t.Fatalf("results = %d, want %d", len(results), len(files))
+	}
+
+	// 结果与文件顺序一致，失败的文件带有原因
+	wantOK := []bool{true, false, true, false}
+	for i, r := range results {
+		if r.Filename != files[i].Filename {
+			t.Errorf("result %d filename = %q, want %q", i, r.Filename, files[i].Filename)
+		}
+		if r.Success != wantOK[i] {
+			t.Errorf("result %d (%s) success = %v, want %v (error %q)", i, r.Filename, r.Success, wantOK[i], r.Error)
+		}
+		if r.Success && (r.ID == 0 || r.URL == "") {
+			t.Errorf("result %d missing id or url: %+v", i, r)
+		}
+		if !r.Success && r.Error == "" {
+			t.Errorf("result %d failed without a reason", i)
+		}
+	}
+	assertStorageMatchesImages(t, user.ID, 2)
+	if n := storedFileCount(t); n != 2 {
+		t.Fatalf("files on disk = %d, want 2", n)
+	}
+}
+
+func TestProcessImageUploadsStopsAtQuota(t *testing.T) {
+	setupTestDB(t)
+
+	var files [][2]string
+	var sizes []int64
+	for i := 0; i < 5; i++ {
+		f := batchPNG(t, i)
+		files = append(files, f)
+		sizes = append(sizes, int64(len(f[1])))
+	}
+	// 配额只够前两个文件，第三个文件无论按何种顺序处理都放不下
+	quota := sizes[0] + sizes[1] + sizes[2]/2
+	user := createTestUser(t, "batcher", func(u *model.User) { u.StorageQuota = &quota })
+
+	results, err := ProcessImageUploads(context.Background(), batchFileHeaders(t, files...), user.ID)
+	if err != nil {
+		t.Fatalf("ProcessImageUploads: %v", err)
+	}
+
+	succeeded := 0
+	for _, r := range results {
+		if r.Success {
+			succeeded++
+			continue
+		}
+		if r.Error == "" {
+			t.Errorf("%s failed without a reason", r.Filename)
+		}
+	}
+	if succeeded == 0 || succeeded == len(files) {
+		t.Fatalf("succeeded = %d, want a partial batch", succeeded)
+	}
+
+	// 配额用尽前已保存的文件保留，存储统计与实际保存的图片一致且不超过配额
+	assertStorageMatchesImages(t, user.ID, succeeded)
+	var updated model.User
+	db.DB.First(&updated, user.ID)
+	if updated.StorageUsed > quota {
+		t.Fatalf("storage used %d exceeds quota %d", updated.StorageUsed, quota)
+	}
+	if n := storedFileCount(t); n != succeeded {
+		t.Fatalf("files on disk = %d, want %d", n, succeeded)
+	}
+
+	// 已用尽配额后，下一批全部以配额不足失败
+	results, err = ProcessImageUploads(context.Background(), batchFileHeaders(t, batchPNG(t, 10)), user.ID)
+	if err != nil {
+		t.Fatalf("ProcessImageUploads: %v", err)
+	}
+	if results[0].Success {
+		t.Fatal("upload past the quota succeeded")
+	}
+}
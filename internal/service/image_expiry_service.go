@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"sync"
+	"time"
+)
+
+// MaxImageTTL 上传时可设置的最长有效期
+const MaxImageTTL = 3650 * 24 * time.Hour
+
+// expiredImageBatchSize 每批删除的过期图片数量
+const expiredImageBatchSize = 200
+
+var (
+	imageExpiryOnce   sync.Once
+	imageExpiryStopCh chan struct{}
+	imageExpiryDoneCh chan struct{}
+)
+
+// StartImageExpiryJanitor 启动定期删除已过期图片的后台任务 (仅启动一次)
+// 检查间隔由 ConfigExpiryCheckIntervalMinutes 控制，每轮读取最新配置
+func StartImageExpiryJanitor() {
+	imageExpiryOnce.Do(func() {
+		imageExpiryStopCh = make(chan struct{})
+		imageExpiryDoneCh = make(chan struct{})
+		go func() {
+			defer close(imageExpiryDoneCh)
+			timer := time.NewTimer(imageExpiryInterval())
+			defer timer.Stop()
+			for {
+				select {
+				case <-timer.C:
+					if deleted, err := PurgeExpiredImages(time.Now()); err != nil {
+						log.Printf("Purge expired images error: %v\n", err)
+					} else if deleted > 0 {
+						log.Printf("🧹 已清理 %d 张已过期的图片\n", deleted)
+					}
+					timer.Reset(imageExpiryInterval())
+				case <-imageExpiryStopCh:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// StopImageExpiryJanitor 停止清理任务
+func StopImageExpiryJanitor() {
+	if imageExpiryStopCh == nil {
+		return
+	}
+	close(imageExpiryStopCh)
+	<-imageExpiryDoneCh
+	imageExpiryStopCh = nil
+}
+
+// imageExpiryInterval 返回过期图片的检查间隔 (配置无效时为 10 分钟)
+func imageExpiryInterval() time.Duration {
+	minutes := GetInt(consts.ConfigExpiryCheckIntervalMinutes)
+	if minutes <= 0 {
+		minutes = 10
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// PurgeExpiredImages 分批删除过期时间不晚于 now 的图片 (记录、文件与已用空间)，返回删除的数量
+func PurgeExpiredImages(now time.Time) (deleted int, err error) {
+	for {
+		var images []model.Image
+		if err := db.DB.Where("expires_at IS NOT NULL AND expires_at <= ?", now.Unix()).
+			Order("id").Limit(expiredImageBatchSize).Find(&images).Error; err != nil {
+			return deleted, err
+		}
+		if len(images) == 0 {
+			return deleted, nil
+		}
+		if err := BatchDeleteImages(context.Background(), images); err != nil {
+			return deleted, err
+		}
+		deleted += len(images)
+		if len(images) < expiredImageBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// IsImageExpired 判断存储路径 key (原图或 WebP 备选文件) 对应的图片是否已过期
+// 过期但尚未被后台任务删除的图片不再提供访问
+func IsImageExpired(key string) bool {
+	var count int64
+	if err := db.DB.Model(&model.Image{}).
+		Where("(path = ? OR webp_path = ?) AND expires_at IS NOT NULL AND expires_at <= ?", key, key, time.Now().Unix()).
+		Count(&count).Error; err != nil {
+		log.Printf("Check image expiry error: %v", err)
+		return false
+	}
+	return count > 0
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"testing"
+	"time"
+)
+
+func TestPurgeExpiredImages(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	expired := createStoredImage(t, user.ID, "expired.png")
+	kept := createStoredImage(t, user.ID, "kept.png")
+	permanent := createStoredImage(t, user.ID, "permanent.png")
+	past, future := time.Now().Add(-time.Minute).Unix(), time.Now().Add(time.Hour).Unix()
+	db.DB.Model(&expired).Update("expires_at", past)
+	db.DB.Model(&kept).Update("expires_at", future)
+
+	if !IsImageExpired(expired.Path) || IsImageExpired(kept.Path) || IsImageExpired(permanent.Path) {
+		t.Fatal("IsImageExpired reported the wrong images")
+	}
+
+	deleted, err := PurgeExpiredImages(time.Now())
+	if err != nil || deleted != 1 {
+		t.Fatalf("purge = %d, %v", deleted, err)
+	}
+	var ids []uint
+	db.DB.Model(&model.Image{}).Order("id").Pluck("id", &ids)
+	if len(ids) != 2 || ids[0] != kept.ID || ids[1] != permanent.ID {
+		t.Fatalf("remaining images = %v", ids)
+	}
+	store, _ := ImageStorage()
+	if _, err := readObject(t, store, expired.Path); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expired image file not deleted: %v", err)
+	}
+}
+
+func TestImageExpiryJanitorStop(t *testing.T) {
+	setupTestDB(t)
+	StartImageExpiryJanitor()
+	done := make(chan struct{})
+	go func() {
+		StopImageExpiryJanitor()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopImageExpiryJanitor did not return")
+	}
+	StopImageExpiryJanitor()
+}
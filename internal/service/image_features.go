@@ -0,0 +1,27 @@
+package service
+
+import (
+	"log"
+	"perfect-pic-server/internal/utils"
+)
+
+// imageFeatures 上传时从图片像素中提取的附加信息，计算失败的字段保持零值
+type imageFeatures struct {
+	PHash         *int64
+	DominantColor string
+}
+
+// computeImageFeatures 解码临时文件并计算感知哈希与主色调，解码失败时记录日志并返回空结果
+// 调用方需事先校验像素面积
+func computeImageFeatures(path string) imageFeatures {
+	img, err := decodeImageFile(path)
+	if err != nil {
+		log.Printf("Decode image for features error: %v\n", err)
+		return imageFeatures{}
+	}
+	hash := int64(utils.DHash(img))
+	return imageFeatures{
+		PHash:         &hash,
+		DominantColor: utils.DominantColor(img),
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"regexp"
+	"testing"
+)
+
+func TestComputeImageFeatures(t *testing.T) {
+	features := computeImageFeatures(filepath.Join(t.TempDir(), "missing.png"))
+	if features.PHash != nil || features.DominantColor != "" {
+		t.Fatalf("features of a missing file = %+v, want empty", features)
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.png")
+	if err := os.WriteFile(path, []byte("not an image"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if features := computeImageFeatures(path); features.PHash != nil || features.DominantColor != "" {
+		t.Fatalf("features of an undecodable file = %+v, want empty", features)
+	}
+}
+
+func TestUploadStoresDominantColor(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "colorful")
+
+	img, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "c.png"), user.ID, nil)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	var stored model.Image
+	if err := db.DB.First(&stored, img.ID).Error; err != nil {
+		t.Fatalf("load image: %v", err)
+	}
+	if !regexp.MustCompile(`^#[0-9a-f]{6}$`).MatchString(stored.DominantColor) {
+		t.Fatalf("dominant color = %q, want #rrggbb", stored.DominantColor)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrImageNotFound 图片不存在或不属于当前用户
+var ErrImageNotFound = errors.New("图片不存在")
+
+// ImageInfo 图片元信息，仅包含 Web 访问路径，不暴露存储的绝对路径
+type ImageInfo struct {
+	ID               uint   `json:"id"`
+	Filename         string `json:"filename"`
+	OriginalFilename string `json:"original_filename"`
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	Size             int64  `json:"size"`
+	MimeType         string `json:"mime_type"`
+	DominantColor    string `json:"dominant_color"`
+	UploadedAt       int64  `json:"uploaded_at"`
+	ViewCount        int64  `json:"view_count"`
+	LastAccessedAt   int64  `json:"last_accessed_at"`
+	Path             string `json:"path"` // 相对存储路径，与 /api/image_prefix 拼接即为访问地址
+	URL              string `json:"url"`
+}
+
+// GetImageInfo 返回图片元信息
+// 图片目前没有公开/私有之分，按 ID 查询仅允许图片所有者访问；
+// 不属于该用户的图片与不存在的图片一样返回 ErrImageNotFound，避免泄露图片是否存在
+func GetImageInfo(userID, imageID uint) (*ImageInfo, error) {
+	var image model.Image
+	if err := db.DB.Where("id = ? AND user_id = ?", imageID, userID).First(&image).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		return nil, err
+	}
+
+	return &ImageInfo{
+		ID:               image.ID,
+		Filename:         image.Filename,
+		OriginalFilename: image.OriginalFilename,
+		Width:            image.Width,
+		Height:           image.Height,
+		Size:             image.Size,
+		MimeType:         image.MimeType,
+		DominantColor:    image.DominantColor,
+		UploadedAt:       image.UploadedAt,
+		ViewCount:        image.ViewCount,
+		LastAccessedAt:   image.LastAccessedAt,
+		Path:             image.Path,
+		URL:              ImageURLPrefix() + image.Path,
+	}, nil
+}
+
+// exifHeaderReadLimit 读取 EXIF 时最多读取的文件头字节数 (APP1 段上限为 64KB，留出其他 APPn 段的余量)
+const exifHeaderReadLimit = 256 << 10
+
+// GetImageExif 读取图片文件中的常用 EXIF 拍摄参数，仅允许图片所有者访问
+// 默认不返回 GPS 位置信息，includeGPS 为 true 时才包含；非 JPEG 或已去除元数据的图片返回空 map
+func GetImageExif(userID, imageID uint, includeGPS bool) (map[string]string, error) {
+	var image model.Image
+	if err := db.DB.Where("id = ? AND user_id = ?", imageID, userID).First(&image).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		return nil, err
+	}
+	if utils.ImageTypeForExt(strings.ToLower(image.MimeType)) != "jpg" {
+		return map[string]string{}, nil
+	}
+
+	store, err := ImageStorage()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rc, err := store.Get(ctx, image.Path)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+
+	header, err := io.ReadAll(io.LimitReader(rc, exifHeaderReadLimit))
+	if err != nil {
+		return nil, err
+	}
+	return utils.ExtractExifFields(header, includeGPS), nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"perfect-pic-server/internal/consts"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrServerBusy 图片处理任务排队超时
+var ErrServerBusy = errors.New("服务器繁忙，请稍后重试")
+
+// imageOpsSem 按当前 ConfigMaxConcurrentImageOps 创建的信号量；上限变化时替换为新的信号量，
+// 已占用旧信号量的任务结束时归还给旧信号量，过渡期间同时进行的任务数可能短暂超出新上限
+var (
+	imageOpsMu    sync.Mutex
+	imageOpsLimit int
+	imageOpsSem   *semaphore.Weighted
+)
+
+// acquireImageOp 占用一个图片处理名额 (解码、缩放、重新编码等消耗 CPU 与内存的步骤)
+// 同时进行的任务数受 ConfigMaxConcurrentImageOps 限制，超出时排队，
+// 等待超过 ConfigImageOpQueueTimeoutSeconds 返回 ErrServerBusy，排队期间 ctx 取消时返回 ctx.Err()。
+// 处理结束后需调用返回的 release
+func acquireImageOp(ctx context.Context) (release func(), err error) {
+	sem := currentImageOpsSem()
+	if sem == nil {
+		return func() {}, nil
+	}
+
+	timeout := time.Duration(GetInt(consts.ConfigImageOpQueueTimeoutSeconds)) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := sem.Acquire(waitCtx, 1); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrServerBusy
+	}
+	var once sync.Once
+	return func() { once.Do(func() { sem.Release(1) }) }, nil
+}
+
+// currentImageOpsSem 返回与当前上限对应的信号量，不限制时返回 nil
+func currentImageOpsSem() *semaphore.Weighted {
+	limit := GetInt(consts.ConfigMaxConcurrentImageOps)
+	if limit <= 0 {
+		return nil
+	}
+	imageOpsMu.Lock()
+	defer imageOpsMu.Unlock()
+	if imageOpsSem == nil || imageOpsLimit != limit {
+		imageOpsSem = semaphore.NewWeighted(int64(limit))
+		imageOpsLimit = limit
+	}
+	return imageOpsSem
+}
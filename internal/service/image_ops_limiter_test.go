@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"perfect-pic-server/internal/consts"
+	"testing"
+	"time"
+)
+
+func TestAcquireImageOpQueuesBeyondLimit(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigMaxConcurrentImageOps:      "1",
+		consts.ConfigImageOpQueueTimeoutSeconds: "1",
+	})
+
+	release, err := acquireImageOp(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	if _, err := acquireImageOp(context.Background()); !errors.Is(err, ErrServerBusy) {
+		t.Fatalf("expected ErrServerBusy, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := acquireImageOp(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		next, err := acquireImageOp(context.Background())
+		if err == nil {
+			next()
+		}
+		acquired <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	release()
+	release() // 重复调用无效
+	if err := <-acquired; err != nil {
+		t.Fatalf("queued acquire after release: %v", err)
+	}
+}
+
+func TestAcquireImageOpFollowsLimitChanges(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigMaxConcurrentImageOps:      "1",
+		consts.ConfigImageOpQueueTimeoutSeconds: "1",
+	})
+	release, err := acquireImageOp(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	setTestSettings(t, map[string]string{consts.ConfigMaxConcurrentImageOps: "2"})
+	second, err := acquireImageOp(context.Background())
+	if err != nil {
+		t.Fatalf("raised limit should allow another op: %v", err)
+	}
+	second()
+
+	setTestSettings(t, map[string]string{consts.ConfigMaxConcurrentImageOps: "0"})
+	unlimited, err := acquireImageOp(context.Background())
+	if err != nil {
+		t.Fatalf("unlimited acquire: %v", err)
+	}
+	unlimited()
+}
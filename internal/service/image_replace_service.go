@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"os"
+	"path"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"perfect-pic-server/internal/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrImageTypeMismatch 替换图片时新文件类型与原图不一致
+var ErrImageTypeMismatch = errors.New("新图片的类型必须与原图一致")
+
+// ReplaceImage 用新上传的文件覆盖已有图片，存储路径与访问地址保持不变
+// 新文件类型需与原图一致 (访问地址的扩展名决定了返回的 Content-Type)；
+// 已用空间按新旧文件的大小差值调整，增量超出剩余配额时拒绝；ctx 取消 (如客户端断开) 时放弃排队与写入
+func ReplaceImage(ctx context.Context, userID, imageID uint, fh *multipart.FileHeader) (*model.Image, error) {
+	var image model.Image
+	if err := db.DB.Where("id = ? AND user_id = ?", imageID, userID).First(&image).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		log.Printf("Get image error: %v\n", err)
+		return nil, errors.New("查询图片失败")
+	}
+
+	valid, ext, err := ValidateImageFile(fh)
+	if !valid {
+		return nil, err
+	}
+	storedExt := strings.ToLower(path.Ext(image.Path))
+	if utils.ImageTypeForExt(ext) != utils.ImageTypeForExt(storedExt) {
+		return nil, fmt.Errorf("%w (原图为 %s，新文件为 %s)", ErrImageTypeMismatch, storedExt, ext)
+	}
+
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		log.Printf("Get user error: %v\n", err)
+		return nil, errors.New("查询用户信息失败")
+	}
+	quota := userStorageQuota(&user)
+	// 替换后释放原文件占用的空间，可写入量为剩余配额加上原文件大小
+	available := quota - user.StorageUsed + image.Size
+	maxSize := GetMaxUploadSizeBytes()
+	streamLimit := maxSize
+	if available < streamLimit {
+		streamLimit = available
+	}
+	if fh.Size > streamLimit {
+		if streamLimit == maxSize {
+			return nil, fileTooLargeError(maxSize)
+		}
+		return nil, quotaExceededError(user.StorageUsed, quota)
+	}
+
+	store, err := ImageStorage()
+	if err != nil {
+		log.Printf("Image storage error: %v\n", err)
+		return nil, errors.New("系统错误: 存储后端不可用")
+	}
+	tempDir := os.TempDir()
+	if committer, ok := store.(storage.FileCommitter); ok {
+		if tempDir, err = committer.TempDir(image.Path); err != nil {
+			log.Printf("MkdirAll error: %v\n", err)
+			return nil, errors.New("系统错误: 无法创建存储目录")
+		}
+	}
+
+	src, err := fh.Open()
+	if err != nil {
+		return nil, errors.New("无法读取上传文件")
+	}
+	defer func() { _ = src.Close() }()
+
+	stored, err := streamToTempFile(src, tempDir, streamLimit)
+	if err != nil {
+		if !errors.Is(err, errStreamLimitExceeded) {
+			log.Printf("Save upload error: %v\n", err)
+			return nil, errors.New("文件保存失败")
+		}
+		if streamLimit == maxSize {
+			return nil, fileTooLargeError(maxSize)
+		}
+		return nil, quotaExceededError(user.StorageUsed, quota)
+	}
+
+	releaseOp, err := acquireImageOp(ctx)
+	if err != nil {
+		removeTempFile(stored)
+		return nil, err
+	}
+	prepared, err := prepareTempImage(stored, ext, tempDir, streamLimit)
+	if err != nil {
+		releaseOp()
+		return nil, err
+	}
+	stored = prepared.File
+	alt := generateWebPAlternative(stored, ext, tempDir)
+	releaseOp()
+
+	// 先在事务内锁定图片记录并调整已用空间，再覆盖文件；覆盖失败时事务回滚，记录与原文件保持一致
+	// 存储后端的写入均为原子替换 (本地为临时文件重命名)，不会留下半截文件
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var current model.Image
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", imageID, userID).First(&current).Error; err != nil {
+			return err
+		}
+
+		delta := stored.Size - current.Size
+		if delta != 0 {
+			query := tx.Model(&model.User{}).Where("id = ?", userID)
+			if delta > 0 {
+				query = query.Where("storage_used + ? <= COALESCE(storage_quota, ?)", delta, defaultStorageQuota())
+			}
+			result := query.UpdateColumn("storage_used", gorm.Expr("storage_used + ?", delta))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrStorageQuotaExceeded
+			}
+		}
+
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"size":           stored.Size,
+			"width":          prepared.Width,
+			"height":         prepared.Height,
+			"mime_type":      ext,
+			"hash":           stored.Hash,
+			"p_hash":         prepared.Features.PHash,
+			"dominant_color": prepared.Features.DominantColor,
+			"webp_path":      "",
+			"updated_at":     time.Now().Unix(),
+		}).Error; err != nil {
+			return err
+		}
+
+		return storeTempFile(ctx, store, current.Path, stored)
+	})
+	if err != nil {
+		removeTempFile(stored)
+		removeTempFile(alt)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		if errors.Is(err, ErrStorageQuotaExceeded) {
+			return nil, newLocalizedError(ErrStorageQuotaExceeded, MsgStorageQuotaExceededReplace)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("Replace image error: %v\n", err)
+		return nil, errors.New("替换图片失败")
+	}
+
+	// 旧的 WebP 备选文件已与新内容不一致：事务内先清空记录，提交后删除旧文件，再写入新生成的备选文件
+	if image.WebpPath != "" {
+		deleteStoredImage(store, image.WebpPath)
+	}
+	if webpPath := storeWebPAlternative(ctx, store, image.Path, alt); webpPath != "" {
+		if err := db.DB.Model(&model.Image{}).Where("id = ?", imageID).Update("webp_path", webpPath).Error; err != nil {
+			log.Printf("Update webp path error: %v\n", err)
+			deleteStoredImage(store, webpPath)
+		}
+	}
+
+	if err := db.DB.First(&image, imageID).Error; err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
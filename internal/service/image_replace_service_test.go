@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"perfect-pic-server/internal/consts"
+	"testing"
+	"time"
+)
+
+func TestReplaceImageGivesUpWhenRequestIsCancelled(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigMaxConcurrentImageOps: "1"})
+	user := createTestUser(t, "replacer")
+	img := createStoredImage(t, user.ID, "2026/02/13/a.png")
+
+	release, err := acquireImageOp(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = ReplaceImage(ctx, user.ID, img.ID, pngFileHeader(t, "b.png"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("replace should stop queueing once the request is cancelled")
+	}
+}
+
+func TestReplaceImage(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "replacer")
+	img := createStoredImage(t, user.ID, "2026/02/13/a.png")
+
+	replaced, err := ReplaceImage(context.Background(), user.ID, img.ID, pngFileHeader(t, "b.png"))
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if replaced.Path != img.Path || replaced.Hash == "" || replaced.Width != 16 {
+		t.Fatalf("unexpected replaced image: %+v", replaced)
+	}
+}
+
+func TestUpdateUserAvatarGivesUpWhenRequestIsCancelled(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigMaxConcurrentImageOps: "1"})
+	user := createTestUser(t, "avatar")
+
+	release, err := acquireImageOp(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := UpdateUserAvatar(ctx, &user, pngFileHeader(t, "avatar.png")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
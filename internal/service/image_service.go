@@ -1,8 +1,11 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"image/png"
 	"io"
 	"log"
 	"mime/multipart"
@@ -12,42 +15,56 @@ import (
 	"perfect-pic-server/internal/consts"
 	"perfect-pic-server/internal/db"
 	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
 	"perfect-pic-server/internal/utils"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// uploadSource 待保存的上传文件，可以是表单文件或分片上传合并后的临时文件
+type uploadSource struct {
+	Filename string
+	Size     int64
+	Open     func() (multipart.File, error)
+	// UploaderIP 访客上传时的客户端 IP (用于按 IP 限额)，登录用户上传时为空
+	UploaderIP string
+	// UploaderNetwork 访客上传时按 IP 限额所用的地址，登录用户上传时为空
+	UploaderNetwork string
+	// ExpiresAt 图片的过期时间 (Unix 秒)，nil 表示永久保留
+	ExpiresAt *int64
+}
+
+// formUploadSource 将表单文件包装为 uploadSource
+func formUploadSource(file *multipart.FileHeader) uploadSource {
+	return uploadSource{Filename: file.Filename, Size: file.Size, Open: file.Open}
+}
+
 // ValidateImageFile 验证上传的图片文件（大小、后缀、内容）
 // 返回:
 //   - bool: 是否合法
-//   - string: 文件扩展名 (小写, 如 .jpg)
+//   - string: 文件扩展名 (小写, 如 .jpg)；内容与扩展名不一致时按 ConfigExtensionMismatchPolicy 确定
 //   - error: 错误信息或原因
 func ValidateImageFile(file *multipart.FileHeader) (bool, string, error) {
-	// 检查文件大小
-	maxSizeMB := GetInt(consts.ConfigMaxUploadSize) // 默认 10MB
-	if file.Size > int64(maxSizeMB*1024*1024) {
-		return false, "", fmt.Errorf("文件大小不能超过 %dMB", maxSizeMB)
-	}
+	return validateUploadSource(formUploadSource(file))
+}
 
-	// 检查文件扩展名
-	allowExtsStr := GetString(consts.ConfigAllowFileExtensions)
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if ext == "" {
-		return false, "", errors.New("无法识别文件类型")
+func validateUploadSource(file uploadSource) (bool, string, error) {
+	// 检查文件大小 (优先使用 multipart 头中声明的大小，实际读取时仍会再次限制)
+	maxSize := GetMaxUploadSizeBytes()
+	if file.Size > maxSize {
+		return false, "", fileTooLargeError(maxSize)
 	}
 
-	allowed := false
-	for _, allowExt := range strings.Split(allowExtsStr, ",") {
-		if strings.TrimSpace(strings.ToLower(allowExt)) == ext {
-			allowed = true
-			break
-		}
-	}
-	if !allowed {
-		return false, ext, fmt.Errorf("不支持的文件类型: %s", ext)
+	// 检查文件扩展名
+	allowedTypes := GetAllowedImageTypes()
+	ext, err := validateImageExt(file.Filename, allowedTypes)
+	if err != nil {
+		return false, ext, err
 	}
 
 	// 检查文件内容 (Magic Bytes)
@@ -57,68 +74,284 @@ func ValidateImageFile(file *multipart.FileHeader) (bool, string, error) {
 	}
 	defer func() { _ = src.Close() }()
 
-	if valid, msg := utils.ValidateImageContent(src, ext); !valid {
+	// SVG 为文本格式，需解析整个文档确认根元素为 <svg> 且能被清理
+	if utils.ImageTypeForExt(ext) == "svg" {
+		data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+		if err != nil {
+			return false, ext, errors.New("读取文件内容失败")
+		}
+		if int64(len(data)) > maxSize {
+			return false, ext, fileTooLargeError(maxSize)
+		}
+		if _, err := utils.SanitizeSVG(data); err != nil {
+			return false, ext, err
+		}
+		return true, ext, nil
+	}
+
+	detected, contentType, err := utils.DetectImageType(src)
+	if err != nil {
+		return false, ext, err
+	}
+	mismatchErr := fmt.Errorf("文件真实类型(%s)与扩展名(%s)不匹配或不支持，仅允许: %s", contentType, ext, strings.Join(allowedTypes, ", "))
+	if !isAllowedImageType(detected, allowedTypes) {
+		return false, ext, mismatchErr
+	}
+	// contentExt 为真实类型对应的扩展名，用于按内容检查尺寸
+	contentExt := ext
+	if detected != utils.ImageTypeForExt(ext) {
+		switch GetString(consts.ConfigExtensionMismatchPolicy) {
+		case ExtensionMismatchTrustContent:
+			ext = utils.ImageExtForType(detected)
+			contentExt = ext
+		case ExtensionMismatchTrustExtension:
+			contentExt = utils.ImageExtForType(detected)
+		default:
+			return false, ext, mismatchErr
+		}
+	}
+
+	// 检查像素面积与 GIF 帧数 (仅读取文件头，防止解压炸弹)
+	maxPixels := GetInt64(consts.ConfigMaxPixels)
+	maxGifFrames := GetInt(consts.ConfigMaxGifFrames)
+	if valid, msg := utils.ValidateImageDimensions(src, contentExt, maxPixels, maxGifFrames); !valid {
 		return false, ext, errors.New(msg)
 	}
+	if err := checkImageBounds(src, utils.ImageTypeForExt(contentExt)); err != nil {
+		return false, ext, err
+	}
 
 	return true, ext, nil
 }
 
+// 宽高超出 ConfigMaxImageWidth/ConfigMaxImageHeight 时的处理策略 (ConfigOversizePolicy)
+const (
+	OversizeReject    = "reject"    // 拒绝上传
+	OversizeDownscale = "downscale" // 等比缩小后保存
+)
+
+// maxImageBounds 返回图片允许的最大宽度与高度 (0 表示不限制)
+func maxImageBounds() (int, int) {
+	return max(GetInt(consts.ConfigMaxImageWidth), 0), max(GetInt(consts.ConfigMaxImageHeight), 0)
+}
+
+// checkImageBounds 仅读取文件头检查宽高是否超出限制。
+// 超出时按 reject 策略拒绝；downscale 策略留给 prepareTempImage 缩小，但 GIF 不支持缩小，仍然拒绝
+func checkImageBounds(src io.ReadSeeker, imageType string) error {
+	maxWidth, maxHeight := maxImageBounds()
+	if maxWidth == 0 && maxHeight == 0 {
+		return nil
+	}
+	width, height, err := utils.ImageSize(src)
+	if err != nil {
+		return errors.New("无法解析图片尺寸信息")
+	}
+	if _, _, oversize := utils.FitWithin(width, height, maxWidth, maxHeight); !oversize {
+		return nil
+	}
+	if GetString(consts.ConfigOversizePolicy) == OversizeDownscale && imageType != "gif" {
+		return nil
+	}
+
+	var limits []string
+	if maxWidth > 0 {
+		limits = append(limits, fmt.Sprintf("宽度不超过 %d 像素", maxWidth))
+	}
+	if maxHeight > 0 {
+		limits = append(limits, fmt.Sprintf("高度不超过 %d 像素", maxHeight))
+	}
+	return fmt.Errorf("图片尺寸过大 (%dx%d)，要求%s", width, height, strings.Join(limits, "、"))
+}
+
+// 文件内容与扩展名不一致时的处理策略 (ConfigExtensionMismatchPolicy)
+const (
+	ExtensionMismatchReject         = "reject"          // 拒绝上传
+	ExtensionMismatchTrustContent   = "trust-content"   // 按真实类型的扩展名保存
+	ExtensionMismatchTrustExtension = "trust-extension" // 保留声明的扩展名
+)
+
+// isAllowedImageType 判断图片类型 (规范名称) 是否在允许列表中
+func isAllowedImageType(name string, allowedTypes []string) bool {
+	if name == "" {
+		return false
+	}
+	for _, t := range allowedTypes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImageExt 根据文件名检查扩展名是否属于允许的图片类型，返回小写扩展名
+func validateImageExt(filename string, allowedTypes []string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return "", errors.New("无法识别文件类型")
+	}
+
+	if isAllowedImageType(utils.ImageTypeForExt(ext), allowedTypes) {
+		return ext, nil
+	}
+	return ext, fmt.Errorf("不支持的文件类型: %s，仅允许: %s", ext, strings.Join(allowedTypes, ", "))
+}
+
+var (
+	// ErrFileTooLarge 单个文件超过上传大小限制
+	ErrFileTooLarge = errors.New("文件过大")
+	// ErrStorageQuotaExceeded 用户存储配额不足
+	ErrStorageQuotaExceeded = errors.New("存储空间不足")
+)
+
+// GetMaxUploadSizeBytes 获取单个文件的最大上传大小 (Bytes)
+// ConfigMaxUploadSizeBytes 大于 0 时优先生效，否则使用 ConfigMaxUploadSize (MB)，默认 10MB
+func GetMaxUploadSizeBytes() int64 {
+	if maxBytes := GetInt64(consts.ConfigMaxUploadSizeBytes); maxBytes > 0 {
+		return maxBytes
+	}
+	maxSizeMB := GetInt64(consts.ConfigMaxUploadSize)
+	if maxSizeMB <= 0 {
+		maxSizeMB = 10
+	}
+	return maxSizeMB * 1024 * 1024
+}
+
+// FormatUploadSizeLimit 将上传大小限制格式化为便于阅读的文本
+func FormatUploadSizeLimit(maxSize int64) string {
+	if maxSize%(1024*1024) == 0 {
+		return fmt.Sprintf("%dMB", maxSize/(1024*1024))
+	}
+	return fmt.Sprintf("%d B", maxSize)
+}
+
+func fileTooLargeError(maxSize int64) error {
+	return newLocalizedError(ErrFileTooLarge, MsgFileTooLarge, FormatUploadSizeLimit(maxSize))
+}
+
+// defaultImageTypes 未配置或配置全部无效时使用的默认图片类型
+var defaultImageTypes = []string{"jpg", "png", "gif", "webp"}
+
+// GetAllowedImageTypes 获取允许上传的图片类型 (规范名称，如 jpg/png)
+// 优先读取 ConfigAllowedImageTypes，留空时沿用 ConfigAllowFileExtensions，配置中的未知类型会被忽略
+func GetAllowedImageTypes() []string {
+	types := utils.ParseImageTypes(GetString(consts.ConfigAllowedImageTypes))
+	if len(types) == 0 {
+		types = utils.ParseImageTypes(GetString(consts.ConfigAllowFileExtensions))
+	}
+	if len(types) == 0 {
+		types = defaultImageTypes
+	}
+	// SVG 只由 ConfigAllowSVG 控制，不受类型列表影响
+	allowed := make([]string, 0, len(types)+1)
+	for _, t := range types {
+		if t != "svg" {
+			allowed = append(allowed, t)
+		}
+	}
+	if GetBool(consts.ConfigAllowSVG) {
+		allowed = append(allowed, "svg")
+	}
+	return allowed
+}
+
 // ProcessImageUpload 处理图片上传核心业务
-// 包括：配额检查、文件保存、数据库记录
-func ProcessImageUpload(file *multipart.FileHeader, uid uint) (*model.Image, string, error) {
+// 包括：配额检查、文件保存、数据库记录；expiresAt 为过期时间 (Unix 秒)，nil 表示永久保留。
+// ctx 取消 (如客户端断开) 时在写入记录前中止，清理临时文件与已写入的文件，不计入已用空间
+func ProcessImageUpload(ctx context.Context, file *multipart.FileHeader, uid uint, expiresAt *int64) (*model.Image, string, error) {
+	source := formUploadSource(file)
+	source.ExpiresAt = expiresAt
+	return processUploadSource(ctx, source, uid)
+}
+
+func processUploadSource(ctx context.Context, file uploadSource, uid uint) (*model.Image, string, error) {
 	// 1. 验证文件
-	valid, ext, err := ValidateImageFile(file)
+	valid, ext, err := validateUploadSource(file)
 	if !valid {
 		return nil, "", err
 	}
 
 	// 2. 检查配额 (使用 StorageUsed 字段)
 	var user model.User
-	if err := db.DB.First(&user, uid).Error; err != nil {
+	if err := db.DB.WithContext(ctx).First(&user, uid).Error; err != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
 		log.Printf("Get user error: %v\n", err)
 		return nil, "", errors.New("查询用户信息失败")
 	}
 
 	// 如果 StorageUsed 为 0 但不是新用户，可能需要同步（可选，这里假设已同步）
 	usedSize := user.StorageUsed
+	quota := userStorageQuota(&user)
+
+	if usedSize+file.Size > quota {
+		return nil, "", quotaExceededError(usedSize, quota)
+	}
+
+	// 3. 预占当日上传额度，失败时归还
+	reservation, err := reserveDailyUpload(&user, file.Size)
+	if err != nil {
+		return nil, "", err
+	}
+	image, url, err := saveImageUpload(ctx, file, uid, ext, usedSize, quota)
+	if err != nil {
+		reservation.settle(0, false)
+		return nil, "", err
+	}
+	reservation.settle(image.Size, true)
+	return image, url, nil
+}
 
-	var quota int64
+// userStorageQuota 返回用户的存储配额，未单独设置时使用默认配额
+func userStorageQuota(user *model.User) int64 {
 	if user.StorageQuota != nil {
-		quota = *user.StorageQuota
-	} else {
-		quota = GetInt64(consts.ConfigDefaultStorageQuota)
-		if quota == 0 {
-			quota = 1073741824 // 1GB
-		}
+		return *user.StorageQuota
 	}
+	return defaultStorageQuota()
+}
 
-	if usedSize+file.Size > quota {
-		return nil, "", fmt.Errorf("存储空间不足，上传失败。当前已用: %d B, 剩余: %d B", usedSize, quota-usedSize)
+// defaultStorageQuota 返回未单独设置配额的用户所使用的默认配额
+func defaultStorageQuota() int64 {
+	quota := GetInt64(consts.ConfigDefaultStorageQuota)
+	if quota == 0 {
+		quota = 1073741824 // 1GB
 	}
+	return quota
+}
 
-	// 3. 准备路径
+func quotaExceededError(usedSize, quota int64) error {
+	return newLocalizedError(ErrStorageQuotaExceeded, MsgStorageQuotaExceeded, usedSize, quota-usedSize)
+}
+
+// saveImageUpload 保存已通过校验的图片并写入数据库记录，同时累加用户已用空间
+// usedSize 为计入本文件前的已用空间，写入量超过剩余配额时中止；
+// ctx 取消时在各处理阶段之间中止并返回 ctx.Err()，已写入的临时文件与存储中的文件均被清理
+func saveImageUpload(ctx context.Context, file uploadSource, uid uint, ext string, usedSize, quota int64) (*model.Image, string, error) {
+	// 3. 准备路径 (date 布局按日期分目录，如 2026/02/13/xxx.png；hash 布局需等写入完成得到内容哈希后再确定)
 	now := time.Now()
-	datePath := filepath.Join(now.Format("2006"), now.Format("01"), now.Format("02"))
+	layout := imageStorageLayout()
+	newFilename, relativePath := datedImageKey(now, ext)
 
-	cfg := config.Get()
-	uploadRoot := cfg.Upload.Path
-	if uploadRoot == "" {
-		uploadRoot = "uploads/imgs"
+	store, err := ImageStorage()
+	if err != nil {
+		log.Printf("Image storage error: %v\n", err)
+		return nil, "", errors.New("系统错误: 存储后端不可用")
 	}
-	// 完整的磁盘文件夹路径
-	fullDir := filepath.Join(uploadRoot, datePath)
 
-	// 自动创建文件夹
-	if err := os.MkdirAll(fullDir, 0755); err != nil {
-		log.Printf("MkdirAll error: %v\n", err)
-		return nil, "", errors.New("系统错误: 无法创建存储目录")
+	// 本地存储直接在目标目录写临时文件以便原子重命名 (hash 布局写入存储根目录，提交时再移入分片目录)，
+	// 其他存储先写入系统临时目录再上传
+	tempDir := os.TempDir()
+	if committer, ok := store.(storage.FileCommitter); ok {
+		tempKey := relativePath
+		if layout == StorageLayoutHash {
+			tempKey = newFilename
+		}
+		if tempDir, err = committer.TempDir(tempKey); err != nil {
+			log.Printf("MkdirAll error: %v\n", err)
+			return nil, "", errors.New("系统错误: 无法创建存储目录")
+		}
 	}
 
-	// 生成唯一文件名
-	newFilename := uuid.New().String() + ext
-	dst := filepath.Join(fullDir, newFilename)
-
 	// 保存文件 (IO 操作放在事务前，如果 DB 失败则删除文件)
 	src, err := file.Open()
 	if err != nil {
@@ -126,64 +359,220 @@ func ProcessImageUpload(file *multipart.FileHeader, uid uint) (*model.Image, str
 	}
 	defer func() { _ = src.Close() }()
 
-	out, err := os.Create(dst)
+	// 流式写入临时文件，同时计算大小与内容哈希 (供完整性巡检使用)
+	// 写入上限取单文件限制与剩余配额中的较小者，超出即中止，不依赖 multipart 头中声明的大小
+	maxSize := GetMaxUploadSizeBytes()
+	streamLimit := maxSize
+	if remaining := quota - usedSize; remaining < streamLimit {
+		streamLimit = remaining
+	}
+	stored, err := streamToTempFile(contextReader{ctx: ctx, r: src}, tempDir, streamLimit)
 	if err != nil {
-		return nil, "", errors.New("系统错误: 无法创建文件")
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		if !errors.Is(err, errStreamLimitExceeded) {
+			log.Printf("Save upload error: %v\n", err)
+			return nil, "", errors.New("文件保存失败")
+		}
+		if streamLimit == maxSize {
+			return nil, "", fileTooLargeError(maxSize)
+		}
+		return nil, "", quotaExceededError(usedSize, quota)
 	}
-	defer func() { _ = out.Close() }()
 
-	if _, err = io.Copy(out, src); err != nil {
+	// 摆正方向、压缩并提取宽高等信息 (占用图片处理名额，并发处理过多时排队)
+	releaseOp, err := acquireImageOp(ctx)
+	if err != nil {
+		removeTempFile(stored)
+		return nil, "", err
+	}
+	prepared, err := prepareTempImage(stored, ext, tempDir, streamLimit)
+	if err != nil {
+		releaseOp()
+		return nil, "", err
+	}
+	stored = prepared.File
+	// 需在原文件提交 (本地存储会重命名临时文件) 前生成备选格式
+	alt := generateWebPAlternative(stored, ext, tempDir)
+	releaseOp()
+	if ctx.Err() != nil {
+		removeTempFile(stored)
+		removeTempFile(alt)
+		return nil, "", ctx.Err()
+	}
+
+	if layout == StorageLayoutHash {
+		newFilename, relativePath = hashedImageKey(stored.Hash, ext)
+	}
+
+	if err := storeTempFile(ctx, store, relativePath, stored); err != nil {
+		removeTempFile(alt)
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		log.Printf("Store upload error: %v\n", err)
 		return nil, "", errors.New("文件保存失败")
 	}
+	webpPath := storeWebPAlternative(ctx, store, relativePath, alt)
 
 	// 4. 数据库操作 (事务)
-	relativePath := filepath.ToSlash(filepath.Join(
-		now.Format("2006"), now.Format("01"), now.Format("02"), newFilename))
-
+	written := stored.Size
 	imageRecord := model.Image{
-		Filename:   newFilename,
-		Path:       relativePath,
-		Size:       file.Size,
-		UserID:     uid,
-		UploadedAt: now.Unix(),
-		MimeType:   ext,
+		Filename:         newFilename,
+		Path:             relativePath,
+		OriginalFilename: utils.SanitizeFilename(file.Filename),
+		Size:             written,
+		Width:            prepared.Width,
+		Height:           prepared.Height,
+		UserID:           uid,
+		UploadedAt:       now.Unix(),
+		UpdatedAt:        now.Unix(),
+		MimeType:         ext,
+		Hash:             stored.Hash,
+		PHash:            prepared.Features.PHash,
+		DominantColor:    prepared.Features.DominantColor,
+		WebpPath:         webpPath,
+		UploaderIP:       file.UploaderIP,
+		UploaderNetwork:  file.UploaderNetwork,
+		ExpiresAt:        file.ExpiresAt,
+	}
+	deleteSecret, err := issueImageDeleteToken(&imageRecord)
+	if err != nil {
+		log.Printf("Generate delete token error: %v\n", err)
 	}
 
-	err = db.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&imageRecord).Error; err != nil {
-			return err
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 在事务内以原子表达式增加已用空间，并重新校验配额 (以数据库中的最新值为准)
+		// 并发上传时只有未超出配额的请求能更新成功，避免多个请求同时通过事务外的配额检查
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND storage_used + ? <= COALESCE(storage_quota, ?)", uid, written, defaultStorageQuota()).
+			UpdateColumn("storage_used", gorm.Expr("storage_used + ?", written))
+		if result.Error != nil {
+			return result.Error
 		}
-		// 增加已用空间
-		if err := tx.Model(&model.User{}).Where("id = ?", uid).
-			UpdateColumn("storage_used", gorm.Expr("storage_used + ?", file.Size)).Error; err != nil {
-			return err
+		if result.RowsAffected == 0 {
+			return ErrStorageQuotaExceeded
 		}
-		return nil
+		return tx.Create(&imageRecord).Error
 	})
 
 	if err != nil {
-		_ = os.Remove(dst) // 回滚文件
+		deleteStoredImage(store, relativePath) // 回滚文件
+		if webpPath != "" {
+			deleteStoredImage(store, webpPath)
+		}
+		if errors.Is(err, ErrStorageQuotaExceeded) {
+			return nil, "", newLocalizedError(ErrStorageQuotaExceeded, MsgStorageQuotaExceededUpload)
+		}
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
 		log.Printf("Process upload DB error: %v\n", err)
 		return nil, "", errors.New("系统错误: 数据库记录失败")
 	}
 
-	return &imageRecord, cfg.Upload.URLPrefix + relativePath, nil
+	if deleteSecret != "" {
+		imageRecord.DeleteToken = formatImageDeleteToken(imageRecord.ID, deleteSecret)
+	}
+	return &imageRecord, imageURL(store, relativePath), nil
 }
 
-// DeleteImage 删除图片文件和数据库记录
-func DeleteImage(image *model.Image) error {
-	cfg := config.Get()
-	uploadRoot := cfg.Upload.Path
-	if uploadRoot == "" {
-		uploadRoot = "uploads/imgs"
+// preparedImage 完成旋转、压缩与信息提取后的上传临时文件
+type preparedImage struct {
+	File     *streamedFile
+	Width    int
+	Height   int
+	Features imageFeatures
+}
+
+// prepareTempImage 对已写入临时文件的上传图片按配置摆正方向与压缩，并读取宽高、感知哈希与主色调
+// 需在临时文件提交 (本地存储会重命名) 前调用；解析宽高失败时删除临时文件并返回错误
+func prepareTempImage(stored *streamedFile, ext, tempDir string, limit int64) (*preparedImage, error) {
+	if utils.ImageTypeForExt(ext) == "svg" {
+		return prepareTempSVG(stored, tempDir, limit)
+	}
+
+	// 按 EXIF 方向摆正 JPEG 像素 (保留其余 EXIF)，部分浏览器会忽略 EXIF 方向
+	if GetBool(consts.ConfigAutoOrient) && utils.ImageTypeForExt(ext) == "jpg" {
+		stored = autoOrientStoredJPEG(stored, tempDir, limit)
+	}
+
+	// 宽高超出限制时等比缩小 (reject 策略已在校验阶段拒绝)
+	if GetString(consts.ConfigOversizePolicy) == OversizeDownscale {
+		var err error
+		if stored, err = downscaleStoredImage(stored, tempDir, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	// 按配置压缩图片，压缩后不小于原图时保留原图
+	if GetBool(consts.ConfigOptimizeOnUpload) {
+		stored = optimizeStoredImage(stored, utils.ImageTypeForExt(ext), tempDir)
 	}
 
-	// 拼接完整物理路径
-	fullPath := filepath.Join(uploadRoot, image.Path)
+	// 仅解析文件头获取宽高
+	width, height, err := decodeImageSize(stored.TempPath)
+	if err != nil {
+		removeTempFile(stored)
+		return nil, errors.New("无法解析图片尺寸信息")
+	}
+
+	// 感知哈希与主色调计算失败不影响上传
+	return &preparedImage{
+		File:     stored,
+		Width:    width,
+		Height:   height,
+		Features: computeImageFeatures(stored.TempPath),
+	}, nil
+}
+
+// prepareTempSVG 将已写入临时文件的 SVG 替换为清理后的版本，宽高取自根元素的 width/height 或 viewBox
+func prepareTempSVG(stored *streamedFile, tempDir string, limit int64) (*preparedImage, error) {
+	data, err := os.ReadFile(stored.TempPath)
+	removeTempFile(stored)
+	if err != nil {
+		log.Printf("Read svg error: %v\n", err)
+		return nil, errors.New("文件保存失败")
+	}
+	clean, err := utils.SanitizeSVG(data)
+	if err != nil {
+		return nil, err
+	}
+	sanitized, err := streamToTempFile(bytes.NewReader(clean), tempDir, limit)
+	if err != nil {
+		if errors.Is(err, errStreamLimitExceeded) {
+			return nil, errors.New("SVG 清理后的文件大小超出限制")
+		}
+		log.Printf("Save sanitized svg error: %v\n", err)
+		return nil, errors.New("文件保存失败")
+	}
+	width, height := utils.SVGSize(clean)
+	return &preparedImage{File: sanitized, Width: width, Height: height}, nil
+}
+
+// DeleteImage 删除图片文件和数据库记录
+// ctx 在事务提交前取消时不做任何修改；提交后存储中的文件总会被删除，不随 ctx 中止
+func DeleteImage(ctx context.Context, image *model.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	store, err := ImageStorage()
+	if err != nil {
+		return err
+	}
 
 	// 使用事务确保数据库操作原子性
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Delete(image).Error; err != nil {
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(image)
+		if result.Error != nil {
+			return result.Error
+		}
+		// 记录已被并发请求删除时不再重复释放空间
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := deleteImageRelations(tx, []uint{image.ID}); err != nil {
 			return err
 		}
 		// 减少用户已用存储空间
@@ -198,44 +587,68 @@ func DeleteImage(image *model.Image) error {
 		return err
 	}
 
-	// 事务提交后，删除物理文件
-	if err := os.Remove(fullPath); err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Delete file error: %v, path: %s\n", err, fullPath)
-		}
+	// 事务提交后，删除存储中的文件 (包括 WebP 备选文件)
+	deleteStoredImage(store, image.Path)
+	if image.WebpPath != "" {
+		deleteStoredImage(store, image.WebpPath)
 	}
 
 	return nil
 }
 
 // BatchDeleteImages 批量删除图片
-func BatchDeleteImages(images []model.Image) error {
+// 与 DeleteImage 相同，ctx 只影响事务提交前的阶段，提交后的文件清理不随 ctx 中止
+func BatchDeleteImages(ctx context.Context, images []model.Image) error {
 	if len(images) == 0 {
 		return nil
 	}
-
-	// 使用 map 按用户分组统计待释放的空间
-	// key: UserID, value: TotalSizeToFree
-	userSizeMap := make(map[uint]int64)
-	var imageIDs []uint
-	var pathsToDelete []string
-
-	cfg := config.Get()
-	uploadRoot := cfg.Upload.Path
-	if uploadRoot == "" {
-		uploadRoot = "uploads/imgs"
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
+	imageIDs := make([]uint, 0, len(images))
 	for _, img := range images {
-		userSizeMap[img.UserID] += img.Size
 		imageIDs = append(imageIDs, img.ID)
-		pathsToDelete = append(pathsToDelete, filepath.Join(uploadRoot, img.Path))
 	}
 
+	store, err := ImageStorage()
+	if err != nil {
+		return err
+	}
+
+	var keysToDelete []string
+
 	// 开启单一事务处理所有数据库变更
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 重新读取仍存在的记录 (可能已被并发请求删除)，只对实际删除的记录释放空间与删除文件
+		var existing []model.Image
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", imageIDs).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return nil
+		}
+
+		// 使用 map 按用户分组统计待释放的空间
+		// key: UserID, value: TotalSizeToFree
+		userSizeMap := make(map[uint]int64)
+		existingIDs := make([]uint, 0, len(existing))
+		keys := make([]string, 0, len(existing))
+		for _, img := range existing {
+			userSizeMap[img.UserID] += img.Size
+			existingIDs = append(existingIDs, img.ID)
+			keys = append(keys, img.Path)
+			if img.WebpPath != "" {
+				keys = append(keys, img.WebpPath)
+			}
+		}
+
 		// 批量删除图片记录
-		if err := tx.Where("id IN ?", imageIDs).Delete(&model.Image{}).Error; err != nil {
+		if err := tx.Where("id IN ?", existingIDs).Delete(&model.Image{}).Error; err != nil {
+			return err
+		}
+		if err := deleteImageRelations(tx, existingIDs); err != nil {
 			return err
 		}
 
@@ -247,6 +660,7 @@ func BatchDeleteImages(images []model.Image) error {
 				return err
 			}
 		}
+		keysToDelete = keys
 		return nil
 	})
 
@@ -254,27 +668,48 @@ func BatchDeleteImages(images []model.Image) error {
 		return err
 	}
 
-	// 事务成功提交后，清理物理文件
-	for _, path := range pathsToDelete {
-		if err := os.Remove(path); err != nil {
-			if !os.IsNotExist(err) {
-				log.Printf("Batch delete file error: %v, path: %s\n", err, path)
-			}
-		}
-	}
+	// 事务成功提交后，并发清理存储中的文件 (单个文件删除失败只记录日志，不影响整体结果)
+	deleteStoredImagesConcurrently(store, keysToDelete)
 
 	return nil
 }
 
-// UpdateUserAvatar 更新用户头像
-func UpdateUserAvatar(user *model.User, file *multipart.FileHeader) (string, error) {
+// fileDeleteWorkers 批量删除物理文件时的并发数
+const fileDeleteWorkers = 8
+
+// deleteStoredImagesConcurrently 使用有限数量的协程删除存储中的文件，文件不存在视为成功
+func deleteStoredImagesConcurrently(store storage.Storage, keys []string) {
+	workers := fileDeleteWorkers
+	if len(keys) < workers {
+		workers = len(keys)
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				deleteStoredImage(store, key)
+			}
+		}()
+	}
+	for _, key := range keys {
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// UpdateUserAvatar 更新用户头像，ctx 取消 (如客户端断开) 时放弃排队
+func UpdateUserAvatar(ctx context.Context, user *model.User, file *multipart.FileHeader) (string, error) {
 	cfg := config.Get()
 	avatarRoot := cfg.Upload.AvatarPath
 	if avatarRoot == "" {
 		avatarRoot = "uploads/avatars"
 	}
 
-	ext := strings.ToLower(filepath.Ext(file.Filename))
 	// userIdStr for path
 	userIdStr := fmt.Sprintf("%v", user.ID)
 	storageDir := filepath.Join(avatarRoot, userIdStr)
@@ -285,10 +720,6 @@ func UpdateUserAvatar(user *model.User, file *multipart.FileHeader) (string, err
 		return "", errors.New("系统错误: 无法创建存储目录")
 	}
 
-	// 生成唯一文件名
-	newFilename := uuid.New().String() + ext
-	dstPath := filepath.Join(storageDir, newFilename)
-
 	// 打开源文件
 	src, err := file.Open()
 	if err != nil {
@@ -297,32 +728,64 @@ func UpdateUserAvatar(user *model.User, file *multipart.FileHeader) (string, err
 	}
 	defer func() { _ = src.Close() }()
 
-	// 创建目标文件
-	out, err := os.Create(dstPath)
+	// 先流式写入临时文件 (同样限制实际读取的字节数)，再解码处理
+	maxSize := GetMaxUploadSizeBytes()
+	uploaded, err := streamToTempFile(src, storageDir, maxSize)
 	if err != nil {
-		log.Printf("File create error: %v\n", err)
-		return "", errors.New("系统错误: 无法创建文件")
+		if errors.Is(err, errStreamLimitExceeded) {
+			return "", fileTooLargeError(maxSize)
+		}
+		log.Printf("File save error: %v\n", err)
+		return "", errors.New("文件保存失败")
+	}
+	releaseOp, err := acquireImageOp(ctx)
+	if err != nil {
+		removeTempFile(uploaded)
+		return "", err
+	}
+	img, err := decodeImageFile(uploaded.TempPath)
+	removeTempFile(uploaded)
+	if err != nil {
+		releaseOp()
+		log.Printf("Avatar decode error: %v\n", err)
+		return "", errors.New("无法解析图片内容")
+	}
+
+	// 裁剪缩放后统一重新编码为 PNG，存储处理后的版本
+	processed := utils.ResizeAvatar(img, GetInt(consts.ConfigAvatarMaxSize), GetBool(consts.ConfigAvatarCropSquare))
+	var buf bytes.Buffer
+	err = png.Encode(&buf, processed)
+	releaseOp()
+	if err != nil {
+		log.Printf("Avatar encode error: %v\n", err)
+		return "", errors.New("头像处理失败")
 	}
-	defer func() { _ = out.Close() }()
 
-	// 复制内容
-	if _, err = io.Copy(out, src); err != nil {
+	// 生成唯一文件名
+	newFilename := uuid.New().String() + ".png"
+	dstPath := filepath.Join(storageDir, newFilename)
+
+	stored, err := streamToTempFile(&buf, storageDir, int64(buf.Len()))
+	if err != nil {
 		log.Printf("File save error: %v\n", err)
 		return "", errors.New("文件保存失败")
 	}
+	if err := commitTempFile(stored.TempPath, dstPath); err != nil {
+		return "", errors.New("系统错误: 无法创建文件")
+	}
 
-	// 保存旧头像文件名用于后续删除
-	oldAvatar := user.Avatar
-
-	// 更新数据库
-	if err := db.DB.Model(user).Update("avatar", newFilename).Error; err != nil {
+	// 在事务中读取并替换当前头像，只删除本次被替换掉的旧文件
+	// 避免并发更新时误删其他请求刚写入的新头像
+	oldAvatar, err := swapUserAvatar(user.ID, newFilename)
+	if err != nil {
 		_ = os.Remove(dstPath) // 回滚文件
 		log.Printf("DB Update avatar error: %v\n", err)
 		return "", errors.New("系统错误: 数据库更新失败")
 	}
+	user.Avatar = newFilename
 
 	// 删除旧头像
-	if oldAvatar != "" {
+	if oldAvatar != "" && oldAvatar != newFilename {
 		_ = os.Remove(filepath.Join(storageDir, oldAvatar))
 	}
 
@@ -342,19 +805,166 @@ func RemoveUserAvatar(user *model.User) error {
 		avatarRoot = "uploads/avatars"
 	}
 
-	userIdStr := fmt.Sprintf("%v", user.ID)
-	oldAvatarPath := filepath.Join(avatarRoot, userIdStr, user.Avatar)
-
-	// 更新数据库
-	if err := db.DB.Model(user).Select("Avatar").Updates(map[string]interface{}{"avatar": ""}).Error; err != nil {
+	// 更新数据库 (以事务内读到的当前值为准)
+	oldAvatar, err := swapUserAvatar(user.ID, "")
+	if err != nil {
 		log.Printf("DB Remove avatar error: %v\n", err)
 		return errors.New("系统错误: 移除头像失败")
 	}
+	user.Avatar = ""
+	if oldAvatar == "" {
+		return nil
+	}
 
 	// 删除文件
+	userIdStr := fmt.Sprintf("%v", user.ID)
+	oldAvatarPath := filepath.Join(avatarRoot, userIdStr, oldAvatar)
 	if err := os.Remove(oldAvatarPath); err != nil && !os.IsNotExist(err) {
 		log.Printf("Remove avatar file error: %v\n", err)
 	}
 
 	return nil
 }
+
+// swapUserAvatar 在事务中读取用户当前头像并替换为 newAvatar，返回被替换的旧文件名
+// 更新条件附带旧值 (比较并交换)，在不支持行锁的数据库上同样能检测并发修改
+func swapUserAvatar(userID uint, newAvatar string) (string, error) {
+	var oldAvatar string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for attempt := 0; attempt < 3; attempt++ {
+			var current model.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("id", "avatar").First(&current, userID).Error; err != nil {
+				return err
+			}
+			if current.Avatar == newAvatar {
+				return nil
+			}
+
+			result := tx.Model(&model.User{}).
+				Where("id = ? AND avatar = ?", userID, current.Avatar).
+				Update("avatar", newAvatar)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				oldAvatar = current.Avatar
+				return nil
+			}
+		}
+		return errors.New("avatar update conflict")
+	})
+	return oldAvatar, err
+}
+
+// autoOrientJPEGQuality 自动旋转后重新编码 JPEG 的质量
+const autoOrientJPEGQuality = 92
+
+// autoOrientStoredJPEG 按 EXIF Orientation 旋转已写入临时文件的 JPEG，返回新的临时文件
+// 无需旋转或处理失败 (如旋转后超出写入上限) 时保留原文件
+func autoOrientStoredJPEG(stored *streamedFile, dir string, limit int64) *streamedFile {
+	data, err := os.ReadFile(stored.TempPath)
+	if err != nil {
+		log.Printf("Auto orient read error: %v\n", err)
+		return stored
+	}
+
+	oriented, changed, err := utils.AutoOrientJPEG(data, autoOrientJPEGQuality)
+	if err != nil {
+		log.Printf("Auto orient error: %v\n", err)
+		return stored
+	}
+	if !changed {
+		return stored
+	}
+
+	rotated, err := streamToTempFile(bytes.NewReader(oriented), dir, limit)
+	if err != nil {
+		log.Printf("Auto orient save error: %v\n", err)
+		return stored
+	}
+	removeTempFile(stored)
+	return rotated
+}
+
+// downscaleStoredImage 将宽高超出限制的临时文件等比缩小并按实际格式重新编码
+// 未超出限制时原样返回；处理失败时删除临时文件并返回错误 (不能保存超出限制的原图)
+func downscaleStoredImage(stored *streamedFile, dir string, limit int64) (*streamedFile, error) {
+	maxWidth, maxHeight := maxImageBounds()
+	if maxWidth == 0 && maxHeight == 0 {
+		return stored, nil
+	}
+	width, height, err := decodeImageSize(stored.TempPath)
+	if err != nil {
+		removeTempFile(stored)
+		return nil, errors.New("无法解析图片尺寸信息")
+	}
+	if _, _, oversize := utils.FitWithin(width, height, maxWidth, maxHeight); !oversize {
+		return stored, nil
+	}
+
+	data, err := os.ReadFile(stored.TempPath)
+	removeTempFile(stored)
+	if err != nil {
+		log.Printf("Downscale image read error: %v\n", err)
+		return nil, errors.New("文件保存失败")
+	}
+	quality := GetInt(consts.ConfigJpegQuality)
+	if quality < 1 || quality > 100 {
+		quality = 85
+	}
+	resized, _, err := utils.DownscaleImage(data, maxWidth, maxHeight, quality)
+	if err != nil {
+		log.Printf("Downscale image error: %v\n", err)
+		return nil, errors.New("图片缩小失败")
+	}
+	smaller, err := streamToTempFile(bytes.NewReader(resized), dir, limit)
+	if err != nil {
+		if errors.Is(err, errStreamLimitExceeded) {
+			return nil, errors.New("缩小后的图片大小超出限制")
+		}
+		log.Printf("Downscale image save error: %v\n", err)
+		return nil, errors.New("文件保存失败")
+	}
+	return smaller, nil
+}
+
+// optimizeStoredImage 重新压缩已写入临时文件的 JPEG/PNG，仅在结果更小时返回新的临时文件
+// 其他类型、动画或色彩管理的图片、处理失败或压缩无收益时保留原文件
+func optimizeStoredImage(stored *streamedFile, imageType, dir string) *streamedFile {
+	if imageType != "jpg" && imageType != "png" {
+		return stored
+	}
+	data, err := os.ReadFile(stored.TempPath)
+	if err != nil {
+		log.Printf("Optimize image read error: %v\n", err)
+		return stored
+	}
+
+	var optimized []byte
+	var changed bool
+	if imageType == "jpg" {
+		quality := GetInt(consts.ConfigJpegQuality)
+		if quality < 1 || quality > 100 {
+			quality = 85
+		}
+		optimized, changed, err = utils.RecompressJPEG(data, quality)
+	} else {
+		optimized, changed, err = utils.OptimizePNG(data)
+	}
+	if err != nil {
+		log.Printf("Optimize image error: %v\n", err)
+		return stored
+	}
+	if !changed || int64(len(optimized)) >= stored.Size {
+		return stored
+	}
+
+	smaller, err := streamToTempFile(bytes.NewReader(optimized), dir, stored.Size)
+	if err != nil {
+		log.Printf("Optimize image save error: %v\n", err)
+		return stored
+	}
+	removeTempFile(stored)
+	return smaller
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+// storedFileCount 统计上传目录中的文件数
+func storedFileCount(t *testing.T) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir("uploads", func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk uploads: %v", err)
+	}
+	return n
+}
+
+func TestProcessImageUploadGivesUpWhenRequestIsCancelled(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigMaxConcurrentImageOps: "1"})
+	user := createTestUser(t, "uploader")
+
+	release, err := acquireImageOp(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := ProcessImageUpload(ctx, pngFileHeader(t, "a.png"), user.ID, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	var count int64
+	db.DB.Model(&model.Image{}).Count(&count)
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if count != 0 || stored.StorageUsed != 0 {
+		t.Fatalf("cancelled upload left %d images and %d bytes used", count, stored.StorageUsed)
+	}
+	if n := storedFileCount(t); n != 0 {
+		t.Fatalf("cancelled upload left %d files in storage", n)
+	}
+}
+
+func TestDeleteImageGivesUpWhenRequestIsCancelled(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "deleter")
+	img := createStoredImage(t, user.ID, "2026/02/13/a.png")
+	other := createStoredImage(t, user.ID, "2026/02/13/b.png")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := DeleteImage(ctx, &img); !errors.Is(err, context.Canceled) {
+		t.Fatalf("DeleteImage: expected context.Canceled, got %v", err)
+	}
+	if err := BatchDeleteImages(ctx, []model.Image{img, other}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("BatchDeleteImages: expected context.Canceled, got %v", err)
+	}
+
+	var count int64
+	db.DB.Model(&model.Image{}).Count(&count)
+	if count != 2 || storedFileCount(t) != 2 {
+		t.Fatalf("cancelled delete changed data: %d images, %d files", count, storedFileCount(t))
+	}
+
+	// 未取消时正常删除记录与文件
+	if err := DeleteImage(context.Background(), &img); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := BatchDeleteImages(context.Background(), []model.Image{other}); err != nil {
+		t.Fatalf("batch delete: %v", err)
+	}
+	db.DB.Model(&model.Image{}).Count(&count)
+	if count != 0 || storedFileCount(t) != 0 {
+		t.Fatalf("delete left %d images, %d files", count, storedFileCount(t))
+	}
+}
+
+func TestBatchDeleteImagesRemovesManyFiles(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice", func(u *model.User) { u.StorageUsed = 10000 })
+	bob := createTestUser(t, "bob", func(u *model.User) { u.StorageUsed = 10000 })
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+
+	var batch []model.Image
+	var aliceFreed, bobFreed int64
+	for i := 0; i < 40; i++ {
+		owner := alice.ID
+		if i%2 == 1 {
+			owner = bob.ID
+		}
+		key := fmt.Sprintf("2026/02/13/%02d.png", i)
+		img := model.Image{Filename: key, Path: key, Size: int64(10 + i), MimeType: "image/png", UploadedAt: time.Now().Unix(), UserID: owner}
+		// 文件已丢失的记录同样应被删除，不影响其他文件
+		if i != 7 {
+			putObject(t, store, key, []byte("image"))
+		}
+		if i%5 == 0 {
+			img.WebpPath = key + ".webp"
+			putObject(t, store, img.WebpPath, []byte("webp"))
+		}
+		if err := db.DB.Create(&img).Error; err != nil {
+			t.Fatalf("create image: %v", err)
+		}
+		if owner == alice.ID {
+			aliceFreed += img.Size
+		} else {
+			bobFreed += img.Size
+		}
+		batch = append(batch, img)
+	}
+	kept := createStoredImage(t, alice.ID, "2026/02/13/kept.png")
+
+	// 已被并发请求删除的记录不重复释放空间
+	gone := batch[3]
+	if err := db.DB.Delete(&model.Image{}, gone.ID).Error; err != nil {
+		t.Fatalf("delete image: %v", err)
+	}
+	bobFreed -= gone.Size
+
+	if err := BatchDeleteImages(context.Background(), batch); err != nil {
+		t.Fatalf("batch delete: %v", err)
+	}
+
+	var remaining []model.Image
+	db.DB.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].ID != kept.ID {
+		t.Fatalf("expected only the kept image to remain, got %d records", len(remaining))
+	}
+	// 只剩下未删除的图片与已删除记录 batch[3] 的文件
+	if n := storedFileCount(t); n != 2 {
+		t.Fatalf("%d files left in storage, want 2", n)
+	}
+	if _, err := readObject(t, store, kept.Path); err != nil {
+		t.Fatalf("kept image file: %v", err)
+	}
+
+	var users []model.User
+	db.DB.Order("id").Find(&users)
+	if users[0].StorageUsed != 10000-aliceFreed || users[1].StorageUsed != 10000-bobFreed {
+		t.Fatalf("storage used = %d/%d, want %d/%d",
+			users[0].StorageUsed, users[1].StorageUsed, 10000-aliceFreed, 10000-bobFreed)
+	}
+}
+
+func TestConcurrentUploadsNeverExceedQuota(t *testing.T) {
+	setupTestDB(t)
+	const uploads = 20
+	files := make([]*multipart.FileHeader, uploads)
+	var quota int64
+	for i := range files {
+		f := batchPNG(t, i)
+		files[i] = uploadFileHeader(t, f[0], []byte(f[1]))
+		if i < 3 {
+			quota += files[i].Size
+		}
+	}
+	user := createTestUser(t, "racer", func(u *model.User) { u.StorageQuota = &quota })
+
+	var wg sync.WaitGroup
+	errs := make([]error, uploads)
+	for i, f := range files {
+		wg.Add(1)
+		go func(i int, f *multipart.FileHeader) {
+			defer wg.Done()
+			_, _, errs[i] = ProcessImageUpload(context.Background(), f, user.ID, nil)
+		}(i, f)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrStorageQuotaExceeded):
+			t.Fatalf("upload %d: unexpected error %v", i, err)
+		}
+	}
+	if succeeded == 0 || succeeded == uploads {
+		t.Fatalf("%d of %d uploads succeeded, expected the quota to stop some of them", succeeded, uploads)
+	}
+
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.StorageUsed > quota {
+		t.Fatalf("storage used %d exceeds quota %d", stored.StorageUsed, quota)
+	}
+	assertStorageMatchesImages(t, user.ID, succeeded)
+	if n := storedFileCount(t); n != succeeded {
+		t.Fatalf("%d files in storage for %d committed images", n, succeeded)
+	}
+}
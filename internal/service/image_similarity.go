@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// DefaultSimilarImageDistance 上传时提示“可能重复”所使用的汉明距离阈值
+const DefaultSimilarImageDistance = 5
+
+// FindSimilarImages 查找用户图库中与指定图片视觉相似的其他图片，
+// 按汉明距离从小到大排列。目标图片没有感知哈希时返回空列表
+func FindSimilarImages(userID uint, imageID uint, maxHammingDistance int) ([]model.Image, error) {
+	var target model.Image
+	if err := db.DB.Where("id = ? AND user_id = ?", imageID, userID).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("图片不存在")
+		}
+		return nil, err
+	}
+	if target.PHash == nil || maxHammingDistance < 0 {
+		return []model.Image{}, nil
+	}
+
+	var candidates []model.Image
+	if err := db.DB.Select("id", "p_hash").
+		Where("user_id = ? AND id <> ? AND p_hash IS NOT NULL", userID, imageID).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	distances := make(map[uint]int)
+	var ids []uint
+	for _, candidate := range candidates {
+		d := utils.HammingDistance(uint64(*target.PHash), uint64(*candidate.PHash))
+		if d <= maxHammingDistance {
+			distances[candidate.ID] = d
+			ids = append(ids, candidate.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return []model.Image{}, nil
+	}
+
+	var images []model.Image
+	if err := db.DB.Where("id IN ?", ids).Find(&images).Error; err != nil {
+		return nil, err
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		if distances[images[i].ID] != distances[images[j].ID] {
+			return distances[images[i].ID] < distances[images[j].ID]
+		}
+		return images[i].ID < images[j].ID
+	})
+	return images, nil
+}
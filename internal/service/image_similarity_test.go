@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+)
+
+// setImagePHash 设置图片记录的感知哈希
+func setImagePHash(t *testing.T, img *model.Image, hash int64) {
+	t.Helper()
+	if err := db.DB.Model(img).Update("p_hash", hash).Error; err != nil {
+		t.Fatalf("set p_hash: %v", err)
+	}
+}
+
+func TestFindSimilarImages(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "similar")
+	other := createTestUser(t, "stranger")
+
+	target := createStoredImage(t, user.ID, "target.png")
+	near := createStoredImage(t, user.ID, "near.png")
+	closest := createStoredImage(t, user.ID, "closest.png")
+	far := createStoredImage(t, user.ID, "far.png")
+	unhashed := createStoredImage(t, user.ID, "unhashed.png")
+	foreign := createStoredImage(t, other.ID, "foreign.png")
+	setImagePHash(t, &target, 0b1111_0000)
+	setImagePHash(t, &near, 0b1111_0011)    // 距离 2
+	setImagePHash(t, &closest, 0b1111_0001) // 距离 1
+	setImagePHash(t, &far, 0b0000_1111)     // 距离 8
+	setImagePHash(t, &foreign, 0b1111_0000)
+
+	images, err := FindSimilarImages(user.ID, target.ID, DefaultSimilarImageDistance)
+	if err != nil {
+		t.Fatalf("find similar: %v", err)
+	}
+	if len(images) != 2 || images[0].ID != closest.ID || images[1].ID != near.ID {
+		t.Fatalf("similar images = %+v, want closest then near", images)
+	}
+
+	// 没有感知哈希的图片没有相似结果
+	if images, err := FindSimilarImages(user.ID, unhashed.ID, DefaultSimilarImageDistance); err != nil || len(images) != 0 {
+		t.Fatalf("unhashed similar = %v, %v", images, err)
+	}
+	// 不能查询其他用户的图片
+	if _, err := FindSimilarImages(user.ID, foreign.ID, DefaultSimilarImageDistance); err == nil {
+		t.Fatal("expected error for another user's image")
+	}
+}
+
+func TestUploadStoresPerceptualHash(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "hashed")
+
+	img, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "a.png"), user.ID, nil)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	var stored model.Image
+	if err := db.DB.First(&stored, img.ID).Error; err != nil {
+		t.Fatalf("load image: %v", err)
+	}
+	if stored.PHash == nil {
+		t.Fatal("p_hash not stored on upload")
+	}
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"mime/multipart"
+	"net/http/httptest"
+	"path/filepath"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uploadFileHeader 构造内容为 data 的 multipart 文件头
+func uploadFileHeader(t *testing.T, name string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", name)
+	_, _ = part.Write(data)
+	_ = w.Close()
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("parse multipart: %v", err)
+	}
+	t.Cleanup(func() { _ = req.MultipartForm.RemoveAll() })
+	return req.MultipartForm.File["file"][0]
+}
+
+func TestExtensionMismatchPolicy(t *testing.T) {
+	setupTestDB(t)
+
+	tests := []struct {
+		policy  string
+		wantOK  bool
+		wantExt string
+	}{
+		{policy: ExtensionMismatchReject, wantOK: false, wantExt: ".jpg"},
+		{policy: ExtensionMismatchTrustContent, wantOK: true, wantExt: ".png"},
+		{policy: ExtensionMismatchTrustExtension, wantOK: true, wantExt: ".jpg"},
+	}
+	for _, tt := range tests {
+		setTestSettings(t, map[string]string{consts.ConfigExtensionMismatchPolicy: tt.policy})
+		ok, ext, err := ValidateImageFile(pngFileHeader(t, "a.jpg"))
+		if ok != tt.wantOK || ext != tt.wantExt {
+			t.Errorf("%s: ok = %v, ext = %q, err = %v; want %v, %q", tt.policy, ok, ext, err, tt.wantOK, tt.wantExt)
+		}
+	}
+}
+
+func TestExtensionMismatchPolicyStillChecksAllowedTypes(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigExtensionMismatchPolicy: ExtensionMismatchTrustContent})
+
+	// 声明的扩展名不在允许列表
+	if ok, _, _ := ValidateImageFile(pngFileHeader(t, "a.exe")); ok {
+		t.Fatal("disallowed extension accepted")
+	}
+
+	// 真实类型不在允许列表
+	setTestSettings(t, map[string]string{consts.ConfigAllowFileExtensions: ".jpg,.jpeg,.gif,.webp"})
+	if ok, _, _ := ValidateImageFile(pngFileHeader(t, "a.jpg")); ok {
+		t.Fatal("disallowed content type accepted")
+	}
+}
+
+func TestTrustContentStoresDetectedType(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigExtensionMismatchPolicy: ExtensionMismatchTrustContent})
+	user := createTestUser(t, "uploader")
+
+	img, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "photo.jpg"), user.ID, nil)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if filepath.Ext(img.Path) != ".png" || img.MimeType != ".png" {
+		t.Fatalf("stored as %s (%s), want png", img.Path, img.MimeType)
+	}
+}
+
+func TestSVGUploadIsSanitized(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "artist")
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20" onload="alert(1)"><script>alert(1)</script><rect width="4" height="4"/></svg>`)
+
+	if _, _, err := ProcessImageUpload(context.Background(), uploadFileHeader(t, "logo.svg", svg), user.ID, nil); err == nil {
+		t.Fatal("SVG accepted while disabled")
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigAllowSVG: "true"})
+	if _, _, err := ProcessImageUpload(context.Background(), uploadFileHeader(t, "page.svg", []byte(`<html/>`)), user.ID, nil); err == nil {
+		t.Fatal("non-SVG document accepted as .svg")
+	}
+
+	img, _, err := ProcessImageUpload(context.Background(), uploadFileHeader(t, "logo.svg", svg), user.ID, nil)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if img.Width != 40 || img.Height != 20 {
+		t.Fatalf("size = %dx%d, want 40x20", img.Width, img.Height)
+	}
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	stored, err := readObject(t, store, img.Path)
+	if err != nil {
+		t.Fatalf("read stored svg: %v", err)
+	}
+	if strings.Contains(string(stored), "alert") || !strings.Contains(string(stored), "<rect") {
+		t.Fatalf("stored SVG not sanitized: %s", stored)
+	}
+}
+
+func TestOversizePolicy(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "photographer")
+	setTestSettings(t, map[string]string{consts.ConfigMaxImageWidth: "32", consts.ConfigMaxImageHeight: "32"})
+
+	if _, _, err := ProcessImageUpload(context.Background(), uploadFileHeader(t, "wide.png", testPNG(t, 64, 16)), user.ID, nil); err == nil || !strings.Contains(err.Error(), "图片尺寸过大") {
+		t.Fatalf("oversize image under reject policy: err = %v", err)
+	}
+	if _, _, err := ProcessImageUpload(context.Background(), uploadFileHeader(t, "fits.png", testPNG(t, 32, 32)), user.ID, nil); err != nil {
+		t.Fatalf("image within bounds: %v", err)
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigOversizePolicy: OversizeDownscale})
+	img, _, err := ProcessImageUpload(context.Background(), uploadFileHeader(t, "wide.png", testPNG(t, 64, 16)), user.ID, nil)
+	if err != nil {
+		t.Fatalf("downscale upload: %v", err)
+	}
+	if img.Width != 32 || img.Height != 8 {
+		t.Fatalf("stored size = %dx%d, want 32x8", img.Width, img.Height)
+	}
+
+	// GIF 不支持缩小，超出时仍然拒绝
+	var animated bytes.Buffer
+	if err := gif.Encode(&animated, gradientImage(64, 16), nil); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	if _, _, err := ProcessImageUpload(context.Background(), uploadFileHeader(t, "wide.gif", animated.Bytes()), user.ID, nil); err == nil {
+		t.Fatal("oversize GIF should be rejected even with downscale policy")
+	}
+}
+
+func TestValidateImageFileRejectsTooManyGIFFrames(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigMaxGifFrames: "2"})
+
+	pal := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := 0; i < 3; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), pal))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+
+	ok, _, err := ValidateImageFile(uploadFileHeader(t, "a.gif", buf.Bytes()))
+	if ok || err == nil || !strings.Contains(err.Error(), "帧数") {
+		t.Fatalf("ok = %v, err = %v; want frame limit error", ok, err)
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigMaxGifFrames: "3"})
+	if ok, _, err := ValidateImageFile(uploadFileHeader(t, "a.gif", buf.Bytes())); !ok {
+		t.Fatalf("gif at the frame limit rejected: %v", err)
+	}
+}
+
+func TestValidateImageFileRejectsOversizedPNGHeader(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigMaxPixels: "1000000"})
+
+	// 只有 IHDR 的 PNG 声明 50000x50000，必须在解码像素前被拒绝
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], 50000)
+	binary.BigEndian.PutUint32(ihdr[4:], 50000)
+	ihdr[8], ihdr[9] = 8, 6
+	var data bytes.Buffer
+	data.WriteString("\x89PNG\r\n\x1a\n")
+	_ = binary.Write(&data, binary.BigEndian, uint32(len(ihdr)))
+	data.WriteString("IHDR")
+	data.Write(ihdr)
+	_ = binary.Write(&data, binary.BigEndian, crc32.ChecksumIEEE(append([]byte("IHDR"), ihdr...)))
+
+	ok, _, err := ValidateImageFile(uploadFileHeader(t, "bomb.png", data.Bytes()))
+	if ok || err == nil || !strings.Contains(err.Error(), "像素面积过大") {
+		t.Fatalf("ok = %v, err = %v; want pixel limit error", ok, err)
+	}
+}
+
+func TestAllowedImageTypesSetting(t *testing.T) {
+	setupTestDB(t)
+	if ok, _, err := ValidateImageFile(pngFileHeader(t, "a.png")); !ok {
+		t.Fatalf("png rejected by default: %v", err)
+	}
+
+	// 收窄允许的类型后，之前可以上传的 PNG 被拒绝，错误信息列出允许的类型
+	setTestSettings(t, map[string]string{consts.ConfigAllowedImageTypes: "jpg,webp"})
+	ok, _, err := ValidateImageFile(pngFileHeader(t, "a.png"))
+	if ok || err == nil || !strings.Contains(err.Error(), "jpg, webp") {
+		t.Fatalf("ok = %v, err = %v; want rejection listing jpg, webp", ok, err)
+	}
+	// 扩展名伪装成允许的类型也会被内容检查拒绝
+	if ok, _, _ := ValidateImageFile(pngFileHeader(t, "a.jpg")); ok {
+		t.Fatal("png content accepted under an allowed extension")
+	}
+
+	// 配置中的未知类型被忽略，不影响其余类型
+	setTestSettings(t, map[string]string{consts.ConfigAllowedImageTypes: "png, tiff,,exe"})
+	if ok, _, err := ValidateImageFile(pngFileHeader(t, "a.png")); !ok {
+		t.Fatalf("png rejected next to unknown types: %v", err)
+	}
+
+	// 全部无效时回退到允许的文件扩展名
+	setTestSettings(t, map[string]string{consts.ConfigAllowedImageTypes: "tiff"})
+	if got := GetAllowedImageTypes(); strings.Join(got, ",") != "jpg,png,gif,webp" {
+		t.Fatalf("allowed types = %v, want the extension list", got)
+	}
+}
+
+func TestMaxUploadSizeBytes(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	file := pngFileHeader(t, "a.png")
+
+	// 恰好等于限制时允许
+	setTestSettings(t, map[string]string{consts.ConfigMaxUploadSizeBytes: strconv.FormatInt(file.Size, 10)})
+	if ok, _, err := ValidateImageFile(file); !ok {
+		t.Fatalf("file at the limit rejected: %v", err)
+	}
+
+	// 超出 1 字节时返回与配额不足不同的错误
+	setTestSettings(t, map[string]string{consts.ConfigMaxUploadSizeBytes: strconv.FormatInt(file.Size-1, 10)})
+	ok, _, err := ValidateImageFile(file)
+	if ok || !errors.Is(err, ErrFileTooLarge) || errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("file over the limit: ok = %v, err = %v; want ErrFileTooLarge", ok, err)
+	}
+	if _, _, err := ProcessImageUpload(context.Background(), file, user.ID, nil); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("upload over the limit: err = %v, want ErrFileTooLarge", err)
+	}
+}
+
+func TestMaxUploadSizeBytesGuardsActualRead(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	file := pngFileHeader(t, "a.png")
+	setTestSettings(t, map[string]string{consts.ConfigMaxUploadSizeBytes: strconv.FormatInt(file.Size-1, 10)})
+
+	// multipart 头中声明的大小偏小时，实际读取仍受限制
+	actual := file.Size
+	file.Size = 10
+	_, _, err := ProcessImageUpload(context.Background(), file, user.ID, nil)
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("upload with a lying header (%d bytes): err = %v, want ErrFileTooLarge", actual, err)
+	}
+	if n := storedFileCount(t); n != 0 {
+		t.Fatalf("stored files = %d, want 0", n)
+	}
+	var count int64
+	db.DB.Model(&model.Image{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("image records = %d, want 0", count)
+	}
+}
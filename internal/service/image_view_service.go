@@ -0,0 +1,135 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// imageViewFlushInterval 访问计数写回数据库的周期
+	imageViewFlushInterval = 10 * time.Second
+	// maxPendingImageViews 缓冲中最多累积的图片数量，超出时提前写回
+	maxPendingImageViews = 10000
+)
+
+// pendingImageView 尚未写回数据库的访问计数
+type pendingImageView struct {
+	Count      int64
+	LastAccess int64
+}
+
+var (
+	imageViewMu      sync.Mutex
+	pendingViews     = make(map[string]*pendingImageView)
+	imageViewOnce    sync.Once
+	imageViewStopCh  chan struct{}
+	imageViewDoneCh  chan struct{}
+	imageViewFlushCh = make(chan struct{}, 1)
+)
+
+// botUserAgentKeywords 判定为爬虫的 User-Agent 关键字 (小写)
+var botUserAgentKeywords = []string{"bot", "spider", "crawl", "slurp", "preview", "facebookexternalhit"}
+
+// RecordImageView 记录一次图片访问，计数先在内存中累积，由后台任务批量写回
+// 未开启 ConfigTrackImageViews 时不记录；开启 ConfigImageViewsExcludeBots 时忽略 HEAD 请求与爬虫
+func RecordImageView(key, method, userAgent string) {
+	if !GetBool(consts.ConfigTrackImageViews) {
+		return
+	}
+	if GetBool(consts.ConfigImageViewsExcludeBots) && (method == http.MethodHead || isBotUserAgent(userAgent)) {
+		return
+	}
+
+	imageViewMu.Lock()
+	view := pendingViews[key]
+	if view == nil {
+		view = &pendingImageView{}
+		pendingViews[key] = view
+	}
+	view.Count++
+	view.LastAccess = time.Now().Unix()
+	full := len(pendingViews) >= maxPendingImageViews
+	imageViewMu.Unlock()
+
+	if full {
+		select {
+		case imageViewFlushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// FlushImageViews 将缓冲中的访问计数写回数据库，每张图片一条原子自增语句
+func FlushImageViews() error {
+	imageViewMu.Lock()
+	views := pendingViews
+	pendingViews = make(map[string]*pendingImageView)
+	imageViewMu.Unlock()
+
+	var firstErr error
+	for key, view := range views {
+		err := db.DB.Model(&model.Image{}).Where("path = ?", key).UpdateColumns(map[string]interface{}{
+			"view_count":       gorm.Expr("view_count + ?", view.Count),
+			"last_accessed_at": gorm.Expr("CASE WHEN last_accessed_at < ? THEN ? ELSE last_accessed_at END", view.LastAccess, view.LastAccess),
+		}).Error
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// StartImageViewFlusher 启动后台任务，定期写回图片访问计数
+func StartImageViewFlusher() {
+	imageViewOnce.Do(func() {
+		imageViewStopCh = make(chan struct{})
+		imageViewDoneCh = make(chan struct{})
+		go func() {
+			defer close(imageViewDoneCh)
+			ticker := time.NewTicker(imageViewFlushInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+				case <-imageViewFlushCh:
+				case <-imageViewStopCh:
+					if err := FlushImageViews(); err != nil {
+						log.Printf("Flush image views error: %v\n", err)
+					}
+					return
+				}
+				if err := FlushImageViews(); err != nil {
+					log.Printf("Flush image views error: %v\n", err)
+				}
+			}
+		}()
+	})
+}
+
+// StopImageViewFlusher 停止后台任务并写回剩余的访问计数
+func StopImageViewFlusher() {
+	if imageViewStopCh == nil {
+		return
+	}
+	close(imageViewStopCh)
+	<-imageViewDoneCh
+	imageViewStopCh = nil
+}
+
+func isBotUserAgent(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, keyword := range botUserAgentKeywords {
+		if strings.Contains(ua, keyword) {
+			return true
+		}
+	}
+	return false
+}
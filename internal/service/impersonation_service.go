@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ImpersonationTokenDuration 模拟登录 Token 的有效期，远短于普通登录
+const ImpersonationTokenDuration = 30 * time.Minute
+
+var (
+	// ErrImpersonationForbidden 调用者无权模拟登录或目标用户不允许被模拟
+	ErrImpersonationForbidden = errors.New("无权模拟登录该用户")
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("用户不存在")
+)
+
+// ImpersonateUser 以管理员身份模拟登录目标用户，签发携带 impersonated_by 声明的短期 Token 并记录审计日志
+// 模拟会话与普通会话一样记录在会话表中，可被吊销；不允许模拟其他管理员
+func ImpersonateUser(adminID, targetUserID uint, meta SessionMeta) (string, error) {
+	var operator model.User
+	if err := db.DB.Select("id", "admin", "status").First(&operator, adminID).Error; err != nil {
+		return "", ErrImpersonationForbidden
+	}
+	if !operator.Admin || operator.Status != 1 {
+		return "", ErrImpersonationForbidden
+	}
+	if adminID == targetUserID {
+		return "", ErrImpersonationForbidden
+	}
+
+	var target model.User
+	if err := db.DB.First(&target, targetUserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrUserNotFound
+		}
+		log.Printf("Get user error: %v\n", err)
+		return "", errors.New("查询用户信息失败")
+	}
+	if target.Admin {
+		return "", ErrImpersonationForbidden
+	}
+
+	now := time.Now()
+	jti := utils.NewTokenID()
+	userAgent := meta.UserAgent
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	session := model.Session{
+		JTI:       jti,
+		UserID:    target.ID,
+		UserAgent: userAgent,
+		IP:        meta.IP,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(ImpersonationTokenDuration).Unix(),
+	}
+	if err := db.DB.Create(&session).Error; err != nil {
+		log.Printf("Create session error: %v\n", err)
+		return "", errors.New("创建会话失败")
+	}
+
+	token, err := utils.GenerateImpersonationToken(jti, target.ID, target.Username, target.TokenVersion, adminID, ImpersonationTokenDuration)
+	if err != nil {
+		return "", err
+	}
+	RecordAudit(adminID, AuditActionImpersonate, AuditTargetUser(target.ID), meta.IP, map[string]interface{}{
+		"jti":        jti,
+		"expires_at": session.ExpiresAt,
+	})
+	return token, nil
+}
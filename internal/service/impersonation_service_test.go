@@ -0,0 +1,88 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestImpersonateUserIssuesShortLivedSessionToken(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestUser(t, "operator", func(u *model.User) { u.Admin = true })
+	target := createTestUser(t, "customer")
+
+	token, err := ImpersonateUser(admin.ID, target.ID, SessionMeta{UserAgent: "curl/8.0", IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("impersonate: %v", err)
+	}
+
+	claims, err := utils.ParseLoginToken(token)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if claims.ID != target.ID || claims.Admin || claims.ImpersonatedBy != admin.ID {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > ImpersonationTokenDuration || ttl < ImpersonationTokenDuration-time.Minute {
+		t.Fatalf("token should expire in %v, got %v", ImpersonationTokenDuration, ttl)
+	}
+
+	// 模拟会话与普通会话一样可以被吊销
+	if !IsSessionActive(target.ID, claims.RegisteredClaims.ID) {
+		t.Fatal("impersonation session should be active")
+	}
+	if err := RevokeSession(target.ID, claims.RegisteredClaims.ID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	if IsSessionActive(target.ID, claims.RegisteredClaims.ID) {
+		t.Fatal("revoked impersonation session should be inactive")
+	}
+
+	var entry model.AuditLog
+	if err := db.DB.Where("action = ?", AuditActionImpersonate).First(&entry).Error; err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(entry.Metadata), &metadata); err != nil {
+		t.Fatalf("unmarshal metadata: %v", err)
+	}
+	if entry.ActorID != admin.ID || entry.Target != AuditTargetUser(target.ID) || metadata["jti"] != claims.RegisteredClaims.ID {
+		t.Fatalf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestImpersonateUserRejectsForbiddenTargets(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestUser(t, "operator", func(u *model.User) { u.Admin = true })
+	otherAdmin := createTestUser(t, "colleague", func(u *model.User) { u.Admin = true })
+	disabledAdmin := createTestUser(t, "retired", func(u *model.User) { u.Admin = true; u.Status = 2 })
+	user := createTestUser(t, "customer")
+
+	tests := []struct {
+		name    string
+		actor   uint
+		target  uint
+		wantErr error
+	}{
+		{name: "other admin", actor: admin.ID, target: otherAdmin.ID, wantErr: ErrImpersonationForbidden},
+		{name: "self", actor: admin.ID, target: admin.ID, wantErr: ErrImpersonationForbidden},
+		{name: "non admin actor", actor: user.ID, target: otherAdmin.ID, wantErr: ErrImpersonationForbidden},
+		{name: "disabled admin actor", actor: disabledAdmin.ID, target: user.ID, wantErr: ErrImpersonationForbidden},
+		{name: "missing user", actor: admin.ID, target: 9999, wantErr: ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := ImpersonateUser(tt.actor, tt.target, SessionMeta{}); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	var count int64
+	db.DB.Model(&model.Session{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("rejected impersonation should not create sessions, got %d", count)
+	}
+}
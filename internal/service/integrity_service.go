@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"sync"
+	"time"
+)
+
+const (
+	IntegrityReasonMismatch = "mismatch"
+	IntegrityReasonMissing  = "missing"
+)
+
+var (
+	// integrityCursor 巡检游标，记录上一轮检查到的最大图片 ID，实现按 ID 轮转抽检
+	integrityCursor uint
+	integrityMu     sync.Mutex
+	integrityOnce   sync.Once
+)
+
+// StartIntegrityScanner 启动后台完整性巡检协程 (仅启动一次)
+// 每分钟按 ConfigIntegrityScanSampleRate 抽检一批图片，限制单轮 IO 量
+func StartIntegrityScanner() {
+	integrityOnce.Do(func() {
+		go func() {
+			for {
+				time.Sleep(1 * time.Minute)
+				limit := GetInt(consts.ConfigIntegrityScanSampleRate)
+				if limit <= 0 {
+					continue
+				}
+				if _, flagged, err := ScanImageIntegrity(limit); err != nil {
+					log.Printf("Integrity scan error: %v\n", err)
+				} else if flagged > 0 {
+					log.Printf("⚠️ 完整性巡检发现 %d 个异常文件\n", flagged)
+				}
+			}
+		}()
+	})
+}
+
+// ScanImageIntegrity 从游标位置开始校验最多 limit 张带有内容哈希的图片
+// 返回本轮检查数量与新标记的异常数量
+func ScanImageIntegrity(limit int) (int, int, error) {
+	integrityMu.Lock()
+	defer integrityMu.Unlock()
+
+	var images []model.Image
+	if err := db.DB.Where("hash <> '' AND id > ?", integrityCursor).
+		Order("id asc").Limit(limit).Find(&images).Error; err != nil {
+		return 0, 0, err
+	}
+
+	// 本轮不足一批说明已扫到末尾，下一轮从头开始
+	if len(images) < limit {
+		integrityCursor = 0
+	} else {
+		integrityCursor = images[len(images)-1].ID
+	}
+
+	store, err := ImageStorage()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	flagged := 0
+	for _, img := range images {
+		reason, actual := checkFileHash(store, img.Path, img.Hash)
+		if reason == "" {
+			continue
+		}
+
+		// 同一图片已有未处理的记录时不重复标记
+		var count int64
+		db.DB.Model(&model.ImageIntegrityIssue{}).Where("image_id = ? AND resolved = ?", img.ID, false).Count(&count)
+		if count > 0 {
+			continue
+		}
+
+		issue := model.ImageIntegrityIssue{
+			ImageID:      img.ID,
+			Path:         img.Path,
+			ExpectedHash: img.Hash,
+			ActualHash:   actual,
+			Reason:       reason,
+			DetectedAt:   time.Now().Unix(),
+		}
+		if err := db.DB.Create(&issue).Error; err != nil {
+			log.Printf("Create integrity issue error: %v\n", err)
+			continue
+		}
+		flagged++
+	}
+
+	return len(images), flagged, nil
+}
+
+// checkFileHash 校验存储中文件的哈希，一致时返回空 reason
+func checkFileHash(store storage.Storage, key, expected string) (string, string) {
+	f, err := store.Get(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return IntegrityReasonMissing, ""
+		}
+		log.Printf("Integrity open file error: %v, path: %s\n", err, key)
+		return "", ""
+	}
+	defer func() { _ = f.Close() }()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		log.Printf("Integrity read file error: %v, path: %s\n", err, key)
+		return "", ""
+	}
+
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if actual != expected {
+		return IntegrityReasonMismatch, actual
+	}
+	return "", ""
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+)
+
+// createHashedImage 保存内容为 content 的图片并记录其内容哈希
+func createHashedImage(t *testing.T, userID uint, key string, content []byte) model.Image {
+	t.Helper()
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	putObject(t, store, key, content)
+	sum := sha256.Sum256(content)
+	img := model.Image{Filename: key, Path: key, Size: int64(len(content)), MimeType: ".png", UserID: userID, Hash: hex.EncodeToString(sum[:])}
+	if err := db.DB.Create(&img).Error; err != nil {
+		t.Fatalf("create image: %v", err)
+	}
+	return img
+}
+
+// integrityIssues 返回按图片 ID 索引的未处理异常记录
+func integrityIssues(t *testing.T) map[uint]model.ImageIntegrityIssue {
+	t.Helper()
+	var issues []model.ImageIntegrityIssue
+	if err := db.DB.Where("resolved = ?", false).Find(&issues).Error; err != nil {
+		t.Fatalf("load issues: %v", err)
+	}
+	byImage := make(map[uint]model.ImageIntegrityIssue, len(issues))
+	for _, issue := range issues {
+		byImage[issue.ImageID] = issue
+	}
+	return byImage
+}
+
+func TestScanImageIntegrityFlagsTamperedFiles(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+
+	good := createHashedImage(t, user.ID, "2026/good.png", []byte("good image"))
+	tampered := createHashedImage(t, user.ID, "2026/tampered.png", []byte("original"))
+	putObject(t, store, tampered.Path, []byte("bit rot"))
+	missing := createHashedImage(t, user.ID, "2026/missing.png", []byte("gone"))
+	if err := store.Delete(context.Background(), missing.Path); err != nil {
+		t.Fatalf("delete file: %v", err)
+	}
+
+	checked, flagged, err := ScanImageIntegrity(10)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if checked != 3 || flagged != 2 {
+		t.Fatalf("checked %d, flagged %d; want 3, 2", checked, flagged)
+	}
+
+	issues := integrityIssues(t)
+	if _, ok := issues[good.ID]; ok {
+		t.Fatal("intact file was flagged")
+	}
+	if issue := issues[tampered.ID]; issue.Reason != IntegrityReasonMismatch || issue.ExpectedHash != tampered.Hash || issue.ActualHash == tampered.Hash {
+		t.Fatalf("tampered issue = %+v", issue)
+	}
+	if issue := issues[missing.ID]; issue.Reason != IntegrityReasonMissing {
+		t.Fatalf("missing issue = %+v", issue)
+	}
+
+	// 未处理的异常不会重复标记
+	if _, flagged, err := ScanImageIntegrity(10); err != nil || flagged != 0 {
+		t.Fatalf("second scan flagged %d, err %v; want 0", flagged, err)
+	}
+	if n := len(integrityIssues(t)); n != 2 {
+		t.Fatalf("issues after second scan = %d, want 2", n)
+	}
+}
+
+func TestScanImageIntegrityRotatesThroughSample(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	var images []model.Image
+	for _, key := range []string{"a.png", "b.png", "c.png"} {
+		images = append(images, createHashedImage(t, user.ID, key, []byte(key)))
+	}
+	// 只有最后一张被篡改，需要轮转到第二批才能发现
+	putObject(t, store, images[2].Path, []byte("tampered"))
+
+	if checked, flagged, err := ScanImageIntegrity(2); err != nil || checked != 2 || flagged != 0 {
+		t.Fatalf("first batch: checked %d, flagged %d, err %v; want 2, 0", checked, flagged, err)
+	}
+	if checked, flagged, err := ScanImageIntegrity(2); err != nil || checked != 1 || flagged != 1 {
+		t.Fatalf("second batch: checked %d, flagged %d, err %v; want 1, 1", checked, flagged, err)
+	}
+	// 扫到末尾后从头开始
+	if checked, _, err := ScanImageIntegrity(2); err != nil || checked != 2 {
+		t.Fatalf("wrapped batch: checked %d, err %v; want 2", checked, err)
+	}
+}
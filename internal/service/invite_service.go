@@ -0,0 +1,137 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 注册模式
+const (
+	RegistrationModeOpen   = "open"   // 开放注册
+	RegistrationModeClosed = "closed" // 关闭注册
+	RegistrationModeInvite = "invite" // 仅允许持有邀请码注册
+)
+
+// GetRegistrationMode 返回当前注册模式
+// ConfigAllowRegister 作为总开关，关闭时始终视为 closed；未知取值按 open 处理
+func GetRegistrationMode() string {
+	if !GetBool(consts.ConfigAllowRegister) {
+		return RegistrationModeClosed
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(GetString(consts.ConfigRegistrationMode))); mode {
+	case RegistrationModeClosed, RegistrationModeInvite:
+		return mode
+	default:
+		return RegistrationModeOpen
+	}
+}
+
+// CheckRegistrationAllowed 按当前注册模式检查是否允许注册，邀请模式下仅预检邀请码是否可用
+func CheckRegistrationAllowed(inviteCode string) error {
+	switch GetRegistrationMode() {
+	case RegistrationModeClosed:
+		return newAuthError(AuthErrorForbidden, "注册功能已关闭")
+	case RegistrationModeInvite:
+		if strings.TrimSpace(inviteCode) == "" {
+			return newAuthError(AuthErrorForbidden, "当前仅允许通过邀请码注册")
+		}
+		var invite model.InviteCode
+		if err := db.DB.Where("code = ?", strings.TrimSpace(inviteCode)).First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newAuthError(AuthErrorValidation, "邀请码无效")
+			}
+			return newAuthError(AuthErrorInternal, "校验邀请码失败")
+		}
+		if invite.UsedCount >= invite.MaxUses {
+			return newAuthError(AuthErrorValidation, "邀请码已被使用")
+		}
+		if invite.ExpiresAt > 0 && invite.ExpiresAt <= time.Now().Unix() {
+			return newAuthError(AuthErrorValidation, "邀请码已过期")
+		}
+	}
+	return nil
+}
+
+// ConsumeInviteCode 在注册事务中消耗一次邀请码，非邀请模式下不做任何处理
+// 通过条件更新保证并发注册时邀请码不会被超额使用
+func ConsumeInviteCode(tx *gorm.DB, inviteCode string) error {
+	if GetRegistrationMode() != RegistrationModeInvite {
+		return nil
+	}
+	result := tx.Model(&model.InviteCode{}).
+		Where("code = ? AND used_count < max_uses AND (expires_at = 0 OR expires_at > ?)", strings.TrimSpace(inviteCode), time.Now().Unix()).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return newAuthError(AuthErrorValidation, "邀请码无效、已被使用或已过期")
+	}
+	return nil
+}
+
+// CreateInviteCode 管理员创建邀请码，maxUses 为可使用次数，expiresAt 为过期时间 (Unix 秒，0 表示永不过期)
+func CreateInviteCode(adminID uint, maxUses int, expiresAt int64) (*model.InviteCode, error) {
+	if maxUses <= 0 {
+		maxUses = 1
+	}
+	if expiresAt != 0 && expiresAt <= time.Now().Unix() {
+		return nil, newAuthError(AuthErrorValidation, "过期时间必须晚于当前时间")
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	invite := model.InviteCode{
+		Code:      strings.ToUpper(hex.EncodeToString(b)),
+		CreatedBy: adminID,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := db.DB.Create(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListInviteCodes 分页查询邀请码，按创建时间倒序
+func ListInviteCodes(page, pageSize int) ([]model.InviteCode, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	var total int64
+	if err := db.DB.Model(&model.InviteCode{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var codes []model.InviteCode
+	err := db.DB.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&codes).Error
+	return codes, total, err
+}
+
+// DeleteInviteCode 删除邀请码
+func DeleteInviteCode(id uint) error {
+	result := db.DB.Delete(&model.InviteCode{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return newAuthError(AuthErrorNotFound, "邀请码不存在")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"strings"
+	"time"
+)
+
+// LoginAttemptStatus 登录失败计数状态
+type LoginAttemptStatus struct {
+	Enabled     bool      // 是否启用了登录失败锁定
+	Remaining   int       // 锁定前剩余可尝试次数
+	LockedUntil time.Time // 锁定截止时间 (零值表示未锁定)
+}
+
+// Locked 当前是否处于锁定状态
+func (s LoginAttemptStatus) Locked() bool {
+	return !s.LockedUntil.IsZero() && time.Now().Before(s.LockedUntil)
+}
+
+var (
+	// loginFailureLimiter 统计锁定窗口内的登录失败次数，loginLockLimiter 记录达到上限后的临时锁定
+	// Key: 规范化后的账号|客户端 IP (见 loginAttemptKey)
+	loginFailureLimiter = NewRateLimiter()
+	loginLockLimiter    = NewRateLimiter()
+)
+
+func loginLockoutPolicy() (int, time.Duration) {
+	maxAttempts := GetInt(consts.ConfigLoginMaxAttempts)
+	minutes := GetInt(consts.ConfigLoginLockoutMinutes)
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return maxAttempts, time.Duration(minutes) * time.Minute
+}
+
+// loginAttemptKeyPrefix 返回账号的计数 key 前缀，用于清除该账号在所有 IP 上的记录
+func loginAttemptKeyPrefix(account string) string {
+	return strings.ToLower(strings.TrimSpace(account)) + "|"
+}
+
+// loginAttemptKey 按账号与客户端 IP 计数：他人从其他 IP 反复输错密码不会锁住账号本人的登录
+func loginAttemptKey(account, ip string) string {
+	return loginAttemptKeyPrefix(account) + ip
+}
+
+// GetLoginAttemptStatus 获取从 ip 登录 account 当前的失败状态
+func GetLoginAttemptStatus(account, ip string) LoginAttemptStatus {
+	maxAttempts, window := loginLockoutPolicy()
+	if maxAttempts <= 0 {
+		return LoginAttemptStatus{}
+	}
+	key := loginAttemptKey(account, ip)
+
+	if _, lockedFor := loginLockLimiter.Peek(key, 1, window); lockedFor > 0 {
+		return LoginAttemptStatus{Enabled: true, LockedUntil: time.Now().Add(lockedFor)}
+	}
+	remaining, _ := loginFailureLimiter.Peek(key, maxAttempts, window)
+	return LoginAttemptStatus{Enabled: true, Remaining: remaining}
+}
+
+// RecordLoginFailure 记录一次从 ip 登录 account 的失败，锁定窗口内的失败次数达到上限后临时锁定该 IP 对该账号的登录
+func RecordLoginFailure(account, ip string) LoginAttemptStatus {
+	maxAttempts, window := loginLockoutPolicy()
+	if maxAttempts <= 0 {
+		return LoginAttemptStatus{}
+	}
+	key := loginAttemptKey(account, ip)
+
+	loginFailureLimiter.Allow(key, maxAttempts, window)
+	if remaining, _ := loginFailureLimiter.Peek(key, maxAttempts, window); remaining == 0 {
+		// 锁定从达到上限时开始计时，锁定结束后重新计数
+		loginLockLimiter.Allow(key, 1, window)
+		loginFailureLimiter.Reset(key)
+	}
+	return GetLoginAttemptStatus(account, ip)
+}
+
+// ResetLoginFailures 登录成功后清除从 ip 登录 account 的失败记录
+func ResetLoginFailures(account, ip string) {
+	key := loginAttemptKey(account, ip)
+	loginFailureLimiter.Reset(key)
+	loginLockLimiter.Reset(key)
+}
+
+// ResetAllLoginFailures 清除 account 在所有 IP 上的失败记录与临时锁定 (如管理员解锁账号时)
+func ResetAllLoginFailures(account string) {
+	prefix := loginAttemptKeyPrefix(account)
+	loginFailureLimiter.ResetPrefix(prefix)
+	loginLockLimiter.ResetPrefix(prefix)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"testing"
+)
+
+func TestLoginAttemptLockout(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigLoginMaxAttempts: "3", consts.ConfigLoginLockoutMinutes: "15"})
+	const ip = "203.0.113.7"
+
+	if status := GetLoginAttemptStatus("alice", ip); !status.Enabled || status.Remaining != 3 || status.Locked() {
+		t.Fatalf("initial status = %+v", status)
+	}
+	RecordLoginFailure("Alice", ip)
+	if status := RecordLoginFailure(" alice ", ip); status.Remaining != 1 || status.Locked() {
+		t.Fatalf("after 2 failures status = %+v", status)
+	}
+	status := RecordLoginFailure("alice", ip)
+	if !status.Locked() {
+		t.Fatalf("not locked after reaching the limit: %+v", status)
+	}
+	if !GetLoginAttemptStatus("ALICE", ip).Locked() {
+		t.Fatal("lock not reported for the same normalized account")
+	}
+
+	ResetLoginFailures("alice", ip)
+	if status := GetLoginAttemptStatus("alice", ip); status.Locked() || status.Remaining != 3 {
+		t.Fatalf("status after reset = %+v", status)
+	}
+}
+
+func TestLoginAttemptLockoutIsPerIP(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigLoginMaxAttempts: "2"})
+	const attacker, owner = "198.51.100.1", "203.0.113.7"
+
+	RecordLoginFailure("alice", attacker)
+	if !RecordLoginFailure("alice", attacker).Locked() {
+		t.Fatal("attacker IP not locked")
+	}
+	if status := GetLoginAttemptStatus("alice", owner); status.Locked() || status.Remaining != 2 {
+		t.Fatalf("failures from another IP affected the owner: %+v", status)
+	}
+
+	ResetAllLoginFailures("Alice")
+	if GetLoginAttemptStatus("alice", attacker).Locked() {
+		t.Fatal("ResetAllLoginFailures did not clear the lock")
+	}
+}
+
+func TestLoginAttemptDisabled(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{consts.ConfigLoginMaxAttempts: "0"})
+	for i := 0; i < 10; i++ {
+		if status := RecordLoginFailure("bob", "203.0.113.7"); status.Enabled || status.Locked() {
+			t.Fatalf("lockout active while disabled: %+v", status)
+		}
+	}
+}
+
+func TestLoginRevealAttemptsDefaultsOff(t *testing.T) {
+	setupTestDB(t)
+	if GetBool(consts.ConfigLoginRevealAttempts) {
+		t.Fatal("login_reveal_attempts must default to false")
+	}
+}
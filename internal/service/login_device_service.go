@@ -0,0 +1,95 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// deviceFingerprint 由浏览器类型、操作系统与 IP 所在网段 (IPv4 /24，IPv6 /64) 计算设备指纹
+// 不含浏览器版本号，浏览器升级后不视为新设备；按网段计算，同一网络内 IP 变化 (如 DHCP 重新分配) 也不视为新设备
+func deviceFingerprint(userAgent, ip string) string {
+	browser, os := utils.ParseUserAgent(userAgent)
+	sum := sha256.Sum256([]byte(browser + "|" + os + "|" + deviceNetwork(ip)))
+	return hex.EncodeToString(sum[:])
+}
+
+// legacyDeviceFingerprint 按完整 User-Agent 计算的旧版设备指纹，用于识别改用新指纹前记录的设备
+func legacyDeviceFingerprint(userAgent, ip string) string {
+	sum := sha256.Sum256([]byte(userAgent + "|" + deviceNetwork(ip)))
+	return hex.EncodeToString(sum[:])
+}
+
+// deviceNetwork 返回 IP 所在网段，无法解析时原样返回
+func deviceNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String()
+}
+
+// recordLoginDevice 记录用户本次登录的设备，返回是否为该用户的新设备
+// 旧版指纹记录的设备会改写为新指纹，不视为新设备
+func recordLoginDevice(userID uint, meta SessionMeta, now time.Time) (isNew bool, err error) {
+	userAgent := meta.UserAgent
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	fingerprint := deviceFingerprint(meta.UserAgent, meta.IP)
+	seen := map[string]interface{}{"user_agent": userAgent, "ip": meta.IP, "last_seen_at": now.Unix()}
+
+	result := db.DB.Model(&model.KnownDevice{}).Where("user_id = ? AND fingerprint = ?", userID, fingerprint).Updates(seen)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return false, result.Error
+	}
+
+	seen["fingerprint"] = fingerprint
+	result = db.DB.Model(&model.KnownDevice{}).
+		Where("user_id = ? AND fingerprint = ?", userID, legacyDeviceFingerprint(meta.UserAgent, meta.IP)).Updates(seen)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return false, result.Error
+	}
+
+	// 并发登录同一设备时只有一个请求插入成功，其余视为已知设备
+	result = db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.KnownDevice{
+		UserID:      userID,
+		Fingerprint: fingerprint,
+		UserAgent:   userAgent,
+		IP:          meta.IP,
+		FirstSeenAt: now.Unix(),
+		LastSeenAt:  now.Unix(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// notifyLoginDevice 登录成功后记录设备，开启 ConfigNotifyNewDevice 时在新设备登录时发送提醒邮件
+// 用户的第一台设备同样发送提醒；失败只记录日志，不影响登录
+func notifyLoginDevice(user *model.User, meta SessionMeta) {
+	now := time.Now()
+	isNew, err := recordLoginDevice(user.ID, meta, now)
+	if err != nil {
+		log.Printf("Record login device error: %v\n", err)
+		return
+	}
+	if !isNew || user.Email == "" || !GetBool(consts.ConfigNotifyNewDevice) {
+		return
+	}
+	if err := SendNewDeviceLoginEmail(user.Email, user.Username, meta.IP, meta.UserAgent, now, user.Locale); err != nil {
+		log.Printf("Send new device login email error: %v\n", err)
+	}
+}
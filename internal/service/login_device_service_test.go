@@ -0,0 +1,68 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+const (
+	chrome125 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36"
+	chrome126 = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	firefox   = "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0"
+)
+
+func TestNotifyLoginDeviceNotifiesFirstAndNewDevices(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	setTestSettings(t, map[string]string{consts.ConfigNotifyNewDevice: "true"})
+	user := createTestUser(t, "traveller")
+
+	notifyLoginDevice(&user, SessionMeta{UserAgent: chrome125, IP: "203.0.113.10"})
+	if n := len(mailer.Sent()); n != 1 {
+		t.Fatalf("first device should be notified, sent %d", n)
+	}
+
+	// 浏览器升级、同网段换 IP 不视为新设备
+	notifyLoginDevice(&user, SessionMeta{UserAgent: chrome126, IP: "203.0.113.20"})
+	if n := len(mailer.Sent()); n != 1 {
+		t.Fatalf("browser update should not be notified, sent %d", n)
+	}
+
+	notifyLoginDevice(&user, SessionMeta{UserAgent: firefox, IP: "203.0.113.10"})
+	if n := len(mailer.Sent()); n != 2 {
+		t.Fatalf("new browser should be notified, sent %d", n)
+	}
+
+	var devices []model.KnownDevice
+	db.DB.Where("user_id = ?", user.ID).Order("id").Find(&devices)
+	if len(devices) != 2 || devices[0].UserAgent != chrome126 || devices[0].IP != "203.0.113.20" {
+		t.Fatalf("unexpected devices: %+v", devices)
+	}
+}
+
+func TestRecordLoginDeviceMigratesLegacyFingerprint(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "legacy")
+	db.DB.Create(&model.KnownDevice{
+		UserID:      user.ID,
+		Fingerprint: legacyDeviceFingerprint(chrome125, "198.51.100.7"),
+		UserAgent:   chrome125,
+		IP:          "198.51.100.7",
+		FirstSeenAt: 1,
+		LastSeenAt:  1,
+	})
+
+	isNew, err := recordLoginDevice(user.ID, SessionMeta{UserAgent: chrome125, IP: "198.51.100.7"}, time.Now())
+	if err != nil || isNew {
+		t.Fatalf("legacy device should be recognised, isNew=%v err=%v", isNew, err)
+	}
+
+	var device model.KnownDevice
+	db.DB.Where("user_id = ?", user.ID).First(&device)
+	if device.Fingerprint != deviceFingerprint(chrome125, "198.51.100.7") {
+		t.Fatal("legacy fingerprint should be rewritten")
+	}
+}
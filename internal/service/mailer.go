@@ -0,0 +1,219 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"perfect-pic-server/internal/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer 邮件发送接口
+// textBody 为纯文本备选内容，留空时仅发送 HTML
+type Mailer interface {
+	Send(to, subject, htmlBody, textBody string) error
+}
+
+// smtpTimeout SMTP 连接与会话的整体超时时间
+const smtpTimeout = 15 * time.Second
+
+var (
+	mailerOverride Mailer
+	mailerMu       sync.RWMutex
+)
+
+// SetMailer 替换全局邮件发送实现 (传入 nil 恢复为按配置选择)
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	mailerOverride = m
+}
+
+// GetMailer 获取当前邮件发送实现
+// 配置了 SMTP Host 时使用 SMTP 发送；未配置时开发模式下仅打印日志，生产模式下静默丢弃
+func GetMailer() Mailer {
+	mailerMu.RLock()
+	override := mailerOverride
+	mailerMu.RUnlock()
+	if override != nil {
+		return override
+	}
+
+	cfg := config.Get()
+	if cfg.SMTP.Host != "" {
+		return &SMTPMailer{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+			SSL:      cfg.SMTP.SSL,
+			Timeout:  smtpTimeout,
+		}
+	}
+	if cfg.Server.Mode != "release" {
+		return LogMailer{}
+	}
+	return noopMailer{}
+}
+
+// SMTPMailer 基于 SMTP 的邮件发送实现
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	SSL      bool // true: 直接使用 TLS 连接 (通常为 465 端口)；false: 服务器支持时使用 STARTTLS
+	Timeout  time.Duration
+}
+
+// Send 发送邮件，连接、认证与投递整个过程受 Timeout 限制
+func (m *SMTPMailer) Send(to, subject, htmlBody, textBody string) error {
+	fromHeader, fromAddr, err := formatAddressHeader(m.From)
+	if err != nil {
+		return err
+	}
+	toHeader, toAddr, err := formatAddressHeader(to)
+	if err != nil {
+		return err
+	}
+
+	msg, err := buildEmailMessage(fromHeader, toHeader, subject, htmlBody, textBody)
+	if err != nil {
+		return err
+	}
+
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = smtpTimeout
+	}
+	addr := net.JoinHostPort(m.Host, fmt.Sprintf("%d", m.Port))
+	dialer := &net.Dialer{Timeout: timeout}
+	tlsConfig := &tls.Config{ServerName: m.Host}
+
+	var conn net.Conn
+	if m.SSL {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		log.Printf("[Email] 连接 SMTP 服务器失败: %v", err)
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		log.Printf("[Email] 创建 SMTP 客户端失败: %v", err)
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if !m.SSL {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				log.Printf("[Email] STARTTLS 失败: %v", err)
+				return err
+			}
+		}
+	}
+
+	// 认证
+	if m.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err = client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+				log.Printf("[Email] SMTP认证失败: %v", err)
+				return err
+			}
+		}
+	}
+
+	// 发送流程
+	if err = client.Mail(fromAddr); err != nil {
+		log.Printf("[Email] MAIL FROM 命令失败: %v", err)
+		return err
+	}
+	if err = client.Rcpt(toAddr); err != nil {
+		// 不记录具体邮箱地址，防止日志泄露敏感信息
+		log.Printf("[Email] RCPT TO 命令失败: %v", err)
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		log.Printf("[Email] DATA 命令失败: %v", err)
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		log.Printf("[Email] 写入邮件内容失败: %v", err)
+		return err
+	}
+	if err = w.Close(); err != nil {
+		log.Printf("[Email] 关闭 DATA 失败: %v", err)
+		return err
+	}
+
+	return client.Quit()
+}
+
+// LogMailer 仅将邮件的收件人与主题打印到日志，用于未配置 SMTP 的开发环境
+// 正文中包含验证、重置密码等一次性链接，不写入日志
+type LogMailer struct{}
+
+func (LogMailer) Send(to, subject, htmlBody, textBody string) error {
+	log.Printf("[Email] (未配置 SMTP，仅打印) 收件人: %s 主题: %s", to, subject)
+	return nil
+}
+
+// noopMailer 丢弃所有邮件
+type noopMailer struct{}
+
+func (noopMailer) Send(to, subject, htmlBody, textBody string) error {
+	return nil
+}
+
+// buildEmailMessage 构造邮件报文，提供纯文本内容时使用 multipart/alternative
+func buildEmailMessage(fromHeader, toHeader, subject, htmlBody, textBody string) ([]byte, error) {
+	// 对 Subject 进行 MIME 编码，防止中文乱码或被拒收
+	encodedSubject := mime.BEncoding.Encode("UTF-8", subject)
+	// 添加 Date 头
+	dateStr := time.Now().Format(time.RFC1123Z)
+
+	header := fmt.Sprintf("Date: %s\r\nFrom: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\n",
+		dateStr, fromHeader, toHeader, encodedSubject)
+
+	if textBody == "" {
+		return []byte(header + "Content-Type: text/html; charset=UTF-8\r\n\r\n" + htmlBody), nil
+	}
+
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=\"%s\"\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, textBody)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, htmlBody)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return []byte(b.String()), nil
+}
+
+func randomBoundary() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("生成邮件分隔符失败")
+	}
+	return hex.EncodeToString(buf), nil
+}
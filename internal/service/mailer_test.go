@@ -0,0 +1,40 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(io.Discard) })
+
+	link := "https://example.com/auth/password-reset?token=secret-token"
+	if err := (LogMailer{}).Send("alice@example.com", "重置密码", "<a href=\""+link+"\">重置</a>", link); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "alice@example.com") || !strings.Contains(out, "重置密码") {
+		t.Fatalf("log missing recipient or subject: %q", out)
+	}
+	if strings.Contains(out, "secret-token") {
+		t.Fatalf("log leaked the email body: %q", out)
+	}
+}
+
+func TestBuildEmailMessageAlternative(t *testing.T) {
+	msg, err := buildEmailMessage("a@example.com", "b@example.com", "主题", "<p>html</p>", "text")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	s := string(msg)
+	for _, want := range []string{"multipart/alternative", "text/plain", "<p>html</p>", "Subject: =?UTF-8?b?"} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("message missing %q:\n%s", want, s)
+		}
+	}
+}
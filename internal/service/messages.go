@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MessageID 面向用户的提示消息标识，与语言无关，用于在处理层按请求语言渲染
+type MessageID string
+
+const (
+	MsgStorageQuotaExceeded        MessageID = "storage.quota_exceeded"         // 参数: 已用字节数, 剩余字节数
+	MsgStorageQuotaExceededReplace MessageID = "storage.quota_exceeded_replace" // 无参数
+	MsgStorageQuotaExceededUpload  MessageID = "storage.quota_exceeded_upload"  // 无参数
+	MsgStorageQuotaExceededBatch   MessageID = "storage.quota_exceeded_batch"   // 无参数
+	MsgFileTooLarge                MessageID = "upload.file_too_large"          // 参数: 大小上限
+	MsgDailyUploadCountLimit       MessageID = "upload.daily_count_limit"       // 参数: 每日张数上限, 重置时间
+	MsgDailyUploadBytesLimit       MessageID = "upload.daily_bytes_limit"       // 参数: 每日字节上限, 今日已上传字节数, 重置时间
+
+	MsgLoginTooManyAttempts  MessageID = "auth.too_many_attempts"
+	MsgLoginInvalid          MessageID = "auth.invalid_credentials"
+	MsgAccountBanned         MessageID = "auth.account_banned"
+	MsgAccountDisabled       MessageID = "auth.account_disabled"
+	MsgAccountLocked         MessageID = "auth.account_locked"
+	MsgEmailNotVerified      MessageID = "auth.email_not_verified"
+	MsgLoginFailed           MessageID = "auth.login_failed"
+	MsgVerificationCooldown  MessageID = "auth.verification_cooldown" // 参数: 剩余秒数
+	MsgResetAccountForbidden MessageID = "auth.reset_account_forbidden"
+	MsgCurrentPasswordWrong  MessageID = "auth.current_password_wrong"
+
+	MsgAccountDeletionPending MessageID = "auth.account_deletion_pending" // 参数: 计划删除时间
+)
+
+// messageCatalog 各语言的消息模板 (fmt 格式)，中文为缺省语言，其他语言缺失的条目回退到中文
+var messageCatalog = map[string]map[MessageID]string{
+	LocaleZhCN: {
+		MsgStorageQuotaExceeded:        "存储空间不足，上传失败。当前已用: %d B, 剩余: %d B",
+		MsgStorageQuotaExceededReplace: "存储空间不足，替换失败",
+		MsgStorageQuotaExceededUpload:  "存储空间不足，上传失败",
+		MsgStorageQuotaExceededBatch:   "存储空间不足，已停止上传剩余文件",
+		MsgFileTooLarge:                "文件过大，文件大小不能超过 %s",
+		MsgDailyUploadCountLimit:       "今日上传已达上限 (每天最多 %d 张)，将于 %s 重置",
+		MsgDailyUploadBytesLimit:       "今日上传已达上限 (每天最多 %s，今日已上传 %d B)，将于 %s 重置",
+
+		MsgLoginTooManyAttempts:  "登录失败次数过多，请稍后再试",
+		MsgLoginInvalid:          "用户名或密码错误",
+		MsgAccountBanned:         "该账号已被封禁",
+		MsgAccountDisabled:       "该账号已停用",
+		MsgAccountLocked:         "该账号因登录失败次数过多已被锁定，请使用邮件中的解锁链接或联系管理员解锁",
+		MsgEmailNotVerified:      "请先验证邮箱后再登录",
+		MsgLoginFailed:           "登录失败，请稍后重试",
+		MsgVerificationCooldown:  "发送过于频繁，请 %d 秒后再试",
+		MsgResetAccountForbidden: "该账号已被封禁或停用，无法重置密码",
+		MsgCurrentPasswordWrong:  "当前密码错误",
+
+		MsgAccountDeletionPending: "该账号已申请注销，将于 %s 删除；如需保留账号，请使用邮件中的撤销链接",
+	},
+	LocaleEn: {
+		MsgStorageQuotaExceeded:        "Not enough storage space, upload failed. Used: %d B, remaining: %d B",
+		MsgStorageQuotaExceededReplace: "Not enough storage space, replace failed",
+		MsgStorageQuotaExceededUpload:  "Not enough storage space, upload failed",
+		MsgStorageQuotaExceededBatch:   "Not enough storage space, the remaining files were not uploaded",
+		MsgFileTooLarge:                "File too large, the size must not exceed %s",
+		MsgDailyUploadCountLimit:       "Daily upload limit reached (at most %d images per day), resets at %s",
+		MsgDailyUploadBytesLimit:       "Daily upload limit reached (at most %s per day, %d B uploaded today), resets at %s",
+
+		MsgLoginTooManyAttempts:  "Too many failed login attempts, please try again later",
+		MsgLoginInvalid:          "Incorrect username or password",
+		MsgAccountBanned:         "This account has been banned",
+		MsgAccountDisabled:       "This account has been disabled",
+		MsgAccountLocked:         "This account has been locked after too many failed logins, use the unlock link in the email or contact an administrator",
+		MsgEmailNotVerified:      "Please verify your email before logging in",
+		MsgLoginFailed:           "Login failed, please try again later",
+		MsgVerificationCooldown:  "Sending too often, please try again in %d seconds",
+		MsgResetAccountForbidden: "This account has been banned or disabled and cannot reset its password",
+		MsgCurrentPasswordWrong:  "The current password is incorrect",
+
+		MsgAccountDeletionPending: "This account is scheduled for deletion on %s; use the cancel link in the email to keep it",
+	},
+}
+
+// LocalizeMessage 按语言渲染消息，语言不支持或缺少条目时回退到中文，中文也缺失时返回消息标识本身
+func LocalizeMessage(locale string, id MessageID, params ...interface{}) string {
+	format, ok := messageCatalog[NormalizeLocale(locale)][id]
+	if !ok {
+		if format, ok = messageCatalog[LocaleZhCN][id]; !ok {
+			return string(id)
+		}
+	}
+	if len(params) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, params...)
+}
+
+// LocalizedError 携带消息标识与参数的业务错误，Error() 返回中文文本
+// Err 为对应的哨兵错误，保持 errors.Is 判断不变
+type LocalizedError struct {
+	ID     MessageID
+	Params []interface{}
+	Err    error
+}
+
+func newLocalizedError(sentinel error, id MessageID, params ...interface{}) *LocalizedError {
+	return &LocalizedError{ID: id, Params: params, Err: sentinel}
+}
+
+func (e *LocalizedError) Error() string {
+	return e.Localize(LocaleZhCN)
+}
+
+func (e *LocalizedError) Unwrap() error {
+	return e.Err
+}
+
+// Localize 按语言渲染错误信息
+func (e *LocalizedError) Localize(locale string) string {
+	return LocalizeMessage(locale, e.ID, e.Params...)
+}
+
+// LocalizeError 按语言渲染错误链中的可本地化错误 (LocalizedError 或带 MessageID 的 AuthError)，
+// 其他错误原样返回 err.Error()
+func LocalizeError(err error, locale string) string {
+	var localized *LocalizedError
+	if errors.As(err, &localized) {
+		return localized.Localize(locale)
+	}
+	if authErr, ok := AsAuthError(err); ok {
+		return authErr.Localize(locale)
+	}
+	return err.Error()
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码哈希方案，存储的哈希通过前缀区分 (bcrypt 为 $2a$/$2b$，argon2id 为 $argon2id$)
+const (
+	PasswordSchemeBcrypt   = "bcrypt"
+	PasswordSchemeArgon2id = "argon2id"
+)
+
+const (
+	argon2idPrefix  = "$argon2id$"
+	argon2SaltLen   = 16
+	argon2KeyLen    = 32
+	argon2MaxMemory = 1 << 20 // KiB，即 1 GiB
+)
+
+var errInvalidPasswordHash = errors.New("无法识别的密码哈希格式")
+
+// argon2Params Argon2id 的计算参数
+type argon2Params struct {
+	memory      uint32 // KiB
+	iterations  uint32
+	parallelism uint8
+}
+
+// passwordSchemeOf 根据存储的哈希前缀判断其哈希方案
+func passwordSchemeOf(hash string) string {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		return PasswordSchemeArgon2id
+	}
+	return PasswordSchemeBcrypt
+}
+
+// PasswordHashScheme 返回新密码使用的哈希方案 (ConfigPasswordHashScheme)
+func PasswordHashScheme() string {
+	if GetString(consts.ConfigPasswordHashScheme) == PasswordSchemeArgon2id {
+		return PasswordSchemeArgon2id
+	}
+	return PasswordSchemeBcrypt
+}
+
+// BcryptCost 返回当前配置的 bcrypt 计算成本，超出 bcrypt 允许范围时取最近的边界值
+func BcryptCost() int {
+	cost := GetInt(consts.ConfigBcryptCost)
+	if cost < bcrypt.MinCost {
+		return bcrypt.MinCost
+	}
+	if cost > bcrypt.MaxCost {
+		return bcrypt.MaxCost
+	}
+	return cost
+}
+
+// configuredArgon2Params 返回当前配置的 Argon2id 参数，未配置或超出范围时取安全的默认值/边界值
+func configuredArgon2Params() argon2Params {
+	clamp := func(v, def, min, max int) int {
+		if v <= 0 {
+			return def
+		}
+		if v < min {
+			return min
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+	return argon2Params{
+		memory:      uint32(clamp(GetInt(consts.ConfigArgon2Memory), 64*1024, 8*1024, argon2MaxMemory)),
+		iterations:  uint32(clamp(GetInt(consts.ConfigArgon2Iterations), 3, 1, 10)),
+		parallelism: uint8(clamp(GetInt(consts.ConfigArgon2Parallelism), 2, 1, 16)),
+	}
+}
+
+// HashPassword 使用当前配置的哈希方案与参数计算密码哈希
+func HashPassword(password string) (string, error) {
+	if PasswordHashScheme() == PasswordSchemeArgon2id {
+		return hashArgon2id(password, configuredArgon2Params())
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// VerifyPassword 按存储哈希自身的方案校验密码是否匹配
+func VerifyPassword(hash, password string) bool {
+	if passwordSchemeOf(hash) == PasswordSchemeArgon2id {
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(computed, key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// hashArgon2id 计算 Argon2id 哈希，编码为 $argon2id$v=19$m=<KiB>,t=<次数>,p=<并行度>$<salt>$<hash>
+func hashArgon2id(password string, params argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// parseArgon2id 解析 Argon2id 哈希字符串
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != PasswordSchemeArgon2id {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	if params.memory == 0 || params.memory > argon2MaxMemory || params.iterations == 0 || params.parallelism == 0 {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	return params, salt, key, nil
+}
+
+// passwordNeedsRehash 判断存储的哈希是否需要按当前配置重新计算：
+// 同一方案下计算强度低于配置时升级；方案与配置不一致且开启了 ConfigPasswordMigrateOnLogin 时迁移
+func passwordNeedsRehash(hash string) bool {
+	scheme := passwordSchemeOf(hash)
+	if scheme != PasswordHashScheme() {
+		return GetBool(consts.ConfigPasswordMigrateOnLogin)
+	}
+	if scheme == PasswordSchemeArgon2id {
+		params, _, _, err := parseArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		target := configuredArgon2Params()
+		return params.memory < target.memory || params.iterations < target.iterations || params.parallelism < target.parallelism
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost < BcryptCost()
+}
+
+// rehashPasswordIfNeeded 密码校验通过后，若已存哈希需要升级或迁移则重新计算并写回
+// 失败时只记录日志，不影响本次登录
+func rehashPasswordIfNeeded(user *model.User, password string) {
+	if !passwordNeedsRehash(user.Password) {
+		return
+	}
+	hashed, err := HashPassword(password)
+	if err != nil {
+		log.Printf("Rehash password error: %v\n", err)
+		return
+	}
+	// 以旧哈希为条件更新，避免覆盖并发修改的新密码
+	result := db.DB.Model(&model.User{}).Where("id = ? AND password = ?", user.ID, user.Password).
+		UpdateColumn("password", hashed)
+	if result.Error != nil {
+		log.Printf("Update rehashed password error: %v\n", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		user.Password = hashed
+	}
+}
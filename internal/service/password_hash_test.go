@@ -0,0 +1,159 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptCostIsClamped(t *testing.T) {
+	setupTestDB(t)
+	if got := BcryptCost(); got != 10 {
+		t.Fatalf("default cost = %d, want 10", got)
+	}
+
+	setStoredSetting(t, consts.ConfigBcryptCost, "2")
+	InvalidateKey(consts.ConfigBcryptCost)
+	if got := BcryptCost(); got != bcrypt.MinCost {
+		t.Fatalf("cost below range = %d, want %d", got, bcrypt.MinCost)
+	}
+	setStoredSetting(t, consts.ConfigBcryptCost, "40")
+	InvalidateKey(consts.ConfigBcryptCost)
+	if got := BcryptCost(); got != bcrypt.MaxCost {
+		t.Fatalf("cost above range = %d, want %d", got, bcrypt.MaxCost)
+	}
+
+	if err := UpdateSettings(map[string]string{consts.ConfigBcryptCost: "3"}); err == nil {
+		t.Fatal("cost below bcrypt.MinCost should be rejected")
+	}
+}
+
+func TestLoginUpgradesWeakBcryptHash(t *testing.T) {
+	setupTestDB(t)
+	weak, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	user := createTestUser(t, "legacy", func(u *model.User) { u.Password = string(weak) })
+
+	if _, err := LoginUser("legacy", testPassword, SessionMeta{IP: "127.0.0.1"}); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if cost, err := bcrypt.Cost([]byte(stored.Password)); err != nil || cost != BcryptCost() {
+		t.Fatalf("hash cost after login = %d, %v; want %d", cost, err, BcryptCost())
+	}
+
+	// 升级后的哈希仍可登录
+	if _, err := LoginUser("legacy", testPassword, SessionMeta{IP: "127.0.0.1"}); err != nil {
+		t.Fatalf("login after rehash: %v", err)
+	}
+}
+
+func TestRehashDoesNotOverwriteConcurrentPasswordChange(t *testing.T) {
+	setupTestDB(t)
+	weak, _ := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	user := createTestUser(t, "racer", func(u *model.User) { u.Password = string(weak) })
+
+	// 校验通过后、写回前密码已被修改
+	changed, _ := HashPassword("N3wPassw0rd!")
+	db.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("password", changed)
+	rehashPasswordIfNeeded(&user, testPassword)
+
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Password != changed {
+		t.Fatal("rehash overwrote a concurrently changed password")
+	}
+}
+
+// useArgon2id 将新密码的哈希方案切换为 Argon2id，并使用最小内存开销以加快测试
+func useArgon2id(t *testing.T) {
+	t.Helper()
+	setTestSettings(t, map[string]string{
+		consts.ConfigPasswordHashScheme: PasswordSchemeArgon2id,
+		consts.ConfigArgon2Memory:       "8192",
+		consts.ConfigArgon2Iterations:   "1",
+		consts.ConfigArgon2Parallelism:  "1",
+	})
+}
+
+func TestArgon2idHashRoundTrip(t *testing.T) {
+	setupTestDB(t)
+	useArgon2id(t)
+
+	hash, err := HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Fatalf("unexpected PHC string: %s", hash)
+	}
+	if !VerifyPassword(hash, testPassword) {
+		t.Fatal("correct password rejected")
+	}
+	if VerifyPassword(hash, "wrong") {
+		t.Fatal("wrong password accepted")
+	}
+	if again, _ := HashPassword(testPassword); again == hash {
+		t.Fatal("hashes should use a random salt")
+	}
+
+	// 解析失败的哈希一律视为不匹配
+	for _, bad := range []string{
+		"$argon2id$v=18$m=8192,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdA",
+	} {
+		if VerifyPassword(bad, testPassword) {
+			t.Errorf("malformed hash %q accepted", bad)
+		}
+	}
+}
+
+func TestLoginMigratesPasswordScheme(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "migrant")
+	useArgon2id(t)
+
+	if _, err := LoginUser("migrant", testPassword, SessionMeta{IP: "127.0.0.1"}); err != nil {
+		t.Fatalf("login with bcrypt hash: %v", err)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if passwordSchemeOf(stored.Password) != PasswordSchemeArgon2id {
+		t.Fatalf("hash not migrated: %s", stored.Password)
+	}
+
+	// 同一方案下参数提高后升级
+	setTestSettings(t, map[string]string{consts.ConfigArgon2Iterations: "2"})
+	if _, err := LoginUser("migrant", testPassword, SessionMeta{IP: "127.0.0.1"}); err != nil {
+		t.Fatalf("login with argon2id hash: %v", err)
+	}
+	db.DB.First(&stored, user.ID)
+	if !strings.Contains(stored.Password, "t=2,") {
+		t.Fatalf("hash not upgraded: %s", stored.Password)
+	}
+}
+
+func TestLoginKeepsSchemeWhenMigrationDisabled(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "settler")
+	useArgon2id(t)
+	setTestSettings(t, map[string]string{consts.ConfigPasswordMigrateOnLogin: "false"})
+
+	if _, err := LoginUser("settler", testPassword, SessionMeta{IP: "127.0.0.1"}); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	var stored model.User
+	db.DB.First(&stored, user.ID)
+	if stored.Password != user.Password {
+		t.Fatal("hash should be left alone when migration is disabled")
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"perfect-pic-server/internal/consts"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PasswordPolicyError 密码不符合策略时返回的错误，Rule 标识未通过的规则，Message 可直接返回给用户
+type PasswordPolicyError struct {
+	Rule    string
+	Message string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Message
+}
+
+// AsPasswordPolicyError 从错误链中提取 PasswordPolicyError
+func AsPasswordPolicyError(err error) (*PasswordPolicyError, bool) {
+	var policyErr *PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return policyErr, true
+	}
+	return nil, false
+}
+
+// PasswordRule 密码校验规则，不符合时返回 *PasswordPolicyError
+type PasswordRule func(password string) error
+
+var (
+	extraPasswordRules   []PasswordRule
+	extraPasswordRulesMu sync.RWMutex
+)
+
+// RegisterPasswordRule 注册额外的密码校验规则，在内置规则全部通过后按注册顺序执行
+func RegisterPasswordRule(rule PasswordRule) {
+	extraPasswordRulesMu.Lock()
+	defer extraPasswordRulesMu.Unlock()
+	extraPasswordRules = append(extraPasswordRules, rule)
+}
+
+var passwordCharsetRegexp = regexp.MustCompile(`^[a-zA-Z0-9[:punct:]]+$`)
+
+// commonPasswords 内置常见弱密码列表 (开启 ConfigPasswordDenyCommon 时生效)
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "12345678", "123456789",
+	"1234567890", "qwerty123", "qwertyuiop", "abc12345", "abcd1234", "a1234567",
+	"iloveyou", "admin123", "admin888", "welcome1", "letmein1", "11111111",
+	"88888888", "1q2w3e4r", "1qaz2wsx", "zaq12wsx", "qwe123456", "aa123456",
+}
+
+// ValidatePasswordPolicy 按当前设置的密码策略校验密码，用于注册、重置与修改密码
+// 返回的 *PasswordPolicyError 说明具体未通过的规则
+func ValidatePasswordPolicy(password string) error {
+	minLength := GetInt(consts.ConfigPasswordMinLength)
+	if minLength <= 0 {
+		minLength = 8
+	}
+	if len(password) < minLength {
+		return &PasswordPolicyError{Rule: "min_length", Message: fmt.Sprintf("密码最少%d位", minLength)}
+	}
+
+	if !passwordCharsetRegexp.MatchString(password) {
+		return &PasswordPolicyError{Rule: "charset", Message: "密码只能包含英文大小写、数字和符号"}
+	}
+
+	var hasLetter, hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasLetter, hasUpper = true, true
+		case unicode.IsLower(r):
+			hasLetter, hasLower = true, true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	if !hasLetter {
+		return &PasswordPolicyError{Rule: "require_letter", Message: "密码必须包含至少一个字母"}
+	}
+	if GetBool(consts.ConfigPasswordRequireDigit) && !hasDigit {
+		return &PasswordPolicyError{Rule: "require_digit", Message: "密码必须包含至少一个数字"}
+	}
+	if GetBool(consts.ConfigPasswordRequireUpper) && !hasUpper {
+		return &PasswordPolicyError{Rule: "require_upper", Message: "密码必须包含至少一个大写字母"}
+	}
+	if GetBool(consts.ConfigPasswordRequireLower) && !hasLower {
+		return &PasswordPolicyError{Rule: "require_lower", Message: "密码必须包含至少一个小写字母"}
+	}
+	if GetBool(consts.ConfigPasswordRequireSymbol) && !hasSymbol {
+		return &PasswordPolicyError{Rule: "require_symbol", Message: "密码必须包含至少一个符号"}
+	}
+
+	if isDeniedPassword(password) {
+		return &PasswordPolicyError{Rule: "denylist", Message: "密码过于常见，请更换一个更安全的密码"}
+	}
+
+	extraPasswordRulesMu.RLock()
+	rules := extraPasswordRules
+	extraPasswordRulesMu.RUnlock()
+	for _, rule := range rules {
+		if err := rule(password); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isDeniedPassword 检查密码是否在禁用列表中 (忽略大小写)
+func isDeniedPassword(password string) bool {
+	if GetBool(consts.ConfigPasswordDenyCommon) {
+		for _, p := range commonPasswords {
+			if strings.EqualFold(password, p) {
+				return true
+			}
+		}
+	}
+
+	for _, p := range strings.Split(GetString(consts.ConfigPasswordDenylist), ",") {
+		p = strings.TrimSpace(p)
+		if p != "" && strings.EqualFold(password, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"testing"
+)
+
+func TestValidatePasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		password string
+		wantRule string
+	}{
+		{name: "default accepts letters and digits", password: "abc12345"},
+		{name: "default min length", password: "ab12", wantRule: "min_length"},
+		{name: "custom min length", settings: map[string]string{consts.ConfigPasswordMinLength: "12"}, password: "abcd12345", wantRule: "min_length"},
+		{name: "charset", password: "密码abc12345", wantRule: "charset"},
+		{name: "letter always required", password: "12345678!", wantRule: "require_letter"},
+		{name: "digit required by default", password: "abcdefgh", wantRule: "require_digit"},
+		{name: "digit toggle off", settings: map[string]string{consts.ConfigPasswordRequireDigit: "false"}, password: "abcdefgh"},
+		{name: "upper toggle", settings: map[string]string{consts.ConfigPasswordRequireUpper: "true"}, password: "abc12345", wantRule: "require_upper"},
+		{name: "upper satisfied", settings: map[string]string{consts.ConfigPasswordRequireUpper: "true"}, password: "Abc12345"},
+		{name: "lower toggle", settings: map[string]string{consts.ConfigPasswordRequireLower: "true"}, password: "ABC12345", wantRule: "require_lower"},
+		{name: "lower satisfied", settings: map[string]string{consts.ConfigPasswordRequireLower: "true"}, password: "ABc12345"},
+		{name: "symbol toggle", settings: map[string]string{consts.ConfigPasswordRequireSymbol: "true"}, password: "abc12345", wantRule: "require_symbol"},
+		{name: "symbol satisfied", settings: map[string]string{consts.ConfigPasswordRequireSymbol: "true"}, password: "abc1234!"},
+		{name: "common passwords allowed by default", password: "Password123"},
+		{name: "common passwords denied", settings: map[string]string{consts.ConfigPasswordDenyCommon: "true"}, password: "Password123", wantRule: "denylist"},
+		{name: "custom denylist", settings: map[string]string{consts.ConfigPasswordDenylist: "perfect2026, hunter22"}, password: "HUNTER22", wantRule: "denylist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			if tt.settings != nil {
+				setTestSettings(t, tt.settings)
+			}
+
+			err := ValidatePasswordPolicy(tt.password)
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Fatalf("ValidatePasswordPolicy(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+			policyErr, ok := AsPasswordPolicyError(err)
+			if !ok {
+				t.Fatalf("ValidatePasswordPolicy(%q) = %v, want rule %q", tt.password, err, tt.wantRule)
+			}
+			if policyErr.Rule != tt.wantRule || policyErr.Message == "" {
+				t.Fatalf("ValidatePasswordPolicy(%q) failed rule %q (%q), want %q", tt.password, policyErr.Rule, policyErr.Message, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestRegisterPasswordRule(t *testing.T) {
+	setupTestDB(t)
+
+	extraPasswordRulesMu.Lock()
+	prev := extraPasswordRules
+	extraPasswordRulesMu.Unlock()
+	t.Cleanup(func() {
+		extraPasswordRulesMu.Lock()
+		extraPasswordRules = prev
+		extraPasswordRulesMu.Unlock()
+	})
+
+	RegisterPasswordRule(func(password string) error {
+		if password == "Company2026" {
+			return &PasswordPolicyError{Rule: "company", Message: "密码不能使用公司名称"}
+		}
+		return nil
+	})
+
+	if err := ValidatePasswordPolicy("Company2026"); err == nil {
+		t.Fatal("registered rule should reject the password")
+	} else if policyErr, ok := AsPasswordPolicyError(err); !ok || policyErr.Rule != "company" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := ValidatePasswordPolicy("Other2026"); err != nil {
+		t.Fatalf("other passwords should pass: %v", err)
+	}
+	// 内置规则先于注册的规则执行
+	if policyErr, ok := AsPasswordPolicyError(ValidatePasswordPolicy("short")); !ok || policyErr.Rule != "min_length" {
+		t.Fatalf("built-in rules should run first, got %+v", policyErr)
+	}
+}
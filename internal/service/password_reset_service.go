@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// passwordResetLimiter 按规范化后的邮箱限制重置密码邮件的发送频率
+var passwordResetLimiter = NewRateLimiter()
+
+// RequestPasswordReset 为邮箱对应的用户生成重置密码 Token 并发送重置邮件
+// 为防止探测邮箱是否已注册，邮箱不存在时同样返回 nil；
+// 同一邮箱在冷却期内的重复请求也返回 nil，但不会再次发信；账号被封禁或停用时返回 AuthErrorForbidden
+func RequestPasswordReset(email string) error {
+	var user model.User
+	if err := db.DB.Where("LOWER(email) = ?", utils.NormalizeEmail(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.Status == 2 || user.Status == 3 {
+		return newLocalizedAuthError(AuthErrorForbidden, MsgResetAccountForbidden)
+	}
+
+	cooldown := time.Duration(GetInt(consts.ConfigPasswordResetCooldown)) * time.Second
+	if allowed, _ := passwordResetLimiter.Allow(utils.NormalizeEmail(user.Email), 1, cooldown); !allowed {
+		return nil
+	}
+
+	token, err := GenerateForgetPasswordToken(user.ID)
+	if err != nil {
+		return newAuthError(AuthErrorInternal, "生成重置链接失败，请稍后重试")
+	}
+
+	// 前端重置密码页面: /auth/reset-password?token=xxx
+	resetUrl := fmt.Sprintf("%s/auth/reset-password?token=%s", getBaseURL(), token)
+
+	// 发送邮件 (进入异步队列)，失败时只记录日志，不向请求方暴露
+	if err := SendPasswordResetEmail(user.Email, user.Username, resetUrl, user.Locale); err != nil {
+		log.Printf("Send password reset email error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/model"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForgetPasswordTokenOnePerUser(t *testing.T) {
+	setupTestDB(t)
+
+	first, err := GenerateForgetPasswordToken(1)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	other, _ := GenerateForgetPasswordToken(2)
+	second, _ := GenerateForgetPasswordToken(1)
+
+	if _, ok := VerifyForgetPasswordToken(first); ok {
+		t.Fatal("older token still valid after a new one was issued")
+	}
+	if id, ok := VerifyForgetPasswordToken(second); !ok || id != 1 {
+		t.Fatalf("latest token = %d, %v", id, ok)
+	}
+	if _, ok := VerifyForgetPasswordToken(second); ok {
+		t.Fatal("token reusable after verification")
+	}
+	if id, ok := VerifyForgetPasswordToken(other); !ok || id != 2 {
+		t.Fatalf("token of another user = %d, %v", id, ok)
+	}
+}
+
+func TestRevokePasswordResetTokens(t *testing.T) {
+	setupTestDB(t)
+	token, _ := GenerateForgetPasswordToken(1)
+	other, _ := GenerateForgetPasswordToken(2)
+
+	RevokePasswordResetTokens(1)
+	if _, ok := VerifyForgetPasswordToken(token); ok {
+		t.Fatal("token valid after revoke")
+	}
+	if _, ok := VerifyForgetPasswordToken(other); !ok {
+		t.Fatal("revoke removed another user's token")
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	setTestSettings(t, map[string]string{consts.ConfigPasswordResetCooldown: "60"})
+	user := createTestUser(t, "alice")
+	createTestUser(t, "banned", func(u *model.User) { u.Status = 2 })
+
+	if err := RequestPasswordReset("nobody@example.com"); err != nil || len(mailer.Sent()) != 0 {
+		t.Fatalf("unknown email: err=%v sent=%d", err, len(mailer.Sent()))
+	}
+	if err := RequestPasswordReset(user.Email); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 || !strings.Contains(sent[0].Text, "/auth/reset-password?token=") {
+		t.Fatalf("reset emails = %+v", sent)
+	}
+	// 冷却期内不重复发送
+	if err := RequestPasswordReset(user.Email); err != nil || len(mailer.Sent()) != 1 {
+		t.Fatalf("cooldown: err=%v sent=%d", err, len(mailer.Sent()))
+	}
+
+	err := RequestPasswordReset("banned@example.com")
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Code != AuthErrorForbidden {
+		t.Fatalf("banned account error = %v", err)
+	}
+}
+
+func TestRequestPasswordResetIgnoresEmailCase(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	createTestUser(t, "alice")
+
+	if err := RequestPasswordReset(" Alice@Example.COM "); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if sent := mailer.Sent(); len(sent) != 1 || sent[0].To != "alice@example.com" {
+		t.Fatalf("reset emails = %+v", sent)
+	}
+}
+
+func TestPasswordResetTTLFollowsSetting(t *testing.T) {
+	setupTestDB(t)
+	if got := PasswordResetTTL(); got != 15*time.Minute {
+		t.Fatalf("default TTL = %v, want 15m", got)
+	}
+
+	mailer := useTestMailer(t)
+	setTestSettings(t, map[string]string{consts.ConfigPasswordResetTTLMinutes: "45"})
+	if got := PasswordResetTTL(); got != 45*time.Minute {
+		t.Fatalf("TTL = %v, want 45m", got)
+	}
+
+	before := time.Now()
+	if _, err := GenerateForgetPasswordToken(1); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	passwordResetStore.Range(func(_, value interface{}) bool {
+		resetToken := value.(ForgetPasswordToken)
+		if resetToken.ExpiresAt.Before(before.Add(45*time.Minute)) || resetToken.ExpiresAt.After(time.Now().Add(45*time.Minute)) {
+			t.Fatalf("token expires at %v, want about 45m from now", resetToken.ExpiresAt)
+		}
+		return true
+	})
+
+	// 邮件中的有效期与配置一致
+	user := createTestUser(t, "alice")
+	if err := RequestPasswordReset(user.Email); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if sent := mailer.Sent(); len(sent) != 1 || !strings.Contains(sent[0].Text, "45分钟") {
+		t.Fatalf("reset email should mention the configured TTL: %+v", sent)
+	}
+
+	if err := UpdateSettings(map[string]string{consts.ConfigPasswordResetTTLMinutes: "0"}); err == nil {
+		t.Fatal("zero TTL should be rejected")
+	}
+}
+
+func TestCleanupExpiredPasswordResetTokens(t *testing.T) {
+	setupTestDB(t)
+	live, _ := GenerateForgetPasswordToken(1)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		hash := hashResetToken(string(rune('a' + i)))
+		passwordResetStore.Store(hash, ForgetPasswordToken{UserID: uint(10 + i), TokenHash: hash, ExpiresAt: now.Add(-time.Second), CreatedAt: now.Add(-time.Hour)})
+	}
+
+	if removed := CleanupExpiredPasswordResetTokens(); removed != 3 {
+		t.Fatalf("removed %d tokens, want 3", removed)
+	}
+	if id, ok := VerifyForgetPasswordToken(live); !ok || id != 1 {
+		t.Fatalf("live token = %d, %v", id, ok)
+	}
+}
+
+func TestPasswordResetStoreIsBounded(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+	oldest := hashResetToken("oldest")
+	passwordResetStore.Store(oldest, ForgetPasswordToken{UserID: 100000, TokenHash: oldest, ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(-time.Hour)})
+	for i := 1; i < maxStoredPasswordResetTokens; i++ {
+		hash := hashResetToken(strconv.Itoa(i))
+		passwordResetStore.Store(hash, ForgetPasswordToken{UserID: uint(100000 + i), TokenHash: hash, ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(time.Duration(i) * time.Millisecond)})
+	}
+
+	token, err := GenerateForgetPasswordToken(1)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	count := 0
+	passwordResetStore.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	if count != maxStoredPasswordResetTokens {
+		t.Fatalf("store holds %d tokens, want %d", count, maxStoredPasswordResetTokens)
+	}
+	if _, ok := passwordResetStore.Load(oldest); ok {
+		t.Fatal("oldest token should be evicted")
+	}
+	if id, ok := VerifyForgetPasswordToken(token); !ok || id != 1 {
+		t.Fatalf("new token = %d, %v", id, ok)
+	}
+}
+
+func TestPasswordResetJanitorStop(t *testing.T) {
+	StartPasswordResetJanitor()
+	done := make(chan struct{})
+	go func() {
+		StopPasswordResetJanitor()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopPasswordResetJanitor did not return")
+	}
+	// 重复停止不阻塞
+	StopPasswordResetJanitor()
+}
+
+func TestPasswordResetStoreKeepsOnlyTokenDigests(t *testing.T) {
+	setupTestDB(t)
+	token, err := GenerateForgetPasswordToken(1)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	passwordResetStore.Range(func(key, value interface{}) bool {
+		resetToken := value.(ForgetPasswordToken)
+		if key == token || resetToken.TokenHash == token {
+			t.Fatal("plaintext token stored")
+		}
+		if key != hashResetToken(token) || resetToken.TokenHash != key {
+			t.Fatalf("store key %v should be the token digest", key)
+		}
+		return true
+	})
+
+	// 仅改动一个字符的 Token 不能通过验证，也不会消耗原 Token
+	last := byte('0')
+	if token[len(token)-1] == '0' {
+		last = '1'
+	}
+	tampered := token[:len(token)-1] + string(last)
+	if _, ok := VerifyForgetPasswordToken(tampered); ok {
+		t.Fatal("tampered token accepted")
+	}
+	if id, ok := VerifyForgetPasswordToken(token); !ok || id != 1 {
+		t.Fatalf("token = %d, %v", id, ok)
+	}
+}
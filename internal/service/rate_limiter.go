@@ -0,0 +1,134 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiterSweepInterval 清理过期计数的最小间隔
+const rateLimiterSweepInterval = time.Minute
+
+// RateLimiter 按 key 计数的滑动窗口限流器，计数保存在内存中 (服务重启后清零)
+// 登录、上传、重置密码、验证码重发等按次数限流的场景统一使用此类型
+type RateLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+}
+
+type rateLimitEntry struct {
+	hits   []time.Time // 窗口内允许通过的请求时间，按时间升序
+	window time.Duration
+}
+
+// NewRateLimiter 创建一个空的限流器
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{entries: make(map[string]*rateLimitEntry)}
+}
+
+// Allow 判断 key 在最近 window 内是否还能再通过一次 (最多 limit 次)
+// 允许时计入本次请求；拒绝时不计数，并返回最早一次计数滑出窗口前的剩余时间。
+// limit 或 window 不大于 0 时不限制
+func (l *RateLimiter) Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	return l.AllowN(key, 1, limit, window)
+}
+
+// AllowN 判断 key 在最近 window 内是否还能再通过 n 次，用于一次请求包含多个操作 (如批量上传) 的场景
+// 全部允许时计入 n 次；否则不计数，并返回腾出足够额度前的剩余时间 (n 超过 limit 时为 window)
+func (l *RateLimiter) AllowN(key string, n, limit int, window time.Duration) (bool, time.Duration) {
+	if limit <= 0 || window <= 0 || n <= 0 {
+		return true, 0
+	}
+	if n > limit {
+		return false, window
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := l.entry(key, window, now)
+	if used := len(entry.hits); used+n > limit {
+		return false, entry.hits[used+n-limit-1].Add(window).Sub(now)
+	}
+	for i := 0; i < n; i++ {
+		entry.hits = append(entry.hits, now)
+	}
+	return true, 0
+}
+
+// Peek 返回 key 在最近 window 内剩余可通过的次数，额度用尽时同时返回恢复一次额度前的剩余时间；不计数
+// limit 或 window 不大于 0 时返回 limit 与 0
+func (l *RateLimiter) Peek(key string, limit int, window time.Duration) (remaining int, retryAfter time.Duration) {
+	if limit <= 0 || window <= 0 {
+		return limit, 0
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := l.entry(key, window, now)
+	used := len(entry.hits)
+	if used < limit {
+		return limit - used, 0
+	}
+	return 0, entry.hits[used-limit].Add(window).Sub(now)
+}
+
+// Reset 清除 key 的计数
+func (l *RateLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// ResetPrefix 清除所有以 prefix 开头的 key 的计数
+func (l *RateLimiter) ResetPrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// entry 返回 key 的计数 (不存在时创建) 并丢弃已滑出窗口的记录，必要时顺带清理其他过期 key。调用方需持有 l.mu
+func (l *RateLimiter) entry(key string, window time.Duration, now time.Time) *rateLimitEntry {
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		l.sweep(now)
+	}
+	entry := l.entries[key]
+	if entry == nil {
+		entry = &rateLimitEntry{}
+		l.entries[key] = entry
+	}
+	entry.window = window
+	entry.prune(now)
+	return entry
+}
+
+// sweep 删除窗口内已无计数的 key，避免长期运行后无限增长。调用方需持有 l.mu
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, entry := range l.entries {
+		entry.prune(now)
+		if len(entry.hits) == 0 {
+			delete(l.entries, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// prune 丢弃滑出窗口的计数
+func (e *rateLimitEntry) prune(now time.Time) {
+	cutoff := now.Add(-e.window)
+	i := 0
+	for i < len(e.hits) && !e.hits[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		e.hits = append(e.hits[:0], e.hits[i:]...)
+	}
+}
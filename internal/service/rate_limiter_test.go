@@ -0,0 +1,124 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := NewRateLimiter()
+	for i := 0; i < 3; i++ {
+		if allowed, _ := l.Allow("k", 3, time.Minute); !allowed {
+			t.Fatalf("request %d rejected within limit", i+1)
+		}
+	}
+	allowed, retryAfter := l.Allow("k", 3, time.Minute)
+	if allowed {
+		t.Fatal("request over limit allowed")
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("retryAfter = %v, want (0, 1m]", retryAfter)
+	}
+
+	// 不同 key 互不影响
+	if allowed, _ := l.Allow("other", 3, time.Minute); !allowed {
+		t.Fatal("other key rejected")
+	}
+}
+
+func TestRateLimiterWindowSlides(t *testing.T) {
+	l := NewRateLimiter()
+	window := 50 * time.Millisecond
+	if allowed, _ := l.Allow("k", 1, window); !allowed {
+		t.Fatal("first request rejected")
+	}
+	if allowed, _ := l.Allow("k", 1, window); allowed {
+		t.Fatal("second request allowed inside window")
+	}
+	time.Sleep(window + 10*time.Millisecond)
+	if allowed, _ := l.Allow("k", 1, window); !allowed {
+		t.Fatal("request rejected after window passed")
+	}
+}
+
+func TestRateLimiterAllowN(t *testing.T) {
+	l := NewRateLimiter()
+	if allowed, _ := l.AllowN("k", 4, 5, time.Minute); !allowed {
+		t.Fatal("batch within limit rejected")
+	}
+	// 剩余额度不足时整批拒绝且不计数
+	if allowed, _ := l.AllowN("k", 2, 5, time.Minute); allowed {
+		t.Fatal("batch exceeding remaining quota allowed")
+	}
+	if remaining, _ := l.Peek("k", 5, time.Minute); remaining != 1 {
+		t.Fatalf("remaining = %d, want 1 (rejected batch must not be counted)", remaining)
+	}
+	if allowed, retryAfter := l.AllowN("k", 6, 5, time.Minute); allowed || retryAfter != time.Minute {
+		t.Fatalf("batch larger than limit: allowed=%v retryAfter=%v", allowed, retryAfter)
+	}
+}
+
+func TestRateLimiterPeekDoesNotCount(t *testing.T) {
+	l := NewRateLimiter()
+	for i := 0; i < 5; i++ {
+		if remaining, retryAfter := l.Peek("k", 2, time.Minute); remaining != 2 || retryAfter != 0 {
+			t.Fatalf("Peek = %d, %v; want 2, 0", remaining, retryAfter)
+		}
+	}
+	l.Allow("k", 2, time.Minute)
+	l.Allow("k", 2, time.Minute)
+	if remaining, retryAfter := l.Peek("k", 2, time.Minute); remaining != 0 || retryAfter <= 0 {
+		t.Fatalf("Peek after exhausting = %d, %v", remaining, retryAfter)
+	}
+}
+
+func TestRateLimiterReset(t *testing.T) {
+	l := NewRateLimiter()
+	l.Allow("alice|1.1.1.1", 1, time.Minute)
+	l.Allow("alice|2.2.2.2", 1, time.Minute)
+	l.Allow("bob|1.1.1.1", 1, time.Minute)
+
+	l.ResetPrefix("alice|")
+	for _, key := range []string{"alice|1.1.1.1", "alice|2.2.2.2"} {
+		if allowed, _ := l.Allow(key, 1, time.Minute); !allowed {
+			t.Fatalf("%s still limited after ResetPrefix", key)
+		}
+	}
+	if allowed, _ := l.Allow("bob|1.1.1.1", 1, time.Minute); allowed {
+		t.Fatal("ResetPrefix cleared an unrelated key")
+	}
+
+	l.Reset("bob|1.1.1.1")
+	if allowed, _ := l.Allow("bob|1.1.1.1", 1, time.Minute); !allowed {
+		t.Fatal("key still limited after Reset")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := NewRateLimiter()
+	for i := 0; i < 10; i++ {
+		if allowed, _ := l.Allow("k", 0, time.Minute); !allowed {
+			t.Fatal("limit 0 must not limit")
+		}
+		if allowed, _ := l.Allow("k", 1, 0); !allowed {
+			t.Fatal("window 0 must not limit")
+		}
+	}
+}
+
+func TestRateLimiterSweepsIdleKeys(t *testing.T) {
+	l := NewRateLimiter()
+	l.Allow("idle", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	l.mu.Lock()
+	l.lastSweep = time.Time{}
+	l.mu.Unlock()
+	l.Allow("active", 1, time.Minute)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.entries["idle"]; ok {
+		t.Fatal("expired key was not swept")
+	}
+}
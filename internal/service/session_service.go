@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionCacheTTL 会话有效性本地缓存时间
+// 多节点部署时，其他节点上的吊销最多延迟该时长生效
+const sessionCacheTTL = 1 * time.Minute
+
+var (
+	// sessionCache 缓存会话有效性，减少数据库查询
+	// Key: jti (string), Value: cachedSession
+	sessionCache sync.Map
+)
+
+type cachedSession struct {
+	UserID    uint
+	Valid     bool
+	ExpiresAt time.Time
+}
+
+// SessionMeta 登录时记录的客户端信息与登录选项
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+	// RememberMe 记住我：按 ConfigRememberMeHours 签发有效期更长的 Token (会话仍可吊销)
+	RememberMe bool
+}
+
+// loginTokenDuration 返回登录 Token 有效期
+// 管理员与普通用户分别读取 ConfigAdminTokenHours / ConfigUserTokenHours，未设置 (<=0) 时回退到配置文件中的 jwt.expiration_hours；
+// rememberMe 时使用 ConfigRememberMeHours (为 0 或短于普通有效期时不生效)
+func loginTokenDuration(admin, rememberMe bool) time.Duration {
+	key := consts.ConfigUserTokenHours
+	if admin {
+		key = consts.ConfigAdminTokenHours
+	}
+	hours := GetInt(key)
+	if hours <= 0 {
+		hours = config.Get().JWT.ExpirationHours
+	}
+	if rememberMe {
+		hours = max(hours, GetInt(consts.ConfigRememberMeHours))
+	}
+	return time.Hour * time.Duration(hours)
+}
+
+// issueSessionToken 创建会话记录并签发携带对应 jti 的登录 Token
+func issueSessionToken(user *model.User, meta SessionMeta) (string, error) {
+	now := time.Now()
+	duration := loginTokenDuration(user.Admin, meta.RememberMe)
+	jti := utils.NewTokenID()
+
+	userAgent := meta.UserAgent
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	// 顺便清理该用户已过期的会话
+	db.DB.Where("user_id = ? AND expires_at < ?", user.ID, now.Unix()).Delete(&model.Session{})
+
+	session := model.Session{
+		JTI:        jti,
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IP:         meta.IP,
+		CreatedAt:  now.Unix(),
+		ExpiresAt:  now.Add(duration).Unix(),
+		RememberMe: meta.RememberMe,
+	}
+	if err := db.DB.Create(&session).Error; err != nil {
+		return "", err
+	}
+
+	return utils.GenerateLoginTokenWithID(jti, user.ID, user.Username, user.Admin, user.TokenVersion, duration)
+}
+
+// RefreshSessionToken 为当前会话重新签发 Token (如修改用户名后)，沿用原 jti 与记住我选项并延长会话有效期
+func RefreshSessionToken(jti string, userID uint, username string, admin bool) (string, error) {
+	var user model.User
+	if err := db.DB.Select("id", "token_version").First(&user, userID).Error; err != nil {
+		return "", err
+	}
+
+	var session model.Session
+	if err := db.DB.Select("id", "remember_me").Where("jti = ? AND user_id = ?", jti, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("会话不存在或已失效")
+		}
+		return "", err
+	}
+
+	duration := loginTokenDuration(admin, session.RememberMe)
+	result := db.DB.Model(&model.Session{}).Where("id = ?", session.ID).
+		Update("expires_at", time.Now().Add(duration).Unix())
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", errors.New("会话不存在或已失效")
+	}
+	return utils.GenerateLoginTokenWithID(jti, userID, username, admin, user.TokenVersion, duration)
+}
+
+// IsSessionActive 检查 jti 对应的会话是否仍然有效 (未吊销、未过期且属于该用户)
+func IsSessionActive(userID uint, jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	if val, ok := sessionCache.Load(jti); ok {
+		if cached, ok := val.(cachedSession); ok && time.Now().Before(cached.ExpiresAt) {
+			return cached.Valid && cached.UserID == userID
+		}
+	}
+
+	var session model.Session
+	err := db.DB.Where("jti = ?", jti).Limit(1).Find(&session).Error
+	if err != nil {
+		log.Printf("Load session error: %v\n", err)
+		return false
+	}
+
+	valid := session.ID != 0 && session.UserID == userID && session.ExpiresAt > time.Now().Unix()
+	sessionCache.Store(jti, cachedSession{
+		UserID:    session.UserID,
+		Valid:     valid,
+		ExpiresAt: time.Now().Add(sessionCacheTTL),
+	})
+	return valid
+}
+
+// BumpTokenVersion 递增用户的 TokenVersion，使此前签发的所有 Token 立即失效
+// 调用方需同时清除中间件中的用户状态缓存
+func BumpTokenVersion(userID uint) error {
+	return db.DB.Model(&model.User{}).Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// ListSessions 列出用户当前有效的会话
+func ListSessions(userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	err := db.DB.Where("user_id = ? AND expires_at > ?", userID, time.Now().Unix()).
+		Order("created_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession 吊销用户的指定会话
+func RevokeSession(userID uint, jti string) error {
+	result := db.DB.Where("user_id = ? AND jti = ?", userID, jti).Delete(&model.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	sessionCache.Delete(jti)
+	if result.RowsAffected == 0 {
+		return newAuthError(AuthErrorNotFound, "会话不存在")
+	}
+	return nil
+}
+
+// RevokeAllSessions 吊销用户的全部会话 (退出所有设备)
+func RevokeAllSessions(userID uint) error {
+	var jtis []string
+	if err := db.DB.Model(&model.Session{}).Where("user_id = ?", userID).Pluck("jti", &jtis).Error; err != nil {
+		return err
+	}
+	if err := db.DB.Where("user_id = ?", userID).Delete(&model.Session{}).Error; err != nil {
+		return err
+	}
+	for _, jti := range jtis {
+		sessionCache.Delete(jti)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestLoginTokenDuration(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigUserTokenHours:  "24",
+		consts.ConfigAdminTokenHours: "2",
+	})
+
+	tests := []struct {
+		rememberHours     string
+		admin, rememberMe bool
+		want              time.Duration
+	}{
+		{rememberHours: "720", want: 24 * time.Hour},
+		{rememberHours: "720", admin: true, want: 2 * time.Hour},
+		{rememberHours: "720", rememberMe: true, want: 720 * time.Hour},
+		{rememberHours: "720", admin: true, rememberMe: true, want: 720 * time.Hour},
+		// 为 0 或短于普通有效期时不生效
+		{rememberHours: "0", rememberMe: true, want: 24 * time.Hour},
+		{rememberHours: "12", rememberMe: true, want: 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		setTestSettings(t, map[string]string{consts.ConfigRememberMeHours: tt.rememberHours})
+		if got := loginTokenDuration(tt.admin, tt.rememberMe); got != tt.want {
+			t.Errorf("remember_me_hours=%s admin=%v rememberMe=%v: got %v, want %v",
+				tt.rememberHours, tt.admin, tt.rememberMe, got, tt.want)
+		}
+	}
+}
+
+// tokenLifetime 返回 Token 剩余有效期 (取整到小时)
+func tokenLifetime(t *testing.T, token string) (time.Duration, *utils.LoginClaims) {
+	t.Helper()
+	claims, err := utils.ParseLoginToken(token)
+	if err != nil {
+		t.Fatalf("parse login token: %v", err)
+	}
+	return time.Until(claims.ExpiresAt.Time).Round(time.Hour), claims
+}
+
+func TestLoginTokenLifetimeByRole(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigUserTokenHours:  "48",
+		consts.ConfigAdminTokenHours: "2",
+	})
+	createTestUser(t, "root", func(u *model.User) { u.Admin = true })
+	createTestUser(t, "member")
+
+	login := func(username string) time.Duration {
+		t.Helper()
+		token, err := LoginUser(username, testPassword, SessionMeta{IP: "127.0.0.1"})
+		if err != nil {
+			t.Fatalf("login %q: %v", username, err)
+		}
+		lifetime, _ := tokenLifetime(t, token)
+		return lifetime
+	}
+
+	if got := login("root"); got != 2*time.Hour {
+		t.Fatalf("admin token lifetime = %v, want 2h", got)
+	}
+	if got := login("member"); got != 48*time.Hour {
+		t.Fatalf("user token lifetime = %v, want 48h", got)
+	}
+
+	// 修改设置后无需重启即可生效，设为 0 时回退到配置文件
+	setTestSettings(t, map[string]string{
+		consts.ConfigUserTokenHours:  "0",
+		consts.ConfigAdminTokenHours: "6",
+	})
+	if got := login("root"); got != 6*time.Hour {
+		t.Fatalf("admin token lifetime after change = %v, want 6h", got)
+	}
+	want := time.Duration(config.Get().JWT.ExpirationHours) * time.Hour
+	if got := login("member"); got != want {
+		t.Fatalf("user token lifetime with fallback = %v, want %v", got, want)
+	}
+}
+
+func TestRememberMeSessionKeepsLifetimeOnRefresh(t *testing.T) {
+	setupTestDB(t)
+	setTestSettings(t, map[string]string{
+		consts.ConfigUserTokenHours:  "24",
+		consts.ConfigRememberMeHours: "720",
+	})
+	user := createTestUser(t, "remy")
+
+	for _, rememberMe := range []bool{false, true} {
+		want := 24 * time.Hour
+		if rememberMe {
+			want = 720 * time.Hour
+		}
+
+		token, err := LoginUser("remy", testPassword, SessionMeta{IP: "127.0.0.1", RememberMe: rememberMe})
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		lifetime, claims := tokenLifetime(t, token)
+		if lifetime != want {
+			t.Fatalf("rememberMe=%v: token lifetime = %v, want %v", rememberMe, lifetime, want)
+		}
+
+		var session model.Session
+		if err := db.DB.Where("jti = ?", claims.RegisteredClaims.ID).First(&session).Error; err != nil {
+			t.Fatalf("load session: %v", err)
+		}
+		if session.RememberMe != rememberMe || time.Until(time.Unix(session.ExpiresAt, 0)).Round(time.Hour) != want {
+			t.Fatalf("rememberMe=%v: session = %+v", rememberMe, session)
+		}
+
+		refreshed, err := RefreshSessionToken(claims.RegisteredClaims.ID, user.ID, user.Username, user.Admin)
+		if err != nil {
+			t.Fatalf("refresh: %v", err)
+		}
+		if lifetime, _ := tokenLifetime(t, refreshed); lifetime != want {
+			t.Fatalf("rememberMe=%v: refreshed lifetime = %v, want %v", rememberMe, lifetime, want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"perfect-pic-server/internal/consts"
+	"strconv"
+	"strings"
+)
+
+// SettingType 配置项的值类型
+type SettingType string
+
+const (
+	SettingTypeString SettingType = "string"
+	SettingTypeBool   SettingType = "bool"
+	SettingTypeInt    SettingType = "int"
+	SettingTypeFloat  SettingType = "float"
+	SettingTypeURL    SettingType = "url"
+	SettingTypeEnum   SettingType = "enum"
+	SettingTypeIPList SettingType = "ip_list" // 逗号分隔的 IP 或 CIDR
+)
+
+// SettingSpec 描述配置项的类型与取值约束
+type SettingSpec struct {
+	Type SettingType
+	// Min/Max 数值类型的取值范围，Max <= Min 时表示不限制上限
+	Min float64
+	Max float64
+	// Options 枚举类型的可选值
+	Options []string
+	// AllowEmpty 是否允许空值 (仅对 url/enum 生效，string/ip_list 始终允许为空)
+	AllowEmpty bool
+	// AllowPath url 类型是否允许以 / 开头的站内路径
+	AllowPath bool
+}
+
+// SettingValidationError 配置值不符合约束时返回的错误，Message 可直接返回给用户
+type SettingValidationError struct {
+	Key     string
+	Message string
+}
+
+func (e *SettingValidationError) Error() string {
+	return e.Message
+}
+
+// AsSettingValidationError 从错误链中提取 SettingValidationError
+func AsSettingValidationError(err error) (*SettingValidationError, bool) {
+	var validationErr *SettingValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr, true
+	}
+	return nil, false
+}
+
+func boolSpec() SettingSpec { return SettingSpec{Type: SettingTypeBool} }
+
+func intSpec(min, max float64) SettingSpec {
+	return SettingSpec{Type: SettingTypeInt, Min: min, Max: max}
+}
+
+func floatSpec(min, max float64) SettingSpec {
+	return SettingSpec{Type: SettingTypeFloat, Min: min, Max: max}
+}
+
+func enumSpec(options ...string) SettingSpec {
+	return SettingSpec{Type: SettingTypeEnum, Options: options}
+}
+
+// settingSpecs 已知配置项的类型注册表，新增配置项时需同步在此登记
+var settingSpecs = map[string]SettingSpec{
+	consts.ConfigSiteName:                   {Type: SettingTypeString},
+	consts.ConfigSiteDescription:            {Type: SettingTypeString},
+	consts.ConfigSiteLogo:                   {Type: SettingTypeURL, AllowEmpty: true, AllowPath: true},
+	consts.ConfigSiteFavicon:                {Type: SettingTypeURL, AllowEmpty: true, AllowPath: true},
+	consts.ConfigBaseURL:                    {Type: SettingTypeURL},
+	consts.ConfigPublicBaseURL:              {Type: SettingTypeURL, AllowEmpty: true},
+	consts.ConfigAllowInit:                  boolSpec(),
+	consts.ConfigAllowRegister:              boolSpec(),
+	consts.ConfigRegistrationMode:           enumSpec(RegistrationModeOpen, RegistrationModeClosed, RegistrationModeInvite),
+	consts.ConfigEnableSMTP:                 boolSpec(),
+	consts.ConfigBlockUnverifiedUsers:       boolSpec(),
+	consts.ConfigAllowEmailLogin:            boolSpec(),
+	consts.ConfigNotifyNewDevice:            boolSpec(),
+	consts.ConfigAccountDeletionGraceDays:   intSpec(1, 365),
+	consts.ConfigRequireEmailVerification:   boolSpec(),
+	consts.ConfigUnverifiedRetentionDays:    intSpec(0, 0),
+	consts.ConfigMaxUploadSize:              intSpec(1, 0),
+	consts.ConfigMaxUploadSizeBytes:         intSpec(0, 0),
+	consts.ConfigAllowFileExtensions:        {Type: SettingTypeString},
+	consts.ConfigAllowedImageTypes:          {Type: SettingTypeString},
+	consts.ConfigMaxPixels:                  intSpec(0, 0),
+	consts.ConfigMaxGifFrames:               intSpec(0, 0),
+	consts.ConfigMaxImageWidth:              intSpec(0, 0),
+	consts.ConfigMaxImageHeight:             intSpec(0, 0),
+	consts.ConfigOversizePolicy:             enumSpec(OversizeReject, OversizeDownscale),
+	consts.ConfigExtensionMismatchPolicy:    enumSpec(ExtensionMismatchReject, ExtensionMismatchTrustContent, ExtensionMismatchTrustExtension),
+	consts.ConfigAllowSVG:                   boolSpec(),
+	consts.ConfigIntegrityScanSampleRate:    intSpec(0, 0),
+	consts.ConfigDefaultStorageQuota:        intSpec(0, 0),
+	consts.ConfigDailyUploadCountLimit:      intSpec(0, 0),
+	consts.ConfigDailyUploadBytesLimit:      intSpec(0, 0),
+	consts.ConfigRateLimitEnabled:           boolSpec(),
+	consts.ConfigRateLimitAuthRPS:           floatSpec(0, 0),
+	consts.ConfigRateLimitAuthBurst:         intSpec(1, 0),
+	consts.ConfigRateLimitUploadRPS:         floatSpec(0, 0),
+	consts.ConfigRateLimitUploadBurst:       intSpec(1, 0),
+	consts.ConfigEnableSensitiveRateLimit:   boolSpec(),
+	consts.ConfigMaxRequestBodySize:         intSpec(1, 0),
+	consts.ConfigStaticCacheControl:         {Type: SettingTypeString},
+	consts.ConfigImageCacheMaxAge:           intSpec(0, 0),
+	consts.ConfigInternalRedirectMode:       enumSpec(InternalRedirectOff, InternalRedirectNginx, InternalRedirectApache),
+	consts.ConfigInternalRedirectPrefix:     {Type: SettingTypeString},
+	consts.ConfigAdminIPAllowlist:           {Type: SettingTypeIPList},
+	consts.ConfigCaptchaBypassIPs:           {Type: SettingTypeIPList},
+	consts.ConfigCaptchaRequiredActions:     {Type: SettingTypeString},
+	consts.ConfigCaptchaProvider:            enumSpec(CaptchaProviderImage, CaptchaProviderCap),
+	consts.ConfigCaptchaCapInstanceURL:      {Type: SettingTypeURL, AllowEmpty: true},
+	consts.ConfigCaptchaCapSiteKey:          {Type: SettingTypeString},
+	consts.ConfigCaptchaCapSecret:           {Type: SettingTypeString},
+	consts.ConfigCaptchaCapVerifyURL:        {Type: SettingTypeURL, AllowEmpty: true},
+	consts.ConfigCORSAllowedOrigins:         {Type: SettingTypeString},
+	consts.ConfigLogLevel:                   enumSpec("debug", "info", "warn", "error"),
+	consts.ConfigLogFormat:                  enumSpec("text", "json"),
+	consts.ConfigTrustedProxies:             {Type: SettingTypeIPList},
+	consts.ConfigEmailWorkerCount:           intSpec(1, 64),
+	consts.ConfigEmailMaxAttempts:           intSpec(1, 20),
+	consts.ConfigDefaultLocale:              enumSpec(SupportedLocales...),
+	consts.ConfigAutoOrient:                 boolSpec(),
+	consts.ConfigOptimizeOnUpload:           boolSpec(),
+	consts.ConfigGenerateAltFormats:         boolSpec(),
+	consts.ConfigUploadResponseStyle:        enumSpec(UploadResponseStyleDefault, UploadResponseStyleShareX, UploadResponseStylePicGo),
+	consts.ConfigUploadDeleteToken:          boolSpec(),
+	consts.ConfigAllowAnonymousUpload:       boolSpec(),
+	consts.ConfigAnonymousImageTTLDays:      intSpec(0, 0),
+	consts.ConfigAnonymousUploadCountPerIP:  intSpec(0, 0),
+	consts.ConfigAnonymousUploadBytesPerIP:  intSpec(0, 0),
+	consts.ConfigExpiryCheckIntervalMinutes: intSpec(1, 1440),
+	consts.ConfigMaxConcurrentImageOps:      intSpec(0, 0),
+	consts.ConfigImageOpQueueTimeoutSeconds: intSpec(1, 600),
+	consts.ConfigJpegQuality:                intSpec(1, 100),
+	consts.ConfigMaxBatchUploadFiles:        intSpec(1, 100),
+	consts.ConfigAvatarMaxSize:              intSpec(16, 4096),
+	consts.ConfigAvatarCropSquare:           boolSpec(),
+	consts.ConfigLoginMaxAttempts:           intSpec(0, 0),
+	consts.ConfigLoginLockoutMinutes:        intSpec(1, 0),
+	consts.ConfigAccountLockThreshold:       intSpec(0, 0),
+	consts.ConfigLoginRevealAttempts:        boolSpec(),
+	consts.ConfigPasswordMinLength:          intSpec(6, 128),
+	consts.ConfigPasswordRequireDigit:       boolSpec(),
+	consts.ConfigPasswordRequireUpper:       boolSpec(),
+	consts.ConfigPasswordRequireLower:       boolSpec(),
+	consts.ConfigPasswordRequireSymbol:      boolSpec(),
+	consts.ConfigPasswordDenyCommon:         boolSpec(),
+	consts.ConfigPasswordDenylist:           {Type: SettingTypeString},
+	consts.ConfigBcryptCost:                 intSpec(4, 31),
+	consts.ConfigPasswordHashScheme:         enumSpec("bcrypt", "argon2id"),
+	consts.ConfigPasswordMigrateOnLogin:     boolSpec(),
+	consts.ConfigArgon2Memory:               intSpec(8192, 1048576),
+	consts.ConfigArgon2Iterations:           intSpec(1, 10),
+	consts.ConfigArgon2Parallelism:          intSpec(1, 16),
+	consts.ConfigVerificationResendCooldown: intSpec(0, 0),
+	consts.ConfigPasswordResetCooldown:      intSpec(0, 0),
+	consts.ConfigPasswordResetTTLMinutes:    intSpec(1, 0),
+	consts.ConfigEmailVerifyTTLHours:        floatSpec(0.1, 0),
+	consts.ConfigUserTokenHours:             intSpec(0, 0),
+	consts.ConfigAdminTokenHours:            intSpec(0, 0),
+	consts.ConfigRememberMeHours:            intSpec(0, 0),
+	consts.ConfigReservedUsernames:          {Type: SettingTypeString},
+	consts.ConfigStorageBackend:             enumSpec(StorageBackendLocal, StorageBackendWebDAV, StorageBackendOSS),
+	consts.ConfigWebDAVURL:                  {Type: SettingTypeURL, AllowEmpty: true},
+	consts.ConfigWebDAVUsername:             {Type: SettingTypeString},
+	consts.ConfigWebDAVPassword:             {Type: SettingTypeString},
+	consts.ConfigOSSEndpoint:                {Type: SettingTypeString},
+	consts.ConfigOSSBucket:                  {Type: SettingTypeString},
+	consts.ConfigOSSAccessKeyID:             {Type: SettingTypeString},
+	consts.ConfigOSSAccessKeySecret:         {Type: SettingTypeString},
+	consts.ConfigOSSCustomDomain:            {Type: SettingTypeString},
+	consts.ConfigPresignTTLSeconds:          intSpec(0, 7*24*3600),
+	consts.ConfigStorageLayout:              enumSpec(StorageLayoutDate, StorageLayoutHash),
+	consts.ConfigTrackImageViews:            boolSpec(),
+	consts.ConfigImageViewsExcludeBots:      boolSpec(),
+	consts.ConfigHotlinkProtection:          boolSpec(),
+	consts.ConfigHotlinkAllowedReferers:     {Type: SettingTypeString},
+	consts.ConfigHotlinkAllowEmptyReferer:   boolSpec(),
+	consts.ConfigHotlinkRedirectURL:         {Type: SettingTypeURL, AllowEmpty: true, AllowPath: true},
+}
+
+// GetSettingSpec 返回配置项的类型描述，未登记的配置项返回 false
+func GetSettingSpec(key string) (SettingSpec, bool) {
+	spec, ok := settingSpecs[key]
+	return spec, ok
+}
+
+// ValidateSetting 按注册表校验配置值，未知配置项或取值不合法时返回 *SettingValidationError
+func ValidateSetting(key, value string) error {
+	spec, ok := settingSpecs[key]
+	if !ok {
+		return &SettingValidationError{Key: key, Message: fmt.Sprintf("未知的配置项: %s", key)}
+	}
+	if msg := spec.check(strings.TrimSpace(value)); msg != "" {
+		return &SettingValidationError{Key: key, Message: fmt.Sprintf("配置项 %s %s", key, msg)}
+	}
+	return nil
+}
+
+// check 校验取值，合法时返回空字符串，否则返回错误说明
+func (s SettingSpec) check(value string) string {
+	switch s.Type {
+	case SettingTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "必须为 true 或 false"
+		}
+	case SettingTypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "必须为整数"
+		}
+		return s.checkRange(float64(n))
+	case SettingTypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "必须为数字"
+		}
+		return s.checkRange(f)
+	case SettingTypeURL:
+		if value == "" {
+			if s.AllowEmpty {
+				return ""
+			}
+			return "不能为空"
+		}
+		if s.AllowPath && strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//") {
+			return ""
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "必须为 http:// 或 https:// 开头的完整 URL"
+		}
+	case SettingTypeEnum:
+		if value == "" && s.AllowEmpty {
+			return ""
+		}
+		for _, opt := range s.Options {
+			if value == opt {
+				return ""
+			}
+		}
+		return "仅允许: " + strings.Join(s.Options, ", ")
+	case SettingTypeIPList:
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if net.ParseIP(item) != nil {
+				continue
+			}
+			if _, _, err := net.ParseCIDR(item); err != nil {
+				return fmt.Sprintf("包含无效的 IP 或 CIDR: %s", item)
+			}
+		}
+	}
+	return ""
+}
+
+func (s SettingSpec) checkRange(n float64) string {
+	if n < s.Min {
+		return fmt.Sprintf("不能小于 %s", strconv.FormatFloat(s.Min, 'f', -1, 64))
+	}
+	if s.Max > s.Min && n > s.Max {
+		return fmt.Sprintf("不能大于 %s", strconv.FormatFloat(s.Max, 'f', -1, 64))
+	}
+	return ""
+}
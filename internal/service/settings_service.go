@@ -1,18 +1,40 @@
 package service
 
 import (
+	"log"
 	"perfect-pic-server/internal/consts"
 	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/logging"
 	"perfect-pic-server/internal/model"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
 )
 
+// settingsCacheTTL 配置项本地缓存时间，过期后下次读取时重新从数据库加载
+const settingsCacheTTL = 5 * time.Minute
+
 var (
 	// 内存缓存
+	// Key: 配置键 (string), Value: cachedSetting
 	settingsCache sync.Map
+	// settingsLoadGroup 合并同一配置项的并发数据库加载
+	settingsLoadGroup singleflight.Group
+	// settingsCacheGeneration 每次失效时递增，加载期间发生失效时丢弃加载结果，避免回填旧值
+	settingsCacheGeneration atomic.Uint64
 )
 
+type cachedSetting struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
 const DefaultValueNotFound = "||__NOT_FOUND__||"
 
 var DefaultSettings = []model.Setting{
@@ -21,14 +43,32 @@ var DefaultSettings = []model.Setting{
 	{Key: consts.ConfigSiteLogo, Value: "", Desc: "网站Logo URL", Category: "常规"},
 	{Key: consts.ConfigSiteFavicon, Value: "", Desc: "网站Favicon URL", Category: "常规"},
 	{Key: consts.ConfigBaseURL, Value: "http://localhost", Desc: "网站基础URL (用于生成链接)", Category: "常规"},
+	{Key: consts.ConfigPublicBaseURL, Value: "", Desc: "图片访问地址的公开域名 (如 CDN 域名 https://cdn.example.com)，设置后返回的图片链接为完整地址，留空返回相对地址", Category: "常规"},
 	{Key: consts.ConfigAllowInit, Value: "true", Desc: "是否允许初始化管理员账号", Category: "安全"},
 	{Key: consts.ConfigAllowRegister, Value: "true", Desc: "是否开放注册", Category: "安全"},
+	{Key: consts.ConfigRegistrationMode, Value: "open", Desc: "注册模式 (open: 开放注册, closed: 关闭注册, invite: 仅邀请码注册)", Category: "安全"},
 	{Key: consts.ConfigEnableSMTP, Value: "false", Desc: "是否启用 SMTP 发送邮件", Category: "邮件服务"},
 	{Key: consts.ConfigBlockUnverifiedUsers, Value: "false", Desc: "是否阻止未验证邮箱用户登录", Category: "安全"},
+	{Key: consts.ConfigAllowEmailLogin, Value: "false", Desc: "是否允许使用邮箱代替用户名登录", Category: "安全"},
+	{Key: consts.ConfigNotifyNewDevice, Value: "false", Desc: "新设备登录时发送邮件提醒 (需开启 SMTP)", Category: "安全"},
+	{Key: consts.ConfigAccountDeletionGraceDays, Value: "14", Desc: "用户申请注销账号后的保留天数，期间可通过邮件中的链接撤销，期满后删除账号及其文件", Category: "安全"},
 	{Key: consts.ConfigRequireEmailVerification, Value: "false", Desc: "是否强制要求注册验证邮箱", Category: "安全"},
+	{Key: consts.ConfigUnverifiedRetentionDays, Value: "0", Desc: "未验证邮箱的账号保留天数，超过后自动删除账号及其文件 (0 表示不清理，管理员不会被删除)", Category: "安全"},
 	{Key: consts.ConfigMaxUploadSize, Value: "10", Desc: "单个文件最大大小 (MB)", Category: "上传"},
+	{Key: consts.ConfigMaxUploadSizeBytes, Value: "0", Desc: "单个文件最大大小 (Bytes，大于 0 时优先于 MB 设置)", Category: "上传"},
 	{Key: consts.ConfigAllowFileExtensions, Value: ".jpg,.jpeg,.png,.gif,.webp", Desc: "允许上传的文件扩展名", Category: "上传"},
+	{Key: consts.ConfigAllowedImageTypes, Value: "", Desc: "允许上传的图片类型 (逗号分隔，如 png,webp,jpg；留空则沿用允许的文件扩展名)", Category: "上传"},
+	{Key: consts.ConfigMaxPixels, Value: "100000000", Desc: "单张图片最大像素面积 (宽*高，GIF 按总帧面积计算，0 表示不限制)", Category: "上传"},
+	{Key: consts.ConfigMaxGifFrames, Value: "300", Desc: "GIF 图片最大帧数 (0 表示不限制)", Category: "上传"},
+	{Key: consts.ConfigMaxImageWidth, Value: "0", Desc: "图片最大宽度 (像素，0 表示不限制)", Category: "上传"},
+	{Key: consts.ConfigMaxImageHeight, Value: "0", Desc: "图片最大高度 (像素，0 表示不限制)", Category: "上传"},
+	{Key: consts.ConfigOversizePolicy, Value: OversizeReject, Desc: "宽高超出限制时的处理方式：reject 拒绝上传；downscale 等比缩小后保存 (GIF 与 SVG 除外，GIF 超出时仍拒绝)", Category: "上传"},
+	{Key: consts.ConfigExtensionMismatchPolicy, Value: "reject", Desc: "文件内容与扩展名不一致时的处理 (reject: 拒绝上传, trust-content: 按真实类型保存, trust-extension: 保留声明的扩展名)；真实类型不在允许列表时始终拒绝", Category: "上传"},
+	{Key: consts.ConfigAllowSVG, Value: "false", Desc: "是否允许上传 SVG 图片 (保存前会删除脚本、事件属性与外部引用；头像不支持 SVG)", Category: "上传"},
+	{Key: consts.ConfigIntegrityScanSampleRate, Value: "0", Desc: "图片完整性巡检每分钟最多校验的图片数量 (0 表示关闭)", Category: "上传"},
 	{Key: consts.ConfigDefaultStorageQuota, Value: "1073741824", Desc: "默认用户存储配额 (Bytes, 默认为1GB)", Category: "上传"},
+	{Key: consts.ConfigDailyUploadCountLimit, Value: "0", Desc: "每个用户每天最多上传的图片数量 (0 表示不限制，管理员不受限)", Category: "上传"},
+	{Key: consts.ConfigDailyUploadBytesLimit, Value: "0", Desc: "每个用户每天最多上传的字节数 (Bytes, 0 表示不限制，管理员不受限)", Category: "上传"},
 	{Key: consts.ConfigRateLimitEnabled, Value: "true", Desc: "是否开启接口限流", Category: "速率限制"},
 	{Key: consts.ConfigRateLimitAuthRPS, Value: "0.5", Desc: "认证接口每秒请求限制 (RPS)", Category: "速率限制"},
 	{Key: consts.ConfigRateLimitAuthBurst, Value: "2", Desc: "认证接口突发请求限制", Category: "速率限制"},
@@ -37,16 +77,163 @@ var DefaultSettings = []model.Setting{
 	{Key: consts.ConfigEnableSensitiveRateLimit, Value: "true", Desc: "是否开启敏感操作（忘记密码、修改邮箱）频率限制", Category: "速率限制"},
 	{Key: consts.ConfigMaxRequestBodySize, Value: "2", Desc: "非文件上传接口最大请求体限制 (MB)", Category: "服务"},
 	{Key: consts.ConfigStaticCacheControl, Value: "public, max-age=31536000", Desc: "静态资源缓存设置 (Cache-Control)", Category: "服务"},
-	{Key: consts.ConfigTrustedProxies, Value: "", Desc: "可信代理列表（逗号分隔，留空表示不信任代理头；修改后需重启服务生效）", Category: "安全"},
+	{Key: consts.ConfigImageCacheMaxAge, Value: "0", Desc: "图片响应的缓存时间 (秒)，到期后需重新验证；0 表示不缓存，每次使用前按 ETag 重新验证 (替换图片后地址不变，不宜设置过长)", Category: "服务"},
+	{Key: consts.ConfigInternalRedirectMode, Value: InternalRedirectOff, Desc: "本地存储的图片交由前端服务器发送 (off: 由本服务发送, nginx: X-Accel-Redirect, apache: X-Sendfile)，权限与防盗链检查仍由本服务完成", Category: "服务"},
+	{Key: consts.ConfigInternalRedirectPrefix, Value: "/_protected_imgs/", Desc: "nginx 模式下 X-Accel-Redirect 指向的 internal location 前缀 (需在 nginx 中将其 alias 到 upload.path)", Category: "服务"},
+	{Key: consts.ConfigAdminIPAllowlist, Value: "", Desc: "允许访问管理后台的 IP 或 CIDR (逗号分隔，留空表示不限制)", Category: "安全"},
+	{Key: consts.ConfigCaptchaRequiredActions, Value: "login,register,password_reset", Desc: "需要验证码的操作 (逗号分隔，可选 login、register、password_reset，留空表示均不需要)", Category: "安全"},
+	{Key: consts.ConfigCaptchaProvider, Value: "image", Desc: "验证码类型 (image: 内置图片验证码, cap: 自托管 Cap 验证码)", Category: "安全"},
+	{Key: consts.ConfigCaptchaCapInstanceURL, Value: "", Desc: "自托管 Cap 服务地址 (如 https://cap.example.com)", Category: "安全"},
+	{Key: consts.ConfigCaptchaCapSiteKey, Value: "", Desc: "Cap 站点 Key", Category: "安全"},
+	{Key: consts.ConfigCaptchaCapSecret, Value: "", Desc: "Cap 站点密钥", Category: "安全"},
+	{Key: consts.ConfigCaptchaCapVerifyURL, Value: "", Desc: "Cap 服务端校验地址 (留空使用 {实例地址}/{站点 Key}/siteverify)", Category: "安全"},
+	{Key: consts.ConfigCaptchaBypassIPs, Value: "", Desc: "免验证码的 IP 或 CIDR (逗号分隔，留空表示所有请求都需验证码，仅用于内部测试环境；访客上传不受影响)", Category: "安全"},
+	{Key: consts.ConfigCORSAllowedOrigins, Value: "", Desc: "允许跨域访问 API 的来源 (逗号分隔，* 表示任意来源，留空表示不允许跨域)", Category: "安全"},
+	{Key: consts.ConfigLogLevel, Value: "info", Desc: "日志级别 (debug / info / warn / error)", Category: "服务"},
+	{Key: consts.ConfigLogFormat, Value: "text", Desc: "日志格式 (text / json)", Category: "服务"},
+	{Key: consts.ConfigTrustedProxies, Value: "", Desc: "可信代理列表（逗号分隔的 IP 或 CIDR，留空表示不信任代理头）", Category: "安全"},
+	{Key: consts.ConfigEmailWorkerCount, Value: "2", Desc: "邮件发送队列工作协程数量（修改后需重启服务生效）", Category: "邮件服务"},
+	{Key: consts.ConfigEmailMaxAttempts, Value: "3", Desc: "邮件发送失败时的最大尝试次数", Category: "邮件服务"},
+	{Key: consts.ConfigDefaultLocale, Value: "zh-CN", Desc: "默认语言，用于未设置语言偏好的用户 (zh-CN / en)", Category: "常规"},
+	{Key: consts.ConfigMaxBatchUploadFiles, Value: "20", Desc: "批量上传单次最多文件数量 (开启上传限流时每个文件计为一次上传，实际上限不超过上传突发请求限制)", Category: "上传"},
+	{Key: consts.ConfigAutoOrient, Value: "false", Desc: "上传 JPEG 时按 EXIF 方向自动旋转图片 (保留其余 EXIF 信息)", Category: "上传"},
+	{Key: consts.ConfigOptimizeOnUpload, Value: "false", Desc: "上传时压缩图片 (JPEG 按设定质量重新编码，PNG 无损重新压缩)，压缩后更大则保留原图", Category: "上传"},
+	{Key: consts.ConfigGenerateAltFormats, Value: "false", Desc: "上传 JPEG/PNG/BMP 时额外生成 WebP 备选文件 (JPEG 按 jpeg_quality 有损编码，PNG/BMP 无损编码，仅在更小时保留，不计入存储配额)，向支持 WebP 的浏览器返回", Category: "上传"},
+	{Key: consts.ConfigUploadResponseStyle, Value: UploadResponseStyleDefault, Desc: "上传成功的响应格式 (default: 默认, sharex: ShareX, picgo: PicGo)，请求可通过 format 参数覆盖", Category: "上传"},
+	{Key: consts.ConfigUploadDeleteToken, Value: "true", Desc: "上传时是否生成一次性删除 Token 并在响应中附带删除链接 (持有 Token 即可删除该图片，使用后失效)", Category: "上传"},
+	{Key: consts.ConfigAllowAnonymousUpload, Value: "false", Desc: "是否允许未登录的访客上传图片 (访客上传必须通过验证码，受每个 IP 的数量与空间限制)", Category: "上传"},
+	{Key: consts.ConfigAnonymousImageTTLDays, Value: "7", Desc: "访客上传的图片保留天数，到期后自动删除 (0 表示永久保留，修改后只影响之后上传的图片)", Category: "上传"},
+	{Key: consts.ConfigAnonymousUploadCountPerIP, Value: "20", Desc: "同一 IP (IPv6 按 /64 网段) 最多保留的访客图片数量 (0 表示不限制)", Category: "上传"},
+	{Key: consts.ConfigAnonymousUploadBytesPerIP, Value: "104857600", Desc: "同一 IP (IPv6 按 /64 网段) 上传的访客图片最多占用的空间 (Bytes，0 表示不限制)", Category: "上传"},
+	{Key: consts.ConfigExpiryCheckIntervalMinutes, Value: "10", Desc: "清理已过期图片 (上传时设置了有效期) 的检查间隔 (分钟)", Category: "上传"},
+	{Key: consts.ConfigMaxConcurrentImageOps, Value: "4", Desc: "同时进行的图片解码、缩放与编码任务数上限，超出时排队 (0 表示不限制)", Category: "上传"},
+	{Key: consts.ConfigImageOpQueueTimeoutSeconds, Value: "30", Desc: "图片处理排队等待的最长时间 (秒)，超时返回服务器繁忙 (503)", Category: "上传"},
+	{Key: consts.ConfigJpegQuality, Value: "85", Desc: "上传压缩时 JPEG 的编码质量 (1-100)", Category: "上传"},
+	{Key: consts.ConfigAvatarMaxSize, Value: "512", Desc: "头像最大边长 (像素)，超出时自动缩放", Category: "上传"},
+	{Key: consts.ConfigAvatarCropSquare, Value: "true", Desc: "是否将头像居中裁剪为正方形", Category: "上传"},
+	{Key: consts.ConfigLoginMaxAttempts, Value: "5", Desc: "同一 IP 登录同一账号的连续失败次数上限，达到后临时锁定该 IP 对该账号的登录 (0 表示不锁定)", Category: "安全"},
+	{Key: consts.ConfigLoginLockoutMinutes, Value: "15", Desc: "登录失败锁定时长 (分钟)", Category: "安全"},
+	{Key: consts.ConfigAccountLockThreshold, Value: "0", Desc: "累计连续登录失败多少次后锁定账号，锁定后由管理员或用户通过邮件链接解锁 (0 表示不锁定)", Category: "安全"},
+	{Key: consts.ConfigLoginRevealAttempts, Value: "false", Desc: "登录失败时是否返回剩余尝试次数与锁定截止时间", Category: "安全"},
+	{Key: consts.ConfigPasswordMinLength, Value: "8", Desc: "密码最小长度", Category: "安全"},
+	{Key: consts.ConfigPasswordRequireDigit, Value: "true", Desc: "密码是否必须包含数字", Category: "安全"},
+	{Key: consts.ConfigPasswordRequireUpper, Value: "false", Desc: "密码是否必须包含大写字母", Category: "安全"},
+	{Key: consts.ConfigPasswordRequireLower, Value: "false", Desc: "密码是否必须包含小写字母", Category: "安全"},
+	{Key: consts.ConfigPasswordRequireSymbol, Value: "false", Desc: "密码是否必须包含符号", Category: "安全"},
+	{Key: consts.ConfigPasswordDenyCommon, Value: "false", Desc: "是否禁止使用常见弱密码", Category: "安全"},
+	{Key: consts.ConfigPasswordDenylist, Value: "", Desc: "自定义禁用密码列表 (逗号分隔，忽略大小写)", Category: "安全"},
+	{Key: consts.ConfigBcryptCost, Value: "10", Desc: "密码哈希的 bcrypt 计算成本 (4-31，越高越安全也越耗时；用户登录时旧哈希会自动升级)", Category: "安全"},
+	{Key: consts.ConfigPasswordHashScheme, Value: "bcrypt", Desc: "新密码使用的哈希方案 (bcrypt / argon2id，已有密码按其原方案校验)", Category: "安全"},
+	{Key: consts.ConfigPasswordMigrateOnLogin, Value: "true", Desc: "用户登录成功时是否将其他方案的旧密码哈希迁移为当前方案", Category: "安全"},
+	{Key: consts.ConfigArgon2Memory, Value: "65536", Desc: "Argon2id 内存开销 (KiB，8192-1048576)", Category: "安全"},
+	{Key: consts.ConfigArgon2Iterations, Value: "3", Desc: "Argon2id 迭代次数 (1-10)", Category: "安全"},
+	{Key: consts.ConfigArgon2Parallelism, Value: "2", Desc: "Argon2id 并行度 (1-16)", Category: "安全"},
+	{Key: consts.ConfigVerificationResendCooldown, Value: "60", Desc: "重发验证邮件的冷却时间 (秒)", Category: "邮件服务"},
+	{Key: consts.ConfigPasswordResetCooldown, Value: "60", Desc: "同一邮箱重复请求重置密码邮件的冷却时间 (秒，冷却期内的请求不再发信)", Category: "邮件服务"},
+	{Key: consts.ConfigPasswordResetTTLMinutes, Value: "15", Desc: "重置密码链接的有效期 (分钟)", Category: "安全"},
+	{Key: consts.ConfigEmailVerifyTTLHours, Value: "0.5", Desc: "邮箱验证与修改邮箱确认链接的有效期 (小时，可为小数，如 0.5 表示 30 分钟)", Category: "安全"},
+	{Key: consts.ConfigUserTokenHours, Value: "0", Desc: "普通用户登录有效期 (小时，0 表示使用配置文件设置)", Category: "安全"},
+	{Key: consts.ConfigAdminTokenHours, Value: "0", Desc: "管理员登录有效期 (小时，0 表示使用配置文件设置)", Category: "安全"},
+	{Key: consts.ConfigRememberMeHours, Value: "720", Desc: "登录时勾选记住我的有效期 (小时，0 表示不支持记住我)", Category: "安全"},
+	{Key: consts.ConfigStorageBackend, Value: "local", Desc: "图片存储后端 (local: 本地磁盘, webdav: WebDAV, oss: 阿里云 OSS；切换后已有图片不会自动迁移)", Category: "存储"},
+	{Key: consts.ConfigWebDAVURL, Value: "", Desc: "WebDAV 存储根目录地址 (如 https://nas.example.com/dav/images/)", Category: "存储"},
+	{Key: consts.ConfigWebDAVUsername, Value: "", Desc: "WebDAV 用户名", Category: "存储"},
+	{Key: consts.ConfigWebDAVPassword, Value: "", Desc: "WebDAV 密码", Category: "存储"},
+	{Key: consts.ConfigOSSEndpoint, Value: "", Desc: "阿里云 OSS Endpoint (如 oss-cn-hangzhou.aliyuncs.com)", Category: "存储"},
+	{Key: consts.ConfigOSSBucket, Value: "", Desc: "阿里云 OSS Bucket 名称", Category: "存储"},
+	{Key: consts.ConfigOSSAccessKeyID, Value: "", Desc: "阿里云 OSS AccessKey ID", Category: "存储"},
+	{Key: consts.ConfigOSSAccessKeySecret, Value: "", Desc: "阿里云 OSS AccessKey Secret", Category: "存储"},
+	{Key: consts.ConfigPresignTTLSeconds, Value: "0", Desc: "对象存储限时访问地址有效期 (秒)，大于 0 时图片请求重定向到签名地址 (0 表示由本服务转发)", Category: "存储"},
+	{Key: consts.ConfigOSSCustomDomain, Value: "", Desc: "OSS 自定义域名或 CDN 地址 (用于图片公开链接，留空使用 Bucket 默认域名)", Category: "存储"},
+	{Key: consts.ConfigStorageLayout, Value: StorageLayoutDate, Desc: "新上传图片的路径布局 (date: 按日期分目录, hash: 按内容哈希前缀分片，仅影响新上传)", Category: "存储"},
+	{Key: consts.ConfigTrackImageViews, Value: "true", Desc: "统计图片访问次数与最近访问时间 (关闭后不再记录)", Category: "常规"},
+	{Key: consts.ConfigImageViewsExcludeBots, Value: "true", Desc: "统计访问次数时忽略 HEAD 请求与爬虫", Category: "常规"},
+	{Key: consts.ConfigHotlinkProtection, Value: "false", Desc: "开启图片防盗链 (按 Referer 来源过滤图片请求)", Category: "安全"},
+	{Key: consts.ConfigHotlinkAllowedReferers, Value: "", Desc: "防盗链允许的来源域名 (逗号分隔，支持 *.example.com，本站域名始终允许)", Category: "安全"},
+	{Key: consts.ConfigHotlinkAllowEmptyReferer, Value: "true", Desc: "防盗链是否允许无 Referer 的请求 (如直接在浏览器中打开)", Category: "安全"},
+	{Key: consts.ConfigHotlinkRedirectURL, Value: "", Desc: "被防盗链拦截时重定向到的占位图地址 (留空返回 403)", Category: "安全"},
+	{Key: consts.ConfigReservedUsernames, Value: "admin,administrator,root,system,audit,security,support,api,www,mail", Desc: "保留用户名列表 (逗号分隔，忽略大小写)", Category: "安全"},
 }
 
+// UpdateSetting 校验并保存单个配置项，仅清理该配置项的缓存
+func UpdateSetting(key, value string) error {
+	return UpdateSettings(map[string]string{key: value})
+}
+
+// UpdateSettings 校验并批量保存配置项 (同一事务)，任一配置值不合法时全部不保存并返回 *SettingValidationError
+// 保存成功后仅清理受影响配置项的缓存
+func UpdateSettings(values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(values))
+	normalized := make(map[string]string, len(values))
+	for key, value := range values {
+		if err := ValidateSetting(key, value); err != nil {
+			return err
+		}
+		if settingSpecs[key].Type != SettingTypeString {
+			value = strings.TrimSpace(value)
+		}
+		keys = append(keys, key)
+		normalized[key] = value
+	}
+	sort.Strings(keys)
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			setting := model.Setting{Key: key, Value: normalized[key]}
+
+			// 使用 Model(&setting) 会利用主键 Key 进行条件匹配
+			result := tx.Model(&setting).Select("Value").Updates(setting)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			// 如果没有记录被更新（说明不存在），则按默认配置补全描述后创建
+			if result.RowsAffected == 0 {
+				for _, def := range DefaultSettings {
+					if def.Key == key {
+						setting.Desc, setting.Category = def.Desc, def.Category
+						break
+					}
+				}
+				if err := tx.Create(&setting).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	InvalidateSettingKeys(keys...)
+	return nil
+}
+
+// ClearCache 清空全部配置缓存
 func ClearCache() {
+	settingsCacheGeneration.Add(1)
 	settingsCache.Range(func(key, value interface{}) bool {
 		settingsCache.Delete(key)
 		return true
 	})
 }
 
+// InvalidateKey 清除单个配置项的缓存，下次读取时重新从数据库加载
+func InvalidateKey(key string) {
+	settingsCacheGeneration.Add(1)
+	settingsCache.Delete(key)
+}
+
+// ApplyLogSettings 按当前配置设置全局日志级别与格式
+func ApplyLogSettings() {
+	logging.Configure(GetString(consts.ConfigLogLevel), GetString(consts.ConfigLogFormat))
+}
+
 func InitializeSettings() {
 	for _, def := range DefaultSettings {
 		var count int64
@@ -63,19 +250,70 @@ func InitializeSettings() {
 	}
 }
 
+// WarmSettingsCache 启动时一次性读取全部已知配置项并写入缓存，避免冷启动时的首批请求逐项查询数据库
+// 数据库中缺失的配置项按默认值缓存；查询失败时只记录日志，之后按需从数据库加载
+func WarmSettingsCache() {
+	if db.DB == nil {
+		log.Println("⚠️ 数据库未初始化，跳过配置缓存预热")
+		return
+	}
+	keys := make([]string, 0, len(DefaultSettings))
+	values := make(map[string]string, len(DefaultSettings))
+	for _, def := range DefaultSettings {
+		keys = append(keys, def.Key)
+		values[def.Key] = def.Value
+	}
+
+	generation := settingsCacheGeneration.Load()
+	var settings []model.Setting
+	// 使用 map 条件由 GORM 按方言引用列名 (key 在 MySQL 中是保留字)
+	if err := db.DB.Where(map[string]any{"key": keys}).Find(&settings).Error; err != nil {
+		log.Printf("⚠️ 预热配置缓存失败: %v", err)
+		return
+	}
+	for _, setting := range settings {
+		values[setting.Key] = setting.Value
+	}
+
+	// 读取期间有配置被修改时放弃预热，避免回填旧值
+	if settingsCacheGeneration.Load() != generation {
+		return
+	}
+	expiresAt := time.Now().Add(settingsCacheTTL)
+	for key, value := range values {
+		settingsCache.Store(key, cachedSetting{Value: value, ExpiresAt: expiresAt})
+	}
+}
+
 func GetString(key string) string {
 	if val, ok := settingsCache.Load(key); ok {
-		strVal, ok := val.(string)
-		if !ok {
-			settingsCache.Delete(key)
-		} else {
-			if strVal == DefaultValueNotFound {
+		if cached, ok := val.(cachedSetting); ok && time.Now().Before(cached.ExpiresAt) {
+			if cached.Value == DefaultValueNotFound {
 				return ""
 			}
-			return strVal
+			return cached.Value
+		}
+	}
+
+	// 缓存未命中或已过期：同一配置项的并发读取只触发一次数据库加载
+	val, _, _ := settingsLoadGroup.Do(key, func() (interface{}, error) {
+		generation := settingsCacheGeneration.Load()
+		value := loadSetting(key)
+		if settingsCacheGeneration.Load() == generation {
+			settingsCache.Store(key, cachedSetting{Value: value, ExpiresAt: time.Now().Add(settingsCacheTTL)})
 		}
+		return value, nil
+	})
+
+	value, _ := val.(string)
+	if value == DefaultValueNotFound {
+		return ""
 	}
+	return value
+}
 
+// loadSetting 从数据库读取配置值，不存在时写入默认配置；均不存在时返回 DefaultValueNotFound
+func loadSetting(key string) string {
 	var setting model.Setting
 	if err := db.DB.Where("key = ?", key).First(&setting).Error; err != nil {
 		// 数据库没查到，尝试查找默认配置
@@ -85,19 +323,13 @@ func GetString(key string) string {
 				newSetting := def
 				// 尝试写入数据库 (忽略错误，防止并发写入导致的主键冲突)
 				db.DB.Create(&newSetting)
-
-				settingsCache.Store(key, newSetting.Value)
 				return newSetting.Value
 			}
 		}
 
-		// 没查到，往缓存里存 DefaultValueNotFound 标记
-		settingsCache.Store(key, DefaultValueNotFound)
-		return ""
+		// 没查到，缓存 DefaultValueNotFound 标记
+		return DefaultValueNotFound
 	}
-	// 数据库查到，写入缓存
-	settingsCache.Store(key, setting.Value)
-
 	return setting.Value
 }
 
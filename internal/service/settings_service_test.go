@@ -0,0 +1,260 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestDefaultSettingsPassValidation(t *testing.T) {
+	for _, def := range DefaultSettings {
+		if err := ValidateSetting(def.Key, def.Value); err != nil {
+			t.Errorf("default %s = %q: %v", def.Key, def.Value, err)
+		}
+	}
+}
+
+func TestValidateSetting(t *testing.T) {
+	tests := []struct {
+		key, value string
+		ok         bool
+	}{
+		{consts.ConfigAllowRegister, "false", true},
+		{consts.ConfigAllowRegister, "yes please", false},
+		{consts.ConfigRateLimitUploadBurst, "3", true},
+		{consts.ConfigRateLimitUploadBurst, "0", false},
+		{consts.ConfigRateLimitUploadBurst, "1.5", false},
+		{consts.ConfigRateLimitUploadRPS, "0.5", true},
+		{consts.ConfigRateLimitUploadRPS, "NaN", false},
+		{consts.ConfigBaseURL, "https://pic.example.com", true},
+		{consts.ConfigBaseURL, "not a url", false},
+		{consts.ConfigInternalRedirectMode, InternalRedirectNginx, true},
+		{consts.ConfigInternalRedirectMode, "lighttpd", false},
+		{consts.ConfigAdminIPAllowlist, "10.0.0.0/8, 192.168.1.1", true},
+		{consts.ConfigAdminIPAllowlist, "10.0.0.0/33", false},
+		{"no_such_setting", "x", false},
+	}
+	for _, tt := range tests {
+		err := ValidateSetting(tt.key, tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateSetting(%s, %q) = %v, want ok %v", tt.key, tt.value, err, tt.ok)
+		}
+		if err != nil {
+			if verr, ok := AsSettingValidationError(err); !ok || verr.Key != tt.key {
+				t.Errorf("ValidateSetting(%s, %q) error = %#v, want SettingValidationError for the key", tt.key, tt.value, err)
+			}
+		}
+	}
+}
+
+func TestUpdateSettingsRejectsWholeBatch(t *testing.T) {
+	setupTestDB(t)
+	before := GetInt(consts.ConfigRateLimitUploadBurst)
+
+	err := UpdateSettings(map[string]string{
+		consts.ConfigRateLimitUploadBurst: "9",
+		consts.ConfigAllowRegister:        "maybe",
+	})
+	verr, ok := AsSettingValidationError(err)
+	if !ok || verr.Key != consts.ConfigAllowRegister {
+		t.Fatalf("error = %v, want validation error for %s", err, consts.ConfigAllowRegister)
+	}
+
+	// 任一值不合法时整批不保存
+	var stored model.Setting
+	if err := db.DB.Where("key = ?", consts.ConfigRateLimitUploadBurst).First(&stored).Error; err != nil {
+		t.Fatalf("load setting: %v", err)
+	}
+	if got := GetInt(consts.ConfigRateLimitUploadBurst); got != before || stored.Value == "9" {
+		t.Fatalf("burst = %d (stored %q), want unchanged %d", got, stored.Value, before)
+	}
+}
+
+func TestUpdateSettingsTrimsAndRefreshesCache(t *testing.T) {
+	setupTestDB(t)
+	// 先读取一次，确认缓存中的旧值会被清理
+	_ = GetInt(consts.ConfigRateLimitUploadBurst)
+	_ = GetString(consts.ConfigSiteName)
+
+	if err := UpdateSettings(map[string]string{
+		consts.ConfigRateLimitUploadBurst: " 7 ",
+		consts.ConfigSiteName:             " My Pics ",
+	}); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+	if got := GetInt(consts.ConfigRateLimitUploadBurst); got != 7 {
+		t.Fatalf("burst = %d, want 7", got)
+	}
+	// 字符串类型保留原值
+	if got := GetString(consts.ConfigSiteName); got != " My Pics " {
+		t.Fatalf("site name = %q", got)
+	}
+}
+
+// setStoredSetting 直接修改数据库中的配置值，绕过缓存清理
+func setStoredSetting(t *testing.T, key, value string) {
+	t.Helper()
+	if err := db.DB.Model(&model.Setting{}).Where("key = ?", key).Update("value", value).Error; err != nil {
+		t.Fatalf("update %s: %v", key, err)
+	}
+}
+
+func TestInvalidateKeyOnlyDropsThatKey(t *testing.T) {
+	setupTestDB(t)
+	_ = GetString(consts.ConfigSiteName)
+	_ = GetString(consts.ConfigSiteDescription)
+	setStoredSetting(t, consts.ConfigSiteName, "new name")
+	setStoredSetting(t, consts.ConfigSiteDescription, "new description")
+
+	InvalidateKey(consts.ConfigSiteName)
+	if got := GetString(consts.ConfigSiteName); got != "new name" {
+		t.Fatalf("site name = %q, want reloaded value", got)
+	}
+	if got := GetString(consts.ConfigSiteDescription); got == "new description" {
+		t.Fatal("site description reloaded although only the site name was invalidated")
+	}
+}
+
+func TestSettingsCacheExpires(t *testing.T) {
+	setupTestDB(t)
+	_ = GetString(consts.ConfigSiteName)
+	setStoredSetting(t, consts.ConfigSiteName, "fresh")
+
+	// 模拟缓存到期
+	settingsCache.Store(consts.ConfigSiteName, cachedSetting{Value: "stale", ExpiresAt: time.Now().Add(-time.Second)})
+	if got := GetString(consts.ConfigSiteName); got != "fresh" {
+		t.Fatalf("site name = %q, want value reloaded after expiry", got)
+	}
+}
+
+func TestConcurrentSettingReloadsAgree(t *testing.T) {
+	setupTestDB(t)
+	setStoredSetting(t, consts.ConfigSiteName, "shared")
+	InvalidateKey(consts.ConfigSiteName)
+
+	var wg sync.WaitGroup
+	results := make([]string, 16)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = GetString(consts.ConfigSiteName)
+		}(i)
+	}
+	wg.Wait()
+	for i, got := range results {
+		if got != "shared" {
+			t.Fatalf("reader %d got %q", i, got)
+		}
+	}
+}
+
+// countQueries 统计之后在 d 上执行的查询次数
+func countQueries(t testing.TB, d *gorm.DB) *atomic.Int64 {
+	t.Helper()
+	var n atomic.Int64
+	if err := d.Callback().Query().After("gorm:query").Register("test:count_queries", func(*gorm.DB) { n.Add(1) }); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	return &n
+}
+
+func TestWarmSettingsCacheLoadsAllKeysInOneQuery(t *testing.T) {
+	d := setupTestDB(t)
+	setStoredSetting(t, consts.ConfigSiteName, "warmed")
+	// 数据库中缺失的配置项按默认值缓存
+	if err := d.Where("key = ?", consts.ConfigSiteDescription).Delete(&model.Setting{}).Error; err != nil {
+		t.Fatalf("delete setting: %v", err)
+	}
+	ClearCache()
+
+	queries := countQueries(t, d)
+	WarmSettingsCache()
+	if got := queries.Load(); got != 1 {
+		t.Fatalf("warm-up ran %d queries, want 1", got)
+	}
+
+	if got := GetString(consts.ConfigSiteName); got != "warmed" {
+		t.Fatalf("site name = %q, want stored value", got)
+	}
+	if got, want := GetString(consts.ConfigSiteDescription), defaultSettingValue(t, consts.ConfigSiteDescription); got != want {
+		t.Fatalf("site description = %q, want default %q", got, want)
+	}
+	_ = GetInt(consts.ConfigAccountLockThreshold)
+	_ = GetBool(consts.ConfigAllowRegister)
+	if got := queries.Load(); got != 1 {
+		t.Fatalf("reads after warm-up ran %d queries, want 0", got-1)
+	}
+}
+
+func TestWarmSettingsCacheWithoutDatabase(t *testing.T) {
+	setupTestDB(t)
+	prev := db.DB
+	db.DB = nil
+	defer func() { db.DB = prev }()
+	// 只记录日志，不应 panic，也不写入缓存
+	WarmSettingsCache()
+	if _, ok := settingsCache.Load(consts.ConfigSiteName); ok {
+		t.Fatal("warm-up without a database should not fill the cache")
+	}
+}
+
+func defaultSettingValue(t *testing.T, key string) string {
+	t.Helper()
+	for _, def := range DefaultSettings {
+		if def.Key == key {
+			return def.Value
+		}
+	}
+	t.Fatalf("no default for %s", key)
+	return ""
+}
+
+func TestWarmSettingsCacheQuotesKeyColumn(t *testing.T) {
+	d := setupTestDB(t)
+	var queries []string
+	if err := d.Callback().Query().After("gorm:query").Register("test:capture_sql", func(tx *gorm.DB) {
+		queries = append(queries, tx.Statement.SQL.String())
+	}); err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	WarmSettingsCache()
+	// key 在 MySQL 中是保留字，列名必须被引用
+	if len(queries) != 1 || !strings.Contains(queries[0], "`key` IN") {
+		t.Fatalf("warm-up queries = %q, want the key column quoted", queries)
+	}
+}
+
+// BenchmarkGetString 对比缓存命中与每次失效后重新加载的读取开销
+func BenchmarkGetString(b *testing.B) {
+	d := setupTestDB(b)
+	queries := countQueries(b, d)
+
+	b.Run("cached", func(b *testing.B) {
+		_ = GetString(consts.ConfigSiteName)
+		before := queries.Load()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = GetString(consts.ConfigSiteName)
+		}
+		b.ReportMetric(float64(queries.Load()-before)/float64(b.N), "queries/op")
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		before := queries.Load()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			InvalidateKey(consts.ConfigSiteName)
+			_ = GetString(consts.ConfigSiteName)
+		}
+		b.ReportMetric(float64(queries.Load()-before)/float64(b.N), "queries/op")
+	})
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"log"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// settingsVersionID 配置版本号记录的固定主键
+const settingsVersionID = 1
+
+// settingsChangeRetention 保留的配置变更记录数，落后更多版本的节点整体清理缓存
+const settingsChangeRetention = 100
+
+var (
+	// localSettingsVersion 本节点已应用的配置版本号
+	localSettingsVersion atomic.Int64
+	settingsSyncMu       sync.Mutex
+	settingsSyncStopCh   chan struct{}
+	settingsSyncDoneCh   chan struct{}
+)
+
+// InvalidateSettings 配置变更后调用：清理本地缓存，并在开启多节点同步时递增全局版本号通知其他节点
+func InvalidateSettings() {
+	ClearCache()
+	ApplyLogSettings()
+	notifySettingsChanged(nil)
+}
+
+// InvalidateSettingKeys 仅清理指定配置项的本地缓存，并通知其他节点清理相同的配置项
+func InvalidateSettingKeys(keys ...string) {
+	invalidateLocalKeys(keys)
+	notifySettingsChanged(keys)
+}
+
+// invalidateLocalKeys 清理本节点指定配置项的缓存，涉及日志配置时重新应用
+func invalidateLocalKeys(keys []string) {
+	applyLog := false
+	for _, key := range keys {
+		InvalidateKey(key)
+		if key == consts.ConfigLogLevel || key == consts.ConfigLogFormat {
+			applyLog = true
+		}
+	}
+	if applyLog {
+		ApplyLogSettings()
+	}
+}
+
+// notifySettingsChanged 开启多节点同步时递增全局版本号并记录修改的配置项 (nil 表示全部)
+func notifySettingsChanged(keys []string) {
+	if config.Get().Cluster.SettingsSyncInterval <= 0 {
+		return
+	}
+
+	version, err := bumpSettingsVersion(keys)
+	if err != nil {
+		log.Printf("Bump settings version error: %v\n", err)
+		return
+	}
+	localSettingsVersion.Store(version)
+}
+
+// StartSettingsSync 启动配置版本轮询协程 (已启动时不重复启动)
+// 未配置 cluster.settings_sync_interval 时不启动，仅依赖本地缓存清理
+func StartSettingsSync() {
+	interval := config.Get().Cluster.SettingsSyncInterval
+	if interval <= 0 {
+		return
+	}
+	startSettingsSync(time.Duration(interval) * time.Second)
+}
+
+func startSettingsSync(interval time.Duration) {
+	settingsSyncMu.Lock()
+	defer settingsSyncMu.Unlock()
+	if settingsSyncStopCh != nil {
+		return
+	}
+
+	if version, err := loadSettingsVersion(); err == nil {
+		localSettingsVersion.Store(version)
+	}
+
+	settingsSyncStopCh = make(chan struct{})
+	settingsSyncDoneCh = make(chan struct{})
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				SyncSettingsVersion()
+			case <-stop:
+				return
+			}
+		}
+	}(settingsSyncStopCh, settingsSyncDoneCh)
+	log.Printf("✅ 已开启多节点配置同步，轮询间隔 %v", interval)
+}
+
+// StopSettingsSync 停止配置版本轮询协程，等待正在进行的同步完成
+func StopSettingsSync() {
+	settingsSyncMu.Lock()
+	stop, done := settingsSyncStopCh, settingsSyncDoneCh
+	settingsSyncStopCh = nil
+	settingsSyncMu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// SyncSettingsVersion 检查全局配置版本号，若其他节点已修改配置则清理本地缓存
+// 能读取到期间全部变更记录时只清理修改过的配置项，否则整体清理
+func SyncSettingsVersion() {
+	version, err := loadSettingsVersion()
+	if err != nil {
+		log.Printf("Load settings version error: %v\n", err)
+		return
+	}
+
+	previous := localSettingsVersion.Swap(version)
+	if previous == version {
+		return
+	}
+	keys, ok := changedSettingKeys(previous, version)
+	if !ok {
+		ClearCache()
+		ApplyLogSettings()
+		return
+	}
+	invalidateLocalKeys(keys)
+}
+
+// changedSettingKeys 返回版本 (from, to] 之间修改的配置项
+// 变更记录缺失 (已被清理或版本号回退) 或其中有整体清理时 ok 为 false
+func changedSettingKeys(from, to int64) (keys []string, ok bool) {
+	if from > to {
+		return nil, false
+	}
+	var changes []model.SettingsChange
+	if err := db.DB.Where("version > ? AND version <= ?", from, to).Find(&changes).Error; err != nil {
+		log.Printf("Load settings changes error: %v\n", err)
+		return nil, false
+	}
+	if int64(len(changes)) != to-from {
+		return nil, false
+	}
+	for _, change := range changes {
+		if change.Keys == "" {
+			return nil, false
+		}
+		keys = append(keys, strings.Split(change.Keys, ",")...)
+	}
+	return keys, true
+}
+
+func loadSettingsVersion() (int64, error) {
+	var sv model.SettingsVersion
+	err := db.DB.Where("id = ?", settingsVersionID).Limit(1).Find(&sv).Error
+	return sv.Version, err
+}
+
+func bumpSettingsVersion(keys []string) (int64, error) {
+	var sv model.SettingsVersion
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// 记录不存在时先创建，存在则忽略
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.SettingsVersion{ID: settingsVersionID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.SettingsVersion{}).Where("id = ?", settingsVersionID).
+			UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&sv, settingsVersionID).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.SettingsChange{Version: sv.Version, Keys: strings.Join(keys, ",")}).Error; err != nil {
+			return err
+		}
+		return tx.Where("version <= ?", sv.Version-settingsChangeRetention).Delete(&model.SettingsChange{}).Error
+	})
+	return sv.Version, err
+}
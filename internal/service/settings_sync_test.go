@@ -0,0 +1,127 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+// cacheSiteSettings 缓存网站名称与描述后直接修改数据库，模拟其他节点修改了配置
+func cacheSiteSettings(t *testing.T, name, description string) {
+	t.Helper()
+	_ = GetString(consts.ConfigSiteName)
+	_ = GetString(consts.ConfigSiteDescription)
+	setStoredSetting(t, consts.ConfigSiteName, name)
+	setStoredSetting(t, consts.ConfigSiteDescription, description)
+}
+
+// bumpFromOtherNode 模拟其他节点递增配置版本号
+func bumpFromOtherNode(t *testing.T, keys ...string) {
+	t.Helper()
+	if _, err := bumpSettingsVersion(keys); err != nil {
+		t.Fatalf("bump settings version: %v", err)
+	}
+}
+
+func TestSyncSettingsVersionInvalidatesChangedKeys(t *testing.T) {
+	setupTestDB(t)
+	cacheSiteSettings(t, "name v1", "description v1")
+
+	bumpFromOtherNode(t, consts.ConfigSiteName)
+	SyncSettingsVersion()
+	if got := GetString(consts.ConfigSiteName); got != "name v1" {
+		t.Fatalf("site name = %q, want reloaded value", got)
+	}
+	if got := GetString(consts.ConfigSiteDescription); got == "description v1" {
+		t.Fatal("site description reloaded although only the site name changed")
+	}
+
+	// 整体清理的变更使所有配置重新加载
+	bumpFromOtherNode(t)
+	SyncSettingsVersion()
+	if got := GetString(consts.ConfigSiteDescription); got != "description v1" {
+		t.Fatalf("site description = %q, want reloaded after a full clear", got)
+	}
+}
+
+func TestSyncSettingsVersionMergesSkippedVersions(t *testing.T) {
+	setupTestDB(t)
+	cacheSiteSettings(t, "name v1", "description v1")
+
+	bumpFromOtherNode(t, consts.ConfigSiteName)
+	bumpFromOtherNode(t, consts.ConfigSiteDescription)
+	SyncSettingsVersion()
+	if GetString(consts.ConfigSiteName) != "name v1" || GetString(consts.ConfigSiteDescription) != "description v1" {
+		t.Fatal("keys changed in every skipped version should be reloaded")
+	}
+	if got := localSettingsVersion.Load(); got != 2 {
+		t.Fatalf("local version = %d, want 2", got)
+	}
+}
+
+func TestSyncSettingsVersionClearsCacheWhenChangesAreMissing(t *testing.T) {
+	setupTestDB(t)
+	for i := 0; i < settingsChangeRetention+5; i++ {
+		bumpFromOtherNode(t, consts.ConfigSiteLogo)
+	}
+	var count int64
+	db.DB.Model(&model.SettingsChange{}).Count(&count)
+	if count != settingsChangeRetention {
+		t.Fatalf("kept %d change records, want %d", count, settingsChangeRetention)
+	}
+
+	// 本节点落后的版本中最早的变更记录已被清理
+	cacheSiteSettings(t, "name v1", "description v1")
+	SyncSettingsVersion()
+	if GetString(consts.ConfigSiteName) != "name v1" || GetString(consts.ConfigSiteDescription) != "description v1" {
+		t.Fatal("a node behind the retained changes should clear its whole cache")
+	}
+
+	// 版本号回退 (例如数据库被恢复) 时同样整体清理
+	localSettingsVersion.Store(settingsChangeRetention * 10)
+	cacheSiteSettings(t, "name v2", "description v2")
+	SyncSettingsVersion()
+	if GetString(consts.ConfigSiteName) != "name v2" || GetString(consts.ConfigSiteDescription) != "description v2" {
+		t.Fatal("a version that went backwards should clear the whole cache")
+	}
+}
+
+func TestSettingsSyncStop(t *testing.T) {
+	setupTestDB(t)
+	cacheSiteSettings(t, "name v1", "description v1")
+	startSettingsSync(5 * time.Millisecond)
+	t.Cleanup(StopSettingsSync)
+
+	// 轮询协程发现其他节点的修改并清理对应缓存
+	bumpFromOtherNode(t, consts.ConfigSiteName)
+	deadline := time.Now().Add(time.Second)
+	for GetString(consts.ConfigSiteName) != "name v1" {
+		if time.Now().After(deadline) {
+			t.Fatal("settings sync did not pick up the change")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		StopSettingsSync()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopSettingsSync did not return")
+	}
+
+	// 停止后不再轮询
+	applied := localSettingsVersion.Load()
+	bumpFromOtherNode(t, consts.ConfigSiteName)
+	time.Sleep(30 * time.Millisecond)
+	if got := localSettingsVersion.Load(); got != applied {
+		t.Fatalf("local settings version changed from %d to %d after stop", applied, got)
+	}
+	// 重复停止不阻塞
+	StopSettingsSync()
+}
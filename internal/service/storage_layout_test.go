@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageLayoutKeys(t *testing.T) {
+	now := time.Date(2026, 2, 13, 10, 0, 0, 0, time.Local)
+	filename, key := datedImageKey(now, ".png")
+	if !strings.HasSuffix(filename, ".png") || key != "2026/02/13/"+filename {
+		t.Fatalf("dated key = %q (%q)", key, filename)
+	}
+
+	hash := strings.Repeat("ab", 32)
+	filename, key = hashedImageKey(hash, ".jpg")
+	if !regexp.MustCompile(`^ab/ab/`+hash+`-[0-9a-f]{8}\.jpg$`).MatchString(key) || path.Base(key) != filename {
+		t.Fatalf("hashed key = %q (%q)", key, filename)
+	}
+	// 相同内容的两次上传使用不同的文件
+	if _, again := hashedImageKey(hash, ".jpg"); again == key {
+		t.Fatal("hashed keys for the same content collide")
+	}
+}
+
+func TestUploadUsesConfiguredLayout(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "layout")
+
+	setTestSettings(t, map[string]string{consts.ConfigStorageLayout: StorageLayoutHash})
+	hashed, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "a.png"), user.ID, nil)
+	if err != nil {
+		t.Fatalf("hash layout upload: %v", err)
+	}
+	var stored model.Image
+	if err := db.DB.First(&stored, hashed.ID).Error; err != nil {
+		t.Fatalf("load image: %v", err)
+	}
+	if want := stored.Hash[0:2] + "/" + stored.Hash[2:4] + "/" + stored.Hash + "-"; !strings.HasPrefix(stored.Path, want) {
+		t.Fatalf("hash layout path = %q, want prefix %q", stored.Path, want)
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigStorageLayout: StorageLayoutDate})
+	dated, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "b.png"), user.ID, nil)
+	if err != nil {
+		t.Fatalf("date layout upload: %v", err)
+	}
+	if want := time.Now().Format("2006/01/02") + "/"; !strings.HasPrefix(dated.Path, want) {
+		t.Fatalf("date layout path = %q, want prefix %q", dated.Path, want)
+	}
+
+	// 两种布局的文件都已提交到存储中，存储根目录下没有遗留的临时文件
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	for _, img := range []*model.Image{hashed, dated} {
+		if got, err := readObject(t, store, img.Path); err != nil || len(got) == 0 {
+			t.Fatalf("stored file %s: %d bytes, %v", img.Path, len(got), err)
+		}
+	}
+	entries, err := os.ReadDir(config.Get().Upload.Path)
+	if err != nil {
+		t.Fatalf("read upload root: %v", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			t.Fatalf("unexpected file %s left in the upload root", e.Name())
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+)
+
+// StorageMigrationResult 存储迁移结果统计
+type StorageMigrationResult struct {
+	Total   int `json:"total"`   // 图片记录总数
+	Copied  int `json:"copied"`  // 本次复制的文件数
+	Skipped int `json:"skipped"` // 目标已存在且内容一致而跳过的文件数
+	Missing int `json:"missing"` // 源存储中不存在的文件数
+	Failed  int `json:"failed"`  // 复制或校验失败的文件数
+}
+
+// MigrateStorage 将所有图片文件从 from 复制到 to
+// 按图片 ID 分批遍历，目标已存在且大小与哈希一致的文件直接跳过，因此中断后可重复执行继续迁移；
+// 复制后校验目标的大小与哈希才视为成功，deleteSource 为 true 时随后删除源文件。
+// 迁移期间不修改数据库，可在服务运行时执行。progress 可为 nil。
+func MigrateStorage(from, to storage.Storage, batchSize int, deleteSource bool, progress func(done, total int)) (*StorageMigrationResult, error) {
+	if from == nil || to == nil {
+		return nil, errors.New("源存储与目标存储不能为空")
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	var total int64
+	if err := db.DB.Model(&model.Image{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	result := &StorageMigrationResult{Total: int(total)}
+
+	ctx := context.Background()
+	done := 0
+	var lastID uint
+	for {
+		var images []model.Image
+		if err := db.DB.Select("id", "path", "size", "hash", "webp_path").Where("id > ?", lastID).
+			Order("id asc").Limit(batchSize).Find(&images).Error; err != nil {
+			return result, err
+		}
+		if len(images) == 0 {
+			break
+		}
+
+		for _, img := range images {
+			migrateImageFile(ctx, from, to, img, deleteSource, result)
+			done++
+			if progress != nil {
+				progress(done, result.Total)
+			}
+		}
+		lastID = images[len(images)-1].ID
+	}
+
+	return result, nil
+}
+
+// migrateImageFile 迁移单个图片文件并更新统计
+// 目标已存在时校验大小与哈希，一致才视为已迁移；不一致时重新复制。
+// 只有确认目标内容与记录一致后才会删除源文件
+func migrateImageFile(ctx context.Context, from, to storage.Storage, img model.Image, deleteSource bool, result *StorageMigrationResult) {
+	key, err := storage.CleanKey(img.Path)
+	if err != nil {
+		log.Printf("[Migrate] 跳过非法图片路径 %q (id=%d)", img.Path, img.ID)
+		result.Failed++
+		return
+	}
+
+	want := objectDigest{Size: img.Size, Hash: img.Hash}
+	if want.Hash == "" {
+		// 记录中没有哈希时以源文件为准
+		want, err = storedObjectDigest(ctx, from, key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				result.Missing++
+				return
+			}
+			log.Printf("[Migrate] 读取源文件失败 %s: %v", key, err)
+			result.Failed++
+			return
+		}
+	}
+
+	got, err := storedObjectDigest(ctx, to, key)
+	switch {
+	case err == nil && got == want:
+		result.Skipped++
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		log.Printf("[Migrate] 检查目标文件失败 %s: %v", key, err)
+		result.Failed++
+		return
+	default:
+		if err == nil {
+			log.Printf("[Migrate] 目标文件与记录不一致，重新复制 %s", key)
+		}
+		if err := copyStoredObject(ctx, from, to, key, want); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				result.Missing++
+				return
+			}
+			log.Printf("[Migrate] 复制文件失败 %s: %v", key, err)
+			result.Failed++
+			return
+		}
+		result.Copied++
+	}
+
+	if deleteSource {
+		if err := from.Delete(ctx, key); err != nil {
+			log.Printf("[Migrate] 删除源文件失败 %s: %v", key, err)
+		}
+	}
+	if img.WebpPath != "" {
+		migrateWebPAlternative(ctx, from, to, img.WebpPath, deleteSource)
+	}
+}
+
+// migrateWebPAlternative 迁移图片的 WebP 备选文件，失败只记录日志且不计入迁移结果
+// (备选文件缺失时直接返回原图)。记录中没有备选文件的大小与哈希，先整体读入内存，
+// 目标内容与源文件一致时跳过复制；确认一致后才删除源文件
+func migrateWebPAlternative(ctx context.Context, from, to storage.Storage, altKey string, deleteSource bool) {
+	key, err := storage.CleanKey(altKey)
+	if err != nil {
+		log.Printf("[Migrate] 跳过非法备选文件路径 %q", altKey)
+		return
+	}
+	r, err := from.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("[Migrate] 读取备选文件失败 %s: %v", key, err)
+		}
+		return
+	}
+	data, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		log.Printf("[Migrate] 读取备选文件失败 %s: %v", key, err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	want := objectDigest{Size: int64(len(data)), Hash: hex.EncodeToString(sum[:])}
+
+	if got, err := storedObjectDigest(ctx, to, key); err != nil || got != want {
+		if err := to.Put(ctx, key, bytes.NewReader(data), want.Size); err != nil {
+			log.Printf("[Migrate] 复制备选文件失败 %s: %v", key, err)
+			return
+		}
+		if got, err := storedObjectDigest(ctx, to, key); err != nil || got != want {
+			log.Printf("[Migrate] 备选文件复制后校验失败 %s", key)
+			return
+		}
+	}
+	if deleteSource {
+		if err := from.Delete(ctx, key); err != nil {
+			log.Printf("[Migrate] 删除源备选文件失败 %s: %v", key, err)
+		}
+	}
+}
+
+// objectDigest 对象内容的大小与 SHA-256 (Hex)
+type objectDigest struct {
+	Size int64
+	Hash string
+}
+
+// storedObjectDigest 读取对象并计算其大小与哈希，不存在时返回 storage.ErrNotFound
+func storedObjectDigest(ctx context.Context, s storage.Storage, key string) (objectDigest, error) {
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		return objectDigest{}, err
+	}
+	defer func() { _ = r.Close() }()
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, r)
+	if err != nil {
+		return objectDigest{}, err
+	}
+	return objectDigest{Size: n, Hash: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+// copyStoredObject 复制对象，并确认目标内容的大小与哈希与 want 一致
+func copyStoredObject(ctx context.Context, from, to storage.Storage, key string, want objectDigest) error {
+	r, err := from.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	if err := to.Put(ctx, key, r, want.Size); err != nil {
+		return err
+	}
+	got, err := storedObjectDigest(ctx, to, key)
+	if err != nil {
+		return fmt.Errorf("复制后读取目标文件失败: %s: %w", key, err)
+	}
+	if got != want {
+		return fmt.Errorf("复制后目标文件校验不一致: %s", key)
+	}
+	return nil
+}
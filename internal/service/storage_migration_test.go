@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"testing"
+	"time"
+)
+
+// createMigrationImage 写入一条图片记录，content 非 nil 时同时写入源存储
+func createMigrationImage(t *testing.T, from storage.Storage, userID uint, key string, content []byte) {
+	t.Helper()
+	sum := sha256.Sum256(content)
+	img := model.Image{
+		Filename:   key,
+		Path:       key,
+		Size:       int64(len(content)),
+		MimeType:   "image/png",
+		Hash:       hex.EncodeToString(sum[:]),
+		UploadedAt: time.Now().Unix(),
+		UserID:     userID,
+	}
+	if err := db.DB.Create(&img).Error; err != nil {
+		t.Fatalf("create image: %v", err)
+	}
+	putObject(t, from, key, content)
+}
+
+func putObject(t *testing.T, s storage.Storage, key string, content []byte) {
+	t.Helper()
+	if err := s.Put(context.Background(), key, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("put %s: %v", key, err)
+	}
+}
+
+func readObject(t *testing.T, s storage.Storage, key string) ([]byte, error) {
+	t.Helper()
+	r, err := s.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return io.ReadAll(r)
+}
+
+func TestMigrateStorageVerifiesExistingTarget(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	from, to := storage.NewLocal(t.TempDir()), storage.NewLocal(t.TempDir())
+
+	// 正常复制
+	createMigrationImage(t, from, user.ID, "a.png", []byte("image-a"))
+	// 目标已有完整副本
+	createMigrationImage(t, from, user.ID, "b.png", []byte("image-b"))
+	putObject(t, to, "b.png", []byte("image-b"))
+	// 目标只有截断的副本，需要重新复制
+	createMigrationImage(t, from, user.ID, "c.png", []byte("image-c-full"))
+	putObject(t, to, "c.png", []byte("image-c"))
+
+	result, err := MigrateStorage(from, to, 2, true, nil)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if result.Total != 3 || result.Copied != 2 || result.Skipped != 1 || result.Failed != 0 || result.Missing != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	for key, want := range map[string]string{"a.png": "image-a", "b.png": "image-b", "c.png": "image-c-full"} {
+		got, err := readObject(t, to, key)
+		if err != nil || string(got) != want {
+			t.Fatalf("target %s = %q, %v; want %q", key, got, err, want)
+		}
+		if _, err := readObject(t, from, key); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("source %s not deleted: %v", key, err)
+		}
+	}
+}
+
+func TestMigrateStorageKeepsSourceWhenTargetDiffers(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice")
+	from, to := storage.NewLocal(t.TempDir()), storage.NewLocal(t.TempDir())
+
+	// 源文件缺失且目标内容与记录不一致：不能视为已迁移
+	createMigrationImage(t, from, user.ID, "a.png", []byte("image-a"))
+	if err := from.Delete(context.Background(), "a.png"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	putObject(t, to, "a.png", []byte("corrupt"))
+
+	result, err := MigrateStorage(from, to, 10, true, nil)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if result.Missing != 1 || result.Skipped != 0 || result.Copied != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	// 复制后目标内容与记录不一致时计为失败，不删除源文件
+	createMigrationImage(t, from, user.ID, "b.png", []byte("image-b"))
+	putObject(t, from, "b.png", []byte("image-b-modified"))
+	result, err = MigrateStorage(from, to, 10, true, nil)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if result.Failed != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if got, err := readObject(t, from, "b.png"); err != nil || string(got) != "image-b-modified" {
+		t.Fatalf("source b.png = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"path/filepath"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/storage"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StorageBackendLocal  = "local"  // 本地磁盘 (upload.path)
+	StorageBackendWebDAV = "webdav" // WebDAV
+	StorageBackendOSS    = "oss"    // 阿里云 OSS
+)
+
+// 图片交由前端服务器发送的方式 (ConfigInternalRedirectMode)
+const (
+	InternalRedirectOff    = "off"    // 由本服务发送文件内容
+	InternalRedirectNginx  = "nginx"  // X-Accel-Redirect 指向 internal location
+	InternalRedirectApache = "apache" // X-Sendfile 指向文件的绝对路径 (mod_xsendfile)
+)
+
+const (
+	StorageLayoutDate = "date" // 2026/02/13/<uuid>.ext
+	StorageLayoutHash = "hash" // ab/cd/<sha256>-<随机后缀>.ext
+)
+
+var (
+	imageStorageMu sync.Mutex
+	// imageStorage 当前使用的存储实例及其配置签名，配置变化时重新创建
+	imageStorage    storage.Storage
+	imageStorageSig string
+)
+
+// ImageStorage 返回当前配置的图片存储后端
+// 存储实例会被复用 (如 WebDAV 已创建目录的缓存)，相关配置修改后自动重新创建
+func ImageStorage() (storage.Storage, error) {
+	backend := strings.ToLower(strings.TrimSpace(GetString(consts.ConfigStorageBackend)))
+	if backend == "" {
+		backend = StorageBackendLocal
+	}
+
+	var sig string
+	switch backend {
+	case StorageBackendLocal:
+		sig = backend + "|" + imageUploadRoot()
+	case StorageBackendWebDAV:
+		sig = strings.Join([]string{backend, GetString(consts.ConfigWebDAVURL),
+			GetString(consts.ConfigWebDAVUsername), GetString(consts.ConfigWebDAVPassword)}, "|")
+	case StorageBackendOSS:
+		sig = strings.Join([]string{backend, GetString(consts.ConfigOSSEndpoint), GetString(consts.ConfigOSSBucket),
+			GetString(consts.ConfigOSSAccessKeyID), GetString(consts.ConfigOSSAccessKeySecret),
+			GetString(consts.ConfigOSSCustomDomain)}, "|")
+	default:
+		return nil, errors.New("不支持的存储后端: " + backend)
+	}
+
+	imageStorageMu.Lock()
+	defer imageStorageMu.Unlock()
+	if imageStorage != nil && imageStorageSig == sig {
+		return imageStorage, nil
+	}
+
+	var store storage.Storage
+	switch backend {
+	case StorageBackendLocal:
+		store = storage.NewLocal(imageUploadRoot())
+	case StorageBackendWebDAV:
+		if GetString(consts.ConfigWebDAVURL) == "" {
+			return nil, errors.New("未配置 WebDAV 地址")
+		}
+		webdav, err := storage.NewWebDAV(GetString(consts.ConfigWebDAVURL),
+			GetString(consts.ConfigWebDAVUsername), GetString(consts.ConfigWebDAVPassword))
+		if err != nil {
+			return nil, err
+		}
+		store = webdav
+	case StorageBackendOSS:
+		oss, err := storage.NewOSS(GetString(consts.ConfigOSSEndpoint), GetString(consts.ConfigOSSBucket),
+			GetString(consts.ConfigOSSAccessKeyID), GetString(consts.ConfigOSSAccessKeySecret),
+			GetString(consts.ConfigOSSCustomDomain))
+		if err != nil {
+			return nil, err
+		}
+		store = oss
+	}
+
+	imageStorage, imageStorageSig = store, sig
+	return store, nil
+}
+
+// imageUploadRoot 返回本地图片存储根目录
+func imageUploadRoot() string {
+	uploadRoot := config.Get().Upload.Path
+	if uploadRoot == "" {
+		uploadRoot = "uploads/imgs"
+	}
+	return uploadRoot
+}
+
+// ImageURLPrefix 返回图片访问地址前缀
+// 存储后端可直接对外提供访问 (如 OSS 自定义域名) 时使用其公开地址，否则经由本服务的 upload.url_prefix 访问
+func ImageURLPrefix() string {
+	if store, err := ImageStorage(); err == nil {
+		if provider, ok := store.(storage.PublicURLProvider); ok {
+			return provider.PublicURLPrefix()
+		}
+	}
+	return servedImageURLPrefix()
+}
+
+// PublicImageOrigin 返回 ConfigPublicBaseURL 的源 (scheme://host)，供 CSP 允许从该域名加载图片，未设置时返回空字符串
+func PublicImageOrigin() string {
+	u, err := url.Parse(strings.TrimSpace(GetString(consts.ConfigPublicBaseURL)))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// servedImageURLPrefix 返回经由本服务访问图片的地址前缀 (upload.url_prefix)
+// 设置了 ConfigPublicBaseURL 时拼接为完整地址，两者首尾的 / 均可省略或重复
+func servedImageURLPrefix() string {
+	prefix := config.Get().Upload.URLPrefix
+	base := strings.TrimRight(strings.TrimSpace(GetString(consts.ConfigPublicBaseURL)), "/")
+	if base == "" {
+		return prefix
+	}
+	if trimmed := strings.Trim(prefix, "/"); trimmed != "" {
+		return base + "/" + trimmed + "/"
+	}
+	return base + "/"
+}
+
+// PresignImageURL 存储后端支持限时地址且 ConfigPresignTTLSeconds > 0 时返回签名下载地址及有效期
+// 不支持或未开启时 ok 返回 false，调用方应由本服务直接转发图片
+func PresignImageURL(store storage.Storage, key string) (signedURL string, ttl time.Duration, ok bool, err error) {
+	presigner, supported := store.(storage.Presigner)
+	seconds := GetInt(consts.ConfigPresignTTLSeconds)
+	if !supported || seconds <= 0 {
+		return "", 0, false, nil
+	}
+	ttl = time.Duration(seconds) * time.Second
+	signedURL, err = presigner.PresignGet(key, ttl)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return signedURL, ttl, true, nil
+}
+
+// InternalRedirectHeader 按 ConfigInternalRedirectMode 返回将 key 交由前端服务器发送所需的响应头
+// 未开启或存储后端不是本地文件时 ok 返回 false，调用方应由本服务发送文件内容
+func InternalRedirectHeader(store storage.Storage, key string) (name, value string, ok bool, err error) {
+	provider, supported := store.(storage.LocalFileProvider)
+	if !supported {
+		return "", "", false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(GetString(consts.ConfigInternalRedirectMode))) {
+	case InternalRedirectNginx:
+		prefix := "/"
+		if trimmed := strings.Trim(strings.TrimSpace(GetString(consts.ConfigInternalRedirectPrefix)), "/"); trimmed != "" {
+			prefix += trimmed + "/"
+		}
+		return "X-Accel-Redirect", prefix + (&url.URL{Path: key}).EscapedPath(), true, nil
+	case InternalRedirectApache:
+		p, err := provider.Path(key)
+		if err != nil {
+			return "", "", false, err
+		}
+		if p, err = filepath.Abs(p); err != nil {
+			return "", "", false, err
+		}
+		return "X-Sendfile", p, true, nil
+	}
+	return "", "", false, nil
+}
+
+// imageStorageLayout 返回新上传图片使用的路径布局，未知值按 date 处理
+func imageStorageLayout() string {
+	if strings.ToLower(strings.TrimSpace(GetString(consts.ConfigStorageLayout))) == StorageLayoutHash {
+		return StorageLayoutHash
+	}
+	return StorageLayoutDate
+}
+
+// datedImageKey 按上传日期生成存储路径，如 2026/02/13/xxx.png
+func datedImageKey(now time.Time, ext string) (filename, key string) {
+	filename = uuid.New().String() + ext
+	return filename, path.Join(now.Format("2006"), now.Format("01"), now.Format("02"), filename)
+}
+
+// hashedImageKey 按内容哈希的前两个字节分片生成存储路径，如 ab/cd/abcd...-1a2b3c4d.png
+// 文件名追加随机后缀，相同内容的多次上传各自拥有独立的文件，删除其中一张不影响其他记录
+func hashedImageKey(hash, ext string) (filename, key string) {
+	suffix := strings.ReplaceAll(uuid.New().String(), "-", "")[:8]
+	filename = hash + "-" + suffix + ext
+	return filename, path.Join(hash[0:2], hash[2:4], filename)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"perfect-pic-server/internal/consts"
+	"strings"
+	"testing"
+)
+
+func TestImageURLPrefixUsesPublicBaseURL(t *testing.T) {
+	setupTestDB(t)
+
+	tests := []struct {
+		base, wantPrefix, wantOrigin string
+	}{
+		{base: "", wantPrefix: "/imgs/", wantOrigin: ""},
+		{base: "https://cdn.example.com", wantPrefix: "https://cdn.example.com/imgs/", wantOrigin: "https://cdn.example.com"},
+		{base: "https://cdn.example.com//", wantPrefix: "https://cdn.example.com/imgs/", wantOrigin: "https://cdn.example.com"},
+		{base: "http://cdn.example.com:8080/sub/", wantPrefix: "http://cdn.example.com:8080/sub/imgs/", wantOrigin: "http://cdn.example.com:8080"},
+	}
+	for _, tt := range tests {
+		setTestSettings(t, map[string]string{consts.ConfigPublicBaseURL: tt.base})
+		if got := ImageURLPrefix(); got != tt.wantPrefix {
+			t.Errorf("base %q: ImageURLPrefix() = %q, want %q", tt.base, got, tt.wantPrefix)
+		}
+		if got := PublicImageOrigin(); got != tt.wantOrigin {
+			t.Errorf("base %q: PublicImageOrigin() = %q, want %q", tt.base, got, tt.wantOrigin)
+		}
+	}
+}
+
+func TestUploadURLUsesPublicBaseURL(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader")
+	setTestSettings(t, map[string]string{consts.ConfigPublicBaseURL: "https://cdn.example.com/"})
+
+	img, url, err := ProcessImageUpload(context.Background(), pngFileHeader(t, "a.png"), user.ID, nil)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if url != "https://cdn.example.com/imgs/"+img.Path {
+		t.Fatalf("upload URL = %q, path = %q", url, img.Path)
+	}
+	// 数据库中仍保存相对路径
+	if strings.Contains(img.Path, "cdn.example.com") {
+		t.Fatalf("stored path %q should stay relative", img.Path)
+	}
+}
+
+func TestPublicBaseURLRejectsInvalidValues(t *testing.T) {
+	setupTestDB(t)
+	for _, value := range []string{"ftp://cdn.example.com", "/cdn", "javascript:alert(1)"} {
+		if err := UpdateSettings(map[string]string{consts.ConfigPublicBaseURL: value}); err == nil {
+			t.Errorf("UpdateSettings(%q) should fail", value)
+		}
+	}
+	if got := GetString(consts.ConfigPublicBaseURL); got != "" {
+		t.Fatalf("public base URL = %q after rejected updates", got)
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"log"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+)
+
+// storageRecomputeBatchSize 重新统计已用空间时每批处理的用户数
+const storageRecomputeBatchSize = 200
+
+// RecomputeAllStorage 按图片记录重新统计所有用户的已用存储空间，修正与图片总大小不一致的 StorageUsed，
+// 返回修正的用户数。按用户 ID 分批处理，每批只需一次分组求和查询；
+// 更新时以读取到的旧值为条件，统计期间有并发上传或删除的用户 (已用空间已变化) 不会被覆盖，留待下次执行
+func RecomputeAllStorage() (fixed int, err error) {
+	var lastID uint
+	for {
+		var users []model.User
+		if err := db.DB.Select("id", "storage_used").Where("id > ?", lastID).
+			Order("id asc").Limit(storageRecomputeBatchSize).Find(&users).Error; err != nil {
+			return fixed, err
+		}
+		if len(users) == 0 {
+			return fixed, nil
+		}
+
+		ids := make([]uint, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		var rows []struct {
+			UserID uint
+			Total  int64
+		}
+		if err := db.DB.Model(&model.Image{}).Select("user_id, COALESCE(SUM(size), 0) AS total").
+			Where("user_id IN ?", ids).Group("user_id").Scan(&rows).Error; err != nil {
+			return fixed, err
+		}
+		totals := make(map[uint]int64, len(rows))
+		for _, row := range rows {
+			totals[row.UserID] = row.Total
+		}
+
+		for _, u := range users {
+			actual := totals[u.ID]
+			if u.StorageUsed == actual {
+				continue
+			}
+			result := db.DB.Model(&model.User{}).Where("id = ? AND storage_used = ?", u.ID, u.StorageUsed).
+				UpdateColumn("storage_used", actual)
+			if result.Error != nil {
+				return fixed, result.Error
+			}
+			if result.RowsAffected > 0 {
+				log.Printf("[Storage] 修正用户 %d 的已用空间: %d -> %d", u.ID, u.StorageUsed, actual)
+				fixed++
+			}
+		}
+		lastID = users[len(users)-1].ID
+	}
+}
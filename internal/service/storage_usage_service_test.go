@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+func TestRecomputeAllStorage(t *testing.T) {
+	setupTestDB(t)
+
+	// 跨越多个批次，每隔一个用户写入错误的已用空间
+	const users = storageRecomputeBatchSize*2 + 10
+	wantFixed := 0
+	batch := make([]model.User, 0, users)
+	for i := 0; i < users; i++ {
+		u := model.User{Username: fmt.Sprintf("user%03d", i), Password: "x", Status: 1, Email: fmt.Sprintf("user%03d@example.com", i)}
+		if i%2 == 0 {
+			u.StorageUsed = 999
+			wantFixed++
+		}
+		batch = append(batch, u)
+	}
+	if err := db.DB.CreateInBatches(&batch, 100).Error; err != nil {
+		t.Fatalf("create users: %v", err)
+	}
+	withImages := batch[1]
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("a%d.png", i)
+		img := model.Image{Filename: name, Path: name, Size: 100, MimeType: ".png", UploadedAt: time.Now().Unix(), UserID: withImages.ID}
+		if err := db.DB.Create(&img).Error; err != nil {
+			t.Fatalf("create image: %v", err)
+		}
+	}
+	wantFixed++ // 有图片但记录为 0 的用户
+
+	fixed, err := RecomputeAllStorage()
+	if err != nil || fixed != wantFixed {
+		t.Fatalf("RecomputeAllStorage = %d, %v; want %d", fixed, err, wantFixed)
+	}
+
+	var stored model.User
+	db.DB.First(&stored, withImages.ID)
+	if stored.StorageUsed != 300 {
+		t.Fatalf("storage used = %d, want 300", stored.StorageUsed)
+	}
+	var wrong int64
+	db.DB.Model(&model.User{}).Where("id <> ? AND storage_used <> 0", withImages.ID).Count(&wrong)
+	if wrong != 0 {
+		t.Fatalf("%d users still have wrong storage usage", wrong)
+	}
+
+	// 再次执行时无需修正
+	if fixed, err := RecomputeAllStorage(); err != nil || fixed != 0 {
+		t.Fatalf("second run = %d, %v; want 0", fixed, err)
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"perfect-pic-server/internal/config"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testPassword 测试用户的默认密码 (符合默认密码策略)
+const testPassword = "Passw0rd!"
+
+// TestMain 在临时目录中运行测试，未找到配置文件时使用默认配置，上传文件写入各测试的临时目录
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "perfect-pic-service-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	log.SetOutput(io.Discard)
+	config.InitConfig()
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupTestDB 为当前测试创建独立的内存 SQLite 数据库，执行迁移并写入默认设置
+// 测试结束后关闭数据库并清除设置缓存；工作目录切换到测试专用的临时目录
+func setupTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=busy_timeout(5000)", name)
+	d, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.Migrate(d); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	prev := db.DB
+	db.DB = d
+	ClearCache()
+	resetTestState()
+	InitializeSettings()
+	t.Cleanup(func() {
+		if sqlDB, err := d.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		db.DB = prev
+		ClearCache()
+		resetTestState()
+	})
+	return d
+}
+
+// resetTestState 清除按数据库缓存的进程内状态，避免不同测试的数据库之间互相影响
+func resetTestState() {
+	anonymousUserMu.Lock()
+	anonymousUserID = 0
+	anonymousUserMu.Unlock()
+
+	loginFailureLimiter = NewRateLimiter()
+	loginLockLimiter = NewRateLimiter()
+	passwordResetLimiter = NewRateLimiter()
+	verificationResendLimiter = NewRateLimiter()
+	accountUnlockLimiter = NewRateLimiter()
+	passwordResetStore.Clear()
+	localSettingsVersion.Store(0)
+	shuttingDown.Store(false)
+
+	integrityMu.Lock()
+	integrityCursor = 0
+	integrityMu.Unlock()
+
+	imageOpsMu.Lock()
+	imageOpsSem, imageOpsLimit = nil, 0
+	imageOpsMu.Unlock()
+
+	chunkUploadsMu.Lock()
+	uploads := make([]*chunkUpload, 0, len(chunkUploads))
+	for _, u := range chunkUploads {
+		uploads = append(uploads, u)
+	}
+	chunkUploadsMu.Unlock()
+	for _, u := range uploads {
+		removeChunkUpload(u)
+	}
+}
+
+// setTestSettings 修改系统设置，失败时终止测试
+func setTestSettings(t *testing.T, values map[string]string) {
+	t.Helper()
+	if err := UpdateSettings(values); err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+}
+
+// createTestUser 创建一个状态正常、密码为 testPassword 的用户，mutate 可在写入前修改字段
+func createTestUser(t *testing.T, username string, mutate ...func(*model.User)) model.User {
+	t.Helper()
+	hashed, err := HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := model.User{
+		Username:    strings.ToLower(username),
+		DisplayName: username,
+		Password:    hashed,
+		Status:      1,
+		Email:       strings.ToLower(username) + "@example.com",
+	}
+	for _, m := range mutate {
+		m(&user)
+	}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// sentEmail 测试邮件发送实现记录的一封邮件
+type sentEmail struct {
+	To, Subject, HTML, Text string
+}
+
+// testMailer 记录所有发送的邮件，不实际发送
+type testMailer struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (m *testMailer) Send(to, subject, htmlBody, textBody string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentEmail{To: to, Subject: subject, HTML: htmlBody, Text: textBody})
+	return nil
+}
+
+// Sent 返回已发送邮件的副本
+func (m *testMailer) Sent() []sentEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]sentEmail(nil), m.sent...)
+}
+
+// useTestMailer 开启 SMTP 并将邮件发送替换为 testMailer；邮件队列未启动，邮件同步发送
+func useTestMailer(t *testing.T) *testMailer {
+	t.Helper()
+	m := &testMailer{}
+	SetMailer(m)
+	t.Cleanup(func() { SetMailer(nil) })
+	setTestSettings(t, map[string]string{consts.ConfigEnableSMTP: "true"})
+	return m
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/logging"
+	"perfect-pic-server/internal/model"
+	"sync"
+	"time"
+)
+
+// unverifiedPurgeInterval 清理未验证账号的执行间隔
+const unverifiedPurgeInterval = time.Hour
+
+// errUserVerified 清理过程中用户已完成邮箱验证，放弃删除
+var errUserVerified = errors.New("用户已验证邮箱")
+
+var (
+	unverifiedPurgeOnce   sync.Once
+	unverifiedPurgeStopCh chan struct{}
+	unverifiedPurgeDoneCh chan struct{}
+)
+
+// StartUnverifiedUserJanitor 启动定期清理未验证账号的后台任务 (仅启动一次)
+// 每小时按 ConfigUnverifiedRetentionDays 删除超期未验证邮箱的账号，设置为 0 时跳过
+func StartUnverifiedUserJanitor() {
+	unverifiedPurgeOnce.Do(func() {
+		unverifiedPurgeStopCh = make(chan struct{})
+		unverifiedPurgeDoneCh = make(chan struct{})
+		go func() {
+			defer close(unverifiedPurgeDoneCh)
+			ticker := time.NewTicker(unverifiedPurgeInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					purgeUnverifiedUsersOnce()
+				case <-unverifiedPurgeStopCh:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// StopUnverifiedUserJanitor 停止清理任务
+func StopUnverifiedUserJanitor() {
+	if unverifiedPurgeStopCh == nil {
+		return
+	}
+	close(unverifiedPurgeStopCh)
+	<-unverifiedPurgeDoneCh
+	unverifiedPurgeStopCh = nil
+}
+
+// purgeUnverifiedUsersOnce 按当前配置执行一轮清理
+func purgeUnverifiedUsersOnce() {
+	days := GetInt(consts.ConfigUnverifiedRetentionDays)
+	if days <= 0 {
+		return
+	}
+	if deleted, err := PurgeUnverifiedUsers(time.Duration(days) * 24 * time.Hour); err != nil {
+		log.Printf("Purge unverified users error: %v\n", err)
+	} else if deleted > 0 {
+		log.Printf("🧹 已清理 %d 个超过 %d 天未验证邮箱的账号\n", deleted, days)
+	}
+}
+
+// PurgeUnverifiedUsers 删除注册时间早于 olderThan 之前且仍未验证邮箱的账号，同时清理其头像与图片文件
+// 管理员与未填写邮箱的账号 (无法完成验证) 不会被删除；每个账号先以验证状态为条件标记为删除中，期间完成验证的账号会被跳过
+func PurgeUnverifiedUsers(olderThan time.Duration) (deleted int, err error) {
+	ctx := context.Background()
+	cutoff := time.Now().Add(-olderThan)
+
+	var ids []uint
+	if err := db.DB.Model(&model.User{}).
+		Where("email_verified = ? AND admin = ? AND email <> '' AND created_at < ?", false, false, cutoff).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := purgeUnverifiedUser(ctx, id); err != nil {
+			if errors.Is(err, errUserVerified) {
+				continue
+			}
+			logging.FromContext(ctx).Error("清理未验证账号失败", "user_id", id, "error", err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// purgeUnverifiedUser 确认用户仍未验证后将其标记为删除中 (状态 3)，再删除其文件与全部记录
+// 标记后邮箱验证不再生效；上一轮已标记但未删除完成的账号直接重试
+func purgeUnverifiedUser(ctx context.Context, userID uint) error {
+	result := db.DB.Model(&model.User{}).
+		Where("id = ? AND email_verified = ? AND admin = ? AND status <> ?", userID, false, false, 3).
+		Update("status", 3)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := db.DB.Model(&model.User{}).
+			Where("id = ? AND email_verified = ? AND admin = ? AND status = ?", userID, false, false, 3).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errUserVerified
+		}
+	}
+	return purgeClaimedUser(ctx, userID)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"testing"
+	"time"
+)
+
+func TestPurgeUnverifiedUsers(t *testing.T) {
+	setupTestDB(t)
+	old := time.Now().Add(-48 * time.Hour)
+	stale := createTestUser(t, "alice", func(u *model.User) { u.EmailVerified = false; u.CreatedAt = old })
+	verified := createTestUser(t, "bob", func(u *model.User) { u.EmailVerified = true; u.CreatedAt = old })
+	admin := createTestUser(t, "root", func(u *model.User) { u.EmailVerified = false; u.Admin = true; u.CreatedAt = old })
+	fresh := createTestUser(t, "carol", func(u *model.User) { u.EmailVerified = false })
+	createStoredImage(t, stale.ID, "alice.png")
+
+	deleted, err := PurgeUnverifiedUsers(24 * time.Hour)
+	if err != nil || deleted != 1 {
+		t.Fatalf("purge = %d, %v", deleted, err)
+	}
+	for _, id := range []uint{verified.ID, admin.ID, fresh.ID} {
+		var count int64
+		db.DB.Model(&model.User{}).Where("id = ?", id).Count(&count)
+		if count != 1 {
+			t.Fatalf("user %d should be kept", id)
+		}
+	}
+	var count int64
+	db.DB.Unscoped().Model(&model.User{}).Where("id = ?", stale.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("stale unverified user not deleted")
+	}
+}
+
+func TestPurgeUnverifiedUserSkipsVerified(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", func(u *model.User) { u.EmailVerified = true })
+	img := createStoredImage(t, user.ID, "alice.png")
+
+	if err := purgeUnverifiedUser(context.Background(), user.ID); !errors.Is(err, errUserVerified) {
+		t.Fatalf("purge verified user error = %v", err)
+	}
+	// 未通过认领时不能删除任何文件
+	store, _ := ImageStorage()
+	if _, err := readObject(t, store, img.Path); err != nil {
+		t.Fatalf("file of a verified user deleted: %v", err)
+	}
+}
+
+func TestPurgeUnverifiedUserClaimsFirst(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", func(u *model.User) { u.EmailVerified = false })
+
+	// 上一轮已标记为删除中但未完成的账号会被重试
+	db.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("status", 3)
+	if err := purgeUnverifiedUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("retry purge: %v", err)
+	}
+	var count int64
+	db.DB.Unscoped().Model(&model.User{}).Where("id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("claimed user not deleted on retry")
+	}
+}
+
+func TestUnverifiedUserJanitorStop(t *testing.T) {
+	StartUnverifiedUserJanitor()
+	done := make(chan struct{})
+	go func() {
+		StopUnverifiedUserJanitor()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopUnverifiedUserJanitor did not return")
+	}
+	StopUnverifiedUserJanitor()
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 上传成功的响应格式 (ConfigUploadResponseStyle)
+const (
+	UploadResponseStyleDefault = "default"
+	UploadResponseStyleShareX  = "sharex" // {"url": "...", "deletion_url": "..."}
+	UploadResponseStylePicGo   = "picgo"  // {"success": true, "data": {"url": "...", ...}}
+)
+
+// ErrInvalidDeleteToken 删除 Token 无效、已使用或与图片不匹配
+var ErrInvalidDeleteToken = errors.New("删除链接无效")
+
+// UploadResponseStyle 返回上传响应格式，requested 为请求指定的格式 (为空或无效时使用站点配置)
+func UploadResponseStyle(requested string) string {
+	switch requested {
+	case UploadResponseStyleDefault, UploadResponseStyleShareX, UploadResponseStylePicGo:
+		return requested
+	}
+	style := GetString(consts.ConfigUploadResponseStyle)
+	if style == UploadResponseStyleShareX || style == UploadResponseStylePicGo {
+		return style
+	}
+	return UploadResponseStyleDefault
+}
+
+// AbsoluteImageURL 将站内相对地址 (以 / 开头) 补全为带站点基础 URL 的完整地址，供 ShareX 等第三方工具使用
+func AbsoluteImageURL(u string) string {
+	if strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//") {
+		return getBaseURL() + u
+	}
+	return u
+}
+
+// ImageDeletionURL 返回上传响应中附带的免登录删除链接，图片未生成删除 Token 时返回空字符串
+func ImageDeletionURL(image *model.Image) string {
+	if image.DeleteToken == "" {
+		return ""
+	}
+	return getBaseURL() + "/api/image_delete/" + image.DeleteToken
+}
+
+// issueImageDeleteToken 为即将入库的图片生成删除 Token 的随机部分，并把摘要写入 DeleteTokenHash
+// 未开启 ConfigUploadDeleteToken 时返回空字符串
+func issueImageDeleteToken(image *model.Image) (string, error) {
+	if !GetBool(consts.ConfigUploadDeleteToken) {
+		return "", nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+	image.DeleteTokenHash = hashDeleteToken(secret)
+	return secret, nil
+}
+
+// formatImageDeleteToken 组合返回给上传者的 Token：<图片 ID>.<随机部分>
+func formatImageDeleteToken(imageID uint, secret string) string {
+	return fmt.Sprintf("%d.%s", imageID, secret)
+}
+
+// hashDeleteToken 计算删除 Token 随机部分的 SHA-256 摘要，数据库中只保存摘要
+func hashDeleteToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseImageDeleteToken 拆分 Token 中的图片 ID 与随机部分
+func parseImageDeleteToken(token string) (uint, string, bool) {
+	idPart, secret, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return 0, "", false
+	}
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil || id == 0 {
+		return 0, "", false
+	}
+	return uint(id), secret, true
+}
+
+// DeleteImageByToken 通过上传时返回的删除 Token 删除图片，返回被删除的图片
+func DeleteImageByToken(token string) (*model.Image, error) {
+	image, secret, err := findImageByDeleteToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return image, deleteImageWithToken(image, secret)
+}
+
+// CheckImageDeleteToken 校验删除 Token 但不删除图片，返回 Token 对应的图片，用于展示删除确认页
+func CheckImageDeleteToken(token string) (*model.Image, error) {
+	image, secret, err := findImageByDeleteToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if !deleteTokenMatches(image, secret) {
+		return nil, ErrInvalidDeleteToken
+	}
+	return image, nil
+}
+
+// findImageByDeleteToken 按 Token 中的图片 ID 查找图片，返回图片与 Token 的随机部分
+func findImageByDeleteToken(token string) (*model.Image, string, error) {
+	id, secret, ok := parseImageDeleteToken(token)
+	if !ok {
+		return nil, "", ErrInvalidDeleteToken
+	}
+	var image model.Image
+	if err := db.DB.First(&image, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrImageNotFound
+		}
+		return nil, "", err
+	}
+	return &image, secret, nil
+}
+
+// deleteTokenMatches 判断 Token 的随机部分是否与图片记录中的摘要一致
+func deleteTokenMatches(image *model.Image, secret string) bool {
+	hash := hashDeleteToken(secret)
+	return image.DeleteTokenHash != "" && subtle.ConstantTimeCompare([]byte(image.DeleteTokenHash), []byte(hash)) == 1
+}
+
+// DeleteImageByPathToken 删除存储路径为 path 的图片，token 必须是为该图片签发的删除 Token
+func DeleteImageByPathToken(path, token string) (*model.Image, error) {
+	id, secret, ok := parseImageDeleteToken(token)
+	if !ok {
+		return nil, ErrInvalidDeleteToken
+	}
+	var image model.Image
+	if err := db.DB.Where("path = ?", path).First(&image).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImageNotFound
+		}
+		return nil, err
+	}
+	if image.ID != id {
+		return nil, ErrInvalidDeleteToken
+	}
+	return &image, deleteImageWithToken(&image, secret)
+}
+
+// deleteImageWithToken 校验 Token 后删除图片
+// 先以摘要为条件清空 DeleteTokenHash 占用 Token，并发的重复请求只有一个能继续；删除失败时恢复摘要以便重试
+func deleteImageWithToken(image *model.Image, secret string) error {
+	if !deleteTokenMatches(image, secret) {
+		return ErrInvalidDeleteToken
+	}
+	hash := hashDeleteToken(secret)
+	result := db.DB.Model(&model.Image{}).Where("id = ? AND delete_token_hash = ?", image.ID, hash).
+		UpdateColumn("delete_token_hash", "")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidDeleteToken
+	}
+	// Token 已被占用，删除不随请求取消中止
+	if err := DeleteImage(context.Background(), image); err != nil {
+		db.DB.Model(&model.Image{}).Where("id = ?", image.ID).UpdateColumn("delete_token_hash", hash)
+		return err
+	}
+	return nil
+}
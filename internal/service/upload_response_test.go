@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"testing"
+)
+
+// uploadTestImage 以 userID 上传一张 16x16 的 PNG 图片
+func uploadTestImage(t *testing.T, userID uint, name string) *model.Image {
+	t.Helper()
+	img, _, err := ProcessImageUpload(context.Background(), pngFileHeader(t, name), userID, nil)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	return img
+}
+
+func TestUploadIssuesHashedDeleteToken(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader")
+
+	img := uploadTestImage(t, user.ID, "a.png")
+	id, secret, ok := parseImageDeleteToken(img.DeleteToken)
+	if !ok || id != img.ID {
+		t.Fatalf("delete token %q should start with the image ID %d", img.DeleteToken, img.ID)
+	}
+	var stored model.Image
+	db.DB.First(&stored, img.ID)
+	if stored.DeleteTokenHash != hashDeleteToken(secret) || strings.Contains(stored.DeleteTokenHash, secret) {
+		t.Fatalf("stored hash = %q", stored.DeleteTokenHash)
+	}
+	if !strings.HasSuffix(ImageDeletionURL(img), "/api/image_delete/"+img.DeleteToken) {
+		t.Fatalf("deletion URL = %q", ImageDeletionURL(img))
+	}
+
+	setTestSettings(t, map[string]string{consts.ConfigUploadDeleteToken: "false"})
+	if img := uploadTestImage(t, user.ID, "plain.png"); img.DeleteToken != "" || ImageDeletionURL(img) != "" {
+		t.Fatalf("delete token issued while disabled: %q", img.DeleteToken)
+	}
+}
+
+func TestDeleteImageByTokenIsOneTime(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader")
+	img := uploadTestImage(t, user.ID, "a.png")
+
+	if _, err := CheckImageDeleteToken(formatImageDeleteToken(img.ID, "wrong")); !errors.Is(err, ErrInvalidDeleteToken) {
+		t.Fatalf("wrong secret: err = %v", err)
+	}
+	if _, err := CheckImageDeleteToken(img.DeleteToken); err != nil {
+		t.Fatalf("check token: %v", err)
+	}
+	if _, err := DeleteImageByToken(img.DeleteToken); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var count int64
+	db.DB.Model(&model.Image{}).Where("id = ?", img.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("image not deleted")
+	}
+	if _, err := DeleteImageByToken(img.DeleteToken); !errors.Is(err, ErrImageNotFound) {
+		t.Fatalf("reused token: err = %v, want ErrImageNotFound", err)
+	}
+	for _, token := range []string{"", "abc", "0.x", "12.", "x.y"} {
+		if _, err := DeleteImageByToken(token); !errors.Is(err, ErrInvalidDeleteToken) {
+			t.Errorf("malformed token %q: err = %v", token, err)
+		}
+	}
+}
+
+func TestDeleteImageByPathTokenRequiresMatchingImage(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "uploader")
+	first := uploadTestImage(t, user.ID, "a.png")
+	second := uploadTestImage(t, user.ID, "b.png")
+
+	// 其他图片的 Token 不能删除本图片
+	if _, err := DeleteImageByPathToken(second.Path, first.DeleteToken); !errors.Is(err, ErrInvalidDeleteToken) {
+		t.Fatalf("token of another image: err = %v", err)
+	}
+	if _, err := DeleteImageByPathToken(second.Path, second.DeleteToken); err != nil {
+		t.Fatalf("delete by path: %v", err)
+	}
+}
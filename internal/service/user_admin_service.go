@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/logging"
+	"perfect-pic-server/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxBulkUserIDs 单次批量操作允许的最大用户数量
+const maxBulkUserIDs = 100
+
+// BulkUserResult 批量用户操作中单个用户的处理结果
+type BulkUserResult struct {
+	UserID  uint   `json:"user_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ErrTooManyBulkUsers 批量操作的用户数量超出限制
+var ErrTooManyBulkUsers = fmt.Errorf("单次最多操作 %d 个用户", maxBulkUserIDs)
+
+// UpdateUserStatus 管理员修改用户状态 (1: 正常, 2: 封禁)，不允许修改自己的状态
+func UpdateUserStatus(adminID, userID uint, status int) error {
+	if status != 1 && status != 2 {
+		return newAuthError(AuthErrorValidation, "无效的状态值")
+	}
+	if adminID == userID {
+		return newAuthError(AuthErrorForbidden, "不能修改自己的账号状态")
+	}
+
+	result := db.DB.Model(&model.User{}).Where("id = ?", userID).Update("status", status)
+	if result.Error != nil {
+		log.Printf("Update user status error: %v\n", result.Error)
+		return newAuthError(AuthErrorInternal, "更新用户状态失败")
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		db.DB.Model(&model.User{}).Where("id = ?", userID).Count(&count)
+		if count == 0 {
+			return newAuthError(AuthErrorNotFound, "用户不存在")
+		}
+	}
+	return nil
+}
+
+// DeleteUser 管理员删除用户，不允许删除自己
+// hard 为 true 时清理用户的头像与图片文件，并在事务中删除图片记录与用户记录；
+// 否则软删除：修改用户名和邮箱以释放唯一索引占用，并标记为状态3(停用)
+func DeleteUser(ctx context.Context, adminID, userID uint, hard bool) error {
+	if adminID == userID {
+		return newAuthError(AuthErrorForbidden, "不能删除自己的账号")
+	}
+
+	var user model.User
+	if err := db.DB.Unscoped().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newAuthError(AuthErrorNotFound, "用户不存在")
+		}
+		return newAuthError(AuthErrorInternal, "查询用户失败")
+	}
+
+	if hard {
+		// 先清理文件，失败时不删除记录，保证记录与文件一致
+		if err := DeleteUserFiles(ctx, user.ID); err != nil {
+			logging.FromContext(ctx).Error("清理用户文件失败", "user_id", user.ID, "error", err)
+			return newAuthError(AuthErrorInternal, "清理用户文件失败")
+		}
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			return deleteUserRecords(tx, user.ID)
+		})
+		if err != nil {
+			log.Printf("Delete user error: %v\n", err)
+			return newAuthError(AuthErrorInternal, "删除用户失败")
+		}
+	} else {
+		if user.DeletedAt.Valid {
+			return nil
+		}
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			// 邮箱格式: del_<timestamp>_<original_email>
+			// 注意长度限制 255
+			timestamp := time.Now().Unix()
+			newUsername := fmt.Sprintf("%s_del_%d", user.Username, timestamp)
+			newEmail := fmt.Sprintf("del_%d_%s", timestamp, user.Email)
+			if len(newEmail) > 255 {
+				newEmail = newEmail[:255]
+			}
+
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"username": newUsername,
+				"email":    newEmail,
+				"status":   3,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&user).Error
+		})
+		if err != nil {
+			log.Printf("Delete user error: %v\n", err)
+			return newAuthError(AuthErrorInternal, "删除用户失败")
+		}
+	}
+
+	if err := RevokeAllSessions(user.ID); err != nil {
+		log.Printf("Revoke sessions error: %v\n", err)
+	}
+	return nil
+}
+
+// BulkUpdateUserStatus 批量修改用户状态，逐个处理，单个失败不影响其他用户
+func BulkUpdateUserStatus(adminID uint, userIDs []uint, status int) ([]BulkUserResult, error) {
+	return runBulkUserOperation(userIDs, func(userID uint) error {
+		return UpdateUserStatus(adminID, userID, status)
+	})
+}
+
+// BulkDeleteUsers 批量彻底删除用户及其文件，逐个处理，单个失败不影响其他用户
+func BulkDeleteUsers(ctx context.Context, adminID uint, userIDs []uint) ([]BulkUserResult, error) {
+	return runBulkUserOperation(userIDs, func(userID uint) error {
+		return DeleteUser(ctx, adminID, userID, true)
+	})
+}
+
+// runBulkUserOperation 对去重后的用户 ID 依次执行操作并汇总结果
+func runBulkUserOperation(userIDs []uint, op func(userID uint) error) ([]BulkUserResult, error) {
+	ids := make([]uint, 0, len(userIDs))
+	seen := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) > maxBulkUserIDs {
+		return nil, ErrTooManyBulkUsers
+	}
+
+	results := make([]BulkUserResult, 0, len(ids))
+	for _, id := range ids {
+		result := BulkUserResult{UserID: id, Success: true}
+		if err := op(id); err != nil {
+			result.Success = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
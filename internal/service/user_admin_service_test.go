@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/storage"
+	"testing"
+)
+
+// bulkResultsByID 按用户 ID 索引批量操作结果
+func bulkResultsByID(results []BulkUserResult) map[uint]BulkUserResult {
+	byID := make(map[uint]BulkUserResult, len(results))
+	for _, r := range results {
+		byID[r.UserID] = r
+	}
+	return byID
+}
+
+func TestBulkUpdateUserStatusProtectsSelf(t *testing.T) {
+	setupTestDB(t)
+	adminUser := createTestUser(t, "admin", func(u *model.User) { u.Admin = true })
+	a := createTestUser(t, "usera")
+	b := createTestUser(t, "userb")
+
+	results, err := BulkUpdateUserStatus(adminUser.ID, []uint{a.ID, adminUser.ID, b.ID, a.ID, 999}, 2)
+	if err != nil {
+		t.Fatalf("bulk ban: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 deduplicated results, got %+v", results)
+	}
+	byID := bulkResultsByID(results)
+	if !byID[a.ID].Success || !byID[b.ID].Success {
+		t.Fatalf("other users should be banned: %+v", results)
+	}
+	if byID[adminUser.ID].Success || byID[adminUser.ID].Error == "" {
+		t.Fatalf("admin must not ban themselves: %+v", byID[adminUser.ID])
+	}
+	if byID[999].Success {
+		t.Fatalf("missing user should fail: %+v", byID[999])
+	}
+
+	statuses := map[uint]int{}
+	var users []model.User
+	db.DB.Find(&users)
+	for _, u := range users {
+		statuses[u.ID] = u.Status
+	}
+	if statuses[adminUser.ID] != 1 || statuses[a.ID] != 2 || statuses[b.ID] != 2 {
+		t.Fatalf("unexpected statuses: %v", statuses)
+	}
+}
+
+func TestBulkDeleteUsersCleansUpFiles(t *testing.T) {
+	setupTestDB(t)
+	adminUser := createTestUser(t, "admin", func(u *model.User) { u.Admin = true })
+	a := createTestUser(t, "usera")
+	b := createTestUser(t, "userb")
+	bystander := createTestUser(t, "bystander", func(u *model.User) { u.StorageUsed = 5 })
+
+	imgA := createStoredImage(t, a.ID, "2026/02/13/a.png")
+	imgB := createStoredImage(t, b.ID, "2026/02/13/b.png")
+	kept := createStoredImage(t, bystander.ID, "2026/02/13/kept.png")
+	if _, err := UpdateUserAvatar(context.Background(), &a, pngFileHeader(t, "avatar.png")); err != nil {
+		t.Fatalf("set avatar: %v", err)
+	}
+
+	results, err := BulkDeleteUsers(context.Background(), adminUser.ID, []uint{a.ID, b.ID, adminUser.ID})
+	if err != nil {
+		t.Fatalf("bulk delete: %v", err)
+	}
+	byID := bulkResultsByID(results)
+	if !byID[a.ID].Success || !byID[b.ID].Success || byID[adminUser.ID].Success {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	var remaining []model.User
+	db.DB.Unscoped().Order("id").Find(&remaining)
+	if len(remaining) != 2 || remaining[0].ID != adminUser.ID || remaining[1].ID != bystander.ID {
+		t.Fatalf("only the admin and bystander should remain: %+v", remaining)
+	}
+	if remaining[1].StorageUsed != bystander.StorageUsed {
+		t.Fatalf("bystander storage used changed to %d", remaining[1].StorageUsed)
+	}
+
+	var imageCount int64
+	db.DB.Unscoped().Model(&model.Image{}).Where("user_id IN ?", []uint{a.ID, b.ID}).Count(&imageCount)
+	if imageCount != 0 {
+		t.Fatalf("%d image records left for deleted users", imageCount)
+	}
+
+	store, err := ImageStorage()
+	if err != nil {
+		t.Fatalf("image storage: %v", err)
+	}
+	for _, img := range []model.Image{imgA, imgB} {
+		if _, err := readObject(t, store, img.Path); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("file %s should be removed, got %v", img.Path, err)
+		}
+	}
+	if _, err := readObject(t, store, kept.Path); err != nil {
+		t.Fatalf("bystander file should be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("uploads", "avatars", fmt.Sprint(a.ID))); !os.IsNotExist(err) {
+		t.Fatalf("avatar directory should be removed, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"strings"
+	"time"
+)
+
+// UserListOptions 管理员用户列表查询参数
+type UserListOptions struct {
+	Page     int
+	PageSize int
+	// Keyword 按用户名或邮箱模糊搜索
+	Keyword string
+	// Status 按状态过滤：active / banned / deactivated / locked，留空表示不过滤
+	Status string
+	// EmailVerified 按邮箱验证状态过滤，nil 表示不过滤
+	EmailVerified *bool
+	// SortBy 排序字段：id / created_at / storage_used，默认 id
+	SortBy string
+	// Desc 是否倒序
+	Desc bool
+	// ShowDeleted 是否包含已删除的用户
+	ShowDeleted bool
+}
+
+// UserListItem 用户列表中返回给管理员的用户信息 (不含密码等敏感字段)
+type UserListItem struct {
+	ID            uint       `json:"id"`
+	Username      string     `json:"username"`
+	DisplayName   string     `json:"display_name"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	Admin         bool       `json:"admin"`
+	Status        int        `json:"status"`
+	LockReason    string     `json:"lock_reason"`
+	Avatar        string     `json:"avatar"`
+	StorageQuota  *int64     `json:"storage_quota"`
+	StorageUsed   int64      `json:"storage_used"`
+	ImageCount    int64      `json:"image_count"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeletedAt     *time.Time `json:"deleted_at"`
+}
+
+// userStatusFilters 状态过滤参数与状态码的对应关系
+var userStatusFilters = map[string]int{
+	"active":      1,
+	"banned":      2,
+	"deactivated": 3,
+	"locked":      4,
+}
+
+// userSortColumns 允许的排序字段，防止 SQL 注入
+var userSortColumns = map[string]string{
+	"id":           "users.id",
+	"created_at":   "users.created_at",
+	"storage_used": "users.storage_used",
+}
+
+// ListUsers 分页查询用户列表，图片数量通过子查询在同一条 SQL 中统计，避免 N+1 查询
+func ListUsers(opts UserListOptions) ([]UserListItem, int64, error) {
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PageSize < 1 {
+		opts.PageSize = 10
+	}
+	if opts.PageSize > 100 {
+		opts.PageSize = 100
+	}
+
+	// 匿名系统用户 (访客上传的归属) 不在用户列表中展示
+	query := db.DB.Model(&model.User{}).Where("LOWER(users.username) <> ?", UsernameKey(AnonymousUsername))
+	if opts.ShowDeleted {
+		query = query.Unscoped()
+	}
+	if keyword := strings.TrimSpace(opts.Keyword); keyword != "" {
+		like := "%" + UsernameKey(keyword) + "%"
+		query = query.Where("LOWER(users.username) LIKE ? OR LOWER(users.email) LIKE ?", like, like)
+	}
+	if status, ok := userStatusFilters[opts.Status]; ok {
+		query = query.Where("users.status = ?", status)
+	}
+	if opts.EmailVerified != nil {
+		query = query.Where("users.email_verified = ?", *opts.EmailVerified)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	sortColumn, ok := userSortColumns[opts.SortBy]
+	if !ok {
+		sortColumn = userSortColumns["id"]
+	}
+	if opts.Desc {
+		sortColumn += " desc"
+	} else {
+		sortColumn += " asc"
+	}
+
+	items := make([]UserListItem, 0)
+	err := query.
+		Select("users.id, users.username, users.display_name, users.email, users.email_verified, users.admin, " +
+			"users.status, users.lock_reason, users.avatar, users.storage_quota, users.storage_used, " +
+			"users.created_at, users.deleted_at, " +
+			"(SELECT COUNT(*) FROM images WHERE images.user_id = users.id) AS image_count").
+		Order(sortColumn).
+		Offset((opts.Page - 1) * opts.PageSize).
+		Limit(opts.PageSize).
+		Scan(&items).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
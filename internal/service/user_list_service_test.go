@@ -0,0 +1,86 @@
+package service
+
+import (
+	"perfect-pic-server/internal/model"
+	"reflect"
+	"testing"
+)
+
+// seedListUsers 创建用于列表查询的一组用户
+func seedListUsers(t *testing.T) {
+	t.Helper()
+	createTestUser(t, "Alice", func(u *model.User) { u.EmailVerified = true; u.StorageUsed = 300 })
+	createTestUser(t, "alicia", func(u *model.User) { u.Status = 2; u.StorageUsed = 100 })
+	createTestUser(t, "bob", func(u *model.User) { u.Email = "bob@corp.example"; u.StorageUsed = 200 })
+	createTestUser(t, "carol", func(u *model.User) { u.Status = 4; u.EmailVerified = true })
+	createTestUser(t, AnonymousUsername)
+}
+
+func listUsernames(t *testing.T, opts UserListOptions) ([]string, int64) {
+	t.Helper()
+	items, total, err := ListUsers(opts)
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.Username)
+	}
+	return names, total
+}
+
+func TestListUsersSearchAndFilter(t *testing.T) {
+	setupTestDB(t)
+	seedListUsers(t)
+	verified, unverified := true, false
+
+	tests := []struct {
+		name string
+		opts UserListOptions
+		want []string
+	}{
+		{name: "all without anonymous user", opts: UserListOptions{}, want: []string{"alice", "alicia", "bob", "carol"}},
+		{name: "username substring", opts: UserListOptions{Keyword: "ALI"}, want: []string{"alice", "alicia"}},
+		{name: "email substring", opts: UserListOptions{Keyword: "corp.example"}, want: []string{"bob"}},
+		{name: "active", opts: UserListOptions{Status: "active"}, want: []string{"alice", "bob"}},
+		{name: "banned", opts: UserListOptions{Status: "banned"}, want: []string{"alicia"}},
+		{name: "locked", opts: UserListOptions{Status: "locked"}, want: []string{"carol"}},
+		{name: "unknown status is ignored", opts: UserListOptions{Status: "bogus"}, want: []string{"alice", "alicia", "bob", "carol"}},
+		{name: "keyword and status", opts: UserListOptions{Keyword: "ali", Status: "active"}, want: []string{"alice"}},
+		{name: "email verified", opts: UserListOptions{EmailVerified: &verified}, want: []string{"alice", "carol"}},
+		{name: "email unverified", opts: UserListOptions{EmailVerified: &unverified}, want: []string{"alicia", "bob"}},
+		{name: "sort by storage used", opts: UserListOptions{SortBy: "storage_used", Desc: true}, want: []string{"alice", "bob", "alicia", "carol"}},
+		{name: "pagination", opts: UserListOptions{Page: 2, PageSize: 3}, want: []string{"carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := listUsernames(t, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if tt.opts.Page == 0 && total != int64(len(tt.want)) {
+				t.Fatalf("total = %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestListUsersCountsImages(t *testing.T) {
+	setupTestDB(t)
+	seedListUsers(t)
+	createStoredImage(t, 1, "2026/02/13/a.png")
+	createStoredImage(t, 1, "2026/02/13/b.png")
+	createStoredImage(t, 3, "2026/02/13/c.png")
+
+	items, _, err := ListUsers(UserListOptions{})
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	counts := map[string]int64{}
+	for _, item := range items {
+		counts[item.Username] = item.ImageCount
+	}
+	if counts["alice"] != 2 || counts["bob"] != 1 || counts["carol"] != 0 {
+		t.Fatalf("unexpected image counts: %v", counts)
+	}
+}
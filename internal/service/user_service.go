@@ -1,32 +1,65 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"perfect-pic-server/internal/config"
 	"perfect-pic-server/internal/consts"
 	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/logging"
 	"perfect-pic-server/internal/model"
+	"sort"
 	"sync"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// ForgetPasswordToken 内存中保存的忘记密码 Token 记录，只保存 Token 的 SHA-256 摘要而不保存明文
 type ForgetPasswordToken struct {
 	UserID    uint
-	Token     string
+	TokenHash string
 	ExpiresAt time.Time
+	CreatedAt time.Time
 }
 
+const (
+	// maxStoredPasswordResetTokens 内存中保存的忘记密码 Token 总数上限，超出时淘汰最早生成的
+	maxStoredPasswordResetTokens = 10000
+	// passwordResetCleanupInterval 后台清理过期 Token 的间隔
+	passwordResetCleanupInterval = 5 * time.Minute
+)
+
 var (
 	// passwordResetStore 存储忘记密码 Token
-	// Key: UserID (uint), Value: ForgetPasswordToken
+	// Key: Token 的 SHA-256 摘要 (hex), Value: ForgetPasswordToken
 	passwordResetStore sync.Map
+	// passwordResetMu 串行化 Token 的生成，保证每个用户只有一个有效 Token 且总数不超过上限
+	passwordResetMu sync.Mutex
+
+	passwordResetOnce   sync.Once
+	passwordResetStopCh chan struct{}
+	passwordResetDoneCh chan struct{}
 )
 
-// GenerateForgetPasswordToken 生成忘记密码 Token，有效期 15 分钟
+// PasswordResetTTL 返回重置密码 Token 的有效期 (ConfigPasswordResetTTLMinutes，配置无效时为 15 分钟)
+func PasswordResetTTL() time.Duration {
+	minutes := GetInt(consts.ConfigPasswordResetTTLMinutes)
+	if minutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GenerateForgetPasswordToken 生成忘记密码 Token，有效期见 PasswordResetTTL
+// 同一用户只保留最新生成的 Token (旧链接随即失效)，总数最多 maxStoredPasswordResetTokens 个，已过期的 Token 顺便清理
 func GenerateForgetPasswordToken(userID uint) (string, error) {
 	// 使用 crypto/rand 生成 32 字节的高熵随机字符串 (64字符Hex)
 	b := make([]byte, 32)
@@ -35,52 +68,117 @@ func GenerateForgetPasswordToken(userID uint) (string, error) {
 	}
 	token := hex.EncodeToString(b)
 
-	resetToken := ForgetPasswordToken{
-		UserID:    userID,
-		Token:     token,
-		ExpiresAt: time.Now().Add(15 * time.Minute),
-	}
-	// 存储（覆盖之前的）
-	passwordResetStore.Store(userID, resetToken)
-	return token, nil
-}
+	passwordResetMu.Lock()
+	defer passwordResetMu.Unlock()
 
-// VerifyForgetPasswordToken 验证忘记密码 Token
-func VerifyForgetPasswordToken(token string) (uint, bool) {
-	var foundUserID uint
-	var valid bool
-
-	// 遍历 Map 查找 Token
+	now := time.Now()
+	var active []ForgetPasswordToken
 	passwordResetStore.Range(func(key, value interface{}) bool {
 		resetToken, ok := value.(ForgetPasswordToken)
-		if !ok {
+		// 新 Token 覆盖该用户的旧 Token
+		if !ok || now.After(resetToken.ExpiresAt) || resetToken.UserID == userID {
+			passwordResetStore.Delete(key)
 			return true
 		}
+		active = append(active, resetToken)
+		return true
+	})
+	if excess := len(active) - maxStoredPasswordResetTokens + 1; excess > 0 {
+		sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+		for _, resetToken := range active[:excess] {
+			passwordResetStore.Delete(resetToken.TokenHash)
+		}
+	}
 
-		if resetToken.Token == token {
-			// 找到 Token，无论是否过期，都先停止遍历
-			// 并且为了保证一次性使用（防止重放）以及清理过期数据，直接删除
-			passwordResetStore.Delete(key)
+	tokenHash := hashResetToken(token)
+	passwordResetStore.Store(tokenHash, ForgetPasswordToken{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(PasswordResetTTL()),
+		CreatedAt: now,
+	})
+	return token, nil
+}
 
-			if time.Now().Before(resetToken.ExpiresAt) {
-				foundUserID = resetToken.UserID
-				valid = true
-			}
-			return false // 停止遍历
+// CleanupExpiredPasswordResetTokens 删除所有已过期的忘记密码 Token，返回删除的数量
+func CleanupExpiredPasswordResetTokens() int {
+	now := time.Now()
+	removed := 0
+	passwordResetStore.Range(func(key, value interface{}) bool {
+		resetToken, ok := value.(ForgetPasswordToken)
+		if !ok || now.After(resetToken.ExpiresAt) {
+			passwordResetStore.Delete(key)
+			removed++
 		}
+		return true
+	})
+	return removed
+}
 
-		// 顺便清理其他已过期的 Token (惰性清理)
-		if time.Now().After(resetToken.ExpiresAt) {
+// RevokePasswordResetTokens 删除用户的全部忘记密码 Token，密码重置成功后调用
+func RevokePasswordResetTokens(userID uint) {
+	passwordResetStore.Range(func(key, value interface{}) bool {
+		if resetToken, ok := value.(ForgetPasswordToken); !ok || resetToken.UserID == userID {
 			passwordResetStore.Delete(key)
 		}
 		return true
 	})
+}
+
+// StartPasswordResetJanitor 启动定期清理过期忘记密码 Token 的后台任务
+// 未被使用的 Token 不会经过 VerifyForgetPasswordToken，需要靠此任务回收
+func StartPasswordResetJanitor() {
+	passwordResetOnce.Do(func() {
+		passwordResetStopCh = make(chan struct{})
+		passwordResetDoneCh = make(chan struct{})
+		go func() {
+			defer close(passwordResetDoneCh)
+			ticker := time.NewTicker(passwordResetCleanupInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					CleanupExpiredPasswordResetTokens()
+				case <-passwordResetStopCh:
+					return
+				}
+			}
+		}()
+	})
+}
 
-	if valid {
-		return foundUserID, true
+// StopPasswordResetJanitor 停止清理任务
+func StopPasswordResetJanitor() {
+	if passwordResetStopCh == nil {
+		return
 	}
+	close(passwordResetStopCh)
+	<-passwordResetDoneCh
+	passwordResetStopCh = nil
+}
+
+// VerifyForgetPasswordToken 验证忘记密码 Token
+// Token 无论是否过期都会被删除，保证一次性使用 (防止重放)
+func VerifyForgetPasswordToken(token string) (uint, bool) {
+	tokenHash := hashResetToken(token)
+	value, ok := passwordResetStore.LoadAndDelete(tokenHash)
+	if !ok {
+		return 0, false
+	}
+	resetToken, ok := value.(ForgetPasswordToken)
+	if !ok || subtle.ConstantTimeCompare([]byte(resetToken.TokenHash), []byte(tokenHash)) != 1 {
+		return 0, false
+	}
+	if !time.Now().Before(resetToken.ExpiresAt) {
+		return 0, false
+	}
+	return resetToken.UserID, true
+}
 
-	return 0, false
+// hashResetToken 计算 Token 的 SHA-256 摘要，存储与查找均使用摘要，避免内存中留存明文 Token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // GetSystemDefaultStorageQuota 获取系统默认存储配额
@@ -94,8 +192,9 @@ func GetSystemDefaultStorageQuota() int64 {
 
 // DeleteUserFiles 删除指定用户的所有关联文件（头像、上传的照片）
 // 此函数只负责删除物理文件，不处理数据库记录的清理
-func DeleteUserFiles(userID uint) error {
+func DeleteUserFiles(ctx context.Context, userID uint) error {
 	cfg := config.Get()
+	logger := logging.FromContext(ctx)
 
 	// 1. 删除头像目录
 	// 头像存储结构: data/avatars/{userID}/filename
@@ -108,7 +207,7 @@ func DeleteUserFiles(userID uint) error {
 	// RemoveAll 删除路径及其包含的任何子项。如果路径不存在，RemoveAll 返回 nil（无错误）。
 	if err := os.RemoveAll(userAvatarDir); err != nil {
 		// 记录日志或打印错误，但不中断后续操作
-		fmt.Printf("Warning: Failed to delete avatar directory for user %d: %v\n", userID, err)
+		logger.Warn("删除用户头像目录失败", "user_id", userID, "path", userAvatarDir, "error", err)
 	}
 
 	// 2. 查找并删除用户上传的所有图片
@@ -118,22 +217,60 @@ func DeleteUserFiles(userID uint) error {
 		return fmt.Errorf("failed to retrieve user images: %w", err)
 	}
 
-	uploadRoot := cfg.Upload.Path
-	if uploadRoot == "" {
-		uploadRoot = "uploads/imgs"
+	store, err := ImageStorage()
+	if err != nil {
+		return fmt.Errorf("failed to get image storage: %w", err)
 	}
 
 	for _, img := range images {
-		// 转换路径分隔符以适配当前系统 (DB中存储的是 web 格式 '/')
-		localPath := filepath.FromSlash(img.Path)
-		fullPath := filepath.Join(uploadRoot, localPath)
-
-		if err := os.Remove(fullPath); err != nil {
-			if !os.IsNotExist(err) {
-				fmt.Printf("Warning: Failed to delete image file %s: %v\n", fullPath, err)
+		// 不存在的文件视为已删除
+		if err := store.Delete(ctx, img.Path); err != nil {
+			logger.Warn("删除用户图片文件失败", "user_id", userID, "image_id", img.ID, "path", img.Path, "error", err)
+		}
+		if img.WebpPath != "" {
+			if err := store.Delete(ctx, img.WebpPath); err != nil {
+				logger.Warn("删除用户图片 WebP 备选文件失败", "user_id", userID, "image_id", img.ID, "path", img.WebpPath, "error", err)
 			}
 		}
 	}
 
 	return nil
 }
+
+// deleteUserRecords 删除用户记录及其图片、相册、标签、会话与登录设备记录，需在事务中调用
+// 只处理数据库记录，调用前应已通过 DeleteUserFiles 清理文件
+func deleteUserRecords(tx *gorm.DB, userID uint) error {
+	if err := deleteUserAlbumsAndTags(tx, userID); err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.Image{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&model.Session{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&model.KnownDevice{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&model.DailyUploadUsage{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&model.User{}, userID).Error
+}
+
+// purgeClaimedUser 彻底删除已标记为删除中 (状态 3) 的用户：先清理文件，再删除全部记录并使会话失效
+// 文件清理失败时保留记录与标记，由下一轮后台任务重试
+func purgeClaimedUser(ctx context.Context, userID uint) error {
+	if err := DeleteUserFiles(ctx, userID); err != nil {
+		return err
+	}
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteUserRecords(tx, userID)
+	}); err != nil {
+		return err
+	}
+	if err := RevokeAllSessions(userID); err != nil {
+		log.Printf("Revoke sessions error: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeUsername 规范化用户输入的用户名 (去除首尾空白并进行 NFC 规范化)，保留原始大小写用于展示
+func NormalizeUsername(username string) string {
+	return norm.NFC.String(strings.TrimSpace(username))
+}
+
+// UsernameKey 返回用户名的唯一性比较形式 (规范化后转为小写)，数据库中的 username 字段存储该形式
+func UsernameKey(username string) string {
+	return strings.ToLower(NormalizeUsername(username))
+}
+
+// ValidateUsernamePolicy 校验用户名的字符集、长度，并拒绝 ConfigReservedUsernames 中的保留用户名
+// 参数应为 NormalizeUsername 处理后的用户名
+func ValidateUsernamePolicy(username string) error {
+	if ok, msg := utils.ValidateUsername(username); !ok {
+		return newAuthError(AuthErrorValidation, msg)
+	}
+
+	key := UsernameKey(username)
+	for _, r := range strings.Split(GetString(consts.ConfigReservedUsernames), ",") {
+		if r = strings.ToLower(strings.TrimSpace(r)); r != "" && r == key {
+			return newAuthError(AuthErrorReservedName, "用户名包含保留词汇，不可使用")
+		}
+	}
+	return nil
+}
+
+// IsUsernameTaken 检查用户名 (忽略大小写) 是否已被其他用户占用，excludeID 为 0 时不排除任何用户
+// 使用 LOWER 比较以兼容规范化之前写入的大小写混合用户名
+func IsUsernameTaken(username string, excludeID uint) (bool, error) {
+	query := db.DB.Model(&model.User{}).Where("LOWER(username) = ?", UsernameKey(username))
+	if excludeID != 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// verificationResendLimiter 按用户 ID 记录验证邮件的发送，用于重发冷却
+var verificationResendLimiter = NewRateLimiter()
+
+// verificationResendCooldown 返回重发验证邮件的冷却时间 (ConfigVerificationResendCooldown，0 表示不限制)
+func verificationResendCooldown() time.Duration {
+	return time.Duration(GetInt(consts.ConfigVerificationResendCooldown)) * time.Second
+}
+
+// getBaseURL 返回站点基础 URL (不含末尾斜杠)
+func getBaseURL() string {
+	baseURL := GetString(consts.ConfigBaseURL)
+	if baseURL == "" {
+		baseURL = "http://localhost"
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// EmailVerifyTTL 返回邮箱验证与修改邮箱确认 Token 的有效期 (ConfigEmailVerifyTTLHours，配置无效时为 30 分钟)
+func EmailVerifyTTL() time.Duration {
+	hours := GetFloat64(consts.ConfigEmailVerifyTTLHours)
+	if hours <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// SendAccountVerificationEmail 为用户生成邮箱验证 Token (有效期见 EmailVerifyTTL) 并发送验证邮件，同时开始重发冷却计时
+func SendAccountVerificationEmail(user *model.User) error {
+	verificationResendLimiter.Allow(strconv.FormatUint(uint64(user.ID), 10), 1, verificationResendCooldown())
+	return sendVerificationLink(user)
+}
+
+// sendVerificationLink 生成邮箱验证 Token 并发送验证邮件，不处理冷却
+func sendVerificationLink(user *model.User) error {
+	verifyToken, err := utils.GenerateEmailToken(user.ID, user.Email, EmailVerifyTTL())
+	if err != nil {
+		return err
+	}
+
+	// 用户点击邮件中的链接 -> 跳转到前端 /auth/email-verify 页面 -> 前端获取 token 并调用后端 /api/auth/email-verify 接口
+	verifyUrl := fmt.Sprintf("%s/auth/email-verify?token=%s", getBaseURL(), verifyToken)
+	return SendVerificationEmail(user.Email, user.Username, verifyUrl, user.Locale)
+}
+
+// ResendVerificationEmail 重新发送邮箱验证邮件
+// 邮箱已验证或未绑定邮箱时静默返回；处于冷却期内时返回携带 RetryAfter 的 AuthErrorCooldown
+func ResendVerificationEmail(userID uint) error {
+	var user model.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newAuthError(AuthErrorNotFound, "用户不存在")
+		}
+		return err
+	}
+
+	if user.Email == "" || user.EmailVerified {
+		return nil
+	}
+
+	key := strconv.FormatUint(uint64(user.ID), 10)
+	if allowed, remaining := verificationResendLimiter.Allow(key, 1, verificationResendCooldown()); !allowed {
+		authErr := newLocalizedAuthError(AuthErrorCooldown, MsgVerificationCooldown, int(remaining.Seconds()+0.999))
+		authErr.RetryAfter = remaining
+		return authErr
+	}
+
+	return sendVerificationLink(&user)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"perfect-pic-server/internal/consts"
+	"perfect-pic-server/internal/model"
+	"perfect-pic-server/internal/utils"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestResendVerificationEmailAlreadyVerified(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	user := createTestUser(t, "verified", func(u *model.User) { u.EmailVerified = true })
+
+	if err := ResendVerificationEmail(user.ID); err != nil {
+		t.Fatalf("ResendVerificationEmail: %v", err)
+	}
+	if n := len(mailer.Sent()); n != 0 {
+		t.Fatalf("sent %d emails to a verified user", n)
+	}
+}
+
+func TestResendVerificationEmailCooldown(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	setTestSettings(t, map[string]string{consts.ConfigVerificationResendCooldown: "60"})
+	user := createTestUser(t, "pending")
+
+	// 注册时发送的验证邮件同样开始冷却计时
+	if err := SendAccountVerificationEmail(&user); err != nil {
+		t.Fatalf("SendAccountVerificationEmail: %v", err)
+	}
+	err := ResendVerificationEmail(user.ID)
+	authErr, ok := AsAuthError(err)
+	if !ok || authErr.Code != AuthErrorCooldown {
+		t.Fatalf("resend during cooldown: err = %v, want AuthErrorCooldown", err)
+	}
+	if authErr.RetryAfter <= 0 {
+		t.Fatalf("RetryAfter = %v, want > 0", authErr.RetryAfter)
+	}
+	if n := len(mailer.Sent()); n != 1 {
+		t.Fatalf("sent %d emails, want 1", n)
+	}
+}
+
+func TestResendVerificationEmailNoCooldown(t *testing.T) {
+	setupTestDB(t)
+	mailer := useTestMailer(t)
+	setTestSettings(t, map[string]string{consts.ConfigVerificationResendCooldown: "0"})
+	user := createTestUser(t, "nocooldown")
+
+	for i := 0; i < 3; i++ {
+		if err := ResendVerificationEmail(user.ID); err != nil {
+			t.Fatalf("resend %d: %v", i+1, err)
+		}
+	}
+	if n := len(mailer.Sent()); n != 3 {
+		t.Fatalf("sent %d emails, want 3", n)
+	}
+}
+
+func TestEmailVerifyTTLFollowsSetting(t *testing.T) {
+	setupTestDB(t)
+	if got := EmailVerifyTTL(); got != 30*time.Minute {
+		t.Fatalf("default TTL = %v, want 30m", got)
+	}
+
+	mailer := useTestMailer(t)
+	setTestSettings(t, map[string]string{consts.ConfigEmailVerifyTTLHours: "1.5"})
+	if got := EmailVerifyTTL(); got != 90*time.Minute {
+		t.Fatalf("TTL = %v, want 1h30m", got)
+	}
+
+	user := createTestUser(t, "pending")
+	if err := SendAccountVerificationEmail(&user); err != nil {
+		t.Fatalf("SendAccountVerificationEmail: %v", err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(sent))
+	}
+	match := regexp.MustCompile(`token=([A-Za-z0-9._-]+)`).FindStringSubmatch(sent[0].Text)
+	if match == nil {
+		t.Fatalf("no verification link in email: %s", sent[0].Text)
+	}
+	claims, err := utils.ParseEmailToken(match[1])
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > 90*time.Minute || ttl < 89*time.Minute {
+		t.Fatalf("token expires in %v, want 1h30m", ttl)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/utils"
+)
+
+// Local 本地磁盘存储
+type Local struct {
+	Root string
+}
+
+// NewLocal 创建以 root 为根目录的本地存储
+func NewLocal(root string) *Local {
+	return &Local{Root: root}
+}
+
+// Path 返回 key 对应的磁盘路径 (保证位于 Root 之内)
+func (l *Local) Path(key string) (string, error) {
+	return utils.SecureJoin(l.Root, key)
+}
+
+func (l *Local) TempDir(key string) (string, error) {
+	dst, err := l.Path(key)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func (l *Local) Commit(key, tmpPath string) error {
+	dst, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	// 临时文件可能不在目标目录中 (如按内容哈希分片的路径需写入完成后才能确定)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := utils.SyncDir(filepath.Dir(dst)); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	dst, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(dst)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// 先写入同目录临时文件再原子重命名，避免留下截断的文件
+	tmp, err := os.CreateTemp(dir, ".put-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return utils.SyncDir(dir)
+}
+
+func (l *Local) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (l *Local) GetSeeker(_ context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ObjectInfo{}, ErrNotFound
+		}
+		return nil, ObjectInfo{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	if stat.IsDir() {
+		_ = f.Close()
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	return f, ObjectInfo{Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Exists(_ context.Context, key string) (bool, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
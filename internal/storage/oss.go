@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+const (
+	// ossConnectTimeout 建立连接的超时时间 (秒)
+	ossConnectTimeout = 10
+	// ossReadWriteTimeout 单次 OSS 请求读写的超时时间 (秒)
+	ossReadWriteTimeout = 60
+)
+
+var (
+	// ErrOSSUnauthorized OSS 拒绝了请求 (AccessKey 错误或无权限)
+	ErrOSSUnauthorized = errors.New("OSS 认证失败，请检查 AccessKey 与 Bucket 权限")
+	// ErrOSSTimeout OSS 请求超时
+	ErrOSSTimeout = errors.New("OSS 请求超时")
+)
+
+// OSS 阿里云对象存储，通过官方 SDK 访问
+type OSS struct {
+	bucket       *oss.Bucket
+	publicPrefix string
+}
+
+// NewOSS 创建 OSS 存储
+// endpoint 如 oss-cn-hangzhou.aliyuncs.com (可带 http(s):// 前缀，默认 https)；
+// customDomain 为绑定的自定义域名或 CDN 地址，留空时使用 Bucket 默认域名生成公开链接
+func NewOSS(endpoint, bucket, accessKeyID, accessKeySecret, customDomain string) (*OSS, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	bucket = strings.TrimSpace(bucket)
+	if endpoint == "" || bucket == "" || accessKeyID == "" || accessKeySecret == "" {
+		return nil, errors.New("OSS 配置不完整 (Endpoint、Bucket、AccessKey 均不能为空)")
+	}
+
+	scheme := "https"
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, errors.New("OSS Endpoint 无效")
+		}
+		scheme, endpoint = u.Scheme, u.Host
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	client, err := oss.New(scheme+"://"+endpoint, accessKeyID, accessKeySecret,
+		oss.Timeout(ossConnectTimeout, ossReadWriteTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("OSS 配置无效: %w", err)
+	}
+	b, err := client.Bucket(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("OSS 配置无效: %w", err)
+	}
+
+	publicPrefix := strings.TrimSpace(customDomain)
+	if publicPrefix == "" {
+		publicPrefix = scheme + "://" + bucket + "." + endpoint
+	} else if !strings.Contains(publicPrefix, "://") {
+		publicPrefix = "https://" + publicPrefix
+	}
+	if !strings.HasSuffix(publicPrefix, "/") {
+		publicPrefix += "/"
+	}
+
+	return &OSS{bucket: b, publicPrefix: publicPrefix}, nil
+}
+
+func (o *OSS) PublicURLPrefix() string {
+	return o.publicPrefix
+}
+
+func (o *OSS) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	err = o.bucket.PutObject(key, r, oss.WithContext(ctx), oss.ContentType(contentType), oss.ContentLength(size))
+	return ossError(http.MethodPut, key, err)
+}
+
+func (o *OSS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := o.bucket.GetObject(key, oss.WithContext(ctx))
+	if err != nil {
+		if isOSSNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, ossError(http.MethodGet, key, err)
+	}
+	return body, nil
+}
+
+func (o *OSS) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	// 删除不存在的对象 OSS 同样返回成功
+	err = o.bucket.DeleteObject(key, oss.WithContext(ctx))
+	if isOSSNotFound(err) {
+		return nil
+	}
+	return ossError(http.MethodDelete, key, err)
+}
+
+func (o *OSS) Exists(ctx context.Context, key string) (bool, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return false, err
+	}
+	exists, err := o.bucket.IsObjectExist(key, oss.WithContext(ctx))
+	if err != nil {
+		return false, ossError(http.MethodHead, key, err)
+	}
+	return exists, nil
+}
+
+// PresignGet 生成带签名的限时下载地址
+func (o *OSS) PresignGet(key string, ttl time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if ttl <= 0 {
+		return "", errors.New("presign ttl must be positive")
+	}
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	return o.bucket.SignURL(key, oss.HTTPGet, seconds)
+}
+
+// isOSSNotFound 判断错误是否为对象不存在
+func isOSSNotFound(err error) bool {
+	var serviceErr oss.ServiceError
+	return errors.As(err, &serviceErr) && serviceErr.StatusCode == http.StatusNotFound
+}
+
+// ossError 将 SDK 返回的错误转换为带操作与 key 的错误，认证失败与超时分别包装为 ErrOSSUnauthorized 与 ErrOSSTimeout
+func ossError(method, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) {
+		wrapped := fmt.Errorf("OSS %s %s 失败: %s (%s)", method, key, serviceErr.Code, serviceErr.Message)
+		if serviceErr.StatusCode == http.StatusForbidden {
+			return fmt.Errorf("%w: %v", ErrOSSUnauthorized, wrapped)
+		}
+		return wrapped
+	}
+	if isTimeout(err) {
+		return fmt.Errorf("%w: %s %s", ErrOSSTimeout, method, key)
+	}
+	return fmt.Errorf("OSS %s %s 请求失败: %w", method, key, err)
+}
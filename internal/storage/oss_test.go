@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOSS 以路径形式 (/{bucket}/{key}) 模拟 OSS 的 PUT/GET/HEAD/DELETE Object
+type fakeOSS struct {
+	mu          sync.Mutex
+	objects     map[string][]byte
+	types       map[string]string
+	accessKeyID string
+}
+
+func newFakeOSS(t *testing.T, accessKeyID string) (*fakeOSS, *httptest.Server) {
+	t.Helper()
+	f := &fakeOSS{objects: map[string][]byte{}, types: map[string]string{}, accessKeyID: accessKeyID}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeOSS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "OSS "+f.accessKeyID+":") {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>InvalidAccessKeyId</Code><Message>The OSS Access Key Id you provided does not exist in our records.</Message></Error>`)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestOSS(t *testing.T, srv *httptest.Server, accessKeyID string) *OSS {
+	t.Helper()
+	o, err := NewOSS(srv.URL, "bucket", accessKeyID, "secret", "")
+	if err != nil {
+		t.Fatalf("new oss: %v", err)
+	}
+	return o
+}
+
+func TestOSSPutGetExistsDelete(t *testing.T) {
+	fake, srv := newFakeOSS(t, "ak")
+	o := newTestOSS(t, srv, "ak")
+	ctx := context.Background()
+	content := []byte("png-bytes")
+
+	if err := o.Put(ctx, "/2026/02/13/a.png", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if got := fake.types["2026/02/13/a.png"]; got != "image/png" {
+		t.Fatalf("content type = %q", got)
+	}
+
+	if ok, err := o.Exists(ctx, "2026/02/13/a.png"); err != nil || !ok {
+		t.Fatalf("exists = %v, %v", ok, err)
+	}
+	rc, err := o.Get(ctx, "2026/02/13/a.png")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if !bytes.Equal(data, content) {
+		t.Fatalf("got %q", data)
+	}
+
+	if err := o.Delete(ctx, "2026/02/13/a.png"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := o.Delete(ctx, "2026/02/13/a.png"); err != nil {
+		t.Fatalf("delete missing object: %v", err)
+	}
+	if ok, err := o.Exists(ctx, "2026/02/13/a.png"); err != nil || ok {
+		t.Fatalf("exists after delete = %v, %v", ok, err)
+	}
+	if _, err := o.Get(ctx, "2026/02/13/a.png"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing object: %v", err)
+	}
+}
+
+func TestOSSUnauthorized(t *testing.T) {
+	_, srv := newFakeOSS(t, "ak")
+	o := newTestOSS(t, srv, "wrong")
+
+	err := o.Put(context.Background(), "a.png", strings.NewReader("x"), 1)
+	if !errors.Is(err, ErrOSSUnauthorized) {
+		t.Fatalf("expected ErrOSSUnauthorized, got %v", err)
+	}
+	if _, err := o.Exists(context.Background(), "a.png"); !errors.Is(err, ErrOSSUnauthorized) {
+		t.Fatalf("expected ErrOSSUnauthorized from exists, got %v", err)
+	}
+}
+
+func TestOSSPresignGet(t *testing.T) {
+	_, srv := newFakeOSS(t, "ak")
+	o := newTestOSS(t, srv, "ak")
+
+	before := time.Now().Unix()
+	signed, err := o.PresignGet("2026/a.png", time.Minute)
+	if err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("parse %q: %v", signed, err)
+	}
+	q := u.Query()
+	if u.Path != "/bucket/2026/a.png" || q.Get("OSSAccessKeyId") != "ak" || q.Get("Signature") == "" || q.Get("Expires") == "" {
+		t.Fatalf("unexpected signed url %q", signed)
+	}
+	if expires, err := strconv.ParseInt(q.Get("Expires"), 10, 64); err != nil || expires < before+60 || expires > time.Now().Unix()+60 {
+		t.Fatalf("Expires = %q, want the ttl from now", q.Get("Expires"))
+	}
+
+	if _, err := o.PresignGet("a.png", 0); err == nil {
+		t.Fatal("non-positive ttl should be rejected")
+	}
+}
+
+func TestNewOSSPublicURLPrefix(t *testing.T) {
+	o, err := NewOSS("oss-cn-hangzhou.aliyuncs.com", "pics", "ak", "sk", "")
+	if err != nil {
+		t.Fatalf("new oss: %v", err)
+	}
+	if got := o.PublicURLPrefix(); got != "https://pics.oss-cn-hangzhou.aliyuncs.com/" {
+		t.Fatalf("public prefix = %q", got)
+	}
+
+	o, err = NewOSS("http://oss-cn-hangzhou.aliyuncs.com", "pics", "ak", "sk", "cdn.example.com")
+	if err != nil {
+		t.Fatalf("new oss: %v", err)
+	}
+	if got := o.PublicURLPrefix(); got != "https://cdn.example.com/" {
+		t.Fatalf("public prefix = %q", got)
+	}
+
+	if _, err := NewOSS("", "pics", "ak", "sk", ""); err == nil {
+		t.Fatal("missing endpoint should be rejected")
+	}
+}
@@ -0,0 +1,82 @@
+// Package storage 提供图片文件的存储后端抽象 (本地磁盘、WebDAV 等)
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path"
+	"perfect-pic-server/internal/utils"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage 图片存储后端，key 为 URL 风格的相对路径 (如 2026/02/13/xxx.png)
+type Storage interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，不存在时视为成功
+	Delete(ctx context.Context, key string) error
+	// Exists 判断对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// FileCommitter 可直接接收本地临时文件的存储 (如本地磁盘，通过重命名避免再次拷贝)
+type FileCommitter interface {
+	// TempDir 返回写入 key 前应使用的临时文件目录 (与最终文件位于同一文件系统)，目录不存在时创建
+	TempDir(key string) (string, error)
+	// Commit 将 TempDir 下的临时文件原子地移动为 key 对应的对象
+	Commit(key, tmpPath string) error
+}
+
+// ObjectInfo 对象的基本属性
+type ObjectInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// SeekableGetter 可返回可随机读取对象的存储 (如本地磁盘)，用于响应 Range 请求
+type SeekableGetter interface {
+	// GetSeeker 打开对象并返回其属性，不存在时返回 ErrNotFound
+	GetSeeker(ctx context.Context, key string) (io.ReadSeekCloser, ObjectInfo, error)
+}
+
+// CleanKey 规范化对象 key，去除 ..、重复分隔符与首部 /，拒绝越出根目录的路径
+func CleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	if cleaned == "" || cleaned == "." {
+		return "", utils.ErrUnsafePath
+	}
+	return cleaned, nil
+}
+
+// LocalFileProvider 对象以本地文件形式保存的存储，可返回对象的磁盘路径 (如交由前端服务器直接发送)
+type LocalFileProvider interface {
+	// Path 返回 key 对应的磁盘路径
+	Path(key string) (string, error)
+}
+
+// PublicURLProvider 可直接对外提供访问地址的存储 (如 OSS/CDN)，图片链接不再经由本服务转发
+type PublicURLProvider interface {
+	// PublicURLPrefix 返回对象公开访问地址的前缀，拼接 key 即为完整地址
+	PublicURLPrefix() string
+}
+
+// Presigner 可生成限时访问地址的存储 (如 OSS)，图片访问时重定向到该地址，不再经由本服务转发数据
+type Presigner interface {
+	// PresignGet 生成 ttl 内有效的对象下载地址
+	PresignGet(key string, ttl time.Duration) (string, error)
+}
+
+// isTimeout 判断请求错误是否为超时
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
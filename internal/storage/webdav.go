@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// webdavTimeout 单次 WebDAV 请求的超时时间
+const webdavTimeout = 60 * time.Second
+
+var (
+	// ErrWebDAVUnauthorized WebDAV 服务器拒绝了提供的凭据
+	ErrWebDAVUnauthorized = errors.New("WebDAV 认证失败，请检查用户名与密码")
+	// ErrWebDAVTimeout WebDAV 请求超时
+	ErrWebDAVTimeout = errors.New("WebDAV 请求超时")
+)
+
+// WebDAV 基于 WebDAV 协议的远程存储 (如 NAS)，通过 gowebdav 访问
+type WebDAV struct {
+	root string
+	auth gowebdav.Authorizer
+}
+
+// NewWebDAV 创建 WebDAV 存储，baseURL 为存放图片的根目录地址
+func NewWebDAV(baseURL, username, password string) (*WebDAV, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("WebDAV 地址无效")
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &WebDAV{
+		root: u.String(),
+		auth: gowebdav.NewPreemptiveAuth(&webdavBasicAuth{username: username, password: password}),
+	}, nil
+}
+
+// client 返回绑定 ctx 的客户端，gowebdav 本身不支持 context，通过拦截器为每个请求设置
+func (w *WebDAV) client(ctx context.Context) *gowebdav.Client {
+	c := gowebdav.NewAuthClient(w.root, w.auth)
+	c.SetTimeout(webdavTimeout)
+	c.SetInterceptor(func(_ string, rq *http.Request) {
+		*rq = *rq.WithContext(ctx)
+	})
+	return c
+}
+
+func (w *WebDAV) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	// 上级目录不存在时逐级创建 (MKCOL)
+	err = w.client(ctx).WriteStreamWithLength(key, r, size, 0o644)
+	return webdavError(http.MethodPut, key, err)
+}
+
+func (w *WebDAV) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := w.client(ctx).ReadStream(key)
+	if err != nil {
+		if webdavStatus(err) == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, webdavError(http.MethodGet, key, err)
+	}
+	return body, nil
+}
+
+func (w *WebDAV) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	// 不存在的资源视为删除成功
+	return webdavError(http.MethodDelete, key, w.client(ctx).Remove(key))
+}
+
+func (w *WebDAV) Exists(ctx context.Context, key string) (bool, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := w.client(ctx).Stat(key); err != nil {
+		if webdavStatus(err) == http.StatusNotFound {
+			return false, nil
+		}
+		return false, webdavError("PROPFIND", key, err)
+	}
+	return true, nil
+}
+
+// webdavError 将 gowebdav 返回的错误转换为带操作与 key 的错误，认证失败与超时分别返回 ErrWebDAVUnauthorized 与 ErrWebDAVTimeout
+func webdavError(method, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	status := webdavStatus(err)
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return ErrWebDAVUnauthorized
+	}
+	if isTimeout(err) {
+		return fmt.Errorf("%w: %s %s", ErrWebDAVTimeout, method, key)
+	}
+	if status != 0 {
+		return fmt.Errorf("WebDAV %s %s 失败: %d %s", method, key, status, http.StatusText(status))
+	}
+	return fmt.Errorf("WebDAV %s %s 请求失败: %w", method, key, err)
+}
+
+// webdavStatus 返回错误链中 WebDAV 服务器响应的状态码，不是状态码错误时返回 0
+// gowebdav 可能将状态码错误嵌套在多层 os.PathError 中，gowebdav.IsErrCode 只检查一层
+func webdavStatus(err error) int {
+	var statusErr gowebdav.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	return 0
+}
+
+// webdavBasicAuth 每个请求都直接携带 Basic 认证头，不先发送匿名请求协商认证方式，
+// 因而上传时无需为重试缓存整个请求体；用户名与密码均为空时不认证
+type webdavBasicAuth struct {
+	username, password string
+}
+
+func (a *webdavBasicAuth) Authorize(_ *http.Client, rq *http.Request, _ string) error {
+	if a.username != "" || a.password != "" {
+		rq.SetBasicAuth(a.username, a.password)
+	}
+	return nil
+}
+
+func (a *webdavBasicAuth) Verify(_ *http.Client, rs *http.Response, path string) (bool, error) {
+	if rs.StatusCode == http.StatusUnauthorized {
+		return false, gowebdav.NewPathError("Authorize", path, rs.StatusCode)
+	}
+	return false, nil
+}
+
+func (a *webdavBasicAuth) Clone() gowebdav.Authenticator {
+	return a
+}
+
+func (a *webdavBasicAuth) Close() error {
+	return nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer 启动进程内的 WebDAV 服务器 (内存文件系统)，根目录挂载在 /dav/ 下，需使用 user/pass 认证
+func newWebDAVServer(t *testing.T) (*httptest.Server, webdav.FileSystem) {
+	t.Helper()
+	fs := webdav.NewMemFS()
+	dav := &webdav.Handler{Prefix: "/dav", FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dav"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, fs
+}
+
+func newTestWebDAV(t *testing.T, srv *httptest.Server, password string) *WebDAV {
+	t.Helper()
+	w, err := NewWebDAV(srv.URL+"/dav", "user", password)
+	if err != nil {
+		t.Fatalf("new webdav: %v", err)
+	}
+	return w
+}
+
+func TestWebDAVPutGetExistsDelete(t *testing.T) {
+	srv, fs := newWebDAVServer(t)
+	w := newTestWebDAV(t, srv, "pass")
+	ctx := context.Background()
+	content := []byte("png-bytes")
+
+	if err := w.Put(ctx, "2026/02/13/a.png", io.NopCloser(bytes.NewReader(content)), int64(len(content))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if info, err := fs.Stat(ctx, "/2026/02/13/a.png"); err != nil || info.Size() != int64(len(content)) {
+		t.Fatalf("object not stored: %v", err)
+	}
+	// 目录已存在时再次写入同样成功
+	if err := w.Put(ctx, "2026/02/13/b.png", strings.NewReader("b"), 1); err != nil {
+		t.Fatalf("put into existing collection: %v", err)
+	}
+
+	if ok, err := w.Exists(ctx, "2026/02/13/a.png"); err != nil || !ok {
+		t.Fatalf("exists = %v, %v", ok, err)
+	}
+	rc, err := w.Get(ctx, "2026/02/13/a.png")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if !bytes.Equal(data, content) {
+		t.Fatalf("got %q", data)
+	}
+
+	if err := w.Delete(ctx, "2026/02/13/a.png"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := w.Delete(ctx, "2026/02/13/a.png"); err != nil {
+		t.Fatalf("delete missing object: %v", err)
+	}
+	if ok, err := w.Exists(ctx, "2026/02/13/a.png"); err != nil || ok {
+		t.Fatalf("exists after delete = %v, %v", ok, err)
+	}
+	if _, err := w.Get(ctx, "2026/02/13/a.png"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing object: %v", err)
+	}
+}
+
+func TestWebDAVUnauthorized(t *testing.T) {
+	srv, _ := newWebDAVServer(t)
+	w := newTestWebDAV(t, srv, "wrong")
+	ctx := context.Background()
+
+	if err := w.Put(ctx, "a.png", strings.NewReader("x"), 1); !errors.Is(err, ErrWebDAVUnauthorized) {
+		t.Fatalf("put: expected ErrWebDAVUnauthorized, got %v", err)
+	}
+	if _, err := w.Get(ctx, "a.png"); !errors.Is(err, ErrWebDAVUnauthorized) {
+		t.Fatalf("get: expected ErrWebDAVUnauthorized, got %v", err)
+	}
+	if _, err := w.Exists(ctx, "a.png"); !errors.Is(err, ErrWebDAVUnauthorized) {
+		t.Fatalf("exists: expected ErrWebDAVUnauthorized, got %v", err)
+	}
+}
+
+func TestWebDAVHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	w, err := NewWebDAV(srv.URL, "", "")
+	if err != nil {
+		t.Fatalf("new webdav: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := w.Exists(ctx, "a.png"); !errors.Is(err, ErrWebDAVTimeout) {
+		t.Fatalf("expected ErrWebDAVTimeout, got %v", err)
+	}
+}
+
+func TestNewWebDAVRejectsInvalidURL(t *testing.T) {
+	for _, u := range []string{"", "ftp://nas/dav", "http://"} {
+		if _, err := NewWebDAV(u, "", ""); err == nil {
+			t.Errorf("NewWebDAV(%q) should fail", u)
+		}
+	}
+}
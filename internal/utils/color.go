@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// dominantColorSampleSize 提取主色调前的缩放尺寸
+const dominantColorSampleSize = 32
+
+// DominantColor 提取图片的主色调，返回 #rrggbb 格式。
+// 先缩放为 32x32，按每通道高 4 位分桶统计像素 (忽略近乎透明的像素)，
+// 取像素最多的桶内颜色的平均值。图片完全透明时返回空字符串。
+func DominantColor(src image.Image) string {
+	small := image.NewNRGBA(image.Rect(0, 0, dominantColorSampleSize, dominantColorSampleSize))
+	draw.CatmullRom.Scale(small, small.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := make(map[uint16]*bucket)
+	var best *bucket
+	for y := 0; y < dominantColorSampleSize; y++ {
+		for x := 0; x < dominantColorSampleSize; x++ {
+			c := small.NRGBAAt(x, y)
+			if c.A < 128 {
+				continue
+			}
+			key := uint16(c.R>>4)<<8 | uint16(c.G>>4)<<4 | uint16(c.B>>4)
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.count++
+			bk.r += int(c.R)
+			bk.g += int(c.G)
+			bk.b += int(c.B)
+			if best == nil || bk.count > best.count {
+				best = bk
+			}
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDominantColor(t *testing.T) {
+	// 四分之三为红色，其余为蓝色
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.NRGBA{R: 200, G: 10, B: 10, A: 255}
+			if x >= 30 {
+				c = color.NRGBA{R: 10, G: 10, B: 200, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	// 缩放时边界像素会与蓝色混合，允许少量误差
+	var r, g, b int
+	got := DominantColor(img)
+	if _, err := fmt.Sscanf(got, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		t.Fatalf("dominant color %q: %v", got, err)
+	}
+	if abs(r-200) > 3 || abs(g-10) > 3 || abs(b-10) > 3 {
+		t.Fatalf("dominant color = %q, want about #c80a0a", got)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func TestDominantColorIgnoresTransparentPixels(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if x < 4 {
+				img.SetNRGBA(x, y, color.NRGBA{R: 0, G: 128, B: 0, A: 255})
+			}
+		}
+	}
+	if got := DominantColor(img); got != "#008000" {
+		t.Fatalf("dominant color = %q, want #008000", got)
+	}
+	if got := DominantColor(image.NewNRGBA(image.Rect(0, 0, 8, 8))); got != "" {
+		t.Fatalf("dominant color of a transparent image = %q, want empty", got)
+	}
+}
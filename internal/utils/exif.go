@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// exifOrientationTag EXIF Orientation 标签号
+const exifOrientationTag = 0x0112
+
+// 自动旋转后需要改写的尺寸标签号
+const (
+	exifTagImageWidth      = 0x0100
+	exifTagImageLength     = 0x0101
+	exifTagPixelXDimension = 0xA002
+	exifTagPixelYDimension = 0xA003
+)
+
+// jpegSegment JPEG 文件中 SOS 之前的一个标记段
+type jpegSegment struct {
+	Marker byte
+	Data   []byte // 不含标记与长度字段的段内容
+}
+
+// readJPEGHeaderSegments 读取 JPEG 在 SOS 之前的所有标记段
+// 文件头损坏或被截断时返回错误，同时返回此前已完整读取的段
+func readJPEGHeaderSegments(data []byte) ([]jpegSegment, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a jpeg file")
+	}
+
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return segments, errors.New("invalid jpeg marker")
+		}
+		marker := data[pos+1]
+		// 填充字节
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		// SOS 之后为压缩数据
+		if marker == 0xDA {
+			return segments, nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return segments, errors.New("invalid jpeg segment length")
+		}
+		segments = append(segments, jpegSegment{Marker: marker, Data: data[pos+4 : pos+2+length]})
+		pos += 2 + length
+	}
+	return segments, errors.New("unexpected end of jpeg header")
+}
+
+// exifEntry IFD 中的一个条目，Value 为按类型与数量截取的原始值字节 (与 TIFF 数据共享底层数组)
+type exifEntry struct {
+	Type  uint16
+	Count uint32
+	Value []byte
+}
+
+// exifTypeSizes EXIF 数据类型对应的单个值字节数
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// parseExifTIFF 返回 APP1 Exif 段中的 TIFF 数据、字节序以及 IFD0 的偏移，不是 Exif 段时 ok 为 false
+func parseExifTIFF(app1 []byte) (tiff []byte, order binary.ByteOrder, ifd0 int, ok bool) {
+	if len(app1) < 14 || !bytes.Equal(app1[:6], []byte("Exif\x00\x00")) {
+		return nil, nil, 0, false
+	}
+	tiff = app1[6:]
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil, 0, false
+	}
+	return tiff, order, int(order.Uint32(tiff[4:8])), true
+}
+
+// readExifIFD 读取 offset 处的 IFD，越界或类型未知的条目会被忽略
+func readExifIFD(tiff []byte, offset int, order binary.ByteOrder) map[uint16]*exifEntry {
+	entries := make(map[uint16]*exifEntry)
+	if offset < 8 || offset+2 > len(tiff) {
+		return entries
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < count; i++ {
+		pos := offset + 2 + i*12
+		if pos+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[pos : pos+2])
+		typ := order.Uint16(tiff[pos+2 : pos+4])
+		n := order.Uint32(tiff[pos+4 : pos+8])
+		size, ok := exifTypeSizes[typ]
+		if !ok || n == 0 || n > 1<<16 {
+			continue
+		}
+		total := size * int(n)
+		var value []byte
+		if total <= 4 {
+			value = tiff[pos+8 : pos+8+total]
+		} else {
+			start := int(order.Uint32(tiff[pos+8 : pos+12]))
+			if start < 0 || start+total > len(tiff) {
+				continue
+			}
+			value = tiff[start : start+total]
+		}
+		entries[tag] = &exifEntry{Type: typ, Count: n, Value: value}
+	}
+	return entries
+}
+
+// exifUint 读取 SHORT/LONG 类型的第一个值
+func exifUint(entry *exifEntry, order binary.ByteOrder) (uint32, bool) {
+	if entry == nil {
+		return 0, false
+	}
+	switch entry.Type {
+	case 3:
+		return uint32(order.Uint16(entry.Value)), true
+	case 4:
+		return order.Uint32(entry.Value), true
+	}
+	return 0, false
+}
+
+// findExifOrientation 在 APP1 Exif 段中查找 IFD0 的 Orientation 标签 (SHORT)
+// 返回该条目 (Value 指向 app1 内部，可原地改写) 与字节序，找不到时 entry 为 nil
+func findExifOrientation(app1 []byte) (*exifEntry, binary.ByteOrder) {
+	tiff, order, ifd0, ok := parseExifTIFF(app1)
+	if !ok {
+		return nil, nil
+	}
+	entry := readExifIFD(tiff, ifd0, order)[exifOrientationTag]
+	if entry == nil || entry.Type != 3 {
+		return nil, nil
+	}
+	return entry, order
+}
+
+// ApplyOrientation 按 EXIF Orientation 值变换图片像素，使其以方向 1 正常显示
+// 5-8 会交换宽高。灰度、CMYK 与 RGBA 图片直接按像素字节复制并保持原有类型，
+// 其他类型 (如 JPEG 解码得到的 YCbCr) 先转换为 RGBA
+func ApplyOrientation(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dr := image.Rect(0, 0, w, h)
+	if orientation >= 5 {
+		dr = image.Rect(0, 0, h, w)
+	}
+
+	switch s := src.(type) {
+	case *image.Gray:
+		dst := image.NewGray(dr)
+		orientPixels(dst.Pix, dst.Stride, s.Pix[s.PixOffset(b.Min.X, b.Min.Y):], s.Stride, 1, w, h, orientation)
+		return dst
+	case *image.CMYK:
+		dst := image.NewCMYK(dr)
+		orientPixels(dst.Pix, dst.Stride, s.Pix[s.PixOffset(b.Min.X, b.Min.Y):], s.Stride, 4, w, h, orientation)
+		return dst
+	case *image.NRGBA:
+		dst := image.NewNRGBA(dr)
+		orientPixels(dst.Pix, dst.Stride, s.Pix[s.PixOffset(b.Min.X, b.Min.Y):], s.Stride, 4, w, h, orientation)
+		return dst
+	case *image.RGBA:
+		dst := image.NewRGBA(dr)
+		orientPixels(dst.Pix, dst.Stride, s.Pix[s.PixOffset(b.Min.X, b.Min.Y):], s.Stride, 4, w, h, orientation)
+		return dst
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	dst := image.NewRGBA(dr)
+	orientPixels(dst.Pix, dst.Stride, rgba.Pix, rgba.Stride, 4, w, h, orientation)
+	return dst
+}
+
+// orientPixels 将 w×h、每像素 bpp 字节的源像素按 orientation 复制到目标像素中
+func orientPixels(dst []byte, dstStride int, src []byte, srcStride, bpp, w, h, orientation int) {
+	for y := 0; y < h; y++ {
+		row := src[y*srcStride : y*srcStride+w*bpp]
+		if orientation == 4 { // 垂直翻转，整行复制
+			copy(dst[(h-1-y)*dstStride:], row)
+			continue
+		}
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转 180°
+				dx, dy = w-1-x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90°
+				dx, dy = y, w-1-x
+			}
+			copy(dst[dy*dstStride+dx*bpp:dy*dstStride+dx*bpp+bpp], row[x*bpp:x*bpp+bpp])
+		}
+	}
+}
+
+// AutoOrientJPEG 按 EXIF Orientation 旋转/翻转 JPEG 像素并重新编码，
+// 同时将 Orientation 改写为 1、宽高标签改写为旋转后的尺寸，其余 APPn 段 (EXIF、ICC 等) 原样保留。
+// 无需处理时 changed 返回 false。
+func AutoOrientJPEG(data []byte, quality int) (out []byte, changed bool, err error) {
+	segments, err := readJPEGHeaderSegments(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	orientation, exifIndex := 1, -1
+	for i, seg := range segments {
+		if seg.Marker != 0xE1 {
+			continue
+		}
+		if entry, order := findExifOrientation(seg.Data); entry != nil {
+			orientation, exifIndex = int(order.Uint16(entry.Value)), i
+			break
+		}
+	}
+	if orientation < 2 || orientation > 8 {
+		return nil, false, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	oriented := ApplyOrientation(img, orientation)
+
+	// seg.Data 是 data 的切片，拷贝后再改写，不影响原始数据
+	patched := append([]byte(nil), segments[exifIndex].Data...)
+	patchOrientedExif(patched, oriented.Bounds().Dx(), oriented.Bounds().Dy())
+	segments[exifIndex].Data = patched
+
+	out, err = encodeJPEGWithMetadata(oriented, segments, quality)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// patchOrientedExif 在旋转后的 APP1 Exif 段中将 Orientation 改写为 1，
+// 并将 IFD0 的 ImageWidth/ImageLength 与 Exif 子 IFD 的 PixelXDimension/PixelYDimension 改写为新的宽高
+func patchOrientedExif(app1 []byte, width, height int) {
+	tiff, order, ifd0Offset, ok := parseExifTIFF(app1)
+	if !ok {
+		return
+	}
+	ifd0 := readExifIFD(tiff, ifd0Offset, order)
+	putExifUint(ifd0[exifOrientationTag], order, 1)
+	putExifUint(ifd0[exifTagImageWidth], order, uint32(width))
+	putExifUint(ifd0[exifTagImageLength], order, uint32(height))
+	if ptr, ok := exifUint(ifd0[exifTagExifIFD], order); ok {
+		sub := readExifIFD(tiff, int(ptr), order)
+		putExifUint(sub[exifTagPixelXDimension], order, uint32(width))
+		putExifUint(sub[exifTagPixelYDimension], order, uint32(height))
+	}
+}
+
+// putExifUint 原地改写 SHORT/LONG 条目的第一个值，条目不存在或值超出 SHORT 范围时不做修改
+func putExifUint(entry *exifEntry, order binary.ByteOrder, v uint32) {
+	if entry == nil {
+		return
+	}
+	switch entry.Type {
+	case 3:
+		if v <= 0xFFFF {
+			order.PutUint16(entry.Value, uint16(v))
+		}
+	case 4:
+		order.PutUint32(entry.Value, v)
+	}
+}
+
+// RecompressJPEG 以指定质量重新编码 JPEG，原样保留 APPn/COM 元数据段 (EXIF、ICC 等)
+// CMYK/YCCK 图片重新编码后会变为 YCbCr，原有的 CMYK 色彩配置不再适用，不做处理并返回 changed 为 false
+func RecompressJPEG(data []byte, quality int) (out []byte, changed bool, err error) {
+	segments, err := readJPEGHeaderSegments(data)
+	if err != nil {
+		return nil, false, err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := img.(*image.CMYK); ok {
+		return nil, false, nil
+	}
+	out, err = encodeJPEGWithMetadata(img, segments, quality)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// resizeJPEG 等比缩小 JPEG 并重新编码，原样保留元数据段 (EXIF 方向仍然适用于缩小后的像素)
+func resizeJPEG(data []byte, maxWidth, maxHeight, quality int) ([]byte, bool, error) {
+	segments, err := readJPEGHeaderSegments(data)
+	if err != nil {
+		return nil, false, err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	b := img.Bounds()
+	width, height, ok := FitWithin(b.Dx(), b.Dy(), maxWidth, maxHeight)
+	if !ok {
+		return nil, false, nil
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	out, err := encodeJPEGWithMetadata(dst, segments, quality)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// encodeJPEGWithMetadata 编码图片，并用 segments 中的元数据段替换编码器生成的 APPn 段
+// 编码结果总是 YCbCr，描述源文件颜色变换方式的 Adobe APP14 段不再适用，不会写出；
+// 源图片为 CMYK 时其 ICC 配置同样不适用，一并丢弃
+func encodeJPEGWithMetadata(img image.Image, segments []jpegSegment, quality int) ([]byte, error) {
+	_, cmyk := img.(*image.CMYK)
+	kept := segments[:0:0]
+	for _, seg := range segments {
+		if seg.Marker == 0xEE || (cmyk && isICCProfileSegment(seg)) {
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	segments = kept
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	encodedSegments, err := readJPEGHeaderSegments(encoded.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// 输出: SOI + 原始 APPn/COM 段 + 编码器生成的量化表、帧头、霍夫曼表 + 压缩数据
+	var buf bytes.Buffer
+	buf.Grow(encoded.Len() + metadataLen(segments))
+	buf.Write([]byte{0xFF, 0xD8})
+	for _, seg := range segments {
+		if isJPEGMetadataMarker(seg.Marker) {
+			writeJPEGSegment(&buf, seg)
+		}
+	}
+	headerLen := 2
+	for _, seg := range encodedSegments {
+		headerLen += 4 + len(seg.Data)
+		if !isJPEGMetadataMarker(seg.Marker) {
+			writeJPEGSegment(&buf, seg)
+		}
+	}
+	buf.Write(encoded.Bytes()[headerLen:])
+	return buf.Bytes(), nil
+}
+
+// isICCProfileSegment 判断标记段是否为 APP2 ICC 色彩配置
+func isICCProfileSegment(seg jpegSegment) bool {
+	return seg.Marker == 0xE2 && bytes.HasPrefix(seg.Data, []byte("ICC_PROFILE\x00"))
+}
+
+// isJPEGMetadataMarker 判断标记段是否为元数据段 (APP0-APP15 或 COM)
+func isJPEGMetadataMarker(marker byte) bool {
+	return (marker >= 0xE0 && marker <= 0xEF) || marker == 0xFE
+}
+
+func writeJPEGSegment(buf *bytes.Buffer, seg jpegSegment) {
+	buf.Write([]byte{0xFF, seg.Marker})
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(seg.Data)+2))
+	buf.Write(seg.Data)
+}
+
+// metadataLen 返回元数据段写出后的总字节数
+func metadataLen(segments []jpegSegment) int {
+	n := 0
+	for _, seg := range segments {
+		if isJPEGMetadataMarker(seg.Marker) {
+			n += 4 + len(seg.Data)
+		}
+	}
+	return n
+}
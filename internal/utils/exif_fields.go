@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EXIF 标签号
+const (
+	exifTagMake             = 0x010F
+	exifTagModel            = 0x0110
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagGPSIFD           = 0x8825
+	exifTagExposureTime     = 0x829A
+	exifTagFNumber          = 0x829D
+	exifTagISO              = 0x8827
+	exifTagDateTimeOriginal = 0x9003
+	exifTagFocalLength      = 0x920A
+	exifTagLensMake         = 0xA433
+	exifTagLensModel        = 0xA434
+
+	gpsTagLatitudeRef  = 0x0001
+	gpsTagLatitude     = 0x0002
+	gpsTagLongitudeRef = 0x0003
+	gpsTagLongitude    = 0x0004
+	gpsTagAltitudeRef  = 0x0005
+	gpsTagAltitude     = 0x0006
+)
+
+// ExtractExifFields 从 JPEG 文件头中提取常用的拍摄参数 (相机、镜头、ISO、快门、光圈、焦距、拍摄时间)。
+// includeGPS 为 false 时不返回任何位置信息。
+// data 只需包含 SOS 之前的文件头；非 JPEG 或不含 EXIF 时返回空 map
+func ExtractExifFields(data []byte, includeGPS bool) map[string]string {
+	fields := make(map[string]string)
+
+	tiff, order, ifd0Offset, ok := parseExifTIFF(findExifSegment(data))
+	if !ok {
+		return fields
+	}
+
+	ifd0 := readExifIFD(tiff, ifd0Offset, order)
+	setExifString(fields, "make", ifd0[exifTagMake])
+	setExifString(fields, "model", ifd0[exifTagModel])
+	setExifString(fields, "date_time", ifd0[exifTagDateTime])
+
+	if ptr, ok := exifUint(ifd0[exifTagExifIFD], order); ok {
+		sub := readExifIFD(tiff, int(ptr), order)
+		setExifString(fields, "lens_make", sub[exifTagLensMake])
+		setExifString(fields, "lens_model", sub[exifTagLensModel])
+		setExifString(fields, "date_time_original", sub[exifTagDateTimeOriginal])
+		if iso, ok := exifUint(sub[exifTagISO], order); ok {
+			fields["iso"] = strconv.FormatUint(uint64(iso), 10)
+		}
+		if v, ok := exifRational(sub[exifTagExposureTime], order, 0); ok && v > 0 {
+			if v < 1 {
+				fields["exposure_time"] = "1/" + strconv.FormatInt(int64(math.Round(1/v)), 10) + "s"
+			} else {
+				fields["exposure_time"] = formatExifFloat(v) + "s"
+			}
+		}
+		if v, ok := exifRational(sub[exifTagFNumber], order, 0); ok && v > 0 {
+			fields["f_number"] = "f/" + formatExifFloat(v)
+		}
+		if v, ok := exifRational(sub[exifTagFocalLength], order, 0); ok && v > 0 {
+			fields["focal_length"] = formatExifFloat(v) + "mm"
+		}
+	}
+
+	if includeGPS {
+		if ptr, ok := exifUint(ifd0[exifTagGPSIFD], order); ok {
+			gps := readExifIFD(tiff, int(ptr), order)
+			if lat, ok := exifGPSCoordinate(gps[gpsTagLatitude], gps[gpsTagLatitudeRef], "S", order); ok {
+				fields["gps_latitude"] = strconv.FormatFloat(lat, 'f', 6, 64)
+			}
+			if lng, ok := exifGPSCoordinate(gps[gpsTagLongitude], gps[gpsTagLongitudeRef], "W", order); ok {
+				fields["gps_longitude"] = strconv.FormatFloat(lng, 'f', 6, 64)
+			}
+			if alt, ok := exifRational(gps[gpsTagAltitude], order, 0); ok {
+				if ref := gps[gpsTagAltitudeRef]; ref != nil && len(ref.Value) > 0 && ref.Value[0] == 1 {
+					alt = -alt
+				}
+				fields["gps_altitude"] = formatExifFloat(alt) + "m"
+			}
+		}
+	}
+	return fields
+}
+
+// findExifSegment 返回 JPEG 文件头中第一个 Exif APP1 段的内容。
+// 文件头被截断时仍会检查已读取的完整段
+func findExifSegment(data []byte) []byte {
+	segments, _ := readJPEGHeaderSegments(data)
+	for _, seg := range segments {
+		if seg.Marker == 0xE1 && bytes.HasPrefix(seg.Data, []byte("Exif\x00\x00")) {
+			return seg.Data
+		}
+	}
+	return nil
+}
+
+// setExifString 写入 ASCII 类型的字段 (去除结尾的 NUL 与空白)，空值不写入
+func setExifString(fields map[string]string, name string, entry *exifEntry) {
+	if entry == nil || entry.Type != 2 {
+		return
+	}
+	value := strings.TrimSpace(strings.TrimRight(string(entry.Value), "\x00"))
+	if i := strings.IndexByte(value, 0); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	if value != "" {
+		fields[name] = value
+	}
+}
+
+// exifRational 读取 RATIONAL/SRATIONAL 类型的第 index 个值
+func exifRational(entry *exifEntry, order binary.ByteOrder, index int) (float64, bool) {
+	if entry == nil || (entry.Type != 5 && entry.Type != 10) || index >= int(entry.Count) {
+		return 0, false
+	}
+	v := entry.Value[index*8 : index*8+8]
+	if entry.Type == 10 {
+		num, den := int32(order.Uint32(v[:4])), int32(order.Uint32(v[4:]))
+		if den == 0 {
+			return 0, false
+		}
+		return float64(num) / float64(den), true
+	}
+	num, den := order.Uint32(v[:4]), order.Uint32(v[4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// exifGPSCoordinate 将度/分/秒三个 RATIONAL 转换为十进制度数，ref 等于 negativeRef 时取负
+func exifGPSCoordinate(value, ref *exifEntry, negativeRef string, order binary.ByteOrder) (float64, bool) {
+	deg, ok1 := exifRational(value, order, 0)
+	minutes, ok2 := exifRational(value, order, 1)
+	sec, ok3 := exifRational(value, order, 2)
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	coord := deg + minutes/60 + sec/3600
+	if ref != nil && ref.Type == 2 && strings.HasPrefix(string(ref.Value), negativeRef) {
+		coord = -coord
+	}
+	return coord, true
+}
+
+func formatExifFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+// testExifTag 测试用的 IFD 条目，value 为按类型编码后的值字节
+type testExifTag struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+func exifShort(tag uint16, v uint16) testExifTag {
+	return testExifTag{tag: tag, typ: 3, count: 1, value: binary.LittleEndian.AppendUint16(nil, v)}
+}
+
+func exifLong(tag uint16, v uint32) testExifTag {
+	return testExifTag{tag: tag, typ: 4, count: 1, value: binary.LittleEndian.AppendUint32(nil, v)}
+}
+
+func exifASCII(tag uint16, s string) testExifTag {
+	return testExifTag{tag: tag, typ: 2, count: uint32(len(s) + 1), value: append([]byte(s), 0)}
+}
+
+func exifRationalTag(tag uint16, num, den uint32) testExifTag {
+	v := binary.LittleEndian.AppendUint32(nil, num)
+	return testExifTag{tag: tag, typ: 5, count: 1, value: binary.LittleEndian.AppendUint32(v, den)}
+}
+
+// buildExifAPP1 生成小端序的 APP1 Exif 段内容，sub 非空时写入 Exif 子 IFD 并在 IFD0 中添加指针
+func buildExifAPP1(ifd0, sub []testExifTag) []byte {
+	ifdSize := func(n int) int { return 2 + 12*n + 4 }
+	if len(sub) > 0 {
+		ifd0 = append(ifd0, exifLong(exifTagExifIFD, 0))
+	}
+	subOffset := 8 + ifdSize(len(ifd0))
+	dataOffset := subOffset
+	if len(sub) > 0 {
+		dataOffset += ifdSize(len(sub))
+	}
+
+	le := binary.LittleEndian
+	tiff := []byte("II\x2a\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	var data []byte
+	writeIFD := func(entries []testExifTag) {
+		tiff = le.AppendUint16(tiff, uint16(len(entries)))
+		for _, e := range entries {
+			if e.tag == exifTagExifIFD {
+				e.value = le.AppendUint32(nil, uint32(subOffset))
+			}
+			tiff = le.AppendUint16(tiff, e.tag)
+			tiff = le.AppendUint16(tiff, e.typ)
+			tiff = le.AppendUint32(tiff, e.count)
+			if len(e.value) <= 4 {
+				tiff = append(tiff, append(e.value, make([]byte, 4-len(e.value))...)...)
+				continue
+			}
+			tiff = le.AppendUint32(tiff, uint32(dataOffset+len(data)))
+			data = append(data, e.value...)
+		}
+		tiff = le.AppendUint32(tiff, 0)
+	}
+	writeIFD(ifd0)
+	if len(sub) > 0 {
+		writeIFD(sub)
+	}
+	return append(append([]byte("Exif\x00\x00"), tiff...), data...)
+}
+
+// jpegWithAPP1 编码测试图片并在 SOI 之后插入 APP1 段
+func jpegWithAPP1(t *testing.T, w, h int, app1 []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	var out bytes.Buffer
+	out.Write(buf.Bytes()[:2])
+	writeJPEGSegment(&out, jpegSegment{Marker: 0xE1, Data: app1})
+	out.Write(buf.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestExtractExifFields(t *testing.T) {
+	app1 := buildExifAPP1(
+		[]testExifTag{exifASCII(exifTagMake, "Canon"), exifASCII(exifTagModel, "EOS R5"), exifShort(exifOrientationTag, 1)},
+		[]testExifTag{exifShort(exifTagISO, 400), exifRationalTag(exifTagFNumber, 28, 10), exifRationalTag(exifTagExposureTime, 1, 250)},
+	)
+	data := jpegWithAPP1(t, 8, 8, app1)
+
+	fields := ExtractExifFields(data, false)
+	want := map[string]string{"make": "Canon", "model": "EOS R5", "iso": "400", "f_number": "f/2.8", "exposure_time": "1/250s"}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Fatalf("%s = %q, want %q (fields %v)", k, fields[k], v, fields)
+		}
+	}
+
+	// 只读取了部分文件头时，已完整读取的 APP1 段仍然可以解析
+	header := data[:4+4+len(app1)+10]
+	if got := ExtractExifFields(header, false)["make"]; got != "Canon" {
+		t.Fatalf("truncated header make = %q", got)
+	}
+	if got := ExtractExifFields([]byte("not a jpeg"), false); len(got) != 0 {
+		t.Fatalf("non-jpeg fields = %v", got)
+	}
+}
+
+func TestApplyOrientationCopiesTypedPixels(t *testing.T) {
+	src := testImage(5, 3)
+	gray := image.NewGray(src.Bounds())
+	draw.Draw(gray, gray.Bounds(), src, image.Point{}, draw.Src)
+
+	// 每种方向下源像素 (x, y) 应出现在的目标位置
+	target := map[int]func(x, y, w, h int) (int, int){
+		2: func(x, y, w, h int) (int, int) { return w - 1 - x, y },
+		3: func(x, y, w, h int) (int, int) { return w - 1 - x, h - 1 - y },
+		4: func(x, y, w, h int) (int, int) { return x, h - 1 - y },
+		5: func(x, y, w, h int) (int, int) { return y, x },
+		6: func(x, y, w, h int) (int, int) { return h - 1 - y, x },
+		7: func(x, y, w, h int) (int, int) { return h - 1 - y, w - 1 - x },
+		8: func(x, y, w, h int) (int, int) { return y, w - 1 - x },
+	}
+	for _, img := range []image.Image{src, gray} {
+		for o, f := range target {
+			out := ApplyOrientation(img, o)
+			if fmt.Sprintf("%T", out) != fmt.Sprintf("%T", img) {
+				t.Fatalf("orientation %d: got %T, want %T", o, out, img)
+			}
+			if o >= 5 && (out.Bounds().Dx() != 3 || out.Bounds().Dy() != 5) {
+				t.Fatalf("orientation %d: bounds = %v", o, out.Bounds())
+			}
+			for y := 0; y < 3; y++ {
+				for x := 0; x < 5; x++ {
+					dx, dy := f(x, y, 5, 3)
+					if out.At(dx, dy) != img.At(x, y) {
+						t.Fatalf("%T orientation %d: pixel (%d,%d) not moved to (%d,%d)", img, o, x, y, dx, dy)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestAutoOrientJPEGUpdatesExif(t *testing.T) {
+	app1 := buildExifAPP1(
+		[]testExifTag{exifShort(exifOrientationTag, 6), exifLong(exifTagImageWidth, 16), exifLong(exifTagImageLength, 8)},
+		[]testExifTag{exifShort(exifTagPixelXDimension, 16), exifShort(exifTagPixelYDimension, 8)},
+	)
+	data := jpegWithAPP1(t, 16, 8, app1)
+
+	out, changed, err := AutoOrientJPEG(data, 90)
+	if err != nil || !changed {
+		t.Fatalf("AutoOrientJPEG = changed %v, %v", changed, err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if cfg.Width != 8 || cfg.Height != 16 {
+		t.Fatalf("size = %dx%d, want 8x16", cfg.Width, cfg.Height)
+	}
+
+	tiff, order, ifd0Offset, ok := parseExifTIFF(findExifSegment(out))
+	if !ok {
+		t.Fatal("exif segment missing after auto orient")
+	}
+	ifd0 := readExifIFD(tiff, ifd0Offset, order)
+	ptr, _ := exifUint(ifd0[exifTagExifIFD], order)
+	sub := readExifIFD(tiff, int(ptr), order)
+	checks := []struct {
+		name  string
+		entry *exifEntry
+		want  uint32
+	}{
+		{"orientation", ifd0[exifOrientationTag], 1},
+		{"image width", ifd0[exifTagImageWidth], 8},
+		{"image length", ifd0[exifTagImageLength], 16},
+		{"pixel x", sub[exifTagPixelXDimension], 8},
+		{"pixel y", sub[exifTagPixelYDimension], 16},
+	}
+	for _, c := range checks {
+		if got, _ := exifUint(c.entry, order); got != c.want {
+			t.Fatalf("%s = %d, want %d", c.name, got, c.want)
+		}
+	}
+	// 原始数据不应被改写
+	if entry, order := findExifOrientation(findExifSegment(data)); order.Uint16(entry.Value) != 6 {
+		t.Fatal("source exif modified")
+	}
+}
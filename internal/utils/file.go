@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrUnsafePath 请求路径试图越出根目录
+var ErrUnsafePath = errors.New("unsafe path")
+
+// SecureJoin 将 URL 风格的相对路径拼接到 root 下，并保证结果不会越出 root
+func SecureJoin(root, rel string) (string, error) {
+	cleaned := path.Clean("/" + strings.ReplaceAll(rel, "\\", "/"))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	if cleaned == "" || cleaned == "." {
+		return "", ErrUnsafePath
+	}
+
+	full := filepath.Join(root, filepath.FromSlash(cleaned))
+	relToRoot, err := filepath.Rel(root, full)
+	if err != nil || relToRoot == ".." || strings.HasPrefix(relToRoot, ".."+string(filepath.Separator)) {
+		return "", ErrUnsafePath
+	}
+	return full, nil
+}
+
+// windowsReservedNames Windows 下不能用作文件名 (不论扩展名) 的设备名
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename 清理用户提供的文件名：去除目录部分、控制字符与首尾空白，统一为 NFC 形式并限制长度。
+// 结果可安全用作各平台的下载文件名：Windows 非法字符替换为 _，保留设备名 (如 CON、NUL) 前加 _
+func SanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+
+	name = norm.NFC.String(name)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		if strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	// Windows 会忽略结尾的点与空格
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" {
+		return ""
+	}
+
+	stem := name
+	if i := strings.IndexByte(stem, '.'); i >= 0 {
+		stem = stem[:i]
+	}
+	if windowsReservedNames[strings.ToUpper(strings.TrimSpace(stem))] {
+		name = "_" + name
+	}
+
+	// 按字符截断，避免截断多字节字符
+	const maxRunes = 200
+	if utf8.RuneCountInString(name) > maxRunes {
+		ext := path.Ext(name)
+		if utf8.RuneCountInString(ext) > 20 {
+			ext = ""
+		}
+		runes := []rune(strings.TrimSuffix(name, ext))
+		name = string(runes[:maxRunes-utf8.RuneCountInString(ext)]) + ext
+	}
+	return name
+}
+
+// ContentDisposition 构造 Content-Disposition 头
+// 同时提供 ASCII 回退的 filename 与 RFC 5987 编码的 filename*，以支持非 ASCII 文件名
+func ContentDisposition(disposition, filename string) string {
+	filename = SanitizeFilename(filename)
+	if filename == "" {
+		return disposition
+	}
+
+	fallback := asciiFallbackFilename(filename)
+	value := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback)
+	if fallback != filename {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return value
+}
+
+// asciiFallbackFilename 生成可安全放入 quoted-string 的 ASCII 文件名
+func asciiFallbackFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('_')
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// encodeRFC5987 按 RFC 5987 attr-char 规则对 UTF-8 字符串进行百分号编码
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isRFC5987AttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+func isRFC5987AttrChar(c byte) bool {
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+		return true
+	}
+	switch c {
+	case '!', '#', '$', '&', '+', '-', '.', '^', '_', '`', '|', '~':
+		return true
+	}
+	return false
+}
+
+// SyncDir 对目录执行 fsync，确保目录项 (如重命名) 已写入磁盘
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+
+	if err := d.Sync(); err != nil && !isSyncUnsupported(err) {
+		return err
+	}
+	return nil
+}
+
+// isSyncUnsupported 部分平台/文件系统 (如 Windows) 不支持对目录执行 fsync，此时忽略
+func isSyncUnsupported(err error) bool {
+	return errors.Is(err, os.ErrInvalid) || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP)
+}
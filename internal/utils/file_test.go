@@ -0,0 +1,45 @@
+package utils
+
+import "testing"
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "holiday.png", want: `attachment; filename="holiday.png"`},
+		{name: "假期 照片.png", want: `attachment; filename="__ __.png"; filename*=UTF-8''%E5%81%87%E6%9C%9F%20%E7%85%A7%E7%89%87.png`},
+		{name: "café.jpg", want: `attachment; filename="caf_.jpg"; filename*=UTF-8''caf%C3%A9.jpg`},
+		{name: "", want: "attachment"},
+	}
+	for _, tt := range tests {
+		if got := ContentDisposition("attachment", tt.name); got != tt.want {
+			t.Errorf("ContentDisposition(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "holiday.png", want: "holiday.png"},
+		{name: "../../etc/passwd\x00.png", want: "passwd.png"},
+		{name: `..\..\windows\evil.png`, want: "evil.png"},
+		{name: "..", want: ""},
+		{name: "CON.png", want: "_CON.png"},
+		{name: "nul", want: "_nul"},
+		{name: "lpt9.tar.gz", want: "_lpt9.tar.gz"},
+		{name: "CONSOLE.png", want: "CONSOLE.png"},
+		{name: `a<b>:"c|d?*.png`, want: "a_b___c_d__.png"},
+		{name: "cafe\u0301.png", want: "caf\u00e9.png"},
+		{name: "photo.png. . ", want: "photo.png"},
+		{name: "  ...  ", want: ""},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFilename(tt.name); got != tt.want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+
+	// 注册常见图片格式的解码器，供 image.DecodeConfig 读取文件头使用
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ValidateImageDimensions 仅读取文件头检查图片的像素面积与 GIF 帧数，防止解压炸弹。
+// maxPixels <= 0 或 maxGifFrames <= 0 表示不限制对应项。
+// 对于 GIF，像素面积按 画布面积 * 帧数 计算，即完整解码后需要处理的总像素量。
+func ValidateImageDimensions(reader io.ReadSeeker, ext string, maxPixels int64, maxGifFrames int) (bool, string) {
+	cfg, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return false, "无法解析图片尺寸信息"
+	}
+
+	// 重置读取位置
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return false, "重置文件读取位置失败"
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return false, "图片尺寸无效"
+	}
+
+	area := int64(cfg.Width) * int64(cfg.Height)
+	if maxPixels > 0 && area > maxPixels {
+		return false, fmt.Sprintf("图片像素面积过大 (%dx%d)，最大允许 %d 像素", cfg.Width, cfg.Height, maxPixels)
+	}
+
+	if ext != ".gif" {
+		return true, ""
+	}
+
+	// GIF 需要额外统计帧数，帧数超过上限时提前终止扫描
+	frames, err := CountGIFFrames(reader, maxGifFrames)
+	if _, seekErr := reader.Seek(0, io.SeekStart); seekErr != nil {
+		return false, "重置文件读取位置失败"
+	}
+	if err != nil {
+		return false, "GIF 文件结构损坏"
+	}
+
+	if maxGifFrames > 0 && frames > maxGifFrames {
+		return false, fmt.Sprintf("GIF 帧数过多，最多允许 %d 帧", maxGifFrames)
+	}
+	if maxPixels > 0 && frames > 0 && area*int64(frames) > maxPixels {
+		return false, fmt.Sprintf("GIF 解码后总像素面积过大，最大允许 %d 像素", maxPixels)
+	}
+
+	return true, ""
+}
+
+// CountGIFFrames 通过遍历 GIF 数据块统计帧数，不解码任何像素数据。
+// stopAfter > 0 时，帧数超过 stopAfter 即停止扫描并返回当前计数。
+// 缺少结束标记 (或末尾被截断) 的文件在已读到至少一帧时视为有效，与浏览器的容错行为一致
+func CountGIFFrames(r io.Reader, stopAfter int) (int, error) {
+	frames, err := scanGIFFrames(bufio.NewReader(r), stopAfter)
+	if err != nil && frames > 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return frames, nil
+	}
+	return frames, err
+}
+
+func scanGIFFrames(br *bufio.Reader, stopAfter int) (int, error) {
+
+	// Header (6) + Logical Screen Descriptor (7)
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 0, err
+	}
+	if string(header[:3]) != "GIF" {
+		return 0, errors.New("not a gif")
+	}
+
+	// 跳过全局颜色表
+	if packed := header[10]; packed&0x80 != 0 {
+		if _, err := br.Discard(3 * (1 << ((packed & 0x07) + 1))); err != nil {
+			return 0, err
+		}
+	}
+
+	frames := 0
+	for {
+		introducer, err := br.ReadByte()
+		if err != nil {
+			return frames, err
+		}
+
+		switch introducer {
+		case 0x21: // Extension
+			if _, err := br.ReadByte(); err != nil {
+				return frames, err
+			}
+			if err := skipGIFSubBlocks(br); err != nil {
+				return frames, err
+			}
+		case 0x2C: // Image Descriptor
+			desc := make([]byte, 9)
+			if _, err := io.ReadFull(br, desc); err != nil {
+				return frames, err
+			}
+			// 跳过局部颜色表
+			if packed := desc[8]; packed&0x80 != 0 {
+				if _, err := br.Discard(3 * (1 << ((packed & 0x07) + 1))); err != nil {
+					return frames, err
+				}
+			}
+			// LZW 最小码长
+			if _, err := br.ReadByte(); err != nil {
+				return frames, err
+			}
+			if err := skipGIFSubBlocks(br); err != nil {
+				return frames, err
+			}
+			frames++
+			if stopAfter > 0 && frames > stopAfter {
+				return frames, nil
+			}
+		case 0x3B: // Trailer
+			return frames, nil
+		default:
+			return frames, fmt.Errorf("unexpected gif block: 0x%x", introducer)
+		}
+	}
+}
+
+func skipGIFSubBlocks(br *bufio.Reader) error {
+	for {
+		size, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if _, err := br.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// ResizeAvatar 处理头像图片：cropSquare 为 true 时先居中裁剪为正方形，
+// 再等比缩放使最长边不超过 maxSize (maxSize <= 0 表示不缩放，不会放大小图)
+func ResizeAvatar(src image.Image, maxSize int, cropSquare bool) image.Image {
+	bounds := src.Bounds()
+	if cropSquare {
+		side := bounds.Dx()
+		if bounds.Dy() < side {
+			side = bounds.Dy()
+		}
+		x0 := bounds.Min.X + (bounds.Dx()-side)/2
+		y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+		bounds = image.Rect(x0, y0, x0+side, y0+side)
+	}
+
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxSize > 0 && (width > maxSize || height > maxSize) {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// ImageSize 仅读取文件头获取图片宽高，读取后重置到文件开头
+func ImageSize(reader io.ReadSeeker) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(reader)
+	if _, seekErr := reader.Seek(0, io.SeekStart); seekErr != nil {
+		return 0, 0, seekErr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// FitWithin 计算等比缩小到 maxWidth x maxHeight 以内的尺寸 (maxWidth/maxHeight <= 0 表示该方向不限制)，
+// 无需缩小时 ok 返回 false
+func FitWithin(width, height, maxWidth, maxHeight int) (int, int, bool) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale >= 1 {
+		return width, height, false
+	}
+	newWidth := max(1, int(float64(width)*scale))
+	newHeight := max(1, int(float64(height)*scale))
+	if maxWidth > 0 {
+		newWidth = min(newWidth, maxWidth)
+	}
+	if maxHeight > 0 {
+		newHeight = min(newHeight, maxHeight)
+	}
+	return newWidth, newHeight, true
+}
+
+// ErrDownscaleUnsupported 该图片类型不支持自动缩小
+var ErrDownscaleUnsupported = errors.New("该图片类型不支持自动缩小")
+
+// DownscaleImage 将图片等比缩小到 maxWidth x maxHeight 以内，并按内容的实际格式重新编码。
+// JPEG 以 jpegQuality 编码并保留 APPn/COM 元数据段；WebP 与原文件相同，无损编码的仍用无损编码，
+// 其余按 jpegQuality 有损编码；GIF 等格式返回 ErrDownscaleUnsupported。
+// 无需缩小时 changed 返回 false
+func DownscaleImage(data []byte, maxWidth, maxHeight, jpegQuality int) (out []byte, changed bool, err error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	switch format {
+	case "jpeg":
+		return resizeJPEG(data, maxWidth, maxHeight, jpegQuality)
+	case "png", "bmp", "webp":
+	default:
+		return nil, false, ErrDownscaleUnsupported
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	b := img.Bounds()
+	width, height, ok := FitWithin(b.Dx(), b.Dy(), maxWidth, maxHeight)
+	if !ok {
+		return nil, false, nil
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buf, dst)
+	case "bmp":
+		err = bmp.Encode(&buf, dst)
+	default:
+		var encoded []byte
+		if encoded, err = EncodeWebP(dst, jpegQuality, IsLosslessWebP(data)); err == nil {
+			buf.Write(encoded)
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// pngSkipOptimizeChunks 重新编码会丢失的数据块：acTL 表示 APNG 动画 (只会保留第一帧)，
+// 其余为色彩管理信息，丢弃后颜色会发生变化
+var pngSkipOptimizeChunks = map[string]bool{
+	"acTL": true,
+	"iCCP": true,
+	"gAMA": true,
+	"cHRM": true,
+	"sRGB": true,
+}
+
+// OptimizePNG 以最高压缩级别重新编码 PNG，像素与尺寸保持不变 (tEXt 等文本块不保留)
+// APNG 动画与带色彩管理信息的 PNG 不做处理，changed 返回 false
+func OptimizePNG(data []byte) (out []byte, changed bool, err error) {
+	chunks, err := pngHeaderChunks(data)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, chunk := range chunks {
+		if pngSkipOptimizeChunks[chunk] {
+			return nil, false, nil
+		}
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// pngHeaderChunks 返回 PNG 在第一个 IDAT 之前的数据块类型 (acTL 与色彩管理块均位于 IDAT 之前)
+func pngHeaderChunks(data []byte) ([]string, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if len(data) < len(signature) || string(data[:len(signature)]) != signature {
+		return nil, errors.New("not a png file")
+	}
+
+	var chunks []string
+	pos := len(signature)
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		if chunkType == "IDAT" || chunkType == "IEND" {
+			return chunks, nil
+		}
+		chunks = append(chunks, chunkType)
+		// 类型 + 数据 + CRC
+		next := pos + 8 + length + 4
+		if length < 0 || next > len(data) {
+			return nil, errors.New("invalid png chunk length")
+		}
+		pos = next
+	}
+	return nil, errors.New("unexpected end of png header")
+}
@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testImage 生成一张带渐变的测试图片
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// withPNGChunk 在 IHDR 之后插入一个数据块
+func withPNGChunk(t *testing.T, data []byte, chunkType string, payload []byte) []byte {
+	t.Helper()
+	const ihdrEnd = 8 + 8 + 13 + 4
+	var chunk bytes.Buffer
+	_ = binary.Write(&chunk, binary.BigEndian, uint32(len(payload)))
+	chunk.WriteString(chunkType)
+	chunk.Write(payload)
+	_ = binary.Write(&chunk, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(chunkType), payload...)))
+
+	out := append([]byte(nil), data[:ihdrEnd]...)
+	out = append(out, chunk.Bytes()...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func TestOptimizePNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&buf, testImage(64, 64)); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out, changed, err := OptimizePNG(buf.Bytes())
+	if err != nil || !changed {
+		t.Fatalf("OptimizePNG = changed %v, %v", changed, err)
+	}
+	if len(out) >= buf.Len() {
+		t.Fatalf("optimized size %d not smaller than %d", len(out), buf.Len())
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatalf("decode optimized: %v", err)
+	}
+}
+
+func TestOptimizePNGSkipsAnimatedAndColorManaged(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(16, 16)); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	cases := map[string][]byte{
+		"acTL": {0, 0, 0, 2, 0, 0, 0, 0},
+		"gAMA": {0, 0, 0xB1, 0x8F},
+		"iCCP": append([]byte("icc\x00\x00"), 0x78, 0x9C),
+	}
+	for chunkType, payload := range cases {
+		data := withPNGChunk(t, buf.Bytes(), chunkType, payload)
+		if _, changed, err := OptimizePNG(data); err != nil || changed {
+			t.Fatalf("%s: changed %v, %v; want skipped", chunkType, changed, err)
+		}
+	}
+}
+
+func TestRecompressJPEGDropsAdobeSegment(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(32, 32), &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	// 在 SOI 之后插入 Adobe APP14 段与一个 COM 段
+	var data bytes.Buffer
+	data.Write(buf.Bytes()[:2])
+	writeJPEGSegment(&data, jpegSegment{Marker: 0xEE, Data: []byte("Adobe\x00\x64\x00\x00\x00\x00\x02")})
+	writeJPEGSegment(&data, jpegSegment{Marker: 0xFE, Data: []byte("comment")})
+	data.Write(buf.Bytes()[2:])
+
+	out, changed, err := RecompressJPEG(data.Bytes(), 75)
+	if err != nil || !changed {
+		t.Fatalf("RecompressJPEG = changed %v, %v", changed, err)
+	}
+	segments, err := readJPEGHeaderSegments(out)
+	if err != nil {
+		t.Fatalf("read segments: %v", err)
+	}
+	hasComment := false
+	for _, seg := range segments {
+		if seg.Marker == 0xEE {
+			t.Fatal("APP14 segment copied onto the re-encoded image")
+		}
+		hasComment = hasComment || seg.Marker == 0xFE
+	}
+	if !hasComment {
+		t.Fatal("COM segment not preserved")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+}
+
+func TestEncodeJPEGDropsICCForCMYK(t *testing.T) {
+	img := image.NewCMYK(image.Rect(0, 0, 8, 8))
+	segments := []jpegSegment{
+		{Marker: 0xE2, Data: []byte("ICC_PROFILE\x00\x01\x01cmyk")},
+		{Marker: 0xFE, Data: []byte("comment")},
+	}
+	out, err := encodeJPEGWithMetadata(img, segments, 90)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := readJPEGHeaderSegments(out)
+	if err != nil {
+		t.Fatalf("read segments: %v", err)
+	}
+	for _, seg := range got {
+		if isICCProfileSegment(seg) {
+			t.Fatal("CMYK ICC profile kept on a YCbCr image")
+		}
+	}
+}
+
+func TestCountGIFFramesWithoutTrailer(t *testing.T) {
+	pal := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := 0; i < 3; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 4, 4), pal))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	data := buf.Bytes()
+	if data[len(data)-1] != 0x3B {
+		t.Fatal("encoded gif does not end with a trailer")
+	}
+
+	if n, err := CountGIFFrames(bytes.NewReader(data), 0); err != nil || n != 3 {
+		t.Fatalf("with trailer: frames = %d, err = %v", n, err)
+	}
+	if n, err := CountGIFFrames(bytes.NewReader(data[:len(data)-1]), 0); err != nil || n != 3 {
+		t.Fatalf("without trailer: frames = %d, err = %v", n, err)
+	}
+	// 只有文件头、没有任何帧的文件仍然无效
+	if _, err := CountGIFFrames(bytes.NewReader(data[:13]), 0); err == nil {
+		t.Fatal("expected error for gif without frames")
+	}
+}
+
+// testGIF 生成 frames 帧、每帧 w*h 的 GIF
+func testGIF(t *testing.T, w, h, frames int) []byte {
+	t.Helper()
+	pal := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, w, h), pal))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeaderOnly 构造只有签名与 IHDR 的 PNG，用于模拟声明超大尺寸的解压炸弹
+func pngHeaderOnly(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8] = 8 // 位深
+	ihdr[9] = 6 // RGBA
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	buf.WriteString("IHDR")
+	buf.Write(ihdr)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(append([]byte("IHDR"), ihdr...)))
+	return buf.Bytes()
+}
+
+func TestValidateImageDimensions(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		ext       string
+		maxPixels int64
+		maxFrames int
+		wantOK    bool
+	}{
+		{name: "gif within limits", data: testGIF(t, 4, 4, 3), ext: ".gif", maxPixels: 1000, maxFrames: 3, wantOK: true},
+		{name: "gif too many frames", data: testGIF(t, 4, 4, 5), ext: ".gif", maxPixels: 1000, maxFrames: 3, wantOK: false},
+		{name: "gif total area too large", data: testGIF(t, 4, 4, 5), ext: ".gif", maxPixels: 4 * 4 * 4, maxFrames: 0, wantOK: false},
+		{name: "png header within limit", data: pngHeaderOnly(100, 100), ext: ".png", maxPixels: 10000, wantOK: true},
+		{name: "png header too large", data: pngHeaderOnly(100000, 100000), ext: ".png", maxPixels: 100000000, wantOK: false},
+		{name: "png header unlimited", data: pngHeaderOnly(100000, 100000), ext: ".png", maxPixels: 0, wantOK: true},
+	}
+	for _, tt := range tests {
+		ok, msg := ValidateImageDimensions(bytes.NewReader(tt.data), tt.ext, tt.maxPixels, tt.maxFrames)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v (%s), want %v", tt.name, ok, msg, tt.wantOK)
+		}
+		if !ok && msg == "" {
+			t.Errorf("%s: rejected without a message", tt.name)
+		}
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		w, h, maxW, maxH int
+		wantW, wantH     int
+		wantOversize     bool
+	}{
+		{100, 50, 0, 0, 100, 50, false},
+		{100, 50, 100, 50, 100, 50, false},
+		{200, 100, 100, 0, 100, 50, true},
+		{200, 100, 0, 25, 50, 25, true},
+		{200, 100, 150, 25, 50, 25, true},
+		{3000, 1, 100, 100, 100, 1, true},
+	}
+	for _, tt := range tests {
+		w, h, oversize := FitWithin(tt.w, tt.h, tt.maxW, tt.maxH)
+		if w != tt.wantW || h != tt.wantH || oversize != tt.wantOversize {
+			t.Errorf("FitWithin(%d, %d, %d, %d) = %d, %d, %v; want %d, %d, %v",
+				tt.w, tt.h, tt.maxW, tt.maxH, w, h, oversize, tt.wantW, tt.wantH, tt.wantOversize)
+		}
+	}
+}
+
+func TestDownscaleImage(t *testing.T) {
+	var pngData, jpegData, gifData bytes.Buffer
+	_ = png.Encode(&pngData, testImage(64, 32))
+	_ = jpeg.Encode(&jpegData, testImage(64, 32), nil)
+	_ = gif.Encode(&gifData, testImage(64, 32), nil)
+
+	for name, data := range map[string][]byte{"png": pngData.Bytes(), "jpeg": jpegData.Bytes()} {
+		out, changed, err := DownscaleImage(data, 16, 0, 85)
+		if err != nil || !changed {
+			t.Fatalf("%s: changed = %v, err = %v", name, changed, err)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+		if err != nil || format != name || cfg.Width != 16 || cfg.Height != 8 {
+			t.Fatalf("%s: downscaled to %s %dx%d (%v), want 16x8", name, format, cfg.Width, cfg.Height, err)
+		}
+	}
+
+	if _, changed, err := DownscaleImage(pngData.Bytes(), 64, 64, 85); err != nil || changed {
+		t.Fatalf("image within bounds: changed = %v, err = %v", changed, err)
+	}
+	if _, _, err := DownscaleImage(gifData.Bytes(), 16, 16, 85); !errors.Is(err, ErrDownscaleUnsupported) {
+		t.Fatalf("gif: err = %v, want ErrDownscaleUnsupported", err)
+	}
+}
+
+func TestResizeAvatar(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h         int
+		maxSize      int
+		crop         bool
+		wantW, wantH int
+	}{
+		{name: "crop and shrink", w: 2000, h: 1000, maxSize: 512, crop: true, wantW: 512, wantH: 512},
+		{name: "shrink without crop", w: 2000, h: 1000, maxSize: 512, wantW: 512, wantH: 256},
+		{name: "portrait crop", w: 300, h: 900, maxSize: 512, crop: true, wantW: 300, wantH: 300},
+		{name: "small image is not enlarged", w: 64, h: 32, maxSize: 512, wantW: 64, wantH: 32},
+		{name: "no limit", w: 800, h: 600, maxSize: 0, crop: true, wantW: 600, wantH: 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResizeAvatar(testImage(tt.w, tt.h), tt.maxSize, tt.crop).Bounds()
+			if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+				t.Fatalf("ResizeAvatar(%dx%d) = %dx%d, want %dx%d", tt.w, tt.h, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
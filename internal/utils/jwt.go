@@ -3,10 +3,16 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"log"
+	"os"
 	"perfect-pic-server/internal/config"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 //var jwtSecret = []byte(config.Get().JWT.Secret)
@@ -16,7 +22,10 @@ type LoginClaims struct {
 	ID       uint   `json:"id"`
 	Username string `json:"username"`
 	Admin    bool   `json:"admin"`
+	Version  int    `json:"ver"`  // 签发时用户的 TokenVersion，版本落后的 Token 视为失效
 	Type     string `json:"type"` // "login"
+	// ImpersonatedBy 管理员模拟登录时为发起模拟的管理员 ID，普通登录为 0
+	ImpersonatedBy uint `json:"impersonated_by,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -33,27 +42,179 @@ type EmailChangeClaims struct {
 	ID       uint   `json:"id"`
 	OldEmail string `json:"old_email"`
 	NewEmail string `json:"new_email"`
-	Type     string `json:"type"` // "email_change"
+	Type     string `json:"type"` // "email_change" 或 "email_change_cancel"
 	jwt.RegisteredClaims
 }
 
-func getSecret() []byte {
-	return []byte(config.Get().JWT.Secret)
+// AccountDeletionClaims 用于撤销注销账号申请
+type AccountDeletionClaims struct {
+	ID          uint   `json:"id"`
+	ScheduledAt int64  `json:"scheduled_at"` // 申请时计划删除的时间 (Unix 秒)，重新申请后旧链接失效
+	Type        string `json:"type"`         // "account_deletion_cancel"
+	jwt.RegisteredClaims
+}
+
+// AccountUnlockClaims 用于通过邮件自助解锁因登录失败被锁定的账号
+type AccountUnlockClaims struct {
+	ID       uint   `json:"id"`
+	LockedAt int64  `json:"locked_at"` // 签发时账号的锁定时间 (Unix 秒)，解锁或再次锁定后旧链接失效
+	Type     string `json:"type"`      // "account_unlock"
+	jwt.RegisteredClaims
+}
+
+// jwtKey 一个签名或验证密钥
+type jwtKey struct {
+	method jwt.SigningMethod
+	sign   interface{} // 签名密钥，仅当前密钥需要
+	verify interface{}
+}
+
+// jwtKeySet 由配置生成的密钥集合：current 用于签发，valid 中的密钥 (按 kid 索引，含 current) 均可用于验证
+type jwtKeySet struct {
+	currentID string
+	current   jwtKey
+	valid     map[string]jwtKey
+}
+
+var (
+	jwtKeysMu sync.Mutex
+	// jwtKeysConfig 生成 jwtKeys 时的配置，配置文件变化后重新加载密钥
+	jwtKeysConfig config.JWTConfig
+	jwtKeys       *jwtKeySet
+	jwtKeysErr    error
+)
+
+// currentJWTKeys 返回当前配置对应的密钥集合 (按配置缓存，避免每次请求读取密钥文件)
+func currentJWTKeys() (*jwtKeySet, error) {
+	cfg := config.Get().JWT
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	if (jwtKeys == nil && jwtKeysErr == nil) || !reflect.DeepEqual(cfg, jwtKeysConfig) {
+		jwtKeys, jwtKeysErr = loadJWTKeys(cfg)
+		jwtKeysConfig = cfg
+		if jwtKeysErr != nil {
+			log.Printf("❌ 加载 JWT 密钥失败: %v", jwtKeysErr)
+		}
+	}
+	return jwtKeys, jwtKeysErr
+}
+
+func loadJWTKeys(cfg config.JWTConfig) (*jwtKeySet, error) {
+	set := &jwtKeySet{currentID: cfg.KeyID, valid: make(map[string]jwtKey)}
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "", "HS256":
+		set.current = jwtKey{method: jwt.SigningMethodHS256, sign: []byte(cfg.Secret), verify: []byte(cfg.Secret)}
+	case "RS256":
+		pemBytes, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 jwt.private_key_file 失败: %w", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析 jwt.private_key_file 失败: %w", err)
+		}
+		set.current = jwtKey{method: jwt.SigningMethodRS256, sign: privateKey, verify: &privateKey.PublicKey}
+	default:
+		return nil, fmt.Errorf("不支持的 JWT 签名算法 %q (可选 HS256, RS256)", cfg.Algorithm)
+	}
+	set.valid[cfg.KeyID] = set.current
+
+	for _, prev := range cfg.PreviousKeys {
+		if _, exists := set.valid[prev.ID]; exists {
+			return nil, fmt.Errorf("jwt.previous_keys 中的 id %q 重复", prev.ID)
+		}
+		switch {
+		case prev.PublicKeyFile != "":
+			pemBytes, err := os.ReadFile(prev.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("读取旧 JWT 公钥 %q 失败: %w", prev.ID, err)
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, fmt.Errorf("解析旧 JWT 公钥 %q 失败: %w", prev.ID, err)
+			}
+			set.valid[prev.ID] = jwtKey{method: jwt.SigningMethodRS256, verify: publicKey}
+		case prev.Secret != "":
+			set.valid[prev.ID] = jwtKey{method: jwt.SigningMethodHS256, verify: []byte(prev.Secret)}
+		default:
+			return nil, fmt.Errorf("旧 JWT 密钥 %q 未设置 secret 或 public_key_file", prev.ID)
+		}
+	}
+	return set, nil
+}
+
+// signToken 使用当前密钥签发 Token，设置了 jwt.key_id 时写入头部的 kid
+func signToken(claims jwt.Claims) (string, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(keys.current.method, claims)
+	if keys.currentID != "" {
+		token.Header["kid"] = keys.currentID
+	}
+	return token.SignedString(keys.current.sign)
+}
+
+// verificationKey 按 Token 头部的 kid (没有 kid 时对应 id 为空的密钥) 选择验证密钥
+// kid 未知或签名算法与该密钥不一致时拒绝
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys.valid[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verify, nil
+}
+
+// NewTokenID 生成随机的 Token ID (jti)
+func NewTokenID() string {
+	return uuid.New().String()
 }
 
-func GenerateLoginToken(id uint, username string, admin bool, duration time.Duration) (string, error) {
+func GenerateLoginToken(id uint, username string, admin bool, version int, duration time.Duration) (string, error) {
+	return GenerateLoginTokenWithID(NewTokenID(), id, username, admin, version, duration)
+}
+
+// GenerateLoginTokenWithID 使用指定的 jti 签发登录 Token，用于与会话记录关联
+func GenerateLoginTokenWithID(jti string, id uint, username string, admin bool, version int, duration time.Duration) (string, error) {
 	claims := LoginClaims{
 		ID:       id,
 		Username: username,
 		Admin:    admin,
+		Version:  version,
 		Type:     "login",
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+			Issuer:    "perfect-pic-server",
+		},
+	}
+	return signToken(claims)
+}
+
+// GenerateImpersonationToken 签发管理员模拟登录目标用户的 Token，impersonatorID 为发起模拟的管理员 ID
+func GenerateImpersonationToken(jti string, id uint, username string, version int, impersonatorID uint, duration time.Duration) (string, error) {
+	claims := LoginClaims{
+		ID:             id,
+		Username:       username,
+		Version:        version,
+		Type:           "login",
+		ImpersonatedBy: impersonatorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
 			Issuer:    "perfect-pic-server",
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getSecret())
+	return signToken(claims)
 }
 
 func GenerateEmailToken(id uint, email string, duration time.Duration) (string, error) {
@@ -66,32 +227,34 @@ func GenerateEmailToken(id uint, email string, duration time.Duration) (string,
 			Issuer:    "perfect-pic-server",
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getSecret())
+	return signToken(claims)
 }
 
 func GenerateEmailChangeToken(id uint, oldEmail, newEmail string, duration time.Duration) (string, error) {
+	return generateEmailChangeToken("email_change", id, oldEmail, newEmail, duration)
+}
+
+// GenerateEmailChangeCancelToken 签发发送到旧邮箱的撤销修改邮箱 Token
+func GenerateEmailChangeCancelToken(id uint, oldEmail, newEmail string, duration time.Duration) (string, error) {
+	return generateEmailChangeToken("email_change_cancel", id, oldEmail, newEmail, duration)
+}
+
+func generateEmailChangeToken(tokenType string, id uint, oldEmail, newEmail string, duration time.Duration) (string, error) {
 	claims := EmailChangeClaims{
 		ID:       id,
 		OldEmail: oldEmail,
 		NewEmail: newEmail,
-		Type:     "email_change",
+		Type:     tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
 			Issuer:    "perfect-pic-server",
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getSecret())
+	return signToken(claims)
 }
 
 func ParseLoginToken(tokenString string) (*LoginClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &LoginClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return getSecret(), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &LoginClaims{}, verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -108,12 +271,7 @@ func ParseLoginToken(tokenString string) (*LoginClaims, error) {
 }
 
 func ParseEmailToken(tokenString string) (*EmailClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &EmailClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return getSecret(), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &EmailClaims{}, verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -130,19 +288,87 @@ func ParseEmailToken(tokenString string) (*EmailClaims, error) {
 }
 
 func ParseEmailChangeToken(tokenString string) (*EmailChangeClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &EmailChangeClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return getSecret(), nil
-	})
+	return parseEmailChangeToken(tokenString, "email_change")
+}
+
+// ParseEmailChangeCancelToken 解析撤销修改邮箱 Token
+func ParseEmailChangeCancelToken(tokenString string) (*EmailChangeClaims, error) {
+	return parseEmailChangeToken(tokenString, "email_change_cancel")
+}
+
+func parseEmailChangeToken(tokenString, tokenType string) (*EmailChangeClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &EmailChangeClaims{}, verificationKey)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*EmailChangeClaims); ok && token.Valid {
-		if claims.Type != "email_change" {
+		if claims.Type != tokenType {
+			return nil, errors.New("invalid token type")
+		}
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// GenerateAccountDeletionCancelToken 签发撤销注销账号申请的 Token，有效期至计划删除时间
+func GenerateAccountDeletionCancelToken(id uint, scheduledAt time.Time) (string, error) {
+	claims := AccountDeletionClaims{
+		ID:          id,
+		ScheduledAt: scheduledAt.Unix(),
+		Type:        "account_deletion_cancel",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(scheduledAt),
+			Issuer:    "perfect-pic-server",
+		},
+	}
+	return signToken(claims)
+}
+
+// ParseAccountDeletionCancelToken 解析撤销注销账号申请的 Token
+func ParseAccountDeletionCancelToken(tokenString string) (*AccountDeletionClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &AccountDeletionClaims{}, verificationKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*AccountDeletionClaims); ok && token.Valid {
+		if claims.Type != "account_deletion_cancel" {
+			return nil, errors.New("invalid token type")
+		}
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// GenerateAccountUnlockToken 签发自助解锁账号的 Token
+func GenerateAccountUnlockToken(id uint, lockedAt int64, duration time.Duration) (string, error) {
+	claims := AccountUnlockClaims{
+		ID:       id,
+		LockedAt: lockedAt,
+		Type:     "account_unlock",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+			Issuer:    "perfect-pic-server",
+		},
+	}
+	return signToken(claims)
+}
+
+// ParseAccountUnlockToken 解析自助解锁账号的 Token
+func ParseAccountUnlockToken(tokenString string) (*AccountUnlockClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &AccountUnlockClaims{}, verificationKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*AccountUnlockClaims); ok && token.Valid {
+		if claims.Type != "account_unlock" {
 			return nil, errors.New("invalid token type")
 		}
 		return claims, nil
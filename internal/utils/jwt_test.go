@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"perfect-pic-server/internal/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useJWTKeys 在当前测试中使用 cfg 生成的密钥签发与验证 Token
+func useJWTKeys(t *testing.T, cfg config.JWTConfig) {
+	t.Helper()
+	keys, err := loadJWTKeys(cfg)
+	if err != nil {
+		t.Fatalf("load jwt keys: %v", err)
+	}
+	jwtKeysMu.Lock()
+	jwtKeys, jwtKeysErr, jwtKeysConfig = keys, nil, config.Get().JWT
+	jwtKeysMu.Unlock()
+	t.Cleanup(func() {
+		jwtKeysMu.Lock()
+		jwtKeys, jwtKeysErr = nil, nil
+		jwtKeysMu.Unlock()
+	})
+}
+
+// writeRSAKeys 生成 RSA 密钥对并写入 PEM 文件，返回私钥与公钥路径
+func writeRSAKeys(t *testing.T) (privateFile, publicFile string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	dir := t.TempDir()
+	privateFile = filepath.Join(dir, "jwt.key")
+	publicFile = filepath.Join(dir, "jwt.pub")
+	writePEM(t, privateFile, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	writePEM(t, publicFile, "PUBLIC KEY", publicDER)
+	return privateFile, publicFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func loginToken(t *testing.T) string {
+	t.Helper()
+	token, err := GenerateLoginToken(1, "alice", false, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("generate login token: %v", err)
+	}
+	return token
+}
+
+func tokenKeyID(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &LoginClaims{})
+	if err != nil {
+		t.Fatalf("parse token header: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestParseLoginTokenRejectsExpired(t *testing.T) {
+	useJWTKeys(t, config.JWTConfig{Secret: "secret"})
+	expired, err := GenerateLoginToken(1, "alice", true, 0, -time.Minute)
+	if err != nil {
+		t.Fatalf("generate login token: %v", err)
+	}
+	if _, err := ParseLoginToken(expired); err == nil {
+		t.Fatal("expired token should be rejected")
+	}
+	if _, err := ParseLoginToken(loginToken(t)); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	useJWTKeys(t, config.JWTConfig{Secret: "old-secret", KeyID: "k1"})
+	oldToken := loginToken(t)
+	if kid := tokenKeyID(t, oldToken); kid != "k1" {
+		t.Fatalf("kid = %q, want k1", kid)
+	}
+
+	useJWTKeys(t, config.JWTConfig{Secret: "new-secret", KeyID: "k2",
+		PreviousKeys: []config.JWTKeyConfig{{ID: "k1", Secret: "old-secret"}}})
+	if _, err := ParseLoginToken(oldToken); err != nil {
+		t.Fatalf("token signed before rotation should still verify: %v", err)
+	}
+	newToken := loginToken(t)
+	if kid := tokenKeyID(t, newToken); kid != "k2" {
+		t.Fatalf("kid = %q, want k2", kid)
+	}
+	if _, err := ParseLoginToken(newToken); err != nil {
+		t.Fatalf("parse new token: %v", err)
+	}
+
+	// 移除旧密钥后旧 Token 失效
+	useJWTKeys(t, config.JWTConfig{Secret: "new-secret", KeyID: "k2"})
+	if _, err := ParseLoginToken(oldToken); err == nil {
+		t.Fatal("token with an unknown kid should be rejected")
+	}
+}
+
+func TestJWTLegacyTokenWithoutKeyID(t *testing.T) {
+	useJWTKeys(t, config.JWTConfig{Secret: "legacy"})
+	legacy := loginToken(t)
+	if kid := tokenKeyID(t, legacy); kid != "" {
+		t.Fatalf("kid = %q, want none without jwt.key_id", kid)
+	}
+
+	useJWTKeys(t, config.JWTConfig{Secret: "new-secret", KeyID: "k1",
+		PreviousKeys: []config.JWTKeyConfig{{Secret: "legacy"}}})
+	if _, err := ParseLoginToken(legacy); err != nil {
+		t.Fatalf("token without kid should verify with the previous key without id: %v", err)
+	}
+}
+
+func TestJWTRS256(t *testing.T) {
+	privateFile, publicFile := writeRSAKeys(t)
+
+	useJWTKeys(t, config.JWTConfig{Secret: "hmac-secret", KeyID: "hs"})
+	hmacToken := loginToken(t)
+
+	useJWTKeys(t, config.JWTConfig{Algorithm: "RS256", KeyID: "rs", PrivateKeyFile: privateFile,
+		PreviousKeys: []config.JWTKeyConfig{{ID: "hs", Secret: "hmac-secret"}}})
+	token := loginToken(t)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &LoginClaims{})
+	if err != nil {
+		t.Fatalf("parse token header: %v", err)
+	}
+	if alg := parsed.Method.Alg(); alg != "RS256" {
+		t.Fatalf("token alg = %s, want RS256", alg)
+	}
+	if _, err := ParseLoginToken(token); err != nil {
+		t.Fatalf("parse RS256 token: %v", err)
+	}
+	if _, err := ParseLoginToken(hmacToken); err != nil {
+		t.Fatalf("HS256 token from previous_keys should still verify: %v", err)
+	}
+
+	// 使用公钥作为 HMAC 密钥伪造的 Token 必须被拒绝
+	publicPEM, err := os.ReadFile(publicFile)
+	if err != nil {
+		t.Fatalf("read public key: %v", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, LoginClaims{ID: 1, Type: "login",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}})
+	forged.Header["kid"] = "rs"
+	forgedToken, err := forged.SignedString(publicPEM)
+	if err != nil {
+		t.Fatalf("sign forged token: %v", err)
+	}
+	if _, err := ParseLoginToken(forgedToken); err == nil {
+		t.Fatal("HS256 token forged with the RSA public key should be rejected")
+	}
+
+	// 轮换到新的 RSA 密钥后，旧私钥签发的 Token 可由其公钥验证
+	newPrivateFile, _ := writeRSAKeys(t)
+	useJWTKeys(t, config.JWTConfig{Algorithm: "RS256", KeyID: "rs2", PrivateKeyFile: newPrivateFile,
+		PreviousKeys: []config.JWTKeyConfig{{ID: "rs", PublicKeyFile: publicFile}}})
+	if _, err := ParseLoginToken(token); err != nil {
+		t.Fatalf("token signed by the previous RSA key should verify: %v", err)
+	}
+}
+
+func TestLoadJWTKeysRejectsInvalidConfig(t *testing.T) {
+	tests := map[string]config.JWTConfig{
+		"unknown algorithm":   {Algorithm: "ES256"},
+		"missing private key": {Algorithm: "RS256", PrivateKeyFile: filepath.Join(t.TempDir(), "missing.key")},
+		"duplicate id":        {Secret: "s", KeyID: "k1", PreviousKeys: []config.JWTKeyConfig{{ID: "k1", Secret: "old"}}},
+		"empty previous key":  {Secret: "s", KeyID: "k2", PreviousKeys: []config.JWTKeyConfig{{ID: "k1"}}},
+	}
+	for name, cfg := range tests {
+		if _, err := loadJWTKeys(cfg); err == nil {
+			t.Errorf("%s: loadJWTKeys should fail", name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// DHash 计算图片的 64 位差值感知哈希 (dHash)。
+// 先缩放为 9x8 灰度图，再逐行比较相邻像素的亮度，左侧更亮时对应位为 1。
+// 重新压缩、轻微缩放或调色后的图片哈希值通常只相差少数几位。
+func DHash(src image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.CatmullRom.Scale(small, small.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance 返回两个哈希值不同的位数
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func TestDHashToleratesResizing(t *testing.T) {
+	src := testImage(64, 48)
+	resized := image.NewNRGBA(image.Rect(0, 0, 160, 120))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	if d := HammingDistance(DHash(src), DHash(resized)); d > 5 {
+		t.Fatalf("distance between original and resized = %d, want <= 5", d)
+	}
+
+	// 水平镜像后左右亮度关系全部反转
+	mirrored := image.NewNRGBA(src.Bounds())
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			mirrored.Set(63-x, y, src.At(x, y))
+		}
+	}
+	if d := HammingDistance(DHash(src), DHash(mirrored)); d < 32 {
+		t.Fatalf("distance between original and mirrored = %d, want a clearly different hash", d)
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	if d := HammingDistance(0, 0); d != 0 {
+		t.Fatalf("distance(0, 0) = %d", d)
+	}
+	if d := HammingDistance(0b1011, 0b0001); d != 2 {
+		t.Fatalf("distance = %d, want 2", d)
+	}
+	if d := HammingDistance(0, ^uint64(0)); d != 64 {
+		t.Fatalf("distance = %d, want 64", d)
+	}
+}
+
+func TestDHashOfUniformImage(t *testing.T) {
+	img := image.NewUniform(color.Gray{Y: 200})
+	bounded := image.NewGray(image.Rect(0, 0, 10, 10))
+	draw.Draw(bounded, bounded.Bounds(), img, image.Point{}, draw.Src)
+	if h := DHash(bounded); h != 0 {
+		t.Fatalf("hash of a uniform image = %x, want 0", h)
+	}
+}
@@ -0,0 +1,319 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSVG 文件不是合法的 SVG (XML 格式错误或根元素不是 <svg>)
+var ErrInvalidSVG = errors.New("不是有效的 SVG 文件")
+
+// svgBlockedElements SVG 中会执行脚本或嵌入外部文档的元素，清理时连同子节点一起删除
+var svgBlockedElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"audio":         true,
+	"video":         true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// SanitizeSVG 清理 SVG 中可执行或引用外部资源的内容，返回清理后的文档：
+//   - 删除 <script>、<foreignObject> 等元素及 DOCTYPE/实体声明、注释、处理指令
+//   - 删除 on* 事件属性，以及指向站外资源或 javascript: 的 href/xlink:href
+//   - 删除包含外部 url()、@import 或 javascript: 的样式 (style 属性与 <style> 元素)
+//   - 删除通过 attributeName 修改 href 或事件属性的动画元素
+//
+// 根元素不是 <svg> 或 XML 格式错误时返回 ErrInvalidSVG
+func SanitizeSVG(data []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+
+	var out bytes.Buffer
+	var stack []xml.Name
+	// pendingStart 尚未写出结束符的开始标签，紧跟结束标签时写为自闭合形式
+	pendingStart := false
+	rootSeen := false
+	flushStart := func() {
+		if pendingStart {
+			out.WriteByte('>')
+			pendingStart = false
+		}
+	}
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidSVG
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if !rootSeen {
+				if t.Name.Space != "" || t.Name.Local != "svg" {
+					return nil, ErrInvalidSVG
+				}
+				rootSeen = true
+			} else if len(stack) == 0 {
+				return nil, ErrInvalidSVG // 只允许一个根元素
+			}
+			local := strings.ToLower(t.Name.Local)
+			if svgBlockedElements[local] || svgAnimatesUnsafeAttr(t) {
+				if err := skipSVGElement(d, t.Name); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if local == "style" {
+				css, plain, err := readSVGStyleText(d, t.Name)
+				if err != nil {
+					return nil, err
+				}
+				if !plain || svgUnsafeCSS(css) {
+					continue
+				}
+				flushStart()
+				writeSVGStart(&out, t)
+				out.WriteByte('>')
+				_ = xml.EscapeText(&out, []byte(css))
+				writeSVGEnd(&out, t.Name)
+				continue
+			}
+			flushStart()
+			writeSVGStart(&out, t)
+			pendingStart = true
+			stack = append(stack, t.Name)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1] != t.Name {
+				return nil, ErrInvalidSVG
+			}
+			stack = stack[:len(stack)-1]
+			if pendingStart {
+				out.WriteString("/>")
+				pendingStart = false
+			} else {
+				writeSVGEnd(&out, t.Name)
+			}
+		case xml.CharData:
+			if len(stack) == 0 {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, ErrInvalidSVG
+				}
+				continue
+			}
+			flushStart()
+			_ = xml.EscapeText(&out, t)
+		}
+		// 注释、处理指令 (包括 <?xml ... ?> 声明) 与 DOCTYPE 等指令均被丢弃
+	}
+
+	if !rootSeen || len(stack) != 0 {
+		return nil, ErrInvalidSVG
+	}
+	return out.Bytes(), nil
+}
+
+// SVGSize 读取 SVG 根元素的 width/height 属性 (无单位或 px)，缺失或使用其他单位时取 viewBox 的宽高。
+// 无法确定时返回 0, 0
+func SVGSize(data []byte) (int, int) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return 0, 0
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var width, height, viewW, viewH float64
+		for _, attr := range start.Attr {
+			if attr.Name.Space != "" {
+				continue
+			}
+			switch attr.Name.Local {
+			case "width":
+				width = parseSVGLength(attr.Value)
+			case "height":
+				height = parseSVGLength(attr.Value)
+			case "viewBox":
+				fields := strings.FieldsFunc(attr.Value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' })
+				if len(fields) == 4 {
+					viewW, _ = strconv.ParseFloat(fields[2], 64)
+					viewH, _ = strconv.ParseFloat(fields[3], 64)
+				}
+			}
+		}
+		if width <= 0 || height <= 0 {
+			width, height = viewW, viewH
+		}
+		if width <= 0 || height <= 0 || math.IsInf(width, 0) || math.IsInf(height, 0) || width > math.MaxInt32 || height > math.MaxInt32 {
+			return 0, 0
+		}
+		return int(math.Ceil(width)), int(math.Ceil(height))
+	}
+}
+
+// parseSVGLength 解析无单位或 px 单位的长度，其他单位返回 0
+func parseSVGLength(value string) float64 {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
+
+// writeSVGStart 写出开始标签 (不含结尾的 > )，过滤不安全的属性
+func writeSVGStart(out *bytes.Buffer, t xml.StartElement) {
+	out.WriteByte('<')
+	out.WriteString(svgQName(t.Name))
+	for _, attr := range t.Attr {
+		if svgUnsafeAttr(attr) {
+			continue
+		}
+		out.WriteByte(' ')
+		out.WriteString(svgQName(attr.Name))
+		out.WriteString(`="`)
+		_ = xml.EscapeText(out, []byte(attr.Value))
+		out.WriteByte('"')
+	}
+}
+
+func writeSVGEnd(out *bytes.Buffer, name xml.Name) {
+	out.WriteString("</")
+	out.WriteString(svgQName(name))
+	out.WriteByte('>')
+}
+
+// svgQName 还原带前缀的限定名 (RawToken 中 Space 为前缀而非命名空间 URI)
+func svgQName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// svgUnsafeAttr 判断属性是否需要删除
+func svgUnsafeAttr(attr xml.Attr) bool {
+	local := strings.ToLower(attr.Name.Local)
+	if strings.HasPrefix(local, "on") {
+		return true
+	}
+	value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+	if strings.Contains(value, "javascript:") || strings.Contains(value, "vbscript:") {
+		return true
+	}
+	if local == "href" || local == "src" {
+		return !svgSafeRef(value)
+	}
+	return svgUnsafeCSS(value)
+}
+
+// svgSafeRef 只允许文档内引用 (#id) 与内嵌的位图 data URI
+func svgSafeRef(value string) bool {
+	if strings.HasPrefix(value, "#") {
+		return true
+	}
+	for _, prefix := range []string{"data:image/png", "data:image/jpeg", "data:image/gif", "data:image/webp"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// svgUnsafeCSS 判断样式文本是否引用外部资源或可执行内容 (只允许 url(#id) 形式的文档内引用)
+func svgUnsafeCSS(css string) bool {
+	css = strings.ToLower(strings.Join(strings.Fields(css), ""))
+	if strings.Contains(css, "@import") || strings.Contains(css, "javascript:") || strings.Contains(css, "expression(") {
+		return true
+	}
+	for rest := css; ; {
+		i := strings.Index(rest, "url(")
+		if i < 0 {
+			return false
+		}
+		rest = strings.TrimLeft(rest[i+len("url("):], `"'`)
+		if !strings.HasPrefix(rest, "#") {
+			return true
+		}
+	}
+}
+
+// svgAnimatesUnsafeAttr 判断动画元素 (set/animate 等) 是否通过 attributeName 修改 href 或事件属性
+func svgAnimatesUnsafeAttr(t xml.StartElement) bool {
+	for _, attr := range t.Attr {
+		if attr.Name.Local != "attributeName" {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(attr.Value))
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[i+1:]
+		}
+		if name == "href" || strings.HasPrefix(name, "on") {
+			return true
+		}
+	}
+	return false
+}
+
+// skipSVGElement 跳过 name 元素的全部子节点直到其结束标签
+func skipSVGElement(d *xml.Decoder, name xml.Name) error {
+	depth := 1
+	for depth > 0 {
+		tok, err := d.RawToken()
+		if err != nil {
+			return ErrInvalidSVG
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 && t.Name != name {
+				return ErrInvalidSVG
+			}
+		}
+	}
+	return nil
+}
+
+// readSVGStyleText 读取 <style> 元素的文本内容，plain 为 false 表示其中包含子元素
+func readSVGStyleText(d *xml.Decoder, name xml.Name) (text string, plain bool, err error) {
+	var buf strings.Builder
+	nested := false
+	depth := 1
+	for depth > 0 {
+		tok, tokErr := d.RawToken()
+		if tokErr != nil {
+			return "", false, ErrInvalidSVG
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			nested = true
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 && t.Name != name {
+				return "", false, ErrInvalidSVG
+			}
+		case xml.CharData:
+			if depth == 1 {
+				buf.Write(t)
+			}
+		}
+	}
+	return buf.String(), !nested, nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVGRemovesActiveContent(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<!DOCTYPE svg [<!ENTITY x "boom">]>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="10" height="10" onload="alert(1)">
+  <!-- comment -->
+  <script>alert(1)</script>
+  <foreignObject><iframe src="https://evil.example"></iframe></foreignObject>
+  <style>@import url(https://evil.example/a.css);</style>
+  <style>.a{fill:url(#grad)}</style>
+  <a xlink:href="javascript:alert(1)"><rect width="5" height="5"/></a>
+  <use href="https://evil.example/sprite.svg#icon"/>
+  <use href="#local"/>
+  <image href="data:image/png;base64,AAAA"/>
+  <rect style="fill:url(https://evil.example/p)" onclick="x()" fill="red"/>
+  <set attributeName="xlink:href" to="javascript:alert(1)"/>
+  <animate attributeName="opacity" from="0" to="1"/>
+</svg>`
+
+	out, err := SanitizeSVG([]byte(input))
+	if err != nil {
+		t.Fatalf("SanitizeSVG: %v", err)
+	}
+	got := string(out)
+	for _, banned := range []string{"<script", "alert", "foreignObject", "iframe", "@import", "evil.example", "onload", "onclick", "<set", "DOCTYPE", "ENTITY", "<!--", "<?xml"} {
+		if strings.Contains(got, banned) {
+			t.Errorf("sanitized SVG still contains %q:\n%s", banned, got)
+		}
+	}
+	for _, kept := range []string{`<style>.a{fill:url(#grad)}</style>`, `<use href="#local"/>`, `href="data:image/png;base64,AAAA"`, `fill="red"`, `<animate attributeName="opacity"`, `<rect width="5" height="5"/>`} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("sanitized SVG lost %q:\n%s", kept, got)
+		}
+	}
+}
+
+func TestSanitizeSVGRejectsNonSVG(t *testing.T) {
+	for _, input := range []string{
+		`<html><body/></html>`,
+		`<svg><rect></svg>`,
+		`<svg/><svg/>`,
+		`<svg/>trailing`,
+		`not xml at all`,
+		``,
+	} {
+		if _, err := SanitizeSVG([]byte(input)); !errors.Is(err, ErrInvalidSVG) {
+			t.Errorf("SanitizeSVG(%q) err = %v, want ErrInvalidSVG", input, err)
+		}
+	}
+}
+
+func TestSVGSize(t *testing.T) {
+	tests := []struct {
+		svg          string
+		wantW, wantH int
+	}{
+		{`<svg width="120" height="80"/>`, 120, 80},
+		{`<svg width="12.5px" height="8px"/>`, 13, 8},
+		{`<svg width="50%" height="2em" viewBox="0 0 300 150"/>`, 300, 150},
+		{`<svg viewBox="0,0,64,32"/>`, 64, 32},
+		{`<svg/>`, 0, 0},
+		{`<svg width="1e40" height="10"/>`, 0, 0},
+	}
+	for _, tt := range tests {
+		if w, h := SVGSize([]byte(tt.svg)); w != tt.wantW || h != tt.wantH {
+			t.Errorf("SVGSize(%q) = %d, %d; want %d, %d", tt.svg, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import "strings"
+
+// uaBrowsers 按匹配顺序排列的浏览器标识，基于 Chromium 的浏览器都带有 Chrome/ 与 Safari/，需排在前面
+var uaBrowsers = []struct {
+	token, name string
+}{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Opera", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"YaBrowser/", "Yandex"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chromium/", "Chromium"},
+	{"Chrome/", "Chrome"},
+	{"Version/", "Safari"},
+	{"Trident/", "Internet Explorer"},
+	{"MSIE ", "Internet Explorer"},
+}
+
+// uaSystems 按匹配顺序排列的操作系统标识，Android 与 ChromeOS 的 User-Agent 中也包含 Linux，需排在前面
+var uaSystems = []struct {
+	token, name string
+}{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"iPod", "iOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Macintosh", "macOS"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// ParseUserAgent 从 User-Agent 中识别浏览器类型与操作系统，不含版本号
+// 无法识别的浏览器取第一个产品名 (如 curl)，无法识别的系统返回空字符串
+func ParseUserAgent(userAgent string) (browser, os string) {
+	for _, b := range uaBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	if browser == "" {
+		product, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+		product, _, _ = strings.Cut(product, " ")
+		browser = product
+	}
+	for _, s := range uaSystems {
+		if strings.Contains(userAgent, s.token) {
+			os = s.name
+			break
+		}
+	}
+	return browser, os
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"regexp"
@@ -19,14 +20,6 @@ func ValidateUsername(username string) (bool, string) {
 		return false, "用户名只能包含英文大小写、数字和下划线"
 	}
 
-	// 检查保留用户名，防止冒充官方
-	reserved := []string{"admin", "root", "system", "audit", "security", "support"}
-	for _, r := range reserved {
-		if strings.EqualFold(username, r) {
-			return false, "用户名包含保留词汇，不可使用"
-		}
-	}
-
 	// 不能是纯数字
 	if matched, _ := regexp.MatchString(`^[0-9]+$`, username); matched {
 		return false, "用户名不能为纯数字"
@@ -35,26 +28,6 @@ func ValidateUsername(username string) (bool, string) {
 	return true, ""
 }
 
-// ValidatePassword checks if the password meets the requirements.
-// Returns true if valid, otherwise false and an error message.
-func ValidatePassword(password string) (bool, string) {
-	if len(password) < 8 {
-		return false, "密码最少8位"
-	}
-
-	if matched, _ := regexp.MatchString(`^[a-zA-Z0-9[:punct:]]+$`, password); !matched {
-		return false, "密码只能包含英文大小写、数字和符号"
-	}
-
-	hasLetter, _ := regexp.MatchString(`[a-zA-Z]`, password)
-	hasNum, _ := regexp.MatchString(`[0-9]`, password)
-	if !hasLetter || !hasNum {
-		return false, "密码必须包含至少一个字母和一个数字"
-	}
-
-	return true, ""
-}
-
 // ValidateEmail checks if the email is valid.
 func ValidateEmail(email string) (bool, string) {
 	// 简单的邮箱正则验证
@@ -66,35 +39,84 @@ func ValidateEmail(email string) (bool, string) {
 	return true, ""
 }
 
-// ValidateImageContent checks if the file content matches the extension.
-func ValidateImageContent(reader io.ReadSeeker, ext string) (bool, string) {
+// NormalizeEmail 规范化邮箱地址（去除首尾空白并转为小写），用于唯一性比较
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// imageTypeExts 已知图片类型 (规范名称) 与对应的扩展名
+var imageTypeExts = map[string][]string{
+	"jpg":  {".jpg", ".jpeg"},
+	"png":  {".png"},
+	"gif":  {".gif"},
+	"webp": {".webp"},
+	"bmp":  {".bmp"},
+	"svg":  {".svg"},
+}
+
+// imageMimeTypes 内容嗅探得到的 MIME 类型与图片类型 (规范名称) 的对应关系
+var imageMimeTypes = map[string]string{
+	"image/jpeg":     "jpg",
+	"image/png":      "png",
+	"image/gif":      "gif",
+	"image/webp":     "webp",
+	"image/bmp":      "bmp",
+	"image/x-ms-bmp": "bmp",
+}
+
+// ParseImageTypes 解析逗号分隔的图片类型列表 (如 "png,webp,.jpg,jpeg")，返回去重后的规范名称。
+// 未知的类型会被忽略。
+func ParseImageTypes(raw string) []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(part)), ".")
+		if name == "jpeg" {
+			name = "jpg"
+		}
+		if _, ok := imageTypeExts[name]; !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		types = append(types, name)
+	}
+	return types
+}
+
+// ImageTypeForExt 返回扩展名对应的图片类型 (规范名称)，未知扩展名返回空字符串
+func ImageTypeForExt(ext string) string {
+	for name, exts := range imageTypeExts {
+		for _, e := range exts {
+			if e == ext {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// ImageExtForType 返回图片类型 (规范名称) 的默认扩展名，未知类型返回空字符串
+func ImageExtForType(name string) string {
+	if exts := imageTypeExts[name]; len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// DetectImageType 嗅探文件头判断图片类型，返回规范名称 (非已知图片类型时为空字符串) 与嗅探得到的 MIME 类型。
+// 读取后会将读取位置重置到文件开头
+func DetectImageType(reader io.ReadSeeker) (string, string, error) {
 	buffer := make([]byte, 512)
 	_, err := reader.Read(buffer)
 	if err != nil && err != io.EOF {
-		return false, "读取文件内容失败"
+		return "", "", errors.New("读取文件内容失败")
 	}
 
 	// 重置读取位置
 	if _, err := reader.Seek(0, 0); err != nil {
-		return false, "重置文件读取位置失败"
+		return "", "", errors.New("重置文件读取位置失败")
 	}
 
 	contentType := http.DetectContentType(buffer)
-
-	allowedTypes := map[string]map[string]bool{
-		"image/jpeg":     {".jpg": true, ".jpeg": true},
-		"image/png":      {".png": true},
-		"image/gif":      {".gif": true},
-		"image/webp":     {".webp": true},
-		"image/bmp":      {".bmp": true},
-		"image/x-ms-bmp": {".bmp": true},
-	}
-
-	if exts, ok := allowedTypes[contentType]; ok {
-		if exts[ext] {
-			return true, ""
-		}
-	}
-
-	return false, "文件真实类型(" + contentType + ")与扩展名(" + ext + ")不匹配或不支持"
+	return imageMimeTypes[contentType], contentType, nil
 }
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImageTypes(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{raw: "png,webp,jpg", want: []string{"png", "webp", "jpg"}},
+		{raw: " .PNG , jpeg,.jpg ", want: []string{"png", "jpg"}},
+		{raw: "png,tiff,,exe", want: []string{"png"}},
+		{raw: "", want: nil},
+		{raw: "heic", want: nil},
+	}
+	for _, tt := range tests {
+		if got := ParseImageTypes(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseImageTypes(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
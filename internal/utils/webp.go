@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+
+	"github.com/gen2brain/webp"
+)
+
+// webpMaxDimension WebP 支持的最大宽高
+const webpMaxDimension = 1 << 14
+
+// ErrWebPTooLarge 图片宽或高超出 WebP 支持的范围
+var ErrWebPTooLarge = errors.New("图片尺寸超出 WebP 支持的范围")
+
+// EncodeWebP 使用 libwebp 将图片编码为 WebP。lossless 为 true 时使用无损编码 (忽略 quality)，
+// 否则按 quality (1-100) 有损编码。调用方应在结果不小于原文件时丢弃
+func EncodeWebP(img image.Image, quality int, lossless bool) ([]byte, error) {
+	b := img.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > webpMaxDimension || b.Dy() > webpMaxDimension {
+		return nil, ErrWebPTooLarge
+	}
+	if quality < 1 || quality > 100 {
+		quality = webp.DefaultQuality
+	}
+
+	var buf bytes.Buffer
+	if err := webp.Encode(&buf, img, webp.Options{
+		Quality:  quality,
+		Lossless: lossless,
+		Method:   webp.DefaultMethod,
+	}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsLosslessWebP 判断 WebP 文件的图像数据是否为无损编码 (VP8L)
+func IsLosslessWebP(data []byte) bool {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return false
+	}
+	// 扩展格式 (VP8X) 的图像数据块位于 ICCP、ANIM 等块之后
+	pos := 12
+	for pos+8 <= len(data) {
+		chunk := string(data[pos : pos+4])
+		switch chunk {
+		case "VP8L":
+			return true
+		case "VP8 ", "ANMF":
+			return false
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		// 块内容按偶数字节对齐
+		pos += 8 + size + size&1
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+func TestEncodeWebPLosslessRoundTrip(t *testing.T) {
+	src := testImage(40, 30)
+	data, err := EncodeWebP(src, 0, true)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !IsLosslessWebP(data) {
+		t.Fatal("lossless output not detected as VP8L")
+	}
+	decoded, err := xwebp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Bounds() != src.Bounds() {
+		t.Fatalf("bounds = %v, want %v", decoded.Bounds(), src.Bounds())
+	}
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			r1, g1, b1, a1 := src.At(x, y).RGBA()
+			r2, g2, b2, a2 := decoded.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				t.Fatalf("pixel (%d,%d) changed by lossless encoding", x, y)
+			}
+		}
+	}
+}
+
+func TestEncodeWebPLossyRoundTrip(t *testing.T) {
+	data, err := EncodeWebP(testImage(64, 48), 80, false)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if IsLosslessWebP(data) {
+		t.Fatal("lossy output detected as VP8L")
+	}
+	decoded, err := xwebp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Bounds() != image.Rect(0, 0, 64, 48) {
+		t.Fatalf("bounds = %v", decoded.Bounds())
+	}
+}
+
+func TestEncodeWebPRejectsOversized(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, webpMaxDimension+1, 1))
+	if _, err := EncodeWebP(img, 80, true); err != ErrWebPTooLarge {
+		t.Fatalf("error = %v, want ErrWebPTooLarge", err)
+	}
+}
@@ -14,9 +14,11 @@ import (
 	"perfect-pic-server/internal/config"
 	"perfect-pic-server/internal/consts"
 	"perfect-pic-server/internal/db"
+	"perfect-pic-server/internal/handler"
 	"perfect-pic-server/internal/middleware"
 	"perfect-pic-server/internal/router"
 	"perfect-pic-server/internal/service"
+	"perfect-pic-server/internal/storage"
 	"strings"
 	"syscall"
 	"time"
@@ -35,21 +37,54 @@ var (
 func main() {
 
 	exportRoutes := flag.Bool("export", false, "导出路由到 routes.json 并退出")
+	migrateStorage := flag.Bool("migrate-storage", false, "将本地 upload.path 中的图片迁移到当前配置的存储后端并退出")
+	migrateDeleteSource := flag.Bool("migrate-delete-source", false, "迁移成功后删除本地源文件 (配合 -migrate-storage)")
+	migrateBatchSize := flag.Int("migrate-batch-size", 100, "迁移时每批处理的图片数量 (配合 -migrate-storage)")
+	backfillAltFormats := flag.Bool("backfill-alt-formats", false, "为已有图片补生成 WebP 备选文件并退出 (需开启 generate_alt_formats，可在服务运行时执行)")
+	backfillBatchSize := flag.Int("backfill-batch-size", 100, "补生成备选文件时每批处理的图片数量 (配合 -backfill-alt-formats)")
 	flag.Parse()
 
 	config.InitConfig()
 	db.InitDB()
 	service.InitializeSettings()
+	service.WarmSettingsCache()
+	service.ApplyLogSettings()
 
-	uploadPath, avatarPath := ensureDirectories()
+	if *migrateStorage {
+		runStorageMigration(*migrateBatchSize, *migrateDeleteSource)
+		return
+	}
+	if *backfillAltFormats {
+		runAltFormatBackfill(*backfillBatchSize)
+		return
+	}
+	service.StartSettingsSync()
+	service.StartEmailQueue()
+	service.StartAuditWriter()
+	service.StartIntegrityScanner()
+	service.StartImageViewFlusher()
+	service.StartChunkUploadJanitor()
+	service.StartPasswordResetJanitor()
+	service.StartUnverifiedUserJanitor()
+	if scheduled, err := service.ScheduleAnonymousImageExpiry(); err != nil {
+		log.Printf("⚠️ 设置访客图片过期时间失败: %v", err)
+	} else if scheduled > 0 {
+		log.Printf("🕒 已为 %d 张访客图片设置过期时间", scheduled)
+	}
+	service.StartImageExpiryJanitor()
+	service.StartAccountDeletionJanitor()
+
+	_, avatarPath := ensureDirectories()
 
 	gin.SetMode(config.Get().Server.Mode)
 
-	r := gin.Default()
+	// 请求日志由 middleware.RequestLogger 以结构化格式输出，不使用 gin 默认的 Logger
+	r := gin.New()
+	r.Use(gin.Recovery())
 	applyTrustedProxies(r)
 	router.InitRouter(r)
 
-	setupStaticFiles(r, uploadPath, avatarPath)
+	setupStaticFiles(r, avatarPath)
 
 	distFS := GetFrontendAssets()
 	indexData := setupFrontend(r, distFS)
@@ -83,11 +118,17 @@ func ensureDirectories() (string, string) {
 	return uploadPath, avatarPath
 }
 
-func setupStaticFiles(r *gin.Engine, uploadPath, avatarPath string) {
-	// 使用带缓存控制的静态文件服务
-	r.Group(config.Get().Upload.URLPrefix, middleware.StaticCacheMiddleware()).
-		StaticFS("", gin.Dir(uploadPath, false))
+func setupStaticFiles(r *gin.Engine, avatarPath string) {
+	// 图片由处理函数提供访问，以支持按原始文件名下载
+	// 图片可被原地替换，缓存策略由 ServeImage 设置，不使用静态资源的长缓存
+	imageGroup := r.Group(config.Get().Upload.URLPrefix, middleware.HotlinkProtection())
+	imageGroup.GET("/*filepath", handler.ServeImage)
+	imageGroup.HEAD("/*filepath", handler.ServeImage)
+	// 持有上传时返回的删除 Token 即可免登录删除图片 (DELETE {图片地址}?token=...)
+	r.Group(config.Get().Upload.URLPrefix).DELETE("/*filepath",
+		middleware.RateLimitMiddleware(consts.ConfigRateLimitAuthRPS, consts.ConfigRateLimitAuthBurst), handler.DeleteImageByPathToken)
 
+	// 使用带缓存控制的静态文件服务
 	r.Group(config.Get().Upload.AvatarURLPrefix, middleware.StaticCacheMiddleware()).
 		StaticFS("", gin.Dir(avatarPath, false))
 }
@@ -174,12 +215,30 @@ func startServer(r *gin.Engine) {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("🛑 正在关闭服务...")
+	// 先让探针失败，并等待负载均衡观察到后停止转发新请求，再关闭监听
+	service.SetShuttingDown()
+	if drain := config.Get().Server.ShutdownDrainSeconds; drain > 0 {
+		log.Printf("⏳ 等待 %d 秒以摘除流量...", drain)
+		time.Sleep(time.Duration(drain) * time.Second)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("❌ 服务强制关闭:", err)
 	}
+	// 发送完队列中剩余的邮件
+	service.StopEmailQueue(5 * time.Second)
+	// 写完队列中剩余的审计日志
+	service.StopAuditWriter(5 * time.Second)
+	// 写回缓冲中的图片访问计数
+	service.StopImageViewFlusher()
+	service.StopChunkUploadJanitor()
+	service.StopPasswordResetJanitor()
+	service.StopAccountDeletionJanitor()
+	service.StopUnverifiedUserJanitor()
+	service.StopImageExpiryJanitor()
+	service.StopSettingsSync()
 	log.Println("✅ 服务已退出")
 }
 
@@ -318,3 +377,50 @@ func splitTrustedProxyList(raw string) []string {
 	}
 	return out
 }
+
+// runStorageMigration 将本地磁盘中的图片迁移到当前配置的存储后端
+func runStorageMigration(batchSize int, deleteSource bool) {
+	target, err := service.ImageStorage()
+	if err != nil {
+		log.Fatal("获取目标存储失败: ", err)
+	}
+	if _, ok := target.(*storage.Local); ok {
+		log.Fatal("当前存储后端为本地磁盘，请先修改 storage_backend 配置")
+	}
+
+	source := storage.NewLocal(config.Get().Upload.Path)
+	lastPercent := -1
+	result, err := service.MigrateStorage(source, target, batchSize, deleteSource, func(done, total int) {
+		if total == 0 {
+			return
+		}
+		if percent := done * 100 / total; percent != lastPercent {
+			lastPercent = percent
+			log.Printf("迁移进度: %d/%d (%d%%)", done, total, percent)
+		}
+	})
+	if err != nil {
+		log.Fatal("迁移失败: ", err)
+	}
+	log.Printf("✅ 迁移完成: 共 %d，复制 %d，已存在且一致跳过 %d，源文件缺失 %d，失败 %d",
+		result.Total, result.Copied, result.Skipped, result.Missing, result.Failed)
+}
+
+// runAltFormatBackfill 为已有图片补生成 WebP 备选文件
+func runAltFormatBackfill(batchSize int) {
+	lastPercent := -1
+	result, err := service.BackfillAltFormats(batchSize, func(done, total int) {
+		if total == 0 {
+			return
+		}
+		if percent := done * 100 / total; percent != lastPercent {
+			lastPercent = percent
+			log.Printf("补生成进度: %d/%d (%d%%)", done, total, percent)
+		}
+	})
+	if err != nil {
+		log.Fatal("补生成备选文件失败: ", err)
+	}
+	log.Printf("✅ 补生成完成: 共 %d，生成 %d，跳过 %d，原文件缺失 %d，失败 %d",
+		result.Total, result.Generated, result.Skipped, result.Missing, result.Failed)
+}